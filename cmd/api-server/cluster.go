@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/api"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+func newInstance(cfg config.Config, version string) redisclient.Instance {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	addr := cfg.AdvertiseAddr
+	if addr == "" {
+		addr = "http://" + hostname + ":" + cfg.HTTPPort
+	}
+
+	return redisclient.Instance{
+		ID:        uuid.NewString(),
+		Hostname:  hostname,
+		Addr:      addr,
+		Version:   version,
+		StartedAt: time.Now().UTC(),
+	}
+}
+
+// runHeartbeat publishes the instance record every interval until ctx is done.
+// RequestRate is the request count delta over the time since the last beat.
+func runHeartbeat(ctx context.Context, registry *redisclient.InstanceRegistry, inst redisclient.Instance, interval time.Duration, requests *api.RequestCounter) {
+	lastTotal := requests.Total()
+	lastBeat := time.Now()
+
+	beat := func() {
+		now := time.Now()
+		total := requests.Total()
+		if elapsed := now.Sub(lastBeat).Seconds(); elapsed > 0 {
+			inst.RequestRate = float64(total-lastTotal) / elapsed
+		}
+		lastTotal, lastBeat = total, now
+		inst.LastSeen = now.UTC()
+
+		beatCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		if err := registry.Heartbeat(beatCtx, inst); err != nil {
+			log.Printf("instance heartbeat error: %v", err)
+		}
+	}
+
+	beat()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			beat()
+		}
+	}
+}
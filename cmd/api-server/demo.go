@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/api"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/clock"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/demo"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+// setupDemo runs everything in-process: SQLite storage, an in-memory slot
+// locker and the expiry loop the expiry-worker normally owns. Webhooks need
+// Postgres and are not mounted.
+func setupDemo(ctx context.Context, cfg config.Config, svcOpts ...appointment.Option) (api.RouterConfig, func()) {
+	sqlDB, err := db.OpenSQLite(ctx, cfg.DemoSQLitePath)
+	if err != nil {
+		log.Fatalf("sqlite open error: %v", err)
+	}
+	log.Printf("opened SQLite at %s", cfg.DemoSQLitePath)
+
+	repo := appointment.NewSqliteRepository(sqlDB)
+	if cfg.BookingQueueRate > 0 {
+		svcOpts = append(svcOpts, appointment.WithBookingQueue(redisclient.NewInMemoryBookingQueue()))
+	}
+	svc := appointment.NewService(repo, redisclient.NewInMemorySlotLocker(), cfg,
+		append(svcOpts, appointment.WithClock(clock.Scaled(float64(cfg.DemoTimeScale))))...)
+	reconcileBookings(ctx, shard.Default, svc, nil, cfg.LockTTL)
+
+	seedCtx, cancelSeed := context.WithTimeout(ctx, 30*time.Second)
+	ds, err := demo.Seed(seedCtx, repo)
+	cancelSeed()
+	if err != nil {
+		log.Fatalf("demo seed error: %v", err)
+	}
+	log.Printf("demo data seeded: clinic=%s clinicians=%d patients=%d slots=%d",
+		ds.ClinicID, len(ds.ClinicianIDs), len(ds.PatientIDs), len(ds.SlotIDs))
+	log.Printf("try: clinician_id=%s patient_id=%s slot_id=%s",
+		ds.ClinicianIDs[0], ds.PatientIDs[0], ds.SlotIDs[0])
+
+	// Keep the expiry cadence the same in simulated time
+	interval := cfg.WorkerInterval
+	if cfg.DemoTimeScale > 1 {
+		interval = max(interval/time.Duration(cfg.DemoTimeScale), time.Second)
+		log.Printf("demo clock runs %dx faster than real time", cfg.DemoTimeScale)
+	}
+
+	expiryCtx, stopExpiry := context.WithCancel(ctx)
+	go runExpiryLoop(expiryCtx, svc, interval)
+
+	cleanup := func() {
+		stopExpiry()
+		if err := sqlDB.Close(); err != nil {
+			log.Printf("error closing sqlite: %v", err)
+		}
+	}
+
+	routerCfg := api.RouterConfig{
+		Service: svc,
+		Health:  []api.DependencyCheck{api.SQLCheck("sqlite", sqlDB)},
+		Shedder: newLoadShedder(cfg, api.SQLPoolWaits(sqlDB)),
+	}
+	if cfg.WidgetEnabled {
+		routerCfg.Widget = &api.WidgetConfig{Limiter: redisclient.NewInMemoryRateLimiter()}
+	}
+	return routerCfg, cleanup
+}
+
+func runExpiryLoop(ctx context.Context, svc *appointment.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+			if _, err := svc.ExpirePendingAppointments(runCtx, nil); err != nil {
+				log.Printf("demo expiry run error: %v", err)
+			}
+			cancel()
+		}
+	}
+}
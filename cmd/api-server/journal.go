@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// reconcileBookings cleans up after instances that crashed mid-booking. Only
+// intents older than twice the lock TTL are touched: a live booking finishes
+// or gives up within one TTL of taking the lock.
+func reconcileBookings(ctx context.Context, shardName string, svc *appointment.Service, releaser redisclient.LockReleaser, lockTTL time.Duration) {
+	reconcileCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	res, err := svc.ReconcileBookingIntents(reconcileCtx, releaser, time.Now().Add(-2*lockTTL))
+	if err != nil {
+		log.Printf("booking journal reconciliation error on shard %s: %v", shardName, err)
+		return
+	}
+	log.Printf("booking journal reconciled on shard %s: stale=%d aborted=%d locks_released=%d",
+		shardName, res.Stale, res.Aborted, res.LocksReleased)
+}
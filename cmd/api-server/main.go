@@ -2,26 +2,51 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/hackgods/distributed-appointment-scheduling/internal/api"
 	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/blob"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/bulkcancel"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/clamav"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/cluster"
 	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
 	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/jobs"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/outbound"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/push"
 	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/region"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/turnstile"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/webhook"
 )
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	demoMode := flag.Bool("demo", false, "run with embedded SQLite, an in-memory locker and a seeded demo dataset")
+	flag.Parse()
+
 	log.Println("api-server starting up")
 
-	cfg, err := config.Load()
+	var cfg config.Config
+	var err error
+	if *demoMode {
+		cfg, err = config.LoadDemo()
+	} else {
+		cfg, err = config.Load()
+	}
 	if err != nil {
 		log.Fatalf("config load error: %v", err)
 	}
@@ -31,54 +56,97 @@ func main() {
 	rootCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	// Connect Postgres
-	pgCtx, cancelPg := context.WithTimeout(rootCtx, 10*time.Second)
-	pgPool, err := db.ConnectPostgres(pgCtx, cfg.PostgresDSN)
-	cancelPg()
-	if err != nil {
-		log.Fatalf("postgres connection error: %v", err)
+	version := os.Getenv("APP_VERSION")
+	if version == "" {
+		version = "dev"
 	}
-	defer pgPool.Close()
-	log.Println("connected to Postgres")
+	requests := &api.RequestCounter{}
 
-	// Connect Redis
-	rdb, err := redisclient.NewRedisClient(cfg.RedisAddr, cfg.RedisUsername, cfg.RedisPassword)
+	blobs, err := blob.Open(cfg.Blob())
 	if err != nil {
-		log.Fatalf("redis connection error: %v", err)
+		log.Fatalf("blob storage config error: %v", err)
 	}
-	defer func() {
-		if err := rdb.Close(); err != nil {
-			log.Printf("error closing redis: %v", err)
-		}
-	}()
-	log.Println("connected to Redis")
+	svcOpts := attachmentOptions(cfg, blobs)
 
-	repo := appointment.NewPgRepository(pgPool)
-	locker := redisclient.NewRedisSlotLocker(rdb, cfg.LockTTL)
-	svc := appointment.NewService(repo, locker, cfg)
+	var routerCfg api.RouterConfig
+	if *demoMode {
+		var cleanup func()
+		routerCfg, cleanup = setupDemo(rootCtx, cfg, svcOpts...)
+		defer cleanup()
+	} else {
+		var cleanup func()
+		routerCfg, cleanup = setupProduction(rootCtx, cfg, version, requests, svcOpts...)
+		defer cleanup()
+	}
 
-	version := os.Getenv("APP_VERSION")
-	if version == "" {
-		version = "dev"
+	if cfg.AdminToken == "" {
+		log.Println("ADMIN_TOKEN not set, admin endpoints are disabled")
 	}
+	if blobs != nil {
+		routerCfg.Health = append(routerCfg.Health, api.BlobCheck(blobs))
+		log.Printf("blob storage: %s", cfg.BlobBackend)
 
-	router := api.NewRouter(api.RouterConfig{
-		Service: svc,
-		PgPool:  pgPool,
-		Redis:   rdb,
-		Env:     cfg.Env,
-		Version: version,
+		if cfg.AttachmentURLSecret == "" {
+			log.Println("ATTACHMENT_URL_SECRET not set, attachment endpoints are disabled")
+		} else {
+			routerCfg.Attachments = &api.AttachmentConfig{
+				URLSecret: cfg.AttachmentURLSecret,
+				URLTTL:    cfg.AttachmentURLTTL,
+				MaxBytes:  int64(cfg.AttachmentMaxBytes),
+			}
+		}
+	}
+	if cfg.ActionLinkSecret == "" {
+		log.Println("ACTION_LINK_SECRET not set, action link endpoints are disabled")
+	} else {
+		routerCfg.ActionLinks = &api.ActionLinkConfig{
+			Secret: cfg.ActionLinkSecret,
+			TTL:    cfg.ActionLinkTTL,
+		}
+	}
+	if cfg.TwilioAuthToken == "" || cfg.SMSWebhookURL == "" {
+		log.Println("TWILIO_AUTH_TOKEN or SMS_WEBHOOK_URL not set, the inbound SMS webhook is disabled")
+	} else {
+		routerCfg.SMS = &api.SMSConfig{
+			AuthToken:  cfg.TwilioAuthToken,
+			WebhookURL: cfg.SMSWebhookURL,
+		}
+	}
+	if routerCfg.Widget == nil {
+		log.Println("WIDGET_ENABLED not set, widget endpoints are disabled")
+	} else {
+		configureWidget(cfg, routerCfg.Widget)
+	}
+	routerCfg.Requests = requests
+	if routerCfg.Shedder != nil {
+		go routerCfg.Shedder.Run(rootCtx, shedSampleInterval)
+	}
+	routerCfg.AccessLog = api.NewAccessLogger(api.AccessLogConfig{
+		SampleRate: cfg.AccessLogSample,
+		RouteRates: cfg.AccessLogRouteSamples,
 	})
-
-	server := &http.Server{
-		Addr:              ":" + cfg.HTTPPort,
-		Handler:           router,
-		ReadHeaderTimeout: 5 * time.Second,
+	routerCfg.AdminToken = cfg.AdminToken
+	routerCfg.Env = cfg.Env
+	routerCfg.Version = version
+	if cfg.APICompatMode {
+		routerCfg.Encoder = api.CompatEncoder{}
+		log.Println("API compatibility mode: camelCase fields in a data/meta envelope")
 	}
 
+	router := api.NewRouter(routerCfg)
+
+	server := api.NewServer(":"+cfg.HTTPPort, router, api.ServerConfig{
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+		H2C:               cfg.HTTPH2C,
+	})
+
 	go func() {
-		log.Printf("HTTP server listening on :%s", cfg.HTTPPort)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("HTTP server listening on :%s (h2c=%t max_conns=%d)", cfg.HTTPPort, cfg.HTTPH2C, cfg.HTTPMaxConns)
+		if err := api.Serve(server, cfg.HTTPMaxConns); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("http server error: %v", err)
 		}
 	}()
@@ -100,3 +168,248 @@ func main() {
 
 	log.Println("shutting down api-server")
 }
+
+// setupProduction connects to Postgres and Redis and registers this instance
+// in the cluster registry. The returned cleanup deregisters and closes both.
+func setupProduction(ctx context.Context, cfg config.Config, version string, requests *api.RequestCounter, svcOpts ...appointment.Option) (api.RouterConfig, func()) {
+	// Connect Postgres
+	pgCtx, cancelPg := context.WithTimeout(ctx, 10*time.Second)
+	prepare := appointment.PgBookingStatements()
+	pgPool, err := db.ConnectPostgres(pgCtx, shard.Default, cfg.PostgresDSN, cfg.DBRequestTags, prepare...)
+	cancelPg()
+	if err != nil {
+		log.Fatalf("postgres connection error: %v", err)
+	}
+	log.Println("connected to Postgres")
+	shards, err := shard.Connect(ctx, pgPool, cfg.ShardDSNs, cfg.TenantShards, cfg.DBRequestTags, prepare...)
+	if err != nil {
+		log.Fatalf("shard connection error: %v", err)
+	}
+	if shards.Sharded() {
+		log.Printf("connected to shards %s", strings.Join(shards.Names(), ", "))
+	}
+	if cfg.SchemaCheck {
+		for _, name := range shards.Names() {
+			st, err := db.CheckSchema(ctx, shards.Named(name), cfg.SchemaCompatWindow)
+			if err != nil {
+				log.Fatalf("schema check failed on shard %s: %v", name, err)
+			}
+			log.Printf("shard %s schema at migration %d, binary ships %d, %d contract migration(s) pending",
+				name, st.Database, st.Binary, len(st.Pending))
+		}
+	}
+
+	// Connect Redis. Heartbeats get a pool of their own so lock traffic
+	// cannot starve them and drop the instance from the registry, and so do
+	// the widget's rate limits and the booking queues, which every anonymous
+	// request or booking attempt touches.
+	roles := []redisclient.Role{redisclient.RoleLocking, redisclient.RoleRegistry}
+	if cfg.WidgetEnabled || cfg.BookingQueueRate > 0 {
+		roles = append(roles, redisclient.RoleRateLimit)
+	}
+	redisCtx, cancelRedis := context.WithTimeout(ctx, 10*time.Second)
+	redisClients, err := redisclient.Connect(redisCtx, redisclient.Options{
+		Addr:      cfg.RedisAddr,
+		Username:  cfg.RedisUsername,
+		Password:  cfg.RedisPassword,
+		PoolSizes: cfg.RedisPoolSizes,
+	}, roles...)
+	cancelRedis()
+	if err != nil {
+		log.Fatalf("redis connection error: %v", err)
+	}
+	log.Println("connected to Redis")
+	go redisClients.Monitor(ctx, redisclient.MonitorInterval)
+	rdb := redisClients.Client(redisclient.RoleLocking)
+
+	// A missed heartbeat or two is tolerated before an instance drops out
+	registry := redisclient.NewInstanceRegistry(redisClients.Client(redisclient.RoleRegistry), 3*cfg.HeartbeatInterval)
+	self := newInstance(cfg, version)
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		runHeartbeat(heartbeatCtx, registry, self, cfg.HeartbeatInterval, requests)
+	}()
+	log.Printf("registered instance id=%s", self.ID)
+
+	// Booking data lives on the shard of the request's tenant; webhooks, jobs
+	// and region state stay on the default shard.
+	repo := appointment.NewPgRepository(shards)
+	locker := redisclient.NewRedisSlotLocker(rdb, cfg.LockTTL, cfg.LockWait)
+	if cfg.LockFair {
+		locker = redisclient.NewFairSlotLocker(rdb, cfg.LockTTL, cfg.LockWait)
+	}
+	heldLocks, _ := locker.(redisclient.HeldLockReleaser)
+
+	// Slot ownership only changes hands once every instance has seen the new
+	// member list, which takes up to one registry TTL.
+	var topology *cluster.Topology
+	if cfg.SlotRouting {
+		topology = cluster.NewTopology(registry.List, self.ID, 3*cfg.HeartbeatInterval)
+		go topology.Run(heartbeatCtx, cfg.HeartbeatInterval)
+		locker = cluster.NewOwnershipLocker(topology, locker)
+		log.Printf("slot routing enabled, advertising %s", self.Addr)
+	}
+
+	mtlsDests, err := outbound.ParseDestinations(cfg.OutboundMTLS)
+	if err != nil {
+		log.Fatalf("outbound mTLS config error: %v", err)
+	}
+	outboundClients, err := outbound.NewClientPool(mtlsDests, cfg.OutboundTimeout)
+	if err != nil {
+		log.Fatalf("outbound client error: %v", err)
+	}
+	webhooks := webhook.NewService(webhook.NewPgRepository(pgPool), outboundClients, cfg.WebhookSecretGrace)
+
+	// Webhook deliveries and push notifications are enqueued here and sent
+	// by the expiry-worker
+	queue := jobs.NewQueue(pgPool)
+	dispatcher := webhook.NewDispatcher(webhooks, queue)
+	svcOpts = append(svcOpts, appointment.WithEventPublisher(dispatcher))
+
+	pushProviders, err := push.Open(cfg, outboundClients)
+	if err != nil {
+		log.Fatalf("push config error: %v", err)
+	}
+	if len(pushProviders) > 0 {
+		svcOpts = append(svcOpts, appointment.WithEventPublisher(push.NewDispatcher(repo, queue, pushProviders)))
+	}
+
+	if cfg.BookingQueueRate > 0 {
+		bookingQueue := redisclient.NewRedisBookingQueue(redisClients.Client(redisclient.RoleRateLimit))
+		svcOpts = append(svcOpts, appointment.WithBookingQueue(bookingQueue))
+		log.Printf("booking queue admitting %g attempts/s per clinic, bursts of %d", cfg.BookingQueueRate, cfg.BookingQueueBurst)
+	}
+
+	svcOpts = append(svcOpts, appointment.WithShardTenancy(shards))
+	svc := appointment.NewService(repo, locker, cfg, svcOpts...)
+	bulkCancel := bulkcancel.NewService(bulkcancel.NewPgRepository(shards), svc, queue,
+		cfg.BulkCancelBatchSize, cfg.BulkCancelBatchPause)
+
+	regionCtl := region.NewController(pgPool, cfg.ReadOnly, cfg.MaxReplicationLag)
+	if cfg.ReadOnly {
+		// The database is a standby; reconciliation runs after promotion on
+		// the next restart.
+		log.Println("starting as passive region, writes are rejected")
+	} else {
+		releaser := redisclient.NewLockReleaser(rdb)
+		for _, name := range shards.Names() {
+			reconcileBookings(shard.WithShard(ctx, name), name, svc, releaser, cfg.LockTTL)
+		}
+	}
+
+	cleanup := func() {
+		stopHeartbeat()
+		<-heartbeatDone
+		deregCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if err := registry.Deregister(deregCtx, self.ID); err != nil {
+			log.Printf("error deregistering instance: %v", err)
+		}
+		cancel()
+
+		// Requests cut off by the shutdown timeout never released their
+		// locks; without this their slots stay locked for LOCK_TTL
+		if heldLocks != nil {
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			n, err := heldLocks.ReleaseHeld(releaseCtx)
+			cancel()
+			if n > 0 {
+				log.Printf("released %d lock(s) still held at shutdown", n)
+			}
+			if err != nil {
+				log.Printf("error releasing locks at shutdown: %v", err)
+			}
+		}
+
+		if err := redisClients.Close(); err != nil {
+			log.Printf("error closing redis: %v", err)
+		}
+		shards.Close()
+		pgPool.Close()
+	}
+
+	routerCfg := api.RouterConfig{
+		Service:  svc,
+		Webhooks: webhooks,
+		Health: []api.DependencyCheck{
+			api.PostgresCheck(pgPool),
+			api.RedisCheck(rdb),
+			api.ReplicationCheck(regionCtl),
+		},
+		Cluster:    registry,
+		Contention: redisclient.NewContentionReport(rdb),
+		Locks:      redisclient.NewLockInspector(rdb, cfg.LockTTL),
+		Region:     regionCtl,
+		BulkCancel: bulkCancel,
+	}
+	for _, name := range shards.Names()[1:] {
+		check := api.PostgresCheck(shards.Named(name))
+		check.Name = "postgres:" + name
+		routerCfg.Health = append(routerCfg.Health, check)
+	}
+	if topology != nil {
+		routerCfg.SlotRouter = topology
+	}
+	routerCfg.Consistency, routerCfg.ConsistencyWait = shards, cfg.ConsistencyWait
+	if cfg.WidgetEnabled {
+		routerCfg.Widget = &api.WidgetConfig{
+			Limiter: redisclient.NewRedisRateLimiter(redisClients.Client(redisclient.RoleRateLimit)),
+		}
+	}
+	pools := shards.Pools()
+	go db.NewPoolMonitor(pools, cfg.PoolWaitAlert).Run(ctx, db.PoolSampleInterval)
+	routerCfg.Shedder = newLoadShedder(cfg, api.PgxPoolWaits(slices.Collect(maps.Values(pools))...))
+
+	return routerCfg, cleanup
+}
+
+// shedSampleInterval is how often the load shedder reads its signals
+const shedSampleInterval = time.Second
+
+// attachmentOptions gives the service the blob store for attachments and,
+// when clamd is configured, a scanner for them
+func attachmentOptions(cfg config.Config, blobs blob.Store) []appointment.Option {
+	if blobs == nil {
+		return nil
+	}
+	opts := []appointment.Option{appointment.WithBlobStore(blobs)}
+	if cfg.AttachmentClamdAddr != "" {
+		opts = append(opts, appointment.WithScanner(clamav.New(cfg.AttachmentClamdAddr)))
+		log.Printf("attachments are scanned by clamd at %s", cfg.AttachmentClamdAddr)
+	} else {
+		log.Println("ATTACHMENT_CLAMD_ADDR not set, attachments are stored unscanned")
+	}
+	return opts
+}
+
+// configureWidget completes the widget config whose rate limiter the setup
+// chose. Bookings need a challenge to check, so without TURNSTILE_SECRET
+// the widget only searches.
+func configureWidget(cfg config.Config, w *api.WidgetConfig) {
+	w.Origins = cfg.WidgetOrigins
+	w.SearchLimit = cfg.WidgetSearchLimit
+	w.BookingLimit = cfg.WidgetBookingLimit
+	if len(w.Origins) == 0 {
+		log.Println("WIDGET_ORIGINS not set, browsers on other sites cannot call the widget API")
+	}
+	if cfg.TurnstileSecret == "" {
+		log.Println("TURNSTILE_SECRET not set, widget bookings are disabled")
+		return
+	}
+	w.Verifier = turnstile.New(cfg.TurnstileSecret, cfg.TurnstileVerifyURL)
+}
+
+// newLoadShedder builds a shedder for the configured limits, or returns nil
+// when both are turned off
+func newLoadShedder(cfg config.Config, waits api.PoolWaitStats) *api.LoadShedder {
+	if cfg.ShedMaxGoroutines <= 0 && cfg.ShedMaxPoolWait <= 0 {
+		log.Println("load shedding disabled")
+		return nil
+	}
+	return api.NewLoadShedder(api.LoadShedConfig{
+		MaxGoroutines: cfg.ShedMaxGoroutines,
+		MaxPoolWait:   cfg.ShedMaxPoolWait,
+		PoolWaits:     waits,
+	})
+}
@@ -0,0 +1,257 @@
+// Command bench benchmarks the service's hot paths against a backend, for a
+// baseline to compare performance changes against:
+//
+//	go run ./cmd/bench [-backend sqlite|postgres] [-bench regexp] [-benchtime 1s] [-count 1]
+//
+// The postgres backend reads POSTGRES_DSN and locks slots in Redis at
+// REDIS_ADDR, like an api-server; sqlite runs in memory with in-process
+// locks. Results are printed in the format of go test -bench, with
+// allocations, so benchstat can compare two runs. Allocations count the
+// whole process, including the database driver. Benchmarks write fixture
+// rows, so point it at a scratch database.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+// backend is a repository that can also set up fixtures
+type backend interface {
+	appointment.Repository
+	appointment.Seeder
+}
+
+// benchmark is one hot path. run sets up b.N iterations of it on svc, then
+// resets the timer and runs them; it returns an error instead of failing b.
+type benchmark struct {
+	name string
+	run  func(ctx context.Context, b *testing.B, repo backend, svc *appointment.Service) error
+}
+
+var benchmarks = []benchmark{
+	{"CreateAppointment", benchCreateAppointment},
+	{"ConfirmAppointment", benchConfirmAppointment},
+	{"AppointmentDetail", benchAppointmentDetail},
+}
+
+func main() {
+	log.SetFlags(0)
+
+	backendName := flag.String("backend", "sqlite", "backend to benchmark: sqlite or postgres")
+	sqlitePath := flag.String("sqlite-path", ":memory:", "SQLite database path")
+	pattern := flag.String("bench", ".", "run only benchmarks matching this regexp")
+	benchtime := flag.String("benchtime", "1s", "run each benchmark for this long, or Nx times")
+	count := flag.Int("count", 1, "run each benchmark this many times")
+	flag.Parse()
+
+	match, err := regexp.Compile(*pattern)
+	if err != nil {
+		log.Fatalf("invalid -bench: %v", err)
+	}
+	testing.Init()
+	if err := flag.Set("test.benchtime", *benchtime); err != nil {
+		log.Fatalf("invalid -benchtime: %v", err)
+	}
+
+	ctx := context.Background()
+	repo, svc, cleanup := connect(ctx, *backendName, *sqlitePath)
+	defer cleanup()
+
+	fmt.Printf("goos: %s\ngoarch: %s\nbackend: %s\n", runtime.GOOS, runtime.GOARCH, *backendName)
+	failed := false
+	for _, bm := range benchmarks {
+		if !match.MatchString(bm.name) {
+			continue
+		}
+		name := fmt.Sprintf("Benchmark%s-%d", bm.name, runtime.GOMAXPROCS(0))
+		for range *count {
+			var runErr error
+			res := testing.Benchmark(func(b *testing.B) {
+				b.ReportAllocs()
+				if err := bm.run(ctx, b, repo, svc); err != nil {
+					runErr = err
+					b.FailNow()
+				}
+			})
+			if runErr != nil {
+				fmt.Printf("--- FAIL: %s: %v\n", name, runErr)
+				failed = true
+				break
+			}
+			fmt.Printf("%s\t%s\t%s\n", name, res.String(), res.MemString())
+		}
+	}
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("PASS")
+}
+
+// connect opens the backend and a service on it as the api-server would
+func connect(ctx context.Context, name, sqlitePath string) (backend, *appointment.Service, func()) {
+	switch name {
+	case "sqlite":
+		cfg, err := config.LoadDemo()
+		if err != nil {
+			log.Fatalf("load config: %v", err)
+		}
+		sqlDB, err := db.OpenSQLite(ctx, sqlitePath)
+		if err != nil {
+			log.Fatalf("open sqlite: %v", err)
+		}
+		repo := appointment.NewSqliteRepository(sqlDB)
+		svc := appointment.NewService(repo, redisclient.NewInMemorySlotLocker(), cfg)
+		return repo, svc, func() { sqlDB.Close() }
+
+	case "postgres":
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatalf("load config: %v", err)
+		}
+		pool, err := db.ConnectPostgres(ctx, shard.Default, cfg.PostgresDSN, false)
+		if err != nil {
+			log.Fatalf("connect postgres: %v", err)
+		}
+		redisClients, err := redisclient.Connect(ctx, redisclient.Options{
+			Addr:     cfg.RedisAddr,
+			Username: cfg.RedisUsername,
+			Password: cfg.RedisPassword,
+		}, redisclient.RoleLocking)
+		if err != nil {
+			log.Fatalf("connect redis: %v", err)
+		}
+		locker := redisclient.NewRedisSlotLocker(redisClients.Client(redisclient.RoleLocking), cfg.LockTTL, cfg.LockWait)
+		repo := appointment.NewPgRepository(pool)
+		svc := appointment.NewService(repo, locker, cfg)
+		return repo, svc, func() {
+			redisClients.Close()
+			pool.Close()
+		}
+
+	default:
+		log.Fatalf("unknown backend %q", name)
+		return nil, nil, nil
+	}
+}
+
+// fixture is a clinician of a fresh clinic with n open half-hour slots,
+// back to back from tomorrow, and a patient to book them
+type fixture struct {
+	patient appointment.Patient
+	slots   []appointment.AppointmentSlot
+}
+
+func newFixture(ctx context.Context, repo backend, n int) (*fixture, error) {
+	clinic := appointment.Clinic{ID: uuid.New(), Name: "Benchmark Clinic"}
+	if err := repo.InsertClinic(ctx, clinic); err != nil {
+		return nil, fmt.Errorf("insert clinic: %w", err)
+	}
+	specialty := "General Practice"
+	clinician := appointment.Clinician{ID: uuid.New(), Name: "Dr. Benchmark", Specialty: &specialty, ClinicID: &clinic.ID}
+	if err := repo.InsertClinician(ctx, clinician); err != nil {
+		return nil, fmt.Errorf("insert clinician: %w", err)
+	}
+	email := "bench+" + uuid.NewString() + "@example.com"
+	f := &fixture{patient: appointment.Patient{ID: uuid.New(), Name: "Pat Benchmark", Email: &email}}
+	if err := repo.InsertPatient(ctx, f.patient); err != nil {
+		return nil, fmt.Errorf("insert patient: %w", err)
+	}
+
+	slotType := "consultation"
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour).UTC()
+	f.slots = make([]appointment.AppointmentSlot, n)
+	for i := range f.slots {
+		f.slots[i] = appointment.AppointmentSlot{
+			ID:             uuid.New(),
+			PractitionerID: clinician.ID,
+			StartTime:      start.Add(time.Duration(i) * 30 * time.Minute),
+			EndTime:        start.Add(time.Duration(i+1) * 30 * time.Minute),
+			Status:         appointment.SlotOpen,
+			Capacity:       1,
+			SlotType:       &slotType,
+		}
+		if err := repo.InsertSlot(ctx, f.slots[i]); err != nil {
+			return nil, fmt.Errorf("insert slot: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// benchCreateAppointment takes a hold on a slot of its own per iteration
+func benchCreateAppointment(ctx context.Context, b *testing.B, repo backend, svc *appointment.Service) error {
+	f, err := newFixture(ctx, repo, b.N)
+	if err != nil {
+		return err
+	}
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := svc.CreateAppointment(ctx, f.slots[i].ID, f.patient.ID); err != nil {
+			return fmt.Errorf("CreateAppointment: %w", err)
+		}
+	}
+	return nil
+}
+
+// benchConfirmAppointment confirms a hold taken before the timer started
+// per iteration
+func benchConfirmAppointment(ctx context.Context, b *testing.B, repo backend, svc *appointment.Service) error {
+	f, err := newFixture(ctx, repo, b.N)
+	if err != nil {
+		return err
+	}
+	holds := make([]uuid.UUID, b.N)
+	for i := range b.N {
+		appt, err := svc.CreateAppointment(ctx, f.slots[i].ID, f.patient.ID)
+		if err != nil {
+			return fmt.Errorf("CreateAppointment: %w", err)
+		}
+		holds[i] = appt.ID
+	}
+	b.ResetTimer()
+	for _, id := range holds {
+		if _, err := svc.ConfirmAppointment(ctx, id); err != nil {
+			return fmt.Errorf("ConfirmAppointment: %w", err)
+		}
+	}
+	return nil
+}
+
+// benchAppointmentDetail reads one confirmed appointment with its slot,
+// patient and clinician joined, as GET /appointments/{id} does
+func benchAppointmentDetail(ctx context.Context, b *testing.B, repo backend, svc *appointment.Service) error {
+	f, err := newFixture(ctx, repo, 1)
+	if err != nil {
+		return err
+	}
+	appt, err := svc.CreateAppointment(ctx, f.slots[0].ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, appt.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		if _, err := svc.GetAppointment(ctx, appt.ID, appointment.AllDetailFields); err != nil {
+			return fmt.Errorf("GetAppointment: %w", err)
+		}
+	}
+	return nil
+}
@@ -2,81 +2,342 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
-	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/blob"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/bulkcancel"
 	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/jobs"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/outbound"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/push"
 	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/webhook"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/worker"
 )
 
 func main() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	log.Println("expiry-worker starting up")
+	worker.Main("expiry-worker", func(rt *worker.Runtime) error {
+		mtlsDests, err := outbound.ParseDestinations(rt.Config.OutboundMTLS)
+		if err != nil {
+			return err
+		}
+		outboundClients, err := outbound.NewClientPool(mtlsDests, rt.Config.OutboundTimeout)
+		if err != nil {
+			return err
+		}
+		webhooks := webhook.NewService(webhook.NewPgRepository(rt.Postgres), outboundClients, rt.Config.WebhookSecretGrace)
+		queue := jobs.NewQueue(rt.Postgres)
+		dispatcher := webhook.NewDispatcher(webhooks, queue)
 
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("config load error: %v", err)
-	}
+		repo := appointment.NewPgRepository(rt.Shards)
+		locker := redisclient.NewRedisSlotLocker(rt.Redis, rt.Config.LockTTL, rt.Config.LockWait)
+		if rt.Config.LockFair {
+			locker = redisclient.NewFairSlotLocker(rt.Redis, rt.Config.LockTTL, rt.Config.LockWait)
+		}
+		opts := []appointment.Option{appointment.WithEventPublisher(dispatcher)}
+		// Reminders and feedback requests are sent from here
+		pushProviders, err := push.Open(rt.Config, outboundClients)
+		if err != nil {
+			return err
+		}
+		var pushDispatcher *push.Dispatcher
+		if len(pushProviders) > 0 {
+			pushDispatcher = push.NewDispatcher(repo, queue, pushProviders)
+			opts = append(opts, appointment.WithEventPublisher(pushDispatcher))
+		}
+		// Retention deletes the content of expired appointments' attachments
+		blobs, err := blob.Open(rt.Config.Blob())
+		if err != nil {
+			return err
+		}
+		if blobs != nil {
+			opts = append(opts, appointment.WithBlobStore(blobs))
+		}
+		// Retention only applies the policies of tenants with a shard of
+		// their own
+		opts = append(opts, appointment.WithShardTenancy(rt.Shards))
+		svc := appointment.NewService(repo, locker, rt.Config, opts...)
+
+		rt.Register(worker.Job{
+			Name:     "expire-pending",
+			Interval: rt.Config.WorkerInterval,
+			Run: func(ctx context.Context, stop <-chan struct{}) error {
+				return expirePending(ctx, stop, rt, svc)
+			},
+		})
+
+		rt.Register(worker.Job{
+			Name:     "reject-overdue-approvals",
+			Interval: rt.Config.WorkerInterval,
+			Run: func(ctx context.Context, stop <-chan struct{}) error {
+				return rejectOverdueApprovals(ctx, stop, rt, svc)
+			},
+		})
+
+		rt.Register(worker.Job{
+			Name:     "reconcile-orphans",
+			Interval: 15 * time.Minute,
+			Timeout:  5 * time.Minute,
+			Run: func(ctx context.Context, stop <-chan struct{}) error {
+				return reconcileOrphans(ctx, stop, rt, svc)
+			},
+		})
+
+		rt.Register(worker.Job{
+			Name:     "remind-intake",
+			Interval: 15 * time.Minute,
+			Run: func(ctx context.Context, stop <-chan struct{}) error {
+				return remindIntake(ctx, stop, rt, svc)
+			},
+		})
+
+		rt.Register(worker.Job{
+			Name:     "request-feedback",
+			Interval: 15 * time.Minute,
+			Run: func(ctx context.Context, stop <-chan struct{}) error {
+				return requestFeedback(ctx, stop, rt, svc)
+			},
+		})
+
+		rt.Register(worker.Job{
+			Name:     "apply-retention",
+			Interval: 24 * time.Hour,
+			Timeout:  time.Hour,
+			Run: func(ctx context.Context, stop <-chan struct{}) error {
+				return applyRetention(ctx, stop, rt, svc)
+			},
+		})
 
-	log.Printf("running expiry worker in env=%s interval=%s", cfg.Env, cfg.WorkerInterval)
+		deliveries := jobs.Consumer{
+			Queue:   queue,
+			Name:    webhook.DeliveryQueue,
+			Handler: dispatcher.Handle,
+			// Long enough for one batch of deliveries at the outbound timeout
+			Visibility: 10 * rt.Config.OutboundTimeout,
+		}
+		rt.Register(worker.Job{
+			Name:     "webhook-delivery",
+			Interval: 5 * time.Second,
+			Timeout:  deliveries.Visibility,
+			Run:      deliveries.Drain,
+		})
+
+		if pushDispatcher != nil {
+			notifications := jobs.Consumer{
+				Queue:      queue,
+				Name:       push.Queue,
+				Handler:    pushDispatcher.Handle,
+				Visibility: 10 * rt.Config.OutboundTimeout,
+			}
+			rt.Register(worker.Job{
+				Name:     "push-delivery",
+				Interval: 5 * time.Second,
+				Timeout:  notifications.Visibility,
+				Run:      notifications.Drain,
+			})
+		}
 
-	rootCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+		bulkCancel := bulkcancel.NewService(bulkcancel.NewPgRepository(rt.Shards), svc, queue,
+			rt.Config.BulkCancelBatchSize, rt.Config.BulkCancelBatchPause)
+		closures := jobs.Consumer{
+			Queue:   queue,
+			Name:    bulkcancel.Queue,
+			Handler: bulkCancel.Handle,
+			Batch:   1,
+			// A run is retried from where it stopped, so this only bounds how
+			// long a run of a crashed worker waits to be picked up again
+			Visibility: 30 * time.Minute,
+		}
+		rt.Register(worker.Job{
+			Name:     "bulk-cancel",
+			Interval: 5 * time.Second,
+			Timeout:  30 * time.Minute,
+			Run:      closures.Drain,
+		})
+		return nil
+	})
+}
 
-	// Connect Postgres
-	pgCtx, cancelPg := context.WithTimeout(rootCtx, 10*time.Second)
-	pgPool, err := db.ConnectPostgres(pgCtx, cfg.PostgresDSN)
-	cancelPg()
+// expirePending expires stale holds on every shard in turn
+func expirePending(ctx context.Context, stop <-chan struct{}, rt *worker.Runtime, svc *appointment.Service) error {
+	var errs []error
+	for _, name := range rt.Shards.Names() {
+		if err := expireShard(shard.WithShard(ctx, name), stop, rt, svc, name); err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func expireShard(ctx context.Context, stop <-chan struct{}, rt *worker.Runtime, svc *appointment.Service, name string) error {
+	// In a passive region the database is a read-only standby. Expiry resumes
+	// on its own once the standby is promoted.
+	inRecovery, _, err := db.ReplicationStatus(ctx, rt.Shards.Named(name))
 	if err != nil {
-		log.Fatalf("postgres connection error: %v", err)
+		return err
+	}
+	if inRecovery {
+		log.Printf("shard %s is a standby, skipping expiry run", name)
+		return nil
 	}
-	defer pgPool.Close()
-	log.Println("connected to Postgres")
 
-	rdb, err := redisclient.NewRedisClient(cfg.RedisAddr, cfg.RedisUsername, cfg.RedisPassword)
+	res, err := svc.ExpirePendingAppointments(ctx, stop)
 	if err != nil {
-		log.Fatalf("redis connection error: %v", err)
+		return err
 	}
-	defer func() {
-		if err := rdb.Close(); err != nil {
-			log.Printf("error closing redis: %v", err)
+	if res.Stopped {
+		log.Printf("expiry run stopped for shutdown on shard %s: expired=%d remaining=%d (left for the next run)",
+			name, res.Expired, res.Remaining)
+		return nil
+	}
+	log.Printf("expiry run complete on shard %s: expired=%d", name, res.Expired)
+	return nil
+}
+
+// rejectOverdueApprovals rejects bookings nobody approved in time on every
+// shard in turn
+func rejectOverdueApprovals(ctx context.Context, stop <-chan struct{}, rt *worker.Runtime, svc *appointment.Service) error {
+	var errs []error
+	for _, name := range rt.Shards.Names() {
+		ctx := shard.WithShard(ctx, name)
+		inRecovery, _, err := db.ReplicationStatus(ctx, rt.Shards.Named(name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+			continue
+		}
+		if inRecovery {
+			continue
 		}
-	}()
-	log.Println("connected to Redis")
 
-	repo := appointment.NewPgRepository(pgPool)
-	locker := redisclient.NewRedisSlotLocker(rdb, cfg.LockTTL)
-	svc := appointment.NewService(repo, locker, cfg)
+		rejected, err := svc.RejectOverdueApprovals(ctx, stop)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+			continue
+		}
+		if rejected > 0 {
+			log.Printf("rejected %d overdue approvals on shard %s", rejected, name)
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	// Run once at startup
-	runOnce(rootCtx, svc)
+// remindIntake asks for reminders of incomplete intake forms on every shard
+// in turn
+func remindIntake(ctx context.Context, stop <-chan struct{}, rt *worker.Runtime, svc *appointment.Service) error {
+	var errs []error
+	for _, name := range rt.Shards.Names() {
+		ctx := shard.WithShard(ctx, name)
+		inRecovery, _, err := db.ReplicationStatus(ctx, rt.Shards.Named(name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+			continue
+		}
+		if inRecovery {
+			continue
+		}
 
-	ticker := time.NewTicker(cfg.WorkerInterval)
-	defer ticker.Stop()
+		sent, err := svc.SendIntakeReminders(ctx, stop)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+			continue
+		}
+		if sent > 0 {
+			log.Printf("sent %d intake reminders on shard %s", sent, name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// requestFeedback asks for feedback on appointments that have ended on
+// every shard in turn
+func requestFeedback(ctx context.Context, stop <-chan struct{}, rt *worker.Runtime, svc *appointment.Service) error {
+	var errs []error
+	for _, name := range rt.Shards.Names() {
+		ctx := shard.WithShard(ctx, name)
+		inRecovery, _, err := db.ReplicationStatus(ctx, rt.Shards.Named(name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+			continue
+		}
+		if inRecovery {
+			continue
+		}
 
-	for {
-		select {
-		case <-rootCtx.Done():
-			log.Println("shutdown signal received, stopping expiry worker")
-			return
-		case <-ticker.C:
-			runOnce(rootCtx, svc)
+		sent, err := svc.SendFeedbackRequests(ctx, stop)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+			continue
+		}
+		if sent > 0 {
+			log.Printf("sent %d feedback requests on shard %s", sent, name)
 		}
 	}
+	return errors.Join(errs...)
 }
 
-func runOnce(ctx context.Context, svc *appointment.Service) {
-	runCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
-	defer cancel()
+// applyRetention enforces the retention policy of every shard in turn,
+// only counting what it would delete when cfg.RetentionDryRun is set
+func applyRetention(ctx context.Context, stop <-chan struct{}, rt *worker.Runtime, svc *appointment.Service) error {
+	var errs []error
+	for _, name := range rt.Shards.Names() {
+		ctx := shard.WithShard(ctx, name)
+		inRecovery, _, err := db.ReplicationStatus(ctx, rt.Shards.Named(name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+			continue
+		}
+		if inRecovery {
+			continue
+		}
 
-	start := time.Now()
-	if err := svc.ExpirePendingAppointments(runCtx); err != nil {
-		log.Printf("expiry run error: %v", err)
-		return
+		runs, err := svc.ApplyRetention(ctx, stop, rt.Config.RetentionDryRun)
+		for _, run := range runs {
+			log.Printf("retention on shard %s: %s older than %d months, matched=%d deleted=%d dry_run=%t",
+				name, run.Entity, run.RetainMonths, run.Matched, run.Deleted, run.DryRun)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reconcileOrphans reports, and optionally repairs, orphans on every shard
+func reconcileOrphans(ctx context.Context, stop <-chan struct{}, rt *worker.Runtime, svc *appointment.Service) error {
+	var errs []error
+	for _, name := range rt.Shards.Names() {
+		if err := reconcileShardOrphans(shard.WithShard(ctx, name), stop, rt, svc, name); err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func reconcileShardOrphans(ctx context.Context, stop <-chan struct{}, rt *worker.Runtime, svc *appointment.Service, name string) error {
+	// A standby can still be reported on, but not repaired
+	repair := rt.Config.OrphanRepair
+	if repair {
+		inRecovery, _, err := db.ReplicationStatus(ctx, rt.Shards.Named(name))
+		if err != nil {
+			return err
+		}
+		if inRecovery {
+			log.Printf("shard %s is a standby, reporting orphans without repair", name)
+			repair = false
+		}
+	}
+
+	res, err := svc.ReconcileOrphanedAppointments(ctx, stop, repair)
+	if err != nil {
+		return err
 	}
-	log.Printf("expiry run complete in %s", time.Since(start))
+	log.Printf("orphan reconciliation complete on shard %s: stale_pending=%d deleted_slot=%d blocked_slot=%d repaired=%d stopped=%t",
+		name, res.Found[appointment.OrphanStalePending], res.Found[appointment.OrphanDeletedSlot],
+		res.Found[appointment.OrphanBlockedSlot], res.Repaired, res.Stopped)
+	return nil
 }
@@ -0,0 +1,160 @@
+// Command region-ctl inspects and promotes a passive region. It reads the
+// same environment as the api-server, finds the instances through the Redis
+// instance registry and calls their admin endpoints with ADMIN_TOKEN.
+//
+//	region-ctl status
+//	region-ctl promote
+//
+// promote asks the first instance to promote the Postgres standby and then
+// enables writes on every other instance.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	timeout := flag.Duration("timeout", 90*time.Second, "overall timeout, promotion can take up to a minute")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: region-ctl [-timeout 90s] status|promote")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config load error: %v", err)
+	}
+	if cfg.AdminToken == "" {
+		log.Fatal("ADMIN_TOKEN is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	redisClients, err := redisclient.Connect(ctx, redisclient.Options{
+		Addr:      cfg.RedisAddr,
+		Username:  cfg.RedisUsername,
+		Password:  cfg.RedisPassword,
+		PoolSizes: cfg.RedisPoolSizes,
+	}, redisclient.RoleRegistry)
+	if err != nil {
+		log.Fatalf("redis connection error: %v", err)
+	}
+	defer redisClients.Close()
+
+	instances, err := redisclient.NewInstanceRegistry(redisClients.Client(redisclient.RoleRegistry), 0).List(ctx)
+	if err != nil {
+		log.Fatalf("list instances: %v", err)
+	}
+	if len(instances) == 0 {
+		log.Fatal("no api-server instances registered")
+	}
+
+	c := &adminClient{token: cfg.AdminToken, http: &http.Client{}}
+
+	switch flag.Arg(0) {
+	case "status":
+		for _, inst := range instances {
+			body, err := c.do(ctx, http.MethodGet, inst.Addr+"/admin/region", nil)
+			if err != nil {
+				fmt.Printf("%s  %s  error: %v\n", inst.ID, inst.Addr, err)
+				continue
+			}
+			fmt.Printf("%s  %s  %s\n", inst.ID, inst.Addr, body)
+		}
+
+	case "promote":
+		failed := false
+		for i, inst := range instances {
+			req := map[string]bool{"promote_database": i == 0}
+			body, err := c.do(ctx, http.MethodPost, inst.Addr+"/admin/region/promote", req)
+			if errors.Is(err, errConflict) && strings.Contains(body, "already_active") {
+				fmt.Printf("%s  %s  already active\n", inst.ID, inst.Addr)
+				continue
+			}
+			if err != nil {
+				failed = true
+				fmt.Printf("%s  %s  promote failed: %v\n", inst.ID, inst.Addr, err)
+				if i == 0 {
+					log.Fatal("database promotion failed, other instances left read-only")
+				}
+				continue
+			}
+			fmt.Printf("%s  %s  promoted: %s\n", inst.ID, inst.Addr, body)
+		}
+		if failed {
+			os.Exit(1)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+var errConflict = errors.New("409 Conflict")
+
+type adminClient struct {
+	token string
+	http  *http.Client
+}
+
+func (c *adminClient) do(ctx context.Context, method, url string, payload any) (string, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return "", err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(data))
+	if resp.StatusCode == http.StatusConflict {
+		return text, fmt.Errorf("%w: %s", errConflict, text)
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("%s: %s", resp.Status, text)
+	}
+	return text, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment/conformance"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+// repo-conformance runs the repository conformance suite against a backend.
+// It writes fixture rows, so point it at a scratch database.
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	backend := flag.String("backend", "sqlite", "backend to check: sqlite or postgres")
+	sqlitePath := flag.String("sqlite-path", ":memory:", "SQLite database path")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	var b conformance.Backend
+
+	switch *backend {
+	case "sqlite":
+		sqlDB, err := db.OpenSQLite(ctx, *sqlitePath)
+		if err != nil {
+			log.Fatalf("open sqlite: %v", err)
+		}
+		defer sqlDB.Close()
+		b = appointment.NewSqliteRepository(sqlDB)
+	case "postgres":
+		dsn := os.Getenv("POSTGRES_DSN")
+		if dsn == "" {
+			log.Fatal("POSTGRES_DSN is required for the postgres backend")
+		}
+		pool, err := db.ConnectPostgres(ctx, shard.Default, dsn, false)
+		if err != nil {
+			log.Fatalf("connect postgres: %v", err)
+		}
+		defer pool.Close()
+		b = appointment.NewPgRepository(pool)
+	default:
+		log.Fatalf("unknown backend %q", *backend)
+	}
+
+	failed := 0
+	for _, res := range conformance.Run(ctx, b) {
+		if res.Err != nil {
+			failed++
+			fmt.Printf("FAIL  %s: %v\n", res.Name, res.Err)
+			continue
+		}
+		fmt.Printf("ok    %s\n", res.Name)
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n%d case(s) failed on %s\n", failed, *backend)
+		os.Exit(1)
+	}
+	fmt.Printf("\nall cases passed on %s\n", *backend)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+const (
+	slotLength = 30 * time.Minute
+	// holdWindow is how long a generated booking was held before it was
+	// confirmed or expired
+	holdWindow = 10 * time.Minute
+	// maxLead bounds how far ahead of its slot a booking was made; slots
+	// are created that far ahead
+	maxLead = 21 * 24 * time.Hour
+)
+
+var slotTypes = []string{"consultation", "follow_up"}
+
+// historyCounts is what seedHistory wrote
+type historyCounts struct {
+	slots    int
+	bookings int
+}
+
+// seedHistory gives each clinician slots over [from, to) and books them
+// for random patients, both following p. Slots before now are booked with
+// chance p.Booked and later ones with p.Upcoming, scaled by the slot's
+// weight, so busy hours are both denser and fuller. Every booking gets the
+// events the booking flow would have written, so cmd/verify passes. Each
+// clinician is written in one transaction.
+func seedHistory(ctx context.Context, pool *pgxpool.Pool, rng *rand.Rand, p *profile, clinicians, patients []uuid.UUID, from, to, now time.Time) (historyCounts, error) {
+	log.Printf("seeding slots for %d clinicians from %s to %s with the %s profile",
+		len(clinicians), from.Format(time.DateOnly), to.Format(time.DateOnly), p.Name)
+
+	var total historyCounts
+	for i, clinicianID := range clinicians {
+		batch := &pgx.Batch{}
+		counts := queueClinicianHistory(batch, rng, p, clinicianID, patients, from, to, now)
+
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return total, err
+		}
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			_ = tx.Rollback(ctx)
+			return total, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return total, err
+		}
+
+		total.slots += counts.slots
+		total.bookings += counts.bookings
+		if (i+1)%10 == 0 || i+1 == len(clinicians) {
+			log.Printf("clinicians with history: %d/%d (%d slots, %d bookings)", i+1, len(clinicians), total.slots, total.bookings)
+		}
+	}
+	return total, nil
+}
+
+// queueClinicianHistory queues the clinician's slots and bookings on batch
+func queueClinicianHistory(batch *pgx.Batch, rng *rand.Rand, p *profile, clinicianID uuid.UUID, patients []uuid.UUID, from, to, now time.Time) historyCounts {
+	var counts historyCounts
+	for start := from; start.Before(to); start = start.Add(slotLength) {
+		w := p.weight(start)
+		if w == 0 || rng.Float64() >= p.Density*w {
+			continue
+		}
+
+		slotID := uuid.New()
+		// Before any booking of it, even for a slot well in the future
+		createdAt := start.Add(-maxLead)
+		if start.After(now) {
+			createdAt = now.Add(-maxLead)
+		}
+		batch.Queue(`
+			INSERT INTO appointment_slots (id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, 'open', 1, $5, $6, $6)
+		`, slotID, clinicianID, start, start.Add(slotLength), slotTypes[rng.Intn(len(slotTypes))], createdAt)
+		counts.slots++
+
+		chance := p.Booked
+		if !start.Before(now) {
+			chance = p.Upcoming
+		}
+		if len(patients) == 0 || rng.Float64() >= chance*w {
+			continue
+		}
+		queueBooking(batch, rng, p, slotID, patients[rng.Intn(len(patients))], start, now)
+		counts.bookings++
+	}
+	return counts
+}
+
+// queueBooking books the slot starting at start for the patient at least a
+// hold window before it and settles the booking: a past one as confirmed,
+// cancelled or expired by the profile's shares, an upcoming one as
+// confirmed
+func queueBooking(batch *pgx.Batch, rng *rand.Rand, p *profile, slotID, patientID uuid.UUID, start, now time.Time) {
+	createdAt := start.Add(-holdWindow - time.Duration(rng.Int63n(int64(maxLead-holdWindow))))
+	if latest := now.Add(-holdWindow); createdAt.After(latest) {
+		createdAt = latest
+	}
+	expiresAt := createdAt.Add(holdWindow)
+
+	status, event, settledAt := appointment.StatusConfirmed, appointment.EventAppointmentConfirmed, createdAt.Add(time.Duration(rng.Int63n(int64(holdWindow))))
+	if start.Before(now) {
+		switch r := rng.Float64(); {
+		case r < p.Cancelled:
+			status, event = appointment.StatusCancelled, appointment.EventAppointmentCancelled
+			settledAt = createdAt.Add(time.Duration(rng.Int63n(int64(start.Sub(createdAt)))))
+		case r < p.Cancelled+p.Expired:
+			status, event, settledAt = appointment.StatusExpired, appointment.EventAppointmentExpired, expiresAt.Add(time.Minute)
+		}
+	}
+
+	appointmentID := uuid.New()
+	batch.Queue(`
+		INSERT INTO appointments (id, slot_id, patient_id, status, created_at, updated_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, appointmentID, slotID, patientID, string(status), createdAt, settledAt, expiresAt)
+
+	payload, _ := json.Marshal(map[string]any{
+		"slot_id":    slotID.String(),
+		"patient_id": patientID.String(),
+		"expires_at": expiresAt,
+	})
+	batch.Queue(`
+		INSERT INTO event_logs (event_type, appointment_id, payload, created_at)
+		VALUES ($1, $2, $3, $4), ($5, $2, $3, $6)
+	`, appointment.EventAppointmentCreated, appointmentID, payload, createdAt, event, settledAt)
+}
@@ -1,8 +1,20 @@
+// Command seed fills a Postgres database with clinicians and patients, and
+// with -profile with slots and bookings for them spread over time the way
+// the profile says, so performance tests see production's selectivity.
+//
+//	seed
+//	seed -profile outpatient -days-back 180 -days-ahead 60 -rand-seed 42
+//	seed -profile my-profile.json
+//
+// The same -rand-seed generates the same names and the same calendar,
+// relative to today, under new ids.
 package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"math/rand"
 	"os"
 	"time"
 
@@ -11,11 +23,33 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
 )
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	log.Println("seed starting")
+
+	clinicianCount := flag.Int("clinicians", 100, "clinicians to create")
+	patientCount := flag.Int("patients", 9000, "patients to create")
+	profileName := flag.String("profile", "", "distribution profile for slots and bookings, outpatient, flat or a .json file; none are created when empty")
+	daysBack := flag.Int("days-back", 180, "days of booking history before today")
+	daysAhead := flag.Int("days-ahead", 60, "days of slots after today")
+	randSeed := flag.Int64("rand-seed", 0, "seed for generated data, the current time when 0")
+	flag.Parse()
+
+	var prof *profile
+	if *profileName != "" {
+		p, err := loadProfile(*profileName)
+		if err != nil {
+			log.Fatalf("load profile: %v", err)
+		}
+		prof = p
+	}
+	if *randSeed == 0 {
+		*randSeed = time.Now().UnixNano()
+	}
+
+	log.Printf("seed starting, rand seed %d", *randSeed)
 
 	dsn := os.Getenv("POSTGRES_DSN")
 	if dsn == "" {
@@ -25,25 +59,40 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	pool, err := db.ConnectPostgres(ctx, dsn)
+	pool, err := db.ConnectPostgres(ctx, shard.Default, dsn, false)
 	if err != nil {
 		log.Fatalf("connect postgres: %v", err)
 	}
 	defer pool.Close()
 
-	gofakeit.Seed(time.Now().UnixNano())
+	gofakeit.Seed(*randSeed)
+	rng := rand.New(rand.NewSource(*randSeed))
 
-	if err := seedClinicians(context.Background(), pool, 100); err != nil {
+	clinicians, err := seedClinicians(context.Background(), pool, *clinicianCount)
+	if err != nil {
 		log.Fatalf("seed clinicians: %v", err)
 	}
-	if err := seedPatients(context.Background(), pool, 9000); err != nil {
+	patients, err := seedPatients(context.Background(), pool, *patientCount)
+	if err != nil {
 		log.Fatalf("seed patients: %v", err)
 	}
 
+	if prof != nil {
+		// Whole days in UTC, so hour weights line up with slot starts
+		now := time.Now().UTC()
+		today := now.Truncate(24 * time.Hour)
+		from, to := today.AddDate(0, 0, -*daysBack), today.AddDate(0, 0, *daysAhead+1)
+		counts, err := seedHistory(context.Background(), pool, rng, prof, clinicians, patients, from, to, now)
+		if err != nil {
+			log.Fatalf("seed history: %v", err)
+		}
+		log.Printf("history seeded: %d slots, %d bookings", counts.slots, counts.bookings)
+	}
+
 	log.Println("seed complete")
 }
 
-func seedClinicians(ctx context.Context, pool *pgxpool.Pool, count int) error {
+func seedClinicians(ctx context.Context, pool *pgxpool.Pool, count int) ([]uuid.UUID, error) {
 	log.Printf("seeding %d clinicians", count)
 
 	specialties := []string{
@@ -61,10 +110,11 @@ func seedClinicians(ctx context.Context, pool *pgxpool.Pool, count int) error {
 
 	tx, err := pool.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback(ctx)
 
+	ids := make([]uuid.UUID, 0, count)
 	for i := 0; i < count; i++ {
 		id := uuid.New()
 		name := gofakeit.Name()
@@ -75,23 +125,25 @@ func seedClinicians(ctx context.Context, pool *pgxpool.Pool, count int) error {
 			VALUES ($1, $2, $3, now(), now())
 		`, id, name, spec)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		ids = append(ids, id)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		return err
+		return nil, err
 	}
 
 	log.Println("clinicians seeded")
-	return nil
+	return ids, nil
 }
 
-func seedPatients(ctx context.Context, pool *pgxpool.Pool, count int) error {
+func seedPatients(ctx context.Context, pool *pgxpool.Pool, count int) ([]uuid.UUID, error) {
 	log.Printf("seeding %d patients", count)
 
 	const batchSize = 500
 
+	ids := make([]uuid.UUID, 0, count)
 	for offset := 0; offset < count; offset += batchSize {
 		end := offset + batchSize
 		if end > count {
@@ -100,7 +152,7 @@ func seedPatients(ctx context.Context, pool *pgxpool.Pool, count int) error {
 
 		tx, err := pool.Begin(ctx)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		for i := offset; i < end; i++ {
@@ -114,17 +166,18 @@ func seedPatients(ctx context.Context, pool *pgxpool.Pool, count int) error {
 			`, id, name, email)
 			if err != nil {
 				_ = tx.Rollback(ctx)
-				return err
+				return nil, err
 			}
+			ids = append(ids, id)
 		}
 
 		if err := tx.Commit(ctx); err != nil {
-			return err
+			return nil, err
 		}
 
 		log.Printf("patients seeded: %d/%d", end, count)
 	}
 
 	log.Println("patients seeded")
-	return nil
+	return ids, nil
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// profile shapes when slots exist and how full they are, so generated
+// history has the selectivity of production. Weights are relative within
+// each list; a slot's weight is the product of its weekday, hour and month
+// weights divided by the largest such product, so the busiest half hour of
+// the year weighs 1 and an hour weighted 0 never gets a slot.
+type profile struct {
+	Name string `json:"name"`
+
+	// Weekdays weights the days of the week, Sunday first
+	Weekdays [7]float64 `json:"weekdays"`
+	// Hours weights the two half-hour slots of each hour of the day, in UTC
+	Hours [24]float64 `json:"hours"`
+	// Months weights January to December, for seasonal variation
+	Months [12]float64 `json:"months"`
+
+	// Density is the chance a half hour of weight 1 has a slot
+	Density float64 `json:"density"`
+	// Booked is the chance a past slot of weight 1 was booked, and
+	// Upcoming that a future one already is
+	Booked   float64 `json:"booked"`
+	Upcoming float64 `json:"upcoming"`
+	// Cancelled and Expired are the shares of past bookings that ended
+	// that way; the rest were confirmed
+	Cancelled float64 `json:"cancelled"`
+	Expired   float64 `json:"expired"`
+}
+
+// profiles are the built-in profiles by name
+var profiles = map[string]profile{
+	// A weekday outpatient clinic: busiest on Mondays and mid-morning, a
+	// lunch trough, quiet Saturdays, busy winters and a summer lull
+	"outpatient": {
+		Name:     "outpatient",
+		Weekdays: [7]float64{0, 1, 0.95, 0.9, 0.9, 0.75, 0.2},
+		Hours: [24]float64{
+			8: 0.6, 9: 1, 10: 1, 11: 0.9,
+			12: 0.3, 13: 0.5,
+			14: 0.8, 15: 0.8, 16: 0.7, 17: 0.4,
+		},
+		Months:    [12]float64{1, 0.95, 0.9, 0.85, 0.8, 0.75, 0.6, 0.55, 0.85, 0.9, 0.95, 0.7},
+		Density:   0.9,
+		Booked:    0.85,
+		Upcoming:  0.5,
+		Cancelled: 0.1,
+		Expired:   0.05,
+	},
+	// Every half hour from 08:00 to 18:00 on every day alike, for
+	// comparing against a workload without skew
+	"flat": {
+		Name:     "flat",
+		Weekdays: [7]float64{1, 1, 1, 1, 1, 1, 1},
+		Hours: [24]float64{
+			8: 1, 9: 1, 10: 1, 11: 1, 12: 1, 13: 1, 14: 1, 15: 1, 16: 1, 17: 1,
+		},
+		Months:    [12]float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+		Density:   0.5,
+		Booked:    0.5,
+		Upcoming:  0.25,
+		Cancelled: 0.1,
+		Expired:   0.05,
+	},
+}
+
+// loadProfile returns the built-in profile called name, or reads one from
+// the JSON file at name
+func loadProfile(name string) (*profile, error) {
+	if p, ok := profiles[name]; ok {
+		p.normalize()
+		return &p, nil
+	}
+	if !strings.HasSuffix(name, ".json") {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return nil, fmt.Errorf("unknown profile %q, want one of %s or a .json file", name, strings.Join(names, ", "))
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var p profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse profile %s: %w", name, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	p.normalize()
+	return &p, nil
+}
+
+func (p *profile) validate() error {
+	for _, chance := range []float64{p.Density, p.Booked, p.Upcoming, p.Cancelled, p.Expired} {
+		if chance < 0 || chance > 1 {
+			return fmt.Errorf("chances must be between 0 and 1")
+		}
+	}
+	if p.Cancelled+p.Expired > 1 {
+		return fmt.Errorf("cancelled and expired shares add up to more than 1")
+	}
+	for _, weights := range [][]float64{p.Weekdays[:], p.Hours[:], p.Months[:]} {
+		if slices.Min(weights) < 0 {
+			return fmt.Errorf("weights must not be negative")
+		}
+		if slices.Max(weights) == 0 {
+			return fmt.Errorf("weekdays, hours and months each need a weight above 0")
+		}
+	}
+	return nil
+}
+
+// normalize scales each list of weights so its largest is 1
+func (p *profile) normalize() {
+	for _, weights := range [][]float64{p.Weekdays[:], p.Hours[:], p.Months[:]} {
+		top := slices.Max(weights)
+		for i := range weights {
+			weights[i] /= top
+		}
+	}
+}
+
+// weight is the relative demand for a slot starting at t, in [0, 1]
+func (p *profile) weight(t time.Time) float64 {
+	t = t.UTC()
+	return p.Weekdays[t.Weekday()] * p.Hours[t.Hour()] * p.Months[t.Month()-1]
+}
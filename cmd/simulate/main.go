@@ -22,6 +22,7 @@ import (
 
 	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
 	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
 )
 
 type SimConfig struct {
@@ -33,6 +34,7 @@ type SimConfig struct {
 	ReadRatio    float64
 	PatientLimit int
 	SlotLimit    int
+	ColdBookings int // first bookings reported apart, to compare a cold start with steady state
 	PostgresDSN  string
 }
 
@@ -86,13 +88,26 @@ func (om *OperationMetrics) Record(latency time.Duration, success bool, conflict
 func (om *OperationMetrics) Stats() (avg, min, max, p50, p95 time.Duration) {
 	om.mu.Lock()
 	defer om.mu.Unlock()
+	return latencyStats(om.Latencies)
+}
+
+// SplitStats is Stats of the first n operations to finish and of the rest
+func (om *OperationMetrics) SplitStats(n int) (first, rest [5]time.Duration) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	n = min(n, len(om.Latencies))
+	first[0], first[1], first[2], first[3], first[4] = latencyStats(om.Latencies[:n])
+	rest[0], rest[1], rest[2], rest[3], rest[4] = latencyStats(om.Latencies[n:])
+	return first, rest
+}
 
-	if len(om.Latencies) == 0 {
+func latencyStats(recorded []time.Duration) (avg, min, max, p50, p95 time.Duration) {
+	if len(recorded) == 0 {
 		return 0, 0, 0, 0, 0
 	}
 
-	latencies := make([]time.Duration, len(om.Latencies))
-	copy(latencies, om.Latencies)
+	latencies := make([]time.Duration, len(recorded))
+	copy(latencies, recorded)
 
 	sort.Slice(latencies, func(i, j int) bool {
 		return latencies[i] < latencies[j]
@@ -155,7 +170,7 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	pgPool, err := db.ConnectPostgres(ctx, cfg.PostgresDSN)
+	pgPool, err := db.ConnectPostgres(ctx, shard.Default, cfg.PostgresDSN, false)
 	if err != nil {
 		log.Fatalf("connect postgres: %v", err)
 	}
@@ -198,6 +213,7 @@ func loadConfig() SimConfig {
 		ReadRatio:    getFloat("SIM_READ_RATIO", 0.3),
 		PatientLimit: getInt("SIM_PATIENT_LIMIT", 4000),
 		SlotLimit:    getInt("SIM_SLOT_LIMIT", 2400),
+		ColdBookings: getInt("SIM_COLD_BOOKINGS", 50),
 		PostgresDSN:  baseCfg.PostgresDSN,
 	}
 
@@ -481,6 +497,7 @@ func (s *Simulator) PrintReport() {
 	fmt.Println()
 
 	printOperationReport("Booking", &s.metrics.Booking)
+	printColdStartReport(&s.metrics.Booking, s.config.ColdBookings)
 	printOperationReport("Confirm", &s.metrics.Confirm)
 	printOperationReport("Read by ID", &s.metrics.ReadByID)
 	printOperationReport("List by Patient", &s.metrics.ListByPatient)
@@ -514,6 +531,25 @@ func printOperationReport(name string, om *OperationMetrics) {
 	fmt.Println()
 }
 
+// printColdStartReport compares the first n bookings, served while the
+// API's connections are new, with the rest. Run against a freshly started
+// API to see what warming its pool saves.
+func printColdStartReport(om *OperationMetrics, n int) {
+	if n <= 0 || atomic.LoadInt64(&om.Total) <= int64(n) {
+		return
+	}
+	first, rest := om.SplitStats(n)
+
+	fmt.Println("Booking cold start:")
+	fmt.Printf("  First %d: avg=%s p50=%s p95=%s max=%s\n", n,
+		first[0].Round(time.Millisecond), first[3].Round(time.Millisecond),
+		first[4].Round(time.Millisecond), first[2].Round(time.Millisecond))
+	fmt.Printf("  Rest:     avg=%s p50=%s p95=%s max=%s\n",
+		rest[0].Round(time.Millisecond), rest[3].Round(time.Millisecond),
+		rest[4].Round(time.Millisecond), rest[2].Round(time.Millisecond))
+	fmt.Println()
+}
+
 // Helper functions
 
 func getEnv(key, fallback string) string {
@@ -0,0 +1,119 @@
+// Command snapshot saves the slot inventory of a Postgres database -
+// clinics, clinicians, patients and slots - to a file and restores it into
+// another, e.g. to refresh staging from anonymized prod-shaped data.
+//
+//	snapshot -file inventory.ndjson [-anonymize] save
+//	snapshot -file inventory.ndjson restore
+//	snapshot -file inventory.ndjson anonymize > anonymized.ndjson
+//
+// save and restore read POSTGRES_DSN. A -file of "-" means stdout or stdin,
+// so a save can be piped straight into a restore against another DSN.
+// -anonymize and anonymize replace patient PII with fakes keyed by
+// ANONYMIZE_KEY; keep the key to get the same fakes on the next refresh.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/anonymize"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/snapshot"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	file := flag.String("file", "-", "snapshot file, - for stdout or stdin")
+	anonymized := flag.Bool("anonymize", false, "anonymize patients on save, keyed by ANONYMIZE_KEY")
+	timeout := flag.Duration("timeout", 30*time.Minute, "overall timeout")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: snapshot [-file path] [-anonymize] [-timeout 30m] save|restore|anonymize")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var anon *anonymize.Anonymizer
+	if *anonymized || flag.Arg(0) == "anonymize" {
+		key := os.Getenv("ANONYMIZE_KEY")
+		if key == "" {
+			log.Fatal("ANONYMIZE_KEY is required to anonymize")
+		}
+		anon = anonymize.New(key)
+	}
+
+	if flag.Arg(0) == "anonymize" {
+		counts, err := snapshot.Anonymize(openInput(*file), os.Stdout, anon)
+		if err != nil {
+			log.Fatalf("anonymize snapshot: %v", err)
+		}
+		log.Printf("anonymized %s", counts)
+		return
+	}
+
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		log.Fatal("POSTGRES_DSN is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	pool, err := db.ConnectPostgres(ctx, shard.Default, dsn, false)
+	if err != nil {
+		log.Fatalf("connect postgres: %v", err)
+	}
+	defer pool.Close()
+
+	switch flag.Arg(0) {
+	case "save":
+		var w io.Writer = os.Stdout
+		if *file != "-" {
+			f, err := os.Create(*file)
+			if err != nil {
+				log.Fatalf("create snapshot: %v", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		counts, err := snapshot.Save(ctx, pool, w, anon)
+		if err != nil {
+			log.Fatalf("save snapshot: %v", err)
+		}
+		log.Printf("saved %s", counts)
+
+	case "restore":
+		counts, err := snapshot.Restore(ctx, pool, openInput(*file))
+		if err != nil {
+			log.Fatalf("restore snapshot: %v", err)
+		}
+		log.Printf("restored %s", counts)
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// openInput opens the snapshot at path, or stdin for "-". The file is left
+// for the process exit to close.
+func openInput(path string) io.Reader {
+	if path == "-" {
+		return os.Stdin
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("open snapshot: %v", err)
+	}
+	return f
+}
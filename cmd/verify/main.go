@@ -0,0 +1,110 @@
+// Command verify checks the data invariants of a database, e.g. after a load
+// test or before a release. It only reads.
+//
+//	verify -backend postgres
+//	verify -backend sqlite -sqlite-path demo.db
+//
+// It exits 0 when every check passes, 1 when any finds violations and 2
+// when the checks could not be run.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/verify"
+)
+
+const (
+	exitViolations = 1
+	exitError      = 2
+)
+
+func main() {
+	log.SetFlags(0)
+
+	backend := flag.String("backend", "postgres", "backend to check: postgres or sqlite")
+	sqlitePath := flag.String("sqlite-path", "", "SQLite database path")
+	settle := flag.Duration("settle", time.Minute, "skip event checks for appointments updated this recently")
+	samples := flag.Int("samples", 10, "violations printed per check")
+	timeout := flag.Duration("timeout", 5*time.Minute, "overall timeout")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var v *verify.Verifier
+
+	switch *backend {
+	case "postgres":
+		dsn := os.Getenv("POSTGRES_DSN")
+		if dsn == "" {
+			fatalf("POSTGRES_DSN is required for the postgres backend")
+		}
+		pool, err := db.ConnectPostgres(ctx, shard.Default, dsn, false)
+		if err != nil {
+			fatalf("connect postgres: %v", err)
+		}
+		defer pool.Close()
+		v = verify.NewPostgres(pool)
+	case "sqlite":
+		if *sqlitePath == "" {
+			fatalf("-sqlite-path is required for the sqlite backend")
+		}
+		// OpenSQLite would create a missing database and report it clean
+		if _, err := os.Stat(*sqlitePath); err != nil {
+			fatalf("open sqlite: %v", err)
+		}
+		sqlDB, err := db.OpenSQLite(ctx, *sqlitePath)
+		if err != nil {
+			fatalf("open sqlite: %v", err)
+		}
+		defer sqlDB.Close()
+		v = verify.NewSQLite(sqlDB)
+	default:
+		fatalf("unknown backend %q", *backend)
+	}
+
+	results := v.Run(ctx, time.Now().Add(-*settle), *samples)
+
+	code := 0
+	for _, res := range results {
+		switch {
+		case res.Err != nil:
+			fmt.Printf("ERROR %s: %v\n", res.Check.Name, res.Err)
+			code = exitError
+		case res.Count > 0:
+			fmt.Printf("FAIL  %s: %d violation(s), %s\n", res.Check.Name, res.Count, res.Check.Description)
+			for _, vi := range res.Violations {
+				fmt.Printf("      %s: %s\n", vi.Subject, vi.Detail)
+			}
+			if res.Count > len(res.Violations) {
+				fmt.Printf("      ... and %d more\n", res.Count-len(res.Violations))
+			}
+			code = max(code, exitViolations)
+		default:
+			fmt.Printf("ok    %s\n", res.Check.Name)
+		}
+	}
+
+	switch code {
+	case 0:
+		fmt.Printf("\nall %d checks passed on %s\n", len(results), *backend)
+	case exitViolations:
+		fmt.Printf("\ninvariants violated on %s\n", *backend)
+	default:
+		fmt.Printf("\nsome checks could not run on %s\n", *backend)
+	}
+	os.Exit(code)
+}
+
+func fatalf(format string, args ...any) {
+	log.Printf(format, args...)
+	os.Exit(exitError)
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/linksign"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+// ActionLinkConfig enables the signed confirm and cancel links patients
+// follow from an email or SMS
+type ActionLinkConfig struct {
+	Secret string        // signs action links
+	TTL    time.Duration // how long an action link stays valid
+}
+
+// actionLinkPurpose keeps action link signatures from verifying as any
+// other kind of signed link
+const actionLinkPurpose = "appointment-action"
+
+const (
+	actionConfirm = "confirm"
+	actionCancel  = "cancel"
+)
+
+// actionPreviewProjection shows the patient when and with whom the
+// appointment is
+var actionPreviewProjection = detailProjection{related: appointment.DetailFields{Slot: true, Clinician: true}}
+
+// actionLinks signs and checks action links. A link names the action, the
+// appointment and the tenant it was issued for, since a patient following
+// it sends no tenant header.
+type actionLinks struct {
+	signer *linksign.Signer
+	ttl    time.Duration
+}
+
+func newActionLinks(cfg *ActionLinkConfig) *actionLinks {
+	return &actionLinks{signer: linksign.New(cfg.Secret), ttl: cfg.TTL}
+}
+
+func (l *actionLinks) link(action string, id uuid.UUID, tenant string, expires time.Time) string {
+	q := url.Values{
+		"expires":   {strconv.FormatInt(expires.Unix(), 10)},
+		"signature": {l.signer.Sign(expires, actionLinkPurpose, action, id.String(), tenant)},
+	}
+	if tenant != "" {
+		q.Set("tenant", tenant)
+	}
+	return "/appointments/" + id.String() + "/actions/" + action + "?" + q.Encode()
+}
+
+func (l *actionLinks) verify(r *http.Request, action string, id uuid.UUID, now time.Time) error {
+	q := r.URL.Query()
+	unix, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil {
+		return linksign.ErrInvalid
+	}
+	return l.signer.Verify(q.Get("signature"), time.Unix(unix, 0), now, actionLinkPurpose, action, id.String(), q.Get("tenant"))
+}
+
+// issueActionLinksHandler signs the links a patient can follow to act on
+// their appointment without logging in: cancel for any active appointment,
+// and confirm too while it is pending
+func issueActionLinksHandler(svc *appointment.Service, cfg *ActionLinkConfig) http.HandlerFunc {
+	links := newActionLinks(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		detail, err := svc.GetAppointment(r.Context(), id, appointment.DetailFields{})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if !detail.Status.Active() {
+			writeServiceError(w, appointment.ErrAppointmentNotActive)
+			return
+		}
+
+		tenant := shard.Tenant(r.Context())
+		expires := svc.Now().Add(links.ttl).Truncate(time.Second)
+		resp := ActionLinksResponse{
+			AppointmentID: id,
+			CancelURL:     links.link(actionCancel, id, tenant, expires),
+			ExpiresAt:     expires.UTC(),
+		}
+		if detail.Status == appointment.StatusPending {
+			resp.ConfirmURL = links.link(actionConfirm, id, tenant, expires)
+		}
+
+		writeJSON(w, http.StatusCreated, resp)
+	}
+}
+
+// actionLinkRequest checks the link r followed and returns the appointment
+// and the context routed to the tenant it was issued for. It writes the
+// error and returns false when the link is not valid.
+func actionLinkRequest(w http.ResponseWriter, r *http.Request, svc *appointment.Service, links *actionLinks) (uuid.UUID, string, context.Context, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+		return uuid.Nil, "", nil, false
+	}
+	action := chi.URLParam(r, "action")
+	if action != actionConfirm && action != actionCancel {
+		writeError(w, http.StatusNotFound, "unknown_action", "action must be confirm or cancel")
+		return uuid.Nil, "", nil, false
+	}
+	switch err := links.verify(r, action, id, svc.Now()); {
+	case errors.Is(err, linksign.ErrExpired):
+		writeError(w, http.StatusForbidden, "link_expired", "the link has expired, request a new one")
+		return uuid.Nil, "", nil, false
+	case err != nil:
+		writeError(w, http.StatusForbidden, "invalid_signature", "the link is not valid")
+		return uuid.Nil, "", nil, false
+	}
+
+	ctx := r.Context()
+	if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+		setAccessTenant(ctx, tenant)
+		ctx = shard.WithTenant(ctx, tenant)
+	}
+	return id, action, ctx, true
+}
+
+// previewActionLinkHandler shows what following an action link will do.
+// It changes nothing: mail and message scanners fetch links before the
+// patient sees them, so only a POST to the same link acts.
+func previewActionLinkHandler(svc *appointment.Service, cfg *ActionLinkConfig) http.HandlerFunc {
+	links := newActionLinks(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, action, ctx, ok := actionLinkRequest(w, r, svc, links)
+		if !ok {
+			return
+		}
+
+		detail, err := svc.GetAppointment(ctx, id, actionPreviewProjection.related)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, ActionLinkPreviewResponse{
+			Action:      action,
+			Appointment: toAppointmentDetailResponse(detail, svc.Now(), actionPreviewProjection),
+		})
+	}
+}
+
+// performActionLinkHandler confirms or cancels the appointment named by a
+// valid action link. The link is the only credential.
+func performActionLinkHandler(svc *appointment.Service, cfg *ActionLinkConfig) http.HandlerFunc {
+	links := newActionLinks(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, action, ctx, ok := actionLinkRequest(w, r, svc, links)
+		if !ok {
+			return
+		}
+
+		var appt *appointment.Appointment
+		var err error
+		switch action {
+		case actionConfirm:
+			appt, err = svc.ConfirmAppointment(ctx, id)
+		case actionCancel:
+			appt, err = svc.CancelAppointment(ctx, id, "patient_request", map[string]any{"via": "action_link"})
+		}
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("ETag", appointmentETag(appt.Status))
+		writeJSON(w, http.StatusOK, toAppointmentResponse(appt, svc.Now()))
+	}
+}
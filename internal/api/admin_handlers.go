@@ -0,0 +1,459 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/region"
+)
+
+func clusterHandler(registry ClusterRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instances, err := registry.List(r.Context())
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, "registry_unavailable", err.Error())
+			return
+		}
+
+		resp := ClusterResponse{
+			Instances: make([]InstanceResponse, 0, len(instances)),
+			Count:     len(instances),
+			Versions:  make(map[string]int),
+		}
+		for _, inst := range instances {
+			resp.Instances = append(resp.Instances, InstanceResponse{
+				ID:          inst.ID,
+				Hostname:    inst.Hostname,
+				Addr:        inst.Addr,
+				Version:     inst.Version,
+				StartedAt:   inst.StartedAt,
+				LastSeen:    inst.LastSeen,
+				RequestRate: inst.RequestRate,
+			})
+			resp.Versions[inst.Version]++
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+const (
+	defaultTopContended = 10
+	maxTopContended     = 100
+)
+
+// statsHandler reports slot lock activity. report may be nil when the
+// locker is process-local, in which case the ranking is left out.
+func statsHandler(report ContentionReport) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		top := defaultTopContended
+		if v := r.URL.Query().Get("top"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxTopContended {
+				writeError(w, http.StatusBadRequest, "invalid_top", "top must be between 1 and 100")
+				return
+			}
+			top = n
+		}
+
+		st := redisclient.CurrentLockStats()
+		locks := LockStatsResponse{
+			Attempts:  st.Attempts,
+			Contended: st.Contended,
+			Errors:    st.Errors,
+			Holds:     st.Holds,
+		}
+		if st.Holds > 0 {
+			locks.MeanHoldSeconds = st.HoldSecondsTotal / float64(st.Holds)
+		}
+
+		if report != nil {
+			slots, err := report.TopContended(r.Context(), top)
+			if err != nil {
+				msg := err.Error()
+				locks.TopContendedError = &msg
+			} else {
+				locks.TopContended = make([]SlotContentionResponse, 0, len(slots))
+				for _, s := range slots {
+					locks.TopContended = append(locks.TopContended, SlotContentionResponse{
+						SlotID:    s.SlotID,
+						Conflicts: s.Conflicts,
+					})
+				}
+			}
+		}
+
+		writeJSON(w, http.StatusOK, AdminStatsResponse{Locks: locks})
+	}
+}
+
+const (
+	defaultExpiryReportWindow = 24 * time.Hour
+	maxReportLimit            = 1000
+)
+
+// expiryEventReportHandler lists appointments whose expiry events disagree
+// with their status, looking back ?since (a duration, default 24h)
+func expiryEventReportHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window := defaultExpiryReportWindow
+		if v := r.URL.Query().Get("since"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				writeError(w, http.StatusBadRequest, "invalid_since", "since must be a positive duration such as 24h")
+				return
+			}
+			window = d
+		}
+
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxReportLimit {
+				writeError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and 1000")
+				return
+			}
+			limit = n
+		}
+
+		since := svc.Now().Add(-window)
+		mismatches, err := svc.ExpiryEventReport(r.Context(), since, limit)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := ExpiryEventReportResponse{
+			Since:      since,
+			Mismatches: make([]ExpiryEventMismatchResponse, 0, len(mismatches)),
+		}
+		for _, m := range mismatches {
+			resp.Mismatches = append(resp.Mismatches, ExpiryEventMismatchResponse{
+				AppointmentID: m.AppointmentID,
+				Status:        string(m.Status),
+				ExpiredEvents: m.ExpiredEvents,
+				UpdatedAt:     m.UpdatedAt,
+			})
+		}
+		resp.Count = len(resp.Mismatches)
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// orphanReportHandler lists active appointments the booking flow left
+// behind, as found by the expiry worker's reconciliation job
+func orphanReportHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxReportLimit {
+				writeError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and 1000")
+				return
+			}
+			limit = n
+		}
+
+		orphans, err := svc.FindOrphanedAppointments(r.Context(), limit)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := OrphanReportResponse{Orphans: make([]OrphanedAppointmentResponse, 0, len(orphans))}
+		for _, o := range orphans {
+			resp.Orphans = append(resp.Orphans, OrphanedAppointmentResponse{
+				AppointmentID: o.ID,
+				Kind:          string(o.Kind),
+				Status:        string(o.Status),
+				SlotID:        o.SlotID,
+				SlotStatus:    string(o.SlotStatus),
+				ExpiresAt:     o.ExpiresAt,
+				UpdatedAt:     o.UpdatedAt,
+			})
+		}
+		resp.Count = len(resp.Orphans)
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// expiryBacklogHandler reports the pending holds that have passed without
+// being expired, so operators can tell whether the expiry worker keeps up
+func expiryBacklogHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		backlog, err := svc.ExpiryBacklog(r.Context())
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := ExpiryBacklogResponse{
+			At:              backlog.At,
+			Total:           backlog.Total,
+			OldestExpiresAt: backlog.Oldest,
+			Buckets:         make([]ExpiryBacklogBucketResponse, 0, len(backlog.Buckets)),
+		}
+		if backlog.Oldest != nil {
+			overdue := int64(backlog.At.Sub(*backlog.Oldest) / time.Second)
+			resp.OldestOverdueSeconds = &overdue
+		}
+		for _, b := range backlog.Buckets {
+			bucket := ExpiryBacklogBucketResponse{
+				OverdueFromSeconds: int64(b.From / time.Second),
+				Count:              b.Count,
+			}
+			if b.To > 0 {
+				to := int64(b.To / time.Second)
+				bucket.OverdueToSeconds = &to
+			}
+			resp.Buckets = append(resp.Buckets, bucket)
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func listBookingWindowsHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		windows, err := svc.ListBookingWindows(r.Context())
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := BookingWindowListResponse{Windows: make([]BookingWindowResponse, 0, len(windows))}
+		for i := range windows {
+			resp.Windows = append(resp.Windows, toBookingWindowResponse(&windows[i]))
+		}
+		resp.Count = len(resp.Windows)
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// putBookingWindowHandler creates or replaces the booking window of the
+// specialty in the path, which is matched exactly against clinicians'
+// specialties, so "General%20Practice" for General Practice
+func putBookingWindowHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		specialty, ok := specialtyParam(w, r)
+		if !ok {
+			return
+		}
+
+		var req PutBookingWindowRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		window := appointment.BookingWindow{Specialty: specialty}
+		if req.MinLead != "" {
+			d, err := time.ParseDuration(req.MinLead)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_booking_window", "min_lead must be a duration such as 336h")
+				return
+			}
+			window.MinLead = d
+		}
+		if req.MaxLead != "" {
+			d, err := time.ParseDuration(req.MaxLead)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_booking_window", "max_lead must be a duration such as 2160h")
+				return
+			}
+			window.MaxLead = &d
+		}
+
+		saved, err := svc.PutBookingWindow(r.Context(), window)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toBookingWindowResponse(saved))
+	}
+}
+
+func deleteBookingWindowHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		specialty, ok := specialtyParam(w, r)
+		if !ok {
+			return
+		}
+
+		if err := svc.DeleteBookingWindow(r.Context(), specialty); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func specialtyParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	specialty, err := url.PathUnescape(chi.URLParam(r, "specialty"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_booking_window", "specialty is not a valid path segment")
+		return "", false
+	}
+	return specialty, true
+}
+
+func toBookingWindowResponse(window *appointment.BookingWindow) BookingWindowResponse {
+	resp := BookingWindowResponse{
+		Specialty: window.Specialty,
+		MinLead:   window.MinLead.String(),
+		UpdatedAt: window.UpdatedAt,
+	}
+	if window.MaxLead != nil {
+		maxLead := window.MaxLead.String()
+		resp.MaxLead = &maxLead
+	}
+	return resp
+}
+
+const (
+	defaultLockListLimit = 100
+	maxLockListLimit     = 1000
+)
+
+// listLocksHandler lists held locks, most likely stuck first. A lock set
+// without expiry has no age and a null ttl_remaining_seconds.
+func listLocksHandler(locks LockAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultLockListLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxLockListLimit {
+				writeError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and 1000")
+				return
+			}
+			limit = n
+		}
+
+		held, err := locks.List(r.Context(), limit)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, "locks_unavailable", err.Error())
+			return
+		}
+
+		resp := HeldLocksResponse{Locks: make([]HeldLockResponse, 0, len(held))}
+		for _, l := range held {
+			item := HeldLockResponse{Key: l.Key, Token: l.Token}
+			if l.TTLRemaining >= 0 {
+				age, ttl := l.Age.Seconds(), l.TTLRemaining.Seconds()
+				item.AgeSeconds, item.TTLRemainingSeconds = &age, &ttl
+			}
+			resp.Locks = append(resp.Locks, item)
+		}
+		resp.Count = len(resp.Locks)
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// releaseLockHandler deletes a stuck lock and audits it. The token taken from
+// GET /admin/locks must still match, so a lock that has since been released
+// and re-acquired by a live request is left alone.
+func releaseLockHandler(locks LockAdmin, svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := chi.URLParam(r, "key")
+
+		var req ReleaseLockRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		if req.Token == "" {
+			writeError(w, http.StatusBadRequest, "missing_token", "token is required")
+			return
+		}
+
+		released, err := locks.ForceRelease(r.Context(), key, req.Token)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, "locks_unavailable", err.Error())
+			return
+		}
+		if !released {
+			writeError(w, http.StatusConflict, "lock_not_held", "the lock is no longer held with this token")
+			return
+		}
+
+		details := map[string]any{
+			"token":       req.Token,
+			"reason":      req.Reason,
+			"request_id":  w.Header().Get("X-Request-ID"),
+			"remote_addr": r.RemoteAddr,
+		}
+		if err := svc.RecordLockForceRelease(r.Context(), key, details); err != nil {
+			// the lock is gone either way; say so rather than invite a retry
+			log.Printf("lock %s force released but not audited: %v", key, err)
+		}
+
+		writeJSON(w, http.StatusOK, ReleaseLockResponse{Key: key, Released: true})
+	}
+}
+
+func regionStatusHandler(ctrl *region.Controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st, err := ctrl.Status(r.Context())
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, "region_status_unavailable", err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toRegionResponse(st))
+	}
+}
+
+func promoteRegionHandler(ctrl *region.Controller) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PromoteRegionRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+				return
+			}
+		}
+
+		st, err := ctrl.Promote(r.Context(), req.PromoteDatabase)
+		if err != nil {
+			switch {
+			case errors.Is(err, region.ErrNotPassive):
+				writeError(w, http.StatusConflict, "already_active", err.Error())
+			case errors.Is(err, region.ErrDatabaseStandby):
+				writeError(w, http.StatusConflict, "database_standby", err.Error())
+			default:
+				writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			}
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toRegionResponse(st))
+	}
+}
+
+func toRegionResponse(st region.Status) RegionResponse {
+	resp := RegionResponse{
+		Role:               st.Role,
+		ReadOnly:           st.ReadOnly,
+		DatabaseInRecovery: st.InRecovery,
+		MaxLagSeconds:      st.MaxLag.Seconds(),
+		PromotedAt:         st.PromotedAt,
+	}
+	if st.ReplicationLag != nil {
+		lag := st.ReplicationLag.Seconds()
+		resp.ReplicationLagSeconds = &lag
+	}
+	return resp
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+func createAPIKeyHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateAPIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		scopes := make([]appointment.APIScope, len(req.Scopes))
+		for i, scope := range req.Scopes {
+			scopes[i] = appointment.APIScope(scope)
+		}
+		k, secret, err := svc.CreateAPIKey(r.Context(), req.Name, scopes, req.ClinicID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := toAPIKeyResponse(k)
+		resp.Key = secret
+		writeJSON(w, http.StatusCreated, resp)
+	}
+}
+
+func listAPIKeysHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := svc.ListAPIKeys(r.Context())
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := APIKeyListResponse{
+			APIKeys: make([]APIKeyResponse, len(keys)),
+			Count:   len(keys),
+		}
+		for i := range keys {
+			resp.APIKeys[i] = toAPIKeyResponse(&keys[i])
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// revokeAPIKeyHandler revokes a key. The key is kept, so it is returned
+// with the time it was revoked.
+func revokeAPIKeyHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_api_key_id", "id must be a valid UUID")
+			return
+		}
+
+		k, err := svc.RevokeAPIKey(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toAPIKeyResponse(k))
+	}
+}
+
+func toAPIKeyResponse(k *appointment.APIKey) APIKeyResponse {
+	scopes := make([]string, len(k.Scopes))
+	for i, scope := range k.Scopes {
+		scopes[i] = string(scope)
+	}
+	return APIKeyResponse{
+		ID:        k.ID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    scopes,
+		ClinicID:  k.ClinicID,
+		CreatedAt: k.CreatedAt,
+		RevokedAt: k.RevokedAt,
+	}
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/linksign"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+// AttachmentConfig enables the attachment endpoints
+type AttachmentConfig struct {
+	URLSecret string        // signs download links
+	URLTTL    time.Duration // how long a download link stays valid
+	MaxBytes  int64         // largest file read from an upload; the service enforces the same limit
+}
+
+// multipartOverhead allows for the form fields and part headers around the
+// file of an upload
+const multipartOverhead = 64 << 10
+
+// attachmentLinkPurpose keeps attachment signatures from verifying as any
+// other kind of signed link
+const attachmentLinkPurpose = "attachment-download"
+
+// attachmentLinks signs and checks download links. A link names the tenant
+// it was issued for, since a browser following it sends no tenant header.
+type attachmentLinks struct {
+	signer *linksign.Signer
+	ttl    time.Duration
+}
+
+func newAttachmentLinks(cfg *AttachmentConfig) *attachmentLinks {
+	return &attachmentLinks{signer: linksign.New(cfg.URLSecret), ttl: cfg.URLTTL}
+}
+
+func (l *attachmentLinks) link(id uuid.UUID, tenant string, now time.Time) (string, time.Time) {
+	expires := now.Add(l.ttl).Truncate(time.Second)
+	q := url.Values{
+		"expires":   {strconv.FormatInt(expires.Unix(), 10)},
+		"signature": {l.signer.Sign(expires, attachmentLinkPurpose, id.String(), tenant)},
+	}
+	if tenant != "" {
+		q.Set("tenant", tenant)
+	}
+	return "/attachments/" + id.String() + "/download?" + q.Encode(), expires
+}
+
+func (l *attachmentLinks) verify(r *http.Request, id uuid.UUID, now time.Time) error {
+	q := r.URL.Query()
+	unix, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil {
+		return linksign.ErrInvalid
+	}
+	return l.signer.Verify(q.Get("signature"), time.Unix(unix, 0), now, attachmentLinkPurpose, id.String(), q.Get("tenant"))
+}
+
+func (l *attachmentLinks) response(a *appointment.Attachment, tenant string, now time.Time) AttachmentResponse {
+	link, expires := l.link(a.ID, tenant, now)
+	return AttachmentResponse{
+		ID:                a.ID,
+		AppointmentID:     a.AppointmentID,
+		Kind:              string(a.Kind),
+		Filename:          a.Filename,
+		ContentType:       a.ContentType,
+		SizeBytes:         a.Size,
+		ScanStatus:        string(a.ScanStatus),
+		CreatedAt:         a.CreatedAt,
+		DownloadURL:       link,
+		DownloadExpiresAt: expires.UTC(),
+	}
+}
+
+// uploadAttachmentHandler attaches the "file" part of a multipart form to
+// the appointment, as the kind named by the "kind" field
+func uploadAttachmentHandler(svc *appointment.Service, cfg *AttachmentConfig) http.HandlerFunc {
+	links := newAttachmentLinks(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBytes+multipartOverhead)
+		mr, err := r.MultipartReader()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "expected a multipart/form-data body")
+			return
+		}
+
+		var up appointment.AttachmentUpload
+		var haveFile bool
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				writeUploadError(w, err)
+				return
+			}
+			switch part.FormName() {
+			case "kind":
+				kind, err := io.ReadAll(io.LimitReader(part, 64))
+				if err != nil {
+					writeUploadError(w, err)
+					return
+				}
+				up.Kind = appointment.AttachmentKind(kind)
+			case "file":
+				// Read one byte past the limit so the service sees the
+				// upload is too large
+				up.Content, err = io.ReadAll(io.LimitReader(part, cfg.MaxBytes+1))
+				if err != nil {
+					writeUploadError(w, err)
+					return
+				}
+				up.Filename = part.FileName()
+				haveFile = true
+			}
+			part.Close()
+		}
+		if !haveFile {
+			writeError(w, http.StatusBadRequest, "missing_file", "the form must have a file part named file")
+			return
+		}
+
+		saved, err := svc.AddAttachment(r.Context(), id, up)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, links.response(saved, shard.Tenant(r.Context()), svc.Now()))
+	}
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeServiceError(w, appointment.ErrAttachmentTooLarge)
+		return
+	}
+	writeError(w, http.StatusBadRequest, "invalid_request_body", err.Error())
+}
+
+// listAttachmentsHandler lists the appointment's attachments with fresh
+// download links
+func listAttachmentsHandler(svc *appointment.Service, cfg *AttachmentConfig) http.HandlerFunc {
+	links := newAttachmentLinks(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		attachments, err := svc.ListAttachments(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if len(attachments) > 0 && !recordAppointmentPIIAccess(w, r, svc, id) {
+			return
+		}
+
+		now := svc.Now()
+		tenant := shard.Tenant(r.Context())
+		resp := AttachmentListResponse{Attachments: make([]AttachmentResponse, 0, len(attachments))}
+		for i := range attachments {
+			resp.Attachments = append(resp.Attachments, links.response(&attachments[i], tenant, now))
+		}
+		resp.Count = len(resp.Attachments)
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// downloadAttachmentHandler serves an attachment to whoever holds a valid
+// signed link for it. It takes no other credentials.
+func downloadAttachmentHandler(svc *appointment.Service, cfg *AttachmentConfig) http.HandlerFunc {
+	links := newAttachmentLinks(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_attachment_id", "id must be a valid UUID")
+			return
+		}
+		switch err := links.verify(r, id, svc.Now()); {
+		case errors.Is(err, linksign.ErrExpired):
+			writeError(w, http.StatusForbidden, "link_expired", "the download link has expired, request a new one")
+			return
+		case err != nil:
+			writeError(w, http.StatusForbidden, "invalid_signature", "the download link is not valid")
+			return
+		}
+
+		ctx := r.Context()
+		if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+			setAccessTenant(ctx, tenant)
+			ctx = shard.WithTenant(ctx, tenant)
+		}
+
+		a, content, err := svc.OpenAttachment(ctx, id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		defer content.Close()
+		if !recordAppointmentPIIAccess(w, r.WithContext(ctx), svc, a.AppointmentID) {
+			return
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", a.ContentType)
+		h.Set("Content-Length", strconv.FormatInt(a.Size, 10))
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Cache-Control", "private, no-store")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.Copy(w, content)
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// weekdays maps the weekday names of availability periods
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+func getAvailabilityTemplateHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := clinicianIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		t, err := svc.GetAvailabilityTemplate(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toAvailabilityTemplateResponse(t))
+	}
+}
+
+// putAvailabilityTemplateHandler creates or replaces the clinician's weekly
+// availability
+func putAvailabilityTemplateHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := clinicianIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		var req PutAvailabilityTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		t := appointment.AvailabilityTemplate{
+			ClinicianID:  id,
+			TimeZone:     req.TimeZone,
+			SlotDuration: time.Duration(req.SlotMinutes) * time.Minute,
+			Capacity:     1,
+			SlotType:     req.SlotType,
+		}
+		if req.Capacity != nil {
+			t.Capacity = *req.Capacity
+		}
+		for i, p := range req.Periods {
+			period, err := parseAvailabilityPeriod(p)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_availability_template", fmt.Sprintf("period %d: %v", i+1, err))
+				return
+			}
+			t.Periods = append(t.Periods, period)
+		}
+
+		saved, err := svc.PutAvailabilityTemplate(r.Context(), t)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toAvailabilityTemplateResponse(saved))
+	}
+}
+
+func deleteAvailabilityTemplateHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := clinicianIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		if err := svc.DeleteAvailabilityTemplate(r.Context(), id); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// generateSlotsHandler expands the clinician's availability template into
+// slots over a range of dates, see appointment.Service.GenerateSlots
+func generateSlotsHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := clinicianIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		var req GenerateSlotsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		from, err := time.Parse(time.DateOnly, req.From)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_slot_generation", "from must be a date in YYYY-MM-DD format")
+			return
+		}
+		to, err := time.Parse(time.DateOnly, req.To)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_slot_generation", "to must be a date in YYYY-MM-DD format")
+			return
+		}
+
+		gen, err := svc.GenerateSlots(r.Context(), id, from, to)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := GenerateSlotsResponse{
+			ClinicianID: id,
+			Created:     len(gen.Slots),
+			Skipped:     gen.Skipped,
+			Slots:       make([]SlotSummaryResponse, len(gen.Slots)),
+		}
+		for i := range gen.Slots {
+			resp.Slots[i] = toSlotSummaryResponse(&gen.Slots[i])
+		}
+		writeJSON(w, http.StatusCreated, resp)
+	}
+}
+
+func clinicianIDParam(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_clinician_id", "id must be a valid UUID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func parseAvailabilityPeriod(p AvailabilityPeriodRequest) (appointment.AvailabilityPeriod, error) {
+	weekday, ok := weekdays[strings.ToLower(p.Weekday)]
+	if !ok {
+		return appointment.AvailabilityPeriod{}, errors.New("weekday must be the name of a day, such as monday")
+	}
+	start, err := parseClockTime(p.Start)
+	if err != nil {
+		return appointment.AvailabilityPeriod{}, fmt.Errorf("start: %w", err)
+	}
+	end, err := parseClockTime(p.End)
+	if err != nil {
+		return appointment.AvailabilityPeriod{}, fmt.Errorf("end: %w", err)
+	}
+	return appointment.AvailabilityPeriod{Weekday: weekday, Start: start, End: end}, nil
+}
+
+// parseClockTime reads HH:MM as minutes after midnight, allowing 24:00
+func parseClockTime(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time in HH:MM format", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatClockTime(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func toAvailabilityTemplateResponse(t *appointment.AvailabilityTemplate) AvailabilityTemplateResponse {
+	resp := AvailabilityTemplateResponse{
+		ClinicianID: t.ClinicianID,
+		TimeZone:    t.TimeZone,
+		SlotMinutes: int(t.SlotDuration / time.Minute),
+		Capacity:    t.Capacity,
+		SlotType:    t.SlotType,
+		Periods:     make([]AvailabilityPeriodRequest, len(t.Periods)),
+		UpdatedAt:   t.UpdatedAt,
+	}
+	for i, p := range t.Periods {
+		resp.Periods[i] = AvailabilityPeriodRequest{
+			Weekday: strings.ToLower(p.Weekday.String()),
+			Start:   formatClockTime(p.Start),
+			End:     formatClockTime(p.End),
+		}
+	}
+	return resp
+}
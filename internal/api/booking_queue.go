@@ -0,0 +1,47 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// QueueTicketHeader carries the ticket of a queued booking attempt into its
+// retry, keeping its place in the clinic's booking queue
+const QueueTicketHeader = "X-Queue-Ticket"
+
+// queueTicketRequest passes the request's queue ticket, if any, on to the
+// service
+func queueTicketRequest(r *http.Request) *http.Request {
+	ticket := r.Header.Get(QueueTicketHeader)
+	if ticket == "" {
+		return r
+	}
+	return r.WithContext(appointment.WithQueueTicket(r.Context(), ticket))
+}
+
+// writeQueuedError writes a 429 with the attempt's place in its clinic's
+// booking queue when err is a *appointment.QueuedError, and reports whether
+// it was
+func writeQueuedError(w http.ResponseWriter, err error) bool {
+	var queued *appointment.QueuedError
+	if !errors.As(err, &queued) {
+		return false
+	}
+	seconds := max(int((queued.Wait+time.Second-1)/time.Second), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeJSON(w, http.StatusTooManyRequests, BookingQueuedResponse{
+		ErrorResponse: ErrorResponse{
+			Error:     appointment.ErrBookingQueued.Code,
+			Details:   err.Error(),
+			Retryable: true,
+		},
+		QueueTicket:          queued.Ticket,
+		QueuePosition:        queued.Position,
+		EstimatedWaitSeconds: seconds,
+	})
+	return true
+}
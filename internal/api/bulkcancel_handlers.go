@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/bulkcancel"
+)
+
+// cancelClinicDayHandler queues a bulk cancellation and returns 202 with the
+// run, whose id is polled on /admin/bulk-cancellations/{id}
+func cancelClinicDayHandler(svc *bulkcancel.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clinicID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_clinic_id", "id must be a valid UUID")
+			return
+		}
+
+		q := r.URL.Query()
+		date := q.Get("date")
+		if date == "" {
+			writeError(w, http.StatusBadRequest, "missing_date", "date query parameter is required")
+			return
+		}
+
+		var clinicianID *uuid.UUID
+		if raw := q.Get("clinician_id"); raw != "" {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_clinician_id", "clinician_id must be a valid UUID")
+				return
+			}
+			clinicianID = &id
+		}
+
+		loc := time.UTC
+		if tz := q.Get("tz"); tz != "" {
+			loc, err = time.LoadLocation(tz)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_tz", "tz must be an IANA time zone name")
+				return
+			}
+		}
+
+		run, err := svc.Start(r.Context(), clinicID, clinicianID, date, loc)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, toBulkCancellationResponse(run))
+	}
+}
+
+func getBulkCancellationHandler(svc *bulkcancel.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_bulk_cancellation_id", "id must be a valid UUID")
+			return
+		}
+
+		run, err := svc.Get(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toBulkCancellationResponse(run))
+	}
+}
+
+func toBulkCancellationResponse(run *bulkcancel.Run) BulkCancellationResponse {
+	return BulkCancellationResponse{
+		ID:          run.ID,
+		ClinicID:    run.ClinicID,
+		ClinicianID: run.ClinicianID,
+		Date:        run.Day,
+		WindowStart: run.WindowStart,
+		WindowEnd:   run.WindowEnd,
+		State:       string(run.State),
+		Total:       run.Total,
+		Cancelled:   run.Cancelled,
+		Failed:      run.Failed,
+		LastError:   run.LastError,
+		CreatedAt:   run.CreatedAt,
+		UpdatedAt:   run.UpdatedAt,
+		FinishedAt:  run.FinishedAt,
+	}
+}
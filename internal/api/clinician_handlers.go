@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+func createClinicianHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateClinicianRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		c, err := svc.CreateClinician(r.Context(), appointment.NewClinician{
+			Name:      req.Name,
+			Specialty: req.Specialty,
+			ClinicID:  req.ClinicID,
+		})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, toClinicianResponse(c))
+	}
+}
+
+func getClinicianHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := clinicianIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		c, err := svc.GetClinician(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toClinicianResponse(c))
+	}
+}
+
+func updateClinicianHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := clinicianIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		var req UpdateClinicianRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		c, err := svc.UpdateClinician(r.Context(), id, appointment.ClinicianUpdate{Name: req.Name, Specialty: req.Specialty})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toClinicianResponse(c))
+	}
+}
+
+// deactivateClinicianHandler stops the clinician's slots from being booked;
+// their appointments are kept
+func deactivateClinicianHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := clinicianIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		c, err := svc.DeactivateClinician(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toClinicianResponse(c))
+	}
+}
+
+// scheduleProjection is what a clinician's schedule shows of each
+// appointment: when it is and who is coming
+var scheduleProjection = detailProjection{related: appointment.DetailFields{Slot: true, Patient: true}}
+
+// clinicianScheduleHandler lists the clinician's appointments with slots
+// starting in [from, to), earliest first, with patient details
+func clinicianScheduleHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := clinicianIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		q := r.URL.Query()
+		from, err := time.Parse(time.RFC3339, q.Get("from"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_from", "from must be an RFC 3339 timestamp")
+			return
+		}
+		to, err := time.Parse(time.RFC3339, q.Get("to"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_to", "to must be an RFC 3339 timestamp")
+			return
+		}
+
+		appts, err := svc.ClinicianSchedule(r.Context(), id, from, to)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		patients := make([]uuid.UUID, 0, len(appts))
+		for _, a := range appts {
+			if !slices.Contains(patients, a.PatientID) {
+				patients = append(patients, a.PatientID)
+			}
+		}
+		if !recordPIIAccess(w, r, svc, patients...) {
+			return
+		}
+
+		now := svc.Now()
+		resp := AppointmentListResponse{Appointments: make([]AppointmentDetailResponse, len(appts)), Total: len(appts)}
+		for i := range appts {
+			resp.Appointments[i] = toAppointmentDetailResponse(&appts[i], now, scheduleProjection)
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func toClinicianResponse(c *appointment.Clinician) ClinicianResponse {
+	return ClinicianResponse{
+		ID:            c.ID,
+		Name:          c.Name,
+		Specialty:     c.Specialty,
+		ClinicID:      c.ClinicID,
+		Deactivated:   c.Deactivated(),
+		DeactivatedAt: c.DeactivatedAt,
+		CreatedAt:     c.CreatedAt,
+		UpdatedAt:     c.UpdatedAt,
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// registerDeviceHandler registers a device of the patient for push
+// notifications. The apps call it on every launch; registering a token
+// again only refreshes it.
+func registerDeviceHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "id must be a valid UUID")
+			return
+		}
+
+		var req RegisterDeviceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		d, err := svc.RegisterDevice(r.Context(), id, appointment.PushProvider(req.Provider), req.Token)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, toDeviceResponse(d))
+	}
+}
+
+func listDevicesHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "id must be a valid UUID")
+			return
+		}
+
+		devices, err := svc.ListDevices(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := DeviceListResponse{
+			Devices: make([]DeviceResponse, 0, len(devices)),
+			Count:   len(devices),
+		}
+		for i := range devices {
+			resp.Devices = append(resp.Devices, toDeviceResponse(&devices[i]))
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// deleteDeviceHandler unregisters a device, as the apps do on sign-out
+func deleteDeviceHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "id must be a valid UUID")
+			return
+		}
+		deviceID, err := uuid.Parse(chi.URLParam(r, "device_id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_device_id", "device_id must be a valid UUID")
+			return
+		}
+
+		if err := svc.DeleteDevice(r.Context(), id, deviceID); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func toDeviceResponse(d *appointment.Device) DeviceResponse {
+	return DeviceResponse{
+		ID:        d.ID,
+		PatientID: d.PatientID,
+		Provider:  string(d.Provider),
+		Token:     d.Token,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ResponseEncoder turns the response types into bytes on the wire. Handlers
+// only deal in the snake_case types of types.go; an encoder may reshape them,
+// so one set of types serves every wire format.
+type ResponseEncoder interface {
+	// WriteResponse writes v as the whole body of a response with status
+	WriteResponse(w http.ResponseWriter, status int, v any)
+	// WriteItem writes v as one row of a streamed response
+	WriteItem(w io.Writer, v any) error
+}
+
+// PlainEncoder writes the response types as they are declared
+type PlainEncoder struct{}
+
+func (PlainEncoder) WriteResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (PlainEncoder) WriteItem(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// CompatEncoder renames every field to camelCase and wraps bodies in the
+// envelope gateways following the organization's API standards expect:
+//
+//	{"data": ..., "meta": {"requestId": "...", "serverTime": "..."}}
+//
+// Error responses carry {"code", "details", "retryable"} under "error"
+// instead of "data". Streamed rows are renamed but not wrapped.
+type CompatEncoder struct{}
+
+type compatMeta struct {
+	RequestID  string    `json:"requestId,omitempty"`
+	ServerTime time.Time `json:"serverTime"`
+}
+
+// compatError is ErrorResponse in the envelope, where the code is not
+// repeated under an "error" key
+type compatError struct {
+	Code      string `json:"code"`
+	Details   string `json:"details,omitempty"`
+	Retryable bool   `json:"retryable,omitempty"`
+}
+
+func (CompatEncoder) WriteResponse(w http.ResponseWriter, status int, v any) {
+	envelope := map[string]any{
+		"meta": compatMeta{
+			RequestID:  w.Header().Get("X-Request-ID"),
+			ServerTime: time.Now().UTC(),
+		},
+	}
+	if e, isError := v.(ErrorResponse); isError {
+		envelope["error"] = compatError{Code: e.Error, Details: e.Details, Retryable: e.Retryable}
+	} else {
+		body, err := camelCase(v)
+		if err != nil {
+			envelope["error"] = compatError{Code: "internal_error", Details: err.Error()}
+			status = http.StatusInternalServerError
+		} else {
+			envelope["data"] = body
+		}
+	}
+	PlainEncoder{}.WriteResponse(w, status, envelope)
+}
+
+func (CompatEncoder) WriteItem(w io.Writer, v any) error {
+	body, err := camelCase(v)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(body)
+}
+
+// camelCase round-trips v through its JSON form and renames the object keys.
+// Map keys are renamed too; no response type uses identifiers as keys except
+// ClusterResponse.Versions, whose version strings have no underscores.
+func camelCase(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return renameKeys(tree), nil
+}
+
+func renameKeys(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			out[snakeToCamel(k)] = renameKeys(val)
+		}
+		return out
+	case []any:
+		for i, val := range t {
+			t[i] = renameKeys(val)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func snakeToCamel(s string) string {
+	if !strings.Contains(s, "_") {
+		return s
+	}
+	parts := strings.Split(s, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, p := range parts[1:] {
+		if p == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(p[:1]))
+		b.WriteString(p[1:])
+	}
+	return b.String()
+}
+
+// encoderWriter carries the encoder chosen for a request down to writeJSON
+type encoderWriter struct {
+	http.ResponseWriter
+	enc ResponseEncoder
+}
+
+func (ew *encoderWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// ResponseEncoderMiddleware makes enc the encoder for every response
+// written through writeJSON
+func ResponseEncoderMiddleware(enc ResponseEncoder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&encoderWriter{ResponseWriter: w, enc: enc}, r)
+		})
+	}
+}
+
+// encoderFor finds the encoder installed by ResponseEncoderMiddleware,
+// looking through writers that wrap it
+func encoderFor(w http.ResponseWriter) ResponseEncoder {
+	for {
+		switch t := w.(type) {
+		case *encoderWriter:
+			return t.enc
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return PlainEncoder{}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"fmt"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// exportFlushEvery is how many NDJSON rows are buffered before flushing to
+// the client
+const exportFlushEvery = 100
+
+// exportWriteWindow is how long a streamed export may take to write the
+// rows up to its next flush. It replaces the server's write timeout, which
+// would otherwise cut off large exports, while still dropping stalled clients.
+const exportWriteWindow = 30 * time.Second
+
+// acceptsNDJSON reports whether the Accept header lists application/x-ndjson
+func acceptsNDJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == ndjsonContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// clinicAppointmentsHandler lists a clinic's appointments with slots in
+// [from, to). With Accept: application/x-ndjson every appointment is written
+// on its own line as it is read from the database, so exports of any size
+// run in constant memory; otherwise the usual list response is built.
+func clinicAppointmentsHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clinicID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_clinic_id", "id must be a valid UUID")
+			return
+		}
+
+		q := r.URL.Query()
+		from, err := time.Parse(time.RFC3339, q.Get("from"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_from", "from must be an RFC 3339 timestamp")
+			return
+		}
+		to, err := time.Parse(time.RFC3339, q.Get("to"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_to", "to must be an RFC 3339 timestamp")
+			return
+		}
+
+		fields, lean, err := parseListProjection(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_projection", err.Error())
+			return
+		}
+
+		now := svc.Now()
+		// Patients are collected as a set so a long export holds each once
+		patients := map[uuid.UUID]bool{}
+		render := func(d *appointment.AppointmentDetail) any {
+			if lean {
+				return toAppointmentResponse(&d.Appointment, now)
+			}
+			if d.Patient != nil {
+				patients[d.Patient.ID] = true
+			}
+			return toAppointmentDetailResponse(d, now, fields)
+		}
+
+		if acceptsNDJSON(r) {
+			streamClinicAppointments(w, r, svc, clinicID, from, to, fields.related, render)
+			recordStreamedPIIAccess(r, svc, slices.Collect(maps.Keys(patients)))
+			return
+		}
+
+		items := []any{}
+		err = svc.StreamClinicAppointments(r.Context(), clinicID, from, to, fields.related, func(d *appointment.AppointmentDetail) error {
+			items = append(items, render(d))
+			return nil
+		})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if !recordPIIAccess(w, r, svc, slices.Collect(maps.Keys(patients))...) {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, ClinicAppointmentsResponse{
+			Appointments: items,
+			Total:        len(items),
+		})
+	}
+}
+
+// streamClinicAppointments writes one JSON value per line. The status is
+// sent with the first row, so an error after that can only be reported as a
+// final {"error": ...} line; clients must treat a stream ending in one as
+// incomplete.
+func streamClinicAppointments(w http.ResponseWriter, r *http.Request, svc *appointment.Service, clinicID uuid.UUID, from, to time.Time, fields appointment.DetailFields, render func(*appointment.AppointmentDetail) any) {
+	rc := http.NewResponseController(w)
+	enc := encoderFor(w)
+	_ = rc.SetWriteDeadline(time.Now().Add(exportWriteWindow))
+
+	started := false
+	rows := 0
+	err := svc.StreamClinicAppointments(r.Context(), clinicID, from, to, fields, func(d *appointment.AppointmentDetail) error {
+		if !started {
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if err := enc.WriteItem(w, render(d)); err != nil {
+			return fmt.Errorf("write row: %w", err)
+		}
+		rows++
+		if rows%exportFlushEvery == 0 {
+			_ = rc.Flush()
+			_ = rc.SetWriteDeadline(time.Now().Add(exportWriteWindow))
+		}
+		return nil
+	})
+
+	if err != nil && !started {
+		writeServiceError(w, err)
+		return
+	}
+	if !started {
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		_ = enc.WriteItem(w, ErrorResponse{Error: "export_interrupted", Details: err.Error()})
+	}
+	_ = rc.Flush()
+}
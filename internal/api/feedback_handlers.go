@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+const (
+	defaultRatingReportWindow = 90 * 24 * time.Hour
+	defaultFeedbackListLimit  = 100
+)
+
+// submitFeedbackHandler records the patient's rating of an appointment they
+// attended
+func submitFeedbackHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		var req SubmitFeedbackRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		f, err := svc.SubmitFeedback(r.Context(), id, req.Rating, req.Comment)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, toFeedbackResponse(f))
+	}
+}
+
+func getFeedbackHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		f, err := svc.GetFeedback(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toFeedbackResponse(f))
+	}
+}
+
+// clinicianRatingReportHandler aggregates ratings by clinician, looking back
+// ?since (a duration, default 90 days), for clinicians with at least
+// ?min_count ratings
+func clinicianRatingReportHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window := defaultRatingReportWindow
+		if v := r.URL.Query().Get("since"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				writeError(w, http.StatusBadRequest, "invalid_since", "since must be a positive duration such as 720h")
+				return
+			}
+			window = d
+		}
+
+		minCount := 1
+		if v := r.URL.Query().Get("min_count"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				writeError(w, http.StatusBadRequest, "invalid_min_count", "min_count must be a positive integer")
+				return
+			}
+			minCount = n
+		}
+
+		since := svc.Now().Add(-window)
+		ratings, err := svc.ClinicianRatings(r.Context(), since, minCount)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := ClinicianRatingReportResponse{
+			Since:      since,
+			Clinicians: make([]ClinicianRatingResponse, 0, len(ratings)),
+		}
+		for _, cr := range ratings {
+			resp.Clinicians = append(resp.Clinicians, ClinicianRatingResponse{
+				ClinicianID:   cr.ClinicianID,
+				Name:          cr.Name,
+				Specialty:     cr.Specialty,
+				Count:         cr.Count,
+				AverageRating: math.Round(cr.Average*100) / 100,
+				Ratings:       cr.Ratings,
+			})
+		}
+		resp.Count = len(resp.Clinicians)
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// clinicianFeedbackHandler lists the newest feedback given for the
+// clinician's appointments
+func clinicianFeedbackHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_clinician_id", "id must be a valid UUID")
+			return
+		}
+
+		limit := defaultFeedbackListLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > appointment.MaxClinicianFeedback {
+				writeError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and 1000")
+				return
+			}
+			limit = n
+		}
+
+		feedback, err := svc.ClinicianFeedback(r.Context(), id, limit)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := FeedbackListResponse{Feedback: make([]FeedbackResponse, 0, len(feedback))}
+		for i := range feedback {
+			resp.Feedback = append(resp.Feedback, toFeedbackResponse(&feedback[i]))
+		}
+		resp.Count = len(resp.Feedback)
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func toFeedbackResponse(f *appointment.Feedback) FeedbackResponse {
+	return FeedbackResponse{
+		AppointmentID: f.AppointmentID,
+		ClinicianID:   f.ClinicianID,
+		Rating:        f.Rating,
+		Comment:       f.Comment,
+		CreatedAt:     f.CreatedAt,
+	}
+}
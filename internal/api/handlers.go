@@ -1,20 +1,25 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
 	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
-	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
 )
 
-func createAppointmentHandler(svc *appointment.Service) http.HandlerFunc {
+func createAppointmentHandler(svc *appointment.Service, contention ContentionReport) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		r = queueTicketRequest(r)
 		var req CreateAppointmentRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
@@ -33,20 +38,54 @@ func createAppointmentHandler(svc *appointment.Service) http.HandlerFunc {
 			return
 		}
 
+		if (req.SlotCount != 0 && req.SlotCount != 1) || len(req.Resources) > 0 || req.Reason != nil {
+			booking := appointment.BookingRequest{
+				SlotID:    slotID,
+				PatientID: patientID,
+				SlotCount: max(req.SlotCount, 1),
+				Resources: toResourceRequirements(req.Resources),
+				Reason:    req.Reason,
+			}
+
+			booked, err := svc.Book(r.Context(), booking)
+			if err != nil {
+				if writeQueuedError(w, err) {
+					return
+				}
+				if errors.Is(err, appointment.ErrSlotBeingBooked) {
+					setSlotConflicts(w, r, contention, slotID)
+				}
+				writeServiceError(w, err)
+				return
+			}
+
+			resp := toAppointmentResponse(&booked.Appointment, svc.Now())
+			if len(booked.Slots) > 1 {
+				resp.Span = toSpanResponse(booked.Slots)
+			}
+			resp.Resources = toStaffResourceResponses(booked.Resources)
+			resp.Reason = booked.Reason
+
+			w.Header().Set("ETag", appointmentETag(booked.Status))
+			writeJSON(w, http.StatusCreated, resp)
+			return
+		}
+
 		appt, err := svc.CreateAppointment(r.Context(), slotID, patientID)
 		if err != nil {
-			handleCreateError(w, err)
+			if writeQueuedError(w, err) {
+				return
+			}
+			if errors.Is(err, appointment.ErrSlotBeingBooked) {
+				setSlotConflicts(w, r, contention, slotID)
+			}
+			writeServiceError(w, err)
 			return
 		}
 
-		resp := AppointmentResponse{
-			ID:        appt.ID,
-			SlotID:    appt.SlotID,
-			PatientID: appt.PatientID,
-			Status:    string(appt.Status),
-			ExpiresAt: appt.ExpiresAt,
-		}
+		resp := toAppointmentResponse(appt, svc.Now())
 
+		w.Header().Set("ETag", appointmentETag(appt.Status))
 		writeJSON(w, http.StatusCreated, resp)
 	}
 }
@@ -60,55 +99,279 @@ func confirmAppointmentHandler(svc *appointment.Service) http.HandlerFunc {
 			return
 		}
 
-		appt, err := svc.ConfirmAppointment(r.Context(), id)
+		expected, ok, err := confirmPreconditions(r)
 		if err != nil {
-			handleConfirmError(w, err)
+			writeError(w, http.StatusBadRequest, "invalid_precondition", err.Error())
+			return
+		}
+		if !ok {
+			writeServiceError(w, appointment.ErrPreconditionFailed)
 			return
 		}
 
-		resp := AppointmentResponse{
-			ID:        appt.ID,
-			SlotID:    appt.SlotID,
-			PatientID: appt.PatientID,
-			Status:    string(appt.Status),
-			ExpiresAt: appt.ExpiresAt,
+		appt, err := svc.ConfirmAppointment(r.Context(), id, expected...)
+		if err != nil {
+			writeServiceError(w, err)
+			return
 		}
 
+		resp := toAppointmentResponse(appt, svc.Now())
+
+		w.Header().Set("ETag", appointmentETag(appt.Status))
 		writeJSON(w, http.StatusOK, resp)
 	}
 }
 
-func handleCreateError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, appointment.ErrPatientNotFound):
-		writeError(w, http.StatusNotFound, "patient_not_found", err.Error())
-	case errors.Is(err, appointment.ErrSlotNotFound):
-		writeError(w, http.StatusNotFound, "slot_not_found", err.Error())
-	case errors.Is(err, appointment.ErrSlotNotOpen):
-		writeError(w, http.StatusConflict, "slot_not_open", err.Error())
-	case errors.Is(err, appointment.ErrSlotAlreadyBooked):
-		writeError(w, http.StatusConflict, "slot_already_booked", err.Error())
-	case errors.Is(err, appointment.ErrSlotBeingBooked),
-		errors.Is(err, redisclient.ErrLockNotAcquired):
-		writeError(w, http.StatusConflict, "slot_being_booked", "slot is currently being booked, please retry shortly")
-	default:
-		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+// reviewAppointmentHandler approves or rejects a booking awaiting approval
+// with decide, e.g. svc.ApproveAppointment. The body is optional.
+func reviewAppointmentHandler(svc *appointment.Service, decide func(ctx context.Context, id uuid.UUID, reviewer, note string) (*appointment.Appointment, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		var req ReviewAppointmentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		appt, err := decide(r.Context(), id, req.Reviewer, req.Note)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("ETag", appointmentETag(appt.Status))
+		writeJSON(w, http.StatusOK, toAppointmentResponse(appt, svc.Now()))
 	}
 }
 
-func handleConfirmError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, appointment.ErrAppointmentNotFound):
-		writeError(w, http.StatusNotFound, "appointment_not_found", err.Error())
-	case errors.Is(err, appointment.ErrAppointmentExpiredState):
-		writeError(w, http.StatusConflict, "appointment_expired", err.Error())
-	case errors.Is(err, appointment.ErrInvalidStatusTransition):
-		writeError(w, http.StatusConflict, "invalid_status_transition", err.Error())
-	default:
-		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+// attendanceHandler records whether the patient of a confirmed appointment
+// came with record, e.g. svc.CompleteAppointment. The body is optional.
+func attendanceHandler(svc *appointment.Service, record func(ctx context.Context, id uuid.UUID, recordedBy, note string) (*appointment.Appointment, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		var req AttendanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		appt, err := record(r.Context(), id, req.RecordedBy, req.Note)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("ETag", appointmentETag(appt.Status))
+		writeJSON(w, http.StatusOK, toAppointmentResponse(appt, svc.Now()))
 	}
 }
 
+// checkInAppointmentHandler checks in the patient of a confirmed
+// appointment, e.g. from a front desk or a kiosk
+func checkInAppointmentHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		checkIn, err := svc.CheckInAppointment(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("ETag", appointmentETag(checkIn.Status))
+		writeJSON(w, http.StatusOK, CheckInResponse{
+			AppointmentResponse: toAppointmentResponse(&checkIn.Appointment, svc.Now()),
+			CheckedInAt:         checkIn.CheckedInAt.UTC(),
+		})
+	}
+}
+
+func toAppointmentResponse(appt *appointment.Appointment, now time.Time) AppointmentResponse {
+	return AppointmentResponse{
+		ID:                 appt.ID,
+		SlotID:             appt.SlotID,
+		PatientID:          appt.PatientID,
+		Status:             string(appt.Status),
+		ExpiresAt:          appt.ExpiresAt,
+		SecondsUntilExpiry: secondsUntilExpiry(appt, now),
+		ServerTime:         now.UTC(),
+	}
+}
+
+func toSpanResponse(slots []appointment.AppointmentSlot) *SpanResponse {
+	booking := appointment.Booking{Slots: slots}
+	span := &SpanResponse{
+		StartTime: booking.StartTime(),
+		EndTime:   booking.EndTime(),
+		SlotIDs:   make([]uuid.UUID, len(slots)),
+	}
+	for i, slot := range slots {
+		span.SlotIDs[i] = slot.ID
+	}
+	return span
+}
+
+func toResourceRequirements(reqs []ResourceRequirementRequest) []appointment.ResourceRequirement {
+	var out []appointment.ResourceRequirement
+	for _, res := range reqs {
+		out = append(out, appointment.ResourceRequirement{
+			Kind:     appointment.ResourceKind(res.Kind),
+			Language: res.Language,
+		})
+	}
+	return out
+}
+
+func toStaffResourceResponses(staff []appointment.StaffResource) []StaffResourceResponse {
+	if len(staff) == 0 {
+		return nil
+	}
+	resp := make([]StaffResourceResponse, len(staff))
+	for i, res := range staff {
+		resp[i] = StaffResourceResponse{
+			ID:       res.ID,
+			Kind:     string(res.Kind),
+			Language: res.Language,
+			Name:     res.Name,
+		}
+	}
+	return resp
+}
+
+// secondsUntilExpiry rounds down so a client countdown never outlasts the hold
+func secondsUntilExpiry(appt *appointment.Appointment, now time.Time) *int64 {
+	remaining, ok := appt.HoldRemaining(now)
+	if !ok {
+		return nil
+	}
+	secs := int64(remaining / time.Second)
+	return &secs
+}
+
+// confirmPreconditions reads the allowed current statuses from
+// ?expected_status= and If-Match. An appointment's ETag is its quoted status,
+// which identifies its version because a status is never re-entered. When
+// both are given the status must satisfy both; ok is false when no status
+// can, e.g. If-Match lists only weak or unknown tags.
+func confirmPreconditions(r *http.Request) (expected []appointment.AppointmentStatus, ok bool, err error) {
+	var fromQuery, fromHeader []appointment.AppointmentStatus
+
+	if raw := r.URL.Query().Get("expected_status"); raw != "" {
+		st := appointment.AppointmentStatus(raw)
+		if !st.Valid() {
+			return nil, false, fmt.Errorf("unknown expected_status %q", raw)
+		}
+		fromQuery = []appointment.AppointmentStatus{st}
+	}
+
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return fromQuery, true, nil
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		// If-Match uses strong comparison, so weak tags never match
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		st := appointment.AppointmentStatus(strings.Trim(tag, `"`))
+		if st.Valid() && (fromQuery == nil || st == fromQuery[0]) {
+			fromHeader = append(fromHeader, st)
+		}
+	}
+	return fromHeader, len(fromHeader) > 0, nil
+}
+
+// appointmentETag is the quoted status, see confirmPreconditions
+func appointmentETag(status appointment.AppointmentStatus) string {
+	return `"` + string(status) + `"`
+}
+
+// detailProjection is what a detail response includes, from ?fields
+type detailProjection struct {
+	related appointment.DetailFields
+	audit   bool // created_at and updated_at
+}
+
+var fullProjection = detailProjection{related: appointment.AllDetailFields, audit: true}
+
+// parseFields reads ?fields=slot,patient,clinician,audit. Without it the
+// full response is returned; the appointment's id, status and hold fields
+// are always included.
+func parseFields(r *http.Request) (detailProjection, error) {
+	raw := r.URL.Query().Get("fields")
+	if raw == "" {
+		return fullProjection, nil
+	}
+
+	var p detailProjection
+	for _, name := range strings.Split(raw, ",") {
+		switch strings.TrimSpace(name) {
+		case "slot":
+			p.related.Slot = true
+		case "patient":
+			p.related.Patient = true
+		case "clinician":
+			p.related.Clinician = true
+		case "audit":
+			p.audit = true
+		case "":
+		default:
+			return detailProjection{}, fmt.Errorf("unknown field %q, expected slot, patient, clinician or audit", name)
+		}
+	}
+	return p, nil
+}
+
+// parseListProjection picks the list response shape. ?include=slot,clinician
+// returns details with those entities; ?fields projects as on the detail
+// endpoint. With neither, lean is true and only the appointment rows are
+// read.
+func parseListProjection(r *http.Request) (p detailProjection, lean bool, err error) {
+	q := r.URL.Query()
+	raw := q.Get("include")
+	if raw == "" {
+		if q.Get("fields") == "" {
+			return detailProjection{}, true, nil
+		}
+		p, err = parseFields(r)
+		return p, false, err
+	}
+	if q.Get("fields") != "" {
+		return detailProjection{}, false, errors.New("include and fields cannot be combined")
+	}
+
+	p.audit = true
+	for _, name := range strings.Split(raw, ",") {
+		switch strings.TrimSpace(name) {
+		case "slot":
+			p.related.Slot = true
+		case "patient":
+			p.related.Patient = true
+		case "clinician":
+			p.related.Clinician = true
+		case "":
+		default:
+			return detailProjection{}, false, fmt.Errorf("unknown include %q, expected slot, patient or clinician", name)
+		}
+	}
+	return p, false, nil
+}
+
 func getAppointmentHandler(svc *appointment.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := chi.URLParam(r, "id")
@@ -118,13 +381,108 @@ func getAppointmentHandler(svc *appointment.Service) http.HandlerFunc {
 			return
 		}
 
-		detail, err := svc.GetAppointment(r.Context(), id)
+		fields, err := parseFields(r)
 		if err != nil {
-			handleGetError(w, err)
+			writeError(w, http.StatusBadRequest, "invalid_fields", err.Error())
 			return
 		}
 
-		resp := toAppointmentDetailResponse(detail)
+		detail, err := svc.GetAppointment(r.Context(), id, fields.related)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if detail.Patient != nil && !recordPIIAccess(w, r, svc, detail.Patient.ID) {
+			return
+		}
+
+		resp := toAppointmentDetailResponse(detail, svc.Now(), fields)
+		w.Header().Set("ETag", appointmentETag(detail.Status))
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// batchGetAppointmentsHandler returns the details of up to MaxBatchGet
+// appointments in one round trip, for dashboards
+func batchGetAppointmentsHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fields, err := parseFields(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_fields", err.Error())
+			return
+		}
+
+		var req BatchGetAppointmentsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		if len(req.IDs) == 0 || len(req.IDs) > appointment.MaxBatchGet {
+			writeError(w, http.StatusBadRequest, "invalid_ids", fmt.Sprintf("ids must contain between 1 and %d appointment ids", appointment.MaxBatchGet))
+			return
+		}
+		ids := make([]uuid.UUID, len(req.IDs))
+		for i, s := range req.IDs {
+			id, err := uuid.Parse(s)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_ids", fmt.Sprintf("ids[%d] must be a valid UUID", i))
+				return
+			}
+			ids[i] = id
+		}
+
+		details, notFound, err := svc.GetAppointments(r.Context(), ids, fields.related)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if !recordPIIAccess(w, r, svc, shownPatients(details)...) {
+			return
+		}
+
+		resp := BatchGetAppointmentsResponse{
+			Appointments: make([]AppointmentDetailResponse, len(details)),
+			NotFound:     make([]uuid.UUID, 0, len(notFound)),
+		}
+		now := svc.Now()
+		for i := range details {
+			resp.Appointments[i] = toAppointmentDetailResponse(&details[i], now, fields)
+		}
+		resp.NotFound = append(resp.NotFound, notFound...)
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// getAppointmentTTLHandler serves the hold countdown from a single-row read,
+// for clients that poll while the patient completes checkout
+func getAppointmentTTLHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		appt, err := svc.GetAppointmentHold(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		now := svc.Now()
+		resp := AppointmentTTLResponse{
+			ID:                 appt.ID,
+			Status:             string(appt.Status),
+			ExpiresAt:          appt.ExpiresAt,
+			SecondsUntilExpiry: secondsUntilExpiry(appt, now),
+			ServerTime:         now.UTC(),
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("ETag", appointmentETag(appt.Status))
 		writeJSON(w, http.StatusOK, resp)
 	}
 }
@@ -132,20 +490,17 @@ func getAppointmentHandler(svc *appointment.Service) http.HandlerFunc {
 func listAppointmentsHandler(svc *appointment.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse query parameters
-		patientIDStr := r.URL.Query().Get("patient_id")
-		slotIDStr := r.URL.Query().Get("slot_id")
-		limitStr := r.URL.Query().Get("limit")
 		offsetStr := r.URL.Query().Get("offset")
 
-		// Parse limit and offset
-		limit := 20
-		if limitStr != "" {
-			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-				limit = l
-			}
+		q, ok := parseAppointmentSearch(w, r)
+		if !ok {
+			return
 		}
-		if limit > 100 {
-			limit = 100
+
+		fields, lean, err := parseListProjection(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_projection", err.Error())
+			return
 		}
 
 		offset := 0
@@ -156,88 +511,734 @@ func listAppointmentsHandler(svc *appointment.Service) http.HandlerFunc {
 		}
 
 		var appointments []appointment.AppointmentDetail
-		var err error
+		var nextPageToken string
 
-		// Route to appropriate service method based on query params
-		if patientIDStr != "" {
-			patientID, parseErr := uuid.Parse(patientIDStr)
-			if parseErr != nil {
-				writeError(w, http.StatusBadRequest, "invalid_patient_id", "patient_id must be a valid UUID")
-				return
+		// A patient or a slot alone keeps its own listing, which pages by
+		// offset too and returns every booking of a slot at once; any other
+		// combination of filters is a search
+		switch {
+		case q.PatientID != nil && onlyFilter(q):
+			var page *appointment.AppointmentPage
+			page, err = svc.ListAppointmentsByPatient(r.Context(), *q.PatientID, appointment.PageRequest{
+				Limit:  q.Limit,
+				Offset: offset,
+				Token:  q.Token,
+				Sort:   q.Sort,
+			}, fields.related)
+			if err == nil {
+				appointments = page.Appointments
+				nextPageToken = page.NextToken
 			}
-			appointments, err = svc.ListAppointmentsByPatient(r.Context(), patientID, limit, offset)
-		} else if slotIDStr != "" {
-			slotID, parseErr := uuid.Parse(slotIDStr)
-			if parseErr != nil {
-				writeError(w, http.StatusBadRequest, "invalid_slot_id", "slot_id must be a valid UUID")
-				return
+		case q.SlotID != nil && onlyFilter(q):
+			appointments, err = svc.ListAppointmentsBySlot(r.Context(), *q.SlotID, q.Sort, fields.related)
+		default:
+			var page *appointment.AppointmentPage
+			page, err = svc.SearchAppointments(r.Context(), q, fields.related)
+			if err == nil {
+				appointments = page.Appointments
+				nextPageToken = page.NextToken
 			}
-			appointments, err = svc.ListAppointmentsBySlot(r.Context(), slotID)
-		} else {
-			writeError(w, http.StatusBadRequest, "missing_filter", "must provide either patient_id or slot_id query parameter")
+		}
+
+		if err != nil {
+			writeServiceError(w, err)
 			return
 		}
 
+		writeAppointmentList(w, r, svc, appointments, nextPageToken, fields, lean)
+	}
+}
+
+// searchAppointmentsHandler serves GET /appointments/search: the appointments
+// matching every filter given, paged by page_token only
+func searchAppointmentsHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, ok := parseAppointmentSearch(w, r)
+		if !ok {
+			return
+		}
+
+		fields, lean, err := parseListProjection(r)
 		if err != nil {
-			if errors.Is(err, appointment.ErrAppointmentNotFound) ||
-				errors.Is(err, appointment.ErrPatientNotFound) ||
-				errors.Is(err, appointment.ErrSlotNotFound) {
-				writeError(w, http.StatusNotFound, "not_found", err.Error())
-				return
-			}
-			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			writeError(w, http.StatusBadRequest, "invalid_projection", err.Error())
 			return
 		}
 
-		resp := AppointmentListResponse{
-			Appointments: make([]AppointmentDetailResponse, len(appointments)),
+		page, err := svc.SearchAppointments(r.Context(), q, fields.related)
+		if err != nil {
+			writeServiceError(w, err)
+			return
 		}
-		for i, appt := range appointments {
-			resp.Appointments[i] = toAppointmentDetailResponse(&appt)
+
+		writeAppointmentList(w, r, svc, page.Appointments, page.NextToken, fields, lean)
+	}
+}
+
+// parseAppointmentSearch reads the filters of the appointment list and
+// search endpoints. On a malformed parameter it writes the error response
+// and returns false.
+func parseAppointmentSearch(w http.ResponseWriter, r *http.Request) (appointment.AppointmentSearch, bool) {
+	query := r.URL.Query()
+	q := appointment.AppointmentSearch{
+		PatientName: query.Get("patient_name"),
+		Specialty:   query.Get("specialty"),
+		Token:       query.Get("page_token"),
+	}
+
+	ids := []struct {
+		param string
+		dst   **uuid.UUID
+	}{
+		{"patient_id", &q.PatientID},
+		{"slot_id", &q.SlotID},
+		{"clinician_id", &q.ClinicianID},
+	}
+	for _, id := range ids {
+		raw := query.Get(id.param)
+		if raw == "" {
+			continue
 		}
-		resp.Total = len(appointments)
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_"+id.param, id.param+" must be a valid UUID")
+			return q, false
+		}
+		*id.dst = &parsed
+	}
 
-		writeJSON(w, http.StatusOK, resp)
+	if raw := query.Get("status"); raw != "" {
+		for _, st := range strings.Split(raw, ",") {
+			q.Statuses = append(q.Statuses, appointment.AppointmentStatus(strings.TrimSpace(st)))
+		}
+	}
+
+	times := []struct {
+		param string
+		dst   **time.Time
+	}{
+		{"from", &q.From},
+		{"to", &q.To},
+	}
+	for _, t := range times {
+		raw := query.Get(t.param)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_"+t.param, t.param+" must be an RFC 3339 timestamp")
+			return q, false
+		}
+		*t.dst = &parsed
 	}
+
+	if raw := query.Get("include_deactivated"); raw != "" {
+		include, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_include_deactivated", "include_deactivated must be true or false")
+			return q, false
+		}
+		q.IncludeDeactivated = include
+	}
+
+	sort, err := appointment.ParseListSort(query.Get("sort"), query.Get("order"))
+	if err != nil {
+		writeServiceError(w, err)
+		return q, false
+	}
+	q.Sort = sort
+
+	q.Limit = 20
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		q.Limit = min(l, 100)
+	}
+	return q, true
+}
+
+// onlyFilter reports whether q filters on at most its patient or its slot
+func onlyFilter(q appointment.AppointmentSearch) bool {
+	return (q.PatientID == nil || q.SlotID == nil) && q.PatientName == "" && q.ClinicianID == nil &&
+		q.Specialty == "" && len(q.Statuses) == 0 && q.From == nil && q.To == nil
 }
 
-func handleGetError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, appointment.ErrAppointmentNotFound):
-		writeError(w, http.StatusNotFound, "appointment_not_found", err.Error())
-	default:
-		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+// writeAppointmentList writes a page of appointments, as summaries when
+// lean and otherwise with the related entities in fields, recording the
+// patients shown
+func writeAppointmentList(w http.ResponseWriter, r *http.Request, svc *appointment.Service, appointments []appointment.AppointmentDetail, nextPageToken string, fields detailProjection, lean bool) {
+	now := svc.Now()
+	if lean {
+		resp := AppointmentSummaryListResponse{
+			Appointments:  make([]AppointmentResponse, len(appointments)),
+			Total:         len(appointments),
+			NextPageToken: nextPageToken,
+		}
+		for i := range appointments {
+			resp.Appointments[i] = toAppointmentResponse(&appointments[i].Appointment, now)
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	if !recordPIIAccess(w, r, svc, shownPatients(appointments)...) {
+		return
+	}
+
+	resp := AppointmentListResponse{
+		Appointments: make([]AppointmentDetailResponse, len(appointments)),
+	}
+	for i, appt := range appointments {
+		resp.Appointments[i] = toAppointmentDetailResponse(&appt, now, fields)
 	}
+	resp.Total = len(appointments)
+	resp.NextPageToken = nextPageToken
+
+	writeJSON(w, http.StatusOK, resp)
 }
 
-func toAppointmentDetailResponse(detail *appointment.AppointmentDetail) AppointmentDetailResponse {
+func toAppointmentDetailResponse(detail *appointment.AppointmentDetail, now time.Time, fields detailProjection) AppointmentDetailResponse {
 	resp := AppointmentDetailResponse{
-		ID:        detail.ID,
-		Status:    string(detail.Status),
-		CreatedAt: detail.CreatedAt,
-		UpdatedAt: detail.UpdatedAt,
-		ExpiresAt: detail.ExpiresAt,
+		ID:                 detail.ID,
+		Status:             string(detail.Status),
+		ExpiresAt:          detail.ExpiresAt,
+		SecondsUntilExpiry: secondsUntilExpiry(&detail.Appointment, now),
+		CheckedInAt:        detail.CheckedInAt,
+		Reason:             detail.Reason,
+		ServerTime:         now.UTC(),
+	}
+
+	if fields.audit {
+		resp.CreatedAt = &detail.CreatedAt
+		resp.UpdatedAt = &detail.UpdatedAt
 	}
 
 	if detail.Slot != nil {
-		resp.Slot.ID = detail.Slot.ID
-		resp.Slot.StartTime = detail.Slot.StartTime
-		resp.Slot.EndTime = detail.Slot.EndTime
-		resp.Slot.Status = string(detail.Slot.Status)
-		resp.Slot.Capacity = detail.Slot.Capacity
+		resp.Slot = &SlotSummaryResponse{
+			ID:        detail.Slot.ID,
+			StartTime: detail.Slot.StartTime,
+			EndTime:   detail.Slot.EndTime,
+			Status:    string(detail.Slot.Status),
+			Capacity:  detail.Slot.Capacity,
+			SlotType:  detail.Slot.SlotType,
+		}
+		resp.Slot.Price = toOptionalPriceResponse(detail.Price)
+	}
+	if len(detail.Span) > 0 {
+		resp.Span = toSpanResponse(detail.Span)
 	}
+	resp.Resources = toStaffResourceResponses(detail.Resources)
 
 	if detail.Patient != nil {
-		resp.Patient.ID = detail.Patient.ID
-		resp.Patient.Name = detail.Patient.Name
-		resp.Patient.Email = detail.Patient.Email
+		resp.Patient = &PatientSummaryResponse{
+			ID:          detail.Patient.ID,
+			Name:        detail.Patient.Name,
+			Email:       detail.Patient.Email,
+			Deactivated: detail.Patient.Deactivated(),
+		}
 	}
 
 	if detail.Clinician != nil {
-		resp.Clinician.ID = detail.Clinician.ID
-		resp.Clinician.Name = detail.Clinician.Name
-		resp.Clinician.Specialty = detail.Clinician.Specialty
+		resp.Clinician = &ClinicianSummaryResponse{
+			ID:        detail.Clinician.ID,
+			Name:      detail.Clinician.Name,
+			Specialty: detail.Clinician.Specialty,
+		}
+	}
+	resp.Intake = string(detail.Intake)
+	for _, n := range detail.Notes {
+		resp.Notes = append(resp.Notes, toNoteResponse(&n))
 	}
 
 	return resp
 }
+
+// getAvailabilityVersionHandler lets clients and edge caches revalidate
+// cached availability with If-None-Match instead of refetching slots
+func getAvailabilityVersionHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_clinician_id", "id must be a valid UUID")
+			return
+		}
+
+		version, err := svc.GetAvailabilityVersion(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		etag := `"` + strconv.FormatInt(version, 10) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if etagListContains(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, AvailabilityVersionResponse{
+			ClinicianID: id,
+			Version:     version,
+		})
+	}
+}
+
+// getAvailabilityCalendarHandler returns per-day slot counts for one month,
+// enough to render a month picker in one call. Days are taken in ?tz
+// (default UTC), so a slot late in the evening lands on the patient's date.
+func getAvailabilityCalendarHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_clinician_id", "id must be a valid UUID")
+			return
+		}
+
+		month, err := time.Parse("2006-01", r.URL.Query().Get("month"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_month", "month must be in YYYY-MM format")
+			return
+		}
+
+		loc := time.UTC
+		if tz := r.URL.Query().Get("tz"); tz != "" {
+			loc, err = time.LoadLocation(tz)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_tz", "tz must be an IANA time zone such as Europe/Berlin")
+				return
+			}
+		}
+
+		days, err := svc.GetAvailabilityCalendar(r.Context(), id, month, loc)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := AvailabilityCalendarResponse{
+			ClinicianID: id,
+			Month:       month.Format("2006-01"),
+			TZ:          loc.String(),
+			Days:        make([]CalendarDayResponse, len(days)),
+		}
+		for i, d := range days {
+			resp.Days[i] = CalendarDayResponse{
+				Date:   d.Date.Format(time.DateOnly),
+				Slots:  d.Slots,
+				Open:   d.Open,
+				Booked: d.Booked,
+			}
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// getPatientTimelineHandler returns the events of all of a patient's
+// appointments oldest first, for the patient profile. ?limit keeps the
+// newest events (default 100, max 500).
+func getPatientTimelineHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "id must be a valid UUID")
+			return
+		}
+
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 500 {
+				writeError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and 500")
+				return
+			}
+			limit = n
+		}
+
+		timeline, err := svc.GetPatientTimeline(r.Context(), id, limit)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if !recordPIIAccess(w, r, svc, id) {
+			return
+		}
+
+		resp := PatientTimelineResponse{
+			PatientID: id,
+			Entries:   make([]TimelineEntryResponse, len(timeline.Entries)),
+			Truncated: timeline.Truncated,
+		}
+		for i, e := range timeline.Entries {
+			resp.Entries[i] = TimelineEntryResponse{
+				EventID:           e.EventID,
+				EventType:         e.EventType,
+				Stage:             appointment.TimelineStage(e.EventType),
+				At:                e.CreatedAt,
+				AppointmentID:     e.AppointmentID,
+				AppointmentStatus: string(e.AppointmentStatus),
+				SlotID:            e.SlotID,
+				SlotStart:         e.SlotStart,
+				SlotEnd:           e.SlotEnd,
+			}
+			if json.Valid(e.Payload) {
+				resp.Entries[i].Details = e.Payload
+			}
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// cancelPatientAppointmentsHandler cancels all of a patient's appointments
+// in the statuses listed in ?status, or every active one, in one
+// transaction, e.g. when they close their account
+func cancelPatientAppointmentsHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "id must be a valid UUID")
+			return
+		}
+
+		var req CancelPatientAppointmentsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		if req.Reason == "" {
+			req.Reason = "patient_request"
+		}
+
+		var statuses []appointment.AppointmentStatus
+		if raw := r.URL.Query().Get("status"); raw != "" {
+			for _, st := range strings.Split(raw, ",") {
+				statuses = append(statuses, appointment.AppointmentStatus(strings.TrimSpace(st)))
+			}
+		}
+
+		res, err := svc.CancelPatientAppointments(r.Context(), id, statuses, req.Reason, map[string]any{"via": "cancel_all"})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toPatientCancellationResponse(res))
+	}
+}
+
+func toPatientCancellationResponse(res *appointment.PatientCancellation) *PatientCancellationResponse {
+	resp := &PatientCancellationResponse{
+		PatientID:    res.PatientID,
+		Cancelled:    len(res.Cancelled),
+		ByStatus:     make(map[string]int, len(res.ByStatus)),
+		Appointments: make([]CancelledAppointmentResponse, len(res.Cancelled)),
+	}
+	for st, n := range res.ByStatus {
+		resp.ByStatus[string(st)] = n
+	}
+	for i, c := range res.Cancelled {
+		resp.Appointments[i] = CancelledAppointmentResponse{
+			ID:             c.ID,
+			SlotID:         c.SlotID,
+			Status:         string(c.Status),
+			PreviousStatus: string(c.PreviousStatus),
+		}
+	}
+	return resp
+}
+
+// deactivatePatientHandler deactivates a patient's account, recording the
+// staff member or admin doing so, or "patient" when the request acts for
+// the patient themselves
+func deactivatePatientHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "id must be a valid UUID")
+			return
+		}
+
+		var req DeactivatePatientRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		by := "patient"
+		if actor, ok := actorFrom(r.Context()); ok {
+			by = actor.ID
+		}
+
+		res, err := svc.DeactivatePatient(r.Context(), id, by, req.Reason)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		resp := toPatientStatusResponse(res.Patient)
+		resp.CancelledHolds = toPatientCancellationResponse(res.CancelledHolds)
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func reactivatePatientHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "id must be a valid UUID")
+			return
+		}
+
+		p, err := svc.ReactivatePatient(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toPatientStatusResponse(p))
+	}
+}
+
+func toPatientStatusResponse(p *appointment.Patient) PatientStatusResponse {
+	return PatientStatusResponse{
+		PatientID:          p.ID,
+		Deactivated:        p.Deactivated(),
+		DeactivatedAt:      p.DeactivatedAt,
+		DeactivatedBy:      p.DeactivatedBy,
+		DeactivationReason: p.DeactivationReason,
+	}
+}
+
+// etagListContains reports whether an If-None-Match value matches etag,
+// using the weak comparison that header calls for
+func etagListContains(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// searchSlotsHandler serves GET /slots: the slots starting in [from, to)
+// with the status given, open when it is not, narrowed by clinic,
+// clinician and specialty. Open slots are only the ones still bookable.
+func searchSlotsHandler(svc *appointment.Service, contention ContentionReport) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		q := appointment.SlotQuery{
+			Specialty: query.Get("specialty"),
+			Status:    appointment.SlotStatus(query.Get("status")),
+			Limit:     50,
+			Token:     query.Get("page_token"),
+		}
+
+		ids := []struct {
+			param string
+			dst   **uuid.UUID
+		}{
+			{"clinic_id", &q.ClinicID},
+			{"clinician_id", &q.ClinicianID},
+		}
+		for _, id := range ids {
+			raw := query.Get(id.param)
+			if raw == "" {
+				continue
+			}
+			parsed, err := uuid.Parse(raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_"+id.param, id.param+" must be a valid UUID")
+				return
+			}
+			*id.dst = &parsed
+		}
+
+		var err error
+		q.From, err = time.Parse(time.RFC3339, query.Get("from"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_from", "from must be an RFC 3339 timestamp")
+			return
+		}
+		q.To, err = time.Parse(time.RFC3339, query.Get("to"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_to", "to must be an RFC 3339 timestamp")
+			return
+		}
+		if v := query.Get("limit"); v != "" {
+			q.Limit, err = strconv.Atoi(v)
+			if err != nil || q.Limit < 1 || q.Limit > appointment.MaxOpenSlots {
+				writeError(w, http.StatusBadRequest, "invalid_limit",
+					"limit must be between 1 and "+strconv.Itoa(appointment.MaxOpenSlots))
+				return
+			}
+		}
+
+		res, err := svc.SearchSlots(r.Context(), q)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		slotIDs := make([]uuid.UUID, len(res.Slots))
+		for i, o := range res.Slots {
+			slotIDs[i] = o.ID
+		}
+		conflicts := recentConflicts(r.Context(), contention, slotIDs...)
+
+		resp := SlotSearchResponse{
+			Slots:         make([]SlotSearchItemResponse, len(res.Slots)),
+			Count:         len(res.Slots),
+			NextPageToken: res.NextToken,
+		}
+		for i, o := range res.Slots {
+			resp.Slots[i] = SlotSearchItemResponse{
+				ID:            o.ID,
+				ClinicianID:   o.PractitionerID,
+				ClinicianName: o.ClinicianName,
+				Specialty:     o.Specialty,
+				SlotType:      o.SlotType,
+				StartTime:     o.StartTime.UTC(),
+				EndTime:       o.EndTime.UTC(),
+				Status:        string(o.Status),
+				Capacity:      o.Capacity,
+				Remaining:     o.Remaining,
+				Price:         toOptionalPriceResponse(o.Price),
+				Conflicts:     conflicts[o.ID],
+			}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func getSlotQuoteHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_slot_id", "id must be a valid UUID")
+			return
+		}
+
+		quote, err := svc.QuoteSlot(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := SlotQuoteResponse{
+			SlotID:   quote.SlotID,
+			ClinicID: quote.ClinicID,
+			SlotType: quote.SlotType,
+			Price:    toPriceResponse(quote.Price),
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// reassignSlotHandler moves a slot and its appointments to the covering
+// clinician named by clinician_id
+func reassignSlotHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_slot_id", "id must be a valid UUID")
+			return
+		}
+		clinicianID, err := uuid.Parse(r.URL.Query().Get("clinician_id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_clinician_id", "clinician_id must be a valid UUID")
+			return
+		}
+
+		res, err := svc.ReassignSlot(r.Context(), id, clinicianID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := SlotReassignmentResponse{
+			SlotID:              res.Slot.ID,
+			ClinicianID:         res.Slot.PractitionerID,
+			PreviousClinicianID: res.PreviousClinicianID,
+			StartTime:           res.Slot.StartTime,
+			EndTime:             res.Slot.EndTime,
+			Appointments:        make([]ReassignedAppointmentResponse, len(res.Appointments)),
+		}
+		for i, a := range res.Appointments {
+			resp.Appointments[i] = ReassignedAppointmentResponse{ID: a.ID, PatientID: a.PatientID, Status: string(a.Status)}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// precheckBookingHandler runs the booking checks for a slot without holding
+// it, so a client can tell the patient early that it cannot be booked.
+// Failures are the ones POST /appointments would return.
+func precheckBookingHandler(svc *appointment.Service, contention ContentionReport) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slotID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_slot_id", "id must be a valid UUID")
+			return
+		}
+
+		var req PrecheckBookingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		patientID, err := uuid.Parse(req.PatientID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "patient_id must be a valid UUID")
+			return
+		}
+
+		check, err := svc.PrecheckBooking(r.Context(), appointment.BookingRequest{
+			SlotID:    slotID,
+			PatientID: patientID,
+			SlotCount: max(req.SlotCount, 1),
+			Resources: toResourceRequirements(req.Resources),
+		})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		// Conflicts show the patient's other appointments
+		if len(check.Conflicts) > 0 && !recordPIIAccess(w, r, svc, patientID) {
+			return
+		}
+
+		now := svc.Now()
+		resp := PrecheckBookingResponse{
+			SlotID:           slotID,
+			Span:             *toSpanResponse(check.Slots),
+			Remaining:        check.Remaining,
+			RequiresApproval: check.RequiresApproval,
+			Resources:        toStaffResourceResponses(check.Resources),
+			Conflicts:        make([]AppointmentResponse, len(check.Conflicts)),
+			SlotConflicts:    setSlotConflicts(w, r, contention, slotID),
+		}
+		for i := range check.Conflicts {
+			resp.Conflicts[i] = toAppointmentResponse(&check.Conflicts[i], now)
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// toOptionalPriceResponse is toPriceResponse of p, nil when p is
+func toOptionalPriceResponse(p *appointment.Price) *PriceResponse {
+	if p == nil {
+		return nil
+	}
+	price := toPriceResponse(*p)
+	return &price
+}
+
+func toPriceResponse(p appointment.Price) PriceResponse {
+	return PriceResponse{
+		Amount:      p.Decimal(),
+		AmountMinor: p.AmountMinor,
+		Currency:    p.Currency,
+	}
+}
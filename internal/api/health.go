@@ -2,24 +2,71 @@ package api
 
 import (
 	"context"
+	"database/sql"
 	"net/http"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/blob"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/region"
 )
 
+// DependencyCheck is one dependency probed by the readiness endpoint.
+// A failing critical dependency makes the service unready (503); a failing
+// non-critical one only marks it degraded.
+type DependencyCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+// PostgresCheck probes a pgx pool
+func PostgresCheck(pool *pgxpool.Pool) DependencyCheck {
+	return DependencyCheck{Name: "postgres", Critical: true, Check: pool.Ping}
+}
+
+// RedisCheck probes a Redis client
+func RedisCheck(client *redis.Client) DependencyCheck {
+	return DependencyCheck{
+		Name: "redis",
+		Check: func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		},
+	}
+}
+
+// SQLCheck probes a database/sql handle, e.g. the SQLite backend
+func SQLCheck(name string, db *sql.DB) DependencyCheck {
+	return DependencyCheck{Name: name, Critical: true, Check: db.PingContext}
+}
+
+// BlobCheck probes object storage. Bookings do not need it, so it only
+// marks the service degraded.
+func BlobCheck(store blob.Store) DependencyCheck {
+	return DependencyCheck{Name: "blob", Check: store.Ping}
+}
+
+// ReplicationCheck fails while a standby database lags more than the
+// controller allows, so a passive region stops taking reads that are too stale
+func ReplicationCheck(ctrl *region.Controller) DependencyCheck {
+	return DependencyCheck{Name: "replication", Critical: true, Check: ctrl.Check}
+}
+
 type HealthHandler struct {
-	pgPool    *pgxpool.Pool
-	redis     *redis.Client
-	env       string
-	version   string
+	checks  []DependencyCheck
+	region  *region.Controller
+	env     string
+	version string
 }
 
-func NewHealthHandler(pgPool *pgxpool.Pool, redis *redis.Client, env, version string) *HealthHandler {
+// NewHealthHandler builds the health endpoints. region is optional; when set
+// readiness also reports the region role and replication lag.
+func NewHealthHandler(checks []DependencyCheck, region *region.Controller, env, version string) *HealthHandler {
 	return &HealthHandler{
-		pgPool:  pgPool,
-		redis:   redis,
+		checks:  checks,
+		region:  region,
 		env:     env,
 		version: version,
 	}
@@ -32,10 +79,11 @@ type LivenessResponse struct {
 }
 
 type ReadinessResponse struct {
-	Status      string                 `json:"status"`
-	Version     string                 `json:"version,omitempty"`
-	Env         string                 `json:"env,omitempty"`
-	Dependencies map[string]string     `json:"dependencies"`
+	Status       string            `json:"status"`
+	Version      string            `json:"version,omitempty"`
+	Env          string            `json:"env,omitempty"`
+	Dependencies map[string]string `json:"dependencies"`
+	Region       *RegionResponse   `json:"region,omitempty"`
 }
 
 func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
@@ -54,39 +102,38 @@ func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
 	deps := make(map[string]string)
 	status := "ok"
 
-	// Check Postgres
-	pgCtx, pgCancel := context.WithTimeout(ctx, 1*time.Second)
-	err := h.pgPool.Ping(pgCtx)
-	pgCancel()
-	if err != nil {
-		deps["postgres"] = "down"
-		status = "error"
-	} else {
-		deps["postgres"] = "ok"
-	}
+	for _, dep := range h.checks {
+		depCtx, depCancel := context.WithTimeout(ctx, 1*time.Second)
+		err := dep.Check(depCtx)
+		depCancel()
 
-	// Check Redis
-	redisCtx, redisCancel := context.WithTimeout(ctx, 1*time.Second)
-	err = h.redis.Ping(redisCtx).Err()
-	redisCancel()
-	if err != nil {
-		deps["redis"] = "down"
-		if status == "ok" {
-			status = "degraded"
-		} else {
+		if err == nil {
+			deps[dep.Name] = "ok"
+			continue
+		}
+
+		deps[dep.Name] = "down"
+		if dep.Critical {
 			status = "error"
+		} else if status == "ok" {
+			status = "degraded"
 		}
-	} else {
-		deps["redis"] = "ok"
 	}
 
 	resp := ReadinessResponse{
-		Status:      status,
-		Version:     h.version,
-		Env:         h.env,
+		Status:       status,
+		Version:      h.version,
+		Env:          h.env,
 		Dependencies: deps,
 	}
 
+	if h.region != nil {
+		if st, err := h.region.Status(ctx); err == nil {
+			rr := toRegionResponse(st)
+			resp.Region = &rr
+		}
+	}
+
 	httpStatus := http.StatusOK
 	if status == "error" {
 		httpStatus = http.StatusServiceUnavailable
@@ -94,4 +141,3 @@ func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, httpStatus, resp)
 }
-
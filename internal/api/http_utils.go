@@ -1,14 +1,15 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
 )
 
+// writeJSON writes v with the request's ResponseEncoder
 func writeJSON(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(v)
+	encoderFor(w).WriteResponse(w, status, v)
 }
 
 func writeError(w http.ResponseWriter, status int, msg string, details string) {
@@ -17,3 +18,19 @@ func writeError(w http.ResponseWriter, status int, msg string, details string) {
 		Details: details,
 	})
 }
+
+// writeServiceError writes a domain error with its own status and code, and
+// anything else as a 500
+func writeServiceError(w http.ResponseWriter, err error) {
+	var domainErr *appointment.Error
+	if !errors.As(err, &domainErr) {
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+
+	writeJSON(w, domainErr.HTTPStatus, ErrorResponse{
+		Error:     domainErr.Code,
+		Details:   err.Error(),
+		Retryable: domainErr.Retryable,
+	})
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// getIntakeHandler returns the appointment's intake form with the answers
+// given so far. An appointment whose slot type has no template reports
+// not_required and no questions.
+func getIntakeHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		intake, err := svc.GetIntake(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if !recordAppointmentPIIAccess(w, r, svc, id) {
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, toIntakeResponse(intake))
+	}
+}
+
+// submitIntakeHandler merges answers into the appointment's intake form
+func submitIntakeHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		var req SubmitIntakeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON, answers must be strings")
+			return
+		}
+		if len(req.Answers) == 0 {
+			writeError(w, http.StatusBadRequest, "invalid_intake_answers", "answers is required")
+			return
+		}
+
+		// The response returns every answer given so far, so the access is
+		// recorded before the new ones are saved
+		if !recordAppointmentPIIAccess(w, r, svc, id) {
+			return
+		}
+
+		intake, err := svc.SubmitIntake(r.Context(), id, req.Answers)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toIntakeResponse(intake))
+	}
+}
+
+func listIntakeTemplatesHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templates, err := svc.ListIntakeTemplates(r.Context())
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := IntakeTemplateListResponse{Templates: make([]IntakeTemplateResponse, 0, len(templates))}
+		for i := range templates {
+			resp.Templates = append(resp.Templates, toIntakeTemplateResponse(&templates[i]))
+		}
+		resp.Count = len(resp.Templates)
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// putIntakeTemplateHandler creates or replaces the intake template of the
+// slot type in the path. Each replacement bumps the template's version.
+func putIntakeTemplateHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slotType, ok := slotTypeParam(w, r)
+		if !ok {
+			return
+		}
+
+		var req PutIntakeTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		template := appointment.IntakeTemplate{SlotType: slotType}
+		for _, q := range req.Questions {
+			template.Questions = append(template.Questions, appointment.IntakeQuestion{
+				ID:       q.ID,
+				Label:    q.Label,
+				Type:     appointment.IntakeQuestionType(q.Type),
+				Required: q.Required,
+				Options:  q.Options,
+			})
+		}
+
+		saved, err := svc.PutIntakeTemplate(r.Context(), template)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toIntakeTemplateResponse(saved))
+	}
+}
+
+func deleteIntakeTemplateHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slotType, ok := slotTypeParam(w, r)
+		if !ok {
+			return
+		}
+
+		if err := svc.DeleteIntakeTemplate(r.Context(), slotType); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func slotTypeParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	slotType, err := url.PathUnescape(chi.URLParam(r, "slot_type"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_intake_template", "slot type is not a valid path segment")
+		return "", false
+	}
+	return slotType, true
+}
+
+func toIntakeQuestionResponse(q appointment.IntakeQuestion) IntakeQuestionRequest {
+	return IntakeQuestionRequest{
+		ID:       q.ID,
+		Label:    q.Label,
+		Type:     string(q.Type),
+		Required: q.Required,
+		Options:  q.Options,
+	}
+}
+
+func toIntakeTemplateResponse(t *appointment.IntakeTemplate) IntakeTemplateResponse {
+	resp := IntakeTemplateResponse{
+		SlotType:  t.SlotType,
+		Version:   t.Version,
+		Questions: make([]IntakeQuestionRequest, 0, len(t.Questions)),
+		UpdatedAt: t.UpdatedAt,
+	}
+	for _, q := range t.Questions {
+		resp.Questions = append(resp.Questions, toIntakeQuestionResponse(q))
+	}
+	return resp
+}
+
+func toIntakeResponse(intake *appointment.Intake) IntakeResponse {
+	resp := IntakeResponse{
+		AppointmentID: intake.AppointmentID,
+		Status:        string(intake.Status),
+		Questions:     []IntakeAnswerResponse{},
+	}
+	if intake.Template != nil {
+		resp.SlotType = &intake.Template.SlotType
+		resp.TemplateVersion = &intake.Template.Version
+		for _, q := range intake.Template.Questions {
+			answer := IntakeAnswerResponse{IntakeQuestionRequest: toIntakeQuestionResponse(q)}
+			if intake.Response != nil {
+				if a, ok := intake.Response.Answers[q.ID]; ok {
+					answer.Answer = &a
+				}
+			}
+			resp.Questions = append(resp.Questions, answer)
+		}
+	}
+	if intake.Response != nil {
+		resp.UpdatedAt = &intake.Response.UpdatedAt
+	}
+	return resp
+}
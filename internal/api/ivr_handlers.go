@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// APIKeyHeader carries the API key of an integration
+const APIKeyHeader = "X-API-Key"
+
+type apiKeyKey struct{}
+
+// APIKeyMiddleware requires a valid API key in APIKeyHeader. The request
+// acts for the key, recorded in the PII access log under its prefix, in
+// place of any staff member it names.
+func APIKeyMiddleware(svc *appointment.Service) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k, err := svc.AuthenticateAPIKey(r.Context(), r.Header.Get(APIKeyHeader))
+			if err != nil {
+				if errors.Is(err, appointment.ErrAPIKeyNotFound) {
+					writeError(w, http.StatusUnauthorized, "unauthorized", "a valid API key is required")
+					return
+				}
+				writeServiceError(w, err)
+				return
+			}
+			setAccessUser(r.Context(), k.Prefix)
+
+			ctx := context.WithValue(r.Context(), apiKeyKey{}, k)
+			ctx = context.WithValue(ctx, actorKey{}, appointment.Actor{ID: k.Prefix, Kind: appointment.ActorAPIKey})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// apiKeyFrom returns the key APIKeyMiddleware authenticated
+func apiKeyFrom(ctx context.Context) *appointment.APIKey {
+	k, _ := ctx.Value(apiKeyKey{}).(*appointment.APIKey)
+	return k
+}
+
+// ivrLookupHandler finds the caller's next appointment by the number they
+// call from. The number comes in the body, so it stays out of URLs and the
+// logs that keep them.
+func ivrLookupHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req IVRLookupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		detail, err := svc.NextAppointmentByPhone(r.Context(), apiKeyFrom(r.Context()), req.Phone)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if !recordPIIAccess(w, r, svc, detail.PatientID) {
+			return
+		}
+
+		resp := IVRAppointmentResponse{
+			Reference: detail.ID,
+			Status:    string(detail.Status),
+		}
+		if detail.Slot != nil {
+			resp.StartTime = &detail.Slot.StartTime
+			resp.EndTime = &detail.Slot.EndTime
+		}
+		if detail.Clinician != nil {
+			resp.ClinicianName = detail.Clinician.Name
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// ivrActionHandler confirms or cancels an appointment by reference with
+// act, e.g. svc.ConfirmAppointmentByReference
+func ivrActionHandler(act func(ctx context.Context, key *appointment.APIKey, reference uuid.UUID) (*appointment.Appointment, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reference, err := uuid.Parse(chi.URLParam(r, "reference"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_reference", "reference must be a valid UUID")
+			return
+		}
+
+		appt, err := act(r.Context(), apiKeyFrom(r.Context()), reference)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, IVRAppointmentResponse{
+			Reference: appt.ID,
+			Status:    string(appt.Status),
+		})
+	}
+}
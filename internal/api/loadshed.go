@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+)
+
+// Priority ranks requests for load shedding. Lower priorities are shed first.
+type Priority int
+
+const (
+	PriorityReport  Priority = iota // admin reports and clinic exports
+	PriorityList                    // reads and lists
+	PriorityBook                    // bookings, cancellations and reschedules
+	PriorityConfirm                 // confirming, approving or rejecting a hold
+	PriorityHealth                  // health, metrics and admin tools, never shed
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityReport:
+		return "report"
+	case PriorityList:
+		return "list"
+	case PriorityBook:
+		return "book"
+	case PriorityConfirm:
+		return "confirm"
+	default:
+		return "health"
+	}
+}
+
+// shedAt is the pressure from which each priority is shed. A pressure of 1
+// means a configured limit has been reached.
+var shedAt = map[Priority]float64{
+	PriorityReport:  1,
+	PriorityList:    1.5,
+	PriorityBook:    2,
+	PriorityConfirm: 3,
+}
+
+// RoutePriority classifies a request by method and path. It runs before
+// routing, so unknown paths are classified too.
+func RoutePriority(r *http.Request) Priority {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/health/"), path == "/metrics":
+		return PriorityHealth
+	case strings.HasPrefix(path, "/admin/reports/"),
+		strings.HasPrefix(path, "/clinics/") && strings.HasSuffix(path, "/appointments"):
+		return PriorityReport
+	case strings.HasPrefix(path, "/admin/"):
+		// Operators need the rest of the admin API to recover an overloaded
+		// cluster, e.g. to break stuck locks
+		return PriorityHealth
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return PriorityList
+	}
+	if readOnlyPost(path) {
+		return PriorityList
+	}
+	if strings.HasSuffix(path, "/confirm") || strings.HasSuffix(path, "/approve") || strings.HasSuffix(path, "/reject") {
+		return PriorityConfirm
+	}
+	return PriorityBook
+}
+
+var (
+	requestsShed = metrics.NewCounter(
+		"http_requests_shed_total",
+		"Requests rejected with 503 while overloaded, by priority (report, list, book, confirm).",
+		"priority",
+	)
+	loadPressure = metrics.NewGauge(
+		"http_load_pressure",
+		"Last sampled load pressure; 1 is the configured limit and sheds reports.",
+	)
+)
+
+// PoolWaitStats reports cumulative connection pool waits: how many
+// acquisitions found no free connection, and how long they waited in total
+type PoolWaitStats func() (waits int64, waited time.Duration)
+
+// PgxPoolWaits sums the waits of pools, e.g. the pools of every shard
+func PgxPoolWaits(pools ...*pgxpool.Pool) PoolWaitStats {
+	return func() (int64, time.Duration) {
+		var waits int64
+		var waited time.Duration
+		for _, pool := range pools {
+			st := pool.Stat()
+			waits += st.EmptyAcquireCount()
+			waited += st.EmptyAcquireWaitTime()
+		}
+		return waits, waited
+	}
+}
+
+// SQLPoolWaits reports the waits of a database/sql handle
+func SQLPoolWaits(db *sql.DB) PoolWaitStats {
+	return func() (int64, time.Duration) {
+		st := db.Stats()
+		return st.WaitCount, st.WaitDuration
+	}
+}
+
+// LoadShedConfig sets the limits at which a LoadShedder starts shedding.
+// A zero limit leaves its signal out.
+type LoadShedConfig struct {
+	MaxGoroutines int           // goroutines in the process
+	MaxPoolWait   time.Duration // mean wait of the pool acquisitions that waited since the last sample
+	PoolWaits     PoolWaitStats // optional, the pool signal is left out when nil
+}
+
+// LoadShedder rejects low-priority requests with 503 while the process is
+// saturated, so a storm of reports cannot starve bookings. Pressure is the
+// highest ratio of a signal to its limit, sampled by Run; see shedAt for the
+// pressure each priority is shed at.
+type LoadShedder struct {
+	cfg      LoadShedConfig
+	pressure atomic.Uint64 // math.Float64bits
+
+	// Only touched by sample
+	lastWaits  int64
+	lastWaited time.Duration
+}
+
+func NewLoadShedder(cfg LoadShedConfig) *LoadShedder {
+	s := &LoadShedder{cfg: cfg}
+	if cfg.PoolWaits != nil {
+		s.lastWaits, s.lastWaited = cfg.PoolWaits()
+	}
+	return s
+}
+
+// Run samples the signals every interval until ctx is done
+func (s *LoadShedder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+func (s *LoadShedder) sample() {
+	var pressure float64
+	if s.cfg.MaxGoroutines > 0 {
+		pressure = float64(runtime.NumGoroutine()) / float64(s.cfg.MaxGoroutines)
+	}
+	if s.cfg.MaxPoolWait > 0 && s.cfg.PoolWaits != nil {
+		waits, waited := s.cfg.PoolWaits()
+		if n := waits - s.lastWaits; n > 0 {
+			mean := (waited - s.lastWaited) / time.Duration(n)
+			pressure = max(pressure, float64(mean)/float64(s.cfg.MaxPoolWait))
+		}
+		s.lastWaits, s.lastWaited = waits, waited
+	}
+
+	s.pressure.Store(math.Float64bits(pressure))
+	loadPressure.Set(pressure)
+}
+
+// Pressure returns the last sampled pressure
+func (s *LoadShedder) Pressure() float64 {
+	return math.Float64frombits(s.pressure.Load())
+}
+
+func (s *LoadShedder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := RoutePriority(r)
+		limit, sheddable := shedAt[p]
+		if !sheddable || s.Pressure() < limit {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestsShed.Inc(p.String())
+		w.Header().Set("Retry-After", "1")
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{
+			Error:     "overloaded",
+			Details:   fmt.Sprintf("server is overloaded and shedding %s requests", p),
+			Retryable: true,
+		})
+	})
+}
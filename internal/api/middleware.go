@@ -2,16 +2,19 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
-)
-
-type contextKey string
 
-const requestIDKey contextKey = "request_id"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/requestid"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
 
 // RequestIDMiddleware adds a unique request ID to each request context
 func RequestIDMiddleware(next http.Handler) http.Handler {
@@ -21,49 +24,205 @@ func RequestIDMiddleware(next http.Handler) http.Handler {
 			requestID = uuid.New().String()
 		}
 
-		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+		ctx := requestid.With(r.Context(), requestID)
 		w.Header().Set("X-Request-ID", requestID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// LoggingMiddleware logs HTTP requests with method, path, status, duration, and request ID
-func LoggingMiddleware(next http.Handler) http.Handler {
+// TenantHeader names the tenant a request acts for. Requests without it use
+// the default shard.
+const TenantHeader = "X-Tenant-ID"
+
+// TenantMiddleware routes the request's queries to the shard of the tenant
+// named in TenantHeader
+func TenantMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+		tenant := r.Header.Get(TenantHeader)
+		if tenant == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !shard.ValidTenant(tenant) {
+			writeError(w, http.StatusBadRequest, "invalid_tenant",
+				TenantHeader+" must be 1-64 letters, digits, '-' or '_'")
+			return
+		}
+		setAccessTenant(r.Context(), tenant)
+		next.ServeHTTP(w, r.WithContext(shard.WithTenant(r.Context(), tenant)))
+	})
+}
+
+// AdminAuthMiddleware requires "Authorization: Bearer <token>" on admin endpoints
+func AdminAuthMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "unauthorized", "admin token required")
+				return
+			}
+			setAccessUser(r.Context(), "admin")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// readOnlyPosts are POST endpoints that only read and stay available in a
+// passive region
+var readOnlyPosts = map[string]bool{
+	"/appointments/batch-get": true,
+	"/ivr/lookup":             true,
+}
+
+// readOnlyPost reports whether a POST to path only reads: one of
+// readOnlyPosts or a slot precheck
+func readOnlyPost(path string) bool {
+	return readOnlyPosts[path] || strings.HasPrefix(path, "/slots/") && strings.HasSuffix(path, "/precheck")
+}
+
+// ReadOnlyMiddleware rejects writes while the region is passive. Health and
+// admin endpoints stay writable so the region can be promoted.
+func ReadOnlyMiddleware(readOnly func() bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			case http.MethodPost:
+				if readOnlyPost(r.URL.Path) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			if strings.HasPrefix(r.URL.Path, "/admin/") || strings.HasPrefix(r.URL.Path, "/health/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if readOnly() {
+				writeError(w, http.StatusServiceUnavailable, "read_only", "this region is passive and does not accept writes")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ConsistencyTokenHeader carries a read-after-write token: write responses
+// set it, and reads that send it back see at least that write
+const ConsistencyTokenHeader = "X-Consistency-Token"
+
+// ConsistencyTokens issues read-after-write tokens and holds reads back
+// until the database has the writes a token was issued after
+type ConsistencyTokens interface {
+	ConsistencyToken(ctx context.Context) (string, error)
+	AwaitToken(ctx context.Context, token string, wait time.Duration) error
+}
 
-		// Wrap ResponseWriter to capture status code
-		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+// ConsistencyMiddleware sets ConsistencyTokenHeader on successful writes
+// outside /admin and /health. A read carrying the header waits up to wait
+// for the database to catch up with it, and fails with 503 stale_read if it
+// does not, so the client can retry or read from the active region instead
+// of showing stale state.
+func ConsistencyMiddleware(tokens ConsistencyTokens, wait time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/admin/") || strings.HasPrefix(r.URL.Path, "/health/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			read := r.Method == http.MethodGet || r.Method == http.MethodHead ||
+				r.Method == http.MethodPost && readOnlyPost(r.URL.Path)
+			if !read {
+				next.ServeHTTP(&consistencyWriter{ResponseWriter: w, ctx: r.Context(), tokens: tokens}, r)
+				return
+			}
+
+			if token := r.Header.Get(ConsistencyTokenHeader); token != "" {
+				err := tokens.AwaitToken(r.Context(), token, wait)
+				switch {
+				case errors.Is(err, shard.ErrInvalidConsistencyToken):
+					writeError(w, http.StatusBadRequest, "invalid_consistency_token", err.Error())
+					return
+				case errors.Is(err, shard.ErrStaleRead):
+					w.Header().Set("Retry-After", "1")
+					writeError(w, http.StatusServiceUnavailable, "stale_read", err.Error())
+					return
+				case err != nil:
+					// The token is a hint; a failed check must not fail the read
+					log.Printf("consistency token check failed: %v", err)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-		next.ServeHTTP(wrapped, r)
+// consistencyWriter sets ConsistencyTokenHeader before a 2xx status goes out
+type consistencyWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	tokens      ConsistencyTokens
+	wroteHeader bool
+}
 
-		duration := time.Since(start)
-		requestID := GetRequestID(r.Context())
+func (cw *consistencyWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		if code >= 200 && code < 300 {
+			token, err := cw.tokens.ConsistencyToken(cw.ctx)
+			if err != nil {
+				log.Printf("consistency token: %v", err)
+			} else {
+				cw.Header().Set(ConsistencyTokenHeader, token)
+			}
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
 
-		log.Printf(
-			"method=%s path=%s status=%d duration=%s request_id=%s",
-			r.Method,
-			r.URL.Path,
-			wrapped.statusCode,
-			duration,
-			requestID,
-		)
+func (cw *consistencyWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *consistencyWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// RequestCounter counts every request served by this instance. The
+// api-server samples it on each registry heartbeat to publish a request rate.
+type RequestCounter struct {
+	total atomic.Uint64
+}
+
+func (c *RequestCounter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.total.Add(1)
+		next.ServeHTTP(w, r)
 	})
 }
 
+// Total returns the number of requests seen since startup
+func (c *RequestCounter) Total() uint64 {
+	return c.total.Load()
+}
+
 // GetRequestID retrieves the request ID from context
 func GetRequestID(ctx context.Context) string {
-	if id, ok := ctx.Value(requestIDKey).(string); ok {
-		return id
-	}
-	return ""
+	return requestid.From(ctx)
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
+// responseWriter wraps http.ResponseWriter to capture the status code and
+// body size
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -71,3 +230,14 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush a streamed response
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// addNoteHandler adds a note to an appointment, written by the staff member
+// or admin the request acts for
+func addNoteHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		var req AddNoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		var author string
+		if actor, ok := actorFrom(r.Context()); ok {
+			author = actor.ID
+		}
+		n, err := svc.AddNote(r.Context(), id, author, req.Body)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, toNoteResponse(n))
+	}
+}
+
+func toNoteResponse(n *appointment.AppointmentNote) AppointmentNoteResponse {
+	return AppointmentNoteResponse{
+		ID:            n.ID,
+		AppointmentID: n.AppointmentID,
+		Author:        n.Author,
+		Body:          n.Body,
+		CreatedAt:     n.CreatedAt,
+	}
+}
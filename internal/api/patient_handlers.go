@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+func createPatientHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreatePatientRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		p, err := svc.CreatePatient(r.Context(), appointment.NewPatient{Name: req.Name, Email: req.Email})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, toPatientResponse(p))
+	}
+}
+
+// searchPatientsHandler finds patients by a part of their name or email;
+// staff searches are recorded in the PII access log for every patient shown
+func searchPatientsHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		q := appointment.PatientSearch{
+			Query: query.Get("query"),
+			Token: query.Get("page_token"),
+			Limit: 20,
+		}
+		if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+			q.Limit = min(l, 100)
+		}
+		if raw := query.Get("include_deactivated"); raw != "" {
+			include, err := strconv.ParseBool(raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_include_deactivated", "include_deactivated must be true or false")
+				return
+			}
+			q.IncludeDeactivated = include
+		}
+
+		page, err := svc.SearchPatients(r.Context(), q)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		ids := make([]uuid.UUID, len(page.Patients))
+		resp := PatientSearchResponse{Patients: make([]PatientResponse, len(page.Patients)), NextPageToken: page.NextToken}
+		for i := range page.Patients {
+			ids[i] = page.Patients[i].ID
+			resp.Patients[i] = toPatientResponse(&page.Patients[i])
+		}
+		if !recordPIIAccess(w, r, svc, ids...) {
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// getPatientHandler returns the patient's details; staff reads are
+// recorded in the PII access log
+func getPatientHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "id must be a valid UUID")
+			return
+		}
+
+		p, err := svc.GetPatient(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if !recordPIIAccess(w, r, svc, id) {
+			return
+		}
+		writeJSON(w, http.StatusOK, toPatientResponse(p))
+	}
+}
+
+// updatePatientHandler changes the name or email given and returns the
+// patient's details, recorded like getPatientHandler's
+func updatePatientHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "id must be a valid UUID")
+			return
+		}
+
+		var req UpdatePatientRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		p, err := svc.UpdatePatient(r.Context(), id, appointment.PatientUpdate{Name: req.Name, Email: req.Email})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if !recordPIIAccess(w, r, svc, id) {
+			return
+		}
+		writeJSON(w, http.StatusOK, toPatientResponse(p))
+	}
+}
+
+func toPatientResponse(p *appointment.Patient) PatientResponse {
+	return PatientResponse{
+		ID:            p.ID,
+		Name:          p.Name,
+		Email:         p.Email,
+		Phone:         p.Phone,
+		Deactivated:   p.Deactivated(),
+		DeactivatedAt: p.DeactivatedAt,
+		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.UpdatedAt,
+	}
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+const (
+	// StaffHeader names the staff member a request acts for. Patient data
+	// returned to them is recorded in the PII access log.
+	StaffHeader = "X-Staff-ID"
+	// AccessReasonHeader is why the staff member or admin opens the records,
+	// kept with each access record
+	AccessReasonHeader = "X-Access-Reason"
+
+	maxStaffIDLength      = 128
+	maxAccessReasonLength = 500
+
+	defaultPIIAccessWindow = 30 * 24 * time.Hour
+	defaultPIIAccessLimit  = 100
+)
+
+type actorKey struct{}
+
+// ActorMiddleware identifies the staff member or admin a request acts for,
+// from StaffHeader or the admin bearer token, so the patient data returned
+// to them can be recorded. A request with neither acts for the patient
+// and is not recorded. Admins are recorded under their StaffHeader when
+// they send one, "admin" otherwise.
+func ActorMiddleware(adminToken string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			staff := r.Header.Get(StaffHeader)
+			if staff != "" && !validStaffID(staff) {
+				writeError(w, http.StatusBadRequest, "invalid_staff_id",
+					StaffHeader+" must be 1-128 printable characters")
+				return
+			}
+			reason := strings.TrimSpace(r.Header.Get(AccessReasonHeader))
+			if len(reason) > maxAccessReasonLength {
+				writeError(w, http.StatusBadRequest, "invalid_access_reason",
+					AccessReasonHeader+" must be at most 500 characters")
+				return
+			}
+
+			actor := appointment.Actor{ID: staff, Kind: appointment.ActorStaff, Reason: reason}
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if ok && adminToken != "" && subtle.ConstantTimeCompare([]byte(got), []byte(adminToken)) == 1 {
+				actor.Kind = appointment.ActorAdmin
+				if actor.ID == "" {
+					actor.ID = "admin"
+				}
+			}
+			if actor.ID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, actor)))
+		})
+	}
+}
+
+func validStaffID(id string) bool {
+	if len(id) > maxStaffIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x20 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// actorFrom returns the staff member or admin the request acts for
+func actorFrom(ctx context.Context) (appointment.Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(appointment.Actor)
+	return actor, ok
+}
+
+// routeEndpoint names the endpoint serving r by method and route pattern,
+// so records of the same endpoint group together whatever the IDs
+func routeEndpoint(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return r.Method + " " + rctx.RoutePattern()
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+// recordPIIAccess records that the response to r returns the data of
+// patientIDs to the request's actor. It must be called before the data is
+// written: when recording fails it writes the error and returns false, and
+// the handler returns without the data.
+func recordPIIAccess(w http.ResponseWriter, r *http.Request, svc *appointment.Service, patientIDs ...uuid.UUID) bool {
+	actor, ok := actorFrom(r.Context())
+	if !ok || len(patientIDs) == 0 {
+		return true
+	}
+	if err := svc.RecordPIIAccess(r.Context(), actor, routeEndpoint(r), patientIDs...); err != nil {
+		writeServiceError(w, err)
+		return false
+	}
+	return true
+}
+
+// recordAppointmentPIIAccess is recordPIIAccess for the patient of the
+// appointment
+func recordAppointmentPIIAccess(w http.ResponseWriter, r *http.Request, svc *appointment.Service, appointmentID uuid.UUID) bool {
+	actor, ok := actorFrom(r.Context())
+	if !ok {
+		return true
+	}
+	if err := svc.RecordAppointmentPIIAccess(r.Context(), actor, routeEndpoint(r), appointmentID); err != nil {
+		writeServiceError(w, err)
+		return false
+	}
+	return true
+}
+
+// shownPatients lists the patients whose details a response includes
+func shownPatients(details []appointment.AppointmentDetail) []uuid.UUID {
+	var ids []uuid.UUID
+	for i := range details {
+		if details[i].Patient != nil {
+			ids = append(ids, details[i].Patient.ID)
+		}
+	}
+	return ids
+}
+
+// piiAccessReportHandler lists who was shown which patient's data, newest
+// first, in [?from, ?to) (default the last 30 days), optionally of one
+// ?patient_id or ?actor
+func piiAccessReportHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		filter := appointment.PIIAccessFilter{
+			Actor: q.Get("actor"),
+			To:    svc.Now(),
+			Limit: defaultPIIAccessLimit,
+		}
+		if v := q.Get("to"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_to", "to must be an RFC 3339 timestamp")
+				return
+			}
+			filter.To = t
+		}
+		filter.From = filter.To.Add(-defaultPIIAccessWindow)
+		if v := q.Get("from"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_from", "from must be an RFC 3339 timestamp")
+				return
+			}
+			filter.From = t
+		}
+		if !filter.From.Before(filter.To) {
+			writeError(w, http.StatusBadRequest, "invalid_range", "from must be before to")
+			return
+		}
+		if v := q.Get("patient_id"); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_patient_id", "patient_id must be a valid UUID")
+				return
+			}
+			filter.PatientID = &id
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > appointment.MaxPIIAccessRecords {
+				writeError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and 1000")
+				return
+			}
+			filter.Limit = n
+		}
+
+		records, err := svc.ListPIIAccess(r.Context(), filter)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := PIIAccessReportResponse{
+			From:     filter.From,
+			To:       filter.To,
+			Accesses: make([]PIIAccessResponse, 0, len(records)),
+		}
+		for _, a := range records {
+			resp.Accesses = append(resp.Accesses, PIIAccessResponse{
+				ID:         a.ID,
+				Actor:      a.Actor,
+				ActorKind:  string(a.ActorKind),
+				Reason:     a.Reason,
+				PatientID:  a.PatientID,
+				Endpoint:   a.Endpoint,
+				RequestID:  a.RequestID,
+				AccessedAt: a.AccessedAt,
+			})
+		}
+		resp.Count = len(resp.Accesses)
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// recordStreamedPIIAccess records access to data that was already streamed,
+// so a failure can only be logged
+func recordStreamedPIIAccess(r *http.Request, svc *appointment.Service, patientIDs []uuid.UUID) {
+	actor, ok := actorFrom(r.Context())
+	if !ok || len(patientIDs) == 0 {
+		return
+	}
+	ctx := context.WithoutCancel(r.Context())
+	if err := svc.RecordPIIAccess(ctx, actor, routeEndpoint(r), patientIDs...); err != nil {
+		log.Printf("streamed data of %d patients to %s without an access record: %v", len(patientIDs), actor.ID, err)
+	}
+}
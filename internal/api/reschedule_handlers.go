@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// rescheduleAppointmentHandler moves an appointment to another slot. The
+// response carries the new appointment, which has its own ID, and the old
+// one as cancelled.
+func rescheduleAppointmentHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		var req RescheduleAppointmentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		slotID, err := uuid.Parse(req.SlotID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_slot_id", "slot_id must be a valid UUID")
+			return
+		}
+
+		res, err := svc.RescheduleAppointment(r.Context(), id, slotID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		now := svc.Now()
+		writeJSON(w, http.StatusOK, RescheduleResponse{
+			Appointment: toAppointmentResponse(res.Appointment, now),
+			Previous:    toAppointmentResponse(res.Previous, now),
+		})
+	}
+}
+
+// transferHoldHandler moves a pending hold to another slot. The response is
+// the same appointment, with its ID and expires_at unchanged.
+func transferHoldHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_appointment_id", "id must be a valid UUID")
+			return
+		}
+
+		var req TransferHoldRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		slotID, err := uuid.Parse(req.SlotID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_slot_id", "slot_id must be a valid UUID")
+			return
+		}
+
+		appt, err := svc.TransferHold(r.Context(), id, slotID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toAppointmentResponse(appt, svc.Now()))
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+const defaultRetentionRunLimit = 100
+
+// getRetentionPolicyHandler returns the retention policy of the database of
+// the tenant named in TenantHeader, or of the default database without one.
+// Only a tenant with a database to itself can set a policy, and only the
+// tenant that set it can change it.
+func getRetentionPolicyHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := svc.GetRetentionPolicy(r.Context())
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toRetentionPolicyResponse(p))
+	}
+}
+
+func putRetentionPolicyHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PutRetentionPolicyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		saved, err := svc.PutRetentionPolicy(r.Context(), appointment.RetentionPolicy{
+			TenantID:          shard.Tenant(r.Context()),
+			AppointmentMonths: req.AppointmentMonths,
+			EventMonths:       req.EventMonths,
+			FeedbackMonths:    req.FeedbackMonths,
+		})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toRetentionPolicyResponse(saved))
+	}
+}
+
+func deleteRetentionPolicyHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.DeleteRetentionPolicy(r.Context(), shard.Tenant(r.Context())); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// listRetentionRunsHandler returns the audit trail of the retention worker,
+// newest first, up to ?limit (default 100)
+func listRetentionRunsHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultRetentionRunLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > appointment.MaxRetentionRuns {
+				writeError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and 1000")
+				return
+			}
+			limit = n
+		}
+
+		runs, err := svc.ListRetentionRuns(r.Context(), limit)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := RetentionRunListResponse{Runs: make([]RetentionRunResponse, 0, len(runs))}
+		for _, run := range runs {
+			resp.Runs = append(resp.Runs, RetentionRunResponse{
+				ID:           run.ID,
+				Entity:       string(run.Entity),
+				TenantID:     run.TenantID,
+				RetainMonths: run.RetainMonths,
+				Cutoff:       run.Cutoff,
+				DryRun:       run.DryRun,
+				Matched:      run.Matched,
+				Deleted:      run.Deleted,
+				StartedAt:    run.StartedAt,
+				FinishedAt:   run.FinishedAt,
+			})
+		}
+		resp.Count = len(resp.Runs)
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func toRetentionPolicyResponse(p *appointment.RetentionPolicy) RetentionPolicyResponse {
+	return RetentionPolicyResponse{
+		TenantID:          p.TenantID,
+		AppointmentMonths: p.AppointmentMonths,
+		EventMonths:       p.EventMonths,
+		FeedbackMonths:    p.FeedbackMonths,
+		UpdatedAt:         p.UpdatedAt,
+	}
+}
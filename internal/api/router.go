@@ -1,19 +1,24 @@
 package api
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/redis/go-redis/v9"
 
 	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/bulkcancel"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/region"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/webhook"
 )
 
 type AppointmentService interface {
 	CreateAppointment(ctx Context, slotID, patientID uuid.UUID) (*appointment.Appointment, error)
-	ConfirmAppointment(ctx Context, id uuid.UUID) (*appointment.Appointment, error)
+	ConfirmAppointment(ctx Context, id uuid.UUID, expected ...appointment.AppointmentStatus) (*appointment.Appointment, error)
 }
 
 type Context = interface {
@@ -21,31 +26,247 @@ type Context = interface {
 	Err() error
 }
 
+// ClusterRegistry lists the api-server instances currently heartbeating
+type ClusterRegistry interface {
+	List(ctx context.Context) ([]redisclient.Instance, error)
+}
+
+// ContentionReport ranks slots by failed lock acquisitions and counts their
+// recent ones
+type ContentionReport interface {
+	TopContended(ctx context.Context, k int) ([]redisclient.SlotContention, error)
+	RecentConflicts(ctx context.Context, slotIDs []uuid.UUID) (map[uuid.UUID]int64, error)
+}
+
+// LockAdmin lists held locks and breaks stuck ones
+type LockAdmin interface {
+	List(ctx context.Context, limit int) ([]redisclient.HeldLock, error)
+	ForceRelease(ctx context.Context, key, token string) (bool, error)
+}
+
 type RouterConfig struct {
-	Service  *appointment.Service
-	PgPool   *pgxpool.Pool
-	Redis    *redis.Client
-	Env      string
-	Version  string
+	Service    *appointment.Service
+	Webhooks   *webhook.Service // optional, webhook endpoints are not mounted when nil
+	Health     []DependencyCheck
+	Requests   *RequestCounter     // optional, counts requests for the instance registry
+	Cluster    ClusterRegistry     // optional, /admin/cluster is not mounted when nil
+	Contention ContentionReport    // optional, adds the contended slots to /admin/stats and conflict hints to bookings
+	Locks      LockAdmin           // optional, /admin/locks is not mounted when nil
+	SlotRouter SlotRouter          // optional, forwards bookings to the slot owner when set
+	Region     *region.Controller  // optional, enables read-only mode and /admin/region
+	BulkCancel *bulkcancel.Service // optional, enables clinic day cancellation under /admin
+	Shedder    *LoadShedder        // optional, sheds low-priority requests while overloaded
+	AccessLog  *AccessLogger       // optional, every request is logged when nil
+	AdminToken string              // /admin endpoints are not mounted when empty
+	Encoder    ResponseEncoder     // optional, PlainEncoder when nil
+	Env        string
+	Version    string
+
+	Attachments *AttachmentConfig // optional, attachment endpoints are not mounted when nil
+	ActionLinks *ActionLinkConfig // optional, action link endpoints are not mounted when nil
+	Widget      *WidgetConfig     // optional, the public widget API is not mounted when nil
+	SMS         *SMSConfig        // optional, the inbound SMS webhook is not mounted when nil
+
+	Consistency     ConsistencyTokens // optional, enables read-after-write tokens
+	ConsistencyWait time.Duration     // how long a read waits for its consistency token
 }
 
 func NewRouter(cfg RouterConfig) http.Handler {
 	r := chi.NewRouter()
 
 	// Apply middleware
+	if cfg.Encoder != nil {
+		r.Use(ResponseEncoderMiddleware(cfg.Encoder))
+	}
 	r.Use(RequestIDMiddleware)
-	r.Use(LoggingMiddleware)
+	accessLog := cfg.AccessLog
+	if accessLog == nil {
+		accessLog = NewAccessLogger(AccessLogConfig{SampleRate: 1})
+	}
+	r.Use(accessLog.Middleware)
+	r.Use(TenantMiddleware)
+	r.Use(ActorMiddleware(cfg.AdminToken))
+	if cfg.Requests != nil {
+		r.Use(cfg.Requests.Middleware)
+	}
+	if cfg.Shedder != nil {
+		r.Use(cfg.Shedder.Middleware)
+	}
+	if cfg.Region != nil {
+		r.Use(ReadOnlyMiddleware(cfg.Region.ReadOnly))
+	}
+	if cfg.Consistency != nil {
+		r.Use(ConsistencyMiddleware(cfg.Consistency, cfg.ConsistencyWait))
+	}
 
 	// Health endpoints
-	health := NewHealthHandler(cfg.PgPool, cfg.Redis, cfg.Env, cfg.Version)
+	health := NewHealthHandler(cfg.Health, cfg.Region, cfg.Env, cfg.Version)
 	r.Get("/health/live", health.Liveness)
 	r.Get("/health/ready", health.Readiness)
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
 
 	// Appointment endpoints
-	r.Post("/appointments", createAppointmentHandler(cfg.Service))
+	if cfg.SlotRouter != nil {
+		r.With(SlotRoutingMiddleware(cfg.SlotRouter)).Post("/appointments", createAppointmentHandler(cfg.Service, cfg.Contention))
+	} else {
+		r.Post("/appointments", createAppointmentHandler(cfg.Service, cfg.Contention))
+	}
 	r.Get("/appointments", listAppointmentsHandler(cfg.Service))
+	r.Get("/appointments/search", searchAppointmentsHandler(cfg.Service))
+	r.Post("/appointments/batch-get", batchGetAppointmentsHandler(cfg.Service))
 	r.Get("/appointments/{id}", getAppointmentHandler(cfg.Service))
+	r.Get("/appointments/{id}/ttl", getAppointmentTTLHandler(cfg.Service))
 	r.Post("/appointments/{id}/confirm", confirmAppointmentHandler(cfg.Service))
+	r.Post("/appointments/{id}/approve", reviewAppointmentHandler(cfg.Service, cfg.Service.ApproveAppointment))
+	r.Post("/appointments/{id}/reject", reviewAppointmentHandler(cfg.Service, cfg.Service.RejectAppointment))
+	r.Post("/appointments/{id}/check-in", checkInAppointmentHandler(cfg.Service))
+	r.Post("/appointments/{id}/complete", attendanceHandler(cfg.Service, cfg.Service.CompleteAppointment))
+	r.Post("/appointments/{id}/no-show", attendanceHandler(cfg.Service, cfg.Service.MarkNoShow))
+	r.Post("/appointments/{id}/reschedule", rescheduleAppointmentHandler(cfg.Service))
+	r.Post("/appointments/{id}/transfer-hold", transferHoldHandler(cfg.Service))
+	r.Get("/appointments/{id}/intake", getIntakeHandler(cfg.Service))
+	r.Post("/appointments/{id}/intake", submitIntakeHandler(cfg.Service))
+	r.Post("/appointments/{id}/feedback", submitFeedbackHandler(cfg.Service))
+	r.Get("/appointments/{id}/feedback", getFeedbackHandler(cfg.Service))
+	r.Post("/appointments/{id}/notes", addNoteHandler(cfg.Service))
+
+	// Attachment endpoints
+	if cfg.Attachments != nil {
+		r.Post("/appointments/{id}/attachments", uploadAttachmentHandler(cfg.Service, cfg.Attachments))
+		r.Get("/appointments/{id}/attachments", listAttachmentsHandler(cfg.Service, cfg.Attachments))
+		r.Get("/attachments/{id}/download", downloadAttachmentHandler(cfg.Service, cfg.Attachments))
+	}
+
+	// Action links patients follow without credentials
+	if cfg.ActionLinks != nil {
+		r.Get("/appointments/{id}/actions/{action}", previewActionLinkHandler(cfg.Service, cfg.ActionLinks))
+		r.Post("/appointments/{id}/actions/{action}", performActionLinkHandler(cfg.Service, cfg.ActionLinks))
+	}
+
+	// Inbound SMS from the carrier
+	if cfg.SMS != nil {
+		r.Post("/sms/inbound", inboundSMSHandler(cfg.Service, cfg.SMS))
+	}
+
+	// Phone tree integration, behind API keys
+	r.Route("/ivr", func(r chi.Router) {
+		r.Use(APIKeyMiddleware(cfg.Service))
+		r.Post("/lookup", ivrLookupHandler(cfg.Service))
+		r.Post("/appointments/{reference}/confirm", ivrActionHandler(cfg.Service.ConfirmAppointmentByReference))
+		r.Post("/appointments/{reference}/cancel", ivrActionHandler(cfg.Service.CancelAppointmentByReference))
+	})
+
+	// Public booking widget
+	if cfg.Widget != nil {
+		r.Mount("/widget", widgetRouter(cfg.Service, cfg.Widget, cfg.ActionLinks, cfg.Contention))
+	}
+
+	// Series endpoints
+	r.Post("/series", createSeriesHandler(cfg.Service))
+	r.Get("/series/{id}", getSeriesHandler(cfg.Service))
+	r.Post("/series/{id}/confirm", confirmSeriesHandler(cfg.Service))
+	r.Post("/series/{id}/cancel", cancelSeriesHandler(cfg.Service))
+	r.Post("/series/{id}/reschedule", rescheduleSeriesHandler(cfg.Service))
+	r.Post("/series/{id}/occurrences/{n}/cancel", cancelOccurrenceHandler(cfg.Service))
+	r.Post("/series/{id}/occurrences/{n}/reschedule", rescheduleOccurrenceHandler(cfg.Service))
+
+	// Clinic endpoints
+	r.Get("/clinics/{id}/appointments", clinicAppointmentsHandler(cfg.Service))
+	r.Get("/sync", syncHandler(cfg.Service))
+
+	// Patient endpoints
+	r.Post("/patients", createPatientHandler(cfg.Service))
+	r.Get("/patients", searchPatientsHandler(cfg.Service))
+	r.Get("/patients/{id}", getPatientHandler(cfg.Service))
+	r.Patch("/patients/{id}", updatePatientHandler(cfg.Service))
+	r.Get("/patients/{id}/timeline", getPatientTimelineHandler(cfg.Service))
+	r.Post("/patients/{id}/appointments/cancel-all", cancelPatientAppointmentsHandler(cfg.Service))
+	r.Post("/patients/{id}/deactivate", deactivatePatientHandler(cfg.Service))
+	r.Post("/patients/{id}/reactivate", reactivatePatientHandler(cfg.Service))
+	r.Post("/patients/{id}/devices", registerDeviceHandler(cfg.Service))
+	r.Get("/patients/{id}/devices", listDevicesHandler(cfg.Service))
+	r.Delete("/patients/{id}/devices/{device_id}", deleteDeviceHandler(cfg.Service))
+	r.Put("/patients/{id}/phone", setPatientPhoneHandler(cfg.Service))
+	r.Delete("/patients/{id}/phone", clearPatientPhoneHandler(cfg.Service))
+
+	// Clinician endpoints
+	r.Post("/clinicians", createClinicianHandler(cfg.Service))
+	r.Get("/clinicians/{id}", getClinicianHandler(cfg.Service))
+	r.Patch("/clinicians/{id}", updateClinicianHandler(cfg.Service))
+	r.Post("/clinicians/{id}/deactivate", deactivateClinicianHandler(cfg.Service))
+	r.Get("/clinicians/{id}/appointments", clinicianScheduleHandler(cfg.Service))
+	r.Get("/clinicians/{id}/availability-version", getAvailabilityVersionHandler(cfg.Service))
+	r.Get("/clinicians/{id}/availability-calendar", getAvailabilityCalendarHandler(cfg.Service))
+	r.Get("/clinicians/{id}/availability-template", getAvailabilityTemplateHandler(cfg.Service))
+	r.Put("/clinicians/{id}/availability-template", putAvailabilityTemplateHandler(cfg.Service))
+	r.Delete("/clinicians/{id}/availability-template", deleteAvailabilityTemplateHandler(cfg.Service))
+	r.Post("/clinicians/{id}/slots:generate", generateSlotsHandler(cfg.Service))
+
+	// Slot endpoints
+	r.Get("/slots", searchSlotsHandler(cfg.Service, cfg.Contention))
+	r.Get("/slots/{id}/quote", getSlotQuoteHandler(cfg.Service))
+	r.Post("/slots/{id}/precheck", precheckBookingHandler(cfg.Service, cfg.Contention))
+	r.Post("/slots/{id}/reassign", reassignSlotHandler(cfg.Service))
+
+	// Webhook subscription endpoints
+	if cfg.Webhooks != nil {
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Post("/", createWebhookHandler(cfg.Webhooks))
+			r.Get("/", listWebhooksHandler(cfg.Webhooks))
+			r.Get("/{id}", getWebhookHandler(cfg.Webhooks))
+			r.Delete("/{id}", deleteWebhookHandler(cfg.Webhooks))
+			r.Post("/{id}/rotate-secret", rotateWebhookSecretHandler(cfg.Webhooks))
+			r.Post("/{id}/test", testWebhookHandler(cfg.Webhooks))
+			r.Get("/{id}/deliveries", listWebhookDeliveriesHandler(cfg.Webhooks))
+		})
+	}
+
+	// Admin endpoints
+	if cfg.AdminToken != "" {
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(AdminAuthMiddleware(cfg.AdminToken))
+			r.Get("/stats", statsHandler(cfg.Contention))
+			r.Get("/reports/expiry-events", expiryEventReportHandler(cfg.Service))
+			r.Get("/reports/orphaned-appointments", orphanReportHandler(cfg.Service))
+			r.Get("/expiry-backlog", expiryBacklogHandler(cfg.Service))
+			r.Get("/reports/clinician-ratings", clinicianRatingReportHandler(cfg.Service))
+			r.Get("/reports/pii-access", piiAccessReportHandler(cfg.Service))
+			r.Get("/clinicians/{id}/feedback", clinicianFeedbackHandler(cfg.Service))
+			r.Get("/booking-windows", listBookingWindowsHandler(cfg.Service))
+			r.Put("/booking-windows/{specialty}", putBookingWindowHandler(cfg.Service))
+			r.Delete("/booking-windows/{specialty}", deleteBookingWindowHandler(cfg.Service))
+			r.Get("/intake-templates", listIntakeTemplatesHandler(cfg.Service))
+			r.Put("/intake-templates/{slot_type}", putIntakeTemplateHandler(cfg.Service))
+			r.Delete("/intake-templates/{slot_type}", deleteIntakeTemplateHandler(cfg.Service))
+			r.Get("/retention-policy", getRetentionPolicyHandler(cfg.Service))
+			r.Put("/retention-policy", putRetentionPolicyHandler(cfg.Service))
+			r.Delete("/retention-policy", deleteRetentionPolicyHandler(cfg.Service))
+			r.Get("/retention-runs", listRetentionRunsHandler(cfg.Service))
+			r.Post("/slots/{id}/split", splitSlotHandler(cfg.Service))
+			r.Post("/slots/merge", mergeSlotsHandler(cfg.Service))
+			r.Post("/api-keys", createAPIKeyHandler(cfg.Service))
+			r.Get("/api-keys", listAPIKeysHandler(cfg.Service))
+			r.Delete("/api-keys/{id}", revokeAPIKeyHandler(cfg.Service))
+			if cfg.Cluster != nil {
+				r.Get("/cluster", clusterHandler(cfg.Cluster))
+			}
+			if cfg.Locks != nil {
+				r.Get("/locks", listLocksHandler(cfg.Locks))
+				r.Post("/locks/{key}/release", releaseLockHandler(cfg.Locks, cfg.Service))
+			}
+			if cfg.Region != nil {
+				r.Get("/region", regionStatusHandler(cfg.Region))
+				r.Post("/region/promote", promoteRegionHandler(cfg.Region))
+			}
+			if cfg.ActionLinks != nil {
+				r.Post("/appointments/{id}/action-links", issueActionLinksHandler(cfg.Service, cfg.ActionLinks))
+			}
+			if cfg.BulkCancel != nil {
+				r.Post("/clinics/{id}/cancel-day", cancelClinicDayHandler(cfg.BulkCancel))
+				r.Get("/bulk-cancellations/{id}", getBulkCancellationHandler(cfg.BulkCancel))
+			}
+		})
+	}
 
 	return r
 }
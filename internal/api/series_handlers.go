@@ -0,0 +1,246 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+func createSeriesHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateSeriesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		patientID, err := uuid.Parse(req.PatientID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "patient_id must be a valid UUID")
+			return
+		}
+		slotID, err := uuid.Parse(req.SlotID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_slot_id", "slot_id must be a valid UUID")
+			return
+		}
+		loc := time.UTC
+		if req.TZ != "" {
+			loc, err = time.LoadLocation(req.TZ)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_tz", "tz must be an IANA time zone such as Europe/Berlin")
+				return
+			}
+		}
+
+		series, err := svc.CreateSeries(r.Context(), appointment.SeriesRequest{
+			PatientID:    patientID,
+			FirstSlotID:  slotID,
+			IntervalDays: req.IntervalDays,
+			Occurrences:  req.Occurrences,
+			Location:     loc,
+		})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, toSeriesResponse(series, svc.Now()))
+	}
+}
+
+func getSeriesHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := seriesID(w, r)
+		if !ok {
+			return
+		}
+
+		series, err := svc.GetSeries(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toSeriesResponse(series, svc.Now()))
+	}
+}
+
+func confirmSeriesHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := seriesID(w, r)
+		if !ok {
+			return
+		}
+
+		series, err := svc.ConfirmSeries(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toSeriesResponse(series, svc.Now()))
+	}
+}
+
+func cancelSeriesHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := seriesID(w, r)
+		if !ok {
+			return
+		}
+
+		var req CancelSeriesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		if req.Reason == "" {
+			req.Reason = "series_cancelled"
+		}
+
+		series, err := svc.CancelSeries(r.Context(), id, req.Reason, req.Except)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toSeriesResponse(series, svc.Now()))
+	}
+}
+
+func rescheduleSeriesHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := seriesID(w, r)
+		if !ok {
+			return
+		}
+
+		var req RescheduleSeriesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		shift, err := time.ParseDuration(req.Shift)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_shift", "shift must be a duration such as 168h or -30m")
+			return
+		}
+
+		series, err := svc.RescheduleSeries(r.Context(), id, shift, req.Except)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toSeriesResponse(series, svc.Now()))
+	}
+}
+
+func cancelOccurrenceHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, number, ok := occurrenceParams(w, r)
+		if !ok {
+			return
+		}
+
+		var req CancelSeriesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		if req.Reason == "" {
+			req.Reason = "occurrence_cancelled"
+		}
+
+		series, err := svc.CancelOccurrence(r.Context(), id, number, req.Reason)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toSeriesResponse(series, svc.Now()))
+	}
+}
+
+func rescheduleOccurrenceHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, number, ok := occurrenceParams(w, r)
+		if !ok {
+			return
+		}
+
+		var req RescheduleOccurrenceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+		slotID, err := uuid.Parse(req.SlotID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_slot_id", "slot_id must be a valid UUID")
+			return
+		}
+
+		series, err := svc.RescheduleOccurrence(r.Context(), id, number, slotID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toSeriesResponse(series, svc.Now()))
+	}
+}
+
+// seriesID parses the {id} of a series route, writing the 400 itself
+func seriesID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_series_id", "id must be a valid UUID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// occurrenceParams parses the {id} and {n} of an occurrence route
+func occurrenceParams(w http.ResponseWriter, r *http.Request) (uuid.UUID, int, bool) {
+	id, ok := seriesID(w, r)
+	if !ok {
+		return uuid.Nil, 0, false
+	}
+	n, err := strconv.Atoi(chi.URLParam(r, "n"))
+	if err != nil || n < 1 {
+		writeError(w, http.StatusBadRequest, "invalid_occurrence", "occurrence must be a positive integer")
+		return uuid.Nil, 0, false
+	}
+	return id, n, true
+}
+
+func toSeriesResponse(s *appointment.Series, now time.Time) SeriesResponse {
+	resp := SeriesResponse{
+		ID:           s.ID,
+		PatientID:    s.PatientID,
+		ClinicianID:  s.ClinicianID,
+		IntervalDays: s.IntervalDays,
+		TZ:           s.TimeZone,
+		CreatedAt:    s.CreatedAt,
+		Occurrences:  make([]SeriesOccurrenceResponse, len(s.Occurrences)),
+	}
+	for i, occ := range s.Occurrences {
+		resp.Occurrences[i] = SeriesOccurrenceResponse{
+			Occurrence:  occ.Number,
+			Exception:   occ.Exception,
+			StartTime:   occ.Slot.StartTime,
+			EndTime:     occ.Slot.EndTime,
+			Appointment: toAppointmentResponse(&occ.Appointment, now),
+		}
+	}
+	return resp
+}
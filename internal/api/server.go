@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/netutil"
+)
+
+// ServerConfig bounds how long clients may take and how much they may send.
+// Zero durations leave that timeout off.
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration // whole request including the body
+	WriteTimeout      time.Duration // from the end of the request headers to the end of the response
+	IdleTimeout       time.Duration // keep-alive connections between requests
+	MaxHeaderBytes    int
+	MaxConns          int  // open connections; further ones wait to be accepted, 0 is unlimited
+	H2C               bool // also serve HTTP/2 without TLS, for internal callers
+}
+
+// h2cMaxStreams bounds concurrent requests on one HTTP/2 connection
+const h2cMaxStreams = 250
+
+// NewServer builds an http.Server for addr with cfg's limits. Serve it with
+// Serve, which also applies MaxConns.
+func NewServer(addr string, handler http.Handler, cfg ServerConfig) *http.Server {
+	if cfg.H2C {
+		handler = h2c.NewHandler(handler, &http2.Server{
+			MaxConcurrentStreams: h2cMaxStreams,
+			IdleTimeout:          cfg.IdleTimeout,
+		})
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// Serve listens on server.Addr and serves until the server is shut down, at
+// most maxConns connections at a time when it is positive. Like
+// ListenAndServe it returns http.ErrServerClosed after Shutdown.
+func Serve(server *http.Server, maxConns int) error {
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+	if maxConns > 0 {
+		ln = netutil.LimitListener(ln, maxConns)
+	}
+	return server.Serve(ln)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// SlotConflictsHeader carries a slot's recent failed lock acquisitions on
+// booking conflicts and prechecks, so a client can offer a quieter slot
+// instead of retrying a hot one
+const SlotConflictsHeader = "X-Slot-Conflicts"
+
+// recentConflicts looks up the recent conflicts of slots. The counts are
+// only a hint, so when report is nil or fails the map is nil.
+func recentConflicts(ctx context.Context, report ContentionReport, slotIDs ...uuid.UUID) map[uuid.UUID]int64 {
+	if report == nil {
+		return nil
+	}
+	counts, err := report.RecentConflicts(ctx, slotIDs)
+	if err != nil {
+		log.Printf("slot conflicts: %v", err)
+		return nil
+	}
+	return counts
+}
+
+// setSlotConflicts sets SlotConflictsHeader for slotID and returns the
+// count, or nil when it is not known
+func setSlotConflicts(w http.ResponseWriter, r *http.Request, report ContentionReport, slotID uuid.UUID) *int64 {
+	counts := recentConflicts(r.Context(), report, slotID)
+	if counts == nil {
+		return nil
+	}
+	n := counts[slotID]
+	w.Header().Set(SlotConflictsHeader, strconv.FormatInt(n, 10))
+	return &n
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// splitSlotHandler cuts a slot into shorter back-to-back slots
+func splitSlotHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_slot_id", "id must be a valid UUID")
+			return
+		}
+
+		var req SplitSlotRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		parts, err := svc.SplitSlot(r.Context(), id, time.Duration(req.Minutes)*time.Minute)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := SplitSlotResponse{Slots: make([]SlotSummaryResponse, len(parts))}
+		for i := range parts {
+			resp.Slots[i] = toSlotSummaryResponse(&parts[i])
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// mergeSlotsHandler joins back-to-back slots into the earliest of them
+func mergeSlotsHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req MergeSlotsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		merge, err := svc.MergeSlots(r.Context(), req.SlotIDs)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, MergeSlotsResponse{
+			Slot:          toSlotSummaryResponse(merge.Slot),
+			MergedSlotIDs: merge.Merged,
+		})
+	}
+}
+
+func toSlotSummaryResponse(s *appointment.AppointmentSlot) SlotSummaryResponse {
+	return SlotSummaryResponse{
+		ID:        s.ID,
+		StartTime: s.StartTime,
+		EndTime:   s.EndTime,
+		Status:    string(s.Status),
+		Capacity:  s.Capacity,
+		SlotType:  s.SlotType,
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// SlotRoutedHeader marks a booking already forwarded to its slot owner so the
+// owner handles it even if its own view of the ring disagrees.
+const SlotRoutedHeader = "X-Slot-Routed"
+
+// SlotRouter resolves which instance should serve bookings for a slot
+type SlotRouter interface {
+	Route(slotID uuid.UUID) (addr string, local bool)
+}
+
+// SlotRoutingMiddleware forwards a booking to the instance that owns its slot.
+// Requests it cannot route (bad body, unknown owner) are passed through and
+// handled locally under the distributed lock.
+func SlotRoutingMiddleware(router SlotRouter) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+	proxies := make(map[string]*httputil.ReverseProxy)
+
+	proxyFor := func(addr string) (*httputil.ReverseProxy, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if p, ok := proxies[addr]; ok {
+			return p, nil
+		}
+		target, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		p := httputil.NewSingleHostReverseProxy(target)
+		p.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("slot owner %s unreachable: %v", addr, err)
+			writeError(w, http.StatusServiceUnavailable, "slot_owner_unavailable", "retry shortly")
+		}
+		proxies[addr] = p
+		return p, nil
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(SlotRoutedHeader) != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_request_body", "could not read body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			var req CreateAppointmentRequest
+			if err := json.Unmarshal(body, &req); err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			slotID, err := uuid.Parse(req.SlotID)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			addr, local := router.Route(slotID)
+			if local {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			proxy, err := proxyFor(addr)
+			if err != nil {
+				log.Printf("invalid slot owner address %q: %v", addr, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			r.Header.Set(SlotRoutedHeader, "1")
+			// Keep the ID so the owner's statements carry the same tag
+			r.Header.Set("X-Request-ID", GetRequestID(r.Context()))
+			proxy.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/sms"
+)
+
+// SMSConfig enables the webhook Twilio posts patients' SMS replies to
+type SMSConfig struct {
+	AuthToken  string // the Twilio account's auth token, which signs the webhook
+	WebhookURL string // public URL of the webhook as configured with Twilio, without the query
+}
+
+// smsMaxBody bounds an inbound SMS webhook; Twilio's forms are a few KB
+const smsMaxBody = 64 << 10
+
+// smsReplyText is what the patient is texted back for each outcome. Like
+// push notifications it names no appointment details.
+var smsReplyText = map[appointment.SMSOutcome]string{
+	appointment.SMSConfirmed:        "Thank you, your appointment is confirmed.",
+	appointment.SMSAwaitingApproval: "Thank you. The clinic will confirm your appointment once it has reviewed your booking.",
+	appointment.SMSCancelled:        "Your appointment has been cancelled.",
+	appointment.SMSNotUnderstood:    "Reply 1 to confirm your appointment or 2 to cancel it.",
+	appointment.SMSNoAppointment:    "We could not find an appointment waiting for your reply.",
+	appointment.SMSFailed:           "We could not update your appointment. Please contact the clinic.",
+}
+
+// twiML is the response Twilio texts back to the sender
+type twiML struct {
+	XMLName xml.Name `xml:"Response"`
+	Message string   `xml:"Message"`
+}
+
+// inboundSMSHandler acts on a patient's SMS reply, see
+// appointment.Service.HandleSMSReply. The Twilio signature is the only
+// credential; the tenant comes from the tenant query parameter of the URL
+// configured with Twilio, which the signature covers. A message Twilio
+// delivers again is answered as the first time.
+func inboundSMSHandler(svc *appointment.Service, cfg *SMSConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, smsMaxBody)
+		if err := r.ParseForm(); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse form")
+			return
+		}
+
+		fullURL := cfg.WebhookURL
+		if r.URL.RawQuery != "" {
+			fullURL += "?" + r.URL.RawQuery
+		}
+		if err := sms.VerifyTwilio(cfg.AuthToken, fullURL, r.PostForm, r.Header.Get("X-Twilio-Signature")); err != nil {
+			writeError(w, http.StatusForbidden, "invalid_signature", "the request is not signed by the carrier")
+			return
+		}
+
+		ctx := r.Context()
+		if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+			setAccessTenant(ctx, tenant)
+			ctx = shard.WithTenant(ctx, tenant)
+		}
+
+		reply, err := svc.HandleSMSReply(ctx, r.PostForm.Get("MessageSid"), r.PostForm.Get("From"), r.PostForm.Get("Body"))
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		body, err := xml.Marshal(twiML{Message: smsReplyText[reply.Outcome]})
+		if err != nil {
+			log.Printf("encode sms reply: %v", err)
+			return
+		}
+		_, _ = w.Write([]byte(xml.Header + string(body)))
+	}
+}
+
+// setPatientPhoneHandler sets the number the patient's SMS replies come
+// from
+func setPatientPhoneHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "id must be a valid UUID")
+			return
+		}
+
+		var req SetPatientPhoneRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		p, err := svc.SetPatientPhone(r.Context(), id, req.Phone)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, PatientPhoneResponse{PatientID: p.ID, Phone: p.Phone})
+	}
+}
+
+func clearPatientPhoneHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_patient_id", "id must be a valid UUID")
+			return
+		}
+
+		if _, err := svc.ClearPatientPhone(r.Context(), id); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
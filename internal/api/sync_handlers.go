@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// syncHandler returns the appointments of a clinic, or of one of its
+// clinicians, that changed since ?since, for clients that work offline and
+// catch up when they reconnect. The response is shaped by ?fields as on the
+// detail endpoint.
+func syncHandler(svc *appointment.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		clinicID, err := uuid.Parse(q.Get("clinic_id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_clinic_id", "clinic_id must be a valid UUID")
+			return
+		}
+		scope := appointment.SyncScope{ClinicID: clinicID}
+		if raw := q.Get("clinician_id"); raw != "" {
+			clinicianID, err := uuid.Parse(raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_clinician_id", "clinician_id must be a valid UUID")
+				return
+			}
+			scope.ClinicianID = &clinicianID
+		}
+
+		limit := appointment.MaxSyncChanges
+		if raw := q.Get("limit"); raw != "" {
+			l, err := strconv.Atoi(raw)
+			if err != nil || l < 1 || l > appointment.MaxSyncChanges {
+				writeError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and "+strconv.Itoa(appointment.MaxSyncChanges))
+				return
+			}
+			limit = l
+		}
+
+		fields, err := parseFields(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_fields", err.Error())
+			return
+		}
+
+		page, err := svc.Sync(r.Context(), scope, q.Get("since"), limit, fields.related)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if !recordPIIAccess(w, r, svc, shownPatients(page.Appointments)...) {
+			return
+		}
+
+		resp := SyncResponse{
+			Cursor:       page.Cursor,
+			HasMore:      page.HasMore,
+			Appointments: make([]AppointmentDetailResponse, len(page.Appointments)),
+			Removed:      make([]uuid.UUID, 0, len(page.Removed)),
+		}
+		now := svc.Now()
+		for i := range page.Appointments {
+			resp.Appointments[i] = toAppointmentDetailResponse(&page.Appointments[i], now, fields)
+		}
+		resp.Removed = append(resp.Removed, page.Removed...)
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
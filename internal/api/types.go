@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,6 +10,25 @@ import (
 type CreateAppointmentRequest struct {
 	SlotID    string `json:"slot_id"`
 	PatientID string `json:"patient_id"`
+	// SlotCount books slot_id and the clinician's slots that follow it back
+	// to back as one appointment; 0 and 1 book slot_id alone
+	SlotCount int `json:"slot_count,omitempty"`
+	// Resources are staff the appointment needs besides the clinician
+	Resources []ResourceRequirementRequest `json:"resources,omitempty"`
+	// Reason is why the patient is coming, e.g. "persistent cough"
+	Reason *string `json:"reason,omitempty"`
+}
+
+type ResourceRequirementRequest struct {
+	Kind     string `json:"kind"`               // interpreter or chaperone
+	Language string `json:"language,omitempty"` // required for an interpreter
+}
+
+type StaffResourceResponse struct {
+	ID       uuid.UUID `json:"id"`
+	Kind     string    `json:"kind"`
+	Language *string   `json:"language,omitempty"`
+	Name     string    `json:"name"`
 }
 
 type AppointmentResponse struct {
@@ -17,42 +37,942 @@ type AppointmentResponse struct {
 	PatientID uuid.UUID  `json:"patient_id"`
 	Status    string     `json:"status"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// Only set while the appointment is pending; clients count down from
+	// this rather than comparing expires_at with their own clock
+	SecondsUntilExpiry *int64    `json:"seconds_until_expiry,omitempty"`
+	ServerTime         time.Time `json:"server_time"`
+
+	Span      *SpanResponse           `json:"span,omitempty"`
+	Resources []StaffResourceResponse `json:"resources,omitempty"`
+	Reason    *string                 `json:"reason,omitempty"`
+}
+
+// CheckInResponse is a checked-in appointment with its check-in time
+type CheckInResponse struct {
+	AppointmentResponse
+	CheckedInAt time.Time `json:"checked_in_at"`
+}
+
+// SpanResponse is the composed time range of an appointment spanning
+// several slots
+type SpanResponse struct {
+	StartTime time.Time   `json:"start_time"`
+	EndTime   time.Time   `json:"end_time"`
+	SlotIDs   []uuid.UUID `json:"slot_ids"`
+}
+
+// ReviewAppointmentRequest is the optional body of approve and reject
+type ReviewAppointmentRequest struct {
+	Reviewer string `json:"reviewer"`
+	Note     string `json:"note"`
+}
+
+// AttendanceRequest is the optional body of complete and no-show
+type AttendanceRequest struct {
+	RecordedBy string `json:"recorded_by"`
+	Note       string `json:"note"`
+}
+
+type RescheduleAppointmentRequest struct {
+	SlotID string `json:"slot_id"`
+}
+
+// RescheduleResponse carries the appointment in its new slot and the one it
+// replaced, now cancelled
+type RescheduleResponse struct {
+	Appointment AppointmentResponse `json:"appointment"`
+	Previous    AppointmentResponse `json:"previous"`
+}
+
+type TransferHoldRequest struct {
+	SlotID string `json:"slot_id"`
+}
+
+type AppointmentTTLResponse struct {
+	ID                 uuid.UUID  `json:"id"`
+	Status             string     `json:"status"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	SecondsUntilExpiry *int64     `json:"seconds_until_expiry,omitempty"`
+	ServerTime         time.Time  `json:"server_time"`
 }
 
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Details string `json:"details,omitempty"`
+	Error     string `json:"error"`
+	Details   string `json:"details,omitempty"`
+	Retryable bool   `json:"retryable,omitempty"`
+}
+
+// BookingQueuedResponse is the 429 of a booking attempt over its clinic's
+// rate. The attempt is retried with queue_ticket in X-Queue-Ticket.
+type BookingQueuedResponse struct {
+	ErrorResponse
+	QueueTicket          string `json:"queue_ticket"`
+	QueuePosition        int    `json:"queue_position"`
+	EstimatedWaitSeconds int    `json:"estimated_wait_seconds"`
 }
 
+// AppointmentDetailResponse is the hydrated appointment. With ?fields the
+// related entities and audit timestamps not asked for are left out.
 type AppointmentDetailResponse struct {
-	ID        uuid.UUID  `json:"id"`
-	Status    string      `json:"status"`
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
-	ExpiresAt *time.Time  `json:"expires_at,omitempty"`
-
-	Slot struct {
-		ID        uuid.UUID  `json:"id"`
-		StartTime time.Time  `json:"start_time"`
-		EndTime   time.Time  `json:"end_time"`
-		Status    string     `json:"status"`
-		Capacity  int        `json:"capacity"`
-	} `json:"slot"`
-
-	Patient struct {
-		ID    uuid.UUID `json:"id"`
-		Name  string    `json:"name"`
-		Email *string   `json:"email,omitempty"`
-	} `json:"patient"`
-
-	Clinician struct {
-		ID        uuid.UUID `json:"id"`
-		Name      string    `json:"name"`
-		Specialty *string   `json:"specialty,omitempty"`
-	} `json:"clinician"`
+	ID                 uuid.UUID  `json:"id"`
+	Status             string     `json:"status"`
+	CreatedAt          *time.Time `json:"created_at,omitempty"`
+	UpdatedAt          *time.Time `json:"updated_at,omitempty"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	SecondsUntilExpiry *int64     `json:"seconds_until_expiry,omitempty"`
+	CheckedInAt        *time.Time `json:"checked_in_at,omitempty"`
+	Reason             *string    `json:"reason,omitempty"`
+	ServerTime         time.Time  `json:"server_time"`
+
+	Slot      *SlotSummaryResponse      `json:"slot,omitempty"`
+	Span      *SpanResponse             `json:"span,omitempty"`
+	Resources []StaffResourceResponse   `json:"resources,omitempty"`
+	Patient   *PatientSummaryResponse   `json:"patient,omitempty"`
+	Clinician *ClinicianSummaryResponse `json:"clinician,omitempty"`
+	Intake    string                    `json:"intake_status,omitempty"`
+	Notes     []AppointmentNoteResponse `json:"notes,omitempty"`
+}
+
+type AddNoteRequest struct {
+	Body string `json:"body"`
+}
+
+type AppointmentNoteResponse struct {
+	ID            uuid.UUID `json:"id"`
+	AppointmentID uuid.UUID `json:"appointment_id"`
+	Author        string    `json:"author"`
+	Body          string    `json:"body"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type SlotSummaryResponse struct {
+	ID        uuid.UUID      `json:"id"`
+	StartTime time.Time      `json:"start_time"`
+	EndTime   time.Time      `json:"end_time"`
+	Status    string         `json:"status"`
+	Capacity  int            `json:"capacity"`
+	SlotType  *string        `json:"slot_type,omitempty"`
+	Price     *PriceResponse `json:"price,omitempty"`
+}
+
+type PatientSummaryResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Email       *string   `json:"email,omitempty"`
+	Deactivated bool      `json:"deactivated,omitempty"`
+}
+
+type ClinicianSummaryResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Specialty *string   `json:"specialty,omitempty"`
+}
+
+type CreateClinicianRequest struct {
+	Name      string     `json:"name"`
+	Specialty *string    `json:"specialty,omitempty"`
+	ClinicID  *uuid.UUID `json:"clinic_id,omitempty"`
+}
+
+// UpdateClinicianRequest changes the fields present; absent ones are kept
+type UpdateClinicianRequest struct {
+	Name      *string `json:"name,omitempty"`
+	Specialty *string `json:"specialty,omitempty"`
+}
+
+type ClinicianResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	Specialty     *string    `json:"specialty"`
+	ClinicID      *uuid.UUID `json:"clinic_id"`
+	Deactivated   bool       `json:"deactivated"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
 type AppointmentListResponse struct {
+	Appointments  []AppointmentDetailResponse `json:"appointments"`
+	Total         int                         `json:"total,omitempty"`
+	NextPageToken string                      `json:"next_page_token,omitempty"`
+}
+
+// AppointmentSummaryListResponse is the list response without ?include or
+// ?fields: appointment rows only
+type AppointmentSummaryListResponse struct {
+	Appointments  []AppointmentResponse `json:"appointments"`
+	Total         int                   `json:"total,omitempty"`
+	NextPageToken string                `json:"next_page_token,omitempty"`
+}
+
+// ClinicAppointmentsResponse holds AppointmentResponse or
+// AppointmentDetailResponse items depending on ?include and ?fields
+type ClinicAppointmentsResponse struct {
+	Appointments []any `json:"appointments"`
+	Total        int   `json:"total"`
+}
+
+type BatchGetAppointmentsRequest struct {
+	IDs []string `json:"ids"`
+}
+
+type BatchGetAppointmentsResponse struct {
+	Appointments []AppointmentDetailResponse `json:"appointments"`
+	NotFound     []uuid.UUID                 `json:"not_found"`
+}
+
+type SyncResponse struct {
+	Cursor       string                      `json:"cursor"` // pass as since on the next sync
+	HasMore      bool                        `json:"has_more"`
 	Appointments []AppointmentDetailResponse `json:"appointments"`
-	Total        int                         `json:"total,omitempty"`
+	Removed      []uuid.UUID                 `json:"removed"`
+}
+
+type AvailabilityVersionResponse struct {
+	ClinicianID uuid.UUID `json:"clinician_id"`
+	Version     int64     `json:"version"`
+}
+
+type CalendarDayResponse struct {
+	Date   string `json:"date"` // YYYY-MM-DD in the requested zone
+	Slots  int    `json:"slots"`
+	Open   int    `json:"open"`
+	Booked int    `json:"booked"`
+}
+
+type AvailabilityCalendarResponse struct {
+	ClinicianID uuid.UUID             `json:"clinician_id"`
+	Month       string                `json:"month"`
+	TZ          string                `json:"tz"`
+	Days        []CalendarDayResponse `json:"days"`
+}
+
+// AvailabilityPeriodRequest is a weekly period of availability. Weekday is
+// the day's English name, such as monday; start and end are HH:MM, with end
+// 24:00 for midnight.
+type AvailabilityPeriodRequest struct {
+	Weekday string `json:"weekday"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+// PutAvailabilityTemplateRequest sets a clinician's weekly availability.
+// Capacity defaults to 1.
+type PutAvailabilityTemplateRequest struct {
+	TimeZone    string                      `json:"time_zone"`
+	SlotMinutes int                         `json:"slot_minutes"`
+	Capacity    *int                        `json:"capacity,omitempty"`
+	SlotType    *string                     `json:"slot_type,omitempty"`
+	Periods     []AvailabilityPeriodRequest `json:"periods"`
+}
+
+type AvailabilityTemplateResponse struct {
+	ClinicianID uuid.UUID                   `json:"clinician_id"`
+	TimeZone    string                      `json:"time_zone"`
+	SlotMinutes int                         `json:"slot_minutes"`
+	Capacity    int                         `json:"capacity"`
+	SlotType    *string                     `json:"slot_type,omitempty"`
+	Periods     []AvailabilityPeriodRequest `json:"periods"`
+	UpdatedAt   time.Time                   `json:"updated_at"`
+}
+
+// GenerateSlotsRequest is the range of dates, YYYY-MM-DD and both
+// included, to generate slots for
+type GenerateSlotsRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type GenerateSlotsResponse struct {
+	ClinicianID uuid.UUID             `json:"clinician_id"`
+	Created     int                   `json:"created"`
+	Skipped     int                   `json:"skipped"` // overlapping slots the clinician already had
+	Slots       []SlotSummaryResponse `json:"slots"`
+}
+
+// TimelineEntryResponse is one event of a patient's history. Stage is the
+// step it marks, e.g. booked or confirmed; details is the event payload.
+type TimelineEntryResponse struct {
+	EventID           int64           `json:"event_id"`
+	EventType         string          `json:"event_type"`
+	Stage             string          `json:"stage"`
+	At                time.Time       `json:"at"`
+	AppointmentID     uuid.UUID       `json:"appointment_id"`
+	AppointmentStatus string          `json:"appointment_status"`
+	SlotID            uuid.UUID       `json:"slot_id"`
+	SlotStart         time.Time       `json:"slot_start"`
+	SlotEnd           time.Time       `json:"slot_end"`
+	Details           json.RawMessage `json:"details,omitempty"`
+}
+
+type PatientTimelineResponse struct {
+	PatientID uuid.UUID               `json:"patient_id"`
+	Entries   []TimelineEntryResponse `json:"entries"`
+	Truncated bool                    `json:"truncated"` // older events were left out
+}
+
+type CancelPatientAppointmentsRequest struct {
+	Reason string `json:"reason"`
+}
+
+type PatientCancellationResponse struct {
+	PatientID    uuid.UUID                      `json:"patient_id"`
+	Cancelled    int                            `json:"cancelled"`
+	ByStatus     map[string]int                 `json:"by_status"` // cancelled appointments by their previous status
+	Appointments []CancelledAppointmentResponse `json:"appointments"`
+}
+
+type CancelledAppointmentResponse struct {
+	ID             uuid.UUID `json:"id"`
+	SlotID         uuid.UUID `json:"slot_id"`
+	Status         string    `json:"status"`
+	PreviousStatus string    `json:"previous_status"`
+}
+
+type SlotReassignmentResponse struct {
+	SlotID              uuid.UUID                       `json:"slot_id"`
+	ClinicianID         uuid.UUID                       `json:"clinician_id"`
+	PreviousClinicianID uuid.UUID                       `json:"previous_clinician_id"`
+	StartTime           time.Time                       `json:"start_time"`
+	EndTime             time.Time                       `json:"end_time"`
+	Appointments        []ReassignedAppointmentResponse `json:"appointments"` // active appointments whose patients are notified
+}
+
+type SplitSlotRequest struct {
+	Minutes int `json:"minutes"` // length of each slot the split makes
+}
+
+type SplitSlotResponse struct {
+	Slots []SlotSummaryResponse `json:"slots"` // the first keeps the split slot's ID
+}
+
+type MergeSlotsRequest struct {
+	SlotIDs []uuid.UUID `json:"slot_ids"`
+}
+
+type MergeSlotsResponse struct {
+	Slot          SlotSummaryResponse `json:"slot"`
+	MergedSlotIDs []uuid.UUID         `json:"merged_slot_ids"` // deleted into slot
+}
+
+type ReassignedAppointmentResponse struct {
+	ID        uuid.UUID `json:"id"`
+	PatientID uuid.UUID `json:"patient_id"`
+	Status    string    `json:"status"`
+}
+
+type CreatePatientRequest struct {
+	Name  string  `json:"name"`
+	Email *string `json:"email,omitempty"`
+}
+
+// UpdatePatientRequest changes the fields present; absent ones are kept
+type UpdatePatientRequest struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+}
+
+type PatientResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	Email         *string    `json:"email"`
+	Phone         *string    `json:"phone"`
+	Deactivated   bool       `json:"deactivated"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type PatientSearchResponse struct {
+	Patients      []PatientResponse `json:"patients"`
+	NextPageToken string            `json:"next_page_token,omitempty"`
+}
+
+type DeactivatePatientRequest struct {
+	Reason string `json:"reason"`
+}
+
+type PatientStatusResponse struct {
+	PatientID          uuid.UUID                    `json:"patient_id"`
+	Deactivated        bool                         `json:"deactivated"`
+	DeactivatedAt      *time.Time                   `json:"deactivated_at,omitempty"`
+	DeactivatedBy      *string                      `json:"deactivated_by,omitempty"`
+	DeactivationReason *string                      `json:"deactivation_reason,omitempty"`
+	CancelledHolds     *PatientCancellationResponse `json:"cancelled_holds,omitempty"`
+}
+
+type SetPatientPhoneRequest struct {
+	Phone string `json:"phone"`
+}
+
+type PatientPhoneResponse struct {
+	PatientID uuid.UUID `json:"patient_id"`
+	Phone     *string   `json:"phone"`
+}
+
+// RegisterDeviceRequest registers a device for push notifications.
+// Provider is fcm or apns, whichever issued Token.
+type RegisterDeviceRequest struct {
+	Provider string `json:"provider"`
+	Token    string `json:"token"`
+}
+
+type DeviceResponse struct {
+	ID        uuid.UUID `json:"id"`
+	PatientID uuid.UUID `json:"patient_id"`
+	Provider  string    `json:"provider"`
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type DeviceListResponse struct {
+	Devices []DeviceResponse `json:"devices"`
+	Count   int              `json:"count"`
+}
+
+// CreateAPIKeyRequest issues a key for an integration. A key with a
+// ClinicID only reaches that clinic's appointments.
+type CreateAPIKeyRequest struct {
+	Name     string     `json:"name"`
+	Scopes   []string   `json:"scopes"`
+	ClinicID *uuid.UUID `json:"clinic_id,omitempty"`
+}
+
+type APIKeyResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	ClinicID  *uuid.UUID `json:"clinic_id,omitempty"`
+	Key       string     `json:"key,omitempty"` // only returned on create
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type APIKeyListResponse struct {
+	APIKeys []APIKeyResponse `json:"api_keys"`
+	Count   int              `json:"count"`
+}
+
+// IVRLookupRequest names the number a caller rings from
+type IVRLookupRequest struct {
+	Phone string `json:"phone"`
+}
+
+// IVRAppointmentResponse is what a phone tree learns of an appointment:
+// enough to read it to the caller, and the reference to act on it by
+type IVRAppointmentResponse struct {
+	Reference     uuid.UUID  `json:"reference"`
+	Status        string     `json:"status"`
+	StartTime     *time.Time `json:"start_time,omitempty"`
+	EndTime       *time.Time `json:"end_time,omitempty"`
+	ClinicianName string     `json:"clinician_name,omitempty"`
+}
+
+type CreateSeriesRequest struct {
+	PatientID    string `json:"patient_id"`
+	SlotID       string `json:"slot_id"` // the first occurrence
+	IntervalDays int    `json:"interval_days"`
+	Occurrences  int    `json:"occurrences"`
+	TZ           string `json:"tz"` // zone that keeps the time of day, UTC when empty
+}
+
+// CancelSeriesRequest is the optional body of a series or occurrence cancel.
+// Except is ignored for a single occurrence.
+type CancelSeriesRequest struct {
+	Reason string `json:"reason"`
+	Except []int  `json:"except"`
+}
+
+type RescheduleSeriesRequest struct {
+	Shift  string `json:"shift"` // Go duration, e.g. "168h" for a week later
+	Except []int  `json:"except"`
+}
+
+type RescheduleOccurrenceRequest struct {
+	SlotID string `json:"slot_id"`
+}
+
+type SeriesResponse struct {
+	ID           uuid.UUID                  `json:"id"`
+	PatientID    uuid.UUID                  `json:"patient_id"`
+	ClinicianID  uuid.UUID                  `json:"clinician_id"`
+	IntervalDays int                        `json:"interval_days"`
+	TZ           string                     `json:"tz"`
+	CreatedAt    time.Time                  `json:"created_at"`
+	Occurrences  []SeriesOccurrenceResponse `json:"occurrences"`
+}
+
+type SeriesOccurrenceResponse struct {
+	Occurrence  int                 `json:"occurrence"`
+	Exception   bool                `json:"exception"` // changed on its own
+	StartTime   time.Time           `json:"start_time"`
+	EndTime     time.Time           `json:"end_time"`
+	Appointment AppointmentResponse `json:"appointment"`
+}
+
+type PriceResponse struct {
+	Amount      string `json:"amount"`
+	AmountMinor int64  `json:"amount_minor"`
+	Currency    string `json:"currency"`
+}
+
+// PrecheckBookingRequest is CreateAppointmentRequest without the slot,
+// which the path names
+type PrecheckBookingRequest struct {
+	PatientID string                       `json:"patient_id"`
+	SlotCount int                          `json:"slot_count,omitempty"`
+	Resources []ResourceRequirementRequest `json:"resources,omitempty"`
+}
+
+// PrecheckBookingResponse describes the booking the request would make.
+// Remaining is the room left in its fullest slot. Conflicts are the
+// patient's active appointments at the same time, which do not stop it.
+type PrecheckBookingResponse struct {
+	SlotID           uuid.UUID               `json:"slot_id"`
+	Span             SpanResponse            `json:"span"`
+	Remaining        int                     `json:"remaining"`
+	RequiresApproval bool                    `json:"requires_approval"`
+	Resources        []StaffResourceResponse `json:"resources,omitempty"`
+	Conflicts        []AppointmentResponse   `json:"conflicts"`
+	// SlotConflicts counts other requests recently turned away by the slot's
+	// lock, left out when not known
+	SlotConflicts *int64 `json:"slot_conflicts,omitempty"`
+}
+
+// SlotSearchItemResponse is a slot found by GET /slots. Remaining is the
+// room left once confirmed appointments and live holds have taken their
+// places; Price is the clinic's self-pay price for the slot type, left out
+// when it has none. Conflicts counts bookings of the slot recently turned
+// away because another was in progress.
+type SlotSearchItemResponse struct {
+	ID            uuid.UUID      `json:"id"`
+	ClinicianID   uuid.UUID      `json:"clinician_id"`
+	ClinicianName string         `json:"clinician_name"`
+	Specialty     *string        `json:"specialty"`
+	SlotType      *string        `json:"slot_type"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	Capacity      int            `json:"capacity"`
+	Remaining     int            `json:"remaining"`
+	Price         *PriceResponse `json:"price,omitempty"`
+	Conflicts     int64          `json:"conflicts"`
+}
+
+type SlotSearchResponse struct {
+	Slots         []SlotSearchItemResponse `json:"slots"`
+	Count         int                      `json:"count"`
+	NextPageToken string                   `json:"next_page_token,omitempty"`
+}
+
+type SlotQuoteResponse struct {
+	SlotID   uuid.UUID     `json:"slot_id"`
+	ClinicID uuid.UUID     `json:"clinic_id"`
+	SlotType string        `json:"slot_type"`
+	Price    PriceResponse `json:"price"`
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+}
+
+type WebhookSubscriptionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	Secret     string    `json:"secret,omitempty"` // only returned on create and rotate
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type WebhookListResponse struct {
+	Webhooks []WebhookSubscriptionResponse `json:"webhooks"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             int64     `json:"id"`
+	EventType      string    `json:"event_type"`
+	Succeeded      bool      `json:"succeeded"`
+	ResponseStatus *int      `json:"response_status,omitempty"`
+	Error          *string   `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type WebhookDeliveryListResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
+
+type InstanceResponse struct {
+	ID          string    `json:"id"`
+	Hostname    string    `json:"hostname"`
+	Addr        string    `json:"addr"`
+	Version     string    `json:"version"`
+	StartedAt   time.Time `json:"started_at"`
+	LastSeen    time.Time `json:"last_seen"`
+	RequestRate float64   `json:"request_rate"`
+}
+
+type ClusterResponse struct {
+	Instances []InstanceResponse `json:"instances"`
+	Count     int                `json:"count"`
+	Versions  map[string]int     `json:"versions"`
+}
+
+type SlotContentionResponse struct {
+	SlotID    uuid.UUID `json:"slot_id"`
+	Conflicts int64     `json:"conflicts"`
+}
+
+// LockStatsResponse counters are for the answering instance only; the
+// contended slots ranking is shared by the whole cluster.
+type LockStatsResponse struct {
+	Attempts          int64                    `json:"attempts"`
+	Contended         int64                    `json:"contended"`
+	Errors            int64                    `json:"errors"`
+	Holds             int64                    `json:"holds"`
+	MeanHoldSeconds   float64                  `json:"mean_hold_seconds"`
+	TopContended      []SlotContentionResponse `json:"top_contended,omitempty"`
+	TopContendedError *string                  `json:"top_contended_error,omitempty"`
+}
+
+type AdminStatsResponse struct {
+	Locks LockStatsResponse `json:"locks"`
+}
+
+type ExpiryEventMismatchResponse struct {
+	AppointmentID uuid.UUID `json:"appointment_id"`
+	Status        string    `json:"status"`
+	ExpiredEvents int       `json:"expired_events"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type ExpiryEventReportResponse struct {
+	Since      time.Time                     `json:"since"`
+	Mismatches []ExpiryEventMismatchResponse `json:"mismatches"`
+	Count      int                           `json:"count"`
+}
+
+type OrphanedAppointmentResponse struct {
+	AppointmentID uuid.UUID  `json:"appointment_id"`
+	Kind          string     `json:"kind"`
+	Status        string     `json:"status"`
+	SlotID        uuid.UUID  `json:"slot_id"`
+	SlotStatus    string     `json:"slot_status"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type OrphanReportResponse struct {
+	Orphans []OrphanedAppointmentResponse `json:"orphans"`
+	Count   int                           `json:"count"`
+}
+
+// ExpiryBacklogBucketResponse counts holds overdue by at least
+// overdue_from_seconds and less than overdue_to_seconds, which is absent
+// for the last bucket
+type ExpiryBacklogBucketResponse struct {
+	OverdueFromSeconds int64  `json:"overdue_from_seconds"`
+	OverdueToSeconds   *int64 `json:"overdue_to_seconds,omitempty"`
+	Count              int    `json:"count"`
+}
+
+type ExpiryBacklogResponse struct {
+	At                   time.Time                     `json:"at"`
+	Total                int                           `json:"total"`
+	OldestExpiresAt      *time.Time                    `json:"oldest_expires_at"`
+	OldestOverdueSeconds *int64                        `json:"oldest_overdue_seconds"`
+	Buckets              []ExpiryBacklogBucketResponse `json:"buckets"`
+}
+
+// PutBookingWindowRequest sets how far ahead a specialty can be booked.
+// Leads are Go durations such as "336h"; an empty max_lead has no maximum.
+type PutBookingWindowRequest struct {
+	MinLead string `json:"min_lead"`
+	MaxLead string `json:"max_lead,omitempty"`
+}
+
+type BookingWindowResponse struct {
+	Specialty string    `json:"specialty"`
+	MinLead   string    `json:"min_lead"`
+	MaxLead   *string   `json:"max_lead"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type BookingWindowListResponse struct {
+	Windows []BookingWindowResponse `json:"windows"`
+	Count   int                     `json:"count"`
+}
+
+type HeldLockResponse struct {
+	Key                 string   `json:"key"`
+	Token               string   `json:"token"`
+	AgeSeconds          *float64 `json:"age_seconds"`
+	TTLRemainingSeconds *float64 `json:"ttl_remaining_seconds"`
+}
+
+type HeldLocksResponse struct {
+	Locks []HeldLockResponse `json:"locks"`
+	Count int                `json:"count"`
+}
+
+type ReleaseLockRequest struct {
+	Token  string `json:"token"`
+	Reason string `json:"reason"`
+}
+
+type ReleaseLockResponse struct {
+	Key      string `json:"key"`
+	Released bool   `json:"released"`
+}
+
+type RegionResponse struct {
+	Role                  string     `json:"role"`
+	ReadOnly              bool       `json:"read_only"`
+	DatabaseInRecovery    bool       `json:"database_in_recovery"`
+	ReplicationLagSeconds *float64   `json:"replication_lag_seconds,omitempty"`
+	MaxLagSeconds         float64    `json:"max_lag_seconds"`
+	PromotedAt            *time.Time `json:"promoted_at,omitempty"`
+}
+
+type PromoteRegionRequest struct {
+	PromoteDatabase bool `json:"promote_database"`
+}
+
+type BulkCancellationResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	ClinicID    uuid.UUID  `json:"clinic_id"`
+	ClinicianID *uuid.UUID `json:"clinician_id,omitempty"`
+	Date        string     `json:"date"`
+	WindowStart time.Time  `json:"window_start"`
+	WindowEnd   time.Time  `json:"window_end"`
+	State       string     `json:"state"`
+	Total       int        `json:"total"`
+	Cancelled   int        `json:"cancelled"`
+	Failed      int        `json:"failed"`
+	LastError   *string    `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// AttachmentResponse describes an uploaded attachment. DownloadURL is a
+// signed link, relative to the API, that works without credentials until
+// DownloadExpiresAt.
+type AttachmentResponse struct {
+	ID                uuid.UUID `json:"id"`
+	AppointmentID     uuid.UUID `json:"appointment_id"`
+	Kind              string    `json:"kind"`
+	Filename          string    `json:"filename"`
+	ContentType       string    `json:"content_type"`
+	SizeBytes         int64     `json:"size_bytes"`
+	ScanStatus        string    `json:"scan_status"`
+	CreatedAt         time.Time `json:"created_at"`
+	DownloadURL       string    `json:"download_url"`
+	DownloadExpiresAt time.Time `json:"download_expires_at"`
+}
+
+// ActionLinksResponse holds signed links, relative to the API, that let the
+// patient act on the appointment without credentials until ExpiresAt.
+// ConfirmURL is only set while the appointment is pending.
+type ActionLinksResponse struct {
+	AppointmentID uuid.UUID `json:"appointment_id"`
+	ConfirmURL    string    `json:"confirm_url,omitempty"`
+	CancelURL     string    `json:"cancel_url"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+type ActionLinkPreviewResponse struct {
+	Action      string                    `json:"action"`
+	Appointment AppointmentDetailResponse `json:"appointment"`
+}
+
+type AttachmentListResponse struct {
+	Attachments []AttachmentResponse `json:"attachments"`
+	Count       int                  `json:"count"`
+}
+
+// SubmitFeedbackRequest rates an appointment from 1 to 5
+type SubmitFeedbackRequest struct {
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type FeedbackResponse struct {
+	AppointmentID uuid.UUID `json:"appointment_id"`
+	ClinicianID   uuid.UUID `json:"clinician_id"`
+	Rating        int       `json:"rating"`
+	Comment       *string   `json:"comment"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type FeedbackListResponse struct {
+	Feedback []FeedbackResponse `json:"feedback"`
+	Count    int                `json:"count"`
+}
+
+// ClinicianRatingResponse aggregates a clinician's ratings. Ratings counts
+// the ratings of 1 to 5 in order.
+type ClinicianRatingResponse struct {
+	ClinicianID   uuid.UUID `json:"clinician_id"`
+	Name          string    `json:"name"`
+	Specialty     *string   `json:"specialty"`
+	Count         int       `json:"count"`
+	AverageRating float64   `json:"average_rating"`
+	Ratings       [5]int    `json:"ratings"`
+}
+
+type ClinicianRatingReportResponse struct {
+	Since      time.Time                 `json:"since"`
+	Clinicians []ClinicianRatingResponse `json:"clinicians"`
+	Count      int                       `json:"count"`
+}
+
+// IntakeQuestionRequest is one question of an intake template. Options are
+// the accepted answers of a choice question.
+type IntakeQuestionRequest struct {
+	ID       string   `json:"id"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+}
+
+type PutIntakeTemplateRequest struct {
+	Questions []IntakeQuestionRequest `json:"questions"`
+}
+
+type IntakeTemplateResponse struct {
+	SlotType  string                  `json:"slot_type"`
+	Version   int                     `json:"version"`
+	Questions []IntakeQuestionRequest `json:"questions"`
+	UpdatedAt time.Time               `json:"updated_at"`
+}
+
+type IntakeTemplateListResponse struct {
+	Templates []IntakeTemplateResponse `json:"templates"`
+	Count     int                      `json:"count"`
+}
+
+// SubmitIntakeRequest answers intake questions by question ID. Every answer
+// is a string: "yes" or "no", one of a choice question's options, a number
+// or a YYYY-MM-DD date. An empty answer clears an earlier one.
+type SubmitIntakeRequest struct {
+	Answers map[string]string `json:"answers"`
+}
+
+// IntakeAnswerResponse pairs a question with its answer, so answers are not
+// keyed by question IDs the compat encoder would rename
+type IntakeAnswerResponse struct {
+	IntakeQuestionRequest
+	Answer *string `json:"answer"`
+}
+
+type IntakeResponse struct {
+	AppointmentID   uuid.UUID              `json:"appointment_id"`
+	Status          string                 `json:"status"`
+	SlotType        *string                `json:"slot_type,omitempty"`
+	TemplateVersion *int                   `json:"template_version,omitempty"`
+	Questions       []IntakeAnswerResponse `json:"questions"`
+	UpdatedAt       *time.Time             `json:"updated_at,omitempty"`
+}
+
+// PutRetentionPolicyRequest sets how many months each kind of record is
+// kept. A null or missing period keeps those records forever.
+type PutRetentionPolicyRequest struct {
+	AppointmentMonths *int `json:"appointment_months"`
+	EventMonths       *int `json:"event_months"`
+	FeedbackMonths    *int `json:"feedback_months"`
+}
+
+type RetentionPolicyResponse struct {
+	TenantID          string    `json:"tenant_id"`
+	AppointmentMonths *int      `json:"appointment_months"`
+	EventMonths       *int      `json:"event_months"`
+	FeedbackMonths    *int      `json:"feedback_months"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type RetentionRunResponse struct {
+	ID           uuid.UUID `json:"id"`
+	Entity       string    `json:"entity"`
+	TenantID     string    `json:"tenant_id"`
+	RetainMonths int       `json:"retain_months"`
+	Cutoff       time.Time `json:"cutoff"`
+	DryRun       bool      `json:"dry_run"`
+	Matched      int       `json:"matched"`
+	Deleted      int       `json:"deleted"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+}
+
+type RetentionRunListResponse struct {
+	Runs  []RetentionRunResponse `json:"runs"`
+	Count int                    `json:"count"`
+}
+
+type PIIAccessResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Actor      string    `json:"actor"`
+	ActorKind  string    `json:"actor_kind"`
+	Reason     *string   `json:"reason,omitempty"`
+	PatientID  uuid.UUID `json:"patient_id"`
+	Endpoint   string    `json:"endpoint"`
+	RequestID  string    `json:"request_id,omitempty"`
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+type PIIAccessReportResponse struct {
+	From     time.Time           `json:"from"`
+	To       time.Time           `json:"to"`
+	Accesses []PIIAccessResponse `json:"accesses"`
+	Count    int                 `json:"count"`
+}
+
+// WidgetSlotResponse is an open slot as the booking widget shows it
+type WidgetSlotResponse struct {
+	ID            uuid.UUID `json:"id"`
+	ClinicianID   uuid.UUID `json:"clinician_id"`
+	ClinicianName string    `json:"clinician_name"`
+	Specialty     *string   `json:"specialty"`
+	SlotType      *string   `json:"slot_type"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	Remaining     int       `json:"remaining"`
+	// Price is the clinic's self-pay price for the slot type, left out when
+	// it has none
+	Price *PriceResponse `json:"price,omitempty"`
+	// Conflicts counts bookings of the slot recently turned away because
+	// another was in progress; a busy slot is likelier to be gone
+	Conflicts int64 `json:"conflicts"`
+}
+
+type WidgetSlotListResponse struct {
+	Slots         []WidgetSlotResponse `json:"slots"`
+	Count         int                  `json:"count"`
+	NextPageToken string               `json:"next_page_token,omitempty"`
+}
+
+// WidgetBookingRequest books a slot from the widget. ChallengeToken is the
+// token the widget's challenge produced.
+type WidgetBookingRequest struct {
+	SlotID         uuid.UUID `json:"slot_id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	ChallengeToken string    `json:"challenge_token"`
+}
+
+// WidgetBookingResponse leaves out the patient, since anyone can book with
+// any email. CancelURL is set when action links are enabled.
+type WidgetBookingResponse struct {
+	AppointmentID uuid.UUID `json:"appointment_id"`
+	Status        string    `json:"status"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	CancelURL     string    `json:"cancel_url,omitempty"`
 }
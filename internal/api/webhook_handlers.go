@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/webhook"
+)
+
+func createWebhookHandler(svc *webhook.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateWebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		sub, err := svc.CreateSubscription(r.Context(), req.URL, req.EventTypes)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, toWebhookResponse(sub, true))
+	}
+}
+
+func listWebhooksHandler(svc *webhook.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subs, err := svc.ListSubscriptions(r.Context())
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := WebhookListResponse{
+			Webhooks: make([]WebhookSubscriptionResponse, len(subs)),
+		}
+		for i := range subs {
+			resp.Webhooks[i] = toWebhookResponse(&subs[i], false)
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func getWebhookHandler(svc *webhook.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseWebhookID(w, r)
+		if !ok {
+			return
+		}
+
+		sub, err := svc.GetSubscription(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toWebhookResponse(sub, false))
+	}
+}
+
+func deleteWebhookHandler(svc *webhook.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseWebhookID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := svc.DeleteSubscription(r.Context(), id); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func rotateWebhookSecretHandler(svc *webhook.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseWebhookID(w, r)
+		if !ok {
+			return
+		}
+
+		sub, err := svc.RotateSecret(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toWebhookResponse(sub, true))
+	}
+}
+
+func testWebhookHandler(svc *webhook.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseWebhookID(w, r)
+		if !ok {
+			return
+		}
+
+		delivery, err := svc.SendTest(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toWebhookDeliveryResponse(delivery))
+	}
+}
+
+func listWebhookDeliveriesHandler(svc *webhook.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseWebhookID(w, r)
+		if !ok {
+			return
+		}
+
+		deliveries, err := svc.ListDeliveries(r.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := WebhookDeliveryListResponse{
+			Deliveries: make([]WebhookDeliveryResponse, len(deliveries)),
+		}
+		for i := range deliveries {
+			resp.Deliveries[i] = toWebhookDeliveryResponse(&deliveries[i])
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func parseWebhookID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_webhook_id", "id must be a valid UUID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func toWebhookResponse(sub *webhook.Subscription, includeSecret bool) WebhookSubscriptionResponse {
+	resp := WebhookSubscriptionResponse{
+		ID:         sub.ID,
+		URL:        sub.URL,
+		EventTypes: sub.EventTypes,
+		Active:     sub.Active,
+		CreatedAt:  sub.CreatedAt,
+		UpdatedAt:  sub.UpdatedAt,
+	}
+	if includeSecret {
+		resp.Secret = sub.Secret
+	}
+	return resp
+}
+
+func toWebhookDeliveryResponse(d *webhook.Delivery) WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		ID:             d.ID,
+		EventType:      d.EventType,
+		Succeeded:      d.Succeeded(),
+		ResponseStatus: d.ResponseStatus,
+		Error:          d.Error,
+		DurationMs:     d.Duration.Milliseconds(),
+		CreatedAt:      d.CreatedAt,
+	}
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/turnstile"
+)
+
+// ChallengeVerifier checks the token a CAPTCHA-style challenge gave the
+// widget. Verify returns an error wrapping turnstile.ErrRejected for a bad
+// token and any other error when the token could not be checked.
+type ChallengeVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// WidgetConfig enables the public booking widget API under /widget. It
+// takes no credentials, so every client IP is rate limited on its own.
+type WidgetConfig struct {
+	Limiter      redisclient.RateLimiter
+	Verifier     ChallengeVerifier // optional, bookings are not mounted when nil
+	Origins      []string          // sites allowed to call from a browser, "*" for any
+	SearchLimit  int               // slot searches per minute per client IP
+	BookingLimit int               // bookings per hour per client IP
+}
+
+const (
+	widgetSearchWindow  = time.Minute
+	widgetBookingWindow = time.Hour
+
+	// widgetMaxBody bounds a booking request body
+	widgetMaxBody = 4 << 10
+
+	// widgetSlotsMaxAge lets browsers and CDNs reuse a search briefly; a
+	// slot filled in the meantime fails with slot_already_booked
+	widgetSlotsMaxAge = 30 * time.Second
+)
+
+var widgetRejected = metrics.NewCounter(
+	"widget_requests_rejected_total",
+	"Widget requests turned away, by reason (rate_limited, challenge_failed).",
+	"reason",
+)
+
+// widgetRouter serves the widget API. It is its own router so the widget's
+// CORS and rate limits never apply to the authenticated API.
+func widgetRouter(svc *appointment.Service, cfg *WidgetConfig, links *ActionLinkConfig, contention ContentionReport) http.Handler {
+	r := chi.NewRouter()
+	r.Use(widgetCORS(cfg.Origins))
+
+	r.With(widgetRateLimit(cfg.Limiter, "search", cfg.SearchLimit, widgetSearchWindow)).
+		Get("/clinics/{id}/slots", widgetSlotsHandler(svc, contention))
+	if cfg.Verifier != nil {
+		r.With(widgetRateLimit(cfg.Limiter, "booking", cfg.BookingLimit, widgetBookingWindow)).
+			Post("/clinics/{id}/bookings", widgetBookHandler(svc, cfg.Verifier, links))
+	}
+	return r
+}
+
+// widgetCORS lets the allowed sites call the widget API from a browser and
+// answers their preflight requests
+func widgetCORS(origins []string) func(http.Handler) http.Handler {
+	anyOrigin := slices.Contains(origins, "*")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+			allowed := origin != "" && (anyOrigin || slices.Contains(origins, origin))
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				if allowed {
+					w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+					w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+TenantHeader+", "+QueueTicketHeader)
+					w.Header().Set("Access-Control-Max-Age", "600")
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// widgetRateLimit allows limit requests per window from one client IP. The
+// widget is unauthenticated, so when the count cannot be kept the request
+// is refused rather than let through.
+func widgetRateLimit(limiter redisclient.RateLimiter, name string, limit int, window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "widget:" + name + ":" + clientIP(r)
+			allowed, retryAfter, err := limiter.Allow(r.Context(), key, limit, window)
+			if err != nil {
+				log.Printf("widget rate limit: %v", err)
+				w.Header().Set("Retry-After", "1")
+				writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{
+					Error:     "rate_limit_unavailable",
+					Details:   "the request could not be counted, try again",
+					Retryable: true,
+				})
+				return
+			}
+			if !allowed {
+				widgetRejected.Inc("rate_limited")
+				seconds := int((retryAfter + time.Second - 1) / time.Second)
+				w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+				writeJSON(w, http.StatusTooManyRequests, ErrorResponse{
+					Error:     "rate_limited",
+					Details:   "too many " + name + " requests, try again later",
+					Retryable: true,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP is the address the request came from. Behind a proxy that is
+// the proxy, which must then limit clients itself.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// widgetSlotsHandler lists a clinic's open slots in [from, to), of one
+// specialty when given
+func widgetSlotsHandler(svc *appointment.Service, contention ContentionReport) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clinicID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_clinic_id", "id must be a valid UUID")
+			return
+		}
+
+		q := r.URL.Query()
+		from, err := time.Parse(time.RFC3339, q.Get("from"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_from", "from must be an RFC 3339 timestamp")
+			return
+		}
+		to, err := time.Parse(time.RFC3339, q.Get("to"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_to", "to must be an RFC 3339 timestamp")
+			return
+		}
+		limit := 50
+		if v := q.Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > appointment.MaxOpenSlots {
+				writeError(w, http.StatusBadRequest, "invalid_limit",
+					"limit must be between 1 and "+strconv.Itoa(appointment.MaxOpenSlots))
+				return
+			}
+		}
+
+		res, err := svc.SearchOpenSlots(r.Context(), appointment.SlotSearch{
+			ClinicID:  clinicID,
+			Specialty: q.Get("specialty"),
+			From:      from,
+			To:        to,
+			Limit:     limit,
+			Token:     q.Get("page_token"),
+		})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := WidgetSlotListResponse{
+			Slots:         make([]WidgetSlotResponse, len(res.Slots)),
+			Count:         len(res.Slots),
+			NextPageToken: res.NextToken,
+		}
+		ids := make([]uuid.UUID, len(res.Slots))
+		for i, o := range res.Slots {
+			ids[i] = o.ID
+		}
+		conflicts := recentConflicts(r.Context(), contention, ids...)
+		for i, o := range res.Slots {
+			resp.Slots[i] = WidgetSlotResponse{
+				ID:            o.ID,
+				ClinicianID:   o.PractitionerID,
+				ClinicianName: o.ClinicianName,
+				Specialty:     o.Specialty,
+				SlotType:      o.SlotType,
+				StartTime:     o.StartTime.UTC(),
+				EndTime:       o.EndTime.UTC(),
+				Remaining:     o.Remaining,
+				Price:         toOptionalPriceResponse(o.Price),
+				Conflicts:     conflicts[o.ID],
+			}
+		}
+
+		// Slots differ per tenant, so shared caches must key on it too
+		w.Header().Add("Vary", TenantHeader)
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(widgetSlotsMaxAge.Seconds())))
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// widgetBookHandler books and confirms a slot for a guest once the
+// challenge token checks out
+func widgetBookHandler(svc *appointment.Service, verifier ChallengeVerifier, linkCfg *ActionLinkConfig) http.HandlerFunc {
+	var links *actionLinks
+	if linkCfg != nil {
+		links = newActionLinks(linkCfg)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		clinicID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_clinic_id", "id must be a valid UUID")
+			return
+		}
+
+		var req WidgetBookingRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, widgetMaxBody)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_body", "could not parse JSON")
+			return
+		}
+
+		switch err := verifier.Verify(r.Context(), req.ChallengeToken, clientIP(r)); {
+		case errors.Is(err, turnstile.ErrRejected):
+			widgetRejected.Inc("challenge_failed")
+			writeError(w, http.StatusForbidden, "challenge_failed", "the challenge was not passed, try again")
+			return
+		case err != nil:
+			log.Printf("widget challenge: %v", err)
+			w.Header().Set("Retry-After", "1")
+			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{
+				Error:     "challenge_unavailable",
+				Details:   "the challenge could not be checked, try again",
+				Retryable: true,
+			})
+			return
+		}
+
+		booking, err := svc.BookAsGuest(queueTicketRequest(r).Context(), appointment.GuestBooking{
+			ClinicID: clinicID,
+			SlotID:   req.SlotID,
+			Name:     req.Name,
+			Email:    req.Email,
+		})
+		if err != nil {
+			if writeQueuedError(w, err) {
+				return
+			}
+			writeServiceError(w, err)
+			return
+		}
+
+		resp := WidgetBookingResponse{
+			AppointmentID: booking.ID,
+			Status:        string(booking.Status),
+			StartTime:     booking.StartTime().UTC(),
+			EndTime:       booking.EndTime().UTC(),
+		}
+		if links != nil {
+			expires := svc.Now().Add(links.ttl).Truncate(time.Second)
+			resp.CancelURL = links.link(actionCancel, booking.ID, shard.Tenant(r.Context()), expires)
+		}
+		writeJSON(w, http.StatusCreated, resp)
+	}
+}
@@ -0,0 +1,148 @@
+package appointment
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// MaxAPIKeyNameLength bounds the name an API key is given
+const MaxAPIKeyNameLength = 100
+
+// apiKeyPrefixLength is how much of a key is kept in the clear: "ak_" and
+// the first 8 hex digits
+const apiKeyPrefixLength = 11
+
+// CreateAPIKey issues a key with the given scopes, limited to the
+// appointments of clinicID when it is set. The key itself is returned only
+// here; afterwards only its hash is kept.
+func (s *Service) CreateAPIKey(ctx context.Context, name string, scopes []APIScope, clinicID *uuid.UUID) (*APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if err := validateAPIKeyName(name); err != nil {
+		return nil, "", err
+	}
+	scopes, err := validateAPIScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := generateAPIKey()
+	if err != nil {
+		return nil, "", fmt.Errorf("generate api key: %w", err)
+	}
+
+	k, err := s.repo.CreateAPIKey(ctx, APIKey{
+		ID:       uuid.New(),
+		Name:     name,
+		Prefix:   secret[:apiKeyPrefixLength],
+		KeyHash:  hashAPIKey(secret),
+		Scopes:   scopes,
+		ClinicID: clinicID,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("create api key: %w", err)
+	}
+	return k, secret, nil
+}
+
+// AuthenticateAPIKey returns the key secret is, or ErrAPIKeyNotFound when
+// it is unknown or revoked
+func (s *Service) AuthenticateAPIKey(ctx context.Context, secret string) (*APIKey, error) {
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return nil, ErrAPIKeyNotFound
+	}
+	k, err := s.repo.GetAPIKeyByHash(ctx, hashAPIKey(secret))
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load api key: %w", err)
+	}
+	if k.RevokedAt != nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	return k, nil
+}
+
+// ListAPIKeys returns every key, revoked ones included, oldest first
+func (s *Service) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+// RevokeAPIKey stops the key working. Revoking it again keeps the time it
+// was first revoked.
+func (s *Service) RevokeAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	k, err := s.repo.RevokeAPIKey(ctx, id, s.clock.Now())
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("revoke api key: %w", err)
+	}
+	return k, nil
+}
+
+// requireScope checks the key may do scope
+func requireScope(k *APIKey, scope APIScope) error {
+	if !k.Allows(scope) {
+		return fmt.Errorf("%w: the key lacks scope %s", ErrInsufficientScope, scope)
+	}
+	return nil
+}
+
+func validateAPIKeyName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidAPIKey)
+	}
+	if len(name) > MaxAPIKeyNameLength {
+		return fmt.Errorf("%w: name is longer than %d bytes", ErrInvalidAPIKey, MaxAPIKeyNameLength)
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf("%w: name has characters that are not printable", ErrInvalidAPIKey)
+		}
+	}
+	return nil
+}
+
+// validateAPIScopes checks every scope is known and returns them without
+// repeats
+func validateAPIScopes(scopes []APIScope) ([]APIScope, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKey)
+	}
+	var result []APIScope
+	for _, scope := range scopes {
+		if !slices.Contains(APIScopes, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKey, scope)
+		}
+		if !slices.Contains(result, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "ak_" + hex.EncodeToString(buf), nil
+}
+
+// hashAPIKey is what a key is stored and looked up by. Keys are random, so
+// an unsalted SHA-256 is enough.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,140 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// Clinics with RequiresApproval triage bookings: confirming a hold moves it to
+// pending_approval until cfg.ApprovalWindow has passed, and a clinician
+// approves or rejects it in the meantime. Bookings nobody decided on are
+// rejected by RejectOverdueApprovals.
+
+// ApproveAppointment confirms a booking awaiting approval. reviewer and note
+// are recorded on the APPOINTMENT_CONFIRMED event when set. Approving past
+// the deadline rejects the booking and returns ErrApprovalWindowPassed; a
+// slot filled in the meantime returns ErrSlotAlreadyBooked and leaves the
+// booking waiting.
+func (s *Service) ApproveAppointment(ctx context.Context, id uuid.UUID, reviewer, note string) (*Appointment, error) {
+	appt, err := s.repo.GetAppointmentByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("load appointment: %w", err)
+	}
+	if appt.Status != StatusPendingApproval {
+		return nil, ErrInvalidStatusTransition
+	}
+
+	now := s.clock.Now()
+	updated, err := s.repo.ResolveApproval(ctx, id, StatusConfirmed, now)
+	if err != nil {
+		if errors.Is(err, ErrAppointmentNotFound) {
+			return nil, s.approvalLost(ctx, id)
+		}
+		return nil, fmt.Errorf("approve appointment: %w", err)
+	}
+
+	s.logEvent(ctx, id, EventAppointmentConfirmed, reviewPayload(map[string]any{}, reviewer, note))
+	return updated, nil
+}
+
+// RejectAppointment turns down a booking awaiting approval. reviewer and
+// note are recorded on the APPOINTMENT_REJECTED event when set.
+func (s *Service) RejectAppointment(ctx context.Context, id uuid.UUID, reviewer, note string) (*Appointment, error) {
+	appt, err := s.repo.GetAppointmentByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("load appointment: %w", err)
+	}
+	if appt.Status != StatusPendingApproval {
+		return nil, ErrInvalidStatusTransition
+	}
+
+	updated, err := s.repo.ResolveApproval(ctx, id, StatusRejected, s.clock.Now())
+	if err != nil {
+		if errors.Is(err, ErrAppointmentNotFound) {
+			// decided on or cancelled since it was read
+			return nil, ErrInvalidStatusTransition
+		}
+		return nil, fmt.Errorf("reject appointment: %w", err)
+	}
+
+	s.logEvent(ctx, id, EventAppointmentRejected, reviewPayload(map[string]any{"reason": "clinician"}, reviewer, note))
+	return updated, nil
+}
+
+// approvalLost explains an approval whose update matched nothing. A booking
+// still awaiting approval is past its deadline, so it is rejected here.
+func (s *Service) approvalLost(ctx context.Context, id uuid.UUID) error {
+	if s.rejectOverdue(ctx, id) {
+		return ErrApprovalWindowPassed
+	}
+	appt, err := s.repo.GetAppointmentByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("reload appointment: %w", err)
+	}
+	if appt.Status == StatusRejected {
+		return ErrApprovalWindowPassed
+	}
+	return ErrInvalidStatusTransition
+}
+
+// RejectOverdueApprovals rejects every booking whose approval deadline has
+// passed and returns how many it rejected. Once stop is closed the rest is
+// left for the next run. stop may be nil.
+func (s *Service) RejectOverdueApprovals(ctx context.Context, stop <-chan struct{}) (int, error) {
+	overdue, err := s.repo.FindOverdueApprovals(ctx, s.clock.Now())
+	if err != nil {
+		return 0, fmt.Errorf("find overdue approvals: %w", err)
+	}
+
+	rejected := 0
+	for _, appt := range overdue {
+		select {
+		case <-stop:
+			return rejected, nil
+		default:
+		}
+		if s.rejectOverdue(ctx, appt.ID) {
+			rejected++
+		}
+	}
+	return rejected, nil
+}
+
+// rejectOverdue rejects a booking past its approval deadline and reports
+// whether it did; an approval, cancellation or another run may get there
+// first. The deadline never moves while a booking waits, so one found
+// overdue stays overdue.
+func (s *Service) rejectOverdue(ctx context.Context, id uuid.UUID) bool {
+	appt, err := s.repo.GetAppointmentByID(ctx, id)
+	if err != nil {
+		log.Printf("failed to load appointment %s awaiting approval: %v", id, err)
+		return false
+	}
+	if appt.Status != StatusPendingApproval || appt.ExpiresAt == nil || !appt.ExpiresAt.Before(s.clock.Now()) {
+		return false
+	}
+
+	if _, err := s.repo.ResolveApproval(ctx, id, StatusRejected, s.clock.Now()); err != nil {
+		if !errors.Is(err, ErrAppointmentNotFound) {
+			log.Printf("failed to reject overdue appointment %s: %v", id, err)
+		}
+		return false
+	}
+	s.logEvent(ctx, id, EventAppointmentRejected, map[string]any{"reason": "approval_timeout"})
+	return true
+}
+
+// reviewPayload adds the reviewer and note to an event payload when set
+func reviewPayload(payload map[string]any, reviewer, note string) map[string]any {
+	if reviewer != "" {
+		payload["reviewer"] = reviewer
+	}
+	if note != "" {
+		payload["note"] = note
+	}
+	return payload
+}
@@ -0,0 +1,185 @@
+package appointment
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/blob"
+)
+
+// Scanner checks an upload for malware before it is stored. Scan returns
+// the name of the threat it found, empty for clean content; an error means
+// the content could not be checked.
+type Scanner interface {
+	Scan(ctx context.Context, filename string, content []byte) (threat string, err error)
+}
+
+// WithBlobStore keeps attachment content in store. Without it attachments
+// fail with ErrAttachmentsDisabled.
+func WithBlobStore(store blob.Store) Option {
+	return func(s *Service) { s.blobs = store }
+}
+
+// WithScanner scans every attachment with sc before storing it. Without it
+// attachments are stored unscanned.
+func WithScanner(sc Scanner) Option {
+	return func(s *Service) { s.scanner = sc }
+}
+
+// maxFilenameLen bounds the stored name of an attachment, in bytes
+const maxFilenameLen = 255
+
+// AttachmentUpload is a file to attach to an appointment
+type AttachmentUpload struct {
+	Kind     AttachmentKind
+	Filename string
+	Content  []byte
+}
+
+// AddAttachment scans up and stores it for the appointment. The type is
+// sniffed from the content rather than trusted from the client and must be
+// one of the configured attachment types.
+func (s *Service) AddAttachment(ctx context.Context, appointmentID uuid.UUID, up AttachmentUpload) (*Attachment, error) {
+	if s.blobs == nil {
+		return nil, ErrAttachmentsDisabled
+	}
+	if !up.Kind.Valid() {
+		return nil, fmt.Errorf("%w: kind must be referral_letter, intake_form or other", ErrInvalidAttachment)
+	}
+	filename, err := cleanFilename(up.Filename)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(up.Content))
+	if size == 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidAttachment)
+	}
+	if limit := int64(s.cfg.AttachmentMaxBytes); limit > 0 && size > limit {
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d", ErrAttachmentTooLarge, size, limit)
+	}
+	contentType := sniffContentType(up.Content)
+	if !slices.Contains(s.cfg.AttachmentTypes, contentType) {
+		return nil, fmt.Errorf("%w: %s, allowed are %s", ErrUnsupportedAttachmentType, contentType, strings.Join(s.cfg.AttachmentTypes, ", "))
+	}
+
+	if _, err := s.repo.GetAppointmentByID(ctx, appointmentID); err != nil {
+		return nil, fmt.Errorf("load appointment: %w", err)
+	}
+
+	status := ScanUnscanned
+	if s.scanner != nil {
+		threat, err := s.scanner.Scan(ctx, filename, up.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrAttachmentScanFailed, err)
+		}
+		if threat != "" {
+			log.Printf("refused infected attachment %q for appointment %s: %s", filename, appointmentID, threat)
+			return nil, fmt.Errorf("%w: %s", ErrAttachmentInfected, threat)
+		}
+		status = ScanClean
+	}
+
+	id := uuid.New()
+	a := Attachment{
+		ID:            id,
+		AppointmentID: appointmentID,
+		Kind:          up.Kind,
+		Filename:      filename,
+		ContentType:   contentType,
+		Size:          size,
+		BlobKey:       "attachments/" + appointmentID.String() + "/" + id.String(),
+		ScanStatus:    status,
+	}
+	if err := s.blobs.Put(ctx, a.BlobKey, bytes.NewReader(up.Content), size, contentType); err != nil {
+		return nil, fmt.Errorf("store attachment: %w", err)
+	}
+	saved, err := s.repo.InsertAttachment(ctx, a)
+	if err != nil {
+		// Nothing points at the content without the row
+		if derr := s.blobs.Delete(context.WithoutCancel(ctx), a.BlobKey); derr != nil {
+			log.Printf("failed to delete unsaved attachment %s: %v", a.BlobKey, derr)
+		}
+		return nil, fmt.Errorf("save attachment: %w", err)
+	}
+
+	s.logEvent(ctx, appointmentID, EventAttachmentAdded, map[string]any{
+		"attachment_id": saved.ID.String(),
+		"kind":          saved.Kind,
+		"content_type":  saved.ContentType,
+		"size_bytes":    saved.Size,
+		"scan_status":   saved.ScanStatus,
+	})
+	return saved, nil
+}
+
+// ListAttachments returns the appointment's attachments, oldest first
+func (s *Service) ListAttachments(ctx context.Context, appointmentID uuid.UUID) ([]Attachment, error) {
+	if _, err := s.repo.GetAppointmentByID(ctx, appointmentID); err != nil {
+		return nil, fmt.Errorf("load appointment: %w", err)
+	}
+	attachments, err := s.repo.ListAttachments(ctx, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+// OpenAttachment returns the attachment and its content. The caller closes
+// the content.
+func (s *Service) OpenAttachment(ctx context.Context, id uuid.UUID) (*Attachment, io.ReadCloser, error) {
+	if s.blobs == nil {
+		return nil, nil, ErrAttachmentsDisabled
+	}
+	a, err := s.repo.GetAttachment(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get attachment: %w", err)
+	}
+	content, err := s.blobs.Get(ctx, a.BlobKey)
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, nil, fmt.Errorf("%w: content of %s is missing", ErrAttachmentNotFound, id)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("read attachment: %w", err)
+	}
+	return a, content, nil
+}
+
+// cleanFilename keeps the last path element of a client's file name, which
+// browsers may send with directories, and rejects names that cannot be
+// shown back safely
+func cleanFilename(name string) (string, error) {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "" || name == "." || name == "..":
+		return "", fmt.Errorf("%w: filename is required", ErrInvalidAttachment)
+	case len(name) > maxFilenameLen:
+		return "", fmt.Errorf("%w: filename is longer than %d bytes", ErrInvalidAttachment, maxFilenameLen)
+	case strings.ContainsFunc(name, unicode.IsControl):
+		return "", fmt.Errorf("%w: filename contains control characters", ErrInvalidAttachment)
+	}
+	return name, nil
+}
+
+// sniffContentType returns the media type of content without parameters,
+// e.g. text/plain rather than text/plain; charset=utf-8
+func sniffContentType(content []byte) string {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(content))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
@@ -0,0 +1,83 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attendance is recorded on confirmed and checked-in appointments once their
+// last slot has ended: completed when the patient came, no_show when they
+// did not. Both are final, and the events let clinics compute their no-show
+// rates.
+const (
+	EventAppointmentCompleted = "APPOINTMENT_COMPLETED"
+	EventAppointmentNoShow    = "APPOINTMENT_NO_SHOW"
+)
+
+// CompleteAppointment records that the patient of a confirmed or checked-in
+// appointment came. recordedBy and note go on the APPOINTMENT_COMPLETED event
+// when set.
+func (s *Service) CompleteAppointment(ctx context.Context, id uuid.UUID, recordedBy, note string) (*Appointment, error) {
+	return s.recordAttendance(ctx, id, StatusCompleted, EventAppointmentCompleted, recordedBy, note, StatusConfirmed, StatusCheckedIn)
+}
+
+// MarkNoShow records that the patient of a confirmed appointment did not
+// come; one who checked in did. recordedBy and note go on the
+// APPOINTMENT_NO_SHOW event when set.
+func (s *Service) MarkNoShow(ctx context.Context, id uuid.UUID, recordedBy, note string) (*Appointment, error) {
+	return s.recordAttendance(ctx, id, StatusNoShow, EventAppointmentNoShow, recordedBy, note, StatusConfirmed)
+}
+
+// recordAttendance moves an appointment in one of the from statuses whose
+// last slot has ended to status and logs eventType. An appointment in
+// another status, or that leaves its status before the update, returns
+// ErrInvalidStatusTransition; one still running returns
+// ErrAppointmentNotEnded.
+func (s *Service) recordAttendance(ctx context.Context, id uuid.UUID, status AppointmentStatus, eventType, recordedBy, note string, from ...AppointmentStatus) (*Appointment, error) {
+	appt, err := s.repo.GetAppointmentByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("load appointment: %w", err)
+	}
+	if !slices.Contains(from, appt.Status) {
+		return nil, fmt.Errorf("%w: appointment is %s", ErrInvalidStatusTransition, appt.Status)
+	}
+
+	slots, err := s.repo.ListAppointmentSlots(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("load appointment slots: %w", err)
+	}
+	if len(slots) == 0 {
+		return nil, fmt.Errorf("load appointment slots: %w", ErrSlotNotFound)
+	}
+	end := slots[len(slots)-1].EndTime
+	if s.clock.Now().Before(end) {
+		return nil, fmt.Errorf("%w: it ends at %s", ErrAppointmentNotEnded, end.UTC().Format(time.RFC3339))
+	}
+
+	updated, err := s.repo.UpdateAppointmentStatus(ctx, id, appt.Status, status)
+	if err != nil {
+		if errors.Is(err, ErrAppointmentNotFound) {
+			// cancelled or recorded since it was read
+			return nil, ErrInvalidStatusTransition
+		}
+		return nil, fmt.Errorf("record attendance: %w", err)
+	}
+
+	payload := map[string]any{
+		"slot_id":  slots[0].ID.String(),
+		"ended_at": end,
+	}
+	if recordedBy != "" {
+		payload["recorded_by"] = recordedBy
+	}
+	if note != "" {
+		payload["note"] = note
+	}
+	s.logEvent(ctx, id, eventType, payload)
+	return updated, nil
+}
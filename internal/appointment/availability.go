@@ -0,0 +1,232 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+const (
+	// MaxSlotGenerationDays bounds the date range GenerateSlots expands a
+	// template over
+	MaxSlotGenerationDays = 366
+	// maxGeneratedSlots bounds the slots one GenerateSlots call creates,
+	// all of them in one transaction
+	maxGeneratedSlots = 10000
+
+	maxAvailabilityPeriods = 100
+	minutesPerDay          = 24 * 60
+)
+
+// GetAvailabilityTemplate returns the clinician's weekly availability
+func (s *Service) GetAvailabilityTemplate(ctx context.Context, clinicianID uuid.UUID) (*AvailabilityTemplate, error) {
+	if _, err := s.lookupClinician(ctx, clinicianID); err != nil {
+		return nil, fmt.Errorf("get clinician: %w", err)
+	}
+	return s.repo.GetAvailabilityTemplate(ctx, clinicianID)
+}
+
+// PutAvailabilityTemplate creates or replaces the weekly availability of
+// t.ClinicianID. Slots already generated from the template it replaces are
+// kept.
+func (s *Service) PutAvailabilityTemplate(ctx context.Context, t AvailabilityTemplate) (*AvailabilityTemplate, error) {
+	if _, err := s.lookupClinician(ctx, t.ClinicianID); err != nil {
+		return nil, fmt.Errorf("get clinician: %w", err)
+	}
+	if t.SlotType != nil {
+		slotType := strings.TrimSpace(*t.SlotType)
+		t.SlotType = &slotType
+		if slotType == "" {
+			t.SlotType = nil
+		}
+	}
+	periods, err := validateAvailabilityTemplate(t)
+	if err != nil {
+		return nil, err
+	}
+	t.Periods = periods
+
+	saved, err := s.repo.PutAvailabilityTemplate(ctx, t)
+	if err != nil {
+		return nil, fmt.Errorf("put availability template: %w", err)
+	}
+	return saved, nil
+}
+
+// DeleteAvailabilityTemplate removes the clinician's weekly availability.
+// Slots already generated from it are kept.
+func (s *Service) DeleteAvailabilityTemplate(ctx context.Context, clinicianID uuid.UUID) error {
+	if err := s.repo.DeleteAvailabilityTemplate(ctx, clinicianID); err != nil {
+		if errors.Is(err, ErrAvailabilityTemplateNotFound) {
+			return err
+		}
+		return fmt.Errorf("delete availability template: %w", err)
+	}
+	return nil
+}
+
+// GenerateSlots expands the clinician's availability template into open
+// slots for every day from from to to, both included, taking the dates in
+// the template's time zone. Slots that would start before now are left out,
+// and those overlapping a slot the clinician already has, deleted ones
+// aside, are skipped, so generating a range again only fills its gaps. The
+// slots are created in one transaction under the clinician's lock; a slot
+// added without it in the meantime fails the generation with
+// ErrSlotOverlap. A deactivated clinician gets no new slots.
+func (s *Service) GenerateSlots(ctx context.Context, clinicianID uuid.UUID, from, to time.Time) (*SlotGeneration, error) {
+	if err := s.checkActiveClinician(ctx, clinicianID); err != nil {
+		return nil, fmt.Errorf("get clinician: %w", err)
+	}
+	t, err := s.repo.GetAvailabilityTemplate(ctx, clinicianID)
+	if err != nil {
+		if errors.Is(err, ErrAvailabilityTemplateNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("get availability template: %w", err)
+	}
+	loc, err := time.LoadLocation(t.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("load time zone of availability template: %w", err)
+	}
+
+	first := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc)
+	if last.Before(first) {
+		return nil, fmt.Errorf("%w: to is before from", ErrInvalidSlotGeneration)
+	}
+	if !last.Before(first.AddDate(0, 0, MaxSlotGenerationDays)) {
+		return nil, fmt.Errorf("%w: slots are generated for at most %d days at a time", ErrInvalidSlotGeneration, MaxSlotGenerationDays)
+	}
+
+	candidates := expandAvailability(t, first, last, s.clock.Now())
+	if len(candidates) > maxGeneratedSlots {
+		return nil, fmt.Errorf("%w: the range would generate %d slots, more than %d; generate it in parts",
+			ErrInvalidSlotGeneration, len(candidates), maxGeneratedSlots)
+	}
+	if len(candidates) == 0 {
+		return &SlotGeneration{Slots: []AppointmentSlot{}}, nil
+	}
+
+	gen := &SlotGeneration{}
+	err = s.runStage(ctx, StageLockSection, func(ctx context.Context) error {
+		return s.locker.WithLock(ctx, []string{redisclient.ClinicianKey(clinicianID)}, func(ctx context.Context) error {
+			return s.repo.WithTx(ctx, func(tx Repository) error {
+				existing, err := tx.ListClinicianSlotsOverlapping(ctx, clinicianID, candidates[0].StartTime, candidates[len(candidates)-1].EndTime)
+				if err != nil {
+					return fmt.Errorf("list clinician slots: %w", err)
+				}
+				fresh := withoutOverlaps(candidates, existing)
+				gen.Skipped = len(candidates) - len(fresh)
+				gen.Slots, err = tx.CreateSlots(ctx, fresh)
+				return err
+			})
+		})
+	})
+	if err != nil {
+		if mapped := lockError(err); mapped != err {
+			return nil, mapped
+		}
+		return nil, fmt.Errorf("generate slots: %w", err)
+	}
+	return gen, nil
+}
+
+// expandAvailability cuts the template's periods on each day from first to
+// last into slots starting after now, earliest first. Times a DST change
+// skips are left out; times it repeats are taken the first time.
+func expandAvailability(t *AvailabilityTemplate, first, last, now time.Time) []AppointmentSlot {
+	minutes := int(t.SlotDuration / time.Minute)
+	var slots []AppointmentSlot
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		for _, p := range t.Periods {
+			if p.Weekday != day.Weekday() {
+				continue
+			}
+			for m := p.Start; m+minutes <= p.End; m += minutes {
+				start := time.Date(day.Year(), day.Month(), day.Day(), 0, m, 0, 0, day.Location())
+				if start.Hour()*60+start.Minute() != m || !start.After(now) {
+					continue
+				}
+				slots = append(slots, AppointmentSlot{
+					ID:             uuid.New(),
+					PractitionerID: t.ClinicianID,
+					StartTime:      start,
+					EndTime:        start.Add(t.SlotDuration),
+					Status:         SlotOpen,
+					Capacity:       t.Capacity,
+					SlotType:       t.SlotType,
+				})
+			}
+		}
+	}
+	return slots
+}
+
+// withoutOverlaps returns the candidates, which are sorted and do not
+// overlap each other, that overlap none of existing
+func withoutOverlaps(candidates, existing []AppointmentSlot) []AppointmentSlot {
+	taken := make([]bool, len(candidates))
+	for _, e := range existing {
+		i := sort.Search(len(candidates), func(i int) bool { return candidates[i].EndTime.After(e.StartTime) })
+		for ; i < len(candidates) && candidates[i].StartTime.Before(e.EndTime); i++ {
+			taken[i] = true
+		}
+	}
+
+	fresh := make([]AppointmentSlot, 0, len(candidates))
+	for i, c := range candidates {
+		if !taken[i] {
+			fresh = append(fresh, c)
+		}
+	}
+	return fresh
+}
+
+// validateAvailabilityTemplate checks t and returns its periods by weekday
+// and start
+func validateAvailabilityTemplate(t AvailabilityTemplate) ([]AvailabilityPeriod, error) {
+	if t.TimeZone == "" || t.TimeZone == "Local" {
+		return nil, fmt.Errorf("%w: time zone must be an IANA zone such as Europe/Berlin", ErrInvalidAvailabilityTemplate)
+	}
+	if _, err := time.LoadLocation(t.TimeZone); err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidAvailabilityTemplate, t.TimeZone)
+	}
+	if t.SlotDuration < 5*time.Minute || t.SlotDuration > 24*time.Hour || t.SlotDuration%time.Minute != 0 {
+		return nil, fmt.Errorf("%w: slots last 5 to 1440 whole minutes", ErrInvalidAvailabilityTemplate)
+	}
+	if t.Capacity < 1 {
+		return nil, fmt.Errorf("%w: capacity must be at least 1", ErrInvalidAvailabilityTemplate)
+	}
+	if len(t.Periods) == 0 || len(t.Periods) > maxAvailabilityPeriods {
+		return nil, fmt.Errorf("%w: a template has 1 to %d periods", ErrInvalidAvailabilityTemplate, maxAvailabilityPeriods)
+	}
+
+	periods := slices.Clone(t.Periods)
+	slices.SortFunc(periods, func(a, b AvailabilityPeriod) int {
+		if a.Weekday != b.Weekday {
+			return int(a.Weekday) - int(b.Weekday)
+		}
+		return a.Start - b.Start
+	})
+	for i, p := range periods {
+		switch {
+		case p.Weekday < time.Sunday || p.Weekday > time.Saturday:
+			return nil, fmt.Errorf("%w: unknown weekday %d", ErrInvalidAvailabilityTemplate, p.Weekday)
+		case p.Start < 0 || p.End > minutesPerDay || p.Start >= p.End:
+			return nil, fmt.Errorf("%w: a %s period must start before it ends, within the day", ErrInvalidAvailabilityTemplate, p.Weekday)
+		case time.Duration(p.End-p.Start)*time.Minute < t.SlotDuration:
+			return nil, fmt.Errorf("%w: a %s period is shorter than a slot", ErrInvalidAvailabilityTemplate, p.Weekday)
+		case i > 0 && periods[i-1].Weekday == p.Weekday && periods[i-1].End > p.Start:
+			return nil, fmt.Errorf("%w: %s periods overlap", ErrInvalidAvailabilityTemplate, p.Weekday)
+		}
+	}
+	return periods, nil
+}
@@ -0,0 +1,365 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// Booking limits
+const (
+	MaxAppointmentSlots = 8
+	MaxResources        = 4
+)
+
+// BookingRequest describes an appointment that needs more than one slot,
+// staff besides the clinician or a reason for the visit: SlotCount
+// consecutive slots of one clinician starting with SlotID, and a free staff
+// resource for each of Resources over the whole range
+type BookingRequest struct {
+	SlotID    uuid.UUID
+	PatientID uuid.UUID
+	SlotCount int
+	Resources []ResourceRequirement
+	// Reason is why the patient is coming, optional
+	Reason *string
+}
+
+// Book holds the slots and staff of req for a patient as a single pending
+// appointment. Each later slot must start when the previous one ends. Slots
+// and staff are locked at once and held together or not at all; once
+// confirmed the appointment counts against the capacity of every slot it
+// spans. Staff stay reserved while the appointment is active. It is queued
+// as CreateAppointment is.
+func (s *Service) Book(ctx context.Context, req BookingRequest) (*Booking, error) {
+	reason, err := visitReason(req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.planBooking(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	slots, reqs, clinicID, staff := plan.slots, plan.reqs, plan.clinicID, plan.staff
+	start, end := plan.start, plan.end
+	if err := s.admitBooking(ctx, slots[0]); err != nil {
+		return nil, err
+	}
+
+	intent := BookingIntent{
+		ID:        uuid.New(),
+		SlotID:    req.SlotID,
+		PatientID: req.PatientID,
+		LockToken: uuid.NewString(),
+		State:     IntentPending,
+	}
+	err = s.runStage(ctx, StageJournal, func(ctx context.Context) error {
+		return s.repo.CreateBookingIntent(ctx, intent)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("journal booking intent: %w", err)
+	}
+
+	keys := make([]string, 0, len(slots)+len(staff))
+	for _, slot := range slots {
+		keys = append(keys, redisclient.SlotKey(slot.ID))
+	}
+	for _, res := range staff {
+		keys = append(keys, redisclient.ResourceKey(res.ID))
+	}
+
+	var created *Appointment
+	expiresAt := s.clock.Now().Add(s.cfg.AppointmentTTL)
+
+	err = s.runStage(ctx, StageLockSection, func(ctx context.Context) error {
+		lockCtx := redisclient.WithLockToken(ctx, intent.LockToken)
+		return s.locker.WithLock(lockCtx, keys, func(lockCtx context.Context) error {
+			// Staff were picked before the locks were taken; another booking
+			// may have reserved them since
+			for i, res := range staff {
+				free, err := s.repo.ListAvailableResources(lockCtx, clinicID, reqs[i], start, end)
+				if err != nil {
+					return fmt.Errorf("check resource: %w", err)
+				}
+				if !slices.ContainsFunc(free, func(r StaffResource) bool { return r.ID == res.ID }) {
+					return fmt.Errorf("%w: %s", ErrResourceUnavailable, describeRequirement(reqs[i]))
+				}
+			}
+
+			extra := make([]uuid.UUID, 0, len(slots)-1)
+			for _, slot := range slots[1:] {
+				extra = append(extra, slot.ID)
+			}
+			return s.repo.WithTx(lockCtx, func(tx Repository) error {
+				for _, slot := range slots {
+					if err := s.checkSlotRoom(lockCtx, tx, slot); err != nil {
+						return err
+					}
+				}
+				appt, err := tx.CreatePendingAppointment(lockCtx, req.SlotID, req.PatientID, expiresAt)
+				if err != nil {
+					return fmt.Errorf("create pending appointment: %w", err)
+				}
+				if err := tx.AddExtraSlots(lockCtx, appt.ID, extra); err != nil {
+					return err
+				}
+				if reason != nil {
+					if err := tx.SetAppointmentReason(lockCtx, appt.ID, *reason); err != nil {
+						return err
+					}
+				}
+				for _, res := range staff {
+					if err := tx.ReserveResource(lockCtx, appt.ID, res.ID, start, end); err != nil {
+						return err
+					}
+				}
+				if err := tx.ResolveBookingIntent(lockCtx, intent.ID, IntentCommitted, &appt.ID); err != nil {
+					return fmt.Errorf("commit booking intent: %w", err)
+				}
+				created = appt
+				return nil
+			})
+		})
+	})
+
+	if err != nil {
+		s.abortIntent(ctx, intent.ID)
+		return nil, lockError(err)
+	}
+	journalIntents.Inc(string(IntentCommitted))
+
+	booking := &Booking{Appointment: *created, Slots: make([]AppointmentSlot, len(slots)), Resources: staff, Reason: reason}
+	slotIDs := make([]string, len(slots))
+	for i, slot := range slots {
+		booking.Slots[i] = *slot
+		slotIDs[i] = slot.ID.String()
+	}
+	payload := map[string]any{
+		"slot_id":    req.SlotID.String(),
+		"patient_id": req.PatientID.String(),
+		"expires_at": expiresAt,
+	}
+	if len(slots) > 1 {
+		payload["slot_ids"] = slotIDs
+		payload["start_time"] = start
+		payload["end_time"] = end
+	}
+	if len(staff) > 0 {
+		resourceIDs := make([]string, len(staff))
+		for i, res := range staff {
+			resourceIDs[i] = res.ID.String()
+		}
+		payload["resource_ids"] = resourceIDs
+	}
+	s.logEvent(ctx, created.ID, EventAppointmentCreated, payload)
+
+	return booking, nil
+}
+
+// bookingPlan is what a booking request resolves to before anything is
+// locked: the slots it spans and the staff picked for it
+type bookingPlan struct {
+	slots      []*AppointmentSlot
+	reqs       []ResourceRequirement
+	clinicID   uuid.UUID
+	staff      []StaffResource
+	start, end time.Time
+}
+
+// planBooking runs the checks of req that need no lock: the patient, that
+// the slots are open, back to back and within the booking window, and that
+// the staff it needs are free
+func (s *Service) planBooking(ctx context.Context, req BookingRequest) (*bookingPlan, error) {
+	if req.SlotCount < 1 || req.SlotCount > MaxAppointmentSlots {
+		return nil, fmt.Errorf("%w: slot_count must be between 1 and %d", ErrInvalidSlotCount, MaxAppointmentSlots)
+	}
+	reqs, err := normalizeRequirements(req.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.runStage(ctx, StagePatientLookup, func(ctx context.Context) error {
+		return s.checkBookablePatient(ctx, req.PatientID)
+	})
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) || errors.Is(err, ErrPatientDeactivated) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load patient: %w", err)
+	}
+
+	var first *AppointmentSlot
+	err = s.runStage(ctx, StageSlotLookup, func(ctx context.Context) error {
+		first, err = s.repo.GetSlotByID(ctx, req.SlotID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load slot: %w", err)
+	}
+	if first.Status != SlotOpen {
+		return nil, ErrSlotNotOpen
+	}
+	if err := s.checkBookableSlots(ctx, first); err != nil {
+		return nil, err
+	}
+	slots, err := s.consecutiveSlots(ctx, first, req.SlotCount)
+	if err != nil {
+		return nil, err
+	}
+	start, end := first.StartTime, slots[len(slots)-1].EndTime
+
+	var clinicID uuid.UUID
+	var staff []StaffResource
+	if len(reqs) > 0 {
+		clinicID, err = s.slotClinic(ctx, first)
+		if err != nil {
+			return nil, err
+		}
+		staff, err = s.pickResources(ctx, clinicID, reqs, start, end)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &bookingPlan{slots: slots, reqs: reqs, clinicID: clinicID, staff: staff, start: start, end: end}, nil
+}
+
+// checkSlotRoom fails with ErrSlotAlreadyBooked when confirmed appointments
+// and live holds fill the slot. Counted through a transaction's repo it
+// also keeps concurrent bookings of the slot from counting at once, which
+// the semaphore of a slot with capacity above one lets into the section
+// together.
+func (s *Service) checkSlotRoom(ctx context.Context, repo Repository, slot *AppointmentSlot) error {
+	taken, err := repo.CountTakenPlaces(ctx, slot.ID, s.clock.Now())
+	if err != nil {
+		return fmt.Errorf("count taken places: %w", err)
+	}
+	if taken >= slot.Capacity {
+		return ErrSlotAlreadyBooked
+	}
+	return nil
+}
+
+// consecutiveSlots returns first and the open slots of its clinician that
+// follow it back to back, count in all
+func (s *Service) consecutiveSlots(ctx context.Context, first *AppointmentSlot, count int) ([]*AppointmentSlot, error) {
+	slots := []*AppointmentSlot{first}
+	for len(slots) < count {
+		prev := slots[len(slots)-1]
+		var found []AppointmentSlot
+		err := s.runStage(ctx, StageSlotLookup, func(ctx context.Context) (err error) {
+			found, err = s.repo.ListClinicianSlotsAt(ctx, prev.PractitionerID, []time.Time{prev.EndTime})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("find following slot: %w", err)
+		}
+
+		var next *AppointmentSlot
+		for i := range found {
+			if found[i].Status == SlotOpen {
+				next = &found[i]
+				break
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("%w: slot %d at %s", ErrSpanSlotUnavailable, len(slots)+1, prev.EndTime.Format(time.RFC3339))
+		}
+		slots = append(slots, next)
+	}
+	return slots, nil
+}
+
+// slotClinic returns the clinic whose staff serve bookings of the slot
+func (s *Service) slotClinic(ctx context.Context, slot *AppointmentSlot) (uuid.UUID, error) {
+	var clinician *Clinician
+	err := s.runStage(ctx, StageSlotLookup, func(ctx context.Context) (err error) {
+		clinician, err = s.lookupClinician(ctx, slot.PractitionerID)
+		return err
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("load clinician: %w", err)
+	}
+	if clinician.ClinicID == nil {
+		return uuid.Nil, fmt.Errorf("%w: the clinician has no clinic staff", ErrResourceUnavailable)
+	}
+	return *clinician.ClinicID, nil
+}
+
+// pickResources chooses a distinct free staff member for each requirement,
+// the first by name
+func (s *Service) pickResources(ctx context.Context, clinicID uuid.UUID, reqs []ResourceRequirement, start, end time.Time) ([]StaffResource, error) {
+	picked := make([]StaffResource, 0, len(reqs))
+	for _, req := range reqs {
+		var free []StaffResource
+		err := s.runStage(ctx, StageSlotLookup, func(ctx context.Context) (err error) {
+			free, err = s.repo.ListAvailableResources(ctx, clinicID, req, start, end)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("find resource: %w", err)
+		}
+
+		i := slices.IndexFunc(free, func(r StaffResource) bool {
+			return !slices.ContainsFunc(picked, func(p StaffResource) bool { return p.ID == r.ID })
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrResourceUnavailable, describeRequirement(req))
+		}
+		picked = append(picked, free[i])
+	}
+	return picked, nil
+}
+
+// normalizeRequirements validates reqs and lower-cases interpreter
+// languages, which are stored as lower-case tags such as "es" or "pt-br"
+func normalizeRequirements(reqs []ResourceRequirement) ([]ResourceRequirement, error) {
+	if len(reqs) > MaxResources {
+		return nil, fmt.Errorf("%w: at most %d resources", ErrInvalidResource, MaxResources)
+	}
+	out := make([]ResourceRequirement, len(reqs))
+	for i, req := range reqs {
+		req.Language = strings.ToLower(strings.TrimSpace(req.Language))
+		switch req.Kind {
+		case ResourceInterpreter:
+			if req.Language == "" {
+				return nil, fmt.Errorf("%w: an interpreter needs a language", ErrInvalidResource)
+			}
+		case ResourceChaperone:
+			if req.Language != "" {
+				return nil, fmt.Errorf("%w: a chaperone takes no language", ErrInvalidResource)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidResource, req.Kind)
+		}
+		out[i] = req
+	}
+	return out, nil
+}
+
+func describeRequirement(req ResourceRequirement) string {
+	if req.Language != "" {
+		return string(req.Kind) + " (" + req.Language + ")"
+	}
+	return string(req.Kind)
+}
+
+// lockError maps a locker failure to the service's errors: a lock someone
+// else holds is ErrSlotBeingBooked and a lock call Redis was too slow to
+// answer ErrStageTimeout, both retryable. Anything else is returned as is.
+func lockError(err error) error {
+	switch {
+	case errors.Is(err, redisclient.ErrLockNotAcquired):
+		return ErrSlotBeingBooked
+	case errors.Is(err, redisclient.ErrLockTimeout):
+		return fmt.Errorf("%w: %v", ErrStageTimeout, err)
+	}
+	return err
+}
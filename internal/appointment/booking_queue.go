@@ -0,0 +1,89 @@
+package appointment
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// bookingQueueTimeout bounds the queue call of a booking attempt. The queue
+// only smooths demand, so an attempt it cannot check is let through.
+const bookingQueueTimeout = 250 * time.Millisecond
+
+var bookingQueueAttempts = metrics.NewCounter(
+	"booking_queue_attempts_total",
+	"Booking attempts checked against their clinic's booking queue, by outcome: admitted, queued or unchecked.",
+	"outcome",
+)
+
+// QueuedError is returned for a booking attempt its clinic's queue has not
+// reached yet. It wraps ErrBookingQueued; the attempt is retried with
+// Ticket, passed in with WithQueueTicket, after about Wait.
+type QueuedError struct {
+	Ticket   string
+	Position int
+	Wait     time.Duration
+}
+
+func (e *QueuedError) Error() string {
+	return fmt.Sprintf("%s: position %d, about %s", ErrBookingQueued.Message, e.Position, e.Wait.Round(time.Second))
+}
+
+func (e *QueuedError) Unwrap() error { return ErrBookingQueued }
+
+// WithBookingQueue admits new bookings through q at cfg.BookingQueueRate
+// per clinic. Without it, or with a zero rate, every attempt goes ahead.
+func WithBookingQueue(q redisclient.BookingQueue) Option {
+	return func(s *Service) { s.queue = q }
+}
+
+type queueTicketKey struct{}
+
+// WithQueueTicket carries the ticket of a queued booking attempt into its
+// retry
+func WithQueueTicket(ctx context.Context, ticket string) context.Context {
+	return context.WithValue(ctx, queueTicketKey{}, ticket)
+}
+
+func queueTicketFrom(ctx context.Context) string {
+	ticket, _ := ctx.Value(queueTicketKey{}).(string)
+	return ticket
+}
+
+// admitBooking takes the attempt's place in the booking queue of the
+// slot's clinic, or of its clinician when it has none, so that when a
+// clinic releases a block of popular slots the attempts over its rate wait
+// their turn in arrival order instead of racing for the slot locks. It
+// returns a *QueuedError until the attempt's turn comes.
+func (s *Service) admitBooking(ctx context.Context, slot *AppointmentSlot) error {
+	if s.queue == nil || s.cfg.BookingQueueRate <= 0 {
+		return nil
+	}
+	clinician, err := s.lookupClinician(ctx, slot.PractitionerID)
+	if err != nil {
+		return fmt.Errorf("load clinician: %w", err)
+	}
+	scope := "clinician:" + clinician.ID.String()
+	if clinician.ClinicID != nil {
+		scope = "clinic:" + clinician.ClinicID.String()
+	}
+
+	queueCtx, cancel := context.WithTimeout(ctx, bookingQueueTimeout)
+	defer cancel()
+	adm, err := s.queue.Admit(queueCtx, scope, queueTicketFrom(ctx), s.cfg.BookingQueueRate, max(s.cfg.BookingQueueBurst, 1))
+	if err != nil {
+		bookingQueueAttempts.Inc("unchecked")
+		log.Printf("booking queue of %s unavailable, letting the attempt through: %v", scope, err)
+		return nil
+	}
+	if !adm.Admitted {
+		bookingQueueAttempts.Inc("queued")
+		return &QueuedError{Ticket: adm.Ticket, Position: adm.Position, Wait: adm.Wait}
+	}
+	bookingQueueAttempts.Inc("admitted")
+	return nil
+}
@@ -0,0 +1,97 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+)
+
+// Stages of the booking and status-change paths that get their own deadline
+const (
+	StagePatientLookup = "patient_lookup"
+	StageSlotLookup    = "slot_lookup"
+	StageJournal       = "journal"
+	StageLockSection   = "lock_section" // lock acquisition, conflict check and the create transaction
+	StageAppointment   = "appointment_lookup"
+	StageStatusUpdate  = "status_update"
+	StageEventWrite    = "event_write"
+)
+
+// DefaultStageBudgets apply unless overridden by Config.StageBudgets
+var DefaultStageBudgets = map[string]time.Duration{
+	StagePatientLookup: 300 * time.Millisecond,
+	StageSlotLookup:    300 * time.Millisecond,
+	StageJournal:       300 * time.Millisecond,
+	StageLockSection:   time.Second,
+	StageAppointment:   300 * time.Millisecond,
+	StageStatusUpdate:  500 * time.Millisecond,
+	StageEventWrite:    500 * time.Millisecond,
+}
+
+var ErrStageTimeout = &Error{
+	Code: "stage_timeout", HTTPStatus: http.StatusServiceUnavailable,
+	Message: "operation ran out of time", Retryable: true,
+}
+
+var (
+	stageCalls = metrics.NewCounter(
+		"appointment_stage_calls_total",
+		"Service stage executions.",
+		"stage",
+	)
+	stageSeconds = metrics.NewCounter(
+		"appointment_stage_seconds_total",
+		"Total time spent per service stage; divide by appointment_stage_calls_total for the mean.",
+		"stage",
+	)
+	stageDeadlines = metrics.NewCounter(
+		"appointment_stage_deadline_exceeded_total",
+		"Stages that ran out of time, by whose deadline expired: the stage budget or the caller's.",
+		"stage", "source",
+	)
+)
+
+// budgets merges configured overrides into the defaults
+func budgets(overrides map[string]time.Duration) map[string]time.Duration {
+	b := make(map[string]time.Duration, len(DefaultStageBudgets))
+	for stage, d := range DefaultStageBudgets {
+		b[stage] = d
+	}
+	for stage, d := range overrides {
+		b[stage] = d
+	}
+	return b
+}
+
+// runStage runs fn under the stage's budget, or the caller's deadline if that
+// is sooner, and records how long it took. When the budget is what expired
+// the error is ErrStageTimeout naming the stage, so a slow dependency shows up
+// as that stage instead of an anonymous request timeout.
+func (s *Service) runStage(ctx context.Context, stage string, fn func(ctx context.Context) error) error {
+	budget := s.budgets[stage]
+
+	stageCtx, cancel := ctx, context.CancelFunc(func() {})
+	if budget > 0 {
+		stageCtx, cancel = context.WithTimeout(ctx, budget)
+	}
+	defer cancel()
+
+	start := time.Now()
+	err := fn(stageCtx)
+	stageCalls.Inc(stage)
+	stageSeconds.Add(time.Since(start).Seconds(), stage)
+
+	if err == nil || !errors.Is(stageCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	if ctx.Err() != nil {
+		stageDeadlines.Inc(stage, "caller")
+		return err
+	}
+	stageDeadlines.Inc(stage, "budget")
+	return fmt.Errorf("%s exceeded its %s budget: %w", stage, budget, ErrStageTimeout)
+}
@@ -0,0 +1,64 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventAppointmentCheckedIn is logged when the patient of a confirmed
+// appointment arrives. The appointment keeps its place.
+const EventAppointmentCheckedIn = "APPOINTMENT_CHECKED_IN"
+
+// CheckIn is an appointment checked in at CheckedInAt
+type CheckIn struct {
+	Appointment
+	CheckedInAt time.Time
+}
+
+// CheckInAppointment records that the patient of a confirmed appointment
+// has arrived. Check-in opens cfg.CheckInOpens before the appointment's
+// first slot starts and closes cfg.CheckInCloses after; outside that it
+// returns ErrCheckInNotOpen or ErrCheckInClosed. An appointment that is not
+// confirmed, or stops being so before the update, returns
+// ErrInvalidStatusTransition.
+func (s *Service) CheckInAppointment(ctx context.Context, id uuid.UUID) (*CheckIn, error) {
+	appt, err := s.repo.GetAppointmentByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("load appointment: %w", err)
+	}
+	if appt.Status != StatusConfirmed {
+		return nil, fmt.Errorf("%w: appointment is %s", ErrInvalidStatusTransition, appt.Status)
+	}
+
+	slot, err := s.repo.GetSlotByID(ctx, appt.SlotID)
+	if err != nil {
+		return nil, fmt.Errorf("load slot: %w", err)
+	}
+	now := s.clock.Now()
+	if opens := slot.StartTime.Add(-s.cfg.CheckInOpens); now.Before(opens) {
+		return nil, fmt.Errorf("%w: it opens at %s", ErrCheckInNotOpen, opens.UTC().Format(time.RFC3339))
+	}
+	if closes := slot.StartTime.Add(s.cfg.CheckInCloses); now.After(closes) {
+		return nil, fmt.Errorf("%w: it closed at %s", ErrCheckInClosed, closes.UTC().Format(time.RFC3339))
+	}
+
+	updated, err := s.repo.CheckInAppointment(ctx, id, now)
+	if err != nil {
+		if errors.Is(err, ErrAppointmentNotFound) {
+			// cancelled or checked in since it was read
+			return nil, ErrInvalidStatusTransition
+		}
+		return nil, fmt.Errorf("check in: %w", err)
+	}
+
+	s.logEvent(ctx, id, EventAppointmentCheckedIn, map[string]any{
+		"slot_id":       appt.SlotID.String(),
+		"start_time":    slot.StartTime,
+		"checked_in_at": now,
+	})
+	return &CheckIn{Appointment: *updated, CheckedInAt: now}, nil
+}
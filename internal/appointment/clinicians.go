@@ -0,0 +1,193 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxClinicianNameLength = 200
+	maxSpecialtyLength     = 100
+)
+
+// NewClinician is what a clinician is added with. Specialty and ClinicID
+// are optional; without a clinic the clinician has no staff to reserve.
+type NewClinician struct {
+	Name      string
+	Specialty *string
+	ClinicID  *uuid.UUID
+}
+
+// CreateClinician adds a clinician, or returns ErrClinicNotFound when the
+// clinic named does not exist
+func (s *Service) CreateClinician(ctx context.Context, nc NewClinician) (*Clinician, error) {
+	c := Clinician{ID: uuid.New(), ClinicID: nc.ClinicID}
+	var err error
+	if c.Name, err = clinicianName(nc.Name); err != nil {
+		return nil, err
+	}
+	if nc.Specialty != nil {
+		specialty, err := clinicianSpecialty(*nc.Specialty)
+		if err != nil {
+			return nil, err
+		}
+		c.Specialty = &specialty
+	}
+
+	created, err := s.repo.CreateClinician(ctx, c)
+	if err != nil {
+		if errors.Is(err, ErrClinicNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("create clinician: %w", err)
+	}
+	return created, nil
+}
+
+// GetClinician returns the clinician with id as stored, not as cached
+func (s *Service) GetClinician(ctx context.Context, id uuid.UUID) (*Clinician, error) {
+	c, err := s.repo.GetClinicianByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrClinicianNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("get clinician: %w", err)
+	}
+	return c, nil
+}
+
+// UpdateClinician changes the clinician's name or specialty. A new
+// specialty brings its booking window with it for slots booked from then
+// on. An update with neither returns the clinician unchanged.
+func (s *Service) UpdateClinician(ctx context.Context, id uuid.UUID, u ClinicianUpdate) (*Clinician, error) {
+	if u.Name != nil {
+		name, err := clinicianName(*u.Name)
+		if err != nil {
+			return nil, err
+		}
+		u.Name = &name
+	}
+	if u.Specialty != nil {
+		specialty, err := clinicianSpecialty(*u.Specialty)
+		if err != nil {
+			return nil, err
+		}
+		u.Specialty = &specialty
+	}
+	if u.Name == nil && u.Specialty == nil {
+		return s.GetClinician(ctx, id)
+	}
+
+	c, err := s.repo.UpdateClinician(ctx, id, u)
+	if err != nil {
+		if errors.Is(err, ErrClinicianNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("update clinician: %w", err)
+	}
+	s.clinicians.forget(ctx, id)
+	return c, nil
+}
+
+// DeactivateClinician stops the clinician's slots from being booked, e.g.
+// once they leave the practice. Their open slots drop out of searches and
+// the availability calendar, and no slot can be generated or reassigned to
+// them. Appointments already made are kept; staff move them to a covering
+// clinician with ReassignSlot. Other instances notice within the lookup
+// cache's TTL.
+func (s *Service) DeactivateClinician(ctx context.Context, id uuid.UUID) (*Clinician, error) {
+	c, err := s.GetClinician(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Deactivated() {
+		return nil, fmt.Errorf("%w: since %s", ErrClinicianDeactivated, c.DeactivatedAt.UTC().Format(time.RFC3339))
+	}
+
+	c, err = s.repo.DeactivateClinician(ctx, id, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("deactivate clinician: %w", err)
+	}
+	s.clinicians.forget(ctx, id)
+	return c, nil
+}
+
+// checkBookableSlots fails with ErrClinicianDeactivated when the clinician
+// of slots, all of one clinician, is deactivated, and then as
+// checkBookingWindow does
+func (s *Service) checkBookableSlots(ctx context.Context, slots ...*AppointmentSlot) error {
+	var clinician *Clinician
+	err := s.runStage(ctx, StageSlotLookup, func(ctx context.Context) (err error) {
+		clinician, err = s.lookupClinician(ctx, slots[0].PractitionerID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("load clinician: %w", err)
+	}
+	if clinician.Deactivated() {
+		return ErrClinicianDeactivated
+	}
+	return s.checkBookingWindow(ctx, slots...)
+}
+
+// checkActiveClinician returns ErrClinicianNotFound or
+// ErrClinicianDeactivated unless the clinician may take new slots
+func (s *Service) checkActiveClinician(ctx context.Context, id uuid.UUID) error {
+	c, err := s.lookupClinician(ctx, id)
+	if err != nil {
+		return err
+	}
+	if c.Deactivated() {
+		return ErrClinicianDeactivated
+	}
+	return nil
+}
+
+func clinicianName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxClinicianNameLength {
+		return "", fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidClinician, maxClinicianNameLength)
+	}
+	return name, nil
+}
+
+func clinicianSpecialty(specialty string) (string, error) {
+	specialty = strings.TrimSpace(specialty)
+	if specialty == "" || len(specialty) > maxSpecialtyLength {
+		return "", fmt.Errorf("%w: specialty must be 1 to %d characters", ErrInvalidClinician, maxSpecialtyLength)
+	}
+	return specialty, nil
+}
+
+// maxScheduleRange bounds the range one ClinicianSchedule call covers
+const maxScheduleRange = 31 * 24 * time.Hour
+
+// ClinicianSchedule returns the clinician's appointments with a first slot
+// starting in [from, to), earliest first, with their slot and patient, so a
+// practitioner can see their day. Cancelled, expired and rejected
+// appointments are left out. The range may cover up to 31 days.
+func (s *Service) ClinicianSchedule(ctx context.Context, clinicianID uuid.UUID, from, to time.Time) ([]AppointmentDetail, error) {
+	if !from.Before(to) {
+		return nil, ErrInvalidTimeRange
+	}
+	if to.Sub(from) > maxScheduleRange {
+		return nil, fmt.Errorf("%w: a schedule covers at most %d days", ErrInvalidTimeRange, int(maxScheduleRange/(24*time.Hour)))
+	}
+	if _, err := s.repo.GetClinicianByID(ctx, clinicianID); err != nil {
+		if errors.Is(err, ErrClinicianNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load clinician: %w", err)
+	}
+
+	appts, err := s.repo.ListClinicianAppointments(ctx, clinicianID, from, to, DetailFields{Slot: true, Patient: true})
+	if err != nil {
+		return nil, fmt.Errorf("clinician schedule: %w", err)
+	}
+	return appts, nil
+}
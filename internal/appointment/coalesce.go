@@ -0,0 +1,97 @@
+package appointment
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+var coalescedReads = metrics.NewCounter(
+	"coalesced_reads_total",
+	"Reads answered by an identical read already in flight on the instance, by kind.",
+	"kind",
+)
+
+// readCoalescer shares identical reads in flight at the same time, such as
+// many clients polling one appointment, so the instance runs one query per
+// key. Nothing is kept once the read returns. Each write logging an event
+// through the service starts a new generation: reads after it never join
+// one that began before, so a client sees its own changes. Slot changes log
+// no event, so an availability read may join one that began just before a
+// slot was added, as it could have raced the change anyway.
+type readCoalescer struct {
+	gen   atomic.Uint64
+	reads singleflight.Group
+}
+
+// invalidate makes later reads start afresh rather than join one in flight
+func (c *readCoalescer) invalidate() {
+	c.gen.Add(1)
+}
+
+// coalesce runs read, or waits for an identical one in flight. key names
+// the read within kind; the tenant and shard are added to it. The shared
+// read runs on the first caller's context; the others wait for it even if
+// theirs is cancelled first.
+func coalesce[T any](ctx context.Context, c *readCoalescer, kind, key string, read func(ctx context.Context) (T, error)) (T, error) {
+	full := fmt.Sprintf("%d/%s/%s/%s/%s", c.gen.Load(), kind, shard.Tenant(ctx), shard.Name(ctx), key)
+	ran := false
+	v, err, shared := c.reads.Do(full, func() (any, error) {
+		ran = true
+		return read(ctx)
+	})
+	if shared && !ran {
+		coalescedReads.Inc(kind)
+	}
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// GetAppointment retrieves an appointment with the related entities chosen
+// by fields. Identical reads in flight share one query; each caller gets its
+// own copy of the detail, though not of the entities it points to.
+func (s *Service) GetAppointment(ctx context.Context, id uuid.UUID, fields DetailFields) (*AppointmentDetail, error) {
+	key := fmt.Sprintf("%s/%t/%t/%t", id, fields.Slot, fields.Patient, fields.Clinician)
+	detail, err := coalesce(ctx, &s.reads, "appointment", key, func(ctx context.Context) (*AppointmentDetail, error) {
+		return s.getAppointment(ctx, id, fields)
+	})
+	if err != nil {
+		return nil, err
+	}
+	d := *detail
+	return &d, nil
+}
+
+// GetAvailabilityVersion returns the clinician's availability version. Any
+// change to their slots or bookings increases it, so a client holding
+// availability fetched at the same version can keep using it. Identical
+// reads in flight share one query.
+func (s *Service) GetAvailabilityVersion(ctx context.Context, clinicianID uuid.UUID) (int64, error) {
+	return coalesce(ctx, &s.reads, "availability_version", clinicianID.String(), func(ctx context.Context) (int64, error) {
+		return s.getAvailabilityVersion(ctx, clinicianID)
+	})
+}
+
+// GetAvailabilityCalendar counts the clinician's slots for every day of the
+// month containing month, with days taken in loc. Slots outside the booking
+// window of the clinician's specialty are not counted as open. Identical
+// reads in flight share one query.
+func (s *Service) GetAvailabilityCalendar(ctx context.Context, clinicianID uuid.UUID, month time.Time, loc *time.Location) ([]CalendarDay, error) {
+	year, mon, _ := month.Date()
+	key := fmt.Sprintf("%s/%04d-%02d/%s", clinicianID, year, mon, loc)
+	days, err := coalesce(ctx, &s.reads, "availability_calendar", key, func(ctx context.Context) ([]CalendarDay, error) {
+		return s.getAvailabilityCalendar(ctx, clinicianID, month, loc)
+	})
+	return slices.Clone(days), err
+}
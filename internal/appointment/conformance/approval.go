@@ -0,0 +1,225 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// newApprovalFixture is a fixture whose clinic requires approval
+func newApprovalFixture(ctx context.Context, b Backend) (*fixture, error) {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	f.clinic = appointment.Clinic{ID: uuid.New(), Name: "Conformance Triage Clinic", RequiresApproval: true}
+	if err := b.InsertClinic(ctx, f.clinic); err != nil {
+		return nil, err
+	}
+	f.clinician.ID = uuid.New()
+	f.clinician.ClinicID = &f.clinic.ID
+	if err := b.InsertClinician(ctx, f.clinician); err != nil {
+		return nil, err
+	}
+
+	slot, err := f.addSlot(ctx, b, 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	f.slot = *slot
+	return f, nil
+}
+
+func testResolveApproval(ctx context.Context, b Backend) error {
+	plain, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	f, err := newApprovalFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	if required, err := b.SlotRequiresApproval(ctx, plain.slot.ID); err != nil || required {
+		return fmt.Errorf("SlotRequiresApproval without the flag: got %t, %v", required, err)
+	}
+	if required, err := b.SlotRequiresApproval(ctx, f.slot.ID); err != nil || !required {
+		return fmt.Errorf("SlotRequiresApproval with the flag: got %t, %v", required, err)
+	}
+	_, err = b.SlotRequiresApproval(ctx, uuid.New())
+	if err := expectErr(err, appointment.ErrSlotNotFound); err != nil {
+		return fmt.Errorf("SlotRequiresApproval of missing slot: %w", err)
+	}
+
+	hold := time.Now().Add(10 * time.Minute).Truncate(time.Millisecond)
+	deadline := hold.Add(time.Hour)
+	appt, err := b.CreatePendingAppointment(ctx, f.slot.ID, f.patient.ID, hold)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.RequestApproval(ctx, appt.ID, hold.Add(time.Millisecond), deadline)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("request approval after hold: %w", err)
+	}
+	waiting, err := b.RequestApproval(ctx, appt.ID, hold, deadline)
+	if err != nil {
+		return fmt.Errorf("request approval at hold deadline: %w", err)
+	}
+	if waiting.Status != appointment.StatusPendingApproval || waiting.ExpiresAt == nil || !waiting.ExpiresAt.Equal(deadline) {
+		return fmt.Errorf("expected pending_approval until %s, got %s until %v", deadline, waiting.Status, waiting.ExpiresAt)
+	}
+
+	overdue, err := b.FindOverdueApprovals(ctx, deadline)
+	if err != nil {
+		return fmt.Errorf("FindOverdueApprovals: %w", err)
+	}
+	if containsAppointment(overdue, appt.ID) {
+		return fmt.Errorf("appointment overdue at its deadline")
+	}
+	overdue, err = b.FindOverdueApprovals(ctx, deadline.Add(time.Millisecond))
+	if err != nil {
+		return fmt.Errorf("FindOverdueApprovals: %w", err)
+	}
+	if !containsAppointment(overdue, appt.ID) {
+		return fmt.Errorf("appointment not overdue after its deadline")
+	}
+
+	_, err = b.ResolveApproval(ctx, appt.ID, appointment.StatusConfirmed, deadline.Add(time.Millisecond))
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("approve after deadline: %w", err)
+	}
+	_, err = b.ResolveApproval(ctx, appt.ID, appointment.StatusCancelled, deadline)
+	if err := expectErr(err, appointment.ErrInvalidStatusTransition); err != nil {
+		return fmt.Errorf("resolve approval to cancelled: %w", err)
+	}
+	if _, err := b.ResolveApproval(ctx, appt.ID, appointment.StatusConfirmed, deadline); err != nil {
+		return fmt.Errorf("approve at deadline: %w", err)
+	}
+	_, err = b.ResolveApproval(ctx, appt.ID, appointment.StatusRejected, deadline)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("reject approved: %w", err)
+	}
+
+	// Waiting bookings do not hold a place, so approval is where capacity
+	// is enforced
+	second, err := b.CreatePendingAppointment(ctx, f.slot.ID, f.patient.ID, hold)
+	if err != nil {
+		return err
+	}
+	if _, err := b.RequestApproval(ctx, second.ID, hold, deadline); err != nil {
+		return fmt.Errorf("request approval on full slot: %w", err)
+	}
+	_, err = b.ResolveApproval(ctx, second.ID, appointment.StatusConfirmed, hold)
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("approve over capacity: %w", err)
+	}
+	if _, err := b.ResolveApproval(ctx, second.ID, appointment.StatusRejected, deadline.Add(time.Hour)); err != nil {
+		return fmt.Errorf("reject after deadline: %w", err)
+	}
+	return nil
+}
+
+// testApprovalWorkflow runs bookings at a triage clinic through the service:
+// approved, rejected, left to time out and approved too late
+func testApprovalWorkflow(ctx context.Context, b Backend) error {
+	f, err := newApprovalFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, fake := timeTravelService(b, time.Now())
+	start := fake.Now()
+
+	// request books a new slot and confirms it, which asks for approval
+	request := func(d time.Duration) (*appointment.Appointment, error) {
+		slot, err := f.addSlot(ctx, b, d)
+		if err != nil {
+			return nil, err
+		}
+		fake.Set(start)
+		appt, err := svc.CreateAppointment(ctx, slot.ID, f.patient.ID)
+		if err != nil {
+			return nil, fmt.Errorf("CreateAppointment: %w", err)
+		}
+		waiting, err := svc.ConfirmAppointment(ctx, appt.ID)
+		if err != nil {
+			return nil, fmt.Errorf("ConfirmAppointment: %w", err)
+		}
+		deadline := start.Add(approvalWindow)
+		if waiting.Status != appointment.StatusPendingApproval || waiting.ExpiresAt == nil || !waiting.ExpiresAt.Equal(deadline) {
+			return nil, fmt.Errorf("expected pending_approval until %s, got %s until %v", deadline, waiting.Status, waiting.ExpiresAt)
+		}
+		return waiting, nil
+	}
+
+	approved, err := request(72 * time.Hour)
+	if err != nil {
+		return err
+	}
+	if _, err := svc.ApproveAppointment(ctx, approved.ID, "dr-conformance", "ok"); err != nil {
+		return fmt.Errorf("ApproveAppointment: %w", err)
+	}
+	if err := expectStatus(ctx, b, approved.ID, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("approved: %w", err)
+	}
+
+	rejected, err := request(96 * time.Hour)
+	if err != nil {
+		return err
+	}
+	if _, err := svc.RejectAppointment(ctx, rejected.ID, "dr-conformance", ""); err != nil {
+		return fmt.Errorf("RejectAppointment: %w", err)
+	}
+	_, err = svc.ApproveAppointment(ctx, rejected.ID, "", "")
+	if err := expectErr(err, appointment.ErrInvalidStatusTransition); err != nil {
+		return fmt.Errorf("approve rejected: %w", err)
+	}
+
+	timedOut, err := request(120 * time.Hour)
+	if err != nil {
+		return err
+	}
+	late, err := request(144 * time.Hour)
+	if err != nil {
+		return err
+	}
+
+	fake.Set(start.Add(approvalWindow))
+	if _, err := svc.RejectOverdueApprovals(ctx, nil); err != nil {
+		return fmt.Errorf("RejectOverdueApprovals at deadline: %w", err)
+	}
+	if err := expectStatus(ctx, b, timedOut.ID, appointment.StatusPendingApproval); err != nil {
+		return fmt.Errorf("at deadline: %w", err)
+	}
+
+	fake.Set(start.Add(approvalWindow + time.Millisecond))
+	_, err = svc.ApproveAppointment(ctx, late.ID, "", "")
+	if err := expectErr(err, appointment.ErrApprovalWindowPassed); err != nil {
+		return fmt.Errorf("approve after deadline: %w", err)
+	}
+	if err := expectStatus(ctx, b, late.ID, appointment.StatusRejected); err != nil {
+		return fmt.Errorf("approved late: %w", err)
+	}
+
+	if _, err := svc.RejectOverdueApprovals(ctx, nil); err != nil {
+		return fmt.Errorf("RejectOverdueApprovals after deadline: %w", err)
+	}
+	if err := expectStatus(ctx, b, timedOut.ID, appointment.StatusRejected); err != nil {
+		return fmt.Errorf("after deadline: %w", err)
+	}
+	return nil
+}
+
+func containsAppointment(appts []appointment.Appointment, id uuid.UUID) bool {
+	for _, a := range appts {
+		if a.ID == id {
+			return true
+		}
+	}
+	return false
+}
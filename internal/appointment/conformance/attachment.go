@@ -0,0 +1,209 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/blob"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// pdfContent sniffs as application/pdf
+var pdfContent = []byte("%PDF-1.7\n1 0 obj << /Type /Catalog >> endobj\n%%EOF\n")
+
+// eicar is the standard antivirus test string, which the fake scanner flags
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeScanner flags content containing the EICAR string and fails while
+// down is set
+type fakeScanner struct {
+	down bool
+}
+
+func (s *fakeScanner) Scan(_ context.Context, _ string, content []byte) (string, error) {
+	if s.down {
+		return "", errors.New("scanner unreachable")
+	}
+	if bytes.Contains(content, []byte(eicar)) {
+		return "Eicar-Test-Signature", nil
+	}
+	return "", nil
+}
+
+func testAttachmentRoundTrip(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.GetAttachment(ctx, uuid.New()); expectErr(err, appointment.ErrAttachmentNotFound) != nil {
+		return fmt.Errorf("GetAttachment of missing attachment: %w", expectErr(err, appointment.ErrAttachmentNotFound))
+	}
+
+	var ids []uuid.UUID
+	for _, kind := range []appointment.AttachmentKind{appointment.AttachmentReferralLetter, appointment.AttachmentIntakeForm} {
+		id := uuid.New()
+		saved, err := b.InsertAttachment(ctx, appointment.Attachment{
+			ID:            id,
+			AppointmentID: appt.ID,
+			Kind:          kind,
+			Filename:      "letter.pdf",
+			ContentType:   "application/pdf",
+			Size:          1234,
+			BlobKey:       "attachments/" + appt.ID.String() + "/" + id.String(),
+			ScanStatus:    appointment.ScanClean,
+		})
+		if err != nil {
+			return fmt.Errorf("InsertAttachment: %w", err)
+		}
+		if saved.ID != id || saved.Kind != kind || saved.Size != 1234 || saved.ScanStatus != appointment.ScanClean || saved.CreatedAt.IsZero() {
+			return fmt.Errorf("attachment did not round trip: %+v", saved)
+		}
+		ids = append(ids, id)
+	}
+
+	got, err := b.GetAttachment(ctx, ids[0])
+	if err != nil {
+		return fmt.Errorf("GetAttachment: %w", err)
+	}
+	if got.AppointmentID != appt.ID || got.Filename != "letter.pdf" || got.BlobKey == "" {
+		return fmt.Errorf("unexpected attachment %+v", got)
+	}
+
+	list, err := b.ListAttachments(ctx, appt.ID)
+	if err != nil {
+		return fmt.Errorf("ListAttachments: %w", err)
+	}
+	if len(list) != 2 {
+		return fmt.Errorf("expected 2 attachments, got %d", len(list))
+	}
+	for _, a := range list {
+		if a.ID != ids[0] && a.ID != ids[1] {
+			return fmt.Errorf("unexpected attachment %s listed", a.ID)
+		}
+	}
+	return nil
+}
+
+// testAttachmentWorkflow uploads through the service into a directory store
+// and checks the limits, the scan and that the stored content reads back
+func testAttachmentWorkflow(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "conformance-blobs-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	store, err := blob.NewFSStore(dir)
+	if err != nil {
+		return err
+	}
+	scanner := &fakeScanner{}
+	cfg := config.Config{
+		AppointmentTTL:     holdTTL,
+		LockTTL:            5 * time.Second,
+		AttachmentMaxBytes: 1024,
+		AttachmentTypes:    []string{"application/pdf"},
+	}
+	svc := appointment.NewService(b, redisclient.NewInMemorySlotLocker(), cfg,
+		appointment.WithBlobStore(store), appointment.WithScanner(scanner))
+
+	upload := func(kind appointment.AttachmentKind, name string, content []byte) (*appointment.Attachment, error) {
+		return svc.AddAttachment(ctx, appt.ID, appointment.AttachmentUpload{Kind: kind, Filename: name, Content: content})
+	}
+	for _, bad := range []struct {
+		kind    appointment.AttachmentKind
+		name    string
+		content []byte
+		want    error
+	}{
+		{"x-ray", "letter.pdf", pdfContent, appointment.ErrInvalidAttachment},
+		{appointment.AttachmentReferralLetter, "  ", pdfContent, appointment.ErrInvalidAttachment},
+		{appointment.AttachmentReferralLetter, "letter.pdf", nil, appointment.ErrInvalidAttachment},
+		{appointment.AttachmentReferralLetter, "letter.pdf", append(pdfContent, make([]byte, 1024)...), appointment.ErrAttachmentTooLarge},
+		{appointment.AttachmentReferralLetter, "letter.txt", []byte("plain text"), appointment.ErrUnsupportedAttachmentType},
+		{appointment.AttachmentReferralLetter, "letter.pdf", append(pdfContent, eicar...), appointment.ErrAttachmentInfected},
+	} {
+		_, err := upload(bad.kind, bad.name, bad.content)
+		if err := expectErr(err, bad.want); err != nil {
+			return fmt.Errorf("upload %s %q: %w", bad.kind, bad.name, err)
+		}
+	}
+	_, err = svc.AddAttachment(ctx, uuid.New(), appointment.AttachmentUpload{Kind: appointment.AttachmentOther, Filename: "a.pdf", Content: pdfContent})
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("upload for a missing appointment: %w", err)
+	}
+
+	scanner.down = true
+	_, err = upload(appointment.AttachmentReferralLetter, "letter.pdf", pdfContent)
+	if err := expectErr(err, appointment.ErrAttachmentScanFailed); err != nil {
+		return fmt.Errorf("upload while the scanner is down: %w", err)
+	}
+	scanner.down = false
+
+	// Directories sent by the browser are dropped from the name
+	saved, err := upload(appointment.AttachmentReferralLetter, `C:\scans\letter.pdf`, pdfContent)
+	if err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+	if saved.Filename != "letter.pdf" || saved.ContentType != "application/pdf" || saved.ScanStatus != appointment.ScanClean {
+		return fmt.Errorf("unexpected attachment %+v", saved)
+	}
+
+	list, err := svc.ListAttachments(ctx, appt.ID)
+	if err != nil {
+		return fmt.Errorf("ListAttachments: %w", err)
+	}
+	if len(list) != 1 || list[0].ID != saved.ID {
+		return fmt.Errorf("expected only the clean upload stored, got %d attachments", len(list))
+	}
+	objects := 0
+	if err := store.List(ctx, "attachments/", func(blob.ObjectInfo) error { objects++; return nil }); err != nil {
+		return fmt.Errorf("list blobs: %w", err)
+	}
+	if objects != 1 {
+		return fmt.Errorf("expected 1 stored object, got %d", objects)
+	}
+
+	a, content, err := svc.OpenAttachment(ctx, saved.ID)
+	if err != nil {
+		return fmt.Errorf("OpenAttachment: %w", err)
+	}
+	data, err := io.ReadAll(content)
+	content.Close()
+	if err != nil {
+		return fmt.Errorf("read attachment: %w", err)
+	}
+	if a.ID != saved.ID || !bytes.Equal(data, pdfContent) {
+		return fmt.Errorf("attachment content did not round trip")
+	}
+
+	// Without a store attachments are refused
+	plain, _ := timeTravelService(b, time.Now())
+	_, err = plain.AddAttachment(ctx, appt.ID, appointment.AttachmentUpload{Kind: appointment.AttachmentOther, Filename: "a.pdf", Content: pdfContent})
+	if err := expectErr(err, appointment.ErrAttachmentsDisabled); err != nil {
+		return fmt.Errorf("upload without a store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testAttendance records one confirmed appointment as completed and another
+// as a no-show. Neither is taken before the slot has ended, from a pending
+// appointment or twice, and each writes its event once.
+func testAttendance(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	other, err := f.addSlot(ctx, b, 26*time.Hour)
+	if err != nil {
+		return err
+	}
+	svc, fake := timeTravelService(b, time.Now())
+
+	attended, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	_, err = svc.CompleteAppointment(ctx, attended.ID, "", "")
+	if err := expectErr(err, appointment.ErrInvalidStatusTransition); err != nil {
+		return fmt.Errorf("complete while pending: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, attended.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+	missed, err := svc.CreateAppointment(ctx, other.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, missed.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+
+	_, err = svc.CompleteAppointment(ctx, attended.ID, "", "")
+	if err := expectErr(err, appointment.ErrAppointmentNotEnded); err != nil {
+		return fmt.Errorf("complete before the visit: %w", err)
+	}
+	_, err = svc.MarkNoShow(ctx, missed.ID, "", "")
+	if err := expectErr(err, appointment.ErrAppointmentNotEnded); err != nil {
+		return fmt.Errorf("no-show before the visit: %w", err)
+	}
+
+	// The fixture slot starts a day out and the other two hours later, both
+	// lasting half an hour
+	fake.Advance(25 * time.Hour)
+	if _, err := svc.CompleteAppointment(ctx, attended.ID, "front-desk", "seen on time"); err != nil {
+		return fmt.Errorf("CompleteAppointment: %w", err)
+	}
+	_, err = svc.MarkNoShow(ctx, missed.ID, "", "")
+	if err := expectErr(err, appointment.ErrAppointmentNotEnded); err != nil {
+		return fmt.Errorf("no-show before the later visit: %w", err)
+	}
+	fake.Advance(2 * time.Hour)
+	if _, err := svc.MarkNoShow(ctx, missed.ID, "front-desk", ""); err != nil {
+		return fmt.Errorf("MarkNoShow: %w", err)
+	}
+
+	_, err = svc.MarkNoShow(ctx, attended.ID, "", "")
+	if err := expectErr(err, appointment.ErrInvalidStatusTransition); err != nil {
+		return fmt.Errorf("no-show after completing: %w", err)
+	}
+	_, err = svc.CompleteAppointment(ctx, missed.ID, "", "")
+	if err := expectErr(err, appointment.ErrInvalidStatusTransition); err != nil {
+		return fmt.Errorf("complete after a no-show: %w", err)
+	}
+	if err := expectStatus(ctx, b, attended.ID, appointment.StatusCompleted); err != nil {
+		return err
+	}
+	if err := expectStatus(ctx, b, missed.ID, appointment.StatusNoShow); err != nil {
+		return err
+	}
+
+	for event, id := range map[string]uuid.UUID{
+		appointment.EventAppointmentCompleted: attended.ID,
+		appointment.EventAppointmentNoShow:    missed.ID,
+	} {
+		counts, err := countEvents(ctx, b, f.patient.ID, event)
+		if err != nil {
+			return err
+		}
+		if len(counts) != 1 || counts[id] != 1 {
+			return fmt.Errorf("expected one %s event for %s, got %v", event, id, counts)
+		}
+	}
+	return nil
+}
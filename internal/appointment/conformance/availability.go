@@ -0,0 +1,146 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testSlotGeneration sets a clinician's weekly availability and checks
+// generating a range creates its slots once, around the clinician's
+// existing slots, and that invalid templates and ranges are refused
+func testSlotGeneration(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	// A week starting clear of the fixture's slot, in UTC
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	first := today.AddDate(0, 0, 3)
+	last := first.AddDate(0, 0, 6)
+
+	_, err = svc.GenerateSlots(ctx, f.clinician.ID, first, last)
+	if err := expectErr(err, appointment.ErrAvailabilityTemplateNotFound); err != nil {
+		return fmt.Errorf("GenerateSlots without a template: %w", err)
+	}
+
+	slotType := " follow_up "
+	everyDay := func(start, end int) []appointment.AvailabilityPeriod {
+		var periods []appointment.AvailabilityPeriod
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			periods = append(periods, appointment.AvailabilityPeriod{Weekday: d, Start: start, End: end})
+		}
+		return periods
+	}
+	template := appointment.AvailabilityTemplate{
+		ClinicianID:  f.clinician.ID,
+		TimeZone:     "UTC",
+		SlotDuration: time.Hour,
+		Capacity:     2,
+		SlotType:     &slotType,
+		Periods:      everyDay(10*60, 12*60),
+	}
+
+	for _, c := range []struct {
+		what   string
+		change func(t *appointment.AvailabilityTemplate)
+	}{
+		{"an unknown time zone", func(t *appointment.AvailabilityTemplate) { t.TimeZone = "Mars/Olympus" }},
+		{"slots of seconds", func(t *appointment.AvailabilityTemplate) { t.SlotDuration = 90 * time.Second }},
+		{"no capacity", func(t *appointment.AvailabilityTemplate) { t.Capacity = 0 }},
+		{"a period shorter than a slot", func(t *appointment.AvailabilityTemplate) { t.Periods = everyDay(10*60, 10*60+30) }},
+		{"overlapping periods", func(t *appointment.AvailabilityTemplate) {
+			t.Periods = append(everyDay(10*60, 12*60), appointment.AvailabilityPeriod{Weekday: time.Monday, Start: 11 * 60, End: 13 * 60})
+		}},
+	} {
+		invalid := template
+		c.change(&invalid)
+		_, err := svc.PutAvailabilityTemplate(ctx, invalid)
+		if err := expectErr(err, appointment.ErrInvalidAvailabilityTemplate); err != nil {
+			return fmt.Errorf("PutAvailabilityTemplate with %s: %w", c.what, err)
+		}
+	}
+	unknown := template
+	unknown.ClinicianID = uuid.New()
+	_, err = svc.PutAvailabilityTemplate(ctx, unknown)
+	if err := expectErr(err, appointment.ErrClinicianNotFound); err != nil {
+		return fmt.Errorf("PutAvailabilityTemplate of an unknown clinician: %w", err)
+	}
+
+	saved, err := svc.PutAvailabilityTemplate(ctx, template)
+	if err != nil {
+		return fmt.Errorf("PutAvailabilityTemplate: %w", err)
+	}
+	if saved.SlotType == nil || *saved.SlotType != "follow_up" || len(saved.Periods) != 7 || saved.SlotDuration != time.Hour {
+		return fmt.Errorf("unexpected template %+v", saved)
+	}
+
+	// The clinician already has 10:30 on the first day; a deleted slot at
+	// 10:00 the next day is no obstacle
+	if _, err := f.insertSlotAt(ctx, b, first.Add(10*time.Hour+30*time.Minute), 1, appointment.SlotOpen); err != nil {
+		return err
+	}
+	if _, err := f.insertSlotAt(ctx, b, first.AddDate(0, 0, 1).Add(10*time.Hour), 1, appointment.SlotDeleted); err != nil {
+		return err
+	}
+
+	_, err = svc.GenerateSlots(ctx, f.clinician.ID, last, first)
+	if err := expectErr(err, appointment.ErrInvalidSlotGeneration); err != nil {
+		return fmt.Errorf("GenerateSlots of a reversed range: %w", err)
+	}
+	_, err = svc.GenerateSlots(ctx, f.clinician.ID, first, first.AddDate(0, 0, appointment.MaxSlotGenerationDays))
+	if err := expectErr(err, appointment.ErrInvalidSlotGeneration); err != nil {
+		return fmt.Errorf("GenerateSlots of a range too long: %w", err)
+	}
+
+	gen, err := svc.GenerateSlots(ctx, f.clinician.ID, first, last)
+	if err != nil {
+		return fmt.Errorf("GenerateSlots: %w", err)
+	}
+	// Two slots a day for seven days, but the first day's 10:00 overlaps
+	if len(gen.Slots) != 13 || gen.Skipped != 1 {
+		return fmt.Errorf("expected 13 slots created and 1 skipped, got %d and %d", len(gen.Slots), gen.Skipped)
+	}
+	if got := gen.Slots[0]; !got.StartTime.Equal(first.Add(11*time.Hour)) || !got.EndTime.Equal(first.Add(12*time.Hour)) {
+		return fmt.Errorf("expected the first slot at 11:00 to 12:00 on %s, got %s to %s",
+			first.Format(time.DateOnly), got.StartTime, got.EndTime)
+	}
+	for _, s := range gen.Slots {
+		if s.PractitionerID != f.clinician.ID || s.Status != appointment.SlotOpen || s.Capacity != 2 ||
+			s.SlotType == nil || *s.SlotType != "follow_up" {
+			return fmt.Errorf("unexpected generated slot %+v", s)
+		}
+		stored, err := b.GetSlotByID(ctx, s.ID)
+		if err != nil {
+			return fmt.Errorf("GetSlotByID of a generated slot: %w", err)
+		}
+		if !stored.StartTime.Equal(s.StartTime) {
+			return fmt.Errorf("expected slot %s stored at %s, got %s", s.ID, s.StartTime, stored.StartTime)
+		}
+	}
+
+	// The generated slots can be booked
+	if _, err := svc.CreateAppointment(ctx, gen.Slots[0].ID, f.patient.ID); err != nil {
+		return fmt.Errorf("CreateAppointment in a generated slot: %w", err)
+	}
+
+	again, err := svc.GenerateSlots(ctx, f.clinician.ID, first, last)
+	if err != nil {
+		return fmt.Errorf("GenerateSlots again: %w", err)
+	}
+	if len(again.Slots) != 0 || again.Skipped != 14 {
+		return fmt.Errorf("expected the range generated again to skip all 14 slots, got %d created and %d skipped", len(again.Slots), again.Skipped)
+	}
+
+	if err := svc.DeleteAvailabilityTemplate(ctx, f.clinician.ID); err != nil {
+		return fmt.Errorf("DeleteAvailabilityTemplate: %w", err)
+	}
+	_, err = svc.GetAvailabilityTemplate(ctx, f.clinician.ID)
+	return expectErr(err, appointment.ErrAvailabilityTemplateNotFound)
+}
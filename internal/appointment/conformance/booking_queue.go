@@ -0,0 +1,88 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// testBookingQueue books a clinic past its burst and checks the attempts
+// over it are queued in arrival order, keep their place when retried with
+// their ticket and go ahead once their turn comes, while another clinic's
+// bookings are not held up
+func testBookingQueue(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	cfg := config.Config{AppointmentTTL: holdTTL, LockTTL: 5 * time.Second, BookingQueueRate: 2, BookingQueueBurst: 2}
+	svc := appointment.NewService(b, redisclient.NewInMemorySlotLocker(), cfg,
+		appointment.WithBookingQueue(redisclient.NewInMemoryBookingQueue()))
+
+	slots := make([]*appointment.AppointmentSlot, 4)
+	for i := range slots {
+		if slots[i], err = f.addSlot(ctx, b, time.Duration(170+i)*time.Hour); err != nil {
+			return err
+		}
+	}
+	book := func(ctx context.Context, slot *appointment.AppointmentSlot) (*appointment.QueuedError, error) {
+		_, err := svc.CreateAppointment(ctx, slot.ID, f.patient.ID)
+		var queued *appointment.QueuedError
+		if errors.As(err, &queued) {
+			if !errors.Is(err, appointment.ErrBookingQueued) {
+				return nil, fmt.Errorf("expected a queued attempt to be ErrBookingQueued, got %v", err)
+			}
+			return queued, nil
+		}
+		return nil, err
+	}
+
+	for _, slot := range slots[:2] {
+		if queued, err := book(ctx, slot); err != nil || queued != nil {
+			return fmt.Errorf("CreateAppointment within the burst: queued %+v, %w", queued, err)
+		}
+	}
+	third, err := book(ctx, slots[2])
+	if err != nil || third == nil || third.Position != 1 || third.Ticket == "" || third.Wait <= 0 {
+		return fmt.Errorf("expected the third attempt first in the queue, got %+v, %v", third, err)
+	}
+	fourth, err := book(ctx, slots[3])
+	if err != nil || fourth == nil || fourth.Position != 2 {
+		return fmt.Errorf("expected the fourth attempt second in the queue, got %+v, %v", fourth, err)
+	}
+	again, err := book(appointment.WithQueueTicket(ctx, third.Ticket), slots[2])
+	if err != nil || again == nil || again.Ticket != third.Ticket || again.Position != 1 {
+		return fmt.Errorf("expected a retry with the ticket to keep its place, got %+v, %v", again, err)
+	}
+
+	// A clinician of no clinic has a queue of their own
+	other := &fixture{clinician: appointment.Clinician{ID: uuid.New(), Name: "Dr. Unaffiliated"}}
+	if err := b.InsertClinician(ctx, other.clinician); err != nil {
+		return err
+	}
+	otherSlot, err := other.addSlot(ctx, b, 170*time.Hour)
+	if err != nil {
+		return err
+	}
+	if queued, err := book(ctx, otherSlot); err != nil || queued != nil {
+		return fmt.Errorf("CreateAppointment with another clinician: queued %+v, %w", queued, err)
+	}
+
+	time.Sleep(again.Wait + 50*time.Millisecond)
+	if queued, err := book(appointment.WithQueueTicket(ctx, third.Ticket), slots[2]); err != nil || queued != nil {
+		return fmt.Errorf("CreateAppointment once its turn came: queued %+v, %w", queued, err)
+	}
+	// The ticket is used up, so reusing it joins the back of the queue
+	reused, err := book(appointment.WithQueueTicket(ctx, third.Ticket), slots[3])
+	if err != nil || reused == nil || reused.Ticket == third.Ticket {
+		return fmt.Errorf("expected a used ticket to be queued again with a new one, got %+v, %v", reused, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testCancelPatientAppointments cancels a patient's appointments by status
+// and checks each cancellation is counted and has its event
+func testCancelPatientAppointments(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	var booked []*appointment.Appointment
+	for i := 0; i < 3; i++ {
+		slot, err := f.addSlot(ctx, b, time.Duration(25+i)*time.Hour)
+		if err != nil {
+			return err
+		}
+		appt, err := b.CreatePendingAppointment(ctx, slot.ID, f.patient.ID, time.Now().Add(10*time.Minute))
+		if err != nil {
+			return fmt.Errorf("CreatePendingAppointment: %w", err)
+		}
+		booked = append(booked, appt)
+	}
+	if _, err := b.UpdateAppointmentStatus(ctx, booked[1].ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("UpdateAppointmentStatus: %w", err)
+	}
+	if _, err := b.UpdateAppointmentStatus(ctx, booked[2].ID, appointment.StatusPending, appointment.StatusExpired); err != nil {
+		return fmt.Errorf("UpdateAppointmentStatus: %w", err)
+	}
+
+	pendingOnly, err := svc.CancelPatientAppointments(ctx, f.patient.ID,
+		[]appointment.AppointmentStatus{appointment.StatusPending}, "account_closed", nil)
+	if err != nil {
+		return fmt.Errorf("CancelPatientAppointments of pending: %w", err)
+	}
+	if len(pendingOnly.Cancelled) != 1 || pendingOnly.Cancelled[0].ID != booked[0].ID ||
+		pendingOnly.ByStatus[appointment.StatusPending] != 1 {
+		return fmt.Errorf("expected only the pending appointment cancelled, got %+v", pendingOnly)
+	}
+	if err := expectStatus(ctx, b, booked[1].ID, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("confirmed appointment: %w", err)
+	}
+
+	all, err := svc.CancelPatientAppointments(ctx, f.patient.ID, nil, "account_closed", nil)
+	if err != nil {
+		return fmt.Errorf("CancelPatientAppointments: %w", err)
+	}
+	if len(all.Cancelled) != 1 || all.Cancelled[0].ID != booked[1].ID ||
+		all.Cancelled[0].PreviousStatus != appointment.StatusConfirmed || all.ByStatus[appointment.StatusConfirmed] != 1 {
+		return fmt.Errorf("expected the confirmed appointment cancelled, got %+v", all)
+	}
+	for i, want := range []appointment.AppointmentStatus{appointment.StatusCancelled, appointment.StatusCancelled, appointment.StatusExpired} {
+		if err := expectStatus(ctx, b, booked[i].ID, want); err != nil {
+			return fmt.Errorf("appointment %d: %w", i, err)
+		}
+	}
+
+	timeline, err := svc.GetPatientTimeline(ctx, f.patient.ID, 100)
+	if err != nil {
+		return fmt.Errorf("GetPatientTimeline: %w", err)
+	}
+	cancelled := 0
+	for _, e := range timeline.Entries {
+		if e.EventType == appointment.EventAppointmentCancelled {
+			cancelled++
+		}
+	}
+	if cancelled != 2 {
+		return fmt.Errorf("expected 2 cancellation events, got %d", cancelled)
+	}
+
+	again, err := svc.CancelPatientAppointments(ctx, f.patient.ID, nil, "account_closed", nil)
+	if err != nil {
+		return fmt.Errorf("CancelPatientAppointments with nothing left: %w", err)
+	}
+	if len(again.Cancelled) != 0 {
+		return fmt.Errorf("expected nothing left to cancel, got %d", len(again.Cancelled))
+	}
+
+	_, err = svc.CancelPatientAppointments(ctx, f.patient.ID, []appointment.AppointmentStatus{appointment.StatusExpired}, "", nil)
+	if err := expectErr(err, appointment.ErrInvalidCancellation); err != nil {
+		return fmt.Errorf("CancelPatientAppointments of expired: %w", err)
+	}
+	_, err = svc.CancelPatientAppointments(ctx, uuid.New(), nil, "", nil)
+	if err := expectErr(err, appointment.ErrPatientNotFound); err != nil {
+		return fmt.Errorf("CancelPatientAppointments of a missing patient: %w", err)
+	}
+	return nil
+}
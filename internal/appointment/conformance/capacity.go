@@ -0,0 +1,132 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testGroupSlotHolds books a slot of capacity 2 through the service: live
+// holds take places like confirmed appointments, while cancelled holds and
+// holds past their deadline give theirs back even before the expiry worker
+// has run.
+func testGroupSlotHolds(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slot, err := f.addSlotWithCapacity(ctx, b, 48*time.Hour, 2)
+	if err != nil {
+		return err
+	}
+	svc, fake := timeTravelService(b, time.Now())
+
+	first, err := svc.CreateAppointment(ctx, slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("first hold: %w", err)
+	}
+	second, err := svc.CreateAppointment(ctx, slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("second hold: %w", err)
+	}
+	_, err = svc.CreateAppointment(ctx, slot.ID, f.patient.ID)
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("hold over capacity: %w", err)
+	}
+	_, err = svc.PrecheckBooking(ctx, appointment.BookingRequest{SlotID: slot.ID, PatientID: f.patient.ID, SlotCount: 1})
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("precheck over capacity: %w", err)
+	}
+
+	if _, err := b.UpdateAppointmentStatus(ctx, first.ID, appointment.StatusPending, appointment.StatusCancelled); err != nil {
+		return fmt.Errorf("cancel hold: %w", err)
+	}
+	if _, err := svc.CreateAppointment(ctx, slot.ID, f.patient.ID); err != nil {
+		return fmt.Errorf("hold after cancel: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, second.ID); err != nil {
+		return fmt.Errorf("confirm: %w", err)
+	}
+
+	// The third hold has passed but is still pending
+	fake.Advance(holdTTL + time.Second)
+	if _, err := svc.Book(ctx, appointment.BookingRequest{SlotID: slot.ID, PatientID: f.patient.ID, SlotCount: 1}); err != nil {
+		return fmt.Errorf("book after a hold passed: %w", err)
+	}
+	_, err = svc.Book(ctx, appointment.BookingRequest{SlotID: slot.ID, PatientID: f.patient.ID, SlotCount: 1})
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("book over capacity: %w", err)
+	}
+
+	n, err := b.CountTakenPlaces(ctx, slot.ID, fake.Now())
+	if err != nil {
+		return fmt.Errorf("CountTakenPlaces: %w", err)
+	}
+	if n != 2 {
+		return fmt.Errorf("expected 2 places taken, got %d", n)
+	}
+	return nil
+}
+
+// testHeldSlotSearch checks that slot search and the calendar count live
+// holds against capacity as booking does, and give the place back once the
+// hold has passed.
+func testHeldSlotSearch(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slot, err := f.addSlotWithCapacity(ctx, b, 48*time.Hour, 2)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := b.CreatePendingAppointment(ctx, slot.ID, f.patient.ID, now.Add(10*time.Minute)); err != nil {
+			return err
+		}
+	}
+
+	expect := func(at time.Time, remaining int, day appointment.DayAvailability) error {
+		found, err := b.SearchSlots(ctx, appointment.SlotQuery{
+			ClinicianID: &f.clinician.ID, Status: appointment.SlotOpen,
+			From: slot.StartTime.Add(-time.Hour), To: slot.StartTime.Add(time.Hour), Limit: 10,
+		}, at)
+		if err != nil {
+			return fmt.Errorf("SearchSlots: %w", err)
+		}
+		var got []appointment.OpenSlot
+		for _, o := range found {
+			if o.ID == slot.ID {
+				got = append(got, o)
+			}
+		}
+		if remaining == 0 && len(got) != 0 {
+			return fmt.Errorf("expected the held slot left out of the search, got %+v", got)
+		}
+		if remaining > 0 && (len(got) != 1 || got[0].Remaining != remaining) {
+			return fmt.Errorf("expected the slot with %d remaining, got %+v", remaining, got)
+		}
+
+		days := []appointment.DayRange{{Start: slot.StartTime.Add(-time.Minute), End: slot.StartTime.Add(time.Minute)}}
+		var unbounded *appointment.BookingWindow
+		counts, err := b.CountSlotsByDay(ctx, f.clinician.ID, days, unbounded.Bookable(at), at)
+		if err != nil {
+			return fmt.Errorf("CountSlotsByDay: %w", err)
+		}
+		if len(counts) != 1 || counts[0] != day {
+			return fmt.Errorf("expected %+v, got %+v", day, counts)
+		}
+		return nil
+	}
+
+	if err := expect(now, 0, appointment.DayAvailability{Slots: 1, Booked: 1}); err != nil {
+		return fmt.Errorf("while held: %w", err)
+	}
+	if err := expect(now.Add(time.Hour), 2, appointment.DayAvailability{Slots: 1, Open: 1}); err != nil {
+		return fmt.Errorf("after the holds passed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/clock"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+const (
+	checkInOpens  = 30 * time.Minute
+	checkInCloses = 15 * time.Minute
+)
+
+// testCheckIn checks in a confirmed appointment within the window around
+// its start: not before it opens or after it closes, not while pending and
+// not twice. A checked-in appointment keeps its place, shows when it was
+// checked in and can be completed but not marked a no-show.
+func testCheckIn(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	late, err := f.addSlot(ctx, b, 26*time.Hour)
+	if err != nil {
+		return err
+	}
+	fake := clock.NewFake(time.Now().Truncate(time.Millisecond))
+	cfg := config.Config{
+		AppointmentTTL: holdTTL,
+		LockTTL:        5 * time.Second,
+		CheckInOpens:   checkInOpens,
+		CheckInCloses:  checkInCloses,
+	}
+	svc := appointment.NewService(b, redisclient.NewInMemorySlotLocker(), cfg, appointment.WithClock(fake))
+
+	appt, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	_, err = svc.CheckInAppointment(ctx, appt.ID)
+	if err := expectErr(err, appointment.ErrInvalidStatusTransition); err != nil {
+		return fmt.Errorf("check in while pending: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, appt.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+	lateAppt, err := svc.CreateAppointment(ctx, late.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, lateAppt.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+
+	_, err = svc.CheckInAppointment(ctx, appt.ID)
+	if err := expectErr(err, appointment.ErrCheckInNotOpen); err != nil {
+		return fmt.Errorf("check in a day early: %w", err)
+	}
+
+	fake.Advance(f.slot.StartTime.Add(-checkInOpens / 3).Sub(fake.Now()))
+	checkIn, err := svc.CheckInAppointment(ctx, appt.ID)
+	if err != nil {
+		return fmt.Errorf("CheckInAppointment: %w", err)
+	}
+	if checkIn.Status != appointment.StatusCheckedIn || !checkIn.CheckedInAt.Equal(fake.Now()) {
+		return fmt.Errorf("expected checked_in at %s, got %s at %s", fake.Now(), checkIn.Status, checkIn.CheckedInAt)
+	}
+	_, err = svc.CheckInAppointment(ctx, appt.ID)
+	if err := expectErr(err, appointment.ErrInvalidStatusTransition); err != nil {
+		return fmt.Errorf("check in twice: %w", err)
+	}
+
+	detail, err := svc.GetAppointment(ctx, appt.ID, appointment.DetailFields{})
+	if err != nil {
+		return fmt.Errorf("GetAppointment: %w", err)
+	}
+	if detail.Status != appointment.StatusCheckedIn || detail.CheckedInAt == nil || !detail.CheckedInAt.Equal(checkIn.CheckedInAt) {
+		return fmt.Errorf("expected the detail to show the check-in, got %s at %v", detail.Status, detail.CheckedInAt)
+	}
+	n, err := b.CountTakenPlaces(ctx, f.slot.ID, fake.Now())
+	if err != nil {
+		return fmt.Errorf("CountTakenPlaces: %w", err)
+	}
+	if n != 1 {
+		return fmt.Errorf("expected the checked-in appointment to keep its place, got %d taken", n)
+	}
+	if confirmed, err := b.CountConfirmedAppointmentsForSlot(ctx, f.slot.ID); err != nil || confirmed != 1 {
+		return fmt.Errorf("expected the checked-in appointment counted as confirmed, got %d: %v", confirmed, err)
+	}
+	_, err = svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("book a slot whose patient checked in: %w", err)
+	}
+	events, err := countEvents(ctx, b, f.patient.ID, appointment.EventAppointmentCheckedIn)
+	if err != nil {
+		return err
+	}
+	if len(events) != 1 || events[appt.ID] != 1 {
+		return fmt.Errorf("expected one check-in event, got %v", events)
+	}
+
+	// After the visit a checked-in patient came, so it completes
+	fake.Advance(time.Hour)
+	_, err = svc.MarkNoShow(ctx, appt.ID, "", "")
+	if err := expectErr(err, appointment.ErrInvalidStatusTransition); err != nil {
+		return fmt.Errorf("no-show after checking in: %w", err)
+	}
+	if _, err := svc.CompleteAppointment(ctx, appt.ID, "", ""); err != nil {
+		return fmt.Errorf("CompleteAppointment after checking in: %w", err)
+	}
+
+	fake.Advance(late.StartTime.Add(checkInCloses + time.Minute).Sub(fake.Now()))
+	_, err = svc.CheckInAppointment(ctx, lateAppt.ID)
+	if err := expectErr(err, appointment.ErrCheckInClosed); err != nil {
+		return fmt.Errorf("check in after the window: %w", err)
+	}
+	return expectStatus(ctx, b, lateAppt.ID, appointment.StatusConfirmed)
+}
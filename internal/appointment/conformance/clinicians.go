@@ -0,0 +1,200 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testClinicianCRUD adds, reads, updates and deactivates a clinician
+// through the service, and checks a deactivated clinician's open slots can
+// no longer be found or booked
+func testClinicianCRUD(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+	blank := " "
+	unknownClinic := uuid.New()
+
+	for _, c := range []struct {
+		what string
+		nc   appointment.NewClinician
+		want error
+	}{
+		{"no name", appointment.NewClinician{Name: " "}, appointment.ErrInvalidClinician},
+		{"a blank specialty", appointment.NewClinician{Name: "Dr. Blank", Specialty: &blank}, appointment.ErrInvalidClinician},
+		{"an unknown clinic", appointment.NewClinician{Name: "Dr. Nowhere", ClinicID: &unknownClinic}, appointment.ErrClinicNotFound},
+	} {
+		_, err := svc.CreateClinician(ctx, c.nc)
+		if err := expectErr(err, c.want); err != nil {
+			return fmt.Errorf("CreateClinician with %s: %w", c.what, err)
+		}
+	}
+
+	specialty := " Dermatology "
+	c, err := svc.CreateClinician(ctx, appointment.NewClinician{Name: " Dr. Crud ", Specialty: &specialty, ClinicID: &f.clinic.ID})
+	if err != nil {
+		return fmt.Errorf("CreateClinician: %w", err)
+	}
+	if c.Name != "Dr. Crud" || c.Specialty == nil || *c.Specialty != "Dermatology" ||
+		c.ClinicID == nil || *c.ClinicID != f.clinic.ID || c.Deactivated() {
+		return fmt.Errorf("expected an active clinician with trimmed fields, got %+v", c)
+	}
+	got, err := svc.GetClinician(ctx, c.ID)
+	if err != nil {
+		return fmt.Errorf("GetClinician: %w", err)
+	}
+	if got.Name != c.Name || got.Specialty == nil || *got.Specialty != *c.Specialty {
+		return fmt.Errorf("expected the created clinician back, got %+v", got)
+	}
+
+	name := "Dr. Renamed"
+	_, err = svc.UpdateClinician(ctx, uuid.New(), appointment.ClinicianUpdate{Name: &name})
+	if err := expectErr(err, appointment.ErrClinicianNotFound); err != nil {
+		return fmt.Errorf("UpdateClinician of an unknown clinician: %w", err)
+	}
+	_, err = svc.UpdateClinician(ctx, c.ID, appointment.ClinicianUpdate{Specialty: &blank})
+	if err := expectErr(err, appointment.ErrInvalidClinician); err != nil {
+		return fmt.Errorf("UpdateClinician to a blank specialty: %w", err)
+	}
+	// Changing only the name keeps the specialty
+	renamed, err := svc.UpdateClinician(ctx, c.ID, appointment.ClinicianUpdate{Name: &name})
+	if err != nil {
+		return fmt.Errorf("UpdateClinician name: %w", err)
+	}
+	if renamed.Name != name || renamed.Specialty == nil || *renamed.Specialty != "Dermatology" {
+		return fmt.Errorf("expected only the name changed, got %+v", renamed)
+	}
+
+	// Book through the service once first, so the clinician is cached as
+	// active when they are deactivated
+	f.clinician = *renamed
+	booked, err := f.addSlot(ctx, b, 30*time.Hour)
+	if err != nil {
+		return err
+	}
+	if _, err := svc.CreateAppointment(ctx, booked.ID, f.patient.ID); err != nil {
+		return fmt.Errorf("CreateAppointment before deactivation: %w", err)
+	}
+	open, err := f.addSlot(ctx, b, 31*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	deactivated, err := svc.DeactivateClinician(ctx, c.ID)
+	if err != nil {
+		return fmt.Errorf("DeactivateClinician: %w", err)
+	}
+	if !deactivated.Deactivated() {
+		return fmt.Errorf("expected the clinician deactivated, got %+v", deactivated)
+	}
+	_, err = svc.DeactivateClinician(ctx, c.ID)
+	if err := expectErr(err, appointment.ErrClinicianDeactivated); err != nil {
+		return fmt.Errorf("DeactivateClinician twice: %w", err)
+	}
+
+	_, err = svc.CreateAppointment(ctx, open.ID, f.patient.ID)
+	if err := expectErr(err, appointment.ErrClinicianDeactivated); err != nil {
+		return fmt.Errorf("CreateAppointment after deactivation: %w", err)
+	}
+	res, err := svc.SearchSlots(ctx, appointment.SlotQuery{
+		ClinicianID: &c.ID,
+		From:        booked.StartTime.Add(-time.Hour),
+		To:          open.EndTime.Add(time.Hour),
+		Limit:       10,
+	})
+	if err != nil {
+		return fmt.Errorf("SearchSlots: %w", err)
+	}
+	if len(res.Slots) != 0 {
+		return fmt.Errorf("expected no open slots of a deactivated clinician, got %+v", res.Slots)
+	}
+	return nil
+}
+
+// testClinicianSchedule lists a clinician's appointments in a range and
+// checks they come earliest first with their patient, leaving out
+// cancelled ones and those of other clinicians or outside the range
+func testClinicianSchedule(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	_, err = svc.ClinicianSchedule(ctx, uuid.New(), time.Now(), time.Now().Add(time.Hour))
+	if err := expectErr(err, appointment.ErrClinicianNotFound); err != nil {
+		return fmt.Errorf("ClinicianSchedule of an unknown clinician: %w", err)
+	}
+	_, err = svc.ClinicianSchedule(ctx, f.clinician.ID, time.Now(), time.Now().Add(40*24*time.Hour))
+	if err := expectErr(err, appointment.ErrInvalidTimeRange); err != nil {
+		return fmt.Errorf("ClinicianSchedule over 40 days: %w", err)
+	}
+
+	// Booked in reverse, so the order comes from the slots
+	late, err := f.addSlot(ctx, b, 30*time.Hour)
+	if err != nil {
+		return err
+	}
+	early, err := f.addSlot(ctx, b, 26*time.Hour)
+	if err != nil {
+		return err
+	}
+	cancelled, err := f.addSlot(ctx, b, 28*time.Hour)
+	if err != nil {
+		return err
+	}
+	outside, err := f.addSlot(ctx, b, 60*time.Hour)
+	if err != nil {
+		return err
+	}
+	var booked []*appointment.Appointment
+	for _, slot := range []*appointment.AppointmentSlot{late, early, cancelled, outside} {
+		appt, err := svc.CreateAppointment(ctx, slot.ID, f.patient.ID)
+		if err != nil {
+			return fmt.Errorf("CreateAppointment: %w", err)
+		}
+		booked = append(booked, appt)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, booked[0].ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+	if _, err := svc.CancelAppointment(ctx, booked[2].ID, "conformance", nil); err != nil {
+		return fmt.Errorf("CancelAppointment: %w", err)
+	}
+
+	other := &fixture{clinician: appointment.Clinician{ID: uuid.New(), Name: "Dr. Elsewhere", ClinicID: &f.clinic.ID}}
+	if err := b.InsertClinician(ctx, other.clinician); err != nil {
+		return err
+	}
+	otherSlot, err := other.addSlot(ctx, b, 27*time.Hour)
+	if err != nil {
+		return err
+	}
+	if _, err := svc.CreateAppointment(ctx, otherSlot.ID, f.patient.ID); err != nil {
+		return fmt.Errorf("CreateAppointment with another clinician: %w", err)
+	}
+
+	got, err := svc.ClinicianSchedule(ctx, f.clinician.ID, early.StartTime, outside.StartTime)
+	if err != nil {
+		return fmt.Errorf("ClinicianSchedule: %w", err)
+	}
+	if len(got) != 2 || got[0].ID != booked[1].ID || got[1].ID != booked[0].ID {
+		return fmt.Errorf("expected %s then %s, got %+v", booked[1].ID, booked[0].ID, got)
+	}
+	for _, d := range got {
+		if d.Slot == nil || d.Slot.PractitionerID != f.clinician.ID || d.Patient == nil || d.Patient.ID != f.patient.ID {
+			return fmt.Errorf("expected the slot and patient of %s, got %+v", d.ID, d)
+		}
+	}
+	if got[1].Status != appointment.StatusConfirmed {
+		return fmt.Errorf("expected %s confirmed, got %s", got[1].ID, got[1].Status)
+	}
+	return nil
+}
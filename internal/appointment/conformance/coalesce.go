@@ -0,0 +1,86 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// coalescedReaders is how many identical reads testCoalescedReads runs at once
+const coalescedReaders = 20
+
+// testCoalescedReads polls one appointment and one clinician's availability
+// from many goroutines at once, as clients do: every reader gets the same
+// answer, and a read after a change through the service sees the change
+// rather than joining a read still in flight from before it
+func testCoalescedReads(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	appt, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+
+	// poll reads the appointment and the availability version from every
+	// reader and checks they agree
+	poll := func(step string) (*appointment.AppointmentDetail, error) {
+		details := make([]*appointment.AppointmentDetail, coalescedReaders)
+		versions := make([]int64, coalescedReaders)
+		errs := make([]error, coalescedReaders)
+		var wg sync.WaitGroup
+		for i := range coalescedReaders {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if details[i], errs[i] = svc.GetAppointment(ctx, appt.ID, appointment.AllDetailFields); errs[i] != nil {
+					return
+				}
+				versions[i], errs[i] = svc.GetAvailabilityVersion(ctx, f.clinician.ID)
+			}()
+		}
+		wg.Wait()
+		for i := range coalescedReaders {
+			if errs[i] != nil {
+				return nil, fmt.Errorf("read %s: %w", step, errs[i])
+			}
+			if details[i].Status != details[0].Status || details[i].Patient == nil || details[i].Slot == nil || versions[i] != versions[0] {
+				return nil, fmt.Errorf("read %s: readers disagree", step)
+			}
+			if i > 0 && details[i] == details[0] {
+				return nil, fmt.Errorf("read %s: readers share one detail", step)
+			}
+		}
+		return details[0], nil
+	}
+
+	detail, err := poll("while pending")
+	if err != nil {
+		return err
+	}
+	if detail.Status != appointment.StatusPending {
+		return fmt.Errorf("expected pending, got %s", detail.Status)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, appt.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+	detail, err = poll("after confirming")
+	if err != nil {
+		return err
+	}
+	if detail.Status != appointment.StatusConfirmed {
+		return fmt.Errorf("expected confirmed after confirming, got %s", detail.Status)
+	}
+
+	_, err = svc.GetAppointment(ctx, f.slot.ID, appointment.AllDetailFields)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("GetAppointment of an unknown id: %w", err)
+	}
+	return nil
+}
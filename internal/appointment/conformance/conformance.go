@@ -0,0 +1,1167 @@
+// Package conformance is the behavioural contract every appointment.Repository
+// backend must satisfy. It is run with cmd/repo-conformance against a scratch
+// database; each case creates its own rows, so it is safe to run repeatedly.
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// Backend is what the suite needs from a storage implementation
+type Backend interface {
+	appointment.Repository
+	appointment.Seeder
+}
+
+// Result is the outcome of a single case
+type Result struct {
+	Name string
+	Err  error
+}
+
+type testCase struct {
+	name string
+	run  func(ctx context.Context, b Backend) error
+}
+
+// Run executes every case against b and returns one Result per case
+func Run(ctx context.Context, b Backend) []Result {
+	results := make([]Result, 0, len(cases))
+	for _, c := range cases {
+		results = append(results, Result{Name: c.name, Err: c.run(ctx, b)})
+	}
+	return results
+}
+
+var cases = []testCase{
+	{"missing rows map to sentinel errors", testNotFound},
+	{"seeded rows round trip", testSeedRoundTrip},
+	{"pending appointment round trip", testPendingRoundTrip},
+	{"status update is compare-and-set", testStatusCAS},
+	{"pending resolution honours the deadline", testResolvePending},
+	{"one confirmed appointment per slot", testConfirmedUnique},
+	{"slot capacity bounds confirmed appointments", testSlotCapacity},
+	{"live holds take places in group slots", testGroupSlotHolds},
+	{"slot search and calendar count live holds", testHeldSlotSearch},
+	{"find expired pending", testFindExpired},
+	{"event insert", testInsertEvent},
+	{"patient timeline merges appointment events", testPatientTimeline},
+	{"appointment detail joins", testDetail},
+	{"appointment details batch read", testDetailBatch},
+	{"appointment detail field selection", testDetailFields},
+	{"clinic appointments stream", testStreamByClinic},
+	{"list by patient pages with tokens", testListByPatientPaging},
+	{"list by patient rejects bad token", testBadPageToken},
+	{"list by slot", testListBySlot},
+	{"transaction rolls back on error", testTxRollback},
+	{"transaction commits", testTxCommit},
+	{"slot quote", testSlotQuote},
+	{"booking intent journal", testBookingIntents},
+	{"availability version bumps", testAvailabilityVersion},
+	{"slot counts by day", testSlotsByDay},
+	{"hold expires just after its TTL", testHoldExpiresAtTTL},
+	{"confirm at the expiry boundary", testConfirmAtBoundary},
+	{"confirm and expiry race converges", testConfirmExpiryRace},
+	{"confirmed appointment survives its hold deadline", testConfirmedSurvivesDeadline},
+	{"expiry events match appointment state", testExpiryEventReport},
+	{"orphaned appointments are found and repaired", testOrphanedAppointments},
+	{"expiry backlog counts overdue holds", testExpiryBacklog},
+	{"approval resolution honours the deadline", testResolveApproval},
+	{"approval workflow confirms, rejects and times out", testApprovalWorkflow},
+	{"series occurrences round trip", testSeriesRoundTrip},
+	{"series books and changes all occurrences or none", testSeriesWorkflow},
+	{"multi-slot appointments count against every slot", testMultiSlotCapacity},
+	{"multi-slot appointments book back-to-back slots or none", testMultiSlotWorkflow},
+	{"staff resources are reserved per time range", testStaffResourceReservation},
+	{"staff resources are booked alongside the slot", testResourceBookingWorkflow},
+	{"booking windows round trip", testBookingWindowRoundTrip},
+	{"booking windows limit how far ahead slots are booked", testBookingWindowWorkflow},
+	{"attachments round trip", testAttachmentRoundTrip},
+	{"attachments are checked, scanned and stored", testAttachmentWorkflow},
+	{"intake forms round trip", testIntakeRoundTrip},
+	{"intake answers are checked and merged until complete", testIntakeWorkflow},
+	{"incomplete intake forms are reminded once", testIntakeReminders},
+	{"feedback round trips and aggregates by clinician", testFeedbackRoundTrip},
+	{"feedback is requested and taken once the visit has ended", testFeedbackWorkflow},
+	{"reasons and notes come back with the appointment", testAppointmentNotes},
+	{"attendance is recorded once the visit has ended", testAttendance},
+	{"check-in is taken within its window and keeps the place", testCheckIn},
+	{"retention policies round trip and are owned by one tenant", testRetentionPolicyRoundTrip},
+	{"retention deletes expired appointments with their records", testRetentionWorkflow},
+	{"pii access records round trip and filter by patient and actor", testPIIAccessRoundTrip},
+	{"pii access is recorded once per patient shown", testPIIAccessRecording},
+	{"open slot search pages and respects booking windows", testOpenSlotSearch},
+	{"guest bookings match patients by email", testGuestBooking},
+	{"patients are registered and updated with unique emails", testPatientCRUD},
+	{"patients are found by name or email, a page at a time", testPatientSearch},
+	{"clinicians are added, updated and deactivated", testClinicianCRUD},
+	{"a clinician's schedule lists their appointments in order", testClinicianSchedule},
+	{"slot search filters by clinician, specialty and status", testSlotSearch},
+	{"patient conflicts cover every slot of active appointments", testPatientConflicts},
+	{"booking precheck fails as booking would and holds nothing", testBookingPrecheck},
+	{"appointment search combines filters and pages", testAppointmentSearch},
+	{"appointment lists sort by start time and status", testListSort},
+	{"patient appointments are cancelled together by status", testCancelPatientAppointments},
+	{"deactivated patients cannot book and drop out of search", testPatientDeactivation},
+	{"cached patient reads follow updates", testLookupCache},
+	{"booking attempts over a clinic's rate are queued", testBookingQueue},
+	{"identical concurrent reads agree and follow updates", testCoalescedReads},
+	{"slots move to a covering clinician with their appointments", testSlotReassignment},
+	{"slots of a clinician never overlap", testSlotOverlap},
+	{"slots are split and merged around their appointments", testSlotSplitMerge},
+	{"sync returns settled changes in scope by cursor", testSync},
+	{"push devices are registered once per token", testDevices},
+	{"appointments move to another slot in one step", testReschedule},
+	{"holds move to another slot keeping their deadline", testHoldTransfer},
+	{"sms replies confirm or cancel the next pending appointment once", testSMSReplies},
+	{"ivr api keys reach only the appointments and actions they are scoped to", testIVR},
+	{"availability templates generate slots around existing ones", testSlotGeneration},
+}
+
+// fixture is a clinic with one clinician, patient and open future slot
+type fixture struct {
+	clinic    appointment.Clinic
+	clinician appointment.Clinician
+	patient   appointment.Patient
+	slot      appointment.AppointmentSlot
+}
+
+func newFixture(ctx context.Context, b Backend) (*fixture, error) {
+	f := &fixture{}
+
+	f.clinic = appointment.Clinic{ID: uuid.New(), Name: "Conformance Clinic"}
+	if err := b.InsertClinic(ctx, f.clinic); err != nil {
+		return nil, err
+	}
+
+	specialty := "General Practice"
+	f.clinician = appointment.Clinician{
+		ID:        uuid.New(),
+		Name:      "Dr. Conformance",
+		Specialty: &specialty,
+		ClinicID:  &f.clinic.ID,
+	}
+	if err := b.InsertClinician(ctx, f.clinician); err != nil {
+		return nil, err
+	}
+
+	email := "conformance+" + uuid.NewString() + "@example.com"
+	f.patient = appointment.Patient{ID: uuid.New(), Name: "Pat Conformance", Email: &email}
+	if err := b.InsertPatient(ctx, f.patient); err != nil {
+		return nil, err
+	}
+
+	slot, err := f.addSlot(ctx, b, 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	f.slot = *slot
+
+	return f, nil
+}
+
+// addSlot adds a 30 minute open slot for the fixture clinician starting in d
+func (f *fixture) addSlot(ctx context.Context, b Backend, d time.Duration) (*appointment.AppointmentSlot, error) {
+	return f.addSlotWithCapacity(ctx, b, d, 1)
+}
+
+func (f *fixture) addSlotWithCapacity(ctx context.Context, b Backend, d time.Duration, capacity int) (*appointment.AppointmentSlot, error) {
+	return f.insertSlot(ctx, b, d, capacity, appointment.SlotOpen)
+}
+
+func (f *fixture) insertSlot(ctx context.Context, b Backend, d time.Duration, capacity int, status appointment.SlotStatus) (*appointment.AppointmentSlot, error) {
+	return f.insertSlotAt(ctx, b, time.Now().Add(d).Truncate(time.Minute), capacity, status)
+}
+
+func (f *fixture) insertSlotAt(ctx context.Context, b Backend, start time.Time, capacity int, status appointment.SlotStatus) (*appointment.AppointmentSlot, error) {
+	start = start.UTC()
+	slotType := "consultation"
+	s := appointment.AppointmentSlot{
+		ID:             uuid.New(),
+		PractitionerID: f.clinician.ID,
+		StartTime:      start,
+		EndTime:        start.Add(30 * time.Minute),
+		Status:         status,
+		Capacity:       capacity,
+		SlotType:       &slotType,
+	}
+	if err := b.InsertSlot(ctx, s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// nextSlot moves the fixture slot an hour later, keeping its slot type
+func (f *fixture) nextSlot(ctx context.Context, b Backend) error {
+	f.slot.ID = uuid.New()
+	f.slot.StartTime = f.slot.StartTime.Add(time.Hour)
+	f.slot.EndTime = f.slot.EndTime.Add(time.Hour)
+	return b.InsertSlot(ctx, f.slot)
+}
+
+func (f *fixture) book(ctx context.Context, b appointment.Repository) (*appointment.Appointment, error) {
+	return b.CreatePendingAppointment(ctx, f.slot.ID, f.patient.ID, time.Now().Add(10*time.Minute))
+}
+
+func expectErr(got, want error) error {
+	if !errors.Is(got, want) {
+		return fmt.Errorf("expected %v, got %v", want, got)
+	}
+	return nil
+}
+
+func testNotFound(ctx context.Context, b Backend) error {
+	missing := uuid.New()
+
+	if _, err := b.GetPatientByID(ctx, missing); expectErr(err, appointment.ErrPatientNotFound) != nil {
+		return fmt.Errorf("GetPatientByID: %w", expectErr(err, appointment.ErrPatientNotFound))
+	}
+	if _, err := b.GetClinicianByID(ctx, missing); expectErr(err, appointment.ErrClinicianNotFound) != nil {
+		return fmt.Errorf("GetClinicianByID: %w", expectErr(err, appointment.ErrClinicianNotFound))
+	}
+	if _, err := b.GetSlotByID(ctx, missing); expectErr(err, appointment.ErrSlotNotFound) != nil {
+		return fmt.Errorf("GetSlotByID: %w", expectErr(err, appointment.ErrSlotNotFound))
+	}
+	if _, err := b.GetAppointmentByID(ctx, missing); expectErr(err, appointment.ErrAppointmentNotFound) != nil {
+		return fmt.Errorf("GetAppointmentByID: %w", expectErr(err, appointment.ErrAppointmentNotFound))
+	}
+	if _, err := b.GetAppointmentDetail(ctx, missing, appointment.AllDetailFields); expectErr(err, appointment.ErrAppointmentNotFound) != nil {
+		return fmt.Errorf("GetAppointmentDetail: %w", expectErr(err, appointment.ErrAppointmentNotFound))
+	}
+	if blockers, err := b.HasBlockingAppointment(ctx, missing); err != nil || blockers != (appointment.SlotBlockers{}) {
+		return fmt.Errorf("HasBlockingAppointment: expected nothing blocking, got %+v, %v", blockers, err)
+	}
+	return nil
+}
+
+func testSeedRoundTrip(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	p, err := b.GetPatientByID(ctx, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("GetPatientByID: %w", err)
+	}
+	if p.Name != f.patient.Name || p.Email == nil || *p.Email != *f.patient.Email {
+		return fmt.Errorf("patient mismatch: %+v", p)
+	}
+
+	c, err := b.GetClinicianByID(ctx, f.clinician.ID)
+	if err != nil {
+		return fmt.Errorf("GetClinicianByID: %w", err)
+	}
+	if c.ClinicID == nil || *c.ClinicID != f.clinic.ID || c.Specialty == nil {
+		return fmt.Errorf("clinician mismatch: %+v", c)
+	}
+
+	s, err := b.GetSlotByID(ctx, f.slot.ID)
+	if err != nil {
+		return fmt.Errorf("GetSlotByID: %w", err)
+	}
+	if !s.StartTime.Equal(f.slot.StartTime) || !s.EndTime.Equal(f.slot.EndTime) {
+		return fmt.Errorf("slot times mismatch: got %s-%s want %s-%s", s.StartTime, s.EndTime, f.slot.StartTime, f.slot.EndTime)
+	}
+	if s.Status != appointment.SlotOpen || s.Capacity != 1 || s.SlotType == nil || *s.SlotType != *f.slot.SlotType {
+		return fmt.Errorf("slot mismatch: %+v", s)
+	}
+	return nil
+}
+
+func testPendingRoundTrip(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	created, err := f.book(ctx, b)
+	if err != nil {
+		return fmt.Errorf("CreatePendingAppointment: %w", err)
+	}
+	if created.Status != appointment.StatusPending || created.ExpiresAt == nil {
+		return fmt.Errorf("unexpected created appointment: %+v", created)
+	}
+
+	got, err := b.GetAppointmentByID(ctx, created.ID)
+	if err != nil {
+		return fmt.Errorf("GetAppointmentByID: %w", err)
+	}
+	if got.SlotID != f.slot.ID || got.PatientID != f.patient.ID || got.Status != appointment.StatusPending {
+		return fmt.Errorf("appointment mismatch: %+v", got)
+	}
+	if got.ExpiresAt == nil || !got.ExpiresAt.Truncate(time.Millisecond).Equal(created.ExpiresAt.Truncate(time.Millisecond)) {
+		return fmt.Errorf("expires_at mismatch: %v vs %v", got.ExpiresAt, created.ExpiresAt)
+	}
+	return nil
+}
+
+func testStatusCAS(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	// Wrong "from" status must not match
+	_, err = b.UpdateAppointmentStatus(ctx, appt.ID, appointment.StatusConfirmed, appointment.StatusExpired)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("CAS with stale from: %w", err)
+	}
+
+	updated, err := b.UpdateAppointmentStatus(ctx, appt.ID, appointment.StatusPending, appointment.StatusConfirmed)
+	if err != nil {
+		return fmt.Errorf("CAS pending->confirmed: %w", err)
+	}
+	if updated.Status != appointment.StatusConfirmed {
+		return fmt.Errorf("status not updated: %s", updated.Status)
+	}
+
+	// The same transition a second time must lose
+	_, err = b.UpdateAppointmentStatus(ctx, appt.ID, appointment.StatusPending, appointment.StatusExpired)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("second CAS: %w", err)
+	}
+	return nil
+}
+
+func testResolvePending(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(10 * time.Minute).Truncate(time.Millisecond)
+	appt, err := b.CreatePendingAppointment(ctx, f.slot.ID, f.patient.ID, deadline)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.ResolvePendingAppointment(ctx, appt.ID, appointment.StatusConfirmed, deadline.Add(time.Millisecond))
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("confirm after deadline: %w", err)
+	}
+	_, err = b.ResolvePendingAppointment(ctx, appt.ID, appointment.StatusExpired, deadline)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("expire at deadline: %w", err)
+	}
+
+	confirmed, err := b.ResolvePendingAppointment(ctx, appt.ID, appointment.StatusConfirmed, deadline)
+	if err != nil {
+		return fmt.Errorf("confirm at deadline: %w", err)
+	}
+	if confirmed.Status != appointment.StatusConfirmed {
+		return fmt.Errorf("expected confirmed, got %s", confirmed.Status)
+	}
+
+	// Once resolved it cannot be resolved again either way
+	_, err = b.ResolvePendingAppointment(ctx, appt.ID, appointment.StatusExpired, deadline.Add(time.Hour))
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("expire confirmed: %w", err)
+	}
+
+	_, err = b.ResolvePendingAppointment(ctx, appt.ID, appointment.StatusCancelled, deadline)
+	if err := expectErr(err, appointment.ErrInvalidStatusTransition); err != nil {
+		return fmt.Errorf("resolve to cancelled: %w", err)
+	}
+	return nil
+}
+
+func testConfirmedUnique(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	first, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+	second, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.UpdateAppointmentStatus(ctx, first.ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("confirm first: %w", err)
+	}
+	_, err = b.UpdateAppointmentStatus(ctx, second.ID, appointment.StatusPending, appointment.StatusConfirmed)
+	if err == nil {
+		return errors.New("second confirmed appointment on the same slot was accepted")
+	}
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("confirm second: %w", err)
+	}
+
+	// The second stays pending, holding the slot too
+	blockers, err := b.HasBlockingAppointment(ctx, f.slot.ID)
+	if err != nil {
+		return fmt.Errorf("HasBlockingAppointment: %w", err)
+	}
+	if blockers != (appointment.SlotBlockers{Confirmed: true, Held: true}) {
+		return fmt.Errorf("expected the slot confirmed and held, got %+v", blockers)
+	}
+	return nil
+}
+
+func testSlotCapacity(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slot, err := f.addSlotWithCapacity(ctx, b, 48*time.Hour, 2)
+	if err != nil {
+		return err
+	}
+
+	appts := make([]*appointment.Appointment, 3)
+	for i := range appts {
+		appts[i], err = b.CreatePendingAppointment(ctx, slot.ID, f.patient.ID, time.Now().Add(10*time.Minute))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, a := range appts[:2] {
+		if _, err := b.UpdateAppointmentStatus(ctx, a.ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+			return fmt.Errorf("confirm within capacity: %w", err)
+		}
+	}
+	_, err = b.UpdateAppointmentStatus(ctx, appts[2].ID, appointment.StatusPending, appointment.StatusConfirmed)
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("confirm over capacity: %w", err)
+	}
+
+	n, err := b.CountConfirmedAppointmentsForSlot(ctx, slot.ID)
+	if err != nil {
+		return fmt.Errorf("CountConfirmedAppointmentsForSlot: %w", err)
+	}
+	if n != 2 {
+		return fmt.Errorf("expected 2 confirmed appointments, got %d", n)
+	}
+
+	// Cancelling a confirmed appointment frees its place
+	if _, err := b.UpdateAppointmentStatus(ctx, appts[0].ID, appointment.StatusConfirmed, appointment.StatusCancelled); err != nil {
+		return fmt.Errorf("cancel confirmed: %w", err)
+	}
+	if _, err := b.UpdateAppointmentStatus(ctx, appts[2].ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("confirm after cancel: %w", err)
+	}
+	return nil
+}
+
+func testFindExpired(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	appt, err := b.CreatePendingAppointment(ctx, f.slot.ID, f.patient.ID, time.Now().Add(time.Minute))
+	if err != nil {
+		return err
+	}
+
+	contains := func(list []appointment.Appointment) bool {
+		for _, a := range list {
+			if a.ID == appt.ID {
+				return true
+			}
+		}
+		return false
+	}
+
+	before, err := b.FindExpiredPending(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("FindExpiredPending(now): %w", err)
+	}
+	if contains(before) {
+		return errors.New("unexpired appointment reported as expired")
+	}
+
+	after, err := b.FindExpiredPending(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		return fmt.Errorf("FindExpiredPending(now+1h): %w", err)
+	}
+	if !contains(after) {
+		return errors.New("expired pending appointment not reported")
+	}
+
+	if _, err := b.UpdateAppointmentStatus(ctx, appt.ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return err
+	}
+	confirmed, err := b.FindExpiredPending(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		return err
+	}
+	if contains(confirmed) {
+		return errors.New("confirmed appointment reported as expired pending")
+	}
+	return nil
+}
+
+func testInsertEvent(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	if err := b.InsertEvent(ctx, appointment.EventLog{
+		EventType:     appointment.EventAppointmentCreated,
+		AppointmentID: &appt.ID,
+		Payload:       []byte(`{"source":"conformance"}`),
+	}); err != nil {
+		return fmt.Errorf("InsertEvent: %w", err)
+	}
+	return nil
+}
+
+// testPatientTimeline interleaves the events of two appointments of one
+// patient and checks they come back newest first with their slots
+func testPatientTimeline(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	first, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+	slot2, err := f.addSlot(ctx, b, 48*time.Hour)
+	if err != nil {
+		return err
+	}
+	second, err := b.CreatePendingAppointment(ctx, slot2.ID, f.patient.ID, time.Now().Add(10*time.Minute))
+	if err != nil {
+		return err
+	}
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	events := []struct {
+		appt      uuid.UUID
+		eventType string
+	}{
+		{first.ID, appointment.EventAppointmentCreated},
+		{second.ID, appointment.EventAppointmentCreated},
+		{first.ID, appointment.EventAppointmentConfirmed},
+		{second.ID, appointment.EventAppointmentCancelled},
+	}
+	for i, e := range events {
+		id := e.appt
+		if err := b.InsertEvent(ctx, appointment.EventLog{
+			EventType:     e.eventType,
+			AppointmentID: &id,
+			Payload:       []byte(`{"source":"conformance"}`),
+			CreatedAt:     base.Add(time.Duration(i) * time.Minute),
+		}); err != nil {
+			return fmt.Errorf("InsertEvent: %w", err)
+		}
+	}
+
+	entries, err := b.ListPatientTimeline(ctx, f.patient.ID, 10)
+	if err != nil {
+		return fmt.Errorf("ListPatientTimeline: %w", err)
+	}
+	if len(entries) != len(events) {
+		return fmt.Errorf("expected %d entries, got %d", len(events), len(entries))
+	}
+	for i, got := range entries {
+		want := events[len(events)-1-i]
+		if got.AppointmentID != want.appt || got.EventType != want.eventType {
+			return fmt.Errorf("entry %d: expected %s for %s, got %s for %s",
+				i, want.eventType, want.appt, got.EventType, got.AppointmentID)
+		}
+		if !got.CreatedAt.Equal(base.Add(time.Duration(len(events)-1-i) * time.Minute)) {
+			return fmt.Errorf("entry %d: created_at %v", i, got.CreatedAt)
+		}
+	}
+	if entries[0].SlotID != slot2.ID || !entries[0].SlotStart.Equal(slot2.StartTime) || !entries[0].SlotEnd.Equal(slot2.EndTime) {
+		return errors.New("slot not joined")
+	}
+	if entries[0].AppointmentStatus != appointment.StatusPending {
+		return fmt.Errorf("expected current status pending, got %s", entries[0].AppointmentStatus)
+	}
+
+	limited, err := b.ListPatientTimeline(ctx, f.patient.ID, 2)
+	if err != nil {
+		return fmt.Errorf("ListPatientTimeline: %w", err)
+	}
+	if len(limited) != 2 || limited[1].EventType != appointment.EventAppointmentConfirmed {
+		return errors.New("limit did not keep the newest events")
+	}
+	return nil
+}
+
+func testDetail(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	if err := b.SetSlotTypePrice(ctx, f.clinic.ID, *f.slot.SlotType, appointment.Price{AmountMinor: 4500, Currency: "EUR"}); err != nil {
+		return err
+	}
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	d, err := b.GetAppointmentDetail(ctx, appt.ID, appointment.AllDetailFields)
+	if err != nil {
+		return fmt.Errorf("GetAppointmentDetail: %w", err)
+	}
+	if d.Slot == nil || d.Slot.ID != f.slot.ID {
+		return errors.New("slot not joined")
+	}
+	if d.Patient == nil || d.Patient.ID != f.patient.ID {
+		return errors.New("patient not joined")
+	}
+	if d.Clinician == nil || d.Clinician.ID != f.clinician.ID {
+		return errors.New("clinician not joined")
+	}
+	if d.Price == nil || d.Price.AmountMinor != 4500 || d.Price.Currency != "EUR" {
+		return fmt.Errorf("price not joined: %+v", d.Price)
+	}
+	return nil
+}
+
+func testDetailFields(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	if err := b.SetSlotTypePrice(ctx, f.clinic.ID, *f.slot.SlotType, appointment.Price{AmountMinor: 4500, Currency: "EUR"}); err != nil {
+		return err
+	}
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	check := func(fields appointment.DetailFields, d *appointment.AppointmentDetail) error {
+		if d.ID != appt.ID || d.SlotID != f.slot.ID || d.PatientID != f.patient.ID || d.Status != appt.Status {
+			return fmt.Errorf("%+v: appointment columns not read", fields)
+		}
+		if (d.Slot != nil) != fields.Slot || (d.Price != nil) != fields.Slot {
+			return fmt.Errorf("%+v: slot or price hydrated wrongly", fields)
+		}
+		if (d.Patient != nil) != fields.Patient {
+			return fmt.Errorf("%+v: patient hydrated wrongly", fields)
+		}
+		if (d.Clinician != nil) != fields.Clinician {
+			return fmt.Errorf("%+v: clinician hydrated wrongly", fields)
+		}
+		if fields.Clinician && d.Clinician.ID != f.clinician.ID {
+			return fmt.Errorf("%+v: wrong clinician", fields)
+		}
+		return nil
+	}
+
+	for _, fields := range []appointment.DetailFields{
+		{},
+		{Slot: true},
+		{Patient: true},
+		{Clinician: true},
+		{Slot: true, Patient: true},
+	} {
+		d, err := b.GetAppointmentDetail(ctx, appt.ID, fields)
+		if err != nil {
+			return fmt.Errorf("GetAppointmentDetail %+v: %w", fields, err)
+		}
+		if err := check(fields, d); err != nil {
+			return err
+		}
+
+		list, err := b.ListAppointmentsBySlot(ctx, f.slot.ID, appointment.ListSort{}, fields)
+		if err != nil {
+			return fmt.Errorf("ListAppointmentsBySlot %+v: %w", fields, err)
+		}
+		if len(list) != 1 {
+			return fmt.Errorf("ListAppointmentsBySlot %+v: expected 1 row, got %d", fields, len(list))
+		}
+		if err := check(fields, &list[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func testDetailBatch(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	first, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+	slot, err := f.addSlot(ctx, b, 48*time.Hour)
+	if err != nil {
+		return err
+	}
+	second, err := b.CreatePendingAppointment(ctx, slot.ID, f.patient.ID, time.Now().Add(10*time.Minute))
+	if err != nil {
+		return err
+	}
+
+	details, err := b.GetAppointmentDetails(ctx, []uuid.UUID{second.ID, uuid.New(), first.ID}, appointment.AllDetailFields)
+	if err != nil {
+		return fmt.Errorf("GetAppointmentDetails: %w", err)
+	}
+	if len(details) != 2 {
+		return fmt.Errorf("expected 2 details, got %d", len(details))
+	}
+	wantSlot := map[uuid.UUID]uuid.UUID{first.ID: f.slot.ID, second.ID: slot.ID}
+	for _, d := range details {
+		slotID, ok := wantSlot[d.ID]
+		if !ok {
+			return fmt.Errorf("unexpected appointment %s", d.ID)
+		}
+		delete(wantSlot, d.ID)
+		if d.Slot == nil || d.Slot.ID != slotID {
+			return fmt.Errorf("slot not joined for %s", d.ID)
+		}
+		if d.Patient == nil || d.Patient.ID != f.patient.ID || d.Clinician == nil || d.Clinician.ID != f.clinician.ID {
+			return fmt.Errorf("patient or clinician not joined for %s", d.ID)
+		}
+	}
+
+	none, err := b.GetAppointmentDetails(ctx, []uuid.UUID{uuid.New()}, appointment.AllDetailFields)
+	if err != nil {
+		return fmt.Errorf("GetAppointmentDetails unknown: %w", err)
+	}
+	if len(none) != 0 {
+		return fmt.Errorf("expected no details for an unknown id, got %d", len(none))
+	}
+	return nil
+}
+
+func testStreamByClinic(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	first, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+	later, err := f.addSlot(ctx, b, 72*time.Hour)
+	if err != nil {
+		return err
+	}
+	second, err := b.CreatePendingAppointment(ctx, later.ID, f.patient.ID, time.Now().Add(10*time.Minute))
+	if err != nil {
+		return err
+	}
+
+	stream := func(from, to time.Time, fields appointment.DetailFields) ([]uuid.UUID, error) {
+		var ids []uuid.UUID
+		err := b.StreamAppointmentsByClinic(ctx, f.clinic.ID, from, to, fields, func(d *appointment.AppointmentDetail) error {
+			if fields.Clinician && (d.Clinician == nil || d.Clinician.ID != f.clinician.ID) {
+				return fmt.Errorf("clinician not joined for %s", d.ID)
+			}
+			ids = append(ids, d.ID)
+			return nil
+		})
+		return ids, err
+	}
+
+	all, err := stream(f.slot.StartTime, later.StartTime.Add(time.Minute), appointment.AllDetailFields)
+	if err != nil {
+		return fmt.Errorf("StreamAppointmentsByClinic: %w", err)
+	}
+	if len(all) != 2 || all[0] != first.ID || all[1] != second.ID {
+		return fmt.Errorf("expected [%s %s], got %v", first.ID, second.ID, all)
+	}
+
+	// to is exclusive
+	lean, err := stream(f.slot.StartTime, later.StartTime, appointment.DetailFields{})
+	if err != nil {
+		return fmt.Errorf("StreamAppointmentsByClinic lean: %w", err)
+	}
+	if len(lean) != 1 || lean[0] != first.ID {
+		return fmt.Errorf("expected [%s], got %v", first.ID, lean)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = b.StreamAppointmentsByClinic(ctx, f.clinic.ID, f.slot.StartTime, later.StartTime.Add(time.Minute), appointment.DetailFields{}, func(*appointment.AppointmentDetail) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		return fmt.Errorf("expected the callback error after 1 call, got %v after %d", err, calls)
+	}
+	return nil
+}
+
+func testSlotsByDay(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	booked, err := f.addSlot(ctx, b, 48*time.Hour)
+	if err != nil {
+		return err
+	}
+	appt, err := b.CreatePendingAppointment(ctx, booked.ID, f.patient.ID, time.Now().Add(10*time.Minute))
+	if err != nil {
+		return err
+	}
+	if _, err := b.UpdateAppointmentStatus(ctx, appt.ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("confirm: %w", err)
+	}
+
+	around := func(t time.Time) appointment.DayRange {
+		return appointment.DayRange{Start: t.Add(-time.Hour), End: t.Add(time.Hour)}
+	}
+	days := []appointment.DayRange{
+		around(f.slot.StartTime),
+		around(booked.StartTime),
+		around(booked.StartTime.Add(24 * time.Hour)),
+		// Ends where the open slot starts, which is outside the day
+		{Start: f.slot.StartTime.Add(-time.Hour), End: f.slot.StartTime},
+	}
+	expect := func(bookable appointment.DayRange, want ...appointment.DayAvailability) error {
+		counts, err := b.CountSlotsByDay(ctx, f.clinician.ID, days, bookable, time.Now())
+		if err != nil {
+			return fmt.Errorf("CountSlotsByDay: %w", err)
+		}
+		if len(counts) != len(want) {
+			return fmt.Errorf("expected %d days, got %d", len(want), len(counts))
+		}
+		for i := range want {
+			if counts[i] != want[i] {
+				return fmt.Errorf("day %d: expected %+v, got %+v", i, want[i], counts[i])
+			}
+		}
+		return nil
+	}
+
+	var unbounded *appointment.BookingWindow
+	if err := expect(unbounded.Bookable(time.Now()),
+		appointment.DayAvailability{Slots: 1, Open: 1},
+		appointment.DayAvailability{Slots: 1, Booked: 1},
+		appointment.DayAvailability{},
+		appointment.DayAvailability{},
+	); err != nil {
+		return err
+	}
+	// The open slot starts before the booking window and is not open
+	bookable := appointment.DayRange{Start: f.slot.StartTime.Add(time.Minute), End: booked.StartTime.Add(week)}
+	if err := expect(bookable,
+		appointment.DayAvailability{Slots: 1},
+		appointment.DayAvailability{Slots: 1, Booked: 1},
+		appointment.DayAvailability{},
+		appointment.DayAvailability{},
+	); err != nil {
+		return fmt.Errorf("outside the booking window: %w", err)
+	}
+	return nil
+}
+
+func testListByPatientPaging(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	const total = 5
+	want := make(map[uuid.UUID]bool)
+	for i := 0; i < total; i++ {
+		slot, err := f.addSlot(ctx, b, time.Duration(48+i)*time.Hour)
+		if err != nil {
+			return err
+		}
+		appt, err := b.CreatePendingAppointment(ctx, slot.ID, f.patient.ID, time.Now().Add(10*time.Minute))
+		if err != nil {
+			return err
+		}
+		want[appt.ID] = true
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	page := appointment.PageRequest{Limit: 2}
+	var last *appointment.AppointmentDetail
+	for pages := 0; ; pages++ {
+		if pages > total {
+			return errors.New("paging did not terminate")
+		}
+
+		res, err := b.ListAppointmentsByPatient(ctx, f.patient.ID, page, appointment.AllDetailFields)
+		if err != nil {
+			return fmt.Errorf("ListAppointmentsByPatient: %w", err)
+		}
+		if len(res.Appointments) > page.Limit {
+			return fmt.Errorf("page has %d rows, limit %d", len(res.Appointments), page.Limit)
+		}
+
+		for i := range res.Appointments {
+			a := res.Appointments[i]
+			if seen[a.ID] {
+				return fmt.Errorf("appointment %s returned twice", a.ID)
+			}
+			if last != nil && a.CreatedAt.After(last.CreatedAt) {
+				return errors.New("results not ordered by created_at descending")
+			}
+			seen[a.ID] = true
+			last = &a
+		}
+
+		if res.NextToken == "" {
+			break
+		}
+		page.Token = res.NextToken
+	}
+
+	if len(seen) != len(want) {
+		return fmt.Errorf("paged through %d appointments, want %d", len(seen), len(want))
+	}
+
+	offsetPage, err := b.ListAppointmentsByPatient(ctx, f.patient.ID, appointment.PageRequest{Limit: 10, Offset: 3}, appointment.AllDetailFields)
+	if err != nil {
+		return fmt.Errorf("offset page: %w", err)
+	}
+	if len(offsetPage.Appointments) != total-3 || offsetPage.NextToken != "" {
+		return fmt.Errorf("offset page returned %d rows (next=%q), want %d", len(offsetPage.Appointments), offsetPage.NextToken, total-3)
+	}
+	return nil
+}
+
+func testBadPageToken(ctx context.Context, b Backend) error {
+	_, err := b.ListAppointmentsByPatient(ctx, uuid.New(), appointment.PageRequest{Limit: 10, Token: "not-a-token"}, appointment.AllDetailFields)
+	return expectErr(err, appointment.ErrInvalidPageToken)
+}
+
+func testListBySlot(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := f.book(ctx, b); err != nil {
+			return err
+		}
+	}
+
+	list, err := b.ListAppointmentsBySlot(ctx, f.slot.ID, appointment.ListSort{}, appointment.AllDetailFields)
+	if err != nil {
+		return fmt.Errorf("ListAppointmentsBySlot: %w", err)
+	}
+	if len(list) != 2 {
+		return fmt.Errorf("got %d appointments, want 2", len(list))
+	}
+	return nil
+}
+
+func testTxRollback(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	sentinel := errors.New("abort")
+	var createdID uuid.UUID
+	err = b.WithTx(ctx, func(tx appointment.Repository) error {
+		appt, err := f.book(ctx, tx)
+		if err != nil {
+			return err
+		}
+		createdID = appt.ID
+		return sentinel
+	})
+	if !errors.Is(err, sentinel) {
+		return fmt.Errorf("WithTx returned %v, want the callback error", err)
+	}
+
+	if _, err := b.GetAppointmentByID(ctx, createdID); expectErr(err, appointment.ErrAppointmentNotFound) != nil {
+		return errors.New("appointment created in a rolled back transaction is visible")
+	}
+	return nil
+}
+
+func testTxCommit(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	var createdID uuid.UUID
+	err = b.WithTx(ctx, func(tx appointment.Repository) error {
+		appt, err := f.book(ctx, tx)
+		if err != nil {
+			return err
+		}
+		createdID = appt.ID
+
+		// Reads inside the transaction see its own writes
+		_, err = tx.GetAppointmentByID(ctx, appt.ID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("WithTx: %w", err)
+	}
+
+	if _, err := b.GetAppointmentByID(ctx, createdID); err != nil {
+		return fmt.Errorf("committed appointment not visible: %w", err)
+	}
+	return nil
+}
+
+func testSlotQuote(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.GetSlotQuote(ctx, f.slot.ID); expectErr(err, appointment.ErrPriceNotFound) != nil {
+		return fmt.Errorf("unpriced slot: %w", expectErr(err, appointment.ErrPriceNotFound))
+	}
+
+	if err := b.SetSlotTypePrice(ctx, f.clinic.ID, *f.slot.SlotType, appointment.Price{AmountMinor: 12500, Currency: "USD"}); err != nil {
+		return err
+	}
+	// Upsert replaces the price
+	if err := b.SetSlotTypePrice(ctx, f.clinic.ID, *f.slot.SlotType, appointment.Price{AmountMinor: 9900, Currency: "USD"}); err != nil {
+		return err
+	}
+
+	q, err := b.GetSlotQuote(ctx, f.slot.ID)
+	if err != nil {
+		return fmt.Errorf("GetSlotQuote: %w", err)
+	}
+	if q.ClinicID != f.clinic.ID || q.SlotType != *f.slot.SlotType || q.Price.AmountMinor != 9900 || q.Price.Currency != "USD" {
+		return fmt.Errorf("quote mismatch: %+v", q)
+	}
+	return nil
+}
+
+func testBookingIntents(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	intent := appointment.BookingIntent{
+		ID:        uuid.New(),
+		SlotID:    f.slot.ID,
+		PatientID: f.patient.ID,
+		LockToken: uuid.NewString(),
+		State:     appointment.IntentPending,
+	}
+	if err := b.CreateBookingIntent(ctx, intent); err != nil {
+		return fmt.Errorf("CreateBookingIntent: %w", err)
+	}
+
+	pendingHas := func(id uuid.UUID) (bool, error) {
+		pending, err := b.ListPendingBookingIntents(ctx, time.Now().Add(time.Minute))
+		if err != nil {
+			return false, fmt.Errorf("ListPendingBookingIntents: %w", err)
+		}
+		for _, p := range pending {
+			if p.ID == id {
+				if p.LockToken != intent.LockToken || p.SlotID != f.slot.ID {
+					return false, fmt.Errorf("pending intent did not round trip: %+v", p)
+				}
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	found, err := pendingHas(intent.ID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("new intent not listed as pending")
+	}
+
+	older, err := b.ListPendingBookingIntents(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		return fmt.Errorf("ListPendingBookingIntents: %w", err)
+	}
+	for _, p := range older {
+		if p.ID == intent.ID {
+			return errors.New("cutoff not applied")
+		}
+	}
+
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+	if err := b.ResolveBookingIntent(ctx, intent.ID, appointment.IntentCommitted, &appt.ID); err != nil {
+		return fmt.Errorf("ResolveBookingIntent: %w", err)
+	}
+
+	found, err = pendingHas(intent.ID)
+	if err != nil {
+		return err
+	}
+	if found {
+		return errors.New("committed intent still listed as pending")
+	}
+
+	return expectErr(b.ResolveBookingIntent(ctx, uuid.New(), appointment.IntentAborted, nil), appointment.ErrIntentNotFound)
+}
+
+func testAvailabilityVersion(ctx context.Context, b Backend) error {
+	unknown, err := b.GetAvailabilityVersion(ctx, uuid.New())
+	if err != nil {
+		return fmt.Errorf("GetAvailabilityVersion of unknown clinician: %w", err)
+	}
+	if unknown != 0 {
+		return fmt.Errorf("unknown clinician version = %d, want 0", unknown)
+	}
+
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	last, err := b.GetAvailabilityVersion(ctx, f.clinician.ID)
+	if err != nil {
+		return fmt.Errorf("GetAvailabilityVersion: %w", err)
+	}
+	if last == 0 {
+		return errors.New("version not bumped by slot insert")
+	}
+
+	bumped := func(step string) error {
+		v, err := b.GetAvailabilityVersion(ctx, f.clinician.ID)
+		if err != nil {
+			return fmt.Errorf("GetAvailabilityVersion after %s: %w", step, err)
+		}
+		if v <= last {
+			return fmt.Errorf("version not bumped by %s: %d -> %d", step, last, v)
+		}
+		last = v
+		return nil
+	}
+
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return fmt.Errorf("CreatePendingAppointment: %w", err)
+	}
+	if err := bumped("booking"); err != nil {
+		return err
+	}
+
+	if _, err := b.UpdateAppointmentStatus(ctx, appt.ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("UpdateAppointmentStatus: %w", err)
+	}
+	return bumped("status change")
+}
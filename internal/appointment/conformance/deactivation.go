@@ -0,0 +1,110 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testPatientDeactivation deactivates a patient and checks they cannot book,
+// lose their holds but not their confirmed appointments, and drop out of
+// searches until reactivated
+func testPatientDeactivation(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	specialty, err := f.withSpecialty(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	var booked []*appointment.Appointment
+	for i := 0; i < 2; i++ {
+		slot, err := f.addSlot(ctx, b, time.Duration(25+i)*time.Hour)
+		if err != nil {
+			return err
+		}
+		appt, err := b.CreatePendingAppointment(ctx, slot.ID, f.patient.ID, time.Now().Add(10*time.Minute))
+		if err != nil {
+			return fmt.Errorf("CreatePendingAppointment: %w", err)
+		}
+		booked = append(booked, appt)
+	}
+	if _, err := b.UpdateAppointmentStatus(ctx, booked[1].ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("UpdateAppointmentStatus: %w", err)
+	}
+
+	_, err = svc.DeactivatePatient(ctx, f.patient.ID, "staff-1", " ")
+	if err := expectErr(err, appointment.ErrInvalidDeactivation); err != nil {
+		return fmt.Errorf("DeactivatePatient without a reason: %w", err)
+	}
+
+	res, err := svc.DeactivatePatient(ctx, f.patient.ID, "staff-1", "moved away")
+	if err != nil {
+		return fmt.Errorf("DeactivatePatient: %w", err)
+	}
+	p := res.Patient
+	if !p.Deactivated() || p.DeactivatedBy == nil || *p.DeactivatedBy != "staff-1" ||
+		p.DeactivationReason == nil || *p.DeactivationReason != "moved away" {
+		return fmt.Errorf("expected the patient deactivated by staff-1, got %+v", p)
+	}
+	if len(res.CancelledHolds.Cancelled) != 1 || res.CancelledHolds.Cancelled[0].ID != booked[0].ID {
+		return fmt.Errorf("expected only the hold cancelled, got %+v", res.CancelledHolds)
+	}
+	if err := expectStatus(ctx, b, booked[1].ID, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("confirmed appointment: %w", err)
+	}
+
+	_, err = svc.DeactivatePatient(ctx, f.patient.ID, "staff-2", "again")
+	if err := expectErr(err, appointment.ErrPatientDeactivated); err != nil {
+		return fmt.Errorf("DeactivatePatient twice: %w", err)
+	}
+	_, err = svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err := expectErr(err, appointment.ErrPatientDeactivated); err != nil {
+		return fmt.Errorf("CreateAppointment while deactivated: %w", err)
+	}
+
+	found := func(q appointment.AppointmentSearch) (int, error) {
+		page, err := svc.SearchAppointments(ctx, q, appointment.DetailFields{Patient: true})
+		if err != nil {
+			return 0, fmt.Errorf("SearchAppointments: %w", err)
+		}
+		return len(page.Appointments), nil
+	}
+	for _, c := range []struct {
+		what string
+		q    appointment.AppointmentSearch
+		want int
+	}{
+		{"specialty", appointment.AppointmentSearch{Specialty: specialty}, 0},
+		{"specialty including deactivated", appointment.AppointmentSearch{Specialty: specialty, IncludeDeactivated: true}, 2},
+		{"patient", appointment.AppointmentSearch{PatientID: &f.patient.ID, Specialty: specialty}, 2},
+	} {
+		n, err := found(c.q)
+		if err != nil {
+			return err
+		}
+		if n != c.want {
+			return fmt.Errorf("search by %s: expected %d appointments, got %d", c.what, c.want, n)
+		}
+	}
+
+	p, err = svc.ReactivatePatient(ctx, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("ReactivatePatient: %w", err)
+	}
+	if p.Deactivated() || p.DeactivatedBy != nil || p.DeactivationReason != nil {
+		return fmt.Errorf("expected the deactivation cleared, got %+v", p)
+	}
+	if n, err := found(appointment.AppointmentSearch{Specialty: specialty}); err != nil || n != 2 {
+		return fmt.Errorf("expected both appointments found after reactivation, got %d (%v)", n, err)
+	}
+	if _, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID); err != nil {
+		return fmt.Errorf("CreateAppointment after reactivation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testDevices registers push devices and checks a token is stored once, moves
+// between patients, is found through the patient's appointments and goes
+// when deleted
+func testDevices(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	other := appointment.Patient{ID: uuid.New(), Name: "Device Patient"}
+	if err := b.InsertPatient(ctx, other); err != nil {
+		return err
+	}
+
+	// Tokens unique to this run, since they are unique across patients
+	fcmToken := "fcm:" + uuid.NewString()
+	apnsToken := strings.ReplaceAll(uuid.NewString()+uuid.NewString(), "-", "")
+
+	for _, c := range []struct {
+		what      string
+		patientID uuid.UUID
+		provider  appointment.PushProvider
+		token     string
+		want      error
+	}{
+		{"unknown provider", f.patient.ID, "sms", fcmToken, appointment.ErrInvalidDevice},
+		{"no token", f.patient.ID, appointment.PushFCM, "  ", appointment.ErrInvalidDevice},
+		{"APNs token not hex", f.patient.ID, appointment.PushAPNs, fcmToken, appointment.ErrInvalidDevice},
+		{"unknown patient", uuid.New(), appointment.PushFCM, fcmToken, appointment.ErrPatientNotFound},
+	} {
+		_, err := svc.RegisterDevice(ctx, c.patientID, c.provider, c.token)
+		if err := expectErr(err, c.want); err != nil {
+			return fmt.Errorf("RegisterDevice with %s: %w", c.what, err)
+		}
+	}
+
+	first, err := svc.RegisterDevice(ctx, f.patient.ID, appointment.PushFCM, fcmToken)
+	if err != nil {
+		return fmt.Errorf("RegisterDevice: %w", err)
+	}
+	again, err := svc.RegisterDevice(ctx, f.patient.ID, appointment.PushFCM, " "+fcmToken+" ")
+	if err != nil {
+		return fmt.Errorf("RegisterDevice again: %w", err)
+	}
+	if again.ID != first.ID || again.Token != fcmToken {
+		return fmt.Errorf("expected re-registering to keep device %s, got %+v", first.ID, again)
+	}
+	ios, err := svc.RegisterDevice(ctx, f.patient.ID, appointment.PushAPNs, apnsToken)
+	if err != nil {
+		return fmt.Errorf("RegisterDevice for APNs: %w", err)
+	}
+
+	devices, err := svc.ListDevices(ctx, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("ListDevices: %w", err)
+	}
+	if len(devices) != 2 || devices[0].ID != first.ID || devices[1].ID != ios.ID {
+		return fmt.Errorf("expected the FCM then the APNs device, got %+v", devices)
+	}
+
+	appt, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	byAppt, err := b.ListAppointmentDevices(ctx, appt.ID)
+	if err != nil {
+		return fmt.Errorf("ListAppointmentDevices: %w", err)
+	}
+	if len(byAppt) != 2 {
+		return fmt.Errorf("expected the appointment's patient's 2 devices, got %+v", byAppt)
+	}
+
+	// Someone else signs in on the Android phone
+	moved, err := svc.RegisterDevice(ctx, other.ID, appointment.PushFCM, fcmToken)
+	if err != nil {
+		return fmt.Errorf("RegisterDevice for another patient: %w", err)
+	}
+	if moved.PatientID != other.ID {
+		return fmt.Errorf("expected the token moved to %s, got %+v", other.ID, moved)
+	}
+	if devices, err := svc.ListDevices(ctx, f.patient.ID); err != nil || len(devices) != 1 || devices[0].ID != ios.ID {
+		return fmt.Errorf("expected only the APNs device left, got %+v (err %v)", devices, err)
+	}
+
+	err = svc.DeleteDevice(ctx, f.patient.ID, moved.ID)
+	if err := expectErr(err, appointment.ErrDeviceNotFound); err != nil {
+		return fmt.Errorf("DeleteDevice of another patient's device: %w", err)
+	}
+	if err := svc.DeleteDevice(ctx, other.ID, moved.ID); err != nil {
+		return fmt.Errorf("DeleteDevice: %w", err)
+	}
+
+	if err := b.DeleteDeviceByToken(ctx, apnsToken); err != nil {
+		return fmt.Errorf("DeleteDeviceByToken: %w", err)
+	}
+	if err := b.DeleteDeviceByToken(ctx, apnsToken); err != nil {
+		return fmt.Errorf("DeleteDeviceByToken of a gone token: %w", err)
+	}
+	if devices, err := svc.ListDevices(ctx, f.patient.ID); err != nil || len(devices) != 0 {
+		return fmt.Errorf("expected no devices left, got %+v (err %v)", devices, err)
+	}
+	return nil
+}
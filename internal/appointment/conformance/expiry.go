@@ -0,0 +1,482 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/clock"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// The expiry cases drive a Service over the backend with a fake clock. The
+// worker runs expire every pending appointment due before the fake time, so
+// like the rest of the suite they need a scratch database.
+
+const (
+	holdTTL        = 10 * time.Minute
+	orphanGrace    = time.Hour
+	approvalWindow = 48 * time.Hour
+)
+
+// timeTravelService returns a service whose clock starts at start. Time is
+// truncated to the millisecond so it survives every backend's timestamps.
+func timeTravelService(b Backend, start time.Time) (*appointment.Service, *clock.Fake) {
+	fake := clock.NewFake(start.Truncate(time.Millisecond))
+	cfg := config.Config{
+		AppointmentTTL:  holdTTL,
+		LockTTL:         5 * time.Second,
+		ExpiryBatchSize: 100,
+		OrphanGrace:     orphanGrace,
+		ApprovalWindow:  approvalWindow,
+	}
+	svc := appointment.NewService(b, redisclient.NewInMemorySlotLocker(), cfg, appointment.WithClock(fake))
+	return svc, fake
+}
+
+func expectStatus(ctx context.Context, b Backend, id uuid.UUID, want appointment.AppointmentStatus) error {
+	appt, err := b.GetAppointmentByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("GetAppointmentByID: %w", err)
+	}
+	if appt.Status != want {
+		return fmt.Errorf("expected status %s, got %s", want, appt.Status)
+	}
+	return nil
+}
+
+// testHoldExpiresAtTTL checks the worker leaves a hold alone up to and
+// including its deadline and expires it just after
+func testHoldExpiresAtTTL(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, fake := timeTravelService(b, time.Now())
+
+	appt, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	deadline := fake.Now().Add(holdTTL)
+	if appt.ExpiresAt == nil || !appt.ExpiresAt.Equal(deadline) {
+		return fmt.Errorf("expected expires_at %s, got %v", deadline, appt.ExpiresAt)
+	}
+
+	steps := []struct {
+		at   time.Time
+		want appointment.AppointmentStatus
+	}{
+		{deadline.Add(-time.Millisecond), appointment.StatusPending},
+		{deadline, appointment.StatusPending},
+		{deadline.Add(time.Millisecond), appointment.StatusExpired},
+	}
+	for _, step := range steps {
+		fake.Set(step.at)
+		if _, err := svc.ExpirePendingAppointments(ctx, nil); err != nil {
+			return fmt.Errorf("expire at %s: %w", step.at.Sub(deadline), err)
+		}
+		if err := expectStatus(ctx, b, appt.ID, step.want); err != nil {
+			return fmt.Errorf("at deadline%+v: %w", step.at.Sub(deadline), err)
+		}
+	}
+	return nil
+}
+
+// testConfirmAtBoundary checks a confirm exactly at the deadline succeeds
+// and one a millisecond later fails and leaves the hold expired
+func testConfirmAtBoundary(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, fake := timeTravelService(b, time.Now())
+	start := fake.Now()
+
+	onTime, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	fake.Set(start.Add(holdTTL))
+	if _, err := svc.ConfirmAppointment(ctx, onTime.ID); err != nil {
+		return fmt.Errorf("confirm at deadline: %w", err)
+	}
+
+	late, err := f.addSlot(ctx, b, 72*time.Hour)
+	if err != nil {
+		return err
+	}
+	fake.Set(start)
+	appt, err := svc.CreateAppointment(ctx, late.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	fake.Set(start.Add(holdTTL + time.Millisecond))
+	_, err = svc.ConfirmAppointment(ctx, appt.ID)
+	if err := expectErr(err, appointment.ErrAppointmentExpiredState); err != nil {
+		return fmt.Errorf("confirm after deadline: %w", err)
+	}
+	return expectStatus(ctx, b, appt.ID, appointment.StatusExpired)
+}
+
+// testConfirmExpiryRace runs a confirm that still sees the hold as valid
+// against a worker that already sees it as expired. Whichever wins, the
+// appointment ends in the state the winner reported and a later worker run
+// does not change it.
+func testConfirmExpiryRace(ctx context.Context, b Backend) error {
+	const rounds = 20
+
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slot, err := f.addSlotWithCapacity(ctx, b, 96*time.Hour, rounds)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	confirmSvc, confirmClock := timeTravelService(b, start)
+	workerSvc, workerClock := timeTravelService(b, start)
+
+	for i := 0; i < rounds; i++ {
+		now := confirmClock.Now()
+		appt, err := b.CreatePendingAppointment(ctx, slot.ID, f.patient.ID, now.Add(holdTTL))
+		if err != nil {
+			return err
+		}
+		confirmClock.Set(now.Add(holdTTL))
+		workerClock.Set(now.Add(holdTTL + time.Millisecond))
+
+		var confirmErr error
+		var wg sync.WaitGroup
+		ready := make(chan struct{})
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			<-ready
+			_, confirmErr = confirmSvc.ConfirmAppointment(ctx, appt.ID)
+		}()
+		go func() {
+			defer wg.Done()
+			<-ready
+			_, _ = workerSvc.ExpirePendingAppointments(ctx, nil)
+		}()
+		close(ready)
+		wg.Wait()
+
+		want := appointment.StatusConfirmed
+		if confirmErr != nil {
+			if !errors.Is(confirmErr, appointment.ErrInvalidStatusTransition) &&
+				!errors.Is(confirmErr, appointment.ErrAppointmentExpiredState) {
+				return fmt.Errorf("round %d: unexpected confirm error: %w", i, confirmErr)
+			}
+			want = appointment.StatusExpired
+		}
+		if err := expectStatus(ctx, b, appt.ID, want); err != nil {
+			return fmt.Errorf("round %d: %w", i, err)
+		}
+
+		if _, err := workerSvc.ExpirePendingAppointments(ctx, nil); err != nil {
+			return fmt.Errorf("round %d: second worker run: %w", i, err)
+		}
+		if err := expectStatus(ctx, b, appt.ID, want); err != nil {
+			return fmt.Errorf("round %d: after second worker run: %w", i, err)
+		}
+
+		// Next round starts after this one's hold
+		confirmClock.Set(now.Add(holdTTL + time.Second))
+	}
+	return nil
+}
+
+// testConfirmedSurvivesDeadline is the regression for appointments that
+// showed as expired after being confirmed: a repeated confirm after the hold
+// deadline used to try to expire the appointment and log an EXPIRED event
+// even though it was already confirmed.
+func testConfirmedSurvivesDeadline(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, fake := timeTravelService(b, time.Now())
+
+	appt, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, appt.ID); err != nil {
+		return fmt.Errorf("confirm: %w", err)
+	}
+
+	fake.Advance(holdTTL + time.Minute)
+	_, err = svc.ConfirmAppointment(ctx, appt.ID)
+	if err := expectErr(err, appointment.ErrInvalidStatusTransition); err != nil {
+		return fmt.Errorf("repeated confirm: %w", err)
+	}
+	if _, err := svc.ExpirePendingAppointments(ctx, nil); err != nil {
+		return fmt.Errorf("expire: %w", err)
+	}
+	return expectStatus(ctx, b, appt.ID, appointment.StatusConfirmed)
+}
+
+// testExpiryEventReport checks a worker expiry is logged once and not
+// reported, and that a stray EXPIRED event on a confirmed appointment is
+func testExpiryEventReport(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, fake := timeTravelService(b, time.Now())
+	since := fake.Now().Add(-time.Minute)
+
+	expired, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	fake.Advance(holdTTL + time.Millisecond)
+	// The second run finds nothing left to expire and must not log again
+	for i := 0; i < 2; i++ {
+		if _, err := svc.ExpirePendingAppointments(ctx, nil); err != nil {
+			return fmt.Errorf("expire: %w", err)
+		}
+	}
+
+	slot, err := f.addSlot(ctx, b, 120*time.Hour)
+	if err != nil {
+		return err
+	}
+	confirmed, err := svc.CreateAppointment(ctx, slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, confirmed.ID); err != nil {
+		return fmt.Errorf("confirm: %w", err)
+	}
+	if err := b.InsertEvent(ctx, appointment.EventLog{
+		EventType:     appointment.EventAppointmentExpired,
+		AppointmentID: &confirmed.ID,
+		CreatedAt:     fake.Now(),
+	}); err != nil {
+		return fmt.Errorf("InsertEvent: %w", err)
+	}
+
+	mismatches, err := b.ListExpiryEventMismatches(ctx, since, 1000)
+	if err != nil {
+		return fmt.Errorf("ListExpiryEventMismatches: %w", err)
+	}
+	var found bool
+	for _, m := range mismatches {
+		switch m.AppointmentID {
+		case expired.ID:
+			return fmt.Errorf("expired appointment reported with %d events", m.ExpiredEvents)
+		case confirmed.ID:
+			if m.Status != appointment.StatusConfirmed || m.ExpiredEvents != 1 {
+				return fmt.Errorf("expected confirmed with 1 event, got %s with %d", m.Status, m.ExpiredEvents)
+			}
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("confirmed appointment with an EXPIRED event not reported")
+	}
+	return nil
+}
+
+// testOrphanedAppointments sets up each kind of orphan next to a look-alike
+// that is not one, then checks a repairing run resolves exactly the orphans
+func testOrphanedAppointments(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, fake := timeTravelService(b, time.Now())
+	start := fake.Now()
+
+	book := func(slot *appointment.AppointmentSlot, expiresAt time.Time, confirm bool) (uuid.UUID, error) {
+		appt, err := b.CreatePendingAppointment(ctx, slot.ID, f.patient.ID, expiresAt)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("CreatePendingAppointment: %w", err)
+		}
+		if confirm {
+			if _, err := b.UpdateAppointmentStatus(ctx, appt.ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+				return uuid.Nil, fmt.Errorf("confirm: %w", err)
+			}
+		}
+		return appt.ID, nil
+	}
+	slot := func(d time.Duration, status appointment.SlotStatus) (*appointment.AppointmentSlot, error) {
+		return f.insertSlot(ctx, b, d, 1, status)
+	}
+
+	// The fake clock ends up orphanGrace past the stale hold's deadline
+	now := start.Add(holdTTL + orphanGrace + time.Second)
+	open1, err := slot(150*time.Hour, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	open2, err := slot(151*time.Hour, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	deleted, err := slot(152*time.Hour, appointment.SlotDeleted)
+	if err != nil {
+		return err
+	}
+	blocked, err := slot(153*time.Hour, appointment.SlotBlocked)
+	if err != nil {
+		return err
+	}
+
+	stale, err := book(open1, start.Add(holdTTL), false)
+	if err != nil {
+		return err
+	}
+	// past its deadline, but within the grace the worker gets
+	lapsed, err := book(open2, now.Add(-orphanGrace/2), false)
+	if err != nil {
+		return err
+	}
+	onDeleted, err := book(deleted, now.Add(holdTTL), false)
+	if err != nil {
+		return err
+	}
+	onBlocked, err := book(blocked, now.Add(holdTTL), true)
+	if err != nil {
+		return err
+	}
+	// a hold on a blocked slot is left to expire on its own
+	blocked2, err := slot(154*time.Hour, appointment.SlotBlocked)
+	if err != nil {
+		return err
+	}
+	pendingOnBlocked, err := book(blocked2, now.Add(holdTTL), false)
+	if err != nil {
+		return err
+	}
+
+	fake.Set(now)
+	orphans, err := svc.FindOrphanedAppointments(ctx, 1000)
+	if err != nil {
+		return fmt.Errorf("FindOrphanedAppointments: %w", err)
+	}
+	want := map[uuid.UUID]appointment.OrphanKind{
+		stale:     appointment.OrphanStalePending,
+		onDeleted: appointment.OrphanDeletedSlot,
+		onBlocked: appointment.OrphanBlockedSlot,
+	}
+	for _, o := range orphans {
+		switch o.ID {
+		case lapsed, pendingOnBlocked:
+			return fmt.Errorf("appointment %s reported as %s", o.ID, o.Kind)
+		}
+		if kind, ok := want[o.ID]; ok {
+			if o.Kind != kind {
+				return fmt.Errorf("appointment %s: expected kind %s, got %s", o.ID, kind, o.Kind)
+			}
+			delete(want, o.ID)
+		}
+	}
+	for id, kind := range want {
+		return fmt.Errorf("%s appointment %s not reported", kind, id)
+	}
+
+	if _, err := svc.ReconcileOrphanedAppointments(ctx, nil, true); err != nil {
+		return fmt.Errorf("ReconcileOrphanedAppointments: %w", err)
+	}
+	for id, status := range map[uuid.UUID]appointment.AppointmentStatus{
+		stale:            appointment.StatusExpired,
+		lapsed:           appointment.StatusPending,
+		onDeleted:        appointment.StatusCancelled,
+		onBlocked:        appointment.StatusCancelled,
+		pendingOnBlocked: appointment.StatusPending,
+	} {
+		if err := expectStatus(ctx, b, id, status); err != nil {
+			return fmt.Errorf("after repair, appointment %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// testExpiryBacklog adds holds overdue by different amounts and checks the
+// backlog counts each in its bucket, leaving out holds not yet due and
+// appointments no longer pending, then that an expiry run clears it
+func testExpiryBacklog(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	// Holds must expire after they are created, so the clock is set ahead
+	// of the oldest one
+	svc, fake := timeTravelService(b, time.Now().Add(3*time.Hour))
+	now := fake.Now()
+
+	// Other cases may have left holds behind, so compare against a baseline
+	before, err := svc.ExpiryBacklog(ctx)
+	if err != nil {
+		return fmt.Errorf("ExpiryBacklog: %w", err)
+	}
+
+	for i, c := range []struct {
+		expiresAt time.Time
+		confirm   bool
+	}{
+		{now.Add(-30 * time.Second), false},
+		{now.Add(-3 * time.Minute), false},
+		{now.Add(-2 * time.Hour), false},
+		{now.Add(5 * time.Minute), false},
+		{now.Add(-3 * time.Minute), true},
+	} {
+		slot, err := f.addSlot(ctx, b, time.Duration(160+i)*time.Hour)
+		if err != nil {
+			return err
+		}
+		appt, err := b.CreatePendingAppointment(ctx, slot.ID, f.patient.ID, c.expiresAt)
+		if err != nil {
+			return fmt.Errorf("CreatePendingAppointment: %w", err)
+		}
+		if c.confirm {
+			if _, err := b.UpdateAppointmentStatus(ctx, appt.ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+				return fmt.Errorf("confirm: %w", err)
+			}
+		}
+	}
+
+	after, err := svc.ExpiryBacklog(ctx)
+	if err != nil {
+		return fmt.Errorf("ExpiryBacklog: %w", err)
+	}
+	if after.Total-before.Total != 3 {
+		return fmt.Errorf("expected 3 more overdue holds, got %d then %d", before.Total, after.Total)
+	}
+	want := []int{1, 1, 0, 0, 1}
+	if len(after.Buckets) != len(want) {
+		return fmt.Errorf("expected %d buckets, got %+v", len(want), after.Buckets)
+	}
+	for i, bucket := range after.Buckets {
+		if got := bucket.Count - before.Buckets[i].Count; got != want[i] {
+			return fmt.Errorf("bucket from %s: expected %d more, got %d", bucket.From, want[i], got)
+		}
+	}
+	if after.Oldest == nil || after.Oldest.After(now.Add(-2*time.Hour)) {
+		return fmt.Errorf("expected the oldest deadline 2h ago or earlier, got %v", after.Oldest)
+	}
+
+	if _, err := svc.ExpirePendingAppointments(ctx, nil); err != nil {
+		return fmt.Errorf("expire: %w", err)
+	}
+	cleared, err := svc.ExpiryBacklog(ctx)
+	if err != nil {
+		return fmt.Errorf("ExpiryBacklog: %w", err)
+	}
+	if cleared.Total != 0 || cleared.Oldest != nil {
+		return fmt.Errorf("expected no backlog after an expiry run, got %d from %v", cleared.Total, cleared.Oldest)
+	}
+	return nil
+}
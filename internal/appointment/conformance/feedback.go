@@ -0,0 +1,219 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/clock"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+const feedbackWindow = 72 * time.Hour
+
+func testFeedbackRoundTrip(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	since := time.Now().Add(-time.Minute)
+
+	_, err = b.GetFeedback(ctx, uuid.New())
+	if err := expectErr(err, appointment.ErrFeedbackNotFound); err != nil {
+		return fmt.Errorf("GetFeedback of missing feedback: %w", err)
+	}
+
+	comment := "Very thorough"
+	var ids []uuid.UUID
+	for i, rating := range []int{5, 4, 5} {
+		if i > 0 {
+			if err := f.nextSlot(ctx, b); err != nil {
+				return err
+			}
+		}
+		appt, err := f.book(ctx, b)
+		if err != nil {
+			return err
+		}
+		fb := appointment.Feedback{AppointmentID: appt.ID, ClinicianID: f.clinician.ID, Rating: rating}
+		if i == 0 {
+			fb.Comment = &comment
+		}
+		saved, err := b.InsertFeedback(ctx, fb)
+		if err != nil {
+			return fmt.Errorf("InsertFeedback: %w", err)
+		}
+		if saved.AppointmentID != appt.ID || saved.Rating != rating || saved.CreatedAt.IsZero() {
+			return fmt.Errorf("feedback did not round trip: %+v", saved)
+		}
+		ids = append(ids, appt.ID)
+	}
+	_, err = b.InsertFeedback(ctx, appointment.Feedback{AppointmentID: ids[0], ClinicianID: f.clinician.ID, Rating: 1})
+	if err := expectErr(err, appointment.ErrFeedbackAlreadyGiven); err != nil {
+		return fmt.Errorf("InsertFeedback twice: %w", err)
+	}
+
+	got, err := b.GetFeedback(ctx, ids[0])
+	if err != nil {
+		return fmt.Errorf("GetFeedback: %w", err)
+	}
+	if got.Rating != 5 || got.Comment == nil || *got.Comment != comment || got.ClinicianID != f.clinician.ID {
+		return fmt.Errorf("unexpected feedback %+v", got)
+	}
+
+	ratings, err := b.ListClinicianRatings(ctx, since, 1)
+	if err != nil {
+		return fmt.Errorf("ListClinicianRatings: %w", err)
+	}
+	var mine *appointment.ClinicianRating
+	for i := range ratings {
+		if ratings[i].ClinicianID == f.clinician.ID {
+			mine = &ratings[i]
+		}
+	}
+	if mine == nil || mine.Count != 3 || mine.Name != f.clinician.Name || mine.Ratings != [5]int{0, 0, 0, 1, 2} {
+		return fmt.Errorf("unexpected rating %+v", mine)
+	}
+	if mine.Average < 4.66 || mine.Average > 4.67 {
+		return fmt.Errorf("expected an average of 4.67, got %v", mine.Average)
+	}
+	ratings, err = b.ListClinicianRatings(ctx, since, 4)
+	if err != nil {
+		return fmt.Errorf("ListClinicianRatings: %w", err)
+	}
+	for _, cr := range ratings {
+		if cr.ClinicianID == f.clinician.ID {
+			return fmt.Errorf("expected the clinician left out below the minimum count")
+		}
+	}
+
+	list, err := b.ListClinicianFeedback(ctx, f.clinician.ID, 2)
+	if err != nil {
+		return fmt.Errorf("ListClinicianFeedback: %w", err)
+	}
+	if len(list) != 2 {
+		return fmt.Errorf("expected 2 feedback entries, got %d", len(list))
+	}
+	if list[0].CreatedAt.Before(list[1].CreatedAt) {
+		return fmt.Errorf("expected the newest feedback first")
+	}
+	return nil
+}
+
+// testFeedbackWorkflow rates an appointment before, during and after the
+// feedback window and checks the request is sent once it has ended
+func testFeedbackWorkflow(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	clk := clock.NewFake(time.Now().Truncate(time.Millisecond))
+	cfg := config.Config{
+		AppointmentTTL: holdTTL,
+		LockTTL:        5 * time.Second,
+		FeedbackWindow: feedbackWindow,
+	}
+	svc := appointment.NewService(b, redisclient.NewInMemorySlotLocker(), cfg, appointment.WithClock(clk))
+
+	appt, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	_, err = svc.SubmitFeedback(ctx, appt.ID, 5, "")
+	if err := expectErr(err, appointment.ErrFeedbackNotOpen); err != nil {
+		return fmt.Errorf("SubmitFeedback while pending: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, appt.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+	_, err = svc.SubmitFeedback(ctx, appt.ID, 5, "")
+	if err := expectErr(err, appointment.ErrFeedbackNotOpen); err != nil {
+		return fmt.Errorf("SubmitFeedback before the visit: %w", err)
+	}
+	_, err = svc.SubmitFeedback(ctx, uuid.New(), 5, "")
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("SubmitFeedback for a missing appointment: %w", err)
+	}
+
+	// Nothing is requested before the visit has ended
+	if _, err := svc.SendFeedbackRequests(ctx, nil); err != nil {
+		return fmt.Errorf("SendFeedbackRequests: %w", err)
+	}
+	requested, err := countEvents(ctx, b, f.patient.ID, appointment.EventFeedbackRequested)
+	if err != nil {
+		return err
+	}
+	if requested[appt.ID] != 0 {
+		return fmt.Errorf("expected no request before the visit, got %d", requested[appt.ID])
+	}
+
+	// The fixture slot starts a day out and lasts half an hour
+	clk.Advance(25 * time.Hour)
+	for range 2 {
+		if _, err := svc.SendFeedbackRequests(ctx, nil); err != nil {
+			return fmt.Errorf("SendFeedbackRequests: %w", err)
+		}
+	}
+	requested, err = countEvents(ctx, b, f.patient.ID, appointment.EventFeedbackRequested)
+	if err != nil {
+		return err
+	}
+	if requested[appt.ID] != 1 {
+		return fmt.Errorf("expected one feedback request, got %d", requested[appt.ID])
+	}
+
+	for _, bad := range []struct {
+		rating  int
+		comment string
+	}{
+		{0, ""},
+		{6, ""},
+		{3, string(make([]byte, 2001))},
+	} {
+		_, err := svc.SubmitFeedback(ctx, appt.ID, bad.rating, bad.comment)
+		if err := expectErr(err, appointment.ErrInvalidFeedback); err != nil {
+			return fmt.Errorf("rating %d: %w", bad.rating, err)
+		}
+	}
+	saved, err := svc.SubmitFeedback(ctx, appt.ID, 4, "  Kind and on time ")
+	if err != nil {
+		return fmt.Errorf("SubmitFeedback: %w", err)
+	}
+	if saved.Rating != 4 || saved.Comment == nil || *saved.Comment != "Kind and on time" || saved.ClinicianID != f.clinician.ID {
+		return fmt.Errorf("unexpected feedback %+v", saved)
+	}
+	_, err = svc.SubmitFeedback(ctx, appt.ID, 5, "")
+	if err := expectErr(err, appointment.ErrFeedbackAlreadyGiven); err != nil {
+		return fmt.Errorf("SubmitFeedback twice: %w", err)
+	}
+	received, err := countEvents(ctx, b, f.patient.ID, appointment.EventFeedbackReceived)
+	if err != nil {
+		return err
+	}
+	if received[appt.ID] != 1 {
+		return fmt.Errorf("expected one feedback event, got %d", received[appt.ID])
+	}
+
+	// A second visit is not rated once the window has closed
+	if err := f.nextSlot(ctx, b); err != nil {
+		return err
+	}
+	clk.Advance(-25 * time.Hour)
+	late, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, late.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+	clk.Advance(26*time.Hour + feedbackWindow)
+	_, err = svc.SubmitFeedback(ctx, late.ID, 5, "")
+	if err := expectErr(err, appointment.ErrFeedbackNotOpen); err != nil {
+		return fmt.Errorf("SubmitFeedback after the window: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testOpenSlotSearch pages through a clinic's open slots and checks full,
+// blocked, other-specialty and out-of-window slots are left out
+func testOpenSlotSearch(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	specialty, err := f.withSpecialty(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+	day := f.slot.StartTime
+
+	shared, err := f.insertSlotAt(ctx, b, day.Add(2*time.Hour), 2, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	full, err := f.insertSlotAt(ctx, b, day.Add(3*time.Hour), 1, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	if _, err := f.insertSlotAt(ctx, b, day.Add(4*time.Hour), 1, appointment.SlotBlocked); err != nil {
+		return err
+	}
+	late, err := f.insertSlotAt(ctx, b, day.Add(5*time.Hour), 1, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	for _, slot := range []*appointment.AppointmentSlot{shared, full} {
+		booking, err := svc.Book(ctx, appointment.BookingRequest{SlotID: slot.ID, PatientID: f.patient.ID, SlotCount: 1})
+		if err != nil {
+			return fmt.Errorf("Book: %w", err)
+		}
+		if _, err := svc.ConfirmAppointment(ctx, booking.ID); err != nil {
+			return fmt.Errorf("ConfirmAppointment: %w", err)
+		}
+	}
+
+	// A clinician of another specialty at the same clinic
+	otherSpecialty := "Cardiology " + uuid.NewString()
+	f.clinician.ID = uuid.New()
+	f.clinician.Specialty = &otherSpecialty
+	if err := b.InsertClinician(ctx, f.clinician); err != nil {
+		return err
+	}
+	if _, err := f.insertSlotAt(ctx, b, day.Add(time.Hour), 1, appointment.SlotOpen); err != nil {
+		return err
+	}
+
+	search := appointment.SlotSearch{
+		ClinicID: f.clinic.ID, Specialty: specialty,
+		From: day.Add(-time.Hour), To: day.Add(6 * time.Hour), Limit: 2,
+	}
+	first, err := svc.SearchOpenSlots(ctx, search)
+	if err != nil {
+		return fmt.Errorf("SearchOpenSlots: %w", err)
+	}
+	if len(first.Slots) != 2 || first.Slots[0].ID != f.slot.ID || first.Slots[1].ID != shared.ID || first.NextToken == "" {
+		return fmt.Errorf("expected the fixture and shared slots then a token, got %+v", first)
+	}
+	if o := first.Slots[1]; o.Remaining != 1 || o.ClinicianName != "Dr. Conformance" ||
+		o.Specialty == nil || *o.Specialty != specialty || !o.StartTime.Equal(shared.StartTime) || o.Price != nil {
+		return fmt.Errorf("unexpected open slot %+v", o)
+	}
+
+	// Slots carry the clinic's price for their type once it has one
+	if err := b.SetSlotTypePrice(ctx, f.clinic.ID, *late.SlotType, appointment.Price{AmountMinor: 6000, Currency: "EUR"}); err != nil {
+		return fmt.Errorf("SetSlotTypePrice: %w", err)
+	}
+	search.Token = first.NextToken
+	second, err := svc.SearchOpenSlots(ctx, search)
+	if err != nil {
+		return fmt.Errorf("SearchOpenSlots of the next page: %w", err)
+	}
+	if len(second.Slots) != 1 || second.Slots[0].ID != late.ID || second.NextToken != "" {
+		return fmt.Errorf("expected only the late slot on the last page, got %+v", second)
+	}
+	if p := second.Slots[0].Price; p == nil || p.AmountMinor != 6000 || p.Currency != "EUR" {
+		return fmt.Errorf("expected the slot priced at 60 EUR, got %+v", p)
+	}
+
+	all, err := svc.SearchOpenSlots(ctx, appointment.SlotSearch{
+		ClinicID: f.clinic.ID, From: day.Add(-time.Hour), To: day.Add(6 * time.Hour), Limit: 10,
+	})
+	if err != nil {
+		return fmt.Errorf("SearchOpenSlots of every specialty: %w", err)
+	}
+	// The fixture's first clinician keeps its slot, so there are 5
+	if len(all.Slots) != 5 {
+		return fmt.Errorf("expected 5 open slots across specialties, got %d", len(all.Slots))
+	}
+
+	// A window opening just before the late slot hides the earlier ones
+	minLead := late.StartTime.Sub(time.Now()) - time.Minute
+	if _, err := svc.PutBookingWindow(ctx, appointment.BookingWindow{Specialty: specialty, MinLead: minLead}); err != nil {
+		return fmt.Errorf("PutBookingWindow: %w", err)
+	}
+	search.Token, search.Limit = "", 10
+	windowed, err := svc.SearchOpenSlots(ctx, search)
+	if err != nil {
+		return fmt.Errorf("SearchOpenSlots within a window: %w", err)
+	}
+	if len(windowed.Slots) != 1 || windowed.Slots[0].ID != late.ID {
+		return fmt.Errorf("expected the window to leave only the late slot, got %+v", windowed.Slots)
+	}
+
+	search.Token = "not a token"
+	_, err = svc.SearchOpenSlots(ctx, search)
+	if err := expectErr(err, appointment.ErrInvalidPageToken); err != nil {
+		return fmt.Errorf("SearchOpenSlots with a bad token: %w", err)
+	}
+	search.Token, search.To = "", search.From
+	_, err = svc.SearchOpenSlots(ctx, search)
+	if err := expectErr(err, appointment.ErrInvalidTimeRange); err != nil {
+		return fmt.Errorf("SearchOpenSlots of an empty range: %w", err)
+	}
+	return nil
+}
+
+// testGuestBooking books as a guest and checks the patient is matched by
+// email and the slot must belong to the clinic
+func testGuestBooking(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+	second, err := f.addSlotWithCapacity(ctx, b, 26*time.Hour, 2)
+	if err != nil {
+		return err
+	}
+
+	for _, bad := range []appointment.GuestBooking{
+		{ClinicID: f.clinic.ID, SlotID: f.slot.ID, Name: "  ", Email: "guest@example.com"},
+		{ClinicID: f.clinic.ID, SlotID: f.slot.ID, Name: strings.Repeat("x", 201), Email: "guest@example.com"},
+		{ClinicID: f.clinic.ID, SlotID: f.slot.ID, Name: "Guest", Email: "not an email"},
+		{ClinicID: f.clinic.ID, SlotID: f.slot.ID, Name: "Guest", Email: "Guest <guest@example.com>"},
+	} {
+		_, err := svc.BookAsGuest(ctx, bad)
+		if err := expectErr(err, appointment.ErrInvalidGuest); err != nil {
+			return fmt.Errorf("guest %+v: %w", bad, err)
+		}
+	}
+	_, err = svc.BookAsGuest(ctx, appointment.GuestBooking{ClinicID: uuid.New(), SlotID: f.slot.ID, Name: "Guest", Email: "guest@example.com"})
+	if err := expectErr(err, appointment.ErrSlotNotFound); err != nil {
+		return fmt.Errorf("BookAsGuest at another clinic: %w", err)
+	}
+
+	// The fixture patient books again under a differently cased email
+	booking, err := svc.BookAsGuest(ctx, appointment.GuestBooking{
+		ClinicID: f.clinic.ID, SlotID: f.slot.ID, Name: "Someone Else", Email: strings.ToUpper(*f.patient.Email),
+	})
+	if err != nil {
+		return fmt.Errorf("BookAsGuest: %w", err)
+	}
+	if booking.PatientID != f.patient.ID || booking.Status != appointment.StatusConfirmed || !booking.StartTime().Equal(f.slot.StartTime) {
+		return fmt.Errorf("expected a confirmed booking for the existing patient, got %+v", booking)
+	}
+	patient, err := b.GetPatientByID(ctx, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("GetPatientByID: %w", err)
+	}
+	if patient.Name != f.patient.Name {
+		return fmt.Errorf("expected the existing patient to keep their name, got %q", patient.Name)
+	}
+
+	email := "guest+" + uuid.NewString() + "@example.com"
+	guest := appointment.GuestBooking{ClinicID: f.clinic.ID, SlotID: second.ID, Name: " New Guest ", Email: email}
+	booking, err = svc.BookAsGuest(ctx, guest)
+	if err != nil {
+		return fmt.Errorf("BookAsGuest of a new patient: %w", err)
+	}
+	created, err := b.GetPatientByID(ctx, booking.PatientID)
+	if err != nil {
+		return fmt.Errorf("GetPatientByID of the new patient: %w", err)
+	}
+	if created.Name != "New Guest" || created.Email == nil || *created.Email != email {
+		return fmt.Errorf("unexpected new patient %+v", created)
+	}
+	again, err := svc.BookAsGuest(ctx, guest)
+	if err != nil {
+		return fmt.Errorf("BookAsGuest of the same guest: %w", err)
+	}
+	if again.PatientID != created.ID {
+		return fmt.Errorf("expected the second booking to reuse patient %s, got %s", created.ID, again.PatientID)
+	}
+
+	_, err = svc.BookAsGuest(ctx, guest)
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("BookAsGuest of a full slot: %w", err)
+	}
+	return nil
+}
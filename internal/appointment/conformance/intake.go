@@ -0,0 +1,337 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/clock"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// withSlotType moves the fixture slot to a slot type of its own, so intake
+// templates set by a case do not reach other fixtures
+func (f *fixture) withSlotType(ctx context.Context, b Backend) (string, error) {
+	slotType := "intake " + uuid.NewString()
+	f.slot.SlotType = &slotType
+	if err := f.nextSlot(ctx, b); err != nil {
+		return "", err
+	}
+	return slotType, nil
+}
+
+func intakeQuestions() []appointment.IntakeQuestion {
+	return []appointment.IntakeQuestion{
+		{ID: "allergies", Label: "Do you have any allergies?", Type: appointment.IntakeYesNo, Required: true},
+		{ID: "reason", Label: "Reason for the visit", Type: appointment.IntakeChoice, Required: true, Options: []string{"check-up", "follow-up"}},
+		{ID: "notes", Label: "Anything else?", Type: appointment.IntakeText},
+	}
+}
+
+func testIntakeRoundTrip(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slotType, err := f.withSlotType(ctx, b)
+	if err != nil {
+		return err
+	}
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.GetIntakeTemplate(ctx, slotType)
+	if err := expectErr(err, appointment.ErrIntakeTemplateNotFound); err != nil {
+		return fmt.Errorf("GetIntakeTemplate of missing template: %w", err)
+	}
+
+	saved, err := b.PutIntakeTemplate(ctx, appointment.IntakeTemplate{SlotType: slotType, Questions: intakeQuestions()})
+	if err != nil {
+		return fmt.Errorf("PutIntakeTemplate: %w", err)
+	}
+	if saved.Version != 1 || len(saved.Questions) != 3 || saved.UpdatedAt.IsZero() {
+		return fmt.Errorf("template did not round trip: %+v", saved)
+	}
+	q := saved.Questions[1]
+	if q.ID != "reason" || q.Type != appointment.IntakeChoice || !q.Required || len(q.Options) != 2 || q.Options[1] != "follow-up" {
+		return fmt.Errorf("question did not round trip: %+v", q)
+	}
+
+	// Putting again replaces the questions under a new version
+	saved, err = b.PutIntakeTemplate(ctx, appointment.IntakeTemplate{SlotType: slotType, Questions: intakeQuestions()[:1]})
+	if err != nil {
+		return fmt.Errorf("PutIntakeTemplate again: %w", err)
+	}
+	if saved.Version != 2 || len(saved.Questions) != 1 {
+		return fmt.Errorf("expected version 2 with one question, got %+v", saved)
+	}
+	got, err := b.GetIntakeTemplate(ctx, slotType)
+	if err != nil {
+		return fmt.Errorf("GetIntakeTemplate: %w", err)
+	}
+	if got.Version != 2 || len(got.Questions) != 1 {
+		return fmt.Errorf("expected the replaced template, got %+v", got)
+	}
+
+	templates, err := b.ListIntakeTemplates(ctx)
+	if err != nil {
+		return fmt.Errorf("ListIntakeTemplates: %w", err)
+	}
+	found := 0
+	for _, t := range templates {
+		if t.SlotType == slotType {
+			found++
+		}
+	}
+	if found != 1 {
+		return fmt.Errorf("expected the template listed once, got %d", found)
+	}
+
+	response, err := b.GetIntakeResponse(ctx, appt.ID)
+	if err != nil || response != nil {
+		return fmt.Errorf("expected no response yet, got %+v, %v", response, err)
+	}
+	if _, err := b.PutIntakeResponse(ctx, appointment.IntakeResponse{
+		AppointmentID: appt.ID, TemplateVersion: 1, Answers: map[string]string{"allergies": "no"},
+	}); err != nil {
+		return fmt.Errorf("PutIntakeResponse: %w", err)
+	}
+	// Putting again replaces the answers
+	response, err = b.PutIntakeResponse(ctx, appointment.IntakeResponse{
+		AppointmentID: appt.ID, TemplateVersion: 2, Answers: map[string]string{"allergies": "yes", "notes": "none"}, Complete: true,
+	})
+	if err != nil {
+		return fmt.Errorf("PutIntakeResponse again: %w", err)
+	}
+	if response.TemplateVersion != 2 || !response.Complete || response.Answers["allergies"] != "yes" || response.CreatedAt.IsZero() {
+		return fmt.Errorf("response did not round trip: %+v", response)
+	}
+	response, err = b.GetIntakeResponse(ctx, appt.ID)
+	if err != nil {
+		return fmt.Errorf("GetIntakeResponse: %w", err)
+	}
+	if response == nil || len(response.Answers) != 2 || response.Answers["notes"] != "none" || !response.Complete {
+		return fmt.Errorf("expected the replaced response, got %+v", response)
+	}
+
+	if err := b.DeleteIntakeTemplate(ctx, slotType); err != nil {
+		return fmt.Errorf("DeleteIntakeTemplate: %w", err)
+	}
+	err = b.DeleteIntakeTemplate(ctx, slotType)
+	if err := expectErr(err, appointment.ErrIntakeTemplateNotFound); err != nil {
+		return fmt.Errorf("DeleteIntakeTemplate twice: %w", err)
+	}
+	return nil
+}
+
+// testIntakeWorkflow fills in a form over two submissions and checks the
+// status reported with the appointment and that the completion is logged
+func testIntakeWorkflow(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slotType, err := f.withSlotType(ctx, b)
+	if err != nil {
+		return err
+	}
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	status := func() (appointment.IntakeStatus, error) {
+		detail, err := svc.GetAppointment(ctx, appt.ID, appointment.DetailFields{Slot: true})
+		if err != nil {
+			return "", fmt.Errorf("GetAppointment: %w", err)
+		}
+		return detail.Intake, nil
+	}
+	if st, err := status(); err != nil || st != appointment.IntakeNotRequired {
+		return fmt.Errorf("expected no intake form before a template, got %q, %v", st, err)
+	}
+	_, err = svc.SubmitIntake(ctx, appt.ID, map[string]string{"allergies": "no"})
+	if err := expectErr(err, appointment.ErrIntakeTemplateNotFound); err != nil {
+		return fmt.Errorf("SubmitIntake without a template: %w", err)
+	}
+
+	for _, bad := range [][]appointment.IntakeQuestion{
+		nil,
+		{{ID: "Allergies", Label: "Allergies", Type: appointment.IntakeText}},
+		{{ID: "a", Label: "A", Type: appointment.IntakeText}, {ID: "a", Label: "B", Type: appointment.IntakeText}},
+		{{ID: "a", Label: " ", Type: appointment.IntakeText}},
+		{{ID: "a", Label: "A", Type: appointment.IntakeChoice}},
+		{{ID: "a", Label: "A", Type: appointment.IntakeChoice, Options: []string{"x", "x"}}},
+		{{ID: "a", Label: "A", Type: appointment.IntakeNumber, Options: []string{"1"}}},
+		{{ID: "a", Label: "A", Type: "signature"}},
+	} {
+		_, err := svc.PutIntakeTemplate(ctx, appointment.IntakeTemplate{SlotType: slotType, Questions: bad})
+		if err := expectErr(err, appointment.ErrInvalidIntakeTemplate); err != nil {
+			return fmt.Errorf("template %+v: %w", bad, err)
+		}
+	}
+	if _, err := svc.PutIntakeTemplate(ctx, appointment.IntakeTemplate{SlotType: slotType, Questions: intakeQuestions()}); err != nil {
+		return fmt.Errorf("PutIntakeTemplate: %w", err)
+	}
+	if st, err := status(); err != nil || st != appointment.IntakeIncomplete {
+		return fmt.Errorf("expected an incomplete form, got %q, %v", st, err)
+	}
+
+	for _, bad := range []map[string]string{
+		{"allergies": "maybe"},
+		{"reason": "emergency"},
+		{"shoe_size": "42"},
+	} {
+		_, err := svc.SubmitIntake(ctx, appt.ID, bad)
+		if err := expectErr(err, appointment.ErrInvalidIntakeAnswers); err != nil {
+			return fmt.Errorf("answers %v: %w", bad, err)
+		}
+	}
+	_, err = svc.SubmitIntake(ctx, uuid.New(), map[string]string{"allergies": "no"})
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("SubmitIntake for a missing appointment: %w", err)
+	}
+
+	intake, err := svc.SubmitIntake(ctx, appt.ID, map[string]string{"allergies": "no", "notes": "first visit"})
+	if err != nil {
+		return fmt.Errorf("SubmitIntake: %w", err)
+	}
+	if intake.Status != appointment.IntakeIncomplete || len(intake.Response.Answers) != 2 {
+		return fmt.Errorf("expected an incomplete form with two answers, got %+v", intake)
+	}
+	// The second submission is merged into the first and clears the notes
+	intake, err = svc.SubmitIntake(ctx, appt.ID, map[string]string{"reason": "follow-up", "notes": ""})
+	if err != nil {
+		return fmt.Errorf("SubmitIntake again: %w", err)
+	}
+	answers := intake.Response.Answers
+	if intake.Status != appointment.IntakeComplete || len(answers) != 2 || answers["allergies"] != "no" || answers["reason"] != "follow-up" {
+		return fmt.Errorf("expected a complete form with two answers, got %+v", intake.Response)
+	}
+	if st, err := status(); err != nil || st != appointment.IntakeComplete {
+		return fmt.Errorf("expected a complete form, got %q, %v", st, err)
+	}
+	got, err := svc.GetIntake(ctx, appt.ID)
+	if err != nil {
+		return fmt.Errorf("GetIntake: %w", err)
+	}
+	if got.Template == nil || got.Template.SlotType != slotType || got.Response == nil || got.Status != appointment.IntakeComplete {
+		return fmt.Errorf("unexpected intake %+v", got)
+	}
+
+	completed, err := countEvents(ctx, b, f.patient.ID, appointment.EventIntakeCompleted)
+	if err != nil {
+		return err
+	}
+	if completed[appt.ID] != 1 {
+		return fmt.Errorf("expected one completion event, got %d", completed[appt.ID])
+	}
+
+	// A cancelled appointment takes no more answers
+	if _, err := svc.CancelAppointment(ctx, appt.ID, "conformance", nil); err != nil {
+		return fmt.Errorf("CancelAppointment: %w", err)
+	}
+	_, err = svc.SubmitIntake(ctx, appt.ID, map[string]string{"notes": "late"})
+	if err := expectErr(err, appointment.ErrAppointmentNotActive); err != nil {
+		return fmt.Errorf("SubmitIntake after cancelling: %w", err)
+	}
+	return nil
+}
+
+// testIntakeReminders checks confirmed appointments with incomplete forms
+// inside the lead are reminded once, and the rest are not
+func testIntakeReminders(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slotType, err := f.withSlotType(ctx, b)
+	if err != nil {
+		return err
+	}
+	clk := clock.NewFake(time.Now().Truncate(time.Millisecond))
+	cfg := config.Config{
+		AppointmentTTL:     holdTTL,
+		LockTTL:            5 * time.Second,
+		IntakeReminderLead: 12 * time.Hour,
+	}
+	svc := appointment.NewService(b, redisclient.NewInMemorySlotLocker(), cfg, appointment.WithClock(clk))
+	if _, err := svc.PutIntakeTemplate(ctx, appointment.IntakeTemplate{SlotType: slotType, Questions: intakeQuestions()}); err != nil {
+		return fmt.Errorf("PutIntakeTemplate: %w", err)
+	}
+
+	// Three confirmed appointments a day out, one without answers, one with
+	// some and one complete, and one still pending, an hour apart
+	var ids []uuid.UUID
+	for range 4 {
+		if err := f.nextSlot(ctx, b); err != nil {
+			return err
+		}
+		appt, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+		if err != nil {
+			return fmt.Errorf("CreateAppointment: %w", err)
+		}
+		ids = append(ids, appt.ID)
+	}
+	for _, id := range ids[:3] {
+		if _, err := svc.ConfirmAppointment(ctx, id); err != nil {
+			return fmt.Errorf("ConfirmAppointment: %w", err)
+		}
+	}
+	if _, err := svc.SubmitIntake(ctx, ids[1], map[string]string{"allergies": "yes"}); err != nil {
+		return fmt.Errorf("SubmitIntake: %w", err)
+	}
+	if _, err := svc.SubmitIntake(ctx, ids[2], map[string]string{"allergies": "yes", "reason": "check-up"}); err != nil {
+		return fmt.Errorf("SubmitIntake: %w", err)
+	}
+
+	// A day out is beyond a 12 hour lead
+	if _, err := svc.SendIntakeReminders(ctx, nil); err != nil {
+		return fmt.Errorf("SendIntakeReminders: %w", err)
+	}
+	counts, err := countEvents(ctx, b, f.patient.ID, appointment.EventIntakeReminder)
+	if err != nil {
+		return err
+	}
+	if len(counts) != 0 {
+		return fmt.Errorf("expected no reminders outside the lead, got %v", counts)
+	}
+
+	// Later all four are inside the lead
+	clk.Advance(18 * time.Hour)
+	for range 2 {
+		if _, err := svc.SendIntakeReminders(ctx, nil); err != nil {
+			return fmt.Errorf("SendIntakeReminders: %w", err)
+		}
+	}
+	counts, err = countEvents(ctx, b, f.patient.ID, appointment.EventIntakeReminder)
+	if err != nil {
+		return err
+	}
+	if len(counts) != 2 || counts[ids[0]] != 1 || counts[ids[1]] != 1 {
+		return fmt.Errorf("expected one reminder each for the two incomplete forms, got %v", counts)
+	}
+	return nil
+}
+
+// countEvents counts the patient's events of eventType by appointment
+func countEvents(ctx context.Context, b Backend, patientID uuid.UUID, eventType string) (map[uuid.UUID]int, error) {
+	entries, err := b.ListPatientTimeline(ctx, patientID, 1000)
+	if err != nil {
+		return nil, fmt.Errorf("ListPatientTimeline: %w", err)
+	}
+	counts := make(map[uuid.UUID]int)
+	for _, e := range entries {
+		if e.EventType == eventType {
+			counts[e.AppointmentID]++
+		}
+	}
+	return counts, nil
+}
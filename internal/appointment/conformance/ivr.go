@@ -0,0 +1,171 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testIVR issues API keys and checks a phone tree finds the caller's next
+// appointment and confirms or cancels it by reference only as far as the
+// key's scopes and clinic allow, and that a revoked key stops working
+func testIVR(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	_, _, err = svc.CreateAPIKey(ctx, "IVR", nil, &f.clinic.ID)
+	if err := expectErr(err, appointment.ErrInvalidAPIKey); err != nil {
+		return fmt.Errorf("CreateAPIKey without scopes: %w", err)
+	}
+	_, _, err = svc.CreateAPIKey(ctx, "IVR", []appointment.APIScope{"ivr:everything"}, nil)
+	if err := expectErr(err, appointment.ErrInvalidAPIKey); err != nil {
+		return fmt.Errorf("CreateAPIKey with an unknown scope: %w", err)
+	}
+
+	all := appointment.APIScopes
+	repeated := []appointment.APIScope{appointment.ScopeIVRLookup, appointment.ScopeIVRConfirm, appointment.ScopeIVRCancel, appointment.ScopeIVRLookup}
+	k, secret, err := svc.CreateAPIKey(ctx, " Phone tree ", repeated, &f.clinic.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAPIKey: %w", err)
+	}
+	if k.Name != "Phone tree" || len(k.Scopes) != len(all) || k.Prefix == "" || k.Prefix == secret || secret[:len(k.Prefix)] != k.Prefix {
+		return fmt.Errorf("unexpected key %+v for %q", k, secret)
+	}
+	key, err := svc.AuthenticateAPIKey(ctx, secret)
+	if err != nil {
+		return fmt.Errorf("AuthenticateAPIKey: %w", err)
+	}
+	if key.ID != k.ID {
+		return fmt.Errorf("expected key %s, got %s", k.ID, key.ID)
+	}
+	_, err = svc.AuthenticateAPIKey(ctx, secret+"0")
+	if err := expectErr(err, appointment.ErrAPIKeyNotFound); err != nil {
+		return fmt.Errorf("AuthenticateAPIKey of an unknown key: %w", err)
+	}
+
+	lookupOnly, _, err := svc.CreateAPIKey(ctx, "Lookup only", []appointment.APIScope{appointment.ScopeIVRLookup}, nil)
+	if err != nil {
+		return fmt.Errorf("CreateAPIKey: %w", err)
+	}
+	elsewhereID := uuid.New()
+	elsewhere, _, err := svc.CreateAPIKey(ctx, "Other clinic", all, &elsewhereID)
+	if err != nil {
+		return fmt.Errorf("CreateAPIKey: %w", err)
+	}
+
+	// A number unique to this run, since lookups are by number
+	phone := fmt.Sprintf("+447%09d", time.Now().UnixNano()%1_000_000_000)
+	if _, err := svc.SetPatientPhone(ctx, f.patient.ID, phone); err != nil {
+		return fmt.Errorf("SetPatientPhone: %w", err)
+	}
+
+	_, err = svc.NextAppointmentByPhone(ctx, key, "not a number")
+	if err := expectErr(err, appointment.ErrInvalidPhone); err != nil {
+		return fmt.Errorf("NextAppointmentByPhone of a bad number: %w", err)
+	}
+	_, err = svc.NextAppointmentByPhone(ctx, key, phone)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("NextAppointmentByPhone with nothing booked: %w", err)
+	}
+
+	// Booked out of order: the lookup finds the soonest slot
+	later, err := f.addSlot(ctx, b, 30*time.Hour)
+	if err != nil {
+		return err
+	}
+	second, err := svc.CreateAppointment(ctx, later.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	first, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+
+	next, err := svc.NextAppointmentByPhone(ctx, lookupOnly, phone)
+	if err != nil {
+		return fmt.Errorf("NextAppointmentByPhone: %w", err)
+	}
+	if next.ID != first.ID || next.Slot == nil || next.Slot.ID != f.slot.ID || next.Clinician == nil || next.Clinician.ID != f.clinician.ID {
+		return fmt.Errorf("expected %s with its slot and clinician, got %+v", first.ID, next)
+	}
+	_, err = svc.NextAppointmentByPhone(ctx, elsewhere, phone)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("NextAppointmentByPhone with a key of another clinic: %w", err)
+	}
+
+	_, err = svc.ConfirmAppointmentByReference(ctx, lookupOnly, first.ID)
+	if err := expectErr(err, appointment.ErrInsufficientScope); err != nil {
+		return fmt.Errorf("ConfirmAppointmentByReference without the scope: %w", err)
+	}
+	_, err = svc.CancelAppointmentByReference(ctx, lookupOnly, first.ID)
+	if err := expectErr(err, appointment.ErrInsufficientScope); err != nil {
+		return fmt.Errorf("CancelAppointmentByReference without the scope: %w", err)
+	}
+	_, err = svc.ConfirmAppointmentByReference(ctx, elsewhere, first.ID)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("ConfirmAppointmentByReference with a key of another clinic: %w", err)
+	}
+	_, err = svc.CancelAppointmentByReference(ctx, elsewhere, second.ID)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("CancelAppointmentByReference with a key of another clinic: %w", err)
+	}
+	if err := expectStatus(ctx, b, first.ID, appointment.StatusPending); err != nil {
+		return err
+	}
+	if err := expectStatus(ctx, b, second.ID, appointment.StatusPending); err != nil {
+		return err
+	}
+
+	confirmed, err := svc.ConfirmAppointmentByReference(ctx, key, first.ID)
+	if err != nil {
+		return fmt.Errorf("ConfirmAppointmentByReference: %w", err)
+	}
+	if confirmed.Status != appointment.StatusConfirmed {
+		return fmt.Errorf("expected %s confirmed, got %s", first.ID, confirmed.Status)
+	}
+	cancelled, err := svc.CancelAppointmentByReference(ctx, key, second.ID)
+	if err != nil {
+		return fmt.Errorf("CancelAppointmentByReference: %w", err)
+	}
+	if cancelled.Status != appointment.StatusCancelled {
+		return fmt.Errorf("expected %s cancelled, got %s", second.ID, cancelled.Status)
+	}
+
+	// A confirmed appointment is still the one the caller has next
+	next, err = svc.NextAppointmentByPhone(ctx, key, phone)
+	if err != nil {
+		return fmt.Errorf("NextAppointmentByPhone after confirming: %w", err)
+	}
+	if next.ID != first.ID || next.Status != appointment.StatusConfirmed {
+		return fmt.Errorf("expected %s confirmed, got %+v", first.ID, next.Appointment)
+	}
+
+	revoked, err := svc.RevokeAPIKey(ctx, k.ID)
+	if err != nil {
+		return fmt.Errorf("RevokeAPIKey: %w", err)
+	}
+	if revoked.RevokedAt == nil {
+		return fmt.Errorf("expected %s revoked, got %+v", k.ID, revoked)
+	}
+	_, err = svc.AuthenticateAPIKey(ctx, secret)
+	if err := expectErr(err, appointment.ErrAPIKeyNotFound); err != nil {
+		return fmt.Errorf("AuthenticateAPIKey of a revoked key: %w", err)
+	}
+	again, err := svc.RevokeAPIKey(ctx, k.ID)
+	if err != nil {
+		return fmt.Errorf("RevokeAPIKey again: %w", err)
+	}
+	if again.RevokedAt == nil || !again.RevokedAt.Equal(*revoked.RevokedAt) {
+		return fmt.Errorf("expected the first revocation %v kept, got %v", revoked.RevokedAt, again.RevokedAt)
+	}
+	_, err = svc.RevokeAPIKey(ctx, uuid.New())
+	return expectErr(err, appointment.ErrAPIKeyNotFound)
+}
@@ -0,0 +1,67 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/clock"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+const lookupCacheTTL = time.Minute
+
+// testLookupCache books through a service that caches patient reads:
+// updates made through it take effect at once, and one made behind its
+// back, as by another instance, once the cached read expires
+func testLookupCache(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	fake := clock.NewFake(time.Now().Truncate(time.Millisecond))
+	cfg := config.Config{AppointmentTTL: holdTTL, LockTTL: 5 * time.Second, LookupCacheTTL: lookupCacheTTL}
+	svc := appointment.NewService(b, redisclient.NewInMemorySlotLocker(), cfg, appointment.WithClock(fake))
+
+	// Each booking takes a slot of its own, an hour after the last
+	next := 48 * time.Hour
+	book := func(what string, want error) error {
+		next += time.Hour
+		slot, err := f.addSlot(ctx, b, next)
+		if err != nil {
+			return err
+		}
+		_, err = svc.CreateAppointment(ctx, slot.ID, f.patient.ID)
+		if err := expectErr(err, want); err != nil {
+			return fmt.Errorf("CreateAppointment %s: %w", what, err)
+		}
+		return nil
+	}
+
+	if err := book("with the patient cached", nil); err != nil {
+		return err
+	}
+	if _, err := svc.DeactivatePatient(ctx, f.patient.ID, "staff-1", "cache"); err != nil {
+		return fmt.Errorf("DeactivatePatient: %w", err)
+	}
+	if err := book("after deactivation", appointment.ErrPatientDeactivated); err != nil {
+		return err
+	}
+	if _, err := svc.ReactivatePatient(ctx, f.patient.ID); err != nil {
+		return fmt.Errorf("ReactivatePatient: %w", err)
+	}
+	if err := book("after reactivation", nil); err != nil {
+		return err
+	}
+
+	if _, err := b.DeactivatePatient(ctx, f.patient.ID, "staff-2", "elsewhere", fake.Now()); err != nil {
+		return fmt.Errorf("DeactivatePatient on the backend: %w", err)
+	}
+	if err := book("before the cached read expires", nil); err != nil {
+		return err
+	}
+	fake.Advance(lookupCacheTTL)
+	return book("after the cached read expires", appointment.ErrPatientDeactivated)
+}
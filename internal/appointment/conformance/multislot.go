@@ -0,0 +1,176 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// backToBackSlots adds n open slots for the fixture clinician, each starting
+// when the previous one ends, two and a half days from now
+func (f *fixture) backToBackSlots(ctx context.Context, b Backend, n int) ([]*appointment.AppointmentSlot, error) {
+	start := time.Now().Add(60 * time.Hour).Truncate(time.Minute)
+	slots := make([]*appointment.AppointmentSlot, n)
+	for i := range slots {
+		slot, err := f.insertSlotAt(ctx, b, start, 1, appointment.SlotOpen)
+		if err != nil {
+			return nil, err
+		}
+		slots[i] = slot
+		start = slot.EndTime
+	}
+	return slots, nil
+}
+
+func testMultiSlotCapacity(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slots, err := f.backToBackSlots(ctx, b, 3)
+	if err != nil {
+		return err
+	}
+	hold := time.Now().Add(10 * time.Minute)
+
+	appt, err := b.CreatePendingAppointment(ctx, slots[0].ID, f.patient.ID, hold)
+	if err != nil {
+		return err
+	}
+	if err := b.AddExtraSlots(ctx, appt.ID, []uuid.UUID{slots[1].ID, slots[2].ID}); err != nil {
+		return fmt.Errorf("AddExtraSlots: %w", err)
+	}
+
+	spanned, err := b.ListAppointmentSlots(ctx, appt.ID)
+	if err != nil {
+		return fmt.Errorf("ListAppointmentSlots: %w", err)
+	}
+	if len(spanned) != len(slots) {
+		return fmt.Errorf("expected %d slots, got %d", len(slots), len(spanned))
+	}
+	for i, slot := range spanned {
+		if slot.ID != slots[i].ID {
+			return fmt.Errorf("slot %d out of order", i+1)
+		}
+	}
+	_, err = b.ListAppointmentSlots(ctx, uuid.New())
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("ListAppointmentSlots of missing appointment: %w", err)
+	}
+	if err := expectBlockers(ctx, b, slots[2], appointment.SlotBlockers{Held: true}); err != nil {
+		return fmt.Errorf("pending span: %w", err)
+	}
+
+	// A hold on the middle slot alone loses to the span once it is confirmed
+	single, err := b.CreatePendingAppointment(ctx, slots[1].ID, f.patient.ID, hold)
+	if err != nil {
+		return err
+	}
+	if _, err := b.ResolvePendingAppointment(ctx, appt.ID, appointment.StatusConfirmed, time.Now()); err != nil {
+		return fmt.Errorf("confirm span: %w", err)
+	}
+	for i, slot := range slots {
+		n, err := b.CountConfirmedAppointmentsForSlot(ctx, slot.ID)
+		if err != nil {
+			return fmt.Errorf("CountConfirmedAppointmentsForSlot: %w", err)
+		}
+		if n != 1 {
+			return fmt.Errorf("slot %d: expected 1 confirmed appointment, got %d", i+1, n)
+		}
+	}
+	_, err = b.ResolvePendingAppointment(ctx, single.ID, appointment.StatusConfirmed, time.Now())
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("confirm inside a confirmed span: %w", err)
+	}
+	if err := expectBlockers(ctx, b, slots[1], appointment.SlotBlockers{Confirmed: true, Held: true}); err != nil {
+		return fmt.Errorf("confirmed span over a hold: %w", err)
+	}
+	if err := expectBlockers(ctx, b, slots[2], appointment.SlotBlockers{Confirmed: true}); err != nil {
+		return fmt.Errorf("confirmed span: %w", err)
+	}
+
+	// Cancelling the span frees every slot
+	if _, err := b.UpdateAppointmentStatus(ctx, appt.ID, appointment.StatusConfirmed, appointment.StatusCancelled); err != nil {
+		return fmt.Errorf("cancel span: %w", err)
+	}
+	if _, err := b.ResolvePendingAppointment(ctx, single.ID, appointment.StatusConfirmed, time.Now()); err != nil {
+		return fmt.Errorf("confirm after the span was cancelled: %w", err)
+	}
+	if err := expectBlockers(ctx, b, slots[2], appointment.SlotBlockers{}); err != nil {
+		return fmt.Errorf("cancelled span: %w", err)
+	}
+	return nil
+}
+
+func expectBlockers(ctx context.Context, b Backend, slot *appointment.AppointmentSlot, want appointment.SlotBlockers) error {
+	got, err := b.HasBlockingAppointment(ctx, slot.ID)
+	if err != nil {
+		return fmt.Errorf("HasBlockingAppointment: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("expected blockers %+v, got %+v", want, got)
+	}
+	return nil
+}
+
+// testMultiSlotWorkflow books a procedure over back-to-back slots through
+// the service and checks it blocks and then frees the slots it spans
+func testMultiSlotWorkflow(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slots, err := f.backToBackSlots(ctx, b, 3)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	_, err = svc.Book(ctx, appointment.BookingRequest{SlotID: slots[0].ID, PatientID: f.patient.ID})
+	if err := expectErr(err, appointment.ErrInvalidSlotCount); err != nil {
+		return fmt.Errorf("zero slots: %w", err)
+	}
+	_, err = svc.Book(ctx, appointment.BookingRequest{SlotID: slots[0].ID, PatientID: f.patient.ID, SlotCount: 4})
+	if err := expectErr(err, appointment.ErrSpanSlotUnavailable); err != nil {
+		return fmt.Errorf("span past the last slot: %w", err)
+	}
+	if booked, err := b.CountConfirmedAppointmentsForSlot(ctx, slots[0].ID); err != nil || booked != 0 {
+		return fmt.Errorf("failed span left %d confirmed appointments, %v", booked, err)
+	}
+
+	booking, err := svc.Book(ctx, appointment.BookingRequest{SlotID: slots[0].ID, PatientID: f.patient.ID, SlotCount: 3})
+	if err != nil {
+		return fmt.Errorf("Book: %w", err)
+	}
+	if !booking.StartTime().Equal(slots[0].StartTime) || !booking.EndTime().Equal(slots[2].EndTime) {
+		return fmt.Errorf("expected %s to %s, got %s to %s",
+			slots[0].StartTime, slots[2].EndTime, booking.StartTime(), booking.EndTime())
+	}
+	if _, err := svc.ConfirmAppointment(ctx, booking.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+
+	detail, err := svc.GetAppointment(ctx, booking.ID, appointment.DetailFields{Slot: true})
+	if err != nil {
+		return fmt.Errorf("GetAppointment: %w", err)
+	}
+	if len(detail.Span) != len(slots) || detail.Slot == nil || detail.Slot.ID != slots[0].ID {
+		return fmt.Errorf("expected the detail to start in slot 1 and span %d slots, got %d", len(slots), len(detail.Span))
+	}
+
+	_, err = svc.CreateAppointment(ctx, slots[2].ID, f.patient.ID)
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("book the last slot of a confirmed span: %w", err)
+	}
+	if _, err := svc.CancelAppointment(ctx, booking.ID, "conformance", nil); err != nil {
+		return fmt.Errorf("CancelAppointment: %w", err)
+	}
+	if _, err := svc.CreateAppointment(ctx, slots[2].ID, f.patient.ID); err != nil {
+		return fmt.Errorf("book the last slot after cancelling: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testAppointmentNotes books with a reason for the visit and adds notes,
+// and checks both come back with the appointment, notes in the order they
+// were written and only alongside the patient
+func testAppointmentNotes(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	blank, long := " ", strings.Repeat("x", appointment.MaxReasonLength+1)
+	for _, reason := range []*string{&blank, &long} {
+		_, err := svc.Book(ctx, appointment.BookingRequest{SlotID: f.slot.ID, PatientID: f.patient.ID, SlotCount: 1, Reason: reason})
+		if err := expectErr(err, appointment.ErrInvalidReason); err != nil {
+			return fmt.Errorf("Book with a %d-byte reason: %w", len(*reason), err)
+		}
+	}
+	reason := " Persistent cough "
+	booked, err := svc.Book(ctx, appointment.BookingRequest{SlotID: f.slot.ID, PatientID: f.patient.ID, SlotCount: 1, Reason: &reason})
+	if err != nil {
+		return fmt.Errorf("Book with a reason: %w", err)
+	}
+	if booked.Reason == nil || *booked.Reason != "Persistent cough" {
+		return fmt.Errorf("expected the trimmed reason back, got %v", booked.Reason)
+	}
+
+	for _, c := range []struct {
+		what         string
+		id           uuid.UUID
+		author, body string
+		want         error
+	}{
+		{"no body", booked.ID, "nurse-1", " ", appointment.ErrInvalidNote},
+		{"a body too long", booked.ID, "nurse-1", strings.Repeat("x", appointment.MaxNoteLength+1), appointment.ErrInvalidNote},
+		{"no author", booked.ID, "", "Called to confirm", appointment.ErrInvalidNote},
+		{"an unknown appointment", uuid.New(), "nurse-1", "Called to confirm", appointment.ErrAppointmentNotFound},
+	} {
+		_, err := svc.AddNote(ctx, c.id, c.author, c.body)
+		if err := expectErr(err, c.want); err != nil {
+			return fmt.Errorf("AddNote with %s: %w", c.what, err)
+		}
+	}
+
+	// Read once first, so a stale read would show without the notes
+	if _, err := svc.GetAppointment(ctx, booked.ID, appointment.AllDetailFields); err != nil {
+		return fmt.Errorf("GetAppointment: %w", err)
+	}
+	first, err := svc.AddNote(ctx, booked.ID, "nurse-1", " Called to confirm ")
+	if err != nil {
+		return fmt.Errorf("AddNote: %w", err)
+	}
+	if first.Body != "Called to confirm" || first.Author != "nurse-1" || first.AppointmentID != booked.ID {
+		return fmt.Errorf("expected the trimmed note by nurse-1, got %+v", first)
+	}
+	second, err := svc.AddNote(ctx, booked.ID, "dr-2", "Bring previous x-rays")
+	if err != nil {
+		return fmt.Errorf("AddNote: %w", err)
+	}
+
+	detail, err := svc.GetAppointment(ctx, booked.ID, appointment.AllDetailFields)
+	if err != nil {
+		return fmt.Errorf("GetAppointment: %w", err)
+	}
+	if detail.Reason == nil || *detail.Reason != "Persistent cough" {
+		return fmt.Errorf("expected the reason with the appointment, got %v", detail.Reason)
+	}
+	if len(detail.Notes) != 2 || detail.Notes[0].ID != first.ID || detail.Notes[1].ID != second.ID {
+		return fmt.Errorf("expected notes %s then %s, got %+v", first.ID, second.ID, detail.Notes)
+	}
+
+	lean, err := svc.GetAppointment(ctx, booked.ID, appointment.DetailFields{Slot: true})
+	if err != nil {
+		return fmt.Errorf("GetAppointment without the patient: %w", err)
+	}
+	if lean.Reason == nil || len(lean.Notes) != 0 {
+		return fmt.Errorf("expected the reason but no notes without the patient, got %v and %+v", lean.Reason, lean.Notes)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testPatientCRUD registers, reads and updates patients through the
+// service: emails are checked and lower-cased, and one email belongs to one
+// patient whichever way it is written
+func testPatientCRUD(ctx context.Context, b Backend) error {
+	svc, _ := timeTravelService(b, time.Now())
+	local := "crud+" + uuid.NewString()
+	email := local + "@example.com"
+	written := "  " + local + "@EXAMPLE.com"
+	malformed, displayName := "not-an-email", "Pat <"+email+">"
+
+	for _, c := range []struct {
+		what string
+		np   appointment.NewPatient
+	}{
+		{"no name", appointment.NewPatient{Name: " "}},
+		{"a malformed email", appointment.NewPatient{Name: "Pat", Email: &malformed}},
+		{"a display name", appointment.NewPatient{Name: "Pat", Email: &displayName}},
+	} {
+		_, err := svc.CreatePatient(ctx, c.np)
+		if err := expectErr(err, appointment.ErrInvalidPatient); err != nil {
+			return fmt.Errorf("CreatePatient with %s: %w", c.what, err)
+		}
+	}
+
+	p, err := svc.CreatePatient(ctx, appointment.NewPatient{Name: " Pat Conformance ", Email: &written})
+	if err != nil {
+		return fmt.Errorf("CreatePatient: %w", err)
+	}
+	if p.Name != "Pat Conformance" || p.Email == nil || *p.Email != email {
+		return fmt.Errorf("expected the name trimmed and the email %s, got %+v", email, p)
+	}
+	got, err := svc.GetPatient(ctx, p.ID)
+	if err != nil {
+		return fmt.Errorf("GetPatient: %w", err)
+	}
+	if got.Name != p.Name || got.Email == nil || *got.Email != email {
+		return fmt.Errorf("expected the created patient back, got %+v", got)
+	}
+	_, err = svc.CreatePatient(ctx, appointment.NewPatient{Name: "Someone Else", Email: &email})
+	if err := expectErr(err, appointment.ErrPatientEmailTaken); err != nil {
+		return fmt.Errorf("CreatePatient with a taken email: %w", err)
+	}
+	noEmail, err := svc.CreatePatient(ctx, appointment.NewPatient{Name: "No Email"})
+	if err != nil {
+		return fmt.Errorf("CreatePatient without an email: %w", err)
+	}
+	if noEmail.Email != nil {
+		return fmt.Errorf("expected no email, got %s", *noEmail.Email)
+	}
+
+	_, err = svc.UpdatePatient(ctx, noEmail.ID, appointment.PatientUpdate{Email: &written})
+	if err := expectErr(err, appointment.ErrPatientEmailTaken); err != nil {
+		return fmt.Errorf("UpdatePatient to a taken email: %w", err)
+	}
+	_, err = svc.UpdatePatient(ctx, noEmail.ID, appointment.PatientUpdate{Email: &malformed})
+	if err := expectErr(err, appointment.ErrInvalidPatient); err != nil {
+		return fmt.Errorf("UpdatePatient to a malformed email: %w", err)
+	}
+	name := "Pat Renamed"
+	_, err = svc.UpdatePatient(ctx, uuid.New(), appointment.PatientUpdate{Name: &name})
+	if err := expectErr(err, appointment.ErrPatientNotFound); err != nil {
+		return fmt.Errorf("UpdatePatient of an unknown patient: %w", err)
+	}
+
+	// Changing only the name keeps the email
+	renamed, err := svc.UpdatePatient(ctx, p.ID, appointment.PatientUpdate{Name: &name})
+	if err != nil {
+		return fmt.Errorf("UpdatePatient name: %w", err)
+	}
+	if renamed.Name != name || renamed.Email == nil || *renamed.Email != email {
+		return fmt.Errorf("expected only the name changed, got %+v", renamed)
+	}
+	// The email freed by a change can be taken by another patient
+	moved := "moved+" + uuid.NewString() + "@example.com"
+	if _, err := svc.UpdatePatient(ctx, p.ID, appointment.PatientUpdate{Email: &moved}); err != nil {
+		return fmt.Errorf("UpdatePatient email: %w", err)
+	}
+	taken, err := svc.UpdatePatient(ctx, noEmail.ID, appointment.PatientUpdate{Email: &email})
+	if err != nil {
+		return fmt.Errorf("UpdatePatient to a freed email: %w", err)
+	}
+	if taken.Name != "No Email" || taken.Email == nil || *taken.Email != email {
+		return fmt.Errorf("expected the freed email taken, got %+v", taken)
+	}
+	return nil
+}
+
+// testPatientSearch finds patients by a part of their name or email,
+// ignoring case, pages through them by name and leaves deactivated patients
+// out unless asked
+func testPatientSearch(ctx context.Context, b Backend) error {
+	svc, _ := timeTravelService(b, time.Now())
+	// A marker no other case's patients contain, so the search sees only
+	// those added here
+	marker := "qz" + uuid.NewString()[:8]
+
+	create := func(name, email string) (*appointment.Patient, error) {
+		np := appointment.NewPatient{Name: name}
+		if email != "" {
+			np.Email = &email
+		}
+		p, err := svc.CreatePatient(ctx, np)
+		if err != nil {
+			return nil, fmt.Errorf("CreatePatient %s: %w", name, err)
+		}
+		return p, nil
+	}
+	ann, err := create("Ann "+strings.ToUpper(marker), "")
+	if err != nil {
+		return err
+	}
+	byEmail, err := create("Zoe Someone", "zoe."+marker+"@example.com")
+	if err != nil {
+		return err
+	}
+	bob1, err := create("Bob "+marker, "")
+	if err != nil {
+		return err
+	}
+	bob2, err := create("bob "+marker, "")
+	if err != nil {
+		return err
+	}
+	gone, err := create("Cy "+marker, "")
+	if err != nil {
+		return err
+	}
+	if _, err := svc.DeactivatePatient(ctx, gone.ID, "conformance", "moved away"); err != nil {
+		return fmt.Errorf("DeactivatePatient: %w", err)
+	}
+
+	// Bob and bob tie on name, so their ids decide
+	bobs := []uuid.UUID{bob1.ID, bob2.ID}
+	if bobs[1].String() < bobs[0].String() {
+		bobs[0], bobs[1] = bobs[1], bobs[0]
+	}
+	want := []uuid.UUID{ann.ID, bobs[0], bobs[1], byEmail.ID}
+	var got []uuid.UUID
+	q := appointment.PatientSearch{Query: " " + strings.ToUpper(marker[:6]) + " ", Limit: 1}
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			return fmt.Errorf("expected %d pages, got more", len(want))
+		}
+		page, err := svc.SearchPatients(ctx, q)
+		if err != nil {
+			return fmt.Errorf("SearchPatients page %d: %w", pages+1, err)
+		}
+		for _, p := range page.Patients {
+			got = append(got, p.ID)
+		}
+		if page.NextToken == "" {
+			break
+		}
+		q.Token = page.NextToken
+	}
+	if !slices.Equal(got, want) {
+		return fmt.Errorf("expected %v by name, got %v", want, got)
+	}
+
+	all, err := svc.SearchPatients(ctx, appointment.PatientSearch{Query: marker, IncludeDeactivated: true, Limit: 10})
+	if err != nil {
+		return fmt.Errorf("SearchPatients including deactivated: %w", err)
+	}
+	if len(all.Patients) != 5 || all.Patients[3].ID != gone.ID {
+		return fmt.Errorf("expected the deactivated patient fourth of 5, got %+v", all.Patients)
+	}
+
+	// LIKE wildcards in the query match only themselves
+	none, err := svc.SearchPatients(ctx, appointment.PatientSearch{Query: marker[:2] + "%" + marker[3:], Limit: 10})
+	if err != nil {
+		return fmt.Errorf("SearchPatients with a wildcard: %w", err)
+	}
+	if len(none.Patients) != 0 {
+		return fmt.Errorf("expected a wildcard to match nothing, got %+v", none.Patients)
+	}
+
+	_, err = svc.SearchPatients(ctx, appointment.PatientSearch{Query: " q "})
+	if err := expectErr(err, appointment.ErrInvalidPatientSearch); err != nil {
+		return fmt.Errorf("SearchPatients with a short query: %w", err)
+	}
+	first, err := svc.SearchPatients(ctx, appointment.PatientSearch{Query: marker, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("SearchPatients: %w", err)
+	}
+	_, err = svc.SearchPatients(ctx, appointment.PatientSearch{Query: marker, IncludeDeactivated: true, Token: first.NextToken})
+	if err := expectErr(err, appointment.ErrInvalidPageToken); err != nil {
+		return fmt.Errorf("SearchPatients with another search's token: %w", err)
+	}
+	return nil
+}
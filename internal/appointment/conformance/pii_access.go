@@ -0,0 +1,148 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/clock"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+func testPIIAccessRoundTrip(ctx context.Context, b Backend) error {
+	// Actors are unique to the run so earlier runs do not match the filters
+	actor := "staff-" + uuid.NewString()
+	other := "staff-" + uuid.NewString()
+	patient, otherPatient := uuid.New(), uuid.New()
+	reason := "chart review"
+	base := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+
+	records := []appointment.PIIAccess{
+		{Actor: actor, ActorKind: appointment.ActorStaff, Reason: &reason, PatientID: patient, Endpoint: "GET /appointments/{id}", RequestID: "req-1", AccessedAt: base},
+		{Actor: actor, ActorKind: appointment.ActorStaff, PatientID: otherPatient, Endpoint: "GET /patients/{id}/timeline", AccessedAt: base.Add(time.Minute)},
+		{Actor: other, ActorKind: appointment.ActorAdmin, PatientID: patient, Endpoint: "POST /appointments/batch-get", AccessedAt: base.Add(2 * time.Minute)},
+	}
+	for i := range records {
+		records[i].ID = uuid.New()
+	}
+	if err := b.InsertPIIAccess(ctx, records); err != nil {
+		return fmt.Errorf("InsertPIIAccess: %w", err)
+	}
+	if err := b.InsertPIIAccess(ctx, nil); err != nil {
+		return fmt.Errorf("InsertPIIAccess of nothing: %w", err)
+	}
+
+	window := appointment.PIIAccessFilter{From: base, To: base.Add(time.Hour), Limit: 10}
+
+	byPatient := window
+	byPatient.PatientID = &patient
+	got, err := b.ListPIIAccess(ctx, byPatient)
+	if err != nil {
+		return fmt.Errorf("ListPIIAccess by patient: %w", err)
+	}
+	if len(got) != 2 || got[0].ID != records[2].ID || got[1].ID != records[0].ID {
+		return fmt.Errorf("expected the patient's 2 records newest first, got %+v", got)
+	}
+	first := got[1]
+	if first.Actor != actor || first.ActorKind != appointment.ActorStaff || first.Reason == nil || *first.Reason != reason ||
+		first.Endpoint != records[0].Endpoint || first.RequestID != "req-1" || !first.AccessedAt.Equal(base) {
+		return fmt.Errorf("access record did not round trip: %+v", first)
+	}
+	if got[0].Reason != nil || got[0].ActorKind != appointment.ActorAdmin {
+		return fmt.Errorf("unexpected admin record %+v", got[0])
+	}
+
+	byActor := window
+	byActor.Actor = actor
+	got, err = b.ListPIIAccess(ctx, byActor)
+	if err != nil {
+		return fmt.Errorf("ListPIIAccess by actor: %w", err)
+	}
+	if len(got) != 2 || got[0].PatientID != otherPatient || got[1].PatientID != patient {
+		return fmt.Errorf("expected the actor's 2 records, got %+v", got)
+	}
+
+	byBoth := byActor
+	byBoth.PatientID = &patient
+	byBoth.From = base.Add(time.Second)
+	got, err = b.ListPIIAccess(ctx, byBoth)
+	if err != nil {
+		return fmt.Errorf("ListPIIAccess by actor and patient: %w", err)
+	}
+	if len(got) != 0 {
+		return fmt.Errorf("expected the range to leave out the only match, got %+v", got)
+	}
+
+	byPatient.Limit = 1
+	got, err = b.ListPIIAccess(ctx, byPatient)
+	if err != nil {
+		return fmt.Errorf("ListPIIAccess with a limit: %w", err)
+	}
+	if len(got) != 1 || got[0].ID != records[2].ID {
+		return fmt.Errorf("expected the newest record only, got %+v", got)
+	}
+	return nil
+}
+
+// testPIIAccessRecording records access through the service and checks
+// each patient is recorded once per response
+func testPIIAccessRecording(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	clk := clock.NewFake(time.Now().Truncate(time.Millisecond))
+	cfg := config.Config{AppointmentTTL: holdTTL, LockTTL: 5 * time.Second}
+	svc := appointment.NewService(b, redisclient.NewInMemorySlotLocker(), cfg, appointment.WithClock(clk))
+
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+	actor := appointment.Actor{ID: "staff-" + uuid.NewString(), Kind: appointment.ActorStaff}
+	filter := appointment.PIIAccessFilter{Actor: actor.ID, From: clk.Now(), To: clk.Now().Add(time.Hour), Limit: 10}
+
+	other := uuid.New()
+	if err := svc.RecordPIIAccess(ctx, actor, "POST /appointments/batch-get", f.patient.ID, other, f.patient.ID); err != nil {
+		return fmt.Errorf("RecordPIIAccess: %w", err)
+	}
+	if err := svc.RecordPIIAccess(ctx, actor, "GET /appointments"); err != nil {
+		return fmt.Errorf("RecordPIIAccess of no patients: %w", err)
+	}
+	got, err := svc.ListPIIAccess(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("ListPIIAccess: %w", err)
+	}
+	if len(got) != 2 {
+		return fmt.Errorf("expected one record per patient, got %d", len(got))
+	}
+
+	clk.Advance(time.Minute)
+	err = svc.RecordAppointmentPIIAccess(ctx, actor, "GET /appointments/{id}/intake", uuid.New())
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("RecordAppointmentPIIAccess for a missing appointment: %w", err)
+	}
+	actor.Reason = "covering for Dr. Ames"
+	if err := svc.RecordAppointmentPIIAccess(ctx, actor, "GET /appointments/{id}/intake", appt.ID); err != nil {
+		return fmt.Errorf("RecordAppointmentPIIAccess: %w", err)
+	}
+
+	filter.Limit = 0
+	got, err = svc.ListPIIAccess(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("ListPIIAccess: %w", err)
+	}
+	if len(got) != 1 {
+		return fmt.Errorf("expected the limit raised to 1, got %d records", len(got))
+	}
+	latest := got[0]
+	if latest.PatientID != f.patient.ID || latest.Endpoint != "GET /appointments/{id}/intake" ||
+		latest.Reason == nil || *latest.Reason != actor.Reason || !latest.AccessedAt.Equal(clk.Now()) {
+		return fmt.Errorf("unexpected access record %+v", latest)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+func testPatientConflicts(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slots, err := f.backToBackSlots(ctx, b, 2)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	single, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+	span, err := svc.Book(ctx, appointment.BookingRequest{SlotID: slots[0].ID, PatientID: f.patient.ID, SlotCount: 2})
+	if err != nil {
+		return fmt.Errorf("Book: %w", err)
+	}
+
+	got, err := b.ListPatientConflicts(ctx, f.patient.ID, f.slot.StartTime, f.slot.EndTime)
+	if err != nil {
+		return fmt.Errorf("ListPatientConflicts: %w", err)
+	}
+	if len(got) != 1 || got[0].ID != single.ID || got[0].Status != appointment.StatusPending {
+		return fmt.Errorf("expected the single slot appointment, got %+v", got)
+	}
+
+	// The range only meets the second slot of the span
+	got, err = b.ListPatientConflicts(ctx, f.patient.ID, slots[1].StartTime.Add(10*time.Minute), slots[1].EndTime.Add(time.Hour))
+	if err != nil {
+		return fmt.Errorf("ListPatientConflicts over a later slot: %w", err)
+	}
+	if len(got) != 1 || got[0].ID != span.ID {
+		return fmt.Errorf("expected the multi-slot appointment, got %+v", got)
+	}
+
+	for name, r := range map[string][2]time.Time{
+		"touching the slot's end":   {f.slot.EndTime, f.slot.EndTime.Add(time.Hour)},
+		"touching the slot's start": {f.slot.StartTime.Add(-time.Hour), f.slot.StartTime},
+	} {
+		got, err := b.ListPatientConflicts(ctx, f.patient.ID, r[0], r[1])
+		if err != nil {
+			return fmt.Errorf("ListPatientConflicts %s: %w", name, err)
+		}
+		if len(got) != 0 {
+			return fmt.Errorf("expected no conflicts %s, got %+v", name, got)
+		}
+	}
+	got, err = b.ListPatientConflicts(ctx, uuid.New(), f.slot.StartTime, f.slot.EndTime)
+	if err != nil {
+		return fmt.Errorf("ListPatientConflicts of another patient: %w", err)
+	}
+	if len(got) != 0 {
+		return fmt.Errorf("expected no conflicts for another patient, got %+v", got)
+	}
+
+	if _, err := b.UpdateAppointmentStatus(ctx, single.ID, appointment.StatusPending, appointment.StatusCancelled); err != nil {
+		return fmt.Errorf("UpdateAppointmentStatus: %w", err)
+	}
+	got, err = b.ListPatientConflicts(ctx, f.patient.ID, f.slot.StartTime, f.slot.EndTime)
+	if err != nil {
+		return fmt.Errorf("ListPatientConflicts after cancelling: %w", err)
+	}
+	if len(got) != 0 {
+		return fmt.Errorf("expected a cancelled appointment not to conflict, got %+v", got)
+	}
+	return nil
+}
+
+// testBookingPrecheck checks a precheck fails as booking would, reports
+// conflicts and holds nothing
+func testBookingPrecheck(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+	req := appointment.BookingRequest{SlotID: f.slot.ID, PatientID: f.patient.ID, SlotCount: 1}
+
+	check, err := svc.PrecheckBooking(ctx, req)
+	if err != nil {
+		return fmt.Errorf("PrecheckBooking: %w", err)
+	}
+	if len(check.Slots) != 1 || check.Slots[0].ID != f.slot.ID || check.Remaining != 1 ||
+		check.RequiresApproval || len(check.Conflicts) != 0 {
+		return fmt.Errorf("unexpected precheck %+v", check)
+	}
+	// Nothing was held, so the same slot still books
+	booked, err := svc.Book(ctx, req)
+	if err != nil {
+		return fmt.Errorf("Book after a precheck: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, booked.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+
+	for name, tc := range map[string]struct {
+		req  appointment.BookingRequest
+		want error
+	}{
+		"full slot":       {req, appointment.ErrSlotAlreadyBooked},
+		"missing patient": {appointment.BookingRequest{SlotID: f.slot.ID, PatientID: uuid.New(), SlotCount: 1}, appointment.ErrPatientNotFound},
+		"missing slot":    {appointment.BookingRequest{SlotID: uuid.New(), PatientID: f.patient.ID, SlotCount: 1}, appointment.ErrSlotNotFound},
+		"no slots":        {appointment.BookingRequest{SlotID: f.slot.ID, PatientID: f.patient.ID}, appointment.ErrInvalidSlotCount},
+	} {
+		_, err := svc.PrecheckBooking(ctx, tc.req)
+		if err := expectErr(err, tc.want); err != nil {
+			return fmt.Errorf("PrecheckBooking, %s: %w", name, err)
+		}
+	}
+	blocked, err := f.insertSlot(ctx, b, 30*time.Hour, 1, appointment.SlotBlocked)
+	if err != nil {
+		return err
+	}
+	_, err = svc.PrecheckBooking(ctx, appointment.BookingRequest{SlotID: blocked.ID, PatientID: f.patient.ID, SlotCount: 1})
+	if err := expectErr(err, appointment.ErrSlotNotOpen); err != nil {
+		return fmt.Errorf("PrecheckBooking of a blocked slot: %w", err)
+	}
+
+	// Another clinician's slot at the same time conflicts but is bookable
+	if _, err := f.withSpecialty(ctx, b); err != nil {
+		return err
+	}
+	shared, err := f.insertSlotAt(ctx, b, f.slot.StartTime.Add(time.Hour), 3, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	check, err = svc.PrecheckBooking(ctx, appointment.BookingRequest{SlotID: f.slot.ID, PatientID: f.patient.ID, SlotCount: 1})
+	if err != nil {
+		return fmt.Errorf("PrecheckBooking of a conflicting slot: %w", err)
+	}
+	if len(check.Conflicts) != 1 || check.Conflicts[0].ID != booked.ID || check.Conflicts[0].Status != appointment.StatusConfirmed {
+		return fmt.Errorf("expected the confirmed appointment as a conflict, got %+v", check.Conflicts)
+	}
+
+	other, err := svc.Book(ctx, appointment.BookingRequest{SlotID: shared.ID, PatientID: f.patient.ID, SlotCount: 1})
+	if err != nil {
+		return fmt.Errorf("Book: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, other.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+	check, err = svc.PrecheckBooking(ctx, appointment.BookingRequest{SlotID: shared.ID, PatientID: f.patient.ID, SlotCount: 1})
+	if err != nil {
+		return fmt.Errorf("PrecheckBooking of a shared slot: %w", err)
+	}
+	if check.Remaining != 2 {
+		return fmt.Errorf("expected 2 places left, got %d", check.Remaining)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testSlotReassignment moves a slot to a covering clinician and checks its
+// appointments move and are told, and that clinicians of another specialty,
+// with an overlapping slot, or slots of a multi-slot appointment are refused
+func testSlotReassignment(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	locum := appointment.Clinician{ID: uuid.New(), Name: "Dr. Locum", Specialty: f.clinician.Specialty, ClinicID: &f.clinic.ID}
+	if err := b.InsertClinician(ctx, locum); err != nil {
+		return err
+	}
+	other := "Radiology"
+	radiologist := appointment.Clinician{ID: uuid.New(), Name: "Dr. Other", Specialty: &other, ClinicID: &f.clinic.ID}
+	if err := b.InsertClinician(ctx, radiologist); err != nil {
+		return err
+	}
+
+	slot, err := f.addSlotWithCapacity(ctx, b, 30*time.Hour, 2)
+	if err != nil {
+		return err
+	}
+	var booked []*appointment.Appointment
+	for i := 0; i < 2; i++ {
+		appt, err := b.CreatePendingAppointment(ctx, slot.ID, f.patient.ID, time.Now().Add(10*time.Minute))
+		if err != nil {
+			return fmt.Errorf("CreatePendingAppointment: %w", err)
+		}
+		booked = append(booked, appt)
+	}
+	if _, err := b.UpdateAppointmentStatus(ctx, booked[1].ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("UpdateAppointmentStatus: %w", err)
+	}
+
+	_, err = svc.ReassignSlot(ctx, slot.ID, radiologist.ID)
+	if err := expectErr(err, appointment.ErrClinicianIncompatible); err != nil {
+		return fmt.Errorf("ReassignSlot to another specialty: %w", err)
+	}
+	_, err = svc.ReassignSlot(ctx, slot.ID, uuid.New())
+	if err := expectErr(err, appointment.ErrClinicianNotFound); err != nil {
+		return fmt.Errorf("ReassignSlot to an unknown clinician: %w", err)
+	}
+
+	// The locum is busy for part of another slot
+	busy, err := f.addSlot(ctx, b, 32*time.Hour)
+	if err != nil {
+		return err
+	}
+	overlap := appointment.AppointmentSlot{
+		ID: uuid.New(), PractitionerID: locum.ID,
+		StartTime: busy.StartTime.Add(15 * time.Minute), EndTime: busy.EndTime.Add(15 * time.Minute),
+		Status: appointment.SlotOpen, Capacity: 1,
+	}
+	if err := b.InsertSlot(ctx, overlap); err != nil {
+		return err
+	}
+	_, err = svc.ReassignSlot(ctx, busy.ID, locum.ID)
+	if err := expectErr(err, appointment.ErrClinicianUnavailable); err != nil {
+		return fmt.Errorf("ReassignSlot to a busy clinician: %w", err)
+	}
+
+	span, err := f.backToBackSlots(ctx, b, 2)
+	if err != nil {
+		return err
+	}
+	spanning, err := b.CreatePendingAppointment(ctx, span[0].ID, f.patient.ID, time.Now().Add(10*time.Minute))
+	if err != nil {
+		return fmt.Errorf("CreatePendingAppointment: %w", err)
+	}
+	if err := b.AddExtraSlots(ctx, spanning.ID, []uuid.UUID{span[1].ID}); err != nil {
+		return fmt.Errorf("AddExtraSlots: %w", err)
+	}
+	_, err = svc.ReassignSlot(ctx, span[1].ID, locum.ID)
+	if err := expectErr(err, appointment.ErrSlotInMultiSlotAppointment); err != nil {
+		return fmt.Errorf("ReassignSlot of a later slot of an appointment: %w", err)
+	}
+
+	res, err := svc.ReassignSlot(ctx, slot.ID, locum.ID)
+	if err != nil {
+		return fmt.Errorf("ReassignSlot: %w", err)
+	}
+	if res.Slot.PractitionerID != locum.ID || res.PreviousClinicianID != f.clinician.ID || len(res.Appointments) != 2 {
+		return fmt.Errorf("expected the slot and both appointments moved to the locum, got %+v", res)
+	}
+	stored, err := b.GetSlotByID(ctx, slot.ID)
+	if err != nil {
+		return fmt.Errorf("GetSlotByID: %w", err)
+	}
+	if stored.PractitionerID != locum.ID {
+		return fmt.Errorf("expected the slot stored with the locum, got %s", stored.PractitionerID)
+	}
+	counts, err := countEvents(ctx, b, f.patient.ID, appointment.EventAppointmentClinicianChanged)
+	if err != nil {
+		return err
+	}
+	for _, appt := range booked {
+		if counts[appt.ID] != 1 {
+			return fmt.Errorf("expected one clinician change event for %s, got %d", appt.ID, counts[appt.ID])
+		}
+	}
+
+	_, err = svc.ReassignSlot(ctx, slot.ID, locum.ID)
+	if err := expectErr(err, appointment.ErrInvalidReassignment); err != nil {
+		return fmt.Errorf("ReassignSlot to its own clinician: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testReschedule moves appointments to other slots and checks the
+// replacement keeps the status, the old appointment is cancelled and both
+// are told, and that a full slot, the same slot or a slot of another clinic
+// leave the appointment where it was
+func testReschedule(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	held, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+
+	_, err = svc.RescheduleAppointment(ctx, held.ID, f.slot.ID)
+	if err := expectErr(err, appointment.ErrInvalidReschedule); err != nil {
+		return fmt.Errorf("RescheduleAppointment to its own slot: %w", err)
+	}
+	_, err = svc.RescheduleAppointment(ctx, uuid.New(), f.slot.ID)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("RescheduleAppointment of an unknown appointment: %w", err)
+	}
+
+	// A hold moves with its deadline
+	later, err := f.addSlot(ctx, b, 26*time.Hour)
+	if err != nil {
+		return err
+	}
+	res, err := svc.RescheduleAppointment(ctx, held.ID, later.ID)
+	if err != nil {
+		return fmt.Errorf("RescheduleAppointment of a hold: %w", err)
+	}
+	moved := res.Appointment
+	if moved.ID == held.ID || moved.SlotID != later.ID || moved.Status != appointment.StatusPending {
+		return fmt.Errorf("expected a new pending appointment in %s, got %+v", later.ID, moved)
+	}
+	if moved.ExpiresAt == nil || held.ExpiresAt == nil || !moved.ExpiresAt.Equal(*held.ExpiresAt) {
+		return fmt.Errorf("expected the hold's deadline %v kept, got %v", held.ExpiresAt, moved.ExpiresAt)
+	}
+	if res.Previous.ID != held.ID || res.Previous.Status != appointment.StatusCancelled {
+		return fmt.Errorf("expected %s returned as cancelled, got %+v", held.ID, res.Previous)
+	}
+	if err := expectStatus(ctx, b, held.ID, appointment.StatusCancelled); err != nil {
+		return err
+	}
+
+	// A confirmed appointment is confirmed again, and the slot it left is
+	// free for someone else
+	if _, err := b.UpdateAppointmentStatus(ctx, moved.ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("UpdateAppointmentStatus: %w", err)
+	}
+	third, err := f.addSlot(ctx, b, 28*time.Hour)
+	if err != nil {
+		return err
+	}
+	res, err = svc.RescheduleAppointment(ctx, moved.ID, third.ID)
+	if err != nil {
+		return fmt.Errorf("RescheduleAppointment of a confirmed appointment: %w", err)
+	}
+	confirmed := res.Appointment
+	if confirmed.Status != appointment.StatusConfirmed || confirmed.SlotID != third.ID {
+		return fmt.Errorf("expected a confirmed appointment in %s, got %+v", third.ID, confirmed)
+	}
+	if err := expectStatus(ctx, b, moved.ID, appointment.StatusCancelled); err != nil {
+		return err
+	}
+	other := appointment.Patient{ID: uuid.New(), Name: "Reschedule Patient"}
+	if err := b.InsertPatient(ctx, other); err != nil {
+		return err
+	}
+	taken, err := svc.CreateAppointment(ctx, later.ID, other.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment in the slot moved out of: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, taken.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+
+	// The slot is full again, so the appointment stays
+	_, err = svc.RescheduleAppointment(ctx, confirmed.ID, later.ID)
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("RescheduleAppointment to a full slot: %w", err)
+	}
+	if err := expectStatus(ctx, b, confirmed.ID, appointment.StatusConfirmed); err != nil {
+		return err
+	}
+
+	elsewhere := appointment.Clinic{ID: uuid.New(), Name: "Other Clinic"}
+	if err := b.InsertClinic(ctx, elsewhere); err != nil {
+		return err
+	}
+	away := &fixture{clinician: appointment.Clinician{ID: uuid.New(), Name: "Dr. Away", ClinicID: &elsewhere.ID}}
+	if err := b.InsertClinician(ctx, away.clinician); err != nil {
+		return err
+	}
+	awaySlot, err := away.addSlot(ctx, b, 30*time.Hour)
+	if err != nil {
+		return err
+	}
+	_, err = svc.RescheduleAppointment(ctx, confirmed.ID, awaySlot.ID)
+	if err := expectErr(err, appointment.ErrInvalidReschedule); err != nil {
+		return fmt.Errorf("RescheduleAppointment to another clinic: %w", err)
+	}
+
+	_, err = svc.RescheduleAppointment(ctx, held.ID, awaySlot.ID)
+	if err := expectErr(err, appointment.ErrAppointmentNotActive); err != nil {
+		return fmt.Errorf("RescheduleAppointment of a cancelled appointment: %w", err)
+	}
+
+	counts, err := countEvents(ctx, b, f.patient.ID, appointment.EventAppointmentRescheduled)
+	if err != nil {
+		return err
+	}
+	if len(counts) != 3 || counts[held.ID] != 1 || counts[moved.ID] != 2 || counts[confirmed.ID] != 1 {
+		return fmt.Errorf("expected one event per appointment per move, got %v", counts)
+	}
+	return nil
+}
+
+// testHoldTransfer moves a hold to another slot and checks it keeps its ID
+// and deadline, frees the slot it left and bumps availability, and that a
+// full slot, a confirmed appointment or a passed hold are not moved
+func testHoldTransfer(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, clk := timeTravelService(b, time.Now())
+
+	held, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	later, err := f.addSlot(ctx, b, 26*time.Hour)
+	if err != nil {
+		return err
+	}
+	before, err := b.GetAvailabilityVersion(ctx, f.clinician.ID)
+	if err != nil {
+		return fmt.Errorf("GetAvailabilityVersion: %w", err)
+	}
+
+	_, err = svc.TransferHold(ctx, held.ID, f.slot.ID)
+	if err := expectErr(err, appointment.ErrInvalidReschedule); err != nil {
+		return fmt.Errorf("TransferHold to its own slot: %w", err)
+	}
+	moved, err := svc.TransferHold(ctx, held.ID, later.ID)
+	if err != nil {
+		return fmt.Errorf("TransferHold: %w", err)
+	}
+	if moved.ID != held.ID || moved.SlotID != later.ID || moved.Status != appointment.StatusPending {
+		return fmt.Errorf("expected %s pending in %s, got %+v", held.ID, later.ID, moved)
+	}
+	if moved.ExpiresAt == nil || held.ExpiresAt == nil || !moved.ExpiresAt.Equal(*held.ExpiresAt) {
+		return fmt.Errorf("expected the hold's deadline %v kept, got %v", held.ExpiresAt, moved.ExpiresAt)
+	}
+	after, err := b.GetAvailabilityVersion(ctx, f.clinician.ID)
+	if err != nil {
+		return fmt.Errorf("GetAvailabilityVersion: %w", err)
+	}
+	if after <= before {
+		return fmt.Errorf("expected the availability version bumped past %d, got %d", before, after)
+	}
+
+	// The slot left is free for someone else, and then too full to move back
+	other := appointment.Patient{ID: uuid.New(), Name: "Transfer Patient"}
+	if err := b.InsertPatient(ctx, other); err != nil {
+		return err
+	}
+	taken, err := svc.CreateAppointment(ctx, f.slot.ID, other.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment in the slot moved out of: %w", err)
+	}
+	_, err = svc.TransferHold(ctx, held.ID, f.slot.ID)
+	if err := expectErr(err, appointment.ErrSlotAlreadyBooked); err != nil {
+		return fmt.Errorf("TransferHold to a full slot: %w", err)
+	}
+
+	if _, err := svc.ConfirmAppointment(ctx, taken.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+	third, err := f.addSlot(ctx, b, 28*time.Hour)
+	if err != nil {
+		return err
+	}
+	_, err = svc.TransferHold(ctx, taken.ID, third.ID)
+	if err := expectErr(err, appointment.ErrInvalidStatusTransition); err != nil {
+		return fmt.Errorf("TransferHold of a confirmed appointment: %w", err)
+	}
+
+	clk.Advance(holdTTL + time.Second)
+	_, err = svc.TransferHold(ctx, held.ID, third.ID)
+	if err := expectErr(err, appointment.ErrAppointmentExpiredState); err != nil {
+		return fmt.Errorf("TransferHold of a passed hold: %w", err)
+	}
+
+	counts, err := countEvents(ctx, b, f.patient.ID, appointment.EventHoldTransferred)
+	if err != nil {
+		return err
+	}
+	if len(counts) != 1 || counts[held.ID] != 1 {
+		return fmt.Errorf("expected one event for the one transfer, got %v", counts)
+	}
+	return nil
+}
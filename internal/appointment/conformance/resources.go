@@ -0,0 +1,182 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// addStaff adds a staff resource to the fixture clinic
+func (f *fixture) addStaff(ctx context.Context, b Backend, kind appointment.ResourceKind, language, name string) (*appointment.StaffResource, error) {
+	res := appointment.StaffResource{ID: uuid.New(), ClinicID: f.clinic.ID, Kind: kind, Name: name}
+	if language != "" {
+		res.Language = &language
+	}
+	if err := b.InsertStaffResource(ctx, res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func testStaffResourceReservation(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	ana, err := f.addStaff(ctx, b, appointment.ResourceInterpreter, "es", "Ana")
+	if err != nil {
+		return err
+	}
+	bea, err := f.addStaff(ctx, b, appointment.ResourceInterpreter, "es", "Bea")
+	if err != nil {
+		return err
+	}
+	if _, err := f.addStaff(ctx, b, appointment.ResourceChaperone, "", "Cal"); err != nil {
+		return err
+	}
+
+	spanish := appointment.ResourceRequirement{Kind: appointment.ResourceInterpreter, Language: "es"}
+	start, end := f.slot.StartTime, f.slot.EndTime
+	expectFree := func(req appointment.ResourceRequirement, start, end time.Time, want ...uuid.UUID) error {
+		free, err := b.ListAvailableResources(ctx, f.clinic.ID, req, start, end)
+		if err != nil {
+			return fmt.Errorf("ListAvailableResources: %w", err)
+		}
+		if len(free) != len(want) {
+			return fmt.Errorf("expected %d free %s, got %d", len(want), req.Kind, len(free))
+		}
+		for i, res := range free {
+			if res.ID != want[i] {
+				return fmt.Errorf("free %s %d: expected %s, got %s", req.Kind, i+1, want[i], res.ID)
+			}
+		}
+		return nil
+	}
+
+	if err := expectFree(spanish, start, end, ana.ID, bea.ID); err != nil {
+		return err
+	}
+	french := appointment.ResourceRequirement{Kind: appointment.ResourceInterpreter, Language: "fr"}
+	if err := expectFree(french, start, end); err != nil {
+		return err
+	}
+	free, err := b.ListAvailableResources(ctx, f.clinic.ID, appointment.ResourceRequirement{Kind: appointment.ResourceChaperone}, start, end)
+	if err != nil || len(free) != 1 || free[0].Language != nil {
+		return fmt.Errorf("expected one chaperone without a language, got %d, %v", len(free), err)
+	}
+
+	appt, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+	if err := b.ReserveResource(ctx, appt.ID, ana.ID, start, end); err != nil {
+		return fmt.Errorf("ReserveResource: %w", err)
+	}
+	reserved, err := b.ListAppointmentResources(ctx, appt.ID)
+	if err != nil {
+		return fmt.Errorf("ListAppointmentResources: %w", err)
+	}
+	if len(reserved) != 1 || reserved[0].ID != ana.ID || reserved[0].Language == nil || *reserved[0].Language != "es" {
+		return fmt.Errorf("expected Ana reserved, got %d resources", len(reserved))
+	}
+
+	// Overlapping ranges conflict, back-to-back ones do not
+	if err := expectFree(spanish, start.Add(15*time.Minute), end.Add(15*time.Minute), bea.ID); err != nil {
+		return fmt.Errorf("overlapping: %w", err)
+	}
+	if err := expectFree(spanish, end, end.Add(30*time.Minute), ana.ID, bea.ID); err != nil {
+		return fmt.Errorf("back to back: %w", err)
+	}
+
+	// The reservation lapses with its appointment
+	if _, err := b.UpdateAppointmentStatus(ctx, appt.ID, appointment.StatusPending, appointment.StatusCancelled); err != nil {
+		return fmt.Errorf("cancel: %w", err)
+	}
+	if err := expectFree(spanish, start, end, ana.ID, bea.ID); err != nil {
+		return fmt.Errorf("after cancel: %w", err)
+	}
+	return nil
+}
+
+// testResourceBookingWorkflow books two clinicians of one clinic at the
+// same time with a single interpreter and chaperone between them
+func testResourceBookingWorkflow(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	if _, err := f.addStaff(ctx, b, appointment.ResourceInterpreter, "es", "Ana"); err != nil {
+		return err
+	}
+	if _, err := f.addStaff(ctx, b, appointment.ResourceChaperone, "", "Cal"); err != nil {
+		return err
+	}
+
+	other := *f
+	other.clinician.ID = uuid.New()
+	if err := b.InsertClinician(ctx, other.clinician); err != nil {
+		return err
+	}
+	otherSlot, err := other.insertSlotAt(ctx, b, f.slot.StartTime.Add(10*time.Minute), 1, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	interpreter := appointment.ResourceRequirement{Kind: appointment.ResourceInterpreter, Language: " ES "}
+	chaperone := appointment.ResourceRequirement{Kind: appointment.ResourceChaperone}
+	for _, bad := range [][]appointment.ResourceRequirement{
+		{{Kind: appointment.ResourceInterpreter}},
+		{{Kind: appointment.ResourceChaperone, Language: "es"}},
+		{{Kind: "nurse"}},
+	} {
+		_, err := svc.Book(ctx, appointment.BookingRequest{SlotID: f.slot.ID, PatientID: f.patient.ID, SlotCount: 1, Resources: bad})
+		if err := expectErr(err, appointment.ErrInvalidResource); err != nil {
+			return fmt.Errorf("requirement %+v: %w", bad[0], err)
+		}
+	}
+
+	booking, err := svc.Book(ctx, appointment.BookingRequest{
+		SlotID: f.slot.ID, PatientID: f.patient.ID, SlotCount: 1,
+		Resources: []appointment.ResourceRequirement{interpreter, chaperone},
+	})
+	if err != nil {
+		return fmt.Errorf("Book: %w", err)
+	}
+	if len(booking.Resources) != 2 || booking.Resources[0].Kind != appointment.ResourceInterpreter {
+		return fmt.Errorf("expected an interpreter and a chaperone, got %d resources", len(booking.Resources))
+	}
+	detail, err := svc.GetAppointment(ctx, booking.ID, appointment.DetailFields{Slot: true})
+	if err != nil {
+		return fmt.Errorf("GetAppointment: %w", err)
+	}
+	if len(detail.Resources) != 2 || len(detail.Span) != 0 {
+		return fmt.Errorf("expected 2 resources and no span, got %d and %d", len(detail.Resources), len(detail.Span))
+	}
+
+	// Both staff are taken over the overlapping slot of the other clinician
+	for _, req := range []appointment.ResourceRequirement{interpreter, chaperone} {
+		_, err := svc.Book(ctx, appointment.BookingRequest{
+			SlotID: otherSlot.ID, PatientID: f.patient.ID, SlotCount: 1,
+			Resources: []appointment.ResourceRequirement{req},
+		})
+		if err := expectErr(err, appointment.ErrResourceUnavailable); err != nil {
+			return fmt.Errorf("%s while reserved: %w", req.Kind, err)
+		}
+	}
+
+	if _, err := svc.CancelAppointment(ctx, booking.ID, "conformance", nil); err != nil {
+		return fmt.Errorf("CancelAppointment: %w", err)
+	}
+	if _, err := svc.Book(ctx, appointment.BookingRequest{
+		SlotID: otherSlot.ID, PatientID: f.patient.ID, SlotCount: 1,
+		Resources: []appointment.ResourceRequirement{interpreter, chaperone},
+	}); err != nil {
+		return fmt.Errorf("Book after cancel: %w", err)
+	}
+	return nil
+}
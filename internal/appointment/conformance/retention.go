@@ -0,0 +1,374 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/blob"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// retentionMonths keeps records for 30 years, so only the ancient records
+// these tests make are past it whatever else the database holds
+const retentionMonths = 360
+
+func retentionService(b Backend, opts ...appointment.Option) *appointment.Service {
+	cfg := config.Config{
+		AppointmentTTL:     holdTTL,
+		LockTTL:            5 * time.Second,
+		RetentionBatchSize: 1,
+	}
+	return appointment.NewService(b, redisclient.NewInMemorySlotLocker(), cfg, opts...)
+}
+
+func months(n int) *int { return &n }
+
+// soleTenant is a ShardTenancy where the shard holds one tenant's records
+type soleTenant string
+
+func (t soleTenant) Dedicated(_ context.Context, tenant string) bool {
+	return tenant == string(t)
+}
+
+func testRetentionPolicyRoundTrip(ctx context.Context, b Backend) error {
+	// The policy is one per database; start from none
+	if err := b.DeleteRetentionPolicy(ctx); err != nil {
+		if err := expectErr(err, appointment.ErrRetentionPolicyNotFound); err != nil {
+			return fmt.Errorf("DeleteRetentionPolicy: %w", err)
+		}
+	}
+	svc := retentionService(b)
+
+	_, err := svc.GetRetentionPolicy(ctx)
+	if err := expectErr(err, appointment.ErrRetentionPolicyNotFound); err != nil {
+		return fmt.Errorf("GetRetentionPolicy without a policy: %w", err)
+	}
+	runs, err := svc.ApplyRetention(ctx, nil, false)
+	if err != nil || len(runs) != 0 {
+		return fmt.Errorf("expected no runs without a policy, got %d (%v)", len(runs), err)
+	}
+
+	for _, bad := range []appointment.RetentionPolicy{
+		{TenantID: "acme"},
+		{TenantID: "acme", AppointmentMonths: months(0)},
+		{TenantID: "acme", EventMonths: months(-1)},
+		{TenantID: "acme", FeedbackMonths: months(1201)},
+	} {
+		_, err := svc.PutRetentionPolicy(ctx, bad)
+		if err := expectErr(err, appointment.ErrInvalidRetentionPolicy); err != nil {
+			return fmt.Errorf("policy %+v: %w", bad, err)
+		}
+	}
+
+	saved, err := svc.PutRetentionPolicy(ctx, appointment.RetentionPolicy{
+		TenantID: "acme", AppointmentMonths: months(120), FeedbackMonths: months(24),
+	})
+	if err != nil {
+		return fmt.Errorf("PutRetentionPolicy: %w", err)
+	}
+	if saved.TenantID != "acme" || saved.AppointmentMonths == nil || *saved.AppointmentMonths != 120 ||
+		saved.EventMonths != nil || saved.FeedbackMonths == nil || *saved.FeedbackMonths != 24 || saved.UpdatedAt.IsZero() {
+		return fmt.Errorf("policy did not round trip: %+v", saved)
+	}
+
+	_, err = svc.PutRetentionPolicy(ctx, appointment.RetentionPolicy{TenantID: "globex", EventMonths: months(12)})
+	if err := expectErr(err, appointment.ErrRetentionPolicyOwned); err != nil {
+		return fmt.Errorf("PutRetentionPolicy by another tenant: %w", err)
+	}
+	err = svc.DeleteRetentionPolicy(ctx, "globex")
+	if err := expectErr(err, appointment.ErrRetentionPolicyOwned); err != nil {
+		return fmt.Errorf("DeleteRetentionPolicy by another tenant: %w", err)
+	}
+
+	if _, err := svc.PutRetentionPolicy(ctx, appointment.RetentionPolicy{TenantID: "acme", EventMonths: months(36)}); err != nil {
+		return fmt.Errorf("PutRetentionPolicy: %w", err)
+	}
+	got, err := svc.GetRetentionPolicy(ctx)
+	if err != nil {
+		return fmt.Errorf("GetRetentionPolicy: %w", err)
+	}
+	if got.AppointmentMonths != nil || got.EventMonths == nil || *got.EventMonths != 36 || got.FeedbackMonths != nil {
+		return fmt.Errorf("expected the policy replaced, got %+v", got)
+	}
+
+	// Once acme has moved off the shard and globex has it to itself, the
+	// policy is no longer applied, other tenants still cannot set one, and
+	// globex can replace it
+	moved := retentionService(b, appointment.WithShardTenancy(soleTenant("globex")))
+	_, err = moved.PutRetentionPolicy(ctx, appointment.RetentionPolicy{TenantID: "initech", EventMonths: months(12)})
+	if err := expectErr(err, appointment.ErrRetentionPolicyShared); err != nil {
+		return fmt.Errorf("PutRetentionPolicy on a shared shard: %w", err)
+	}
+	runs, err = moved.ApplyRetention(ctx, nil, true)
+	if err := expectErr(err, appointment.ErrRetentionPolicyShared); err != nil || len(runs) != 0 {
+		return fmt.Errorf("ApplyRetention of a policy whose tenant moved: %d runs, %w", len(runs), err)
+	}
+	if _, err := moved.PutRetentionPolicy(ctx, appointment.RetentionPolicy{TenantID: "globex", EventMonths: months(12)}); err != nil {
+		return fmt.Errorf("PutRetentionPolicy over a moved tenant's policy: %w", err)
+	}
+	if err := moved.DeleteRetentionPolicy(ctx, "globex"); err != nil {
+		return fmt.Errorf("DeleteRetentionPolicy: %w", err)
+	}
+	if _, err := svc.PutRetentionPolicy(ctx, appointment.RetentionPolicy{TenantID: "acme", EventMonths: months(36)}); err != nil {
+		return fmt.Errorf("PutRetentionPolicy: %w", err)
+	}
+
+	if err := svc.DeleteRetentionPolicy(ctx, "acme"); err != nil {
+		return fmt.Errorf("DeleteRetentionPolicy: %w", err)
+	}
+	err = svc.DeleteRetentionPolicy(ctx, "acme")
+	if err := expectErr(err, appointment.ErrRetentionPolicyNotFound); err != nil {
+		return fmt.Errorf("DeleteRetentionPolicy twice: %w", err)
+	}
+
+	run := appointment.RetentionRun{
+		ID:           uuid.New(),
+		Entity:       appointment.RetainEvents,
+		TenantID:     "acme",
+		RetainMonths: 36,
+		Cutoff:       time.Now().AddDate(0, -36, 0).Truncate(time.Millisecond),
+		DryRun:       true,
+		Matched:      7,
+		StartedAt:    time.Now().Add(time.Hour).Truncate(time.Millisecond),
+		FinishedAt:   time.Now().Add(time.Hour).Truncate(time.Millisecond),
+	}
+	if err := b.InsertRetentionRun(ctx, run); err != nil {
+		return fmt.Errorf("InsertRetentionRun: %w", err)
+	}
+	listed, err := svc.ListRetentionRuns(ctx, 1)
+	if err != nil {
+		return fmt.Errorf("ListRetentionRuns: %w", err)
+	}
+	if len(listed) != 1 || listed[0].ID != run.ID || listed[0].Entity != run.Entity || listed[0].TenantID != "acme" ||
+		!listed[0].DryRun || listed[0].Matched != 7 || listed[0].Deleted != 0 || !listed[0].Cutoff.Equal(run.Cutoff) {
+		return fmt.Errorf("expected the newest run %+v, got %+v", run, listed)
+	}
+	return nil
+}
+
+// testRetentionWorkflow expires an appointment that ended decades ago with
+// everything recorded for it, first as a dry run, and keeps a recent one
+func testRetentionWorkflow(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	if err := b.DeleteRetentionPolicy(ctx); err != nil {
+		if err := expectErr(err, appointment.ErrRetentionPolicyNotFound); err != nil {
+			return fmt.Errorf("DeleteRetentionPolicy: %w", err)
+		}
+	}
+	defer func() { _ = b.DeleteRetentionPolicy(ctx) }()
+
+	dir, err := os.MkdirTemp("", "conformance-blobs-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	store, err := blob.NewFSStore(dir)
+	if err != nil {
+		return err
+	}
+	svc := retentionService(b, appointment.WithBlobStore(store))
+
+	kept, err := f.book(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	// An appointment spanning two slots that ended 31 years ago
+	start := time.Now().AddDate(-31, 0, 0).Truncate(time.Minute)
+	first, err := f.insertSlotAt(ctx, b, start, 1, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	second, err := f.insertSlotAt(ctx, b, first.EndTime, 1, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	old, err := b.CreatePendingAppointment(ctx, first.ID, f.patient.ID, time.Now().Add(time.Minute))
+	if err != nil {
+		return fmt.Errorf("CreatePendingAppointment: %w", err)
+	}
+	if err := b.AddExtraSlots(ctx, old.ID, []uuid.UUID{second.ID}); err != nil {
+		return fmt.Errorf("AddExtraSlots: %w", err)
+	}
+	if _, err := b.UpdateAppointmentStatus(ctx, old.ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("UpdateAppointmentStatus: %w", err)
+	}
+
+	// ...with one of everything that refers to an appointment
+	intentID := uuid.New()
+	if err := b.CreateBookingIntent(ctx, appointment.BookingIntent{
+		ID: intentID, SlotID: first.ID, PatientID: f.patient.ID, LockToken: "retention", State: appointment.IntentPending,
+	}); err != nil {
+		return fmt.Errorf("CreateBookingIntent: %w", err)
+	}
+	if err := b.ResolveBookingIntent(ctx, intentID, appointment.IntentCommitted, &old.ID); err != nil {
+		return fmt.Errorf("ResolveBookingIntent: %w", err)
+	}
+	series, err := b.CreateSeries(ctx, appointment.Series{
+		ID: uuid.New(), PatientID: f.patient.ID, ClinicianID: f.clinician.ID, IntervalDays: 7, TimeZone: "UTC",
+	})
+	if err != nil {
+		return fmt.Errorf("CreateSeries: %w", err)
+	}
+	if err := b.AddSeriesOccurrence(ctx, series.ID, 1, old.ID); err != nil {
+		return fmt.Errorf("AddSeriesOccurrence: %w", err)
+	}
+	if _, err := b.PutIntakeResponse(ctx, appointment.IntakeResponse{
+		AppointmentID: old.ID, TemplateVersion: 1, Answers: map[string]string{"allergies": "none"}, Complete: true,
+	}); err != nil {
+		return fmt.Errorf("PutIntakeResponse: %w", err)
+	}
+	if _, err := b.InsertFeedback(ctx, appointment.Feedback{AppointmentID: old.ID, ClinicianID: f.clinician.ID, Rating: 4}); err != nil {
+		return fmt.Errorf("InsertFeedback: %w", err)
+	}
+	confirm := appointment.SMSConfirm
+	smsID := "SM" + uuid.NewString()
+	if _, err := b.InsertSMSReply(ctx, appointment.SMSReply{
+		MessageID: smsID, From: "+15555550100", Body: "1", Action: &confirm,
+		AppointmentID: &old.ID, Outcome: appointment.SMSConfirmed, ReceivedAt: start,
+	}); err != nil {
+		return fmt.Errorf("InsertSMSReply: %w", err)
+	}
+	blobKey := "attachments/" + old.ID.String() + "/letter"
+	if err := store.Put(ctx, blobKey, bytes.NewReader(pdfContent), int64(len(pdfContent)), "application/pdf"); err != nil {
+		return fmt.Errorf("Put: %w", err)
+	}
+	if _, err := b.InsertAttachment(ctx, appointment.Attachment{
+		ID: uuid.New(), AppointmentID: old.ID, Kind: appointment.AttachmentReferralLetter, Filename: "letter.pdf",
+		ContentType: "application/pdf", Size: int64(len(pdfContent)), BlobKey: blobKey, ScanStatus: appointment.ScanClean,
+	}); err != nil {
+		return fmt.Errorf("InsertAttachment: %w", err)
+	}
+	for _, ev := range []appointment.EventLog{
+		{EventType: "RETENTION_TEST", AppointmentID: &old.ID, CreatedAt: start},
+		{EventType: "RETENTION_TEST", AppointmentID: &kept.ID, CreatedAt: start},
+		{EventType: "RETENTION_TEST", AppointmentID: &kept.ID},
+	} {
+		if err := b.InsertEvent(ctx, ev); err != nil {
+			return fmt.Errorf("InsertEvent: %w", err)
+		}
+	}
+
+	if _, err := svc.PutRetentionPolicy(ctx, appointment.RetentionPolicy{
+		AppointmentMonths: months(retentionMonths), EventMonths: months(retentionMonths), FeedbackMonths: months(1200),
+	}); err != nil {
+		return fmt.Errorf("PutRetentionPolicy: %w", err)
+	}
+
+	// A dry run counts without deleting
+	runs, err := svc.ApplyRetention(ctx, nil, true)
+	if err != nil {
+		return fmt.Errorf("ApplyRetention dry run: %w", err)
+	}
+	if len(runs) != 3 {
+		return fmt.Errorf("expected a run per entity, got %d", len(runs))
+	}
+	for _, run := range runs {
+		if !run.DryRun || run.Deleted != 0 {
+			return fmt.Errorf("expected a dry run to delete nothing, got %+v", run)
+		}
+	}
+	if runs[0].Entity != appointment.RetainAppointments || runs[0].Matched < 1 || runs[1].Matched < 2 {
+		return fmt.Errorf("expected the old appointment and events matched, got %+v", runs)
+	}
+	if _, err := b.GetAppointmentByID(ctx, old.ID); err != nil {
+		return fmt.Errorf("expected the appointment kept by a dry run: %w", err)
+	}
+
+	runs, err = svc.ApplyRetention(ctx, nil, false)
+	if err != nil {
+		return fmt.Errorf("ApplyRetention: %w", err)
+	}
+	if len(runs) != 3 || runs[0].Deleted < 1 || runs[0].Deleted != runs[0].Matched || runs[1].Deleted < 1 || runs[2].Deleted != 0 {
+		return fmt.Errorf("unexpected runs %+v", runs)
+	}
+
+	_, err = b.GetAppointmentByID(ctx, old.ID)
+	if err := expectErr(err, appointment.ErrAppointmentNotFound); err != nil {
+		return fmt.Errorf("GetAppointmentByID after retention: %w", err)
+	}
+	_, err = b.GetFeedback(ctx, old.ID)
+	if err := expectErr(err, appointment.ErrFeedbackNotFound); err != nil {
+		return fmt.Errorf("GetFeedback after retention: %w", err)
+	}
+	_, err = b.GetSeries(ctx, series.ID)
+	if err := expectErr(err, appointment.ErrSeriesNotFound); err != nil {
+		return fmt.Errorf("GetSeries after retention: %w", err)
+	}
+	_, err = b.GetSMSReply(ctx, smsID)
+	if err := expectErr(err, appointment.ErrSMSReplyNotFound); err != nil {
+		return fmt.Errorf("GetSMSReply after retention: %w", err)
+	}
+	if resp, err := b.GetIntakeResponse(ctx, old.ID); err != nil || resp != nil {
+		return fmt.Errorf("expected the intake response deleted, got %+v (%v)", resp, err)
+	}
+	if _, err := store.Stat(ctx, blobKey); err == nil {
+		return fmt.Errorf("expected the attachment content deleted")
+	}
+
+	if _, err := b.GetAppointmentByID(ctx, kept.ID); err != nil {
+		return fmt.Errorf("expected the recent appointment kept: %w", err)
+	}
+	timeline, err := b.ListPatientTimeline(ctx, f.patient.ID, 100)
+	if err != nil {
+		return fmt.Errorf("ListPatientTimeline: %w", err)
+	}
+	recent := 0
+	for _, entry := range timeline {
+		if entry.EventType != "RETENTION_TEST" {
+			continue
+		}
+		if entry.CreatedAt.Before(time.Now().AddDate(-30, 0, 0)) {
+			return fmt.Errorf("expected the old event deleted, got %+v", entry)
+		}
+		recent++
+	}
+	if recent != 1 {
+		return fmt.Errorf("expected the recent event kept, got %d", recent)
+	}
+
+	// The later slot was given back: it can be confirmed again
+	again, err := b.CreatePendingAppointment(ctx, second.ID, f.patient.ID, time.Now().Add(time.Minute))
+	if err != nil {
+		return fmt.Errorf("CreatePendingAppointment: %w", err)
+	}
+	if _, err := b.UpdateAppointmentStatus(ctx, again.ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("expected the later slot free again: %w", err)
+	}
+	var deleted int
+	err = b.WithTx(ctx, func(tx appointment.Repository) error {
+		var err error
+		deleted, err = tx.DeleteAppointments(ctx, []uuid.UUID{again.ID, uuid.New()})
+		return err
+	})
+	if err != nil || deleted != 1 {
+		return fmt.Errorf("expected DeleteAppointments to delete 1, got %d (%v)", deleted, err)
+	}
+
+	listed, err := svc.ListRetentionRuns(ctx, 10)
+	if err != nil {
+		return fmt.Errorf("ListRetentionRuns: %w", err)
+	}
+	found := 0
+	for _, run := range listed {
+		for _, want := range runs {
+			if run.ID == want.ID && run.Deleted == want.Deleted && !run.DryRun {
+				found++
+			}
+		}
+	}
+	if found != len(runs) {
+		return fmt.Errorf("expected the runs in the audit trail, found %d of %d", found, len(runs))
+	}
+	return nil
+}
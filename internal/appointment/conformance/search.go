@@ -0,0 +1,282 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testAppointmentSearch combines the search filters, pages through the
+// matches and checks searches that are malformed or have no filter fail
+func testAppointmentSearch(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	specialty, err := f.withSpecialty(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	// A patient of their own, so the name matches no other case's rows
+	marker := strings.ToUpper(uuid.NewString()[:8])
+	searched := appointment.Patient{ID: uuid.New(), Name: "Sam " + marker + " Search"}
+	if err := b.InsertPatient(ctx, searched); err != nil {
+		return err
+	}
+
+	var slots []*appointment.AppointmentSlot
+	for i := 0; i < 4; i++ {
+		slot, err := f.insertSlotAt(ctx, b, f.slot.StartTime.Add(time.Duration(i+1)*time.Hour), 1, appointment.SlotOpen)
+		if err != nil {
+			return err
+		}
+		slots = append(slots, slot)
+	}
+	var booked []*appointment.Appointment
+	for i, slot := range slots {
+		patientID := searched.ID
+		if i == 3 {
+			patientID = f.patient.ID
+		}
+		appt, err := b.CreatePendingAppointment(ctx, slot.ID, patientID, time.Now().Add(10*time.Minute))
+		if err != nil {
+			return fmt.Errorf("CreatePendingAppointment: %w", err)
+		}
+		booked = append(booked, appt)
+	}
+	if _, err := b.UpdateAppointmentStatus(ctx, booked[0].ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("UpdateAppointmentStatus: %w", err)
+	}
+
+	// The specialty alone pages through all four, newest booking first
+	search := appointment.AppointmentSearch{Specialty: specialty, Limit: 3}
+	first, err := svc.SearchAppointments(ctx, search, appointment.DetailFields{})
+	if err != nil {
+		return fmt.Errorf("SearchAppointments: %w", err)
+	}
+	if len(first.Appointments) != 3 || first.NextToken == "" {
+		return fmt.Errorf("expected 3 appointments and a token, got %d and %q", len(first.Appointments), first.NextToken)
+	}
+	// A token is refused once edited or with other filters
+	edited := search
+	edited.Token = "A" + first.NextToken[1:]
+	if edited.Token == first.NextToken {
+		edited.Token = "B" + first.NextToken[1:]
+	}
+	_, err = svc.SearchAppointments(ctx, edited, appointment.DetailFields{})
+	if err := expectErr(err, appointment.ErrInvalidPageToken); err != nil {
+		return fmt.Errorf("SearchAppointments with an edited token: %w", err)
+	}
+	narrowed := search
+	narrowed.Token = first.NextToken
+	narrowed.Statuses = []appointment.AppointmentStatus{appointment.StatusPending}
+	_, err = svc.SearchAppointments(ctx, narrowed, appointment.DetailFields{})
+	if err := expectErr(err, appointment.ErrInvalidPageToken); err != nil {
+		return fmt.Errorf("SearchAppointments with a token of other filters: %w", err)
+	}
+
+	search.Token = first.NextToken
+	second, err := svc.SearchAppointments(ctx, search, appointment.DetailFields{})
+	if err != nil {
+		return fmt.Errorf("SearchAppointments of the next page: %w", err)
+	}
+	if len(second.Appointments) != 1 || second.NextToken != "" {
+		return fmt.Errorf("expected 1 appointment on the last page, got %d and %q", len(second.Appointments), second.NextToken)
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, d := range append(first.Appointments, second.Appointments...) {
+		seen[d.ID] = true
+	}
+	for _, appt := range booked {
+		if !seen[appt.ID] {
+			return fmt.Errorf("appointment %s missing from the pages", appt.ID)
+		}
+	}
+
+	fields := appointment.DetailFields{Slot: true, Patient: true, Clinician: true}
+	check := func(what string, q appointment.AppointmentSearch, want ...*appointment.Appointment) error {
+		page, err := svc.SearchAppointments(ctx, q, fields)
+		if err != nil {
+			return fmt.Errorf("SearchAppointments by %s: %w", what, err)
+		}
+		if len(page.Appointments) != len(want) {
+			return fmt.Errorf("search by %s: expected %d appointments, got %d", what, len(want), len(page.Appointments))
+		}
+		got := map[uuid.UUID]bool{}
+		for _, d := range page.Appointments {
+			if d.Patient == nil || d.Slot == nil || d.Clinician == nil {
+				return fmt.Errorf("search by %s: related entities missing from %s", what, d.ID)
+			}
+			got[d.ID] = true
+		}
+		for _, appt := range want {
+			if !got[appt.ID] {
+				return fmt.Errorf("search by %s: appointment %s missing", what, appt.ID)
+			}
+		}
+		return nil
+	}
+
+	if err := check("name", appointment.AppointmentSearch{PatientName: strings.ToLower(marker)},
+		booked[0], booked[1], booked[2]); err != nil {
+		return err
+	}
+	if err := check("name and status", appointment.AppointmentSearch{
+		PatientName: marker,
+		Statuses:    []appointment.AppointmentStatus{appointment.StatusPending},
+	}, booked[1], booked[2]); err != nil {
+		return err
+	}
+	// LIKE wildcards in the name are matched literally
+	if err := check("wildcard name", appointment.AppointmentSearch{PatientName: "%", Specialty: specialty}); err != nil {
+		return err
+	}
+	from, to := slots[1].StartTime, slots[3].StartTime
+	if err := check("clinician and start time", appointment.AppointmentSearch{
+		ClinicianID: &f.clinician.ID, From: &from, To: &to,
+	}, booked[1], booked[2]); err != nil {
+		return err
+	}
+	if err := check("patient and specialty", appointment.AppointmentSearch{
+		PatientID: &f.patient.ID, Specialty: specialty,
+	}, booked[3]); err != nil {
+		return err
+	}
+
+	_, err = svc.SearchAppointments(ctx, appointment.AppointmentSearch{}, fields)
+	if err := expectErr(err, appointment.ErrInvalidSearch); err != nil {
+		return fmt.Errorf("SearchAppointments without a filter: %w", err)
+	}
+	_, err = svc.SearchAppointments(ctx, appointment.AppointmentSearch{
+		Specialty: specialty, Statuses: []appointment.AppointmentStatus{"booked"},
+	}, fields)
+	if err := expectErr(err, appointment.ErrInvalidSearch); err != nil {
+		return fmt.Errorf("SearchAppointments with an unknown status: %w", err)
+	}
+	_, err = svc.SearchAppointments(ctx, appointment.AppointmentSearch{From: &to, To: &from}, fields)
+	if err := expectErr(err, appointment.ErrInvalidTimeRange); err != nil {
+		return fmt.Errorf("SearchAppointments with from after to: %w", err)
+	}
+	_, err = svc.SearchAppointments(ctx, appointment.AppointmentSearch{Specialty: specialty, Token: "not a token"}, fields)
+	if err := expectErr(err, appointment.ErrInvalidPageToken); err != nil {
+		return fmt.Errorf("SearchAppointments with a bad token: %w", err)
+	}
+	return nil
+}
+
+// testListSort pages a patient's appointments by slot start and by status,
+// and checks a token only continues the order it was issued for
+func testListSort(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	// Booked latest slot first, so booking and start order disagree
+	var booked []*appointment.Appointment
+	for i := 3; i > 0; i-- {
+		slot, err := f.insertSlotAt(ctx, b, f.slot.StartTime.Add(time.Duration(i)*time.Hour), 1, appointment.SlotOpen)
+		if err != nil {
+			return err
+		}
+		appt, err := b.CreatePendingAppointment(ctx, slot.ID, f.patient.ID, time.Now().Add(10*time.Minute))
+		if err != nil {
+			return fmt.Errorf("CreatePendingAppointment: %w", err)
+		}
+		booked = append(booked, appt)
+	}
+	// booked[0] starts last and booked[2] first
+	if _, err := b.UpdateAppointmentStatus(ctx, booked[1].ID, appointment.StatusPending, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("UpdateAppointmentStatus: %w", err)
+	}
+
+	pages := func(sort appointment.ListSort) ([]uuid.UUID, error) {
+		var ids []uuid.UUID
+		page := appointment.PageRequest{Limit: 2, Sort: sort}
+		for {
+			res, err := svc.ListAppointmentsByPatient(ctx, f.patient.ID, page, appointment.DetailFields{})
+			if err != nil {
+				return nil, fmt.Errorf("ListAppointmentsByPatient by %s: %w", sort, err)
+			}
+			for _, d := range res.Appointments {
+				ids = append(ids, d.ID)
+			}
+			if res.NextToken == "" {
+				return ids, nil
+			}
+			page.Token = res.NextToken
+		}
+	}
+	expectOrder := func(sort appointment.ListSort, want ...*appointment.Appointment) error {
+		got, err := pages(sort)
+		if err != nil {
+			return err
+		}
+		if len(got) != len(want) {
+			return fmt.Errorf("sorted by %s: expected %d appointments, got %d", sort, len(want), len(got))
+		}
+		for i := range want {
+			if got[i] != want[i].ID {
+				return fmt.Errorf("sorted by %s: expected %s at %d, got %s", sort, want[i].ID, i, got[i])
+			}
+		}
+		return nil
+	}
+
+	byStart, err := appointment.ParseListSort("start_time", "")
+	if err != nil {
+		return fmt.Errorf("ParseListSort: %w", err)
+	}
+	if err := expectOrder(byStart, booked[2], booked[1], booked[0]); err != nil {
+		return err
+	}
+	if err := expectOrder(appointment.ListSort{Field: appointment.SortStartTime}, booked[0], booked[1], booked[2]); err != nil {
+		return err
+	}
+	// confirmed sorts before pending, then oldest booking first
+	if err := expectOrder(appointment.ListSort{Field: appointment.SortStatus, Asc: true}, booked[1], booked[0], booked[2]); err != nil {
+		return err
+	}
+	if err := expectOrder(appointment.ListSort{}, booked[2], booked[1], booked[0]); err != nil {
+		return err
+	}
+
+	first, err := svc.SearchAppointments(ctx, appointment.AppointmentSearch{
+		PatientID: &f.patient.ID, Statuses: []appointment.AppointmentStatus{appointment.StatusPending},
+		Sort: byStart, Limit: 1,
+	}, appointment.DetailFields{})
+	if err != nil {
+		return fmt.Errorf("SearchAppointments by start time: %w", err)
+	}
+	if len(first.Appointments) != 1 || first.Appointments[0].ID != booked[2].ID || first.NextToken == "" {
+		return fmt.Errorf("expected the soonest pending appointment and a token, got %+v", first)
+	}
+	_, err = svc.ListAppointmentsByPatient(ctx, f.patient.ID, appointment.PageRequest{Limit: 1, Token: first.NextToken}, appointment.DetailFields{})
+	if err := expectErr(err, appointment.ErrInvalidPageToken); err != nil {
+		return fmt.Errorf("ListAppointmentsByPatient with a token of another sort: %w", err)
+	}
+
+	slotList, err := b.ListAppointmentsBySlot(ctx, booked[0].SlotID, byStart, appointment.DetailFields{Patient: true})
+	if err != nil {
+		return fmt.Errorf("ListAppointmentsBySlot by start time: %w", err)
+	}
+	if len(slotList) != 1 || slotList[0].ID != booked[0].ID {
+		return fmt.Errorf("expected the slot's appointment, got %+v", slotList)
+	}
+
+	if _, err := appointment.ParseListSort("patient_name", ""); expectErr(err, appointment.ErrInvalidSort) != nil {
+		return fmt.Errorf("ParseListSort of an unknown field: %w", expectErr(err, appointment.ErrInvalidSort))
+	}
+	if _, err := appointment.ParseListSort("status", "up"); expectErr(err, appointment.ErrInvalidSort) != nil {
+		return fmt.Errorf("ParseListSort of an unknown order: %w", expectErr(err, appointment.ErrInvalidSort))
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+const week = 7 * 24 * time.Hour
+
+// weeklySlots adds n open slots for the fixture clinician a week apart,
+// starting a day and a half from now, clear of the fixture slot
+func (f *fixture) weeklySlots(ctx context.Context, b Backend, n int) ([]*appointment.AppointmentSlot, error) {
+	first := time.Now().Add(36 * time.Hour).Truncate(time.Minute)
+	slots := make([]*appointment.AppointmentSlot, n)
+	for i := range slots {
+		slot, err := f.insertSlotAt(ctx, b, first.Add(time.Duration(i)*week), 1, appointment.SlotOpen)
+		if err != nil {
+			return nil, err
+		}
+		slots[i] = slot
+	}
+	return slots, nil
+}
+
+func testSeriesRoundTrip(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slots, err := f.weeklySlots(ctx, b, 3)
+	if err != nil {
+		return err
+	}
+
+	found, err := b.ListClinicianSlotsAt(ctx, f.clinician.ID, []time.Time{
+		slots[2].StartTime, slots[0].StartTime, slots[0].StartTime.Add(time.Minute),
+	})
+	if err != nil {
+		return fmt.Errorf("ListClinicianSlotsAt: %w", err)
+	}
+	if len(found) != 2 || found[0].ID != slots[0].ID || found[1].ID != slots[2].ID {
+		return fmt.Errorf("expected slots 1 and 3 in order, got %d slots", len(found))
+	}
+
+	_, err = b.GetSeries(ctx, uuid.New())
+	if err := expectErr(err, appointment.ErrSeriesNotFound); err != nil {
+		return fmt.Errorf("GetSeries of missing series: %w", err)
+	}
+
+	series, err := b.CreateSeries(ctx, appointment.Series{
+		ID: uuid.New(), PatientID: f.patient.ID, ClinicianID: f.clinician.ID,
+		IntervalDays: 7, TimeZone: "Europe/Berlin",
+	})
+	if err != nil {
+		return fmt.Errorf("CreateSeries: %w", err)
+	}
+	if series.IntervalDays != 7 || series.TimeZone != "Europe/Berlin" || series.CreatedAt.IsZero() {
+		return fmt.Errorf("series did not round trip: %+v", series)
+	}
+
+	appts := make([]*appointment.Appointment, len(slots))
+	// Added out of order; they come back by number
+	for _, i := range []int{2, 0, 1} {
+		appts[i], err = b.CreatePendingAppointment(ctx, slots[i].ID, f.patient.ID, time.Now().Add(10*time.Minute))
+		if err != nil {
+			return err
+		}
+		if err := b.AddSeriesOccurrence(ctx, series.ID, i+1, appts[i].ID); err != nil {
+			return fmt.Errorf("AddSeriesOccurrence: %w", err)
+		}
+	}
+
+	got, err := b.GetSeries(ctx, series.ID)
+	if err != nil {
+		return fmt.Errorf("GetSeries: %w", err)
+	}
+	if len(got.Occurrences) != len(slots) {
+		return fmt.Errorf("expected %d occurrences, got %d", len(slots), len(got.Occurrences))
+	}
+	for i, occ := range got.Occurrences {
+		if occ.Number != i+1 || occ.Appointment.ID != appts[i].ID || occ.Slot.ID != slots[i].ID ||
+			!occ.Slot.StartTime.Equal(slots[i].StartTime) || occ.Exception {
+			return fmt.Errorf("occurrence %d did not round trip", i+1)
+		}
+	}
+
+	// An exception stays marked when the occurrence moves again
+	moved, err := b.CreatePendingAppointment(ctx, slots[1].ID, f.patient.ID, time.Now().Add(10*time.Minute))
+	if err != nil {
+		return err
+	}
+	if err := b.MoveSeriesOccurrence(ctx, series.ID, 2, moved.ID, true); err != nil {
+		return fmt.Errorf("MoveSeriesOccurrence: %w", err)
+	}
+	if err := b.MoveSeriesOccurrence(ctx, series.ID, 2, appts[1].ID, false); err != nil {
+		return fmt.Errorf("MoveSeriesOccurrence back: %w", err)
+	}
+	got, err = b.GetSeries(ctx, series.ID)
+	if err != nil {
+		return fmt.Errorf("GetSeries: %w", err)
+	}
+	if occ := got.Occurrences[1]; !occ.Exception || occ.Appointment.ID != appts[1].ID {
+		return fmt.Errorf("expected occurrence 2 marked and moved back, got exception=%t appointment %s", occ.Exception, occ.Appointment.ID)
+	}
+
+	err = b.MoveSeriesOccurrence(ctx, series.ID, 4, moved.ID, false)
+	if err := expectErr(err, appointment.ErrOccurrenceNotFound); err != nil {
+		return fmt.Errorf("MoveSeriesOccurrence of missing occurrence: %w", err)
+	}
+	return nil
+}
+
+// testSeriesWorkflow books a weekly series through the service, confirms
+// it, moves one occurrence on its own and the rest as a series, then
+// cancels all but the last
+func testSeriesWorkflow(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	slots, err := f.weeklySlots(ctx, b, 3)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+	req := appointment.SeriesRequest{
+		PatientID: f.patient.ID, FirstSlotID: slots[0].ID, IntervalDays: 7, Occurrences: 4,
+	}
+
+	// The fourth week has no slot, so nothing is booked
+	_, err = svc.CreateSeries(ctx, req)
+	if err := expectErr(err, appointment.ErrSeriesSlotUnavailable); err != nil {
+		return fmt.Errorf("series past the last slot: %w", err)
+	}
+	booked, err := b.ListAppointmentsBySlot(ctx, slots[0].ID, appointment.ListSort{}, appointment.DetailFields{})
+	if err != nil {
+		return fmt.Errorf("ListAppointmentsBySlot: %w", err)
+	}
+	if len(booked) != 0 {
+		return fmt.Errorf("failed series left %d appointments", len(booked))
+	}
+
+	req.Occurrences = 3
+	series, err := svc.CreateSeries(ctx, req)
+	if err != nil {
+		return fmt.Errorf("CreateSeries: %w", err)
+	}
+	for i, occ := range series.Occurrences {
+		if occ.Slot.ID != slots[i].ID || occ.Appointment.Status != appointment.StatusPending {
+			return fmt.Errorf("occurrence %d: expected a hold on slot %d", occ.Number, i+1)
+		}
+	}
+	if series, err = svc.ConfirmSeries(ctx, series.ID); err != nil {
+		return fmt.Errorf("ConfirmSeries: %w", err)
+	}
+	if err := expectOccurrences(series, appointment.StatusConfirmed, appointment.StatusConfirmed, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("after confirm: %w", err)
+	}
+
+	// Occurrence 2 moves on its own and stays put when the series moves
+	alone, err := f.insertSlotAt(ctx, b, slots[1].StartTime.Add(2*time.Hour), 1, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	before := series.Occurrences
+	if series, err = svc.RescheduleOccurrence(ctx, series.ID, 2, alone.ID); err != nil {
+		return fmt.Errorf("RescheduleOccurrence: %w", err)
+	}
+	if occ := series.Occurrences[1]; !occ.Exception || occ.Slot.ID != alone.ID || occ.Appointment.Status != appointment.StatusConfirmed {
+		return fmt.Errorf("expected occurrence 2 confirmed in its new slot as an exception")
+	}
+	if err := expectStatus(ctx, b, before[1].Appointment.ID, appointment.StatusCancelled); err != nil {
+		return fmt.Errorf("old occurrence 2: %w", err)
+	}
+
+	// The series moves an hour later, but only occurrence 1 has a slot
+	// there yet, so nothing moves
+	later1, err := f.insertSlotAt(ctx, b, slots[0].StartTime.Add(time.Hour), 1, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	_, err = svc.RescheduleSeries(ctx, series.ID, time.Hour, nil)
+	if err := expectErr(err, appointment.ErrSeriesSlotUnavailable); err != nil {
+		return fmt.Errorf("reschedule without every slot: %w", err)
+	}
+	if err := expectStatus(ctx, b, series.Occurrences[0].Appointment.ID, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("occurrence 1 after failed reschedule: %w", err)
+	}
+
+	later3, err := f.insertSlotAt(ctx, b, slots[2].StartTime.Add(time.Hour), 1, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+	if series, err = svc.RescheduleSeries(ctx, series.ID, time.Hour, nil); err != nil {
+		return fmt.Errorf("RescheduleSeries: %w", err)
+	}
+	want := []uuid.UUID{later1.ID, alone.ID, later3.ID}
+	for i, occ := range series.Occurrences {
+		if occ.Slot.ID != want[i] {
+			return fmt.Errorf("occurrence %d: in the wrong slot after the series moved", occ.Number)
+		}
+	}
+	if err := expectOccurrences(series, appointment.StatusConfirmed, appointment.StatusConfirmed, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("after reschedule: %w", err)
+	}
+
+	if series, err = svc.CancelSeries(ctx, series.ID, "conformance", []int{3}); err != nil {
+		return fmt.Errorf("CancelSeries: %w", err)
+	}
+	if err := expectOccurrences(series, appointment.StatusCancelled, appointment.StatusCancelled, appointment.StatusConfirmed); err != nil {
+		return fmt.Errorf("after cancel: %w", err)
+	}
+	_, err = svc.CancelSeries(ctx, series.ID, "conformance", []int{4})
+	if err := expectErr(err, appointment.ErrOccurrenceNotFound); err != nil {
+		return fmt.Errorf("cancel except a missing occurrence: %w", err)
+	}
+	return nil
+}
+
+func expectOccurrences(series *appointment.Series, want ...appointment.AppointmentStatus) error {
+	if len(series.Occurrences) != len(want) {
+		return fmt.Errorf("expected %d occurrences, got %d", len(want), len(series.Occurrences))
+	}
+	for i, occ := range series.Occurrences {
+		if occ.Appointment.Status != want[i] {
+			return fmt.Errorf("occurrence %d: expected %s, got %s", occ.Number, want[i], occ.Appointment.Status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testSlotOverlap checks the backend refuses a slot overlapping another of
+// its clinician, whether created or moved there, while touching slots,
+// deleted slots and other clinicians' slots are left alone
+func testSlotOverlap(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	start := f.slot.StartTime
+	slotAt := func(clinicianID uuid.UUID, offset time.Duration, status appointment.SlotStatus) appointment.AppointmentSlot {
+		return appointment.AppointmentSlot{
+			ID:             uuid.New(),
+			PractitionerID: clinicianID,
+			StartTime:      start.Add(offset),
+			EndTime:        start.Add(offset + 30*time.Minute),
+			Status:         status,
+			Capacity:       1,
+		}
+	}
+
+	_, err = b.CreateSlots(ctx, []appointment.AppointmentSlot{slotAt(f.clinician.ID, 15*time.Minute, appointment.SlotOpen)})
+	if err := expectErr(err, appointment.ErrSlotOverlap); err != nil {
+		return fmt.Errorf("CreateSlots overlapping a slot: %w", err)
+	}
+	err = b.InsertSlot(ctx, slotAt(f.clinician.ID, -15*time.Minute, appointment.SlotBlocked))
+	if err := expectErr(err, appointment.ErrSlotOverlap); err != nil {
+		return fmt.Errorf("InsertSlot of a blocked slot overlapping a slot: %w", err)
+	}
+
+	// A deleted slot neither blocks nor is blocked
+	created, err := b.CreateSlots(ctx, []appointment.AppointmentSlot{
+		slotAt(f.clinician.ID, 30*time.Minute, appointment.SlotOpen),
+		slotAt(f.clinician.ID, 10*time.Minute, appointment.SlotDeleted),
+	})
+	if err != nil {
+		return fmt.Errorf("CreateSlots of a touching slot and a deleted one: %w", err)
+	}
+	if len(created) != 2 {
+		return fmt.Errorf("expected 2 slots created, got %d", len(created))
+	}
+
+	colleague := appointment.Clinician{ID: uuid.New(), Name: "Dr. Colleague", Specialty: f.clinician.Specialty, ClinicID: &f.clinic.ID}
+	if err := b.InsertClinician(ctx, colleague); err != nil {
+		return err
+	}
+	theirs := slotAt(colleague.ID, 15*time.Minute, appointment.SlotOpen)
+	if err := b.InsertSlot(ctx, theirs); err != nil {
+		return fmt.Errorf("InsertSlot of another clinician at the same time: %w", err)
+	}
+	_, err = b.ReassignSlot(ctx, theirs.ID, colleague.ID, f.clinician.ID)
+	if err := expectErr(err, appointment.ErrSlotOverlap); err != nil {
+		return fmt.Errorf("ReassignSlot onto an overlapping slot: %w", err)
+	}
+	moved, err := b.GetSlotByID(ctx, theirs.ID)
+	if err != nil {
+		return err
+	}
+	if moved.PractitionerID != colleague.ID {
+		return fmt.Errorf("expected the refused slot to stay with its clinician, got %s", moved.PractitionerID)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testSlotSplitMerge splits an hour into quarters and merges some back,
+// checking IDs are kept where they can be and slots with appointments are
+// left alone
+func testSlotSplitMerge(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	start := time.Now().Add(80 * time.Hour).Truncate(time.Hour).UTC()
+	hour := f.slot
+	hour.ID = uuid.New()
+	hour.StartTime, hour.EndTime = start, start.Add(time.Hour)
+	if err := b.InsertSlot(ctx, hour); err != nil {
+		return err
+	}
+
+	for _, minutes := range []int{4, 25, 60} {
+		_, err := svc.SplitSlot(ctx, hour.ID, time.Duration(minutes)*time.Minute)
+		if err := expectErr(err, appointment.ErrInvalidSlotChange); err != nil {
+			return fmt.Errorf("SplitSlot into %d minutes: %w", minutes, err)
+		}
+	}
+	parts, err := svc.SplitSlot(ctx, hour.ID, 15*time.Minute)
+	if err != nil {
+		return fmt.Errorf("SplitSlot: %w", err)
+	}
+	if len(parts) != 4 || parts[0].ID != hour.ID {
+		return fmt.Errorf("expected 4 quarters, the first keeping the slot's ID, got %+v", parts)
+	}
+	for i, p := range parts {
+		if want := start.Add(time.Duration(i) * 15 * time.Minute); !p.StartTime.Equal(want) || !p.EndTime.Equal(want.Add(15*time.Minute)) ||
+			p.Status != hour.Status || p.Capacity != hour.Capacity {
+			return fmt.Errorf("expected quarter %d from %s like the slot, got %+v", i, want, p)
+		}
+	}
+
+	if _, err := svc.CreateAppointment(ctx, parts[1].ID, f.patient.ID); err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	_, err = svc.MergeSlots(ctx, []uuid.UUID{parts[0].ID, parts[1].ID})
+	if err := expectErr(err, appointment.ErrSlotHasAppointments); err != nil {
+		return fmt.Errorf("MergeSlots with a held slot: %w", err)
+	}
+	_, err = svc.SplitSlot(ctx, parts[1].ID, 5*time.Minute)
+	if err := expectErr(err, appointment.ErrSlotHasAppointments); err != nil {
+		return fmt.Errorf("SplitSlot of a held slot: %w", err)
+	}
+	_, err = svc.MergeSlots(ctx, []uuid.UUID{parts[0].ID, parts[2].ID})
+	if err := expectErr(err, appointment.ErrInvalidSlotChange); err != nil {
+		return fmt.Errorf("MergeSlots with a gap: %w", err)
+	}
+
+	// Listed out of order, the earliest still keeps its ID
+	merge, err := svc.MergeSlots(ctx, []uuid.UUID{parts[3].ID, parts[2].ID})
+	if err != nil {
+		return fmt.Errorf("MergeSlots: %w", err)
+	}
+	if merge.Slot.ID != parts[2].ID || !merge.Slot.EndTime.Equal(parts[3].EndTime) ||
+		len(merge.Merged) != 1 || merge.Merged[0] != parts[3].ID {
+		return fmt.Errorf("expected the third quarter stretched over the fourth, got %+v", merge)
+	}
+	if gone, err := b.GetSlotByID(ctx, parts[3].ID); err != nil || gone.Status != appointment.SlotDeleted {
+		return fmt.Errorf("expected the fourth quarter deleted, got %+v, %v", gone, err)
+	}
+	_, err = svc.MergeSlots(ctx, []uuid.UUID{parts[2].ID, parts[3].ID})
+	if err := expectErr(err, appointment.ErrSlotNotOpen); err != nil {
+		return fmt.Errorf("MergeSlots with a deleted slot: %w", err)
+	}
+
+	// Splitting again takes back the deleted quarter rather than adding one
+	again, err := svc.SplitSlot(ctx, parts[2].ID, 15*time.Minute)
+	if err != nil {
+		return fmt.Errorf("SplitSlot after a merge: %w", err)
+	}
+	if len(again) != 2 || again[0].ID != parts[2].ID || again[1].ID != parts[3].ID || again[1].Status != hour.Status {
+		return fmt.Errorf("expected the merged quarters back with their IDs, got %+v", again)
+	}
+	return nil
+}
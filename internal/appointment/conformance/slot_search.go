@@ -0,0 +1,105 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testSlotSearch narrows slots by clinician, specialty and status, and
+// checks blocked slots are listed past ones included while open ones are not
+func testSlotSearch(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	specialty, err := f.withSpecialty(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+	clinicianID := f.clinician.ID
+	day := f.slot.StartTime
+
+	blocked, err := f.insertSlotAt(ctx, b, day.Add(2*time.Hour), 1, appointment.SlotBlocked)
+	if err != nil {
+		return err
+	}
+	pastBlocked, err := f.insertSlot(ctx, b, -2*time.Hour, 1, appointment.SlotBlocked)
+	if err != nil {
+		return err
+	}
+	if _, err := f.insertSlot(ctx, b, -time.Hour, 1, appointment.SlotOpen); err != nil {
+		return err
+	}
+
+	// A colleague of another specialty at the same clinic
+	otherSpecialty := "Cardiology " + uuid.NewString()
+	f.clinician.ID = uuid.New()
+	f.clinician.Specialty = &otherSpecialty
+	if err := b.InsertClinician(ctx, f.clinician); err != nil {
+		return err
+	}
+	colleague, err := f.insertSlotAt(ctx, b, day.Add(time.Hour), 1, appointment.SlotOpen)
+	if err != nil {
+		return err
+	}
+
+	from, to := pastBlocked.StartTime.Add(-time.Hour), day.Add(3*time.Hour)
+	own, err := svc.SearchSlots(ctx, appointment.SlotQuery{ClinicianID: &clinicianID, From: from, To: to, Limit: 10})
+	if err != nil {
+		return fmt.Errorf("SearchSlots of a clinician: %w", err)
+	}
+	if len(own.Slots) != 1 || own.Slots[0].ID != f.slot.ID || own.Slots[0].Remaining != 1 {
+		return fmt.Errorf("expected only the clinician's future open slot, got %+v", own.Slots)
+	}
+
+	for want, spec := range map[uuid.UUID]string{f.slot.ID: specialty, colleague.ID: otherSpecialty} {
+		res, err := svc.SearchSlots(ctx, appointment.SlotQuery{ClinicID: &f.clinic.ID, Specialty: spec, From: from, To: to, Limit: 10})
+		if err != nil {
+			return fmt.Errorf("SearchSlots of a specialty: %w", err)
+		}
+		if len(res.Slots) != 1 || res.Slots[0].ID != want {
+			return fmt.Errorf("expected only slot %s of %s, got %+v", want, spec, res.Slots)
+		}
+	}
+
+	clinic, err := svc.SearchSlots(ctx, appointment.SlotQuery{ClinicID: &f.clinic.ID, From: from, To: to, Limit: 10})
+	if err != nil {
+		return fmt.Errorf("SearchSlots of a clinic: %w", err)
+	}
+	// The fixture's first clinician keeps its slot, so there are 3
+	if len(clinic.Slots) != 3 {
+		return fmt.Errorf("expected 3 open slots at the clinic, got %+v", clinic.Slots)
+	}
+
+	blockedOnly, err := svc.SearchSlots(ctx, appointment.SlotQuery{
+		ClinicianID: &clinicianID, Status: appointment.SlotBlocked, From: from, To: to, Limit: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("SearchSlots of blocked slots: %w", err)
+	}
+	if len(blockedOnly.Slots) != 1 || blockedOnly.Slots[0].ID != pastBlocked.ID || blockedOnly.NextToken == "" {
+		return fmt.Errorf("expected the past blocked slot then a token, got %+v", blockedOnly)
+	}
+	next, err := svc.SearchSlots(ctx, appointment.SlotQuery{
+		ClinicianID: &clinicianID, Status: appointment.SlotBlocked, From: from, To: to, Limit: 1,
+		Token: blockedOnly.NextToken,
+	})
+	if err != nil {
+		return fmt.Errorf("SearchSlots of the next blocked page: %w", err)
+	}
+	if len(next.Slots) != 1 || next.Slots[0].ID != blocked.ID || next.Slots[0].Status != appointment.SlotBlocked || next.NextToken != "" {
+		return fmt.Errorf("expected only the future blocked slot on the last page, got %+v", next)
+	}
+
+	_, err = svc.SearchSlots(ctx, appointment.SlotQuery{Status: "booked", From: from, To: to})
+	if err := expectErr(err, appointment.ErrInvalidSlotSearch); err != nil {
+		return fmt.Errorf("SearchSlots of an unknown status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testSMSReplies replies to bookings by SMS and checks "1" confirms and "2"
+// cancels the soonest pending appointment of the number, a reply delivered
+// twice acts once, and replies nothing can be done for are recorded as such
+func testSMSReplies(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	// A number unique to this run, since replies are matched by number
+	digits := fmt.Sprintf("%09d", time.Now().UnixNano()%1_000_000_000)
+	phone := "+447" + digits
+	written := "+44 (7" + digits[:3] + ") " + digits[3:6] + "-" + digits[6:]
+
+	_, err = svc.SetPatientPhone(ctx, f.patient.ID, "07700 900123")
+	if err := expectErr(err, appointment.ErrInvalidPhone); err != nil {
+		return fmt.Errorf("SetPatientPhone without a country code: %w", err)
+	}
+	_, err = svc.SetPatientPhone(ctx, uuid.New(), phone)
+	if err := expectErr(err, appointment.ErrPatientNotFound); err != nil {
+		return fmt.Errorf("SetPatientPhone of an unknown patient: %w", err)
+	}
+	p, err := svc.SetPatientPhone(ctx, f.patient.ID, written)
+	if err != nil {
+		return fmt.Errorf("SetPatientPhone: %w", err)
+	}
+	if p.Phone == nil || *p.Phone != phone {
+		return fmt.Errorf("expected the phone stored as %s, got %v", phone, p.Phone)
+	}
+
+	reply := func(body string) (*appointment.SMSReply, error) {
+		return svc.HandleSMSReply(ctx, "SM"+uuid.NewString(), phone, body)
+	}
+	expectOutcome := func(m *appointment.SMSReply, want appointment.SMSOutcome, appt *appointment.Appointment) error {
+		if m.Outcome != want {
+			return fmt.Errorf("expected outcome %s, got %s", want, m.Outcome)
+		}
+		if appt == nil && m.AppointmentID != nil || appt != nil && (m.AppointmentID == nil || *m.AppointmentID != appt.ID) {
+			return fmt.Errorf("expected the reply to come to %v, got %v", appt, m.AppointmentID)
+		}
+		return nil
+	}
+
+	for _, c := range []struct {
+		what, messageID, from string
+	}{
+		{"no message ID", " ", phone},
+		{"a sender that is not a number", "SM" + uuid.NewString(), "Clinic"},
+	} {
+		_, err := svc.HandleSMSReply(ctx, c.messageID, c.from, "1")
+		if err := expectErr(err, appointment.ErrInvalidSMSReply); err != nil {
+			return fmt.Errorf("HandleSMSReply with %s: %w", c.what, err)
+		}
+	}
+
+	m, err := reply("1")
+	if err != nil {
+		return fmt.Errorf("HandleSMSReply with nothing pending: %w", err)
+	}
+	if err := expectOutcome(m, appointment.SMSNoAppointment, nil); err != nil {
+		return err
+	}
+
+	// Booked out of order: the reply goes to the soonest slot
+	later, err := f.addSlot(ctx, b, 30*time.Hour)
+	if err != nil {
+		return err
+	}
+	second, err := svc.CreateAppointment(ctx, later.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	first, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+
+	m, err = reply(" yes please ")
+	if err != nil {
+		return fmt.Errorf("HandleSMSReply: %w", err)
+	}
+	if err := expectOutcome(m, appointment.SMSNotUnderstood, nil); err != nil {
+		return err
+	}
+	if m.Action != nil {
+		return fmt.Errorf("expected no action for a reply not understood, got %s", *m.Action)
+	}
+
+	messageID := "SM" + uuid.NewString()
+	confirmed, err := svc.HandleSMSReply(ctx, messageID, written, " 1\n")
+	if err != nil {
+		return fmt.Errorf("HandleSMSReply of 1: %w", err)
+	}
+	if err := expectOutcome(confirmed, appointment.SMSConfirmed, first); err != nil {
+		return err
+	}
+	if err := expectStatus(ctx, b, first.ID, appointment.StatusConfirmed); err != nil {
+		return err
+	}
+
+	// The carrier delivers the same message again
+	again, err := svc.HandleSMSReply(ctx, messageID, phone, "1")
+	if err != nil {
+		return fmt.Errorf("HandleSMSReply of a delivery again: %w", err)
+	}
+	if err := expectOutcome(again, appointment.SMSConfirmed, first); err != nil {
+		return fmt.Errorf("delivered again: %w", err)
+	}
+	if err := expectStatus(ctx, b, second.ID, appointment.StatusPending); err != nil {
+		return fmt.Errorf("delivered again: %w", err)
+	}
+	counts, err := countEvents(ctx, b, f.patient.ID, appointment.EventAppointmentConfirmed)
+	if err != nil {
+		return err
+	}
+	if counts[first.ID] != 1 || counts[second.ID] != 0 {
+		return fmt.Errorf("expected one confirmation, got %v", counts)
+	}
+
+	m, err = reply("2")
+	if err != nil {
+		return fmt.Errorf("HandleSMSReply of 2: %w", err)
+	}
+	if err := expectOutcome(m, appointment.SMSCancelled, second); err != nil {
+		return err
+	}
+	if err := expectStatus(ctx, b, second.ID, appointment.StatusCancelled); err != nil {
+		return err
+	}
+
+	// Someone else confirms the slot of the next hold first. The service
+	// counts the hold against the slot, so the other booking goes straight
+	// to the backend.
+	contested, err := f.addSlot(ctx, b, 34*time.Hour)
+	if err != nil {
+		return err
+	}
+	held, err := svc.CreateAppointment(ctx, contested.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	other := appointment.Patient{ID: uuid.New(), Name: "SMS Patient"}
+	if err := b.InsertPatient(ctx, other); err != nil {
+		return err
+	}
+	taken, err := b.CreatePendingAppointment(ctx, contested.ID, other.ID, time.Now().Add(holdTTL))
+	if err != nil {
+		return fmt.Errorf("CreatePendingAppointment: %w", err)
+	}
+	if _, err := svc.ConfirmAppointment(ctx, taken.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+	m, err = reply("1")
+	if err != nil {
+		return fmt.Errorf("HandleSMSReply for a full slot: %w", err)
+	}
+	if err := expectOutcome(m, appointment.SMSFailed, held); err != nil {
+		return err
+	}
+	recorded, err := b.GetSMSReply(ctx, m.MessageID)
+	if err != nil {
+		return fmt.Errorf("GetSMSReply: %w", err)
+	}
+	if recorded.Outcome != appointment.SMSFailed || recorded.From != phone || recorded.Action == nil || *recorded.Action != appointment.SMSConfirm {
+		return fmt.Errorf("expected the failed confirmation recorded, got %+v", recorded)
+	}
+
+	if _, err := svc.ClearPatientPhone(ctx, f.patient.ID); err != nil {
+		return fmt.Errorf("ClearPatientPhone: %w", err)
+	}
+	m, err = reply("1")
+	if err != nil {
+		return fmt.Errorf("HandleSMSReply from a cleared number: %w", err)
+	}
+	return expectOutcome(m, appointment.SMSNoAppointment, nil)
+}
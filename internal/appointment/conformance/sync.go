@@ -0,0 +1,129 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// testSync follows a clinic's changes through the event log: changes come
+// back once they settle, each appointment once at its current state, paged
+// and limited to the scope
+func testSync(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	other, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	svc, fake := timeTravelService(b, time.Now())
+	settle := func() { fake.Advance(appointment.SyncSettle + time.Second) }
+	scope := appointment.SyncScope{ClinicID: f.clinic.ID}
+	fields := appointment.DetailFields{Slot: true}
+
+	sync := func(what string, scope appointment.SyncScope, cursor string, limit int, want ...*appointment.Appointment) (*appointment.SyncPage, error) {
+		page, err := svc.Sync(ctx, scope, cursor, limit, fields)
+		if err != nil {
+			return nil, fmt.Errorf("Sync %s: %w", what, err)
+		}
+		if page.Cursor == "" {
+			return nil, fmt.Errorf("sync %s: no cursor", what)
+		}
+		if len(page.Appointments) != len(want) {
+			return nil, fmt.Errorf("sync %s: expected %d appointments, got %d", what, len(want), len(page.Appointments))
+		}
+		for i, appt := range want {
+			if page.Appointments[i].ID != appt.ID || page.Appointments[i].Slot == nil {
+				return nil, fmt.Errorf("sync %s: expected %s with its slot at %d, got %+v", what, appt.ID, i, page.Appointments[i])
+			}
+		}
+		return page, nil
+	}
+
+	start, err := sync("from scratch", scope, "", 0)
+	if err != nil {
+		return err
+	}
+
+	first, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	if _, err := svc.CreateAppointment(ctx, other.slot.ID, other.patient.ID); err != nil {
+		return fmt.Errorf("CreateAppointment at another clinic: %w", err)
+	}
+	if _, err := sync("before the change settles", scope, start.Cursor, 0); err != nil {
+		return err
+	}
+	settle()
+	created, err := sync("after the booking", scope, start.Cursor, 0, first)
+	if err != nil {
+		return err
+	}
+
+	if _, err := svc.ConfirmAppointment(ctx, first.ID); err != nil {
+		return fmt.Errorf("ConfirmAppointment: %w", err)
+	}
+	if err := f.nextSlot(ctx, b); err != nil {
+		return err
+	}
+	second, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	settle()
+
+	// One change a page: the confirmation, then the second booking
+	page, err := sync("a page at a time", scope, created.Cursor, 1, first)
+	if err != nil {
+		return err
+	}
+	if !page.HasMore || page.Appointments[0].Status != appointment.StatusConfirmed {
+		return fmt.Errorf("expected the confirmed appointment and more to come, got %+v", page)
+	}
+	page, err = sync("the next page", scope, page.Cursor, 1, second)
+	if err != nil {
+		return err
+	}
+	if page, err = sync("past the last change", scope, page.Cursor, 1); err != nil {
+		return err
+	} else if page.HasMore {
+		return fmt.Errorf("expected no more changes, got %+v", page)
+	}
+	// Without a limit both come back once, in the order they last changed
+	if _, err := sync("all changes", scope, created.Cursor, 0, first, second); err != nil {
+		return err
+	}
+
+	// A clinician of the same clinic sees only their own appointments
+	if _, err := f.withSpecialty(ctx, b); err != nil {
+		return err
+	}
+	third, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err != nil {
+		return fmt.Errorf("CreateAppointment: %w", err)
+	}
+	settle()
+	if _, err := sync("by clinician", appointment.SyncScope{ClinicID: f.clinic.ID, ClinicianID: &f.clinician.ID}, start.Cursor, 0, third); err != nil {
+		return err
+	}
+	if _, err := sync("of the other clinic", appointment.SyncScope{ClinicID: other.clinic.ID, ClinicianID: &f.clinician.ID}, start.Cursor, 0); err != nil {
+		return err
+	}
+
+	_, err = svc.Sync(ctx, scope, "not a cursor", 0, fields)
+	if err := expectErr(err, appointment.ErrInvalidSyncCursor); err != nil {
+		return fmt.Errorf("Sync with a bad cursor: %w", err)
+	}
+	_, err = svc.Sync(ctx, appointment.SyncScope{ClinicID: uuid.New()}, "", 0, fields)
+	if err != nil {
+		return fmt.Errorf("Sync of an unknown clinic: %w", err)
+	}
+	return nil
+}
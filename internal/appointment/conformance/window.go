@@ -0,0 +1,173 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// withSpecialty moves the fixture clinician to a specialty of its own, so
+// windows set by a case do not reach other fixtures
+func (f *fixture) withSpecialty(ctx context.Context, b Backend) (string, error) {
+	specialty := "Dermatology " + uuid.NewString()
+	f.clinician.ID = uuid.New()
+	f.clinician.Specialty = &specialty
+	if err := b.InsertClinician(ctx, f.clinician); err != nil {
+		return "", err
+	}
+	slot, err := f.insertSlotAt(ctx, b, f.slot.StartTime, 1, appointment.SlotOpen)
+	if err != nil {
+		return "", err
+	}
+	f.slot = *slot
+	return specialty, nil
+}
+
+func testBookingWindowRoundTrip(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	specialty, err := f.withSpecialty(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.GetBookingWindow(ctx, specialty)
+	if err := expectErr(err, appointment.ErrBookingWindowNotFound); err != nil {
+		return fmt.Errorf("GetBookingWindow of missing window: %w", err)
+	}
+	window, err := b.GetSlotBookingWindow(ctx, f.slot.ID)
+	if err != nil || window != nil {
+		return fmt.Errorf("expected no window for the slot, got %+v, %v", window, err)
+	}
+	_, err = b.GetSlotBookingWindow(ctx, uuid.New())
+	if err := expectErr(err, appointment.ErrSlotNotFound); err != nil {
+		return fmt.Errorf("GetSlotBookingWindow of missing slot: %w", err)
+	}
+
+	maxLead := 90 * 24 * time.Hour
+	saved, err := b.PutBookingWindow(ctx, appointment.BookingWindow{Specialty: specialty, MinLead: 14 * 24 * time.Hour, MaxLead: &maxLead})
+	if err != nil {
+		return fmt.Errorf("PutBookingWindow: %w", err)
+	}
+	if saved.MinLead != 14*24*time.Hour || saved.MaxLead == nil || *saved.MaxLead != maxLead || saved.UpdatedAt.IsZero() {
+		return fmt.Errorf("window did not round trip: %+v", saved)
+	}
+
+	// Putting again replaces the window, clearing the maximum
+	if _, err := b.PutBookingWindow(ctx, appointment.BookingWindow{Specialty: specialty, MinLead: time.Hour}); err != nil {
+		return fmt.Errorf("PutBookingWindow again: %w", err)
+	}
+	window, err = b.GetSlotBookingWindow(ctx, f.slot.ID)
+	if err != nil {
+		return fmt.Errorf("GetSlotBookingWindow: %w", err)
+	}
+	if window == nil || window.Specialty != specialty || window.MinLead != time.Hour || window.MaxLead != nil {
+		return fmt.Errorf("expected the replaced window for the slot, got %+v", window)
+	}
+
+	windows, err := b.ListBookingWindows(ctx)
+	if err != nil {
+		return fmt.Errorf("ListBookingWindows: %w", err)
+	}
+	found := 0
+	for _, w := range windows {
+		if w.Specialty == specialty {
+			found++
+		}
+	}
+	if found != 1 {
+		return fmt.Errorf("expected the window listed once, got %d", found)
+	}
+
+	if err := b.DeleteBookingWindow(ctx, specialty); err != nil {
+		return fmt.Errorf("DeleteBookingWindow: %w", err)
+	}
+	err = b.DeleteBookingWindow(ctx, specialty)
+	if err := expectErr(err, appointment.ErrBookingWindowNotFound); err != nil {
+		return fmt.Errorf("DeleteBookingWindow twice: %w", err)
+	}
+	return nil
+}
+
+// testBookingWindowWorkflow sets a window of two to four days and checks
+// slots a day and two and a half days out are booked or refused, through
+// single slot, multi-slot and series bookings and the calendar
+func testBookingWindowWorkflow(ctx context.Context, b Backend) error {
+	f, err := newFixture(ctx, b)
+	if err != nil {
+		return err
+	}
+	specialty, err := f.withSpecialty(ctx, b)
+	if err != nil {
+		return err
+	}
+	slots, err := f.backToBackSlots(ctx, b, 2)
+	if err != nil {
+		return err
+	}
+	svc, _ := timeTravelService(b, time.Now())
+
+	maxLead := 96 * time.Hour
+	for _, bad := range []appointment.BookingWindow{
+		{Specialty: " "},
+		{Specialty: specialty, MinLead: -time.Hour},
+		{Specialty: specialty, MinLead: 96 * time.Hour, MaxLead: &maxLead},
+	} {
+		_, err := svc.PutBookingWindow(ctx, bad)
+		if err := expectErr(err, appointment.ErrInvalidBookingWindow); err != nil {
+			return fmt.Errorf("window %+v: %w", bad, err)
+		}
+	}
+	if _, err := svc.PutBookingWindow(ctx, appointment.BookingWindow{Specialty: specialty, MinLead: 48 * time.Hour, MaxLead: &maxLead}); err != nil {
+		return fmt.Errorf("PutBookingWindow: %w", err)
+	}
+
+	// The fixture slot is a day out, too soon
+	_, err = svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID)
+	if err := expectErr(err, appointment.ErrOutsideBookingWindow); err != nil {
+		return fmt.Errorf("CreateAppointment too soon: %w", err)
+	}
+	_, err = svc.Book(ctx, appointment.BookingRequest{SlotID: f.slot.ID, PatientID: f.patient.ID, SlotCount: 1})
+	if err := expectErr(err, appointment.ErrOutsideBookingWindow); err != nil {
+		return fmt.Errorf("Book too soon: %w", err)
+	}
+	if _, err := f.insertSlotAt(ctx, b, f.slot.StartTime.Add(week), 1, appointment.SlotOpen); err != nil {
+		return err
+	}
+	_, err = svc.CreateSeries(ctx, appointment.SeriesRequest{
+		PatientID: f.patient.ID, FirstSlotID: f.slot.ID, IntervalDays: 7, Occurrences: 2,
+	})
+	if err := expectErr(err, appointment.ErrOutsideBookingWindow); err != nil {
+		return fmt.Errorf("CreateSeries too soon: %w", err)
+	}
+
+	calendar, err := svc.GetAvailabilityCalendar(ctx, f.clinician.ID, f.slot.StartTime, time.UTC)
+	if err != nil {
+		return fmt.Errorf("GetAvailabilityCalendar: %w", err)
+	}
+	for _, day := range calendar {
+		if day.Date.Day() == f.slot.StartTime.UTC().Day() && day.Slots > 0 && day.Open != 0 {
+			return fmt.Errorf("expected no open slots on %s, got %d", day.Date.Format(time.DateOnly), day.Open)
+		}
+	}
+
+	// The back-to-back slots start two and a half days out, inside the window
+	if _, err := svc.Book(ctx, appointment.BookingRequest{SlotID: slots[0].ID, PatientID: f.patient.ID, SlotCount: 2}); err != nil {
+		return fmt.Errorf("Book inside the window: %w", err)
+	}
+
+	// Without the window the fixture slot can be booked again
+	if err := svc.DeleteBookingWindow(ctx, specialty); err != nil {
+		return fmt.Errorf("DeleteBookingWindow: %w", err)
+	}
+	if _, err := svc.CreateAppointment(ctx, f.slot.ID, f.patient.ID); err != nil {
+		return fmt.Errorf("CreateAppointment without a window: %w", err)
+	}
+	return nil
+}
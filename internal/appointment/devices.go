@@ -0,0 +1,91 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MaxDeviceTokenLength bounds a push token. FCM tokens run to a few hundred
+// bytes and APNs tokens to 64 hex digits today; neither length is promised.
+const MaxDeviceTokenLength = 4096
+
+// RegisterDevice registers a device of the patient for push notifications.
+// Registering a token again refreshes it, and moves it to this patient when
+// another patient had it, as when someone else signs in on the same phone.
+func (s *Service) RegisterDevice(ctx context.Context, patientID uuid.UUID, provider PushProvider, token string) (*Device, error) {
+	token = strings.TrimSpace(token)
+	if err := validateDevice(provider, token); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.lookupPatient(ctx, patientID); err != nil {
+		if errors.Is(err, ErrPatientNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load patient: %w", err)
+	}
+
+	d, err := s.repo.RegisterDevice(ctx, Device{
+		ID:        uuid.New(),
+		PatientID: patientID,
+		Provider:  provider,
+		Token:     token,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("register device: %w", err)
+	}
+	return d, nil
+}
+
+// ListDevices returns the patient's registered devices, oldest first
+func (s *Service) ListDevices(ctx context.Context, patientID uuid.UUID) ([]Device, error) {
+	if _, err := s.lookupPatient(ctx, patientID); err != nil {
+		if errors.Is(err, ErrPatientNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load patient: %w", err)
+	}
+	return s.repo.ListPatientDevices(ctx, patientID)
+}
+
+// DeleteDevice unregisters one of the patient's devices
+func (s *Service) DeleteDevice(ctx context.Context, patientID, deviceID uuid.UUID) error {
+	return s.repo.DeletePatientDevice(ctx, patientID, deviceID)
+}
+
+// validateDevice checks the provider is known and the token could have come
+// from it: printable ASCII without spaces, and hex digits for APNs, whose
+// tokens end up in the request path.
+func validateDevice(provider PushProvider, token string) error {
+	switch provider {
+	case PushFCM, PushAPNs:
+	case "":
+		return fmt.Errorf("%w: provider is required", ErrInvalidDevice)
+	default:
+		return fmt.Errorf("%w: unknown provider %q, want fcm or apns", ErrInvalidDevice, provider)
+	}
+
+	if token == "" {
+		return fmt.Errorf("%w: token is required", ErrInvalidDevice)
+	}
+	if len(token) > MaxDeviceTokenLength {
+		return fmt.Errorf("%w: token is longer than %d bytes", ErrInvalidDevice, MaxDeviceTokenLength)
+	}
+	for _, c := range token {
+		if c <= ' ' || c > '~' {
+			return fmt.Errorf("%w: token has characters other than printable ASCII", ErrInvalidDevice)
+		}
+		if provider == PushAPNs && !isHexDigit(c) {
+			return fmt.Errorf("%w: APNs token is not hexadecimal", ErrInvalidDevice)
+		}
+	}
+	return nil
+}
+
+func isHexDigit(c rune) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,388 @@
+package appointment
+
+import "net/http"
+
+// Error is a domain error with a stable machine-readable code. Handlers map
+// any *Error to its HTTPStatus and Code, so a new error only needs to be
+// declared here. Compare with errors.Is against the values below; wrapping
+// with fmt.Errorf("...: %w", err) keeps the code.
+type Error struct {
+	Code       string // stable identifier returned to clients, e.g. "slot_not_found"
+	HTTPStatus int
+	Message    string
+	Retryable  bool // the same request may succeed if retried shortly
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Not found
+var (
+	ErrPatientNotFound = &Error{
+		Code: "patient_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "patient not found",
+	}
+	ErrClinicianNotFound = &Error{
+		Code: "clinician_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "clinician not found",
+	}
+	ErrClinicNotFound = &Error{
+		Code: "clinic_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "clinic not found",
+	}
+	ErrSlotNotFound = &Error{
+		Code: "slot_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "slot not found",
+	}
+	ErrAppointmentNotFound = &Error{
+		Code: "appointment_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "appointment not found",
+	}
+	ErrPriceNotFound = &Error{
+		Code: "price_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "no self-pay price configured for slot",
+	}
+	ErrIntentNotFound = &Error{
+		Code: "booking_intent_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "booking intent not found",
+	}
+	ErrSeriesNotFound = &Error{
+		Code: "series_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "series not found",
+	}
+	ErrOccurrenceNotFound = &Error{
+		Code: "occurrence_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "series has no such occurrence",
+	}
+	ErrBookingWindowNotFound = &Error{
+		Code: "booking_window_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "no booking window configured for specialty",
+	}
+	ErrIntakeTemplateNotFound = &Error{
+		Code: "intake_template_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "no intake form configured for the appointment type",
+	}
+	ErrDeviceNotFound = &Error{
+		Code: "device_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "device not found",
+	}
+	ErrFeedbackNotFound = &Error{
+		Code: "feedback_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "no feedback was given for the appointment",
+	}
+	ErrSMSReplyNotFound = &Error{
+		Code: "sms_reply_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "sms reply not found",
+	}
+	ErrAPIKeyNotFound = &Error{
+		Code: "api_key_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "api key not found",
+	}
+	ErrAvailabilityTemplateNotFound = &Error{
+		Code: "availability_template_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "the clinician has no availability template",
+	}
+	ErrRetentionPolicyNotFound = &Error{
+		Code: "retention_policy_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "no retention policy is configured, records are kept forever",
+	}
+	ErrAttachmentNotFound = &Error{
+		Code: "attachment_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "attachment not found",
+	}
+	ErrSyncCursorExpired = &Error{
+		Code: "sync_cursor_expired", HTTPStatus: http.StatusGone,
+		Message: "changes since the cursor are no longer retained, sync from scratch",
+	}
+)
+
+// Booking conflicts
+var (
+	ErrSlotAlreadyBooked = &Error{
+		Code: "slot_already_booked", HTTPStatus: http.StatusConflict,
+		Message: "slot is fully booked",
+	}
+	ErrSlotBeingBooked = &Error{
+		Code: "slot_being_booked", HTTPStatus: http.StatusConflict,
+		Message: "slot is currently being booked, please retry", Retryable: true,
+	}
+	ErrBookingQueued = &Error{
+		Code: "booking_queued", HTTPStatus: http.StatusTooManyRequests,
+		Message: "the clinic is taking more bookings than it admits, please retry with the queue ticket", Retryable: true,
+	}
+	ErrSlotNotOpen = &Error{
+		Code: "slot_not_open", HTTPStatus: http.StatusConflict,
+		Message: "slot is not open",
+	}
+	ErrSeriesSlotUnavailable = &Error{
+		Code: "series_slot_unavailable", HTTPStatus: http.StatusConflict,
+		Message: "no open slot of the series clinician for an occurrence",
+	}
+	ErrSpanSlotUnavailable = &Error{
+		Code: "span_slot_unavailable", HTTPStatus: http.StatusConflict,
+		Message: "no open slot of the clinician directly follows the previous one",
+	}
+	ErrResourceUnavailable = &Error{
+		Code: "resource_unavailable", HTTPStatus: http.StatusConflict,
+		Message: "no staff resource of the required kind is free for the appointment",
+	}
+	ErrClinicianIncompatible = &Error{
+		Code: "clinician_incompatible", HTTPStatus: http.StatusConflict,
+		Message: "clinician cannot cover the slot",
+	}
+	ErrClinicianUnavailable = &Error{
+		Code: "clinician_unavailable", HTTPStatus: http.StatusConflict,
+		Message: "clinician already has a slot overlapping the slot",
+	}
+	ErrSlotOverlap = &Error{
+		Code: "slot_overlap", HTTPStatus: http.StatusConflict,
+		Message: "slot overlaps another slot of the clinician",
+	}
+	ErrSlotHasAppointments = &Error{
+		Code: "slot_has_appointments", HTTPStatus: http.StatusConflict,
+		Message: "slot has active appointments",
+	}
+	ErrSlotInMultiSlotAppointment = &Error{
+		Code: "slot_in_multi_slot_appointment", HTTPStatus: http.StatusConflict,
+		Message: "slot is part of an appointment spanning several slots",
+	}
+	ErrRescheduleUnsupported = &Error{
+		Code: "reschedule_unsupported", HTTPStatus: http.StatusConflict,
+		Message: "appointment cannot be moved to another slot",
+	}
+	ErrPatientEmailTaken = &Error{
+		Code: "patient_email_taken", HTTPStatus: http.StatusConflict,
+		Message: "another patient has this email",
+	}
+	ErrClinicianDeactivated = &Error{
+		Code: "clinician_deactivated", HTTPStatus: http.StatusConflict,
+		Message: "clinician is deactivated",
+	}
+	ErrPatientDeactivated = &Error{
+		Code: "patient_deactivated", HTTPStatus: http.StatusConflict,
+		Message: "patient account is deactivated",
+	}
+	ErrOutsideBookingWindow = &Error{
+		Code: "outside_booking_window", HTTPStatus: http.StatusConflict,
+		Message: "slot is outside the booking window of the clinician's specialty",
+	}
+)
+
+// Status transitions
+var (
+	ErrAppointmentExpiredState = &Error{
+		Code: "appointment_expired", HTTPStatus: http.StatusConflict,
+		Message: "appointment is already expired",
+	}
+	ErrInvalidStatusTransition = &Error{
+		Code: "invalid_status_transition", HTTPStatus: http.StatusConflict,
+		Message: "invalid status transition",
+	}
+	ErrAppointmentNotActive = &Error{
+		Code: "appointment_not_active", HTTPStatus: http.StatusConflict,
+		Message: "appointment is not pending, awaiting approval or confirmed",
+	}
+	ErrApprovalWindowPassed = &Error{
+		Code: "approval_window_passed", HTTPStatus: http.StatusConflict,
+		Message: "the approval window has passed and the booking was rejected",
+	}
+	ErrCheckInNotOpen = &Error{
+		Code: "check_in_not_open", HTTPStatus: http.StatusConflict,
+		Message: "check-in has not opened for this appointment yet",
+	}
+	ErrCheckInClosed = &Error{
+		Code: "check_in_closed", HTTPStatus: http.StatusConflict,
+		Message: "check-in has closed for this appointment",
+	}
+	ErrAppointmentNotEnded = &Error{
+		Code: "appointment_not_ended", HTTPStatus: http.StatusConflict,
+		Message: "attendance is recorded once the appointment has ended",
+	}
+	ErrFeedbackNotOpen = &Error{
+		Code: "feedback_not_open", HTTPStatus: http.StatusConflict,
+		Message: "feedback is taken for confirmed, checked-in and completed appointments once they have ended",
+	}
+	ErrFeedbackAlreadyGiven = &Error{
+		Code: "feedback_already_given", HTTPStatus: http.StatusConflict,
+		Message: "feedback was already given for the appointment",
+	}
+	ErrRetentionPolicyOwned = &Error{
+		Code: "retention_policy_owned", HTTPStatus: http.StatusConflict,
+		Message: "the retention policy of the tenant's database is owned by another tenant",
+	}
+	ErrRetentionPolicyShared = &Error{
+		Code: "retention_policy_shared", HTTPStatus: http.StatusConflict,
+		Message: "the tenant shares its database with other tenants, whose records a retention policy would delete too",
+	}
+	ErrPreconditionFailed = &Error{
+		Code: "precondition_failed", HTTPStatus: http.StatusPreconditionFailed,
+		Message: "appointment status does not match the expected status",
+	}
+)
+
+// Invalid input
+var (
+	ErrInvalidPageToken = &Error{
+		Code: "invalid_page_token", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid page token",
+	}
+	ErrInvalidSyncCursor = &Error{
+		Code: "invalid_sync_cursor", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid sync cursor",
+	}
+	ErrInvalidTimeRange = &Error{
+		Code: "invalid_time_range", HTTPStatus: http.StatusBadRequest,
+		Message: "from must be before to",
+	}
+	ErrInvalidSearch = &Error{
+		Code: "invalid_search", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid appointment search",
+	}
+	ErrInvalidPatientSearch = &Error{
+		Code: "invalid_patient_search", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid patient search",
+	}
+	ErrInvalidSort = &Error{
+		Code: "invalid_sort", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid sort",
+	}
+	ErrInvalidCancellation = &Error{
+		Code: "invalid_cancellation", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid cancellation",
+	}
+	ErrInvalidReason = &Error{
+		Code: "invalid_reason", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid reason for the visit",
+	}
+	ErrInvalidNote = &Error{
+		Code: "invalid_note", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid appointment note",
+	}
+	ErrInvalidReschedule = &Error{
+		Code: "invalid_reschedule", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid reschedule",
+	}
+	ErrInvalidReassignment = &Error{
+		Code: "invalid_reassignment", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid reassignment",
+	}
+	ErrInvalidDeactivation = &Error{
+		Code: "invalid_deactivation", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid deactivation",
+	}
+	ErrInvalidDevice = &Error{
+		Code: "invalid_device", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid device",
+	}
+	ErrInvalidPhone = &Error{
+		Code: "invalid_phone", HTTPStatus: http.StatusBadRequest,
+		Message: "phone must be a number in E.164 format, e.g. +14155550123",
+	}
+	ErrInvalidSMSReply = &Error{
+		Code: "invalid_sms_reply", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid sms reply",
+	}
+	ErrInvalidAPIKey = &Error{
+		Code: "invalid_api_key", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid api key",
+	}
+	ErrInvalidSlotSearch = &Error{
+		Code: "invalid_slot_search", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid slot search",
+	}
+	ErrInvalidSeries = &Error{
+		Code: "invalid_series", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid series",
+	}
+	ErrInvalidSlotCount = &Error{
+		Code: "invalid_slot_count", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid slot count",
+	}
+	ErrInvalidResource = &Error{
+		Code: "invalid_resource", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid resource requirement",
+	}
+	ErrInvalidBookingWindow = &Error{
+		Code: "invalid_booking_window", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid booking window",
+	}
+	ErrInvalidIntakeTemplate = &Error{
+		Code: "invalid_intake_template", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid intake template",
+	}
+	ErrInvalidAvailabilityTemplate = &Error{
+		Code: "invalid_availability_template", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid availability template",
+	}
+	ErrInvalidSlotGeneration = &Error{
+		Code: "invalid_slot_generation", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid slot generation",
+	}
+	ErrInvalidSlotChange = &Error{
+		Code: "invalid_slot_change", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid slot split or merge",
+	}
+	ErrInvalidIntakeAnswers = &Error{
+		Code: "invalid_intake_answers", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid intake answers",
+	}
+	ErrInvalidFeedback = &Error{
+		Code: "invalid_feedback", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid feedback",
+	}
+	ErrInvalidClinician = &Error{
+		Code: "invalid_clinician", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid clinician",
+	}
+	ErrInvalidPatient = &Error{
+		Code: "invalid_patient", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid patient",
+	}
+	ErrInvalidGuest = &Error{
+		Code: "invalid_guest", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid guest details",
+	}
+	ErrInvalidRetentionPolicy = &Error{
+		Code: "invalid_retention_policy", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid retention policy",
+	}
+	ErrInvalidAttachment = &Error{
+		Code: "invalid_attachment", HTTPStatus: http.StatusBadRequest,
+		Message: "invalid attachment",
+	}
+	ErrAttachmentTooLarge = &Error{
+		Code: "attachment_too_large", HTTPStatus: http.StatusRequestEntityTooLarge,
+		Message: "attachment is too large",
+	}
+	ErrUnsupportedAttachmentType = &Error{
+		Code: "unsupported_attachment_type", HTTPStatus: http.StatusUnsupportedMediaType,
+		Message: "attachment type is not allowed",
+	}
+	ErrAttachmentInfected = &Error{
+		Code: "attachment_infected", HTTPStatus: http.StatusUnprocessableEntity,
+		Message: "attachment failed the virus scan",
+	}
+)
+
+// Not permitted
+var (
+	ErrInsufficientScope = &Error{
+		Code: "insufficient_scope", HTTPStatus: http.StatusForbidden,
+		Message: "the api key is not allowed to do this",
+	}
+)
+
+// Unavailable
+var (
+	ErrAttachmentsDisabled = &Error{
+		Code: "attachments_disabled", HTTPStatus: http.StatusServiceUnavailable,
+		Message: "attachment storage is not configured",
+	}
+	ErrAttachmentScanFailed = &Error{
+		Code: "attachment_scan_failed", HTTPStatus: http.StatusServiceUnavailable,
+		Message: "the virus scanner could not check the attachment, please retry", Retryable: true,
+	}
+	ErrAccessNotRecorded = &Error{
+		Code: "access_not_recorded", HTTPStatus: http.StatusServiceUnavailable,
+		Message: "access to patient data could not be recorded, please retry", Retryable: true,
+	}
+)
@@ -0,0 +1,57 @@
+package appointment
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// expiryBacklogBounds are the lower bounds of the overdue ranges of an
+// expiry backlog, shortest first. Each range runs to the next bound; the
+// last has no upper bound.
+var expiryBacklogBounds = []time.Duration{0, time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour}
+
+// ExpiryBacklogBucket counts holds overdue by at least From and less than
+// To. To is 0 for the last bucket, which has no upper bound.
+type ExpiryBacklogBucket struct {
+	From  time.Duration
+	To    time.Duration
+	Count int
+}
+
+// ExpiryBacklog is how many pending holds had passed at At without being
+// expired, in buckets by how long ago they passed
+type ExpiryBacklog struct {
+	At      time.Time
+	Total   int
+	Oldest  *time.Time // the earliest passed deadline, nil when Total is 0
+	Buckets []ExpiryBacklogBucket
+}
+
+// ExpiryBacklog reports the pending appointments whose hold has passed but
+// that the expiry worker has not expired yet. A worker keeping up leaves
+// a few in the first bucket at most; counts further along mean runs are
+// failing or falling behind.
+func (s *Service) ExpiryBacklog(ctx context.Context) (*ExpiryBacklog, error) {
+	now := s.clock.Now()
+	cutoffs := make([]time.Time, len(expiryBacklogBounds))
+	for i, d := range expiryBacklogBounds {
+		cutoffs[i] = now.Add(-d)
+	}
+	counts, oldest, err := s.repo.CountOverduePending(ctx, cutoffs)
+	if err != nil {
+		return nil, fmt.Errorf("expiry backlog: %w", err)
+	}
+
+	// counts[i] includes every hold overdue by at least bound i
+	backlog := &ExpiryBacklog{At: now, Total: counts[0], Oldest: oldest}
+	for i, from := range expiryBacklogBounds {
+		bucket := ExpiryBacklogBucket{From: from, Count: counts[i]}
+		if i+1 < len(expiryBacklogBounds) {
+			bucket.To = expiryBacklogBounds[i+1]
+			bucket.Count -= counts[i+1]
+		}
+		backlog.Buckets = append(backlog.Buckets, bucket)
+	}
+	return backlog, nil
+}
@@ -0,0 +1,147 @@
+package appointment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// EventFeedbackRequested asks downstream notification to request
+	// feedback from the patient of an appointment that has ended. It is
+	// logged at most once per appointment.
+	EventFeedbackRequested = "APPOINTMENT_FEEDBACK_REQUESTED"
+	// EventFeedbackReceived is logged when a patient rates an appointment
+	EventFeedbackReceived = "APPOINTMENT_FEEDBACK_RECEIVED"
+)
+
+const (
+	maxFeedbackCommentLen = 2000
+
+	// feedbackRequestLookback bounds how long ago an appointment may have
+	// ended to still be sent a request, so a worker starting after a long
+	// outage does not ask about old visits
+	feedbackRequestLookback = 24 * time.Hour
+	// feedbackRequestBatch bounds the requests sent per run; the rest are
+	// sent by the next
+	feedbackRequestBatch = 500
+
+	// MaxClinicianFeedback bounds the comments ClinicianFeedback returns
+	MaxClinicianFeedback = 1000
+)
+
+// SubmitFeedback records the patient's rating of 1 to 5 and optional comment
+// for a confirmed, checked-in or completed appointment. Feedback is taken
+// from the end of its last slot for cfg.FeedbackWindow, once per appointment.
+func (s *Service) SubmitFeedback(ctx context.Context, appointmentID uuid.UUID, rating int, comment string) (*Feedback, error) {
+	if rating < 1 || rating > 5 {
+		return nil, fmt.Errorf("%w: rating must be from 1 to 5", ErrInvalidFeedback)
+	}
+	comment = strings.TrimSpace(comment)
+	if len(comment) > maxFeedbackCommentLen {
+		return nil, fmt.Errorf("%w: comment is longer than %d bytes", ErrInvalidFeedback, maxFeedbackCommentLen)
+	}
+
+	appt, err := s.repo.GetAppointmentByID(ctx, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("get appointment: %w", err)
+	}
+	switch appt.Status {
+	case StatusConfirmed, StatusCheckedIn, StatusCompleted:
+	default:
+		return nil, fmt.Errorf("%w: it is %s", ErrFeedbackNotOpen, appt.Status)
+	}
+	slots, err := s.repo.ListAppointmentSlots(ctx, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("get appointment slots: %w", err)
+	}
+	if len(slots) == 0 {
+		return nil, fmt.Errorf("get appointment slots: %w", ErrSlotNotFound)
+	}
+	end := slots[len(slots)-1].EndTime
+	now := s.clock.Now()
+	switch {
+	case now.Before(end):
+		return nil, fmt.Errorf("%w: it ends at %s", ErrFeedbackNotOpen, end.UTC().Format(time.RFC3339))
+	case now.After(end.Add(s.cfg.FeedbackWindow)):
+		return nil, fmt.Errorf("%w: feedback closed at %s", ErrFeedbackNotOpen, end.Add(s.cfg.FeedbackWindow).UTC().Format(time.RFC3339))
+	}
+
+	f := Feedback{AppointmentID: appointmentID, ClinicianID: slots[0].PractitionerID, Rating: rating}
+	if comment != "" {
+		f.Comment = &comment
+	}
+	saved, err := s.repo.InsertFeedback(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("save feedback: %w", err)
+	}
+	s.logEvent(ctx, appointmentID, EventFeedbackReceived, map[string]any{
+		"clinician_id": saved.ClinicianID,
+		"rating":       saved.Rating,
+	})
+	return saved, nil
+}
+
+// GetFeedback returns the feedback given for the appointment
+func (s *Service) GetFeedback(ctx context.Context, appointmentID uuid.UUID) (*Feedback, error) {
+	f, err := s.repo.GetFeedback(ctx, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("get feedback: %w", err)
+	}
+	return f, nil
+}
+
+// ClinicianRatings aggregates the feedback given since then by clinician,
+// leaving out clinicians with fewer than minCount ratings
+func (s *Service) ClinicianRatings(ctx context.Context, since time.Time, minCount int) ([]ClinicianRating, error) {
+	ratings, err := s.repo.ListClinicianRatings(ctx, since, max(minCount, 1))
+	if err != nil {
+		return nil, fmt.Errorf("list clinician ratings: %w", err)
+	}
+	return ratings, nil
+}
+
+// ClinicianFeedback returns the newest feedback given for the clinician's
+// appointments, up to limit
+func (s *Service) ClinicianFeedback(ctx context.Context, clinicianID uuid.UUID, limit int) ([]Feedback, error) {
+	if _, err := s.lookupClinician(ctx, clinicianID); err != nil {
+		return nil, fmt.Errorf("get clinician: %w", err)
+	}
+	feedback, err := s.repo.ListClinicianFeedback(ctx, clinicianID, min(limit, MaxClinicianFeedback))
+	if err != nil {
+		return nil, fmt.Errorf("list clinician feedback: %w", err)
+	}
+	return feedback, nil
+}
+
+// SendFeedbackRequests logs an EventFeedbackRequested, for webhooks to
+// notify the patient, for each confirmed appointment that ended in the last
+// day without feedback, and returns how many it sent. An appointment is
+// asked once. Once stop is closed the rest is left for the next run. stop
+// may be nil.
+func (s *Service) SendFeedbackRequests(ctx context.Context, stop <-chan struct{}) (int, error) {
+	now := s.clock.Now()
+	due, err := s.repo.ListFeedbackRequests(ctx, now.Add(-feedbackRequestLookback), now, EventFeedbackRequested, feedbackRequestBatch)
+	if err != nil {
+		return 0, fmt.Errorf("list feedback requests: %w", err)
+	}
+
+	sent := 0
+	for _, req := range due {
+		select {
+		case <-stop:
+			return sent, nil
+		default:
+		}
+		s.logEvent(ctx, req.AppointmentID, EventFeedbackRequested, map[string]any{
+			"clinician_id": req.ClinicianID,
+			"slot_end":     req.SlotEnd.UTC().Format(time.RFC3339),
+			"closes_at":    req.SlotEnd.Add(s.cfg.FeedbackWindow).UTC().Format(time.RFC3339),
+		})
+		sent++
+	}
+	return sent, nil
+}
@@ -0,0 +1,62 @@
+package appointment
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+var funnelEvents = metrics.NewCounter(
+	"appointment_funnel_events_total",
+	"Appointment lifecycle events by event (created, approval_requested, confirmed, rejected, expired, cancelled, checked_in, completed, no_show), specialty, clinic and tenant.",
+	"event", "specialty", "clinic", "tenant",
+)
+
+// funnelStages names the events counted in the booking funnel
+var funnelStages = map[string]string{
+	EventAppointmentCreated:           "created",
+	EventAppointmentApprovalRequested: "approval_requested",
+	EventAppointmentConfirmed:         "confirmed",
+	EventAppointmentRejected:          "rejected",
+	EventAppointmentExpired:           "expired",
+	EventAppointmentCancelled:         "cancelled",
+	EventAppointmentCheckedIn:         "checked_in",
+	EventAppointmentCompleted:         "completed",
+	EventAppointmentNoShow:            "no_show",
+}
+
+// noSegment labels a funnel event whose clinician has no specialty or
+// clinic, or that was not made for a tenant
+const noSegment = "none"
+
+// countFunnel counts a funnel event against the specialty and clinic of the
+// appointment's clinician and the tenant of ctx. Events from the workers,
+// such as expiries, are not made for a tenant.
+func (s *Service) countFunnel(ctx context.Context, appointmentID uuid.UUID, eventType string) {
+	stage, ok := funnelStages[eventType]
+	if !ok {
+		return
+	}
+
+	specialty, clinic := noSegment, noSegment
+	detail, err := s.repo.GetAppointmentDetail(ctx, appointmentID, DetailFields{Clinician: true})
+	if err != nil {
+		log.Printf("failed to load clinician of appointment %s for funnel metrics: %v", appointmentID, err)
+	} else if c := detail.Clinician; c != nil {
+		if c.Specialty != nil {
+			specialty = *c.Specialty
+		}
+		if c.ClinicID != nil {
+			clinic = c.ClinicID.String()
+		}
+	}
+	tenant := shard.Tenant(ctx)
+	if tenant == "" {
+		tenant = noSegment
+	}
+	funnelEvents.Inc(stage, specialty, clinic, tenant)
+}
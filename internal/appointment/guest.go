@@ -0,0 +1,76 @@
+package appointment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxOpenSlots bounds the slots of one SearchSlots page
+	MaxOpenSlots = 200
+
+	maxGuestNameLength = 200
+)
+
+// SearchOpenSlots returns one page of the clinic's slots that can still be
+// booked, as SearchSlots does for open slots
+func (s *Service) SearchOpenSlots(ctx context.Context, q SlotSearch) (*SlotSearchResult, error) {
+	return s.SearchSlots(ctx, SlotQuery{
+		ClinicID:  &q.ClinicID,
+		Specialty: q.Specialty,
+		Status:    SlotOpen,
+		From:      q.From,
+		To:        q.To,
+		Limit:     q.Limit,
+		Token:     q.Token,
+	})
+}
+
+// BookAsGuest books and confirms a slot of the clinic for someone who is
+// not signed in. The patient is found by email, or created with the name
+// given; an existing patient keeps their name. The appointment ends up
+// confirmed, or awaiting approval where the clinic triages bookings. A slot
+// of another clinic is reported as not found.
+func (s *Service) BookAsGuest(ctx context.Context, g GuestBooking) (*Booking, error) {
+	name := strings.TrimSpace(g.Name)
+	if name == "" || len(name) > maxGuestNameLength {
+		return nil, fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidGuest, maxGuestNameLength)
+	}
+	email, ok := normalizeEmail(g.Email)
+	if !ok {
+		return nil, fmt.Errorf("%w: email must be a plain email address", ErrInvalidGuest)
+	}
+
+	slot, err := s.repo.GetSlotByID(ctx, g.SlotID)
+	if err != nil {
+		return nil, fmt.Errorf("get slot: %w", err)
+	}
+	clinicID, err := s.slotClinic(ctx, slot)
+	if err != nil {
+		return nil, err
+	}
+	if clinicID != g.ClinicID {
+		return nil, ErrSlotNotFound
+	}
+
+	patient, err := s.repo.UpsertPatientByEmail(ctx, Patient{ID: uuid.New(), Name: name, Email: &email})
+	if err != nil {
+		return nil, fmt.Errorf("find patient: %w", err)
+	}
+	s.patients.forget(ctx, patient.ID)
+
+	booking, err := s.Book(ctx, BookingRequest{SlotID: slot.ID, PatientID: patient.ID, SlotCount: 1})
+	if err != nil {
+		return nil, err
+	}
+	// The hold lapses on its own if confirming fails
+	appt, err := s.ConfirmAppointment(ctx, booking.ID)
+	if err != nil {
+		return nil, err
+	}
+	booking.Appointment = *appt
+	return booking, nil
+}
@@ -0,0 +1,103 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventHoldTransferred is logged when staff move a hold to another slot.
+// The appointment keeps its ID and deadline.
+const EventHoldTransferred = "APPOINTMENT_HOLD_TRANSFERRED"
+
+// TransferHold moves a pending hold to another open slot of the same
+// clinic before it is confirmed, e.g. when the clinician asks for the
+// patient to be seen at another time. Unlike a reschedule the hold is not
+// replaced: it keeps its ID and its expires_at, so the patient has no more
+// and no less time to confirm than before. The slots are checked as for
+// RescheduleAppointment, and the move runs in one transaction under the
+// locks of both slots, subject to the new slot's capacity. A series
+// occurrence follows its appointment and becomes an exception. An
+// appointment that is not pending returns ErrInvalidStatusTransition, and
+// one whose hold has passed ErrAppointmentExpiredState.
+func (s *Service) TransferHold(ctx context.Context, id, slotID uuid.UUID) (*Appointment, error) {
+	appt, err := s.repo.GetAppointmentByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrAppointmentNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load appointment: %w", err)
+	}
+	now := s.clock.Now()
+	switch {
+	case appt.Status == StatusExpired:
+		return nil, ErrAppointmentExpiredState
+	case appt.Status != StatusPending:
+		return nil, fmt.Errorf("%w: appointment is %s", ErrInvalidStatusTransition, appt.Status)
+	case appt.ExpiresAt != nil && now.After(*appt.ExpiresAt):
+		return nil, fmt.Errorf("%w: the hold passed at %s", ErrAppointmentExpiredState, appt.ExpiresAt.UTC().Format(time.RFC3339))
+	case appt.SlotID == slotID:
+		return nil, fmt.Errorf("%w: the appointment is already in the slot", ErrInvalidReschedule)
+	}
+
+	err = s.runStage(ctx, StagePatientLookup, func(ctx context.Context) error {
+		return s.checkBookablePatient(ctx, appt.PatientID)
+	})
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) || errors.Is(err, ErrPatientDeactivated) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load patient: %w", err)
+	}
+
+	from, to, err := s.rescheduleSlots(ctx, appt, slotID)
+	if err != nil {
+		return nil, err
+	}
+
+	var moved *Appointment
+	err = s.runStage(ctx, StageLockSection, func(ctx context.Context) error {
+		return s.withSlotLocks(ctx, []*AppointmentSlot{from, to}, func(ctx context.Context) error {
+			return s.repo.WithTx(ctx, func(tx Repository) error {
+				if err := s.checkSlotRoom(ctx, tx, to); err != nil {
+					return err
+				}
+				updated, err := tx.TransferHold(ctx, appt.ID, from.ID, to.ID, now)
+				if err != nil {
+					if errors.Is(err, ErrAppointmentNotFound) {
+						// confirmed, cancelled or expired since it was read
+						return ErrInvalidStatusTransition
+					}
+					return err
+				}
+				inSeries, err := tx.MoveAppointmentOccurrence(ctx, appt.ID, appt.ID)
+				if err != nil {
+					return err
+				}
+				if inSeries && to.PractitionerID != from.PractitionerID {
+					return fmt.Errorf("%w: slot belongs to another clinician", ErrSeriesSlotUnavailable)
+				}
+				moved = updated
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		if mapped := lockError(err); mapped != err {
+			return nil, mapped
+		}
+		return nil, fmt.Errorf("transfer hold: %w", err)
+	}
+
+	s.logEvent(ctx, moved.ID, EventHoldTransferred, map[string]any{
+		"patient_id":       moved.PatientID.String(),
+		"previous_slot_id": from.ID.String(),
+		"slot_id":          to.ID.String(),
+		"start_time":       to.StartTime,
+		"expires_at":       moved.ExpiresAt,
+	})
+	return moved, nil
+}
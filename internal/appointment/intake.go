@@ -0,0 +1,324 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// EventIntakeCompleted is logged when the last required question of an
+	// appointment's intake form is answered
+	EventIntakeCompleted = "APPOINTMENT_INTAKE_COMPLETED"
+	// EventIntakeReminder asks downstream notification to remind the patient
+	// of an incomplete intake form. It is logged at most once per appointment.
+	EventIntakeReminder = "APPOINTMENT_INTAKE_REMINDER"
+)
+
+// intakeQuestionID is the form of question IDs, which key answers
+var intakeQuestionID = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+
+const (
+	maxIntakeQuestions = 100
+	maxIntakeAnswerLen = 4000
+
+	// intakeReminderBatch bounds the reminders sent per run; the rest are
+	// sent by the next
+	intakeReminderBatch = 500
+)
+
+// Intake is an appointment's intake form: the template of its slot type,
+// nil when none is configured, and the answers given so far, nil before the
+// first
+type Intake struct {
+	AppointmentID uuid.UUID
+	Status        IntakeStatus
+	Template      *IntakeTemplate
+	Response      *IntakeResponse
+}
+
+// ListIntakeTemplates returns every intake template by slot type
+func (s *Service) ListIntakeTemplates(ctx context.Context) ([]IntakeTemplate, error) {
+	templates, err := s.repo.ListIntakeTemplates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list intake templates: %w", err)
+	}
+	return templates, nil
+}
+
+// PutIntakeTemplate creates or replaces the intake template of t.SlotType.
+// Answers already given are kept; those to removed questions are dropped at
+// the next submission.
+func (s *Service) PutIntakeTemplate(ctx context.Context, t IntakeTemplate) (*IntakeTemplate, error) {
+	t.SlotType = strings.TrimSpace(t.SlotType)
+	if t.SlotType == "" {
+		return nil, fmt.Errorf("%w: slot type is required", ErrInvalidIntakeTemplate)
+	}
+	if err := validateIntakeQuestions(t.Questions); err != nil {
+		return nil, err
+	}
+
+	saved, err := s.repo.PutIntakeTemplate(ctx, t)
+	if err != nil {
+		return nil, fmt.Errorf("put intake template: %w", err)
+	}
+	return saved, nil
+}
+
+// DeleteIntakeTemplate stops asking for an intake form for the slot type.
+// Answers already given are kept.
+func (s *Service) DeleteIntakeTemplate(ctx context.Context, slotType string) error {
+	if err := s.repo.DeleteIntakeTemplate(ctx, slotType); err != nil {
+		return fmt.Errorf("delete intake template: %w", err)
+	}
+	return nil
+}
+
+func validateIntakeQuestions(questions []IntakeQuestion) error {
+	if len(questions) == 0 || len(questions) > maxIntakeQuestions {
+		return fmt.Errorf("%w: a template has 1 to %d questions", ErrInvalidIntakeTemplate, maxIntakeQuestions)
+	}
+	seen := make(map[string]bool, len(questions))
+	for i, q := range questions {
+		switch {
+		case !intakeQuestionID.MatchString(q.ID):
+			return fmt.Errorf("%w: question %d: id must be 1-64 lower-case letters, digits or '_'", ErrInvalidIntakeTemplate, i+1)
+		case seen[q.ID]:
+			return fmt.Errorf("%w: question id %q is used twice", ErrInvalidIntakeTemplate, q.ID)
+		case strings.TrimSpace(q.Label) == "":
+			return fmt.Errorf("%w: question %s has no label", ErrInvalidIntakeTemplate, q.ID)
+		}
+		seen[q.ID] = true
+
+		switch q.Type {
+		case IntakeChoice:
+			if len(q.Options) == 0 {
+				return fmt.Errorf("%w: choice question %s has no options", ErrInvalidIntakeTemplate, q.ID)
+			}
+			for j, opt := range q.Options {
+				if strings.TrimSpace(opt) == "" || slices.Contains(q.Options[:j], opt) {
+					return fmt.Errorf("%w: options of question %s must be distinct and not empty", ErrInvalidIntakeTemplate, q.ID)
+				}
+			}
+		case IntakeText, IntakeYesNo, IntakeNumber, IntakeDate:
+			if len(q.Options) > 0 {
+				return fmt.Errorf("%w: only choice questions have options, not %s", ErrInvalidIntakeTemplate, q.ID)
+			}
+		default:
+			return fmt.Errorf("%w: question %s has unknown type %q, expected text, yes_no, choice, number or date",
+				ErrInvalidIntakeTemplate, q.ID, q.Type)
+		}
+	}
+	return nil
+}
+
+// GetIntake returns the appointment's intake form and answers
+func (s *Service) GetIntake(ctx context.Context, appointmentID uuid.UUID) (*Intake, error) {
+	detail, err := s.repo.GetAppointmentDetail(ctx, appointmentID, DetailFields{Slot: true})
+	if err != nil {
+		return nil, fmt.Errorf("get appointment: %w", err)
+	}
+	template, err := s.slotIntakeTemplate(ctx, detail.Slot)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.repo.GetIntakeResponse(ctx, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("get intake response: %w", err)
+	}
+	return &Intake{
+		AppointmentID: appointmentID,
+		Status:        intakeStatus(template, response),
+		Template:      template,
+		Response:      response,
+	}, nil
+}
+
+// SubmitIntake records answers to the appointment's intake form, keyed by
+// question ID. They are merged into earlier answers, so a form can be filled
+// in over several visits; an empty answer clears one. The form is complete
+// once every required question has an answer.
+func (s *Service) SubmitIntake(ctx context.Context, appointmentID uuid.UUID, answers map[string]string) (*Intake, error) {
+	detail, err := s.repo.GetAppointmentDetail(ctx, appointmentID, DetailFields{Slot: true})
+	if err != nil {
+		return nil, fmt.Errorf("get appointment: %w", err)
+	}
+	switch detail.Status {
+	case StatusPending, StatusPendingApproval, StatusConfirmed:
+	default:
+		return nil, fmt.Errorf("%w: it is %s", ErrAppointmentNotActive, detail.Status)
+	}
+	template, err := s.slotIntakeTemplate(ctx, detail.Slot)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, ErrIntakeTemplateNotFound
+	}
+
+	previous, err := s.repo.GetIntakeResponse(ctx, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("get intake response: %w", err)
+	}
+	merged := make(map[string]string)
+	if previous != nil {
+		maps.Copy(merged, previous.Answers)
+	}
+
+	questions := make(map[string]IntakeQuestion, len(template.Questions))
+	for _, q := range template.Questions {
+		questions[q.ID] = q
+	}
+	for id, answer := range answers {
+		q, ok := questions[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: the form has no question %q", ErrInvalidIntakeAnswers, id)
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			delete(merged, id)
+			continue
+		}
+		if err := checkIntakeAnswer(q, answer); err != nil {
+			return nil, err
+		}
+		merged[id] = answer
+	}
+	// Drop answers to questions a newer template removed
+	maps.DeleteFunc(merged, func(id, _ string) bool {
+		_, ok := questions[id]
+		return !ok
+	})
+
+	complete := true
+	for _, q := range template.Questions {
+		if q.Required && merged[q.ID] == "" {
+			complete = false
+		}
+	}
+
+	saved, err := s.repo.PutIntakeResponse(ctx, IntakeResponse{
+		AppointmentID:   appointmentID,
+		TemplateVersion: template.Version,
+		Answers:         merged,
+		Complete:        complete,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("save intake response: %w", err)
+	}
+	if complete && (previous == nil || !previous.Complete) {
+		s.logEvent(ctx, appointmentID, EventIntakeCompleted, map[string]any{
+			"slot_type":        template.SlotType,
+			"template_version": template.Version,
+		})
+	}
+
+	return &Intake{
+		AppointmentID: appointmentID,
+		Status:        intakeStatus(template, saved),
+		Template:      template,
+		Response:      saved,
+	}, nil
+}
+
+func checkIntakeAnswer(q IntakeQuestion, answer string) error {
+	if len(answer) > maxIntakeAnswerLen {
+		return fmt.Errorf("%w: answer to %s is longer than %d bytes", ErrInvalidIntakeAnswers, q.ID, maxIntakeAnswerLen)
+	}
+	var ok bool
+	switch q.Type {
+	case IntakeText:
+		ok = true
+	case IntakeYesNo:
+		ok = answer == "yes" || answer == "no"
+	case IntakeChoice:
+		ok = slices.Contains(q.Options, answer)
+	case IntakeNumber:
+		_, err := strconv.ParseFloat(answer, 64)
+		ok = err == nil
+	case IntakeDate:
+		_, err := time.Parse(time.DateOnly, answer)
+		ok = err == nil
+	}
+	if !ok {
+		return fmt.Errorf("%w: %q is not a valid %s answer to %s", ErrInvalidIntakeAnswers, answer, q.Type, q.ID)
+	}
+	return nil
+}
+
+// slotIntakeTemplate returns the template of the slot's type, nil when it
+// has none
+func (s *Service) slotIntakeTemplate(ctx context.Context, slot *AppointmentSlot) (*IntakeTemplate, error) {
+	if slot == nil || slot.SlotType == nil {
+		return nil, nil
+	}
+	template, err := s.repo.GetIntakeTemplate(ctx, *slot.SlotType)
+	if errors.Is(err, ErrIntakeTemplateNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get intake template: %w", err)
+	}
+	return template, nil
+}
+
+// appointmentIntakeStatus summarises the intake form of an appointment in
+// slot
+func (s *Service) appointmentIntakeStatus(ctx context.Context, id uuid.UUID, slot *AppointmentSlot) (IntakeStatus, error) {
+	template, err := s.slotIntakeTemplate(ctx, slot)
+	if err != nil || template == nil {
+		return IntakeNotRequired, err
+	}
+	response, err := s.repo.GetIntakeResponse(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("get intake response: %w", err)
+	}
+	return intakeStatus(template, response), nil
+}
+
+func intakeStatus(template *IntakeTemplate, response *IntakeResponse) IntakeStatus {
+	switch {
+	case template == nil:
+		return IntakeNotRequired
+	case response != nil && response.Complete:
+		return IntakeComplete
+	default:
+		return IntakeIncomplete
+	}
+}
+
+// SendIntakeReminders logs an EventIntakeReminder, for webhooks to notify
+// the patient, for each confirmed appointment starting within the reminder
+// lead whose intake form is incomplete, and returns how many it sent. An
+// appointment is reminded once. Once stop is closed the rest is left for
+// the next run. stop may be nil.
+func (s *Service) SendIntakeReminders(ctx context.Context, stop <-chan struct{}) (int, error) {
+	now := s.clock.Now()
+	due, err := s.repo.ListIntakeReminders(ctx, now, now.Add(s.cfg.IntakeReminderLead), EventIntakeReminder, intakeReminderBatch)
+	if err != nil {
+		return 0, fmt.Errorf("list intake reminders: %w", err)
+	}
+
+	sent := 0
+	for _, rem := range due {
+		select {
+		case <-stop:
+			return sent, nil
+		default:
+		}
+		s.logEvent(ctx, rem.AppointmentID, EventIntakeReminder, map[string]any{
+			"slot_type":  rem.SlotType,
+			"slot_start": rem.SlotStart.UTC().Format(time.RFC3339),
+		})
+		sent++
+	}
+	return sent, nil
+}
@@ -0,0 +1,85 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// NextAppointmentByPhone returns the upcoming appointment, pending or
+// confirmed, of a patient with the number whose slot starts soonest, with
+// its slot and clinician. A phone tree reads it to the caller and acts on
+// it by its ID, the reference. The key needs ScopeIVRLookup, and a key of a
+// clinic only finds that clinic's appointments.
+func (s *Service) NextAppointmentByPhone(ctx context.Context, key *APIKey, phone string) (*AppointmentDetail, error) {
+	if err := requireScope(key, ScopeIVRLookup); err != nil {
+		return nil, err
+	}
+	normalized, ok := normalizePhone(phone)
+	if !ok {
+		return nil, ErrInvalidPhone
+	}
+
+	appt, err := s.repo.GetNextAppointmentByPhone(ctx, normalized, key.ClinicID, s.clock.Now())
+	if err != nil {
+		if errors.Is(err, ErrAppointmentNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("find next appointment: %w", err)
+	}
+	detail, err := s.repo.GetAppointmentDetail(ctx, appt.ID, DetailFields{Slot: true, Clinician: true})
+	if err != nil {
+		return nil, fmt.Errorf("load appointment: %w", err)
+	}
+	return detail, nil
+}
+
+// ConfirmAppointmentByReference confirms an appointment for a phone tree,
+// as ConfirmAppointment. The key needs ScopeIVRConfirm.
+func (s *Service) ConfirmAppointmentByReference(ctx context.Context, key *APIKey, reference uuid.UUID) (*Appointment, error) {
+	if err := requireScope(key, ScopeIVRConfirm); err != nil {
+		return nil, err
+	}
+	if err := s.checkKeyClinic(ctx, key, reference); err != nil {
+		return nil, err
+	}
+	return s.ConfirmAppointment(ctx, reference)
+}
+
+// CancelAppointmentByReference cancels an appointment at the patient's
+// request for a phone tree, recording the key's prefix on the event. The
+// key needs ScopeIVRCancel.
+func (s *Service) CancelAppointmentByReference(ctx context.Context, key *APIKey, reference uuid.UUID) (*Appointment, error) {
+	if err := requireScope(key, ScopeIVRCancel); err != nil {
+		return nil, err
+	}
+	if err := s.checkKeyClinic(ctx, key, reference); err != nil {
+		return nil, err
+	}
+	return s.CancelAppointment(ctx, reference, "patient_request", map[string]any{
+		"via":     "ivr",
+		"api_key": key.Prefix,
+	})
+}
+
+// checkKeyClinic returns ErrAppointmentNotFound when the key is limited to
+// a clinic the appointment is not in, so a key cannot tell other clinics'
+// appointments exist
+func (s *Service) checkKeyClinic(ctx context.Context, key *APIKey, id uuid.UUID) error {
+	if key.ClinicID == nil {
+		return nil
+	}
+	detail, err := s.repo.GetAppointmentDetail(ctx, id, DetailFields{Clinician: true})
+	if err != nil {
+		if errors.Is(err, ErrAppointmentNotFound) {
+			return err
+		}
+		return fmt.Errorf("load appointment: %w", err)
+	}
+	if c := detail.Clinician; c == nil || c.ClinicID == nil || *c.ClinicID != *key.ClinicID {
+		return ErrAppointmentNotFound
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package appointment
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+type BookingIntentState string
+
+const (
+	IntentPending   BookingIntentState = "pending"
+	IntentCommitted BookingIntentState = "committed"
+	IntentAborted   BookingIntentState = "aborted"
+)
+
+// BookingIntent is the write-ahead record of one booking attempt. It is
+// written before the slot lock is taken and resolved in the same transaction
+// that creates the appointment, so a pending intent older than the lock TTL
+// always means the process died mid-booking and no appointment exists.
+type BookingIntent struct {
+	ID            uuid.UUID
+	SlotID        uuid.UUID
+	PatientID     uuid.UUID
+	LockToken     string
+	State         BookingIntentState
+	AppointmentID *uuid.UUID
+	CreatedAt     time.Time
+	ResolvedAt    *time.Time
+}
+
+var (
+	journalIntents = metrics.NewCounter(
+		"booking_journal_intents_total",
+		"Booking intents by final state.",
+		"state",
+	)
+	journalReconciled = metrics.NewCounter(
+		"booking_journal_reconciled_total",
+		"Stale pending intents aborted by startup reconciliation.",
+	)
+	journalLocksReleased = metrics.NewCounter(
+		"booking_journal_locks_released_total",
+		"Slot locks still held by a stale intent and released by reconciliation.",
+	)
+	journalPending = metrics.NewGauge(
+		"booking_journal_stale_pending",
+		"Stale pending intents found by the last reconciliation run.",
+	)
+)
+
+// ReconcileResult summarizes one reconciliation run
+type ReconcileResult struct {
+	Stale         int
+	Aborted       int
+	LocksReleased int
+}
+
+// ReconcileBookingIntents aborts intents that have been pending since before
+// cutoff and frees any slot lock still held with their token. releaser may be
+// nil when the locker is process-local. Call it on startup, before serving.
+func (s *Service) ReconcileBookingIntents(ctx context.Context, releaser redisclient.LockReleaser, cutoff time.Time) (ReconcileResult, error) {
+	var res ReconcileResult
+
+	stale, err := s.repo.ListPendingBookingIntents(ctx, cutoff)
+	if err != nil {
+		return res, fmt.Errorf("list pending booking intents: %w", err)
+	}
+	res.Stale = len(stale)
+	journalPending.Set(float64(len(stale)))
+
+	for _, intent := range stale {
+		if releaser != nil {
+			released, err := releaser.ReleaseSlotLock(ctx, intent.SlotID, intent.LockToken)
+			if err != nil {
+				log.Printf("reconcile intent %s: %v", intent.ID, err)
+				continue
+			}
+			if released {
+				res.LocksReleased++
+				journalLocksReleased.Inc()
+			}
+		}
+
+		if err := s.repo.ResolveBookingIntent(ctx, intent.ID, IntentAborted, nil); err != nil {
+			log.Printf("reconcile intent %s: %v", intent.ID, err)
+			continue
+		}
+		res.Aborted++
+		journalReconciled.Inc()
+	}
+
+	return res, nil
+}
+
+// abortIntent marks an intent aborted after a failed booking. Failure only
+// leaves it for reconciliation, so it is logged rather than returned. It
+// still runs when the booking failed because the caller went away.
+func (s *Service) abortIntent(ctx context.Context, id uuid.UUID) {
+	err := s.runStage(context.WithoutCancel(ctx), StageJournal, func(ctx context.Context) error {
+		return s.repo.ResolveBookingIntent(ctx, id, IntentAborted, nil)
+	})
+	if err != nil {
+		log.Printf("failed to abort booking intent %s: %v", id, err)
+		return
+	}
+	journalIntents.Inc(string(IntentAborted))
+}
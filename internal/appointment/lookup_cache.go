@@ -0,0 +1,164 @@
+package appointment
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/clock"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+// lookupCacheSize bounds the entries of each lookup cache. A full cache
+// first drops what has expired, then arbitrary entries.
+const lookupCacheSize = 10_000
+
+var (
+	lookupCacheHits = metrics.NewCounter(
+		"lookup_cache_hits_total",
+		"Patient and clinician reads answered from the in-process cache, by kind.",
+		"kind",
+	)
+	lookupCacheMisses = metrics.NewCounter(
+		"lookup_cache_misses_total",
+		"Patient and clinician reads that went to the database, by kind.",
+		"kind",
+	)
+)
+
+// lookupKey names a row within the shard its request was routed to, so a
+// tenant never sees a row read for another
+type lookupKey struct {
+	tenant string
+	shard  string
+	id     uuid.UUID
+}
+
+func newLookupKey(ctx context.Context, id uuid.UUID) lookupKey {
+	return lookupKey{tenant: shard.Tenant(ctx), shard: shard.Name(ctx), id: id}
+}
+
+func (k lookupKey) String() string {
+	return k.tenant + "/" + k.shard + "/" + k.id.String()
+}
+
+type lookupEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// lookupCache keeps rows read on the booking path for ttl, so repeated
+// bookings by one patient or of one clinician's slots skip the read.
+// Concurrent misses for one row share a single read. Only rows found are
+// kept: a row created on another instance must be seen straight away.
+// Updates made through the service drop the row here; other instances
+// keep their copy until it expires, so ttl bounds how stale a read is.
+type lookupCache[T any] struct {
+	kind  string
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[lookupKey]lookupEntry[T]
+	// gen counts forgets, so a read that started before one does not put
+	// back what it dropped
+	gen   uint64
+	reads singleflight.Group
+}
+
+func newLookupCache[T any](kind string, ttl time.Duration, c clock.Clock) *lookupCache[T] {
+	return &lookupCache[T]{kind: kind, ttl: ttl, clock: c, entries: make(map[lookupKey]lookupEntry[T])}
+}
+
+// get returns a copy of the row with id, calling load on a miss. With a
+// ttl of 0 it always calls load.
+func (c *lookupCache[T]) get(ctx context.Context, id uuid.UUID, load func(ctx context.Context) (*T, error)) (*T, error) {
+	if c.ttl <= 0 {
+		return load(ctx)
+	}
+
+	key := newLookupKey(ctx, id)
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	gen := c.gen
+	c.mu.Unlock()
+	if ok && c.clock.Now().Before(e.expires) {
+		lookupCacheHits.Inc(c.kind)
+		v := e.value
+		return &v, nil
+	}
+	lookupCacheMisses.Inc(c.kind)
+
+	// The shared read runs on the first caller's context; the others wait
+	// for it even if theirs is cancelled first
+	v, err, _ := c.reads.Do(key.String(), func() (any, error) {
+		row, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.put(key, *row, gen)
+		return *row, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	row := v.(T)
+	return &row, nil
+}
+
+func (c *lookupCache[T]) put(key lookupKey, v T, gen uint64) {
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	if len(c.entries) >= lookupCacheSize {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < lookupCacheSize {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = lookupEntry[T]{value: v, expires: now.Add(c.ttl)}
+}
+
+// forget drops the row with id after an update through ctx. Callers
+// after it read the row afresh rather than join a read already running.
+func (c *lookupCache[T]) forget(ctx context.Context, id uuid.UUID) {
+	key := newLookupKey(ctx, id)
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.gen++
+	c.mu.Unlock()
+	c.reads.Forget(key.String())
+}
+
+// lookupPatient reads a patient through the service's cache. Use it for
+// checks on the booking path and existence checks, not for a read whose
+// answer is shown or decides an update.
+func (s *Service) lookupPatient(ctx context.Context, id uuid.UUID) (*Patient, error) {
+	return s.patients.get(ctx, id, func(ctx context.Context) (*Patient, error) {
+		return s.repo.GetPatientByID(ctx, id)
+	})
+}
+
+// lookupClinician reads a clinician through the service's cache, for the
+// same uses as lookupPatient
+func (s *Service) lookupClinician(ctx context.Context, id uuid.UUID) (*Clinician, error) {
+	return s.clinicians.get(ctx, id, func(ctx context.Context) (*Clinician, error) {
+		return s.repo.GetClinicianByID(ctx, id)
+	})
+}
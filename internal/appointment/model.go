@@ -1,6 +1,7 @@
 package appointment
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,8 +14,40 @@ const (
 	StatusConfirmed AppointmentStatus = "confirmed"
 	StatusCancelled AppointmentStatus = "cancelled"
 	StatusExpired   AppointmentStatus = "expired"
+
+	// StatusPendingApproval is a confirmed booking at a clinic that triages
+	// bookings, waiting for a clinician until expires_at
+	StatusPendingApproval AppointmentStatus = "pending_approval"
+	// StatusRejected is a booking a clinician turned down or did not approve
+	// in time
+	StatusRejected AppointmentStatus = "rejected"
+
+	// StatusCheckedIn is a confirmed appointment whose patient has arrived.
+	// It keeps its place like a confirmed one.
+	StatusCheckedIn AppointmentStatus = "checked_in"
+
+	// StatusCompleted and StatusNoShow record whether the patient of a
+	// confirmed or checked-in appointment came, once its last slot has ended
+	StatusCompleted AppointmentStatus = "completed"
+	StatusNoShow    AppointmentStatus = "no_show"
 )
 
+// Valid reports whether s is one of the known appointment statuses
+func (s AppointmentStatus) Valid() bool {
+	switch s {
+	case StatusPending, StatusConfirmed, StatusCancelled, StatusExpired,
+		StatusPendingApproval, StatusRejected, StatusCheckedIn, StatusCompleted, StatusNoShow:
+		return true
+	}
+	return false
+}
+
+// Active reports whether an appointment in status s holds, waits for or has
+// its slot, so it can still be cancelled
+func (s AppointmentStatus) Active() bool {
+	return s == StatusPending || s == StatusPendingApproval || s == StatusConfirmed
+}
+
 type SlotStatus string
 
 const (
@@ -23,20 +56,87 @@ const (
 	SlotDeleted SlotStatus = "deleted"
 )
 
+func (s SlotStatus) Valid() bool {
+	switch s {
+	case SlotOpen, SlotBlocked, SlotDeleted:
+		return true
+	}
+	return false
+}
+
 type Patient struct {
 	ID        uuid.UUID
 	Name      string
 	Email     *string
+	Phone     *string // E.164, the number SMS replies come from
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Set while the account is deactivated: when, by whom and why
+	DeactivatedAt      *time.Time
+	DeactivatedBy      *string
+	DeactivationReason *string
+}
+
+// Deactivated reports whether the patient's account is deactivated
+func (p *Patient) Deactivated() bool {
+	return p.DeactivatedAt != nil
+}
+
+// PatientUpdate changes the fields of a patient that are not nil
+type PatientUpdate struct {
+	Name  *string
+	Email *string
+}
+
+// PatientSearch selects one page of the patients whose name or email
+// contains Query, ignoring case, ordered by name. Deactivated patients are
+// left out unless IncludeDeactivated is set. Token is the NextToken of the
+// previous page.
+type PatientSearch struct {
+	Query              string
+	IncludeDeactivated bool
+	Limit              int
+	Token              string
+}
+
+// PatientPage is one page of patients. NextToken is empty on the last page.
+type PatientPage struct {
+	Patients  []Patient
+	NextToken string
+}
+
+type Clinic struct {
+	ID   uuid.UUID
+	Name string
+	// RequiresApproval makes confirmed bookings wait for a clinician's
+	// approval, see Service.ApproveAppointment
+	RequiresApproval bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 type Clinician struct {
 	ID        uuid.UUID
 	Name      string
 	Specialty *string
+	ClinicID  *uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Set once the clinician is deactivated; their slots cannot be booked
+	DeactivatedAt *time.Time
+}
+
+// Deactivated reports whether the clinician is deactivated
+func (c *Clinician) Deactivated() bool {
+	return c.DeactivatedAt != nil
+}
+
+// ClinicianUpdate changes the fields of a clinician that are not nil
+type ClinicianUpdate struct {
+	Name      *string
+	Specialty *string
 }
 
 type AppointmentSlot struct {
@@ -46,6 +146,7 @@ type AppointmentSlot struct {
 	EndTime        time.Time
 	Status         SlotStatus
 	Capacity       int
+	SlotType       *string
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 }
@@ -60,6 +161,84 @@ type Appointment struct {
 	ExpiresAt *time.Time
 }
 
+// HoldRemaining reports how long a pending appointment keeps its slot
+// reserved, clamped at zero once the hold has lapsed but before the expiry
+// worker has swept it. ok is false for appointments that are not on hold.
+func (a *Appointment) HoldRemaining(now time.Time) (remaining time.Duration, ok bool) {
+	if a.Status != StatusPending || a.ExpiresAt == nil {
+		return 0, false
+	}
+	return max(a.ExpiresAt.Sub(now), 0), true
+}
+
+// ResourceKind is the role of a staff resource
+type ResourceKind string
+
+const (
+	ResourceInterpreter ResourceKind = "interpreter"
+	ResourceChaperone   ResourceKind = "chaperone"
+)
+
+// Valid reports whether k is one of the known resource kinds
+func (k ResourceKind) Valid() bool {
+	return k == ResourceInterpreter || k == ResourceChaperone
+}
+
+// StaffResource is a member of a clinic's staff an appointment can require
+// besides its clinician. Language is set for interpreters only.
+type StaffResource struct {
+	ID        uuid.UUID
+	ClinicID  uuid.UUID
+	Kind      ResourceKind
+	Language  *string
+	Name      string
+	CreatedAt time.Time
+}
+
+// ResourceRequirement asks for one free staff resource of Kind from the
+// clinic of the booked clinician. Interpreters must speak Language.
+type ResourceRequirement struct {
+	Kind     ResourceKind
+	Language string
+}
+
+// SlotBlockers tells which kinds of active appointment span a slot.
+// Holds are counted by status, so one past its deadline counts until the
+// expiry worker resolves it.
+type SlotBlockers struct {
+	Confirmed bool // a confirmed appointment
+	Held      bool // a pending hold or an appointment awaiting approval
+}
+
+// Booking is an appointment with every slot it spans, earliest first, and
+// the staff resources reserved for it. Most span one slot; a procedure
+// booked with a SlotCount spans several back to back.
+type Booking struct {
+	Appointment
+	Slots     []AppointmentSlot
+	Resources []StaffResource
+	Reason    *string
+}
+
+// StartTime is the start of the first slot of the booking
+func (b *Booking) StartTime() time.Time { return b.Slots[0].StartTime }
+
+// EndTime is the end of the last slot of the booking
+func (b *Booking) EndTime() time.Time { return b.Slots[len(b.Slots)-1].EndTime }
+
+// BookingPrecheck is what booking a request would do, found without taking
+// locks or holding anything: the slots and staff it would get, the room
+// left in the fullest slot and whether the clinic would triage it.
+// Conflicts are the patient's active appointments at the same time, which
+// do not stop a booking.
+type BookingPrecheck struct {
+	Slots            []AppointmentSlot
+	Resources        []StaffResource
+	Remaining        int
+	RequiresApproval bool
+	Conflicts        []Appointment
+}
+
 type EventLog struct {
 	ID            int64
 	EventType     string
@@ -68,9 +247,558 @@ type EventLog struct {
 	CreatedAt     time.Time
 }
 
+// ExpiryEventMismatch is an appointment whose APPOINTMENT_EXPIRED events do
+// not match its state: it was logged as expired but is not, or was logged
+// as expired more than once.
+type ExpiryEventMismatch struct {
+	AppointmentID uuid.UUID
+	Status        AppointmentStatus
+	ExpiredEvents int
+	UpdatedAt     time.Time
+}
+
 type AppointmentDetail struct {
 	Appointment
 	Slot      *AppointmentSlot
 	Patient   *Patient
 	Clinician *Clinician
+	Price     *Price // self-pay price of the slot, nil when the clinic has none configured
+
+	// Span lists every slot of a multi-slot appointment, earliest first,
+	// Resources the staff reserved for it and Intake the state of its
+	// intake form. Only GetAppointment sets them, alongside Slot.
+	Span      []AppointmentSlot
+	Resources []StaffResource
+	Intake    IntakeStatus
+
+	// CheckedInAt is when the patient checked in, nil if they have not
+	CheckedInAt *time.Time
+	// Reason is why the patient is coming, as given when booking
+	Reason *string
+	// Notes are the staff's notes on the appointment, oldest first. Only
+	// GetAppointment sets them, alongside Patient.
+	Notes []AppointmentNote
+}
+
+// DetailFields picks the related entities a detail read hydrates. Entities
+// left out stay nil on the AppointmentDetail and their joins are skipped
+// where the query allows it.
+type DetailFields struct {
+	Slot      bool // includes the slot's price
+	Patient   bool
+	Clinician bool
+}
+
+// AllDetailFields hydrates every related entity
+var AllDetailFields = DetailFields{Slot: true, Patient: true, Clinician: true}
+
+// DayRange is one calendar day in some zone, as the instants [Start, End).
+// Days around a DST change are 23 or 25 hours long.
+type DayRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// DayAvailability counts a clinician's slots starting within one day.
+// Deleted slots are not counted.
+type DayAvailability struct {
+	Slots  int
+	Open   int // open with capacity left, and inside the booking window
+	Booked int // confirmed up to capacity
+}
+
+// BookingWindow limits when slots of clinicians with Specialty can be
+// booked: a slot must start at least MinLead after the booking and, when
+// MaxLead is set, at most MaxLead after it
+type BookingWindow struct {
+	Specialty string
+	MinLead   time.Duration
+	MaxLead   *time.Duration
+	UpdatedAt time.Time
+}
+
+// Bookable returns the range of slot starts w admits at now. A nil w admits
+// every slot.
+func (w *BookingWindow) Bookable(now time.Time) DayRange {
+	r := DayRange{Start: unboundedStart, End: unboundedEnd}
+	if w == nil {
+		return r
+	}
+	r.Start = now.Add(w.MinLead)
+	if w.MaxLead != nil {
+		// The end is exclusive, so add the one instant a slot at exactly
+		// MaxLead needs
+		r.End = now.Add(*w.MaxLead + 1)
+	}
+	return r
+}
+
+// Allows reports whether a slot starting at start may be booked at now
+func (w *BookingWindow) Allows(start, now time.Time) bool {
+	r := w.Bookable(now)
+	return !start.Before(r.Start) && start.Before(r.End)
+}
+
+// unboundedStart and unboundedEnd bound a range with no limit on either side
+// but still bind as timestamps on every backend
+var (
+	unboundedStart = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	unboundedEnd   = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// TimelineEntry is one event of a patient's appointment history, with the
+// appointment and slot it belongs to
+type TimelineEntry struct {
+	EventID   int64
+	EventType string
+	Payload   []byte
+	CreatedAt time.Time
+
+	AppointmentID     uuid.UUID
+	AppointmentStatus AppointmentStatus // current, not as of the event
+	SlotID            uuid.UUID
+	SlotStart         time.Time
+	SlotEnd           time.Time
+}
+
+// CalendarDay is the availability of one local date
+type CalendarDay struct {
+	Date time.Time // midnight in the requested zone
+	DayAvailability
+}
+
+// SlotQuote is the self-pay quote for booking a single slot
+type SlotQuote struct {
+	SlotID   uuid.UUID
+	ClinicID uuid.UUID
+	SlotType string
+	Price    Price
+}
+
+// Series is a run of linked appointments for one patient with one
+// clinician, e.g. weekly physiotherapy. Occurrences fall IntervalDays apart
+// at the same wall-clock time in TimeZone and are numbered from 1.
+type Series struct {
+	ID           uuid.UUID
+	PatientID    uuid.UUID
+	ClinicianID  uuid.UUID
+	IntervalDays int
+	TimeZone     string
+	CreatedAt    time.Time
+	Occurrences  []SeriesOccurrence
+}
+
+// SeriesOccurrence is the current appointment of one occurrence and its
+// slot. Exception is set once the occurrence was changed on its own.
+type SeriesOccurrence struct {
+	Number      int
+	Exception   bool
+	Appointment Appointment
+	Slot        AppointmentSlot
+}
+
+// AttachmentKind says what an uploaded attachment is
+type AttachmentKind string
+
+const (
+	AttachmentReferralLetter AttachmentKind = "referral_letter"
+	AttachmentIntakeForm     AttachmentKind = "intake_form"
+	AttachmentOther          AttachmentKind = "other"
+)
+
+// Valid reports whether k is one of the known attachment kinds
+func (k AttachmentKind) Valid() bool {
+	return k == AttachmentReferralLetter || k == AttachmentIntakeForm || k == AttachmentOther
+}
+
+// ScanStatus records the virus scan of an attachment. Infected uploads are
+// refused, so only these two are stored.
+type ScanStatus string
+
+const (
+	ScanClean     ScanStatus = "clean"
+	ScanUnscanned ScanStatus = "unscanned" // no scanner was configured
+)
+
+// Attachment is a file uploaded for an appointment, such as a referral
+// letter. The content is in blob storage under BlobKey.
+type Attachment struct {
+	ID            uuid.UUID
+	AppointmentID uuid.UUID
+	Kind          AttachmentKind
+	Filename      string
+	ContentType   string
+	Size          int64
+	BlobKey       string
+	ScanStatus    ScanStatus
+	CreatedAt     time.Time
+}
+
+// AppointmentNote is a note a staff member added to an appointment, e.g.
+// what was discussed on the phone. Notes are never edited.
+type AppointmentNote struct {
+	ID            uuid.UUID
+	AppointmentID uuid.UUID
+	Author        string
+	Body          string
+	CreatedAt     time.Time
+}
+
+// IntakeQuestionType is the kind of answer an intake question takes
+type IntakeQuestionType string
+
+const (
+	IntakeText   IntakeQuestionType = "text"
+	IntakeYesNo  IntakeQuestionType = "yes_no" // "yes" or "no"
+	IntakeChoice IntakeQuestionType = "choice" // one of Options
+	IntakeNumber IntakeQuestionType = "number"
+	IntakeDate   IntakeQuestionType = "date" // YYYY-MM-DD
+)
+
+// IntakeQuestion is one question of an intake form. ID keys its answer.
+type IntakeQuestion struct {
+	ID       string
+	Label    string
+	Type     IntakeQuestionType
+	Required bool
+	Options  []string // choice questions only
+}
+
+// IntakeTemplate is the questionnaire patients fill in before appointments
+// in slots of SlotType. Version goes up each time it is replaced.
+type IntakeTemplate struct {
+	SlotType  string
+	Version   int
+	Questions []IntakeQuestion
+	UpdatedAt time.Time
+}
+
+// AvailabilityPeriod is a weekly span of a clinician's availability: every
+// Weekday from Start to End, in minutes after midnight
+type AvailabilityPeriod struct {
+	Weekday time.Weekday
+	Start   int
+	End     int
+}
+
+// AvailabilityTemplate is a clinician's weekly availability, which
+// GenerateSlots expands into slots. Periods are in the IANA zone TimeZone
+// and cut into slots of SlotDuration that take Capacity confirmed
+// appointments each.
+type AvailabilityTemplate struct {
+	ClinicianID  uuid.UUID
+	TimeZone     string
+	SlotDuration time.Duration
+	Capacity     int
+	SlotType     *string
+	Periods      []AvailabilityPeriod
+	UpdatedAt    time.Time
+}
+
+// SlotGeneration is the outcome of GenerateSlots: the slots created and how
+// many were skipped because they overlap slots the clinician already has
+type SlotGeneration struct {
+	Slots   []AppointmentSlot
+	Skipped int
+}
+
+// IntakeResponse is a patient's answers to the intake form of an
+// appointment, by question ID. Complete is set once every required question
+// of TemplateVersion is answered.
+type IntakeResponse struct {
+	AppointmentID   uuid.UUID
+	TemplateVersion int
+	Answers         map[string]string
+	Complete        bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// IntakeStatus summarises an appointment's intake form
+type IntakeStatus string
+
+const (
+	IntakeNotRequired IntakeStatus = "not_required" // no template for the slot type
+	IntakeIncomplete  IntakeStatus = "incomplete"
+	IntakeComplete    IntakeStatus = "complete"
+)
+
+// IntakeReminder is a confirmed appointment with an incomplete intake form
+type IntakeReminder struct {
+	AppointmentID uuid.UUID
+	SlotType      string
+	SlotStart     time.Time
+}
+
+// Feedback is a patient's rating, from 1 to 5, of an appointment they
+// attended. ClinicianID is the clinician of its slot.
+type Feedback struct {
+	AppointmentID uuid.UUID
+	ClinicianID   uuid.UUID
+	Rating        int
+	Comment       *string
+	CreatedAt     time.Time
+}
+
+// ClinicianRating aggregates the feedback given for a clinician's
+// appointments. Ratings counts the ratings of 1 to 5 at index 0 to 4.
+type ClinicianRating struct {
+	ClinicianID uuid.UUID
+	Name        string
+	Specialty   *string
+	Count       int
+	Average     float64
+	Ratings     [5]int
+}
+
+// FeedbackRequest is a confirmed appointment that has ended without
+// feedback
+type FeedbackRequest struct {
+	AppointmentID uuid.UUID
+	ClinicianID   uuid.UUID
+	SlotEnd       time.Time
+}
+
+// RetentionEntity is a kind of record a retention policy expires
+type RetentionEntity string
+
+const (
+	// RetainAppointments expires appointments whose last slot ended before
+	// the cutoff, with everything recorded for them
+	RetainAppointments RetentionEntity = "appointments"
+	// RetainEvents expires event log entries created before the cutoff
+	RetainEvents RetentionEntity = "events"
+	// RetainFeedback expires feedback given before the cutoff
+	RetainFeedback RetentionEntity = "feedback"
+)
+
+// RetentionPolicy is how many months each kind of record is kept on a
+// shard. A nil period keeps the records forever. TenantID is the tenant
+// that set the policy, which must be the only tenant on the shard.
+type RetentionPolicy struct {
+	TenantID          string
+	AppointmentMonths *int
+	EventMonths       *int
+	FeedbackMonths    *int
+	UpdatedAt         time.Time
+}
+
+// RetentionRun records one pass of the retention worker over an entity.
+// Matched counts the records past Cutoff when the run started; a dry run
+// deletes none of them.
+type RetentionRun struct {
+	ID           uuid.UUID
+	Entity       RetentionEntity
+	TenantID     string
+	RetainMonths int
+	Cutoff       time.Time
+	DryRun       bool
+	Matched      int
+	Deleted      int
+	StartedAt    time.Time
+	FinishedAt   time.Time
+}
+
+// ActorKind is the kind of caller whose access to patient data is audited
+type ActorKind string
+
+const (
+	// ActorStaff is a staff member named by the X-Staff-ID header
+	ActorStaff ActorKind = "staff"
+	// ActorAdmin is a caller holding the admin token
+	ActorAdmin ActorKind = "admin"
+	// ActorAPIKey is an integration calling with an API key, named by the
+	// key's prefix
+	ActorAPIKey ActorKind = "api_key"
+)
+
+// Actor is the staff member, admin or integration a request acts for.
+// Reason is why they opened the records, when they gave one.
+type Actor struct {
+	ID     string
+	Kind   ActorKind
+	Reason string
+}
+
+// PIIAccess records that a response returned a patient's personal data to
+// an actor. Endpoint is the method and route pattern that returned it.
+type PIIAccess struct {
+	ID         uuid.UUID
+	Actor      string
+	ActorKind  ActorKind
+	Reason     *string
+	PatientID  uuid.UUID
+	Endpoint   string
+	RequestID  string
+	AccessedAt time.Time
+}
+
+// PIIAccessFilter selects access records in [From, To), of one patient or
+// actor when PatientID or Actor is set
+type PIIAccessFilter struct {
+	PatientID *uuid.UUID
+	Actor     string
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// OpenSlot is a slot found by a search, with what a patient choosing it
+// needs to know about the clinician. Remaining is the room left once
+// confirmed appointments and live holds have taken their places. Price is
+// the clinic's self-pay price for the slot type, nil when it has none.
+type OpenSlot struct {
+	AppointmentSlot
+	ClinicianName string
+	Specialty     *string
+	Remaining     int
+	Price         *Price
+}
+
+// SlotSearch selects one page of the open slots of a clinic starting in
+// [From, To), of one specialty when Specialty is set. Token is the
+// NextToken of the previous page.
+type SlotSearch struct {
+	ClinicID  uuid.UUID
+	Specialty string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Token     string
+}
+
+// SlotQuery selects one page of slots starting in [From, To) with Status
+// and every other filter that is set, soonest first. An open slot is only
+// selected while confirmed appointments and live holds leave room in it.
+// Token is the NextToken of the
+// previous page.
+type SlotQuery struct {
+	ClinicID    *uuid.UUID
+	ClinicianID *uuid.UUID
+	Specialty   string
+	Status      SlotStatus
+	From        time.Time
+	To          time.Time
+	Limit       int
+	Token       string
+}
+
+// AppointmentSearch selects one page of appointments matching every filter
+// that is set, in Sort order. PatientName matches a substring of the
+// patient's name, ignoring case. From and To bound the start of the
+// appointment's slot to [From, To). Appointments of deactivated patients
+// are left out unless IncludeDeactivated is set or PatientID names the
+// patient. Token is the NextToken of the previous page.
+type AppointmentSearch struct {
+	PatientID          *uuid.UUID
+	PatientName        string
+	SlotID             *uuid.UUID
+	ClinicianID        *uuid.UUID
+	Specialty          string
+	Statuses           []AppointmentStatus
+	From               *time.Time
+	To                 *time.Time
+	IncludeDeactivated bool
+	Sort               ListSort
+	Limit              int
+	Token              string
+}
+
+// SlotSearchResult is one page of slots. NextToken is empty on the
+// last page.
+type SlotSearchResult struct {
+	Slots     []OpenSlot
+	NextToken string
+}
+
+// GuestBooking books a slot of the clinic for someone who is not signed in,
+// matched to a patient by email
+type GuestBooking struct {
+	ClinicID uuid.UUID
+	SlotID   uuid.UUID
+	Name     string
+	Email    string
+}
+
+// PushProvider is the push service a device token was issued by
+type PushProvider string
+
+const (
+	PushFCM  PushProvider = "fcm"  // Firebase Cloud Messaging, Android and Firebase-based iOS apps
+	PushAPNs PushProvider = "apns" // Apple Push Notification service
+)
+
+// Device is a patient's mobile device registered for push notifications.
+// A token belongs to one patient at a time.
+type Device struct {
+	ID        uuid.UUID
+	PatientID uuid.UUID
+	Provider  PushProvider
+	Token     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SMSAction is what an SMS reply asks for
+type SMSAction string
+
+const (
+	SMSConfirm SMSAction = "confirm"
+	SMSCancel  SMSAction = "cancel"
+)
+
+// SMSOutcome is what came of an SMS reply
+type SMSOutcome string
+
+const (
+	SMSConfirmed        SMSOutcome = "confirmed"
+	SMSAwaitingApproval SMSOutcome = "pending_approval" // confirmed where the clinic triages bookings
+	SMSCancelled        SMSOutcome = "cancelled"
+	SMSNotUnderstood    SMSOutcome = "not_understood"
+	SMSNoAppointment    SMSOutcome = "no_appointment"
+	SMSFailed           SMSOutcome = "failed" // the appointment could not be confirmed, e.g. its slot filled up
+)
+
+// SMSReply is an inbound SMS a patient sent in answer to a booking, by the
+// carrier's message ID. Action is nil when the reply was not understood
+// and AppointmentID when it did not come to an appointment.
+type SMSReply struct {
+	MessageID     string
+	From          string
+	Body          string
+	Action        *SMSAction
+	AppointmentID *uuid.UUID
+	Outcome       SMSOutcome
+	ReceivedAt    time.Time
+}
+
+// APIScope is something an API key may do
+type APIScope string
+
+const (
+	ScopeIVRLookup  APIScope = "ivr:lookup"  // look up a caller's next appointment by phone
+	ScopeIVRConfirm APIScope = "ivr:confirm" // confirm an appointment by reference
+	ScopeIVRCancel  APIScope = "ivr:cancel"  // cancel an appointment by reference
+)
+
+// APIScopes are the scopes a key can be given
+var APIScopes = []APIScope{ScopeIVRLookup, ScopeIVRConfirm, ScopeIVRCancel}
+
+// APIKey lets an integration call the endpoints its scopes allow. Only the
+// SHA-256 of the key is kept; Prefix is the start of the key, to tell keys
+// apart. A key with a ClinicID only reaches that clinic's appointments.
+type APIKey struct {
+	ID        uuid.UUID
+	Name      string
+	Prefix    string
+	KeyHash   string
+	Scopes    []APIScope
+	ClinicID  *uuid.UUID
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// Allows reports whether the key has scope
+func (k *APIKey) Allows(scope APIScope) bool {
+	return slices.Contains(k.Scopes, scope)
 }
@@ -0,0 +1,74 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// EventNoteAdded is logged when staff add a note to an appointment. The
+// payload names the note and its author but leaves out the text.
+const EventNoteAdded = "APPOINTMENT_NOTE_ADDED"
+
+const (
+	// MaxReasonLength bounds the reason for a visit given when booking
+	MaxReasonLength = 500
+	// MaxNoteLength bounds the text of one note
+	MaxNoteLength = 4000
+)
+
+// visitReason trims the reason for a visit and checks its length. A nil
+// reason stays nil.
+func visitReason(reason *string) (*string, error) {
+	if reason == nil {
+		return nil, nil
+	}
+	r := strings.TrimSpace(*reason)
+	if r == "" {
+		return nil, fmt.Errorf("%w: reason is blank", ErrInvalidReason)
+	}
+	if len(r) > MaxReasonLength {
+		return nil, fmt.Errorf("%w: reason is longer than %d bytes", ErrInvalidReason, MaxReasonLength)
+	}
+	return &r, nil
+}
+
+// AddNote adds a note by author, the staff member writing it, to the
+// appointment, whatever its status
+func (s *Service) AddNote(ctx context.Context, appointmentID uuid.UUID, author, body string) (*AppointmentNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, fmt.Errorf("%w: body is required", ErrInvalidNote)
+	}
+	if len(body) > MaxNoteLength {
+		return nil, fmt.Errorf("%w: body is longer than %d bytes", ErrInvalidNote, MaxNoteLength)
+	}
+	if author == "" {
+		return nil, fmt.Errorf("%w: author is required", ErrInvalidNote)
+	}
+
+	if _, err := s.repo.GetAppointmentByID(ctx, appointmentID); err != nil {
+		if errors.Is(err, ErrAppointmentNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load appointment: %w", err)
+	}
+	note, err := s.repo.InsertAppointmentNote(ctx, AppointmentNote{
+		ID:            uuid.New(),
+		AppointmentID: appointmentID,
+		Author:        author,
+		Body:          body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("add note: %w", err)
+	}
+
+	s.logEvent(ctx, appointmentID, EventNoteAdded, map[string]any{
+		"note_id": note.ID.String(),
+		"author":  note.Author,
+	})
+	return note, nil
+}
@@ -0,0 +1,159 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+// OrphanKind says how an appointment was left behind by the booking flow
+type OrphanKind string
+
+const (
+	// OrphanStalePending is pending long after its hold passed, e.g. because
+	// the expiry worker was down
+	OrphanStalePending OrphanKind = "stale_pending"
+	// OrphanDeletedSlot is pending, awaiting approval or confirmed on a deleted slot
+	OrphanDeletedSlot OrphanKind = "deleted_slot"
+	// OrphanBlockedSlot is confirmed on a blocked slot
+	OrphanBlockedSlot OrphanKind = "blocked_slot"
+)
+
+// OrphanKinds lists every kind, for reporting zero counts
+var OrphanKinds = []OrphanKind{OrphanStalePending, OrphanDeletedSlot, OrphanBlockedSlot}
+
+// OrphanedAppointment is an active appointment in a state the booking flow
+// never leaves one in
+type OrphanedAppointment struct {
+	Appointment
+	SlotStatus SlotStatus
+	Kind       OrphanKind
+}
+
+// maxOrphanScan bounds the appointments one reconciliation run looks at
+const maxOrphanScan = 1000
+
+var (
+	orphansFound = metrics.NewGauge(
+		"orphaned_appointments",
+		"Orphaned appointments found by the last reconciliation run of each shard.",
+		"shard", "kind",
+	)
+	orphansRepaired = metrics.NewCounter(
+		"orphaned_appointments_repaired_total",
+		"Orphaned appointments expired or cancelled by reconciliation.",
+		"kind",
+	)
+)
+
+// OrphanResult summarizes one orphan reconciliation run
+type OrphanResult struct {
+	Found    map[OrphanKind]int
+	Repaired int
+	Stopped  bool // stop was closed before every orphan was repaired
+}
+
+// FindOrphanedAppointments lists up to limit active appointments the booking
+// flow left behind: holds more than cfg.OrphanGrace past their deadline that
+// were never expired, and appointments whose slot was deleted or blocked
+// under them.
+func (s *Service) FindOrphanedAppointments(ctx context.Context, limit int) ([]OrphanedAppointment, error) {
+	if limit <= 0 {
+		limit = 100 // default
+	}
+	if limit > maxOrphanScan {
+		limit = maxOrphanScan
+	}
+
+	staleBefore := s.clock.Now().Add(-s.cfg.OrphanGrace)
+	orphans, err := s.repo.ListOrphanedAppointments(ctx, staleBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("find orphaned appointments: %w", err)
+	}
+	return orphans, nil
+}
+
+// RepairOrphanedAppointment expires a stale hold, or cancels an appointment
+// on a deleted or blocked slot with reason slot_deleted or slot_blocked,
+// logging the usual event. It returns ErrAppointmentNotActive when the
+// appointment was resolved or its slot changed since it was found.
+func (s *Service) RepairOrphanedAppointment(ctx context.Context, o OrphanedAppointment) error {
+	switch o.Kind {
+	case OrphanStalePending:
+		if _, err := s.repo.ResolvePendingAppointment(ctx, o.ID, StatusExpired, s.clock.Now()); err != nil {
+			if errors.Is(err, ErrAppointmentNotFound) {
+				return ErrAppointmentNotActive
+			}
+			return fmt.Errorf("expire orphaned appointment: %w", err)
+		}
+		s.logEvent(ctx, o.ID, EventAppointmentExpired, map[string]any{
+			"reason": "reconciler",
+		})
+		return nil
+
+	case OrphanDeletedSlot, OrphanBlockedSlot:
+		slot, err := s.repo.GetSlotByID(ctx, o.SlotID)
+		if err != nil {
+			return fmt.Errorf("load slot: %w", err)
+		}
+		if slot.Status != o.SlotStatus {
+			return ErrAppointmentNotActive
+		}
+		_, err = s.CancelAppointment(ctx, o.ID, "slot_"+string(slot.Status), map[string]any{
+			"slot_id": o.SlotID.String(),
+		})
+		return err
+
+	default:
+		return fmt.Errorf("repair orphaned appointment: unknown kind %q", o.Kind)
+	}
+}
+
+// ReconcileOrphanedAppointments logs every orphaned appointment and, when
+// repair is set, expires or cancels it. Once stop is closed the remaining
+// orphans are left for the next run. stop may be nil.
+func (s *Service) ReconcileOrphanedAppointments(ctx context.Context, stop <-chan struct{}, repair bool) (OrphanResult, error) {
+	res := OrphanResult{Found: make(map[OrphanKind]int, len(OrphanKinds))}
+
+	orphans, err := s.FindOrphanedAppointments(ctx, maxOrphanScan)
+	if err != nil {
+		return res, err
+	}
+	for _, o := range orphans {
+		res.Found[o.Kind]++
+	}
+	for _, kind := range OrphanKinds {
+		orphansFound.Set(float64(res.Found[kind]), shard.Name(ctx), string(kind))
+	}
+
+	for _, o := range orphans {
+		if !repair {
+			log.Printf("orphaned appointment %s: %s (status=%s slot=%s slot_status=%s)",
+				o.ID, o.Kind, o.Status, o.SlotID, o.SlotStatus)
+			continue
+		}
+
+		select {
+		case <-stop:
+			res.Stopped = true
+			return res, nil
+		default:
+		}
+
+		if err := s.RepairOrphanedAppointment(ctx, o); err != nil {
+			if !errors.Is(err, ErrAppointmentNotActive) {
+				log.Printf("failed to repair orphaned appointment %s (%s): %v", o.ID, o.Kind, err)
+			}
+			continue
+		}
+		res.Repaired++
+		orphansRepaired.Inc(string(o.Kind))
+		log.Printf("repaired orphaned appointment %s: %s", o.ID, o.Kind)
+	}
+
+	return res, nil
+}
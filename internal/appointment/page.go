@@ -0,0 +1,251 @@
+package appointment
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/pagination"
+)
+
+// PageRequest selects one page of a list in Sort order. When Token is set
+// the page starts right after the row it points at and Offset is ignored;
+// Offset is kept for callers that predate tokens.
+type PageRequest struct {
+	Limit  int
+	Offset int
+	Token  string
+	Sort   ListSort
+}
+
+// SortField is a column appointment lists can be ordered by
+type SortField string
+
+const (
+	SortCreatedAt SortField = "created_at"
+	SortStartTime SortField = "start_time" // of the appointment's first slot
+	SortStatus    SortField = "status"
+)
+
+// ListSort orders an appointment list. Ties are broken by booking time and
+// then id, in the same direction. The zero value is newest booking first.
+type ListSort struct {
+	Field SortField
+	Asc   bool
+}
+
+// ParseListSort reads the sort and order parameters of a list. An empty
+// field sorts by booking time; an empty order is descending for booking
+// time, so the newest come first, and ascending for the others, so the
+// soonest slots and statuses in alphabetical order come first.
+func ParseListSort(field, order string) (ListSort, error) {
+	s := ListSort{Field: SortField(field)}
+	switch s.Field {
+	case "":
+		s.Field = SortCreatedAt
+	case SortCreatedAt:
+	case SortStartTime, SortStatus:
+		s.Asc = true
+	default:
+		return s, fmt.Errorf("%w: cannot sort by %q", ErrInvalidSort, field)
+	}
+	switch order {
+	case "":
+	case "asc":
+		s.Asc = true
+	case "desc":
+		s.Asc = false
+	default:
+		return s, fmt.Errorf("%w: order must be asc or desc", ErrInvalidSort)
+	}
+	return s, nil
+}
+
+// isDefault reports whether s is newest booking first, the order tokens
+// predating ListSort were issued for
+func (s ListSort) isDefault() bool {
+	return (s.Field == "" || s.Field == SortCreatedAt) && !s.Asc
+}
+
+func (s ListSort) String() string {
+	field := s.Field
+	if field == "" {
+		field = SortCreatedAt
+	}
+	if s.Asc {
+		return string(field) + ".asc"
+	}
+	return string(field) + ".desc"
+}
+
+// AppointmentPage is one page of appointment details. NextToken is empty on the last page.
+type AppointmentPage struct {
+	Appointments []AppointmentDetail
+	NextToken    string
+}
+
+// pageKey is the position a page token points at: the ordering time and
+// id of the last row of the page, and its status when sorting by status
+type pageKey struct {
+	At     time.Time
+	Status string
+	ID     uuid.UUID
+}
+
+// encodePageKey builds the repositories' cursor from the last row of a
+// page. The format is backend independent so tokens survive a storage
+// migration. The service seals cursors before clients see them, see
+// openPageToken.
+func encodePageKey(at time.Time, id uuid.UUID) string {
+	raw := strconv.FormatInt(at.UnixNano(), 10) + ":" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodePageToken(token string) (*pageKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidPageToken
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	return &pageKey{At: time.Unix(0, n).UTC(), ID: parsed}, nil
+}
+
+// encodeSortedPageKey builds the token of key in a list ordered by sort.
+// Tokens of the default order keep the format of encodePageKey; the others
+// name their order, so a token is refused by a list sorted another way.
+func encodeSortedPageKey(sort ListSort, key pageKey) string {
+	token := encodePageKey(key.At, key.ID)
+	if sort.isDefault() {
+		return token
+	}
+	prefix := sort.String() + "|"
+	if sort.Field == SortStatus {
+		prefix += key.Status + "|"
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(token)
+	return base64.RawURLEncoding.EncodeToString(append([]byte(prefix), raw...))
+}
+
+func decodeSortedPageToken(sort ListSort, token string) (*pageKey, error) {
+	if sort.isDefault() {
+		return decodePageToken(token)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	rest, ok := strings.CutPrefix(string(raw), sort.String()+"|")
+	if !ok {
+		return nil, ErrInvalidPageToken
+	}
+	var status string
+	if sort.Field == SortStatus {
+		if status, rest, ok = strings.Cut(rest, "|"); !ok {
+			return nil, ErrInvalidPageToken
+		}
+	}
+	key, err := decodePageToken(base64.RawURLEncoding.EncodeToString([]byte(rest)))
+	if err != nil {
+		return nil, err
+	}
+	key.Status = status
+	return key, nil
+}
+
+// openPageToken returns the repository cursor sealed in a token the service
+// handed out for scope, or "" for the first page. Tokens of another list,
+// sort order or filters, and edited ones, are ErrInvalidPageToken.
+func (s *Service) openPageToken(scope pagination.Scope, token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	cursor, err := s.pages.Decode(scope, token)
+	if err != nil {
+		return "", ErrInvalidPageToken
+	}
+	return cursor, nil
+}
+
+func patientListScope(patientID uuid.UUID, sort ListSort) pagination.Scope {
+	return pagination.NewScope("appointments_by_patient").With(patientID.String()).With(sort.String())
+}
+
+// pageScope names the search by its sort and filters. Statuses are sorted,
+// so listing them in another order keeps the same search.
+func (q AppointmentSearch) pageScope() pagination.Scope {
+	statuses := make([]string, len(q.Statuses))
+	for i, st := range q.Statuses {
+		statuses[i] = string(st)
+	}
+	slices.Sort(statuses)
+	scope := pagination.NewScope("appointment_search").With(q.Sort.String()).
+		WithID(q.PatientID).With(q.PatientName).WithID(q.SlotID).WithID(q.ClinicianID).
+		With(q.Specialty).With(strings.Join(statuses, ",")).
+		With(strconv.FormatBool(q.IncludeDeactivated))
+	for _, t := range []*time.Time{q.From, q.To} {
+		if t == nil {
+			scope = scope.With("")
+		} else {
+			scope = scope.WithTime(*t)
+		}
+	}
+	return scope
+}
+
+func (q SlotQuery) pageScope() pagination.Scope {
+	return pagination.NewScope("slot_search").WithID(q.ClinicID).WithID(q.ClinicianID).
+		With(q.Specialty).With(string(q.Status)).WithTime(q.From).WithTime(q.To)
+}
+
+// patientPageKey is the position a patient search token points at: the
+// name and id of the last patient of the page
+type patientPageKey struct {
+	Name string
+	ID   uuid.UUID
+}
+
+// encodePatientPageKey builds the repositories' cursor of a patient search.
+// The id comes first, as names may hold any character.
+func encodePatientPageKey(key patientPageKey) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key.ID.String() + ":" + key.Name))
+}
+
+func decodePatientPageToken(token string) (*patientPageKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	id, name, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidPageToken
+	}
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	return &patientPageKey{Name: name, ID: parsed}, nil
+}
+
+func (q PatientSearch) pageScope() pagination.Scope {
+	return pagination.NewScope("patient_search").With(q.Query).With(strconv.FormatBool(q.IncludeDeactivated))
+}
@@ -0,0 +1,91 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// patientCancelBatch is how many appointments CancelPatientAppointments
+// reads at a time inside its transaction
+const patientCancelBatch = 100
+
+// PatientCancellation is the outcome of CancelPatientAppointments: the
+// appointments it cancelled and how many were in each status before
+type PatientCancellation struct {
+	PatientID uuid.UUID
+	Cancelled []CancelledAppointment
+	ByStatus  map[AppointmentStatus]int
+}
+
+// CancelledAppointment is one appointment a bulk cancellation cancelled
+type CancelledAppointment struct {
+	Appointment
+	PreviousStatus AppointmentStatus
+}
+
+// CancelPatientAppointments cancels every appointment of the patient in one
+// of statuses, or in any active status when statuses is empty, e.g. when
+// the patient closes their account. The appointments are cancelled in one
+// transaction, so either all of them are or none; one that changes status
+// in the meantime is left alone. reason and details are recorded on the
+// APPOINTMENT_CANCELLED event of each, written once the transaction commits.
+func (s *Service) CancelPatientAppointments(ctx context.Context, patientID uuid.UUID, statuses []AppointmentStatus, reason string, details map[string]any) (*PatientCancellation, error) {
+	for _, st := range statuses {
+		if !st.Active() {
+			return nil, fmt.Errorf("%w: %q is not a status that can be cancelled", ErrInvalidCancellation, st)
+		}
+	}
+	if len(statuses) == 0 {
+		statuses = []AppointmentStatus{StatusPending, StatusPendingApproval, StatusConfirmed}
+	}
+	if _, err := s.lookupPatient(ctx, patientID); err != nil {
+		return nil, fmt.Errorf("load patient: %w", err)
+	}
+
+	var res *PatientCancellation
+	err := s.runStage(ctx, StageStatusUpdate, func(ctx context.Context) error {
+		res = &PatientCancellation{PatientID: patientID, ByStatus: map[AppointmentStatus]int{}}
+		return s.repo.WithTx(ctx, func(tx Repository) error {
+			q := AppointmentSearch{PatientID: &patientID, Statuses: statuses, Limit: patientCancelBatch}
+			for {
+				page, err := tx.SearchAppointments(ctx, q, DetailFields{})
+				if err != nil {
+					return fmt.Errorf("list appointments: %w", err)
+				}
+				for _, d := range page.Appointments {
+					updated, err := tx.UpdateAppointmentStatus(ctx, d.ID, d.Status, StatusCancelled)
+					if errors.Is(err, ErrAppointmentNotFound) {
+						continue // status changed since it was read
+					}
+					if err != nil {
+						return fmt.Errorf("cancel appointment %s: %w", d.ID, err)
+					}
+					res.Cancelled = append(res.Cancelled, CancelledAppointment{Appointment: *updated, PreviousStatus: d.Status})
+					res.ByStatus[d.Status]++
+				}
+				if page.NextToken == "" {
+					return nil
+				}
+				q.Token = page.NextToken
+			}
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cancel patient appointments: %w", err)
+	}
+
+	for _, c := range res.Cancelled {
+		payload := map[string]any{
+			"reason":          reason,
+			"previous_status": string(c.PreviousStatus),
+		}
+		for k, v := range details {
+			payload[k] = v
+		}
+		s.logEvent(ctx, c.ID, EventAppointmentCancelled, payload)
+	}
+	return res, nil
+}
@@ -0,0 +1,100 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxDeactivationReasonLength bounds the reason given for a deactivation
+const MaxDeactivationReasonLength = 500
+
+// PatientDeactivation is the outcome of DeactivatePatient: the patient as
+// deactivated and the holds that were cancelled with it
+type PatientDeactivation struct {
+	Patient        *Patient
+	CancelledHolds *PatientCancellation
+}
+
+// checkBookablePatient returns ErrPatientNotFound for a patient that does
+// not exist and ErrPatientDeactivated for one whose account is deactivated
+func (s *Service) checkBookablePatient(ctx context.Context, patientID uuid.UUID) error {
+	p, err := s.lookupPatient(ctx, patientID)
+	if err != nil {
+		return err
+	}
+	if p.Deactivated() {
+		return fmt.Errorf("%w: since %s", ErrPatientDeactivated, p.DeactivatedAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// DeactivatePatient deactivates the patient's account on behalf of actor:
+// they can no longer book, their pending holds are cancelled and searches
+// leave them out unless asked otherwise. Unlike erasure it keeps the
+// patient's data and their confirmed appointments, and ReactivatePatient
+// undoes it. The account is deactivated before the holds are cancelled, so
+// no new hold can be taken in between; should the cancellation fail, the
+// holds that remain run out at their expiry.
+func (s *Service) DeactivatePatient(ctx context.Context, patientID uuid.UUID, actor, reason string) (*PatientDeactivation, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidDeactivation)
+	}
+	if len(reason) > MaxDeactivationReasonLength {
+		return nil, fmt.Errorf("%w: reason is longer than %d bytes", ErrInvalidDeactivation, MaxDeactivationReasonLength)
+	}
+	if actor == "" {
+		return nil, fmt.Errorf("%w: actor is required", ErrInvalidDeactivation)
+	}
+
+	p, err := s.repo.GetPatientByID(ctx, patientID)
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load patient: %w", err)
+	}
+	if p.Deactivated() {
+		return nil, fmt.Errorf("%w: since %s", ErrPatientDeactivated, p.DeactivatedAt.UTC().Format(time.RFC3339))
+	}
+
+	p, err = s.repo.DeactivatePatient(ctx, patientID, actor, reason, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("deactivate patient: %w", err)
+	}
+	s.patients.forget(ctx, patientID)
+	holds, err := s.CancelPatientAppointments(ctx, patientID,
+		[]AppointmentStatus{StatusPending, StatusPendingApproval},
+		"patient_deactivated", map[string]any{"via": "deactivation", "actor": actor})
+	if err != nil {
+		return nil, fmt.Errorf("cancel holds: %w", err)
+	}
+	return &PatientDeactivation{Patient: p, CancelledHolds: holds}, nil
+}
+
+// ReactivatePatient lets a deactivated patient book again. Appointments
+// cancelled by the deactivation stay cancelled. Reactivating a patient
+// that is not deactivated returns them unchanged.
+func (s *Service) ReactivatePatient(ctx context.Context, patientID uuid.UUID) (*Patient, error) {
+	p, err := s.repo.GetPatientByID(ctx, patientID)
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load patient: %w", err)
+	}
+	if !p.Deactivated() {
+		return p, nil
+	}
+	p, err = s.repo.ReactivatePatient(ctx, patientID)
+	if err != nil {
+		return nil, fmt.Errorf("reactivate patient: %w", err)
+	}
+	s.patients.forget(ctx, patientID)
+	return p, nil
+}
@@ -0,0 +1,147 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxPatientNameLength  = 200
+	maxEmailLength        = 254
+	minPatientQueryLength = 2
+)
+
+// NewPatient is what a patient is registered with. Email is optional.
+type NewPatient struct {
+	Name  string
+	Email *string
+}
+
+// CreatePatient registers a patient, or returns ErrPatientEmailTaken when
+// another patient has the email. Emails are stored lower-cased, as guest
+// bookings look them up.
+func (s *Service) CreatePatient(ctx context.Context, np NewPatient) (*Patient, error) {
+	name, err := patientName(np.Name)
+	if err != nil {
+		return nil, err
+	}
+	p := Patient{ID: uuid.New(), Name: name}
+	if np.Email != nil {
+		email, ok := normalizeEmail(*np.Email)
+		if !ok {
+			return nil, fmt.Errorf("%w: email must be a plain email address", ErrInvalidPatient)
+		}
+		p.Email = &email
+	}
+
+	created, err := s.repo.CreatePatient(ctx, p)
+	if err != nil {
+		if errors.Is(err, ErrPatientEmailTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("create patient: %w", err)
+	}
+	return created, nil
+}
+
+// GetPatient returns the patient with id as stored, not as cached
+func (s *Service) GetPatient(ctx context.Context, id uuid.UUID) (*Patient, error) {
+	p, err := s.repo.GetPatientByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("get patient: %w", err)
+	}
+	return p, nil
+}
+
+// UpdatePatient changes the patient's name or email, checked as
+// CreatePatient checks them. An update with neither returns the patient
+// unchanged.
+func (s *Service) UpdatePatient(ctx context.Context, id uuid.UUID, u PatientUpdate) (*Patient, error) {
+	if u.Name != nil {
+		name, err := patientName(*u.Name)
+		if err != nil {
+			return nil, err
+		}
+		u.Name = &name
+	}
+	if u.Email != nil {
+		email, ok := normalizeEmail(*u.Email)
+		if !ok {
+			return nil, fmt.Errorf("%w: email must be a plain email address", ErrInvalidPatient)
+		}
+		u.Email = &email
+	}
+	if u.Name == nil && u.Email == nil {
+		return s.GetPatient(ctx, id)
+	}
+
+	p, err := s.repo.UpdatePatient(ctx, id, u)
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) || errors.Is(err, ErrPatientEmailTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("update patient: %w", err)
+	}
+	s.patients.forget(ctx, id)
+	return p, nil
+}
+
+// SearchPatients returns one page of the patients whose name or email
+// contains q.Query, for staff finding a patient before booking. The query
+// is trimmed and must be 2 to MaxSearchNameLength characters; from three
+// on, Postgres answers it from trigram indexes.
+func (s *Service) SearchPatients(ctx context.Context, q PatientSearch) (*PatientPage, error) {
+	q.Query = strings.TrimSpace(q.Query)
+	if n := utf8.RuneCountInString(q.Query); n < minPatientQueryLength || len(q.Query) > MaxSearchNameLength {
+		return nil, fmt.Errorf("%w: query must be %d to %d characters", ErrInvalidPatientSearch, minPatientQueryLength, MaxSearchNameLength)
+	}
+	if q.Limit <= 0 {
+		q.Limit = 20 // default
+	}
+	if q.Limit > 100 {
+		q.Limit = 100 // max
+	}
+	scope := q.pageScope()
+	cursor, err := s.openPageToken(scope, q.Token)
+	if err != nil {
+		return nil, err
+	}
+	q.Token = cursor
+
+	page, err := s.repo.SearchPatients(ctx, q)
+	if err != nil {
+		if errors.Is(err, ErrInvalidPageToken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("search patients: %w", err)
+	}
+	page.NextToken = s.pages.Encode(scope, page.NextToken)
+	return page, nil
+}
+
+func patientName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxPatientNameLength {
+		return "", fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidPatient, maxPatientNameLength)
+	}
+	return name, nil
+}
+
+// normalizeEmail returns email lower-cased when it is a plain address,
+// without a display name
+func normalizeEmail(email string) (string, bool) {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || len(addr.Address) > maxEmailLength {
+		return "", false
+	}
+	return strings.ToLower(addr.Address), true
+}
@@ -2,204 +2,1796 @@ package appointment
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// dbtx is satisfied by both *pgxpool.Pool and pgx.Tx, so the same repository
+// methods run either directly on the pool or inside a transaction.
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// pgxPool is satisfied by *pgxpool.Pool and by *shard.Set, which sends each
+// call to the shard of the tenant in its context
+type pgxPool interface {
+	dbtx
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
 type PgRepository struct {
-	pool *pgxpool.Pool
+	pool pgxPool
+	db   dbtx
+}
+
+func NewPgRepository(pool pgxPool) *PgRepository {
+	return &PgRepository{pool: pool, db: pool}
+}
+
+// Interface methods
+
+// WithTx runs fn inside a transaction. Nested calls reuse the outer transaction.
+func (r *PgRepository) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	if _, inTx := r.db.(pgx.Tx); inTx {
+		return fn(r)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := fn(&PgRepository{pool: r.pool, db: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func (r *PgRepository) GetPatientByID(ctx context.Context, id uuid.UUID) (*Patient, error) {
+	return scanPatient(r.db.QueryRow(ctx, pgGetPatientQuery, id))
+}
+
+func (r *PgRepository) CreatePatient(ctx context.Context, p Patient) (*Patient, error) {
+	created, err := scanPatient(r.db.QueryRow(ctx, `
+		INSERT INTO patients (id, name, email, created_at, updated_at)
+		VALUES ($1, $2, $3, now(), now())
+		RETURNING `+patientColumns+`
+	`, p.ID, p.Name, p.Email))
+	if err != nil && isPatientEmailViolation(err) {
+		return nil, ErrPatientEmailTaken
+	}
+	return created, err
+}
+
+func (r *PgRepository) UpdatePatient(ctx context.Context, id uuid.UUID, u PatientUpdate) (*Patient, error) {
+	updated, err := scanPatient(r.db.QueryRow(ctx, `
+		UPDATE patients
+		SET name = COALESCE($2, name), email = COALESCE($3, email), updated_at = now()
+		WHERE id = $1
+		RETURNING `+patientColumns+`
+	`, id, u.Name, u.Email))
+	if err != nil && isPatientEmailViolation(err) {
+		return nil, ErrPatientEmailTaken
+	}
+	return updated, err
+}
+
+func (r *PgRepository) SearchPatients(ctx context.Context, q PatientSearch) (*PatientPage, error) {
+	var after *patientPageKey
+	if q.Token != "" {
+		key, err := decodePatientPageToken(q.Token)
+		if err != nil {
+			return nil, err
+		}
+		after = key
+	}
+
+	query, args := patientSearchQuery(q, after, func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search patients: %w", err)
+	}
+	defer rows.Close()
+
+	return collectPatientPage(rows, q.Limit)
+}
+
+func (r *PgRepository) DeactivatePatient(ctx context.Context, id uuid.UUID, actor, reason string, at time.Time) (*Patient, error) {
+	return scanPatient(r.db.QueryRow(ctx, `
+		UPDATE patients
+		SET deactivated_at = $2, deactivated_by = $3, deactivation_reason = $4, updated_at = now()
+		WHERE id = $1
+		RETURNING `+patientColumns+`
+	`, id, at, actor, reason))
+}
+
+func (r *PgRepository) ReactivatePatient(ctx context.Context, id uuid.UUID) (*Patient, error) {
+	return scanPatient(r.db.QueryRow(ctx, `
+		UPDATE patients
+		SET deactivated_at = NULL, deactivated_by = NULL, deactivation_reason = NULL, updated_at = now()
+		WHERE id = $1
+		RETURNING `+patientColumns+`
+	`, id))
+}
+
+func (r *PgRepository) SetPatientPhone(ctx context.Context, id uuid.UUID, phone *string) (*Patient, error) {
+	return scanPatient(r.db.QueryRow(ctx, `
+		UPDATE patients
+		SET phone = $2, updated_at = now()
+		WHERE id = $1
+		RETURNING `+patientColumns+`
+	`, id, phone))
+}
+
+func (r *PgRepository) ListPendingAppointmentsByPhone(ctx context.Context, phone string, now time.Time) ([]Appointment, error) {
+	query := pendingByPhoneQuery(func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, phone, now)
+	if err != nil {
+		return nil, fmt.Errorf("list pending appointments by phone: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Appointment
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *PgRepository) GetSMSReply(ctx context.Context, messageID string) (*SMSReply, error) {
+	return scanSMSReply(r.db.QueryRow(ctx, `
+		SELECT `+smsReplyColumns+`
+		FROM sms_replies
+		WHERE message_id = $1
+	`, messageID))
+}
+
+func (r *PgRepository) InsertSMSReply(ctx context.Context, m SMSReply) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO sms_replies (message_id, from_number, body, action, appointment_id, outcome, received_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (message_id) DO NOTHING
+	`, m.MessageID, m.From, m.Body, m.Action, m.AppointmentID, m.Outcome, m.ReceivedAt)
+	if err != nil {
+		return false, fmt.Errorf("insert sms reply: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *PgRepository) GetNextAppointmentByPhone(ctx context.Context, phone string, clinicID *uuid.UUID, now time.Time) (*Appointment, error) {
+	args := []any{phone, now}
+	if clinicID != nil {
+		args = append(args, *clinicID)
+	}
+	query := nextByPhoneQuery(clinicID != nil, func(n int) string { return fmt.Sprintf("$%d", n) })
+	return scanAppointment(r.db.QueryRow(ctx, query, args...))
+}
+
+func (r *PgRepository) CreateAPIKey(ctx context.Context, k APIKey) (*APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(ctx, `
+		INSERT INTO api_keys (id, name, prefix, key_hash, scopes, clinic_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+apiKeyColumns+`
+	`, k.ID, k.Name, k.Prefix, k.KeyHash, joinScopes(k.Scopes), k.ClinicID))
+}
+
+func (r *PgRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE key_hash = $1
+	`, keyHash))
+}
+
+func (r *PgRepository) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	defer rows.Close()
+	return collectAPIKeys(rows)
+}
+
+func (r *PgRepository) RevokeAPIKey(ctx context.Context, id uuid.UUID, at time.Time) (*APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(ctx, `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, $2)
+		WHERE id = $1
+		RETURNING `+apiKeyColumns+`
+	`, id, at))
+}
+
+func (r *PgRepository) RegisterDevice(ctx context.Context, d Device) (*Device, error) {
+	return scanDevice(r.db.QueryRow(ctx, `
+		INSERT INTO patient_devices (id, patient_id, provider, token)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (token) DO UPDATE
+		SET patient_id = excluded.patient_id, provider = excluded.provider, updated_at = now()
+		RETURNING `+deviceColumns+`
+	`, d.ID, d.PatientID, d.Provider, d.Token))
+}
+
+func (r *PgRepository) ListPatientDevices(ctx context.Context, patientID uuid.UUID) ([]Device, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+deviceColumns+`
+		FROM patient_devices
+		WHERE patient_id = $1
+		ORDER BY created_at, id
+	`, patientID)
+	if err != nil {
+		return nil, fmt.Errorf("list patient devices: %w", err)
+	}
+	defer rows.Close()
+	return collectDevices(rows)
+}
+
+func (r *PgRepository) ListAppointmentDevices(ctx context.Context, appointmentID uuid.UUID) ([]Device, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT d.id, d.patient_id, d.provider, d.token, d.created_at, d.updated_at
+		FROM appointments a
+		JOIN patient_devices d ON d.patient_id = a.patient_id
+		WHERE a.id = $1
+		ORDER BY d.created_at, d.id
+	`, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("list appointment devices: %w", err)
+	}
+	defer rows.Close()
+	return collectDevices(rows)
+}
+
+func (r *PgRepository) DeletePatientDevice(ctx context.Context, patientID, deviceID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM patient_devices
+		WHERE id = $1 AND patient_id = $2
+	`, deviceID, patientID)
+	if err != nil {
+		return fmt.Errorf("delete patient device: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) DeleteDeviceByToken(ctx context.Context, token string) error {
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM patient_devices
+		WHERE token = $1
+	`, token)
+	if err != nil {
+		return fmt.Errorf("delete device by token: %w", err)
+	}
+	return nil
+}
+
+func (r *PgRepository) GetClinicianByID(ctx context.Context, id uuid.UUID) (*Clinician, error) {
+	return scanClinician(r.db.QueryRow(ctx, pgGetClinicianQuery, id))
+}
+
+func (r *PgRepository) CreateClinician(ctx context.Context, c Clinician) (*Clinician, error) {
+	created, err := scanClinician(r.db.QueryRow(ctx, `
+		INSERT INTO clinicians (id, name, specialty, clinic_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, now(), now())
+		RETURNING `+clinicianColumns+`
+	`, c.ID, c.Name, c.Specialty, c.ClinicID))
+	if err != nil && isClinicianClinicViolation(err) {
+		return nil, ErrClinicNotFound
+	}
+	return created, err
+}
+
+func (r *PgRepository) UpdateClinician(ctx context.Context, id uuid.UUID, u ClinicianUpdate) (*Clinician, error) {
+	return scanClinician(r.db.QueryRow(ctx, `
+		UPDATE clinicians
+		SET name = COALESCE($2, name), specialty = COALESCE($3, specialty), updated_at = now()
+		WHERE id = $1
+		RETURNING `+clinicianColumns+`
+	`, id, u.Name, u.Specialty))
+}
+
+func (r *PgRepository) DeactivateClinician(ctx context.Context, id uuid.UUID, at time.Time) (*Clinician, error) {
+	return scanClinician(r.db.QueryRow(ctx, `
+		UPDATE clinicians
+		SET deactivated_at = $2, updated_at = now()
+		WHERE id = $1
+		RETURNING `+clinicianColumns+`
+	`, id, at))
+}
+
+func (r *PgRepository) GetAvailabilityVersion(ctx context.Context, clinicianID uuid.UUID) (int64, error) {
+	var version int64
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE((SELECT version FROM clinician_availability_versions WHERE clinician_id = $1), 0)
+	`, clinicianID).Scan(&version)
+	return version, err
+}
+
+func (r *PgRepository) CountSlotsByDay(ctx context.Context, clinicianID uuid.UUID, days []DayRange, bookable DayRange, now time.Time) ([]DayAvailability, error) {
+	if len(days) == 0 {
+		return nil, nil
+	}
+	query := slotsByDayQuery(len(days), func(n int, sqlType string) string {
+		return fmt.Sprintf("$%d::%s", n, sqlType)
+	})
+	rows, err := r.db.Query(ctx, query, slotsByDayArgs(clinicianID, days, bookable, now, func(t time.Time) time.Time { return t })...)
+	if err != nil {
+		return nil, fmt.Errorf("count slots by day: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSlotsByDay(rows, len(days))
+}
+
+func (r *PgRepository) GetSlotByID(ctx context.Context, id uuid.UUID) (*AppointmentSlot, error) {
+	return scanSlot(r.db.QueryRow(ctx, pgGetSlotQuery, id))
+}
+
+func (r *PgRepository) LockSlot(ctx context.Context, id uuid.UUID) (*AppointmentSlot, error) {
+	return scanSlot(r.db.QueryRow(ctx, `
+		SELECT id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+		FROM appointment_slots
+		WHERE id = $1
+		FOR UPDATE
+	`, id))
+}
+
+func (r *PgRepository) GetSlotQuote(ctx context.Context, slotID uuid.UUID) (*SlotQuote, error) {
+	var q SlotQuote
+
+	err := r.db.QueryRow(ctx, `
+		SELECT s.id, c.clinic_id, s.slot_type, p.amount_minor, p.currency
+		FROM appointment_slots s
+		INNER JOIN clinicians c ON s.practitioner_id = c.id
+		INNER JOIN slot_type_prices p ON p.clinic_id = c.clinic_id AND p.slot_type = s.slot_type
+		WHERE s.id = $1
+	`, slotID).Scan(&q.SlotID, &q.ClinicID, &q.SlotType, &q.Price.AmountMinor, &q.Price.Currency)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPriceNotFound
+		}
+		return nil, err
+	}
+
+	return &q, nil
+}
+
+func (r *PgRepository) SlotRequiresApproval(ctx context.Context, slotID uuid.UUID) (bool, error) {
+	var required bool
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(k.requires_approval, false)
+		FROM appointment_slots s
+		INNER JOIN clinicians c ON s.practitioner_id = c.id
+		LEFT JOIN clinics k ON k.id = c.clinic_id
+		WHERE s.id = $1
+	`, slotID).Scan(&required)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrSlotNotFound
+		}
+		return false, err
+	}
+	return required, nil
+}
+
+func (r *PgRepository) GetAppointmentByID(ctx context.Context, id uuid.UUID) (*Appointment, error) {
+	row := r.db.QueryRow(ctx, `
+		SELECT id, slot_id, patient_id, status, created_at, updated_at, expires_at
+		FROM appointments
+		WHERE id = $1
+	`, id)
+	return scanAppointment(row)
+}
+
+func (r *PgRepository) GetSlotBookingWindow(ctx context.Context, slotID uuid.UUID) (*BookingWindow, error) {
+	return scanSlotBookingWindow(r.db.QueryRow(ctx, slotBookingWindowColumns+`
+		WHERE s.id = $1
+	`, slotID))
+}
+
+func (r *PgRepository) GetBookingWindow(ctx context.Context, specialty string) (*BookingWindow, error) {
+	return scanBookingWindow(r.db.QueryRow(ctx, bookingWindowSelect+`
+		WHERE specialty = $1
+	`, specialty))
+}
+
+func (r *PgRepository) ListBookingWindows(ctx context.Context) ([]BookingWindow, error) {
+	rows, err := r.db.Query(ctx, bookingWindowSelect+`
+		ORDER BY specialty
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list booking windows: %w", err)
+	}
+	defer rows.Close()
+
+	var result []BookingWindow
+	for rows.Next() {
+		w, err := scanBookingWindow(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) PutBookingWindow(ctx context.Context, w BookingWindow) (*BookingWindow, error) {
+	row := r.db.QueryRow(ctx, `
+		INSERT INTO specialty_booking_policies (specialty, min_lead_seconds, max_lead_seconds, updated_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (specialty)
+		DO UPDATE SET min_lead_seconds = excluded.min_lead_seconds,
+		              max_lead_seconds = excluded.max_lead_seconds,
+		              updated_at = excluded.updated_at
+		RETURNING specialty, min_lead_seconds, max_lead_seconds, updated_at
+	`, w.Specialty, leadSeconds(&w.MinLead), leadSeconds(w.MaxLead))
+	saved, err := scanBookingWindow(row)
+	if err != nil {
+		return nil, fmt.Errorf("put booking window: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *PgRepository) DeleteBookingWindow(ctx context.Context, specialty string) error {
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM specialty_booking_policies
+		WHERE specialty = $1
+	`, specialty)
+	if err != nil {
+		return fmt.Errorf("delete booking window: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrBookingWindowNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) HasBlockingAppointment(ctx context.Context, slotID uuid.UUID) (SlotBlockers, error) {
+	var b SlotBlockers
+	query := blockingAppointmentQuery(func(n int) string { return fmt.Sprintf("$%d", n) })
+	err := r.db.QueryRow(ctx, query, slotID, slotID, slotID, slotID).Scan(&b.Confirmed, &b.Held)
+	return b, err
+}
+
+func (r *PgRepository) CountConfirmedAppointmentsForSlot(ctx context.Context, slotID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.QueryRow(ctx, `
+		SELECT count(*)
+		FROM appointments
+		WHERE status IN ('confirmed', 'checked_in')
+		  AND (slot_id = $1
+		       OR id IN (SELECT appointment_id FROM appointment_extra_slots WHERE slot_id = $1))
+	`, slotID).Scan(&n)
+	return n, err
+}
+
+func (r *PgRepository) CountTakenPlaces(ctx context.Context, slotID uuid.UUID, now time.Time) (int, error) {
+	var one int
+	if err := r.db.QueryRow(ctx, pgLockSlotQuery, slotID).Scan(&one); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrSlotNotFound
+		}
+		return 0, err
+	}
+	var n int
+	err := r.db.QueryRow(ctx, pgTakenPlacesQuery, slotID, slotID, now).Scan(&n)
+	return n, err
+}
+
+func (r *PgRepository) ListPatientConflicts(ctx context.Context, patientID uuid.UUID, start, end time.Time) ([]Appointment, error) {
+	query := patientConflictsQuery(func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, patientID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("list patient conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Appointment
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *PgRepository) ListSyncEvents(ctx context.Context, scope SyncScope, after int64, before time.Time, limit int) ([]SyncEvent, error) {
+	param := func(n int) string { return fmt.Sprintf("$%d", n) }
+	query := syncEventsQuery(scope.ClinicianID != nil, param)
+	args := []any{after, before, scope.ClinicID}
+	if scope.ClinicianID != nil {
+		args = append(args, *scope.ClinicianID)
+	}
+	args = append(args, limit)
+	query += " LIMIT " + param(len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list sync events: %w", err)
+	}
+	defer rows.Close()
+
+	var result []SyncEvent
+	for rows.Next() {
+		var e SyncEvent
+		if err := rows.Scan(&e.ID, &e.AppointmentID); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *PgRepository) EventSequenceBounds(ctx context.Context, before time.Time) (oldest, latest int64, err error) {
+	err = r.db.QueryRow(ctx, `
+		SELECT COALESCE((SELECT min(id) FROM event_logs), 0),
+		       COALESCE((SELECT max(id) FROM event_logs WHERE created_at < $1), 0)
+	`, before).Scan(&oldest, &latest)
+	return oldest, latest, err
+}
+
+func (r *PgRepository) ListClinicianSlotsOverlapping(ctx context.Context, clinicianID uuid.UUID, start, end time.Time) ([]AppointmentSlot, error) {
+	query := clinicianSlotsOverlappingQuery(func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, clinicianID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("list overlapping slots: %w", err)
+	}
+	defer rows.Close()
+
+	var result []AppointmentSlot
+	for rows.Next() {
+		s, err := scanSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *PgRepository) ListActiveSlotAppointments(ctx context.Context, slotID uuid.UUID) ([]Appointment, error) {
+	query := activeSlotAppointmentsQuery(func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, slotID, slotID)
+	if err != nil {
+		return nil, fmt.Errorf("list slot appointments: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Appointment
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *PgRepository) ReassignSlot(ctx context.Context, slotID, from, to uuid.UUID) (*AppointmentSlot, error) {
+	return scanSlot(r.db.QueryRow(ctx, `
+		UPDATE appointment_slots
+		SET practitioner_id = $3, updated_at = now()
+		WHERE id = $1 AND practitioner_id = $2
+		RETURNING id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+	`, slotID, from, to))
+}
+
+func (r *PgRepository) UpdateSlot(ctx context.Context, s AppointmentSlot) (*AppointmentSlot, error) {
+	return scanSlot(r.db.QueryRow(ctx, `
+		UPDATE appointment_slots
+		SET start_time = $2, end_time = $3, status = $4, capacity = $5, slot_type = $6, updated_at = now()
+		WHERE id = $1
+		RETURNING id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+	`, s.ID, s.StartTime, s.EndTime, s.Status, s.Capacity, s.SlotType))
+}
+
+func (r *PgRepository) CreatePendingAppointment(ctx context.Context, slotID, patientID uuid.UUID, expiresAt time.Time) (*Appointment, error) {
+	id := uuid.New()
+
+	row := r.db.QueryRow(ctx, pgCreatePendingQuery, id, slotID, patientID, expiresAt)
+
+	return scanAppointment(row)
+}
+
+func (r *PgRepository) UpdateAppointmentStatus(ctx context.Context, id uuid.UUID, from, to AppointmentStatus) (*Appointment, error) {
+	row := r.db.QueryRow(ctx, `
+		UPDATE appointments
+		SET status = $2,
+		    updated_at = now()
+		WHERE id = $1
+		  AND status = $3
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, id, to, from)
+
+	return scanAppointment(row)
+}
+
+func (r *PgRepository) CheckInAppointment(ctx context.Context, id uuid.UUID, at time.Time) (*Appointment, error) {
+	row := r.db.QueryRow(ctx, `
+		UPDATE appointments
+		SET status = 'checked_in',
+		    checked_in_at = $2,
+		    updated_at = now()
+		WHERE id = $1
+		  AND status = 'confirmed'
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, id, at)
+
+	return scanAppointment(row)
+}
+
+func (r *PgRepository) TransferHold(ctx context.Context, id, from, to uuid.UUID, now time.Time) (*Appointment, error) {
+	row := r.db.QueryRow(ctx, `
+		UPDATE appointments
+		SET slot_id = $3,
+		    updated_at = now()
+		WHERE id = $1
+		  AND slot_id = $2
+		  AND status = 'pending'
+		  AND (expires_at IS NULL OR expires_at >= $4)
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, id, from, to, now)
+
+	return scanAppointment(row)
+}
+
+func (r *PgRepository) ResolvePendingAppointment(ctx context.Context, id uuid.UUID, to AppointmentStatus, now time.Time) (*Appointment, error) {
+	var deadline string
+	switch to {
+	case StatusConfirmed:
+		deadline = "(expires_at IS NULL OR expires_at >= $3)"
+	case StatusExpired:
+		deadline = "(expires_at IS NOT NULL AND expires_at < $3)"
+	default:
+		return nil, fmt.Errorf("resolve pending appointment to %s: %w", to, ErrInvalidStatusTransition)
+	}
+
+	row := r.db.QueryRow(ctx, `
+		UPDATE appointments
+		SET status = $2,
+		    updated_at = now()
+		WHERE id = $1
+		  AND status = 'pending'
+		  AND `+deadline+`
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, id, to, now)
+
+	return scanAppointment(row)
+}
+
+func (r *PgRepository) RequestApproval(ctx context.Context, id uuid.UUID, now, deadline time.Time) (*Appointment, error) {
+	row := r.db.QueryRow(ctx, `
+		UPDATE appointments
+		SET status = 'pending_approval',
+		    expires_at = $3,
+		    updated_at = now()
+		WHERE id = $1
+		  AND status = 'pending'
+		  AND (expires_at IS NULL OR expires_at >= $2)
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, id, now, deadline)
+
+	return scanAppointment(row)
+}
+
+func (r *PgRepository) ResolveApproval(ctx context.Context, id uuid.UUID, to AppointmentStatus, now time.Time) (*Appointment, error) {
+	args := []any{id, to}
+	var deadline string
+	switch to {
+	case StatusConfirmed:
+		deadline = "AND (expires_at IS NULL OR expires_at >= $3)"
+		args = append(args, now)
+	case StatusRejected:
+	default:
+		return nil, fmt.Errorf("resolve approval to %s: %w", to, ErrInvalidStatusTransition)
+	}
+
+	row := r.db.QueryRow(ctx, `
+		UPDATE appointments
+		SET status = $2,
+		    updated_at = now()
+		WHERE id = $1
+		  AND status = 'pending_approval'
+		  `+deadline+`
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, args...)
+
+	return scanAppointment(row)
+}
+
+func (r *PgRepository) FindExpiredPending(ctx context.Context, now time.Time) ([]Appointment, error) {
+	return r.findPastDeadline(ctx, StatusPending, now)
+}
+
+func (r *PgRepository) FindOverdueApprovals(ctx context.Context, now time.Time) ([]Appointment, error) {
+	return r.findPastDeadline(ctx, StatusPendingApproval, now)
+}
+
+// findPastDeadline lists appointments in status whose expires_at passed before now
+func (r *PgRepository) findPastDeadline(ctx context.Context, status AppointmentStatus, now time.Time) ([]Appointment, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, slot_id, patient_id, status, created_at, updated_at, expires_at
+		FROM appointments
+		WHERE status = $1
+		  AND expires_at IS NOT NULL
+		  AND expires_at < $2
+	`, status, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Appointment
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) CountOverduePending(ctx context.Context, cutoffs []time.Time) ([]int, *time.Time, error) {
+	args := make([]any, len(cutoffs))
+	for i, c := range cutoffs {
+		args[i] = c
+	}
+	counts := make([]int, len(cutoffs))
+	dest := make([]any, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	query := overduePendingQuery(func(n int) string { return fmt.Sprintf("$%d", n) }, len(cutoffs))
+	if err := r.db.QueryRow(ctx, query, args...).Scan(dest...); err != nil {
+		return nil, nil, fmt.Errorf("count overdue pending: %w", err)
+	}
+
+	var oldest time.Time
+	err := r.db.QueryRow(ctx, `
+		SELECT expires_at
+		FROM appointments
+		WHERE status = 'pending'
+		  AND expires_at IS NOT NULL
+		  AND expires_at < $1
+		ORDER BY expires_at
+		LIMIT 1
+	`, args[0]).Scan(&oldest)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return counts, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("find oldest overdue pending: %w", err)
+	}
+	return counts, &oldest, nil
+}
+
+func (r *PgRepository) InsertEvent(ctx context.Context, ev EventLog) error {
+	var appID *uuid.UUID
+	if ev.AppointmentID != nil {
+		appID = ev.AppointmentID
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO event_logs (event_type, appointment_id, payload, created_at)
+		VALUES ($1, $2, $3, COALESCE($4, now()))
+	`, ev.EventType, appID, ev.Payload, nullableTime(ev.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("insert event log: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PgRepository) ListExpiryEventMismatches(ctx context.Context, since time.Time, limit int) ([]ExpiryEventMismatch, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT a.id, a.status, count(*), a.updated_at
+		FROM event_logs e
+		JOIN appointments a ON a.id = e.appointment_id
+		WHERE e.event_type = $1
+		  AND e.created_at >= $2
+		GROUP BY a.id, a.status, a.updated_at
+		HAVING a.status <> 'expired' OR count(*) > 1
+		ORDER BY a.updated_at DESC
+		LIMIT $3
+	`, EventAppointmentExpired, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list expiry event mismatches: %w", err)
+	}
+	defer rows.Close()
+
+	var result []ExpiryEventMismatch
+	for rows.Next() {
+		var m ExpiryEventMismatch
+		if err := rows.Scan(&m.AppointmentID, &m.Status, &m.ExpiredEvents, &m.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan expiry event mismatch: %w", err)
+		}
+		result = append(result, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) ListOrphanedAppointments(ctx context.Context, staleBefore time.Time, limit int) ([]OrphanedAppointment, error) {
+	query := orphanedAppointmentsQuery(func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, staleBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list orphaned appointments: %w", err)
+	}
+	defer rows.Close()
+
+	var result []OrphanedAppointment
+	for rows.Next() {
+		o, err := scanOrphanedAppointment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan orphaned appointment: %w", err)
+		}
+		result = append(result, *o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) ListPatientTimeline(ctx context.Context, patientID uuid.UUID, limit int) ([]TimelineEntry, error) {
+	query := patientTimelineQuery(func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, patientID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list patient timeline: %w", err)
+	}
+	defer rows.Close()
+
+	var result []TimelineEntry
+	for rows.Next() {
+		t, err := scanTimelineEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan timeline entry: %w", err)
+		}
+		result = append(result, *t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) ListClinicianSlotsAt(ctx context.Context, clinicianID uuid.UUID, starts []time.Time) ([]AppointmentSlot, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+		FROM appointment_slots
+		WHERE practitioner_id = $1
+		  AND start_time = ANY($2)
+		ORDER BY start_time, id
+	`, clinicianID, starts)
+	if err != nil {
+		return nil, fmt.Errorf("list clinician slots: %w", err)
+	}
+	defer rows.Close()
+
+	var result []AppointmentSlot
+	for rows.Next() {
+		s, err := scanSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) CreateSeries(ctx context.Context, s Series) (*Series, error) {
+	row := r.db.QueryRow(ctx, `
+		INSERT INTO appointment_series (id, patient_id, clinician_id, interval_days, time_zone, created_at)
+		VALUES ($1, $2, $3, $4, $5, now())
+		RETURNING id, patient_id, clinician_id, interval_days, time_zone, created_at
+	`, s.ID, s.PatientID, s.ClinicianID, s.IntervalDays, s.TimeZone)
+	created, err := scanSeries(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert series: %w", err)
+	}
+	return created, nil
+}
+
+func (r *PgRepository) AddSeriesOccurrence(ctx context.Context, seriesID uuid.UUID, number int, appointmentID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO appointment_series_occurrences (series_id, occurrence, appointment_id)
+		VALUES ($1, $2, $3)
+	`, seriesID, number, appointmentID)
+	if err != nil {
+		return fmt.Errorf("insert series occurrence: %w", err)
+	}
+	return nil
+}
+
+func (r *PgRepository) MoveSeriesOccurrence(ctx context.Context, seriesID uuid.UUID, number int, appointmentID uuid.UUID, exception bool) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE appointment_series_occurrences
+		SET appointment_id = $3,
+		    exception = exception OR $4
+		WHERE series_id = $1
+		  AND occurrence = $2
+	`, seriesID, number, appointmentID, exception)
+	if err != nil {
+		return fmt.Errorf("update series occurrence: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOccurrenceNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) MoveAppointmentOccurrence(ctx context.Context, from, to uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE appointment_series_occurrences
+		SET appointment_id = $2,
+		    exception = true
+		WHERE appointment_id = $1
+	`, from, to)
+	if err != nil {
+		return false, fmt.Errorf("update series occurrence: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *PgRepository) GetSeries(ctx context.Context, id uuid.UUID) (*Series, error) {
+	s, err := scanSeries(r.db.QueryRow(ctx, seriesSelect+`
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	query := seriesOccurrencesQuery(func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("list series occurrences: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		o, err := scanSeriesOccurrence(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan series occurrence: %w", err)
+		}
+		s.Occurrences = append(s.Occurrences, *o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (r *PgRepository) AddExtraSlots(ctx context.Context, appointmentID uuid.UUID, slotIDs []uuid.UUID) error {
+	for i, slotID := range slotIDs {
+		_, err := r.db.Exec(ctx, `
+			INSERT INTO appointment_extra_slots (appointment_id, position, slot_id)
+			VALUES ($1, $2, $3)
+		`, appointmentID, i+2, slotID)
+		if err != nil {
+			return fmt.Errorf("insert extra slot: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *PgRepository) ListAppointmentSlots(ctx context.Context, appointmentID uuid.UUID) ([]AppointmentSlot, error) {
+	query := appointmentSlotsQuery(func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, appointmentID, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("list appointment slots: %w", err)
+	}
+	defer rows.Close()
+
+	var result []AppointmentSlot
+	for rows.Next() {
+		s, err := scanSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, ErrAppointmentNotFound
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) ListAvailableResources(ctx context.Context, clinicID uuid.UUID, req ResourceRequirement, start, end time.Time) ([]StaffResource, error) {
+	rows, err := r.db.Query(ctx, availableResourcesQuery(func(n int) string { return fmt.Sprintf("$%d", n) }), clinicID, req.Kind, req.Language, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("list available resources: %w", err)
+	}
+	defer rows.Close()
+
+	var result []StaffResource
+	for rows.Next() {
+		res, err := scanStaffResource(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) ReserveResource(ctx context.Context, appointmentID, resourceID uuid.UUID, start, end time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO appointment_resources (appointment_id, resource_id, start_time, end_time)
+		VALUES ($1, $2, $3, $4)
+	`, appointmentID, resourceID, start, end)
+	if err != nil {
+		return fmt.Errorf("reserve resource: %w", err)
+	}
+	return nil
+}
+
+func (r *PgRepository) ListAppointmentResources(ctx context.Context, appointmentID uuid.UUID) ([]StaffResource, error) {
+	rows, err := r.db.Query(ctx, appointmentResourcesQuery(func(n int) string { return fmt.Sprintf("$%d", n) }), appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("list appointment resources: %w", err)
+	}
+	defer rows.Close()
+
+	var result []StaffResource
+	for rows.Next() {
+		res, err := scanStaffResource(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) InsertAttachment(ctx context.Context, a Attachment) (*Attachment, error) {
+	row := r.db.QueryRow(ctx, `
+		INSERT INTO appointment_attachments (`+attachmentColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+		RETURNING `+attachmentColumns,
+		a.ID, a.AppointmentID, a.Kind, a.Filename, a.ContentType, a.Size, a.BlobKey, a.ScanStatus)
+	saved, err := scanAttachment(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert attachment: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *PgRepository) GetAttachment(ctx context.Context, id uuid.UUID) (*Attachment, error) {
+	return scanAttachment(r.db.QueryRow(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM appointment_attachments
+		WHERE id = $1
+	`, id))
+}
+
+func (r *PgRepository) ListAttachments(ctx context.Context, appointmentID uuid.UUID) ([]Attachment, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM appointment_attachments
+		WHERE appointment_id = $1
+		ORDER BY created_at, id
+	`, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) SetAppointmentReason(ctx context.Context, id uuid.UUID, reason string) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE appointments
+		SET reason = $2,
+		    updated_at = now()
+		WHERE id = $1
+	`, id, reason)
+	if err != nil {
+		return fmt.Errorf("set appointment reason: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAppointmentNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) InsertAppointmentNote(ctx context.Context, n AppointmentNote) (*AppointmentNote, error) {
+	row := r.db.QueryRow(ctx, `
+		INSERT INTO appointment_notes (`+noteColumns+`)
+		VALUES ($1, $2, $3, $4, now())
+		RETURNING `+noteColumns,
+		n.ID, n.AppointmentID, n.Author, n.Body)
+	saved, err := scanNote(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert appointment note: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *PgRepository) ListAppointmentNotes(ctx context.Context, appointmentID uuid.UUID) ([]AppointmentNote, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+noteColumns+`
+		FROM appointment_notes
+		WHERE appointment_id = $1
+		ORDER BY created_at, id
+	`, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("list appointment notes: %w", err)
+	}
+	defer rows.Close()
+
+	var result []AppointmentNote
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *PgRepository) GetIntakeTemplate(ctx context.Context, slotType string) (*IntakeTemplate, error) {
+	return scanIntakeTemplate(r.db.QueryRow(ctx, intakeTemplateSelect+`
+		WHERE slot_type = $1
+	`, slotType))
+}
+
+func (r *PgRepository) ListIntakeTemplates(ctx context.Context) ([]IntakeTemplate, error) {
+	rows, err := r.db.Query(ctx, intakeTemplateSelect+`
+		ORDER BY slot_type
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list intake templates: %w", err)
+	}
+	defer rows.Close()
+
+	var result []IntakeTemplate
+	for rows.Next() {
+		t, err := scanIntakeTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) PutIntakeTemplate(ctx context.Context, t IntakeTemplate) (*IntakeTemplate, error) {
+	questions, err := encodeIntakeQuestions(t.Questions)
+	if err != nil {
+		return nil, fmt.Errorf("encode intake questions: %w", err)
+	}
+	row := r.db.QueryRow(ctx, `
+		INSERT INTO intake_templates (slot_type, version, questions, updated_at)
+		VALUES ($1, 1, $2, now())
+		ON CONFLICT (slot_type)
+		DO UPDATE SET version = intake_templates.version + 1,
+		              questions = excluded.questions,
+		              updated_at = excluded.updated_at
+		RETURNING slot_type, version, questions, updated_at
+	`, t.SlotType, questions)
+	saved, err := scanIntakeTemplate(row)
+	if err != nil {
+		return nil, fmt.Errorf("put intake template: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *PgRepository) DeleteIntakeTemplate(ctx context.Context, slotType string) error {
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM intake_templates
+		WHERE slot_type = $1
+	`, slotType)
+	if err != nil {
+		return fmt.Errorf("delete intake template: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrIntakeTemplateNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) GetAvailabilityTemplate(ctx context.Context, clinicianID uuid.UUID) (*AvailabilityTemplate, error) {
+	return scanAvailabilityTemplate(r.db.QueryRow(ctx, `
+		SELECT `+availabilityTemplateColumns+`
+		FROM availability_templates
+		WHERE clinician_id = $1
+	`, clinicianID))
+}
+
+func (r *PgRepository) PutAvailabilityTemplate(ctx context.Context, t AvailabilityTemplate) (*AvailabilityTemplate, error) {
+	periods, err := encodeAvailabilityPeriods(t.Periods)
+	if err != nil {
+		return nil, fmt.Errorf("encode availability periods: %w", err)
+	}
+	row := r.db.QueryRow(ctx, `
+		INSERT INTO availability_templates (clinician_id, time_zone, slot_minutes, capacity, slot_type, periods, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, now())
+		ON CONFLICT (clinician_id)
+		DO UPDATE SET time_zone = excluded.time_zone,
+		              slot_minutes = excluded.slot_minutes,
+		              capacity = excluded.capacity,
+		              slot_type = excluded.slot_type,
+		              periods = excluded.periods,
+		              updated_at = excluded.updated_at
+		RETURNING `+availabilityTemplateColumns+`
+	`, t.ClinicianID, t.TimeZone, int(t.SlotDuration/time.Minute), t.Capacity, t.SlotType, periods)
+	saved, err := scanAvailabilityTemplate(row)
+	if err != nil {
+		return nil, fmt.Errorf("put availability template: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *PgRepository) DeleteAvailabilityTemplate(ctx context.Context, clinicianID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM availability_templates
+		WHERE clinician_id = $1
+	`, clinicianID)
+	if err != nil {
+		return fmt.Errorf("delete availability template: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAvailabilityTemplateNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) CreateSlots(ctx context.Context, slots []AppointmentSlot) ([]AppointmentSlot, error) {
+	created := make([]AppointmentSlot, 0, len(slots))
+	for _, s := range slots {
+		saved, err := scanSlot(r.db.QueryRow(ctx, `
+			INSERT INTO appointment_slots (id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now())
+			RETURNING id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+		`, s.ID, s.PractitionerID, s.StartTime, s.EndTime, s.Status, s.Capacity, s.SlotType))
+		if err != nil {
+			return nil, fmt.Errorf("create slot: %w", err)
+		}
+		created = append(created, *saved)
+	}
+	return created, nil
+}
+
+func (r *PgRepository) GetIntakeResponse(ctx context.Context, appointmentID uuid.UUID) (*IntakeResponse, error) {
+	return scanIntakeResponse(r.db.QueryRow(ctx, `
+		SELECT `+intakeResponseColumns+`
+		FROM intake_responses
+		WHERE appointment_id = $1
+	`, appointmentID))
+}
+
+func (r *PgRepository) PutIntakeResponse(ctx context.Context, resp IntakeResponse) (*IntakeResponse, error) {
+	answers, err := json.Marshal(resp.Answers)
+	if err != nil {
+		return nil, fmt.Errorf("encode intake answers: %w", err)
+	}
+	row := r.db.QueryRow(ctx, `
+		INSERT INTO intake_responses (appointment_id, template_version, answers, complete, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, now(), now())
+		ON CONFLICT (appointment_id)
+		DO UPDATE SET template_version = excluded.template_version,
+		              answers = excluded.answers,
+		              complete = excluded.complete,
+		              updated_at = excluded.updated_at
+		RETURNING `+intakeResponseColumns,
+		resp.AppointmentID, resp.TemplateVersion, string(answers), resp.Complete)
+	saved, err := scanIntakeResponse(row)
+	if err != nil {
+		return nil, fmt.Errorf("put intake response: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *PgRepository) ListIntakeReminders(ctx context.Context, from, to time.Time, eventType string, limit int) ([]IntakeReminder, error) {
+	rows, err := r.db.Query(ctx, intakeRemindersQuery(func(n int) string { return fmt.Sprintf("$%d", n) }), from, to, eventType, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list intake reminders: %w", err)
+	}
+	defer rows.Close()
+
+	var result []IntakeReminder
+	for rows.Next() {
+		var rem IntakeReminder
+		if err := rows.Scan(&rem.AppointmentID, &rem.SlotType, &rem.SlotStart); err != nil {
+			return nil, err
+		}
+		result = append(result, rem)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) InsertFeedback(ctx context.Context, f Feedback) (*Feedback, error) {
+	saved, err := scanFeedback(r.db.QueryRow(ctx, `
+		INSERT INTO appointment_feedback (appointment_id, clinician_id, rating, comment, created_at)
+		VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (appointment_id) DO NOTHING
+		RETURNING `+feedbackColumns,
+		f.AppointmentID, f.ClinicianID, f.Rating, f.Comment))
+	if errors.Is(err, ErrFeedbackNotFound) {
+		return nil, ErrFeedbackAlreadyGiven
+	}
+	if err != nil {
+		return nil, fmt.Errorf("insert feedback: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *PgRepository) GetFeedback(ctx context.Context, appointmentID uuid.UUID) (*Feedback, error) {
+	return scanFeedback(r.db.QueryRow(ctx, `
+		SELECT `+feedbackColumns+`
+		FROM appointment_feedback
+		WHERE appointment_id = $1
+	`, appointmentID))
+}
+
+func (r *PgRepository) ListClinicianRatings(ctx context.Context, since time.Time, minCount int) ([]ClinicianRating, error) {
+	rows, err := r.db.Query(ctx, clinicianRatingsQuery(func(n int) string { return fmt.Sprintf("$%d", n) }), since, minCount)
+	if err != nil {
+		return nil, fmt.Errorf("list clinician ratings: %w", err)
+	}
+	defer rows.Close()
+
+	var result []ClinicianRating
+	for rows.Next() {
+		cr, err := scanClinicianRating(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *cr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) ListClinicianFeedback(ctx context.Context, clinicianID uuid.UUID, limit int) ([]Feedback, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+feedbackColumns+`
+		FROM appointment_feedback
+		WHERE clinician_id = $1
+		ORDER BY created_at DESC, appointment_id
+		LIMIT $2
+	`, clinicianID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list clinician feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Feedback
+	for rows.Next() {
+		f, err := scanFeedback(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) ListFeedbackRequests(ctx context.Context, from, to time.Time, eventType string, limit int) ([]FeedbackRequest, error) {
+	rows, err := r.db.Query(ctx, feedbackRequestsQuery(func(n int) string { return fmt.Sprintf("$%d", n) }), from, to, to, eventType, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list feedback requests: %w", err)
+	}
+	defer rows.Close()
+
+	var result []FeedbackRequest
+	for rows.Next() {
+		var req FeedbackRequest
+		if err := rows.Scan(&req.AppointmentID, &req.ClinicianID, &req.SlotEnd); err != nil {
+			return nil, err
+		}
+		result = append(result, req)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) GetRetentionPolicy(ctx context.Context) (*RetentionPolicy, error) {
+	return scanRetentionPolicy(r.db.QueryRow(ctx, `
+		SELECT `+retentionPolicyColumns+`
+		FROM retention_policy
+		WHERE id = 1
+	`))
 }
 
-func NewPgRepository(pool *pgxpool.Pool) *PgRepository {
-	return &PgRepository{pool: pool}
+func (r *PgRepository) PutRetentionPolicy(ctx context.Context, p RetentionPolicy) (*RetentionPolicy, error) {
+	saved, err := scanRetentionPolicy(r.db.QueryRow(ctx, `
+		INSERT INTO retention_policy (id, tenant_id, appointment_months, event_months, feedback_months, updated_at)
+		VALUES (1, $1, $2, $3, $4, now())
+		ON CONFLICT (id)
+		DO UPDATE SET tenant_id = excluded.tenant_id,
+		              appointment_months = excluded.appointment_months,
+		              event_months = excluded.event_months,
+		              feedback_months = excluded.feedback_months,
+		              updated_at = excluded.updated_at
+		RETURNING `+retentionPolicyColumns,
+		p.TenantID, p.AppointmentMonths, p.EventMonths, p.FeedbackMonths))
+	if err != nil {
+		return nil, fmt.Errorf("put retention policy: %w", err)
+	}
+	return saved, nil
 }
 
-// Helpers
+func (r *PgRepository) DeleteRetentionPolicy(ctx context.Context) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM retention_policy WHERE id = 1`)
+	if err != nil {
+		return fmt.Errorf("delete retention policy: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRetentionPolicyNotFound
+	}
+	return nil
+}
 
-func scanPatient(row pgx.Row) (*Patient, error) {
-	var p Patient
-	var email *string
+func (r *PgRepository) CountExpiredRecords(ctx context.Context, entity RetentionEntity, before time.Time) (int, error) {
+	query := expiredRecordsCountQuery(entity, func(n int) string { return fmt.Sprintf("$%d", n) })
+	args := []any{before}
+	if entity == RetainAppointments {
+		args = append(args, before)
+	}
+	var n int
+	if err := r.db.QueryRow(ctx, query, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count expired %s: %w", entity, err)
+	}
+	return n, nil
+}
 
-	err := row.Scan(
-		&p.ID,
-		&p.Name,
-		&email,
-		&p.CreatedAt,
-		&p.UpdatedAt,
-	)
+func (r *PgRepository) ListExpiredAppointments(ctx context.Context, before time.Time, limit int) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `SELECT a.id`+expiredAppointmentsWhere(func(n int) string { return fmt.Sprintf("$%d", n) })+`
+		ORDER BY s.end_time, a.id
+		LIMIT $3
+	`, before, before, limit)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrPatientNotFound
+		return nil, fmt.Errorf("list expired appointments: %w", err)
+	}
+	defer rows.Close()
+
+	var result []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
 		}
+		result = append(result, id)
+	}
+
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	p.Email = email
-	return &p, nil
+	return result, nil
 }
 
-func scanClinician(row pgx.Row) (*Clinician, error) {
-	var c Clinician
-	var specialty *string
-
-	err := row.Scan(
-		&c.ID,
-		&c.Name,
-		&specialty,
-		&c.CreatedAt,
-		&c.UpdatedAt,
-	)
+func (r *PgRepository) ListAttachmentBlobKeys(ctx context.Context, appointmentIDs []uuid.UUID) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT blob_key
+		FROM appointment_attachments
+		WHERE appointment_id = ANY($1)
+	`, appointmentIDs)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrClinicianNotFound
+		return nil, fmt.Errorf("list attachment blob keys: %w", err)
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
 		}
+		result = append(result, key)
+	}
+
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	c.Specialty = specialty
-	return &c, nil
+	return result, nil
 }
 
-func scanSlot(row pgx.Row) (*AppointmentSlot, error) {
-	var s AppointmentSlot
+func (r *PgRepository) DeleteAppointments(ctx context.Context, ids []uuid.UUID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
 
-	err := row.Scan(
-		&s.ID,
-		&s.PractitionerID,
-		&s.StartTime,
-		&s.EndTime,
-		&s.Status,
-		&s.Capacity,
-		&s.CreatedAt,
-		&s.UpdatedAt,
-	)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrSlotNotFound
+	var deleted int64
+	for _, stmt := range appointmentDeleteStatements(`SELECT unnest($1::uuid[])`) {
+		tag, err := r.db.Exec(ctx, stmt, ids)
+		if err != nil {
+			return 0, fmt.Errorf("delete appointments: %w", err)
 		}
-		return nil, err
+		deleted = tag.RowsAffected()
 	}
+	return int(deleted), nil
+}
 
-	return &s, nil
+func (r *PgRepository) DeleteEventsBefore(ctx context.Context, before time.Time, limit int) (int, error) {
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM event_logs
+		WHERE id IN (
+		    SELECT id
+		    FROM event_logs
+		    WHERE created_at < $1
+		    ORDER BY created_at
+		    LIMIT $2
+		)
+	`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("delete events: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
 }
 
-func scanAppointment(row pgx.Row) (*Appointment, error) {
-	var a Appointment
-	var expiresAt *time.Time
+func (r *PgRepository) DeleteFeedbackBefore(ctx context.Context, before time.Time, limit int) (int, error) {
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM appointment_feedback
+		WHERE appointment_id IN (
+		    SELECT appointment_id
+		    FROM appointment_feedback
+		    WHERE created_at < $1
+		    ORDER BY created_at
+		    LIMIT $2
+		)
+	`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("delete feedback: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
 
-	err := row.Scan(
-		&a.ID,
-		&a.SlotID,
-		&a.PatientID,
-		&a.Status,
-		&a.CreatedAt,
-		&a.UpdatedAt,
-		&expiresAt,
-	)
+func (r *PgRepository) InsertRetentionRun(ctx context.Context, run RetentionRun) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO retention_runs (`+retentionRunColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, run.ID, run.Entity, run.TenantID, run.RetainMonths, run.Cutoff, run.DryRun,
+		run.Matched, run.Deleted, run.StartedAt, run.FinishedAt)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrAppointmentNotFound
+		return fmt.Errorf("insert retention run: %w", err)
+	}
+	return nil
+}
+
+func (r *PgRepository) ListRetentionRuns(ctx context.Context, limit int) ([]RetentionRun, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+retentionRunColumns+`
+		FROM retention_runs
+		ORDER BY started_at DESC, id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list retention runs: %w", err)
+	}
+	defer rows.Close()
+
+	var result []RetentionRun
+	for rows.Next() {
+		run, err := scanRetentionRun(rows)
+		if err != nil {
+			return nil, err
 		}
+		result = append(result, *run)
+	}
+
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	a.ExpiresAt = expiresAt
-	return &a, nil
+	return result, nil
 }
 
-// Interface methods
+func (r *PgRepository) SearchSlots(ctx context.Context, q SlotQuery, now time.Time) ([]OpenSlot, error) {
+	var after *pageKey
+	if q.Token != "" {
+		key, err := decodePageToken(q.Token)
+		if err != nil {
+			return nil, err
+		}
+		after = key
+	}
 
-func (r *PgRepository) GetPatientByID(ctx context.Context, id uuid.UUID) (*Patient, error) {
-	row := r.pool.QueryRow(ctx, `
-		SELECT id, name, email, created_at, updated_at
-		FROM patients
-		WHERE id = $1
-	`, id)
-	return scanPatient(row)
-}
+	query, args := slotsQuery(q, after, now, func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search slots: %w", err)
+	}
+	defer rows.Close()
 
-func (r *PgRepository) GetClinicianByID(ctx context.Context, id uuid.UUID) (*Clinician, error) {
-	row := r.pool.QueryRow(ctx, `
-		SELECT id, name, specialty, created_at, updated_at
-		FROM clinicians
-		WHERE id = $1
-	`, id)
-	return scanClinician(row)
-}
+	var result []OpenSlot
+	for rows.Next() {
+		o, err := scanOpenSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *o)
+	}
 
-func (r *PgRepository) GetSlotByID(ctx context.Context, id uuid.UUID) (*AppointmentSlot, error) {
-	row := r.pool.QueryRow(ctx, `
-		SELECT id, practitioner_id, start_time, end_time, status, capacity, created_at, updated_at
-		FROM appointment_slots
-		WHERE id = $1
-	`, id)
-	return scanSlot(row)
-}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-func (r *PgRepository) GetAppointmentByID(ctx context.Context, id uuid.UUID) (*Appointment, error) {
-	row := r.pool.QueryRow(ctx, `
-		SELECT id, slot_id, patient_id, status, created_at, updated_at, expires_at
-		FROM appointments
-		WHERE id = $1
-	`, id)
-	return scanAppointment(row)
+	return result, nil
 }
 
-func (r *PgRepository) GetConfirmedAppointmentForSlot(ctx context.Context, slotID uuid.UUID) (*Appointment, error) {
-	row := r.pool.QueryRow(ctx, `
-		SELECT id, slot_id, patient_id, status, created_at, updated_at, expires_at
-		FROM appointments
-		WHERE slot_id = $1 AND status = 'confirmed'
-	`, slotID)
-	return scanAppointment(row)
+// UpsertPatientByEmail updates nothing on a match; the no-op update only
+// makes RETURNING give back the existing row
+func (r *PgRepository) UpsertPatientByEmail(ctx context.Context, p Patient) (*Patient, error) {
+	row := r.db.QueryRow(ctx, `
+		INSERT INTO patients (id, name, email, created_at, updated_at)
+		VALUES ($1, $2, $3, now(), now())
+		ON CONFLICT (email) DO UPDATE SET email = EXCLUDED.email
+		RETURNING `+patientColumns+`
+	`, p.ID, p.Name, p.Email)
+	return scanPatient(row)
 }
 
-func (r *PgRepository) CreatePendingAppointment(ctx context.Context, slotID, patientID uuid.UUID, expiresAt time.Time) (*Appointment, error) {
-	id := uuid.New()
-
-	row := r.pool.QueryRow(ctx, `
-		INSERT INTO appointments (id, slot_id, patient_id, status, created_at, updated_at, expires_at)
-		VALUES ($1, $2, $3, 'pending', now(), now(), $4)
-		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
-	`, id, slotID, patientID, expiresAt)
-
-	return scanAppointment(row)
-}
+func (r *PgRepository) InsertPIIAccess(ctx context.Context, records []PIIAccess) error {
+	if len(records) == 0 {
+		return nil
+	}
 
-func (r *PgRepository) UpdateAppointmentStatus(ctx context.Context, id uuid.UUID, from, to AppointmentStatus) (*Appointment, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE appointments
-		SET status = $2,
-		    updated_at = now()
-		WHERE id = $1
-		  AND status = $3
-		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
-	`, id, to, from)
+	ids := make([]uuid.UUID, len(records))
+	actors := make([]string, len(records))
+	kinds := make([]string, len(records))
+	reasons := make([]*string, len(records))
+	patients := make([]uuid.UUID, len(records))
+	endpoints := make([]string, len(records))
+	requests := make([]string, len(records))
+	times := make([]time.Time, len(records))
+	for i, a := range records {
+		ids[i], actors[i], kinds[i], reasons[i] = a.ID, a.Actor, string(a.ActorKind), a.Reason
+		patients[i], endpoints[i], requests[i], times[i] = a.PatientID, a.Endpoint, a.RequestID, a.AccessedAt
+	}
 
-	return scanAppointment(row)
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO pii_access_log (`+piiAccessColumns+`)
+		SELECT * FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::uuid[], $6::text[], $7::text[], $8::timestamptz[])
+	`, ids, actors, kinds, reasons, patients, endpoints, requests, times)
+	if err != nil {
+		return fmt.Errorf("insert pii access: %w", err)
+	}
+	return nil
 }
 
-func (r *PgRepository) FindExpiredPending(ctx context.Context, now time.Time) ([]Appointment, error) {
-	rows, err := r.pool.Query(ctx, `
-		SELECT id, slot_id, patient_id, status, created_at, updated_at, expires_at
-		FROM appointments
-		WHERE status = 'pending'
-		  AND expires_at IS NOT NULL
-		  AND expires_at < $1
-	`, now)
+func (r *PgRepository) ListPIIAccess(ctx context.Context, filter PIIAccessFilter) ([]PIIAccess, error) {
+	query, args := piiAccessQuery(filter, func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("list pii access: %w", err)
 	}
 	defer rows.Close()
 
-	var result []Appointment
+	var result []PIIAccess
 	for rows.Next() {
-		a, err := scanAppointment(rows)
+		a, err := scanPIIAccess(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -213,23 +1805,56 @@ func (r *PgRepository) FindExpiredPending(ctx context.Context, now time.Time) ([
 	return result, nil
 }
 
-func (r *PgRepository) InsertEvent(ctx context.Context, ev EventLog) error {
-	var appID *uuid.UUID
-	if ev.AppointmentID != nil {
-		appID = ev.AppointmentID
+func (r *PgRepository) CreateBookingIntent(ctx context.Context, intent BookingIntent) error {
+	_, err := r.db.Exec(ctx, pgCreateIntentQuery, intent.ID, intent.SlotID, intent.PatientID, intent.LockToken, intent.State)
+	if err != nil {
+		return fmt.Errorf("insert booking intent: %w", err)
 	}
 
-	_, err := r.pool.Exec(ctx, `
-		INSERT INTO event_logs (event_type, appointment_id, payload, created_at)
-		VALUES ($1, $2, $3, COALESCE($4, now()))
-	`, ev.EventType, appID, ev.Payload, nullableTime(ev.CreatedAt))
+	return nil
+}
+
+func (r *PgRepository) ResolveBookingIntent(ctx context.Context, id uuid.UUID, state BookingIntentState, appointmentID *uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, pgResolveIntentQuery, id, state, appointmentID)
 	if err != nil {
-		return fmt.Errorf("insert event log: %w", err)
+		return fmt.Errorf("resolve booking intent: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrIntentNotFound
 	}
 
 	return nil
 }
 
+func (r *PgRepository) ListPendingBookingIntents(ctx context.Context, createdBefore time.Time) ([]BookingIntent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, slot_id, patient_id, lock_token, state, appointment_id, created_at, resolved_at
+		FROM booking_intents
+		WHERE state = 'pending'
+		  AND created_at < $1
+		ORDER BY created_at
+	`, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []BookingIntent
+	for rows.Next() {
+		i, err := scanBookingIntent(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *i)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
 func nullableTime(t time.Time) *time.Time {
 	if t.IsZero() {
 		return nil
@@ -237,109 +1862,64 @@ func nullableTime(t time.Time) *time.Time {
 	return &t
 }
 
-func scanAppointmentDetail(row pgx.Row) (*AppointmentDetail, error) {
-	var a Appointment
-	var expiresAt *time.Time
-
-	// Slot fields
-	var slot AppointmentSlot
-	var slotPractitionerID uuid.UUID
-
-	// Patient fields
-	var patient Patient
-	var patientEmail *string
-
-	// Clinician fields
-	var clinician Clinician
-	var clinicianSpecialty *string
-
-	err := row.Scan(
-		// Appointment fields
-		&a.ID,
-		&a.SlotID,
-		&a.PatientID,
-		&a.Status,
-		&a.CreatedAt,
-		&a.UpdatedAt,
-		&expiresAt,
-		// Slot fields
-		&slot.ID,
-		&slotPractitionerID,
-		&slot.StartTime,
-		&slot.EndTime,
-		&slot.Status,
-		&slot.Capacity,
-		&slot.CreatedAt,
-		&slot.UpdatedAt,
-		// Patient fields
-		&patient.ID,
-		&patient.Name,
-		&patientEmail,
-		&patient.CreatedAt,
-		&patient.UpdatedAt,
-		// Clinician fields
-		&clinician.ID,
-		&clinician.Name,
-		&clinicianSpecialty,
-		&clinician.CreatedAt,
-		&clinician.UpdatedAt,
-	)
+func (r *PgRepository) GetAppointmentDetail(ctx context.Context, id uuid.UUID, fields DetailFields) (*AppointmentDetail, error) {
+	row := r.db.QueryRow(ctx, detailSelect(fields)+`
+		WHERE a.id = $1
+	`, id)
+	return scanAppointmentDetail(row, fields)
+}
+
+func (r *PgRepository) GetAppointmentDetails(ctx context.Context, ids []uuid.UUID, fields DetailFields) ([]AppointmentDetail, error) {
+	rows, err := r.db.Query(ctx, detailSelect(fields)+`
+		WHERE a.id = ANY($1)
+	`, ids)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrAppointmentNotFound
-		}
 		return nil, err
 	}
+	defer rows.Close()
 
-	a.ExpiresAt = expiresAt
-	slot.PractitionerID = slotPractitionerID
-	patient.Email = patientEmail
-	clinician.Specialty = clinicianSpecialty
+	var result []AppointmentDetail
+	for rows.Next() {
+		detail, err := scanAppointmentDetail(rows, fields)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *detail)
+	}
 
-	// Validate that IDs match
-	if a.SlotID != slot.ID || a.PatientID != patient.ID || slot.PractitionerID != clinician.ID {
-		return nil, fmt.Errorf("data integrity error: appointment/slot/patient/clinician IDs do not match")
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return &AppointmentDetail{
-		Appointment: a,
-		Slot:        &slot,
-		Patient:     &patient,
-		Clinician:   &clinician,
-	}, nil
+	return result, nil
 }
 
-func (r *PgRepository) GetAppointmentDetail(ctx context.Context, id uuid.UUID) (*AppointmentDetail, error) {
-	row := r.pool.QueryRow(ctx, `
-		SELECT 
-			a.id, a.slot_id, a.patient_id, a.status, a.created_at, a.updated_at, a.expires_at,
-			s.id, s.practitioner_id, s.start_time, s.end_time, s.status, s.capacity, s.created_at, s.updated_at,
-			p.id, p.name, p.email, p.created_at, p.updated_at,
-			c.id, c.name, c.specialty, c.created_at, c.updated_at
-		FROM appointments a
-		INNER JOIN appointment_slots s ON a.slot_id = s.id
-		INNER JOIN patients p ON a.patient_id = p.id
-		INNER JOIN clinicians c ON s.practitioner_id = c.id
-		WHERE a.id = $1
-	`, id)
-	return scanAppointmentDetail(row)
+func (r *PgRepository) ListAppointmentsByPatient(ctx context.Context, patientID uuid.UUID, page PageRequest, fields DetailFields) (*AppointmentPage, error) {
+	var after *pageKey
+	if page.Token != "" {
+		key, err := decodeSortedPageToken(page.Sort, page.Token)
+		if err != nil {
+			return nil, err
+		}
+		after = key
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	query, args := detailListQuery(fields, false, false, []string{"a.patient_id = $1"}, []any{patientID},
+		page.Sort, after, page.Limit+1, page.Offset, func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return collectPage(rows, fields, page.Sort, page.Limit)
 }
 
-func (r *PgRepository) ListAppointmentsByPatient(ctx context.Context, patientID uuid.UUID, limit, offset int) ([]AppointmentDetail, error) {
-	rows, err := r.pool.Query(ctx, `
-		SELECT 
-			a.id, a.slot_id, a.patient_id, a.status, a.created_at, a.updated_at, a.expires_at,
-			s.id, s.practitioner_id, s.start_time, s.end_time, s.status, s.capacity, s.created_at, s.updated_at,
-			p.id, p.name, p.email, p.created_at, p.updated_at,
-			c.id, c.name, c.specialty, c.created_at, c.updated_at
-		FROM appointments a
-		INNER JOIN appointment_slots s ON a.slot_id = s.id
-		INNER JOIN patients p ON a.patient_id = p.id
-		INNER JOIN clinicians c ON s.practitioner_id = c.id
-		WHERE a.patient_id = $1
-		ORDER BY a.created_at DESC
-		LIMIT $2 OFFSET $3
-	`, patientID, limit, offset)
+func (r *PgRepository) ListAppointmentsBySlot(ctx context.Context, slotID uuid.UUID, sort ListSort, fields DetailFields) ([]AppointmentDetail, error) {
+	query, args := detailListQuery(fields, false, false, []string{"a.slot_id = $1"}, []any{slotID},
+		sort, nil, 0, 0, func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -347,7 +1927,7 @@ func (r *PgRepository) ListAppointmentsByPatient(ctx context.Context, patientID
 
 	var result []AppointmentDetail
 	for rows.Next() {
-		detail, err := scanAppointmentDetail(rows)
+		detail, err := scanAppointmentDetail(rows, fields)
 		if err != nil {
 			return nil, err
 		}
@@ -361,28 +1941,73 @@ func (r *PgRepository) ListAppointmentsByPatient(ctx context.Context, patientID
 	return result, nil
 }
 
-func (r *PgRepository) ListAppointmentsBySlot(ctx context.Context, slotID uuid.UUID) ([]AppointmentDetail, error) {
-	rows, err := r.pool.Query(ctx, `
-		SELECT 
-			a.id, a.slot_id, a.patient_id, a.status, a.created_at, a.updated_at, a.expires_at,
-			s.id, s.practitioner_id, s.start_time, s.end_time, s.status, s.capacity, s.created_at, s.updated_at,
-			p.id, p.name, p.email, p.created_at, p.updated_at,
-			c.id, c.name, c.specialty, c.created_at, c.updated_at
-		FROM appointments a
-		INNER JOIN appointment_slots s ON a.slot_id = s.id
-		INNER JOIN patients p ON a.patient_id = p.id
-		INNER JOIN clinicians c ON s.practitioner_id = c.id
-		WHERE a.slot_id = $1
-		ORDER BY a.created_at DESC
-	`, slotID)
+func (r *PgRepository) SearchAppointments(ctx context.Context, q AppointmentSearch, fields DetailFields) (*AppointmentPage, error) {
+	var after *pageKey
+	if q.Token != "" {
+		key, err := decodeSortedPageToken(q.Sort, q.Token)
+		if err != nil {
+			return nil, err
+		}
+		after = key
+	}
+
+	query, args := appointmentSearchQuery(q, after, fields, func(n int) string { return fmt.Sprintf("$%d", n) })
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return collectPage(rows, fields, q.Sort, q.Limit)
+}
+
+func (r *PgRepository) StreamAppointmentsByClinic(ctx context.Context, clinicID uuid.UUID, from, to time.Time, fields DetailFields, fn func(*AppointmentDetail) error) error {
+	// Filter through a subquery so the joins stay the ones fields asks for
+	rows, err := r.db.Query(ctx, detailSelect(fields)+`
+		WHERE a.slot_id IN (
+			SELECT cs.id
+			FROM appointment_slots cs
+			INNER JOIN clinicians cc ON cc.id = cs.practitioner_id
+			WHERE cc.clinic_id = $1
+			  AND cs.start_time >= $2
+			  AND cs.start_time < $3
+		)
+		ORDER BY a.created_at, a.id
+	`, clinicID, from, to)
+	if err != nil {
+		return fmt.Errorf("stream clinic appointments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		detail, err := scanAppointmentDetail(rows, fields)
+		if err != nil {
+			return err
+		}
+		if err := fn(detail); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *PgRepository) ListClinicianAppointments(ctx context.Context, clinicianID uuid.UUID, from, to time.Time, fields DetailFields) ([]AppointmentDetail, error) {
+	rows, err := r.db.Query(ctx, detailSelectJoining(fields, true, fields.Patient)+`
+		WHERE s.practitioner_id = $1
+		  AND s.start_time >= $2
+		  AND s.start_time < $3
+		  AND a.status NOT IN ('cancelled', 'expired', 'rejected')
+		ORDER BY s.start_time, a.created_at, a.id
+	`, clinicianID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("list clinician appointments: %w", err)
+	}
+	defer rows.Close()
+
 	var result []AppointmentDetail
 	for rows.Next() {
-		detail, err := scanAppointmentDetail(rows)
+		detail, err := scanAppointmentDetail(rows, fields)
 		if err != nil {
 			return nil, err
 		}
@@ -392,6 +2017,80 @@ func (r *PgRepository) ListAppointmentsBySlot(ctx context.Context, slotID uuid.U
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-
 	return result, nil
 }
+
+// Seeder methods
+
+func (r *PgRepository) InsertClinic(ctx context.Context, c Clinic) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO clinics (id, name, requires_approval, created_at, updated_at)
+		VALUES ($1, $2, $3, now(), now())
+	`, c.ID, c.Name, c.RequiresApproval)
+	if err != nil {
+		return fmt.Errorf("insert clinic: %w", err)
+	}
+	return nil
+}
+
+func (r *PgRepository) InsertClinician(ctx context.Context, c Clinician) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO clinicians (id, name, specialty, clinic_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, now(), now())
+	`, c.ID, c.Name, c.Specialty, c.ClinicID)
+	if err != nil {
+		return fmt.Errorf("insert clinician: %w", err)
+	}
+	return nil
+}
+
+func (r *PgRepository) InsertPatient(ctx context.Context, p Patient) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO patients (id, name, email, created_at, updated_at)
+		VALUES ($1, $2, $3, now(), now())
+	`, p.ID, p.Name, p.Email)
+	if err != nil {
+		return fmt.Errorf("insert patient: %w", err)
+	}
+	return nil
+}
+
+func (r *PgRepository) InsertSlot(ctx context.Context, s AppointmentSlot) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO appointment_slots (id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now())
+	`, s.ID, s.PractitionerID, s.StartTime, s.EndTime, s.Status, s.Capacity, s.SlotType)
+	if err != nil {
+		if isSlotOverlap(err) {
+			return ErrSlotOverlap
+		}
+		return fmt.Errorf("insert slot: %w", err)
+	}
+	return nil
+}
+
+func (r *PgRepository) SetSlotTypePrice(ctx context.Context, clinicID uuid.UUID, slotType string, price Price) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO slot_type_prices (clinic_id, slot_type, amount_minor, currency, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, now(), now())
+		ON CONFLICT (clinic_id, slot_type)
+		DO UPDATE SET amount_minor = EXCLUDED.amount_minor,
+		              currency = EXCLUDED.currency,
+		              updated_at = now()
+	`, clinicID, slotType, price.AmountMinor, price.Currency)
+	if err != nil {
+		return fmt.Errorf("set slot type price: %w", err)
+	}
+	return nil
+}
+
+func (r *PgRepository) InsertStaffResource(ctx context.Context, res StaffResource) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO staff_resources (id, clinic_id, kind, language, name, created_at)
+		VALUES ($1, $2, $3, $4, $5, now())
+	`, res.ID, res.ClinicID, res.Kind, res.Language, res.Name)
+	if err != nil {
+		return fmt.Errorf("insert staff resource: %w", err)
+	}
+	return nil
+}
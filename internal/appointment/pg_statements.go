@@ -0,0 +1,68 @@
+package appointment
+
+import "fmt"
+
+// The queries every booking runs. pgx caches a statement on a connection
+// the first time it runs there; PgBookingStatements lets the pool prepare
+// these as soon as a connection opens instead, so the first bookings on a
+// fresh connection skip the parse and plan round trip.
+const (
+	pgGetPatientQuery = `
+		SELECT ` + patientColumns + `
+		FROM patients
+		WHERE id = $1
+	`
+	pgGetClinicianQuery = `
+		SELECT ` + clinicianColumns + `
+		FROM clinicians
+		WHERE id = $1
+	`
+	pgGetSlotQuery = `
+		SELECT id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+		FROM appointment_slots
+		WHERE id = $1
+	`
+	// pgLockSlotQuery makes concurrent bookings of a slot take turns
+	// counting its places, until the booking transaction ends
+	pgLockSlotQuery = `
+		SELECT 1
+		FROM appointment_slots
+		WHERE id = $1
+		FOR UPDATE
+	`
+	pgCreatePendingQuery = `
+		INSERT INTO appointments (id, slot_id, patient_id, status, created_at, updated_at, expires_at)
+		VALUES ($1, $2, $3, 'pending', now(), now(), $4)
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`
+	pgCreateIntentQuery = `
+		INSERT INTO booking_intents (id, slot_id, patient_id, lock_token, state, created_at)
+		VALUES ($1, $2, $3, $4, $5, now())
+	`
+	pgResolveIntentQuery = `
+		UPDATE booking_intents
+		SET state = $2,
+		    appointment_id = $3,
+		    resolved_at = now()
+		WHERE id = $1
+	`
+)
+
+var (
+	pgTakenPlacesQuery = takenPlacesQuery(func(n int) string { return fmt.Sprintf("$%d", n) })
+)
+
+// PgBookingStatements returns the SQL of the booking path's queries, for
+// db.ConnectPostgres to prepare on each new connection
+func PgBookingStatements() []string {
+	return []string{
+		pgGetPatientQuery,
+		pgGetClinicianQuery,
+		pgGetSlotQuery,
+		pgLockSlotQuery,
+		pgTakenPlacesQuery,
+		pgCreatePendingQuery,
+		pgCreateIntentQuery,
+		pgResolveIntentQuery,
+	}
+}
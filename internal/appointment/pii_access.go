@@ -0,0 +1,87 @@
+package appointment
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/requestid"
+)
+
+const (
+	// MaxPIIAccessRecords bounds the records ListPIIAccess returns
+	MaxPIIAccessRecords = 1000
+)
+
+var piiAccessRecorded = metrics.NewCounter(
+	"pii_access_recorded_total",
+	"Patient records returned to staff and admins, by actor kind.",
+	"kind",
+)
+
+// RecordPIIAccess records that endpoint returned the data of each of
+// patientIDs to actor, once per patient. Callers must not return the data
+// when it fails: it returns ErrAccessNotRecorded, which clients may retry.
+func (s *Service) RecordPIIAccess(ctx context.Context, actor Actor, endpoint string, patientIDs ...uuid.UUID) error {
+	var reason *string
+	if actor.Reason != "" {
+		reason = &actor.Reason
+	}
+
+	now := s.clock.Now()
+	reqID := requestid.Tag(requestid.From(ctx))
+	seen := make(map[uuid.UUID]bool, len(patientIDs))
+	records := make([]PIIAccess, 0, len(patientIDs))
+	for _, id := range patientIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		records = append(records, PIIAccess{
+			ID:         uuid.New(),
+			Actor:      actor.ID,
+			ActorKind:  actor.Kind,
+			Reason:     reason,
+			PatientID:  id,
+			Endpoint:   endpoint,
+			RequestID:  reqID,
+			AccessedAt: now,
+		})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := s.repo.InsertPIIAccess(ctx, records); err != nil {
+		log.Printf("failed to record access by %s %s to %d patients via %s: %v",
+			actor.Kind, actor.ID, len(records), endpoint, err)
+		return fmt.Errorf("%w: %v", ErrAccessNotRecorded, err)
+	}
+	piiAccessRecorded.Add(float64(len(records)), string(actor.Kind))
+	return nil
+}
+
+// RecordAppointmentPIIAccess records that endpoint returned the data of the
+// patient of the appointment to actor
+func (s *Service) RecordAppointmentPIIAccess(ctx context.Context, actor Actor, endpoint string, appointmentID uuid.UUID) error {
+	appt, err := s.repo.GetAppointmentByID(ctx, appointmentID)
+	if err != nil {
+		return fmt.Errorf("get appointment: %w", err)
+	}
+	return s.RecordPIIAccess(ctx, actor, endpoint, appt.PatientID)
+}
+
+// ListPIIAccess returns the access records of the shard ctx is routed to
+// matching filter, newest first. The limit is clamped to
+// MaxPIIAccessRecords.
+func (s *Service) ListPIIAccess(ctx context.Context, filter PIIAccessFilter) ([]PIIAccess, error) {
+	filter.Limit = min(max(filter.Limit, 1), MaxPIIAccessRecords)
+	records, err := s.repo.ListPIIAccess(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("list pii access: %w", err)
+	}
+	return records, nil
+}
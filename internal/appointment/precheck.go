@@ -0,0 +1,45 @@
+package appointment
+
+import (
+	"context"
+	"fmt"
+)
+
+// PrecheckBooking runs the checks Book would run on req and reports what it
+// would book, so a client can fail fast before asking the patient for
+// details. Nothing is locked, journalled or held, so a booking made right
+// after can still lose the slot to a concurrent one.
+func (s *Service) PrecheckBooking(ctx context.Context, req BookingRequest) (*BookingPrecheck, error) {
+	plan, err := s.planBooking(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &BookingPrecheck{
+		Slots:     make([]AppointmentSlot, len(plan.slots)),
+		Resources: plan.staff,
+		Remaining: plan.slots[0].Capacity,
+	}
+	now := s.clock.Now()
+	for i, slot := range plan.slots {
+		taken, err := s.repo.CountTakenPlaces(ctx, slot.ID, now)
+		if err != nil {
+			return nil, fmt.Errorf("count taken places: %w", err)
+		}
+		if taken >= slot.Capacity {
+			return nil, ErrSlotAlreadyBooked
+		}
+		res.Slots[i] = *slot
+		res.Remaining = min(res.Remaining, slot.Capacity-taken)
+	}
+
+	res.RequiresApproval, err = s.repo.SlotRequiresApproval(ctx, req.SlotID)
+	if err != nil {
+		return nil, fmt.Errorf("check approval: %w", err)
+	}
+	res.Conflicts, err = s.repo.ListPatientConflicts(ctx, req.PatientID, plan.start, plan.end)
+	if err != nil {
+		return nil, fmt.Errorf("find conflicts: %w", err)
+	}
+	return res, nil
+}
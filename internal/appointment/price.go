@@ -0,0 +1,62 @@
+package appointment
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Price is an amount of money in the minor unit of an ISO 4217 currency
+type Price struct {
+	AmountMinor int64
+	Currency    string
+}
+
+// currencyExponents lists currencies whose minor unit is not 1/100.
+// Anything not listed is assumed to have two decimal places.
+var currencyExponents = map[string]int{
+	"BHD": 3,
+	"CLP": 0,
+	"ISK": 0,
+	"JOD": 3,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+	"VND": 0,
+}
+
+// CurrencyExponent returns the number of decimal places used by a currency
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[strings.ToUpper(currency)]; ok {
+		return exp
+	}
+	return 2
+}
+
+// Decimal renders the amount in major units, e.g. 12550 USD -> "125.50"
+func (p Price) Decimal() string {
+	exp := CurrencyExponent(p.Currency)
+
+	amount := p.AmountMinor
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	if exp == 0 {
+		return fmt.Sprintf("%s%d", sign, amount)
+	}
+
+	div := int64(1)
+	for i := 0; i < exp; i++ {
+		div *= 10
+	}
+
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/div, exp, amount%div)
+}
+
+func (p Price) String() string {
+	return p.Decimal() + " " + p.Currency
+}
@@ -2,42 +2,337 @@ package appointment
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-var (
-	ErrPatientNotFound     = errors.New("patient not found")
-	ErrClinicianNotFound   = errors.New("clinician not found")
-	ErrSlotNotFound        = errors.New("slot not found")
-	ErrAppointmentNotFound = errors.New("appointment not found")
-)
-
 // Repository contains all DB interactions needed by the service.
+// Every backend must pass the suite in internal/appointment/conformance.
 type Repository interface {
+	// WithTx runs fn against a repository bound to a single transaction.
+	// The transaction commits when fn returns nil and rolls back otherwise.
+	WithTx(ctx context.Context, fn func(tx Repository) error) error
+
 	GetPatientByID(ctx context.Context, id uuid.UUID) (*Patient, error)
+	// CreatePatient inserts p; UpdatePatient sets the fields of u that are
+	// not nil. Both return the stored patient, and ErrPatientEmailTaken
+	// when another patient has the email.
+	CreatePatient(ctx context.Context, p Patient) (*Patient, error)
+	UpdatePatient(ctx context.Context, id uuid.UUID, u PatientUpdate) (*Patient, error)
+	// SearchPatients returns the page of q.Limit patients matching q
+	SearchPatients(ctx context.Context, q PatientSearch) (*PatientPage, error)
+	// DeactivatePatient records actor and reason on the patient and marks
+	// them deactivated at at; ReactivatePatient clears all three. Both
+	// return the updated patient.
+	DeactivatePatient(ctx context.Context, id uuid.UUID, actor, reason string, at time.Time) (*Patient, error)
+	ReactivatePatient(ctx context.Context, id uuid.UUID) (*Patient, error)
+
+	// Push devices. RegisterDevice stores d, or moves its token to
+	// d.PatientID when the token is registered already, and returns the
+	// stored device. ListAppointmentDevices returns the devices of the
+	// appointment's patient. DeleteDeviceByToken removes the token from
+	// whichever patient has it and is not an error when none does.
+	RegisterDevice(ctx context.Context, d Device) (*Device, error)
+	ListPatientDevices(ctx context.Context, patientID uuid.UUID) ([]Device, error)
+	ListAppointmentDevices(ctx context.Context, appointmentID uuid.UUID) ([]Device, error)
+	DeletePatientDevice(ctx context.Context, patientID, deviceID uuid.UUID) error
+	DeleteDeviceByToken(ctx context.Context, token string) error
+
+	// SMS replies. SetPatientPhone sets the patient's number, or clears it
+	// when phone is nil. ListPendingAppointmentsByPhone returns the
+	// appointments holding a slot until after now of the patients with the
+	// number, soonest slot first. InsertSMSReply records m unless its
+	// message ID is recorded already, and reports whether it did.
+	SetPatientPhone(ctx context.Context, id uuid.UUID, phone *string) (*Patient, error)
+	ListPendingAppointmentsByPhone(ctx context.Context, phone string, now time.Time) ([]Appointment, error)
+	GetSMSReply(ctx context.Context, messageID string) (*SMSReply, error)
+	InsertSMSReply(ctx context.Context, m SMSReply) (bool, error)
+	// GetNextAppointmentByPhone returns the active appointment of the
+	// patients with the number whose slot starts soonest after now, of
+	// clinic clinicID when it is set, or ErrAppointmentNotFound
+	GetNextAppointmentByPhone(ctx context.Context, phone string, clinicID *uuid.UUID, now time.Time) (*Appointment, error)
+
+	// API keys. GetAPIKeyByHash finds revoked keys too. RevokeAPIKey marks
+	// the key revoked at at, keeping the first revocation.
+	CreateAPIKey(ctx context.Context, k APIKey) (*APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID, at time.Time) (*APIKey, error)
 	GetClinicianByID(ctx context.Context, id uuid.UUID) (*Clinician, error)
+	// CreateClinician inserts c and returns it as stored, or
+	// ErrClinicNotFound when c.ClinicID names no clinic. UpdateClinician
+	// sets the fields of u that are not nil; DeactivateClinician marks the
+	// clinician deactivated at at. Both return the updated clinician.
+	CreateClinician(ctx context.Context, c Clinician) (*Clinician, error)
+	UpdateClinician(ctx context.Context, id uuid.UUID, u ClinicianUpdate) (*Clinician, error)
+	DeactivateClinician(ctx context.Context, id uuid.UUID, at time.Time) (*Clinician, error)
 
 	GetSlotByID(ctx context.Context, id uuid.UUID) (*AppointmentSlot, error)
+	// LockSlot reads a slot as GetSlotByID does. In a transaction on
+	// Postgres it locks the slot row until the transaction ends, as
+	// CountTakenPlaces does, so bookings of the slot wait for it.
+	LockSlot(ctx context.Context, id uuid.UUID) (*AppointmentSlot, error)
+
+	// GetAvailabilityVersion returns the clinician's availability version,
+	// which the schema bumps on every change to their slots or the status of
+	// appointments in them. It is 0 before the first change.
+	GetAvailabilityVersion(ctx context.Context, clinicianID uuid.UUID) (int64, error)
+	// CountSlotsByDay counts the clinician's slots in each of days with one
+	// grouped query and returns the counts in the order of days. Only slots
+	// starting within bookable count as open. Holds live at now take places
+	// as confirmed appointments do.
+	CountSlotsByDay(ctx context.Context, clinicianID uuid.UUID, days []DayRange, bookable DayRange, now time.Time) ([]DayAvailability, error)
 
-	// For conflict checks
-	GetConfirmedAppointmentForSlot(ctx context.Context, slotID uuid.UUID) (*Appointment, error)
+	// Pricing
+	GetSlotQuote(ctx context.Context, slotID uuid.UUID) (*SlotQuote, error)
+
+	// SlotRequiresApproval reports whether the clinic of the slot's clinician
+	// triages bookings. A clinician without a clinic never does.
+	SlotRequiresApproval(ctx context.Context, slotID uuid.UUID) (bool, error)
+
+	// Booking windows. GetSlotBookingWindow returns the window of the
+	// specialty of the slot's clinician, or nil when it has none.
+	GetSlotBookingWindow(ctx context.Context, slotID uuid.UUID) (*BookingWindow, error)
+	GetBookingWindow(ctx context.Context, specialty string) (*BookingWindow, error)
+	ListBookingWindows(ctx context.Context) ([]BookingWindow, error)
+	PutBookingWindow(ctx context.Context, w BookingWindow) (*BookingWindow, error)
+	DeleteBookingWindow(ctx context.Context, specialty string) error
+
+	// HasBlockingAppointment reports which kinds of active appointment span
+	// the slot, as its first slot or a later one. It and
+	// CountConfirmedAppointmentsForSlot are not on the booking path, which
+	// counts places with CountTakenPlaces under the slot's row lock.
+	HasBlockingAppointment(ctx context.Context, slotID uuid.UUID) (SlotBlockers, error)
+	// CountConfirmedAppointmentsForSlot counts the confirmed appointments
+	// spanning the slot, whether it is their first slot or a later one
+	CountConfirmedAppointmentsForSlot(ctx context.Context, slotID uuid.UUID) (int, error)
+	// CountTakenPlaces counts the confirmed appointments and the holds
+	// still live at now spanning the slot. In a transaction on Postgres it
+	// locks the slot row first, so concurrent bookings of the slot count
+	// one after another.
+	CountTakenPlaces(ctx context.Context, slotID uuid.UUID, now time.Time) (int, error)
+	// ListPatientConflicts returns the patient's active appointments that
+	// span any time in [start, end), oldest first
+	ListPatientConflicts(ctx context.Context, patientID uuid.UUID, start, end time.Time) ([]Appointment, error)
 	GetAppointmentByID(ctx context.Context, id uuid.UUID) (*Appointment, error)
 
 	// Creation and updates
 	CreatePendingAppointment(ctx context.Context, slotID, patientID uuid.UUID, expiresAt time.Time) (*Appointment, error)
 	UpdateAppointmentStatus(ctx context.Context, id uuid.UUID, from, to AppointmentStatus) (*Appointment, error)
+	// ResolvePendingAppointment moves a pending appointment to confirmed if
+	// its hold has not passed at now, or to expired if it has; the deadline
+	// is checked by the same statement that changes the status. It returns
+	// ErrAppointmentNotFound when the appointment is not pending or on the
+	// other side of its deadline.
+	ResolvePendingAppointment(ctx context.Context, id uuid.UUID, to AppointmentStatus, now time.Time) (*Appointment, error)
+	// RequestApproval moves a pending appointment whose hold has not passed
+	// at now to pending_approval, with deadline as its new expires_at. It
+	// returns ErrAppointmentNotFound when the appointment is not pending or
+	// its hold has passed.
+	RequestApproval(ctx context.Context, id uuid.UUID, now, deadline time.Time) (*Appointment, error)
+	// ResolveApproval moves an appointment awaiting approval to confirmed if
+	// its deadline has not passed at now, or to rejected whatever the
+	// deadline. It returns ErrAppointmentNotFound when the appointment is not
+	// awaiting approval or is past its deadline.
+	ResolveApproval(ctx context.Context, id uuid.UUID, to AppointmentStatus, now time.Time) (*Appointment, error)
+	// CheckInAppointment moves a confirmed appointment to checked_in,
+	// recording at as its check-in time. It returns ErrAppointmentNotFound
+	// when the appointment is not confirmed.
+	CheckInAppointment(ctx context.Context, id uuid.UUID, at time.Time) (*Appointment, error)
+	// TransferHold moves a pending appointment from slot from to slot to,
+	// keeping its ID and expires_at. It returns ErrAppointmentNotFound when
+	// the appointment is not pending in from or its hold has passed at now.
+	TransferHold(ctx context.Context, id, from, to uuid.UUID, now time.Time) (*Appointment, error)
 
 	// Expiry worker
 	FindExpiredPending(ctx context.Context, now time.Time) ([]Appointment, error)
+	// FindOverdueApprovals returns appointments awaiting approval past their
+	// deadline at now
+	FindOverdueApprovals(ctx context.Context, now time.Time) ([]Appointment, error)
+	// CountOverduePending returns, for each of cutoffs, how many pending
+	// appointments have a hold that passed before it, and the earliest such
+	// deadline before cutoffs[0], nil when there is none
+	CountOverduePending(ctx context.Context, cutoffs []time.Time) (counts []int, oldest *time.Time, err error)
 
 	// Event logging
 	InsertEvent(ctx context.Context, ev EventLog) error
+	// ListSyncEvents returns the events after sequence number after and
+	// created before before of appointments in scope, in sequence order.
+	// EventSequenceBounds returns the lowest sequence number of any event
+	// and the highest of those created before before, 0 when there is none.
+	ListSyncEvents(ctx context.Context, scope SyncScope, after int64, before time.Time, limit int) ([]SyncEvent, error)
+	EventSequenceBounds(ctx context.Context, before time.Time) (oldest, latest int64, err error)
+	// ListExpiryEventMismatches returns appointments with an EXPIRED event
+	// logged at or after since that are not expired or have more than one
+	// such event, most recently updated first
+	ListExpiryEventMismatches(ctx context.Context, since time.Time, limit int) ([]ExpiryEventMismatch, error)
+	// ListOrphanedAppointments returns appointments pending with a hold that
+	// passed before staleBefore, active on a deleted slot or confirmed on a
+	// blocked slot, most recently updated first
+	ListOrphanedAppointments(ctx context.Context, staleBefore time.Time, limit int) ([]OrphanedAppointment, error)
+	// ListPatientTimeline returns the events of the patient's appointments,
+	// newest first
+	ListPatientTimeline(ctx context.Context, patientID uuid.UUID, limit int) ([]TimelineEntry, error)
+
+	// Slot reassignment. ListClinicianSlotsOverlapping returns the
+	// clinician's slots that are not deleted and overlap [start, end),
+	// earliest first. ListActiveSlotAppointments returns the active
+	// appointments spanning the slot, as their first slot or a later one.
+	// ReassignSlot moves the slot from one clinician to another, and fails
+	// with ErrSlotNotFound when it does not belong to from, or ErrSlotOverlap
+	// when it overlaps a slot of to.
+	ListClinicianSlotsOverlapping(ctx context.Context, clinicianID uuid.UUID, start, end time.Time) ([]AppointmentSlot, error)
+	ListActiveSlotAppointments(ctx context.Context, slotID uuid.UUID) ([]Appointment, error)
+	ReassignSlot(ctx context.Context, slotID, from, to uuid.UUID) (*AppointmentSlot, error)
+
+	// Slot splits and merges. UpdateSlot writes the times, status, capacity
+	// and type of the slot with s.ID and returns it as stored, or
+	// ErrSlotOverlap when it would overlap another slot of its clinician.
+	UpdateSlot(ctx context.Context, s AppointmentSlot) (*AppointmentSlot, error)
+
+	// Series. ListClinicianSlotsAt returns the clinician's slots starting at
+	// any of starts, earliest first.
+	ListClinicianSlotsAt(ctx context.Context, clinicianID uuid.UUID, starts []time.Time) ([]AppointmentSlot, error)
+	CreateSeries(ctx context.Context, s Series) (*Series, error)
+	AddSeriesOccurrence(ctx context.Context, seriesID uuid.UUID, number int, appointmentID uuid.UUID) error
+	// MoveSeriesOccurrence points an occurrence at appointmentID and marks
+	// it an exception when exception is set. A mark is never cleared.
+	MoveSeriesOccurrence(ctx context.Context, seriesID uuid.UUID, number int, appointmentID uuid.UUID, exception bool) error
+	// MoveAppointmentOccurrence points the occurrence of from, if it is
+	// one, at to and marks it an exception. It reports whether from was.
+	MoveAppointmentOccurrence(ctx context.Context, from, to uuid.UUID) (bool, error)
+	// GetSeries returns the series with its occurrences in order
+	GetSeries(ctx context.Context, id uuid.UUID) (*Series, error)
+
+	// Multi-slot appointments. AddExtraSlots records slotIDs as the second
+	// and later slots of the appointment, in order. ListAppointmentSlots
+	// returns every slot the appointment spans, earliest first.
+	AddExtraSlots(ctx context.Context, appointmentID uuid.UUID, slotIDs []uuid.UUID) error
+	ListAppointmentSlots(ctx context.Context, appointmentID uuid.UUID) ([]AppointmentSlot, error)
+
+	// Staff resources. ListAvailableResources returns the clinic's staff
+	// meeting req with no reservation overlapping [start, end) held by an
+	// active appointment, by name. ListAppointmentResources returns the
+	// staff reserved for an appointment, by kind.
+	ListAvailableResources(ctx context.Context, clinicID uuid.UUID, req ResourceRequirement, start, end time.Time) ([]StaffResource, error)
+	ReserveResource(ctx context.Context, appointmentID, resourceID uuid.UUID, start, end time.Time) error
+	ListAppointmentResources(ctx context.Context, appointmentID uuid.UUID) ([]StaffResource, error)
+
+	// Attachments. The rows describe content kept in blob storage.
+	// ListAttachments returns the appointment's attachments, oldest first.
+	InsertAttachment(ctx context.Context, a Attachment) (*Attachment, error)
+	GetAttachment(ctx context.Context, id uuid.UUID) (*Attachment, error)
+	ListAttachments(ctx context.Context, appointmentID uuid.UUID) ([]Attachment, error)
+
+	// Reasons and notes. SetAppointmentReason records why the patient is
+	// coming. ListAppointmentNotes returns the appointment's notes, oldest
+	// first.
+	SetAppointmentReason(ctx context.Context, id uuid.UUID, reason string) error
+	InsertAppointmentNote(ctx context.Context, n AppointmentNote) (*AppointmentNote, error)
+	ListAppointmentNotes(ctx context.Context, appointmentID uuid.UUID) ([]AppointmentNote, error)
+
+	// Availability templates, by clinician. CreateSlots inserts the slots
+	// as given and returns them as stored, or ErrSlotOverlap when one
+	// overlaps another slot of its clinician that is not deleted.
+	GetAvailabilityTemplate(ctx context.Context, clinicianID uuid.UUID) (*AvailabilityTemplate, error)
+	PutAvailabilityTemplate(ctx context.Context, t AvailabilityTemplate) (*AvailabilityTemplate, error)
+	DeleteAvailabilityTemplate(ctx context.Context, clinicianID uuid.UUID) error
+	CreateSlots(ctx context.Context, slots []AppointmentSlot) ([]AppointmentSlot, error)
+
+	// Intake forms. PutIntakeTemplate bumps the version of a template it
+	// replaces. GetIntakeResponse returns nil when nothing was answered yet.
+	GetIntakeTemplate(ctx context.Context, slotType string) (*IntakeTemplate, error)
+	ListIntakeTemplates(ctx context.Context) ([]IntakeTemplate, error)
+	PutIntakeTemplate(ctx context.Context, t IntakeTemplate) (*IntakeTemplate, error)
+	DeleteIntakeTemplate(ctx context.Context, slotType string) error
+	GetIntakeResponse(ctx context.Context, appointmentID uuid.UUID) (*IntakeResponse, error)
+	PutIntakeResponse(ctx context.Context, r IntakeResponse) (*IntakeResponse, error)
+	// ListIntakeReminders returns confirmed appointments in slots starting
+	// in [from, to) whose slot type has a template and whose form is not
+	// complete, with no eventType event logged yet, earliest first
+	ListIntakeReminders(ctx context.Context, from, to time.Time, eventType string, limit int) ([]IntakeReminder, error)
+
+	// Feedback. InsertFeedback returns ErrFeedbackAlreadyGiven when the
+	// appointment has feedback. ListClinicianRatings aggregates the feedback
+	// given since then by clinician, for clinicians with at least minCount
+	// ratings, best rated first. ListClinicianFeedback returns the newest.
+	InsertFeedback(ctx context.Context, f Feedback) (*Feedback, error)
+	GetFeedback(ctx context.Context, appointmentID uuid.UUID) (*Feedback, error)
+	ListClinicianRatings(ctx context.Context, since time.Time, minCount int) ([]ClinicianRating, error)
+	ListClinicianFeedback(ctx context.Context, clinicianID uuid.UUID, limit int) ([]Feedback, error)
+	// ListFeedbackRequests returns confirmed appointments in slots ending
+	// in [from, to) without feedback and with no eventType event logged yet,
+	// earliest first
+	ListFeedbackRequests(ctx context.Context, from, to time.Time, eventType string, limit int) ([]FeedbackRequest, error)
+
+	// Data retention. GetRetentionPolicy returns ErrRetentionPolicyNotFound
+	// when none is set. CountExpiredRecords counts the records of entity past
+	// before: appointments whose every slot ended before it, events and
+	// feedback created before it. ListExpiredAppointments returns such
+	// appointments, oldest first. DeleteAppointments deletes the appointments
+	// with every row that refers to them and returns how many it deleted;
+	// run it in WithTx. DeleteEventsBefore and DeleteFeedbackBefore delete up
+	// to limit of the oldest records created before then.
+	GetRetentionPolicy(ctx context.Context) (*RetentionPolicy, error)
+	PutRetentionPolicy(ctx context.Context, p RetentionPolicy) (*RetentionPolicy, error)
+	DeleteRetentionPolicy(ctx context.Context) error
+	CountExpiredRecords(ctx context.Context, entity RetentionEntity, before time.Time) (int, error)
+	ListExpiredAppointments(ctx context.Context, before time.Time, limit int) ([]uuid.UUID, error)
+	ListAttachmentBlobKeys(ctx context.Context, appointmentIDs []uuid.UUID) ([]string, error)
+	DeleteAppointments(ctx context.Context, ids []uuid.UUID) (int, error)
+	DeleteEventsBefore(ctx context.Context, before time.Time, limit int) (int, error)
+	DeleteFeedbackBefore(ctx context.Context, before time.Time, limit int) (int, error)
+	InsertRetentionRun(ctx context.Context, run RetentionRun) error
+	// ListRetentionRuns returns the newest runs first
+	ListRetentionRuns(ctx context.Context, limit int) ([]RetentionRun, error)
+
+	// Slot discovery and public booking. SearchSlots returns the slots
+	// matching q, soonest first, fetching q.Limit rows, with holds live at
+	// now taking places. UpsertPatientByEmail returns the patient with
+	// p.Email, creating it from p when there is none.
+	SearchSlots(ctx context.Context, q SlotQuery, now time.Time) ([]OpenSlot, error)
+	UpsertPatientByEmail(ctx context.Context, p Patient) (*Patient, error)
+
+	// PII access audit. ListPIIAccess returns the newest records first.
+	InsertPIIAccess(ctx context.Context, records []PIIAccess) error
+	ListPIIAccess(ctx context.Context, filter PIIAccessFilter) ([]PIIAccess, error)
+
+	// Booking journal
+	CreateBookingIntent(ctx context.Context, intent BookingIntent) error
+	ResolveBookingIntent(ctx context.Context, id uuid.UUID, state BookingIntentState, appointmentID *uuid.UUID) error
+	ListPendingBookingIntents(ctx context.Context, createdBefore time.Time) ([]BookingIntent, error)
+
+	// Read operations with joins. fields selects the related entities to
+	// hydrate; only their joins are performed.
+	GetAppointmentDetail(ctx context.Context, id uuid.UUID, fields DetailFields) (*AppointmentDetail, error)
+	// GetAppointmentDetails reads the appointments with the given ids in one
+	// query. Unknown ids are left out and rows come back in no particular
+	// order.
+	GetAppointmentDetails(ctx context.Context, ids []uuid.UUID, fields DetailFields) ([]AppointmentDetail, error)
+	ListAppointmentsByPatient(ctx context.Context, patientID uuid.UUID, page PageRequest, fields DetailFields) (*AppointmentPage, error)
+	ListAppointmentsBySlot(ctx context.Context, slotID uuid.UUID, sort ListSort, fields DetailFields) ([]AppointmentDetail, error)
+	// SearchAppointments returns the page of q.Limit appointments matching q
+	SearchAppointments(ctx context.Context, q AppointmentSearch, fields DetailFields) (*AppointmentPage, error)
+	// StreamAppointmentsByClinic calls fn for each appointment in a slot of
+	// the clinic starting in [from, to), oldest booking first, as rows are
+	// scanned. It stops at the first error from fn and returns it. fn must
+	// not use the repository; the rows are still open.
+	StreamAppointmentsByClinic(ctx context.Context, clinicID uuid.UUID, from, to time.Time, fields DetailFields, fn func(*AppointmentDetail) error) error
+	// ListClinicianAppointments returns the appointments whose first slot
+	// is the clinician's and starts in [from, to), leaving out cancelled,
+	// expired and rejected ones, by slot start time
+	ListClinicianAppointments(ctx context.Context, clinicianID uuid.UUID, from, to time.Time, fields DetailFields) ([]AppointmentDetail, error)
+}
 
-	// Read operations with joins
-	GetAppointmentDetail(ctx context.Context, id uuid.UUID) (*AppointmentDetail, error)
-	ListAppointmentsByPatient(ctx context.Context, patientID uuid.UUID, limit, offset int) ([]AppointmentDetail, error)
-	ListAppointmentsBySlot(ctx context.Context, slotID uuid.UUID) ([]AppointmentDetail, error)
+// Seeder creates reference data. The booking path never uses it; the demo
+// mode, the conformance suite and cmd/bench use it to set up data on any
+// backend.
+type Seeder interface {
+	InsertClinic(ctx context.Context, c Clinic) error
+	InsertClinician(ctx context.Context, c Clinician) error
+	InsertPatient(ctx context.Context, p Patient) error
+	InsertSlot(ctx context.Context, s AppointmentSlot) error
+	InsertStaffResource(ctx context.Context, r StaffResource) error
+	SetSlotTypePrice(ctx context.Context, clinicID uuid.UUID, slotType string, price Price) error
 }
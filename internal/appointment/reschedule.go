@@ -0,0 +1,182 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// EventAppointmentRescheduled is logged on both appointments of a
+// reschedule: the one moved from, which is cancelled, with rescheduled_to,
+// and its replacement with rescheduled_from
+const EventAppointmentRescheduled = "APPOINTMENT_RESCHEDULED"
+
+// Rescheduling is the outcome of RescheduleAppointment: the appointment as
+// cancelled and the one that replaced it in the new slot
+type Rescheduling struct {
+	Previous    *Appointment
+	Appointment *Appointment
+}
+
+// RescheduleAppointment moves an active appointment to another open slot of
+// the same clinic. The replacement is booked and the old appointment
+// cancelled in one transaction under the locks of both slots, so the
+// patient holds one booking throughout and a failure leaves the old one as
+// it was. The replacement takes over the old appointment's status, as
+// series reschedules do: a confirmed appointment is confirmed again,
+// subject to the new slot's capacity, and a hold or a booking awaiting
+// approval keeps its deadline. A series occurrence follows its appointment
+// and becomes an exception. Appointments spanning several slots or
+// reserving staff are not moved.
+func (s *Service) RescheduleAppointment(ctx context.Context, id, slotID uuid.UUID) (*Rescheduling, error) {
+	old, err := s.repo.GetAppointmentByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrAppointmentNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load appointment: %w", err)
+	}
+	if !old.Status.Active() {
+		return nil, ErrAppointmentNotActive
+	}
+	if old.SlotID == slotID {
+		return nil, fmt.Errorf("%w: the appointment is already in the slot", ErrInvalidReschedule)
+	}
+
+	err = s.runStage(ctx, StagePatientLookup, func(ctx context.Context) error {
+		return s.checkBookablePatient(ctx, old.PatientID)
+	})
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) || errors.Is(err, ErrPatientDeactivated) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load patient: %w", err)
+	}
+
+	from, to, err := s.rescheduleSlots(ctx, old, slotID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	var moved, previous *Appointment
+	err = s.runStage(ctx, StageLockSection, func(ctx context.Context) error {
+		return s.withSlotLocks(ctx, []*AppointmentSlot{from, to}, func(ctx context.Context) error {
+			return s.repo.WithTx(ctx, func(tx Repository) error {
+				if err := s.checkSlotRoom(ctx, tx, to); err != nil {
+					return err
+				}
+				appt, cancelled, err := s.moveAppointment(ctx, tx, *old, to.ID, now)
+				if err != nil {
+					return err
+				}
+				inSeries, err := tx.MoveAppointmentOccurrence(ctx, old.ID, appt.ID)
+				if err != nil {
+					return err
+				}
+				if inSeries && to.PractitionerID != from.PractitionerID {
+					return fmt.Errorf("%w: slot belongs to another clinician", ErrSeriesSlotUnavailable)
+				}
+				moved, previous = appt, cancelled
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		if mapped := lockError(err); mapped != err {
+			return nil, mapped
+		}
+		return nil, fmt.Errorf("reschedule appointment: %w", err)
+	}
+
+	s.logEvent(ctx, old.ID, EventAppointmentRescheduled, map[string]any{
+		"previous_status":  string(old.Status),
+		"previous_slot_id": from.ID.String(),
+		"slot_id":          to.ID.String(),
+		"rescheduled_to":   moved.ID.String(),
+	})
+	s.logEvent(ctx, moved.ID, EventAppointmentRescheduled, map[string]any{
+		"status":           string(moved.Status),
+		"patient_id":       moved.PatientID.String(),
+		"previous_slot_id": from.ID.String(),
+		"slot_id":          to.ID.String(),
+		"start_time":       to.StartTime,
+		"rescheduled_from": old.ID.String(),
+	})
+	return &Rescheduling{Previous: previous, Appointment: moved}, nil
+}
+
+// rescheduleSlots loads the slot appt is in and the slot it is to move to
+// and runs the checks that need no lock: neither has started, appt is in
+// its slot alone and reserves no staff, and the new slot is open, within
+// the booking window and in the same clinic
+func (s *Service) rescheduleSlots(ctx context.Context, appt *Appointment, slotID uuid.UUID) (from, to *AppointmentSlot, err error) {
+	now := s.clock.Now()
+
+	err = s.runStage(ctx, StageSlotLookup, func(ctx context.Context) error {
+		if from, err = s.repo.GetSlotByID(ctx, appt.SlotID); err != nil {
+			return err
+		}
+		to, err = s.repo.GetSlotByID(ctx, slotID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("load slot: %w", err)
+	}
+	if !from.StartTime.After(now) {
+		return nil, nil, fmt.Errorf("%w: the appointment has already started", ErrInvalidReschedule)
+	}
+
+	spanned, err := s.repo.ListAppointmentSlots(ctx, appt.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list appointment slots: %w", err)
+	}
+	if len(spanned) > 1 {
+		return nil, nil, fmt.Errorf("%w: it spans %d slots", ErrRescheduleUnsupported, len(spanned))
+	}
+	staff, err := s.repo.ListAppointmentResources(ctx, appt.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list appointment resources: %w", err)
+	}
+	if len(staff) > 0 {
+		return nil, nil, fmt.Errorf("%w: it reserves staff", ErrRescheduleUnsupported)
+	}
+
+	if to.Status != SlotOpen {
+		return nil, nil, ErrSlotNotOpen
+	}
+	if !to.StartTime.After(now) {
+		return nil, nil, fmt.Errorf("%w: the slot has already started", ErrInvalidReschedule)
+	}
+	if err := s.checkBookableSlots(ctx, to); err != nil {
+		return nil, nil, err
+	}
+
+	// Approval is a clinic's rule, so an appointment only keeps its status
+	// in a slot of the clinic that gave it
+	if to.PractitionerID != from.PractitionerID {
+		current, err := s.repo.GetClinicianByID(ctx, from.PractitionerID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load clinician: %w", err)
+		}
+		target, err := s.repo.GetClinicianByID(ctx, to.PractitionerID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load clinician: %w", err)
+		}
+		if !sameClinic(current, target) {
+			return nil, nil, fmt.Errorf("%w: the slot belongs to another clinic", ErrInvalidReschedule)
+		}
+	}
+	return from, to, nil
+}
+
+// sameClinic reports whether a and b belong to the same clinic, or both to
+// none
+func sameClinic(a, b *Clinician) bool {
+	if a.ClinicID == nil || b.ClinicID == nil {
+		return a.ClinicID == nil && b.ClinicID == nil
+	}
+	return *a.ClinicID == *b.ClinicID
+}
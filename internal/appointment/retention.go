@@ -0,0 +1,288 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+)
+
+const (
+	// maxRetentionMonths bounds a retention period at a hundred years
+	maxRetentionMonths = 1200
+	// defaultRetentionBatch is used when cfg.RetentionBatchSize is not set
+	defaultRetentionBatch = 500
+
+	// MaxRetentionRuns bounds the runs ListRetentionRuns returns
+	MaxRetentionRuns = 1000
+)
+
+var retentionDeleted = metrics.NewCounter(
+	"retention_records_deleted_total",
+	"Records deleted by the retention worker.",
+	"entity",
+)
+
+// ShardTenancy tells which tenants keep their records on a shard. Records
+// carry no tenant, so a retention policy deletes those of every tenant on
+// the shard it is set on.
+type ShardTenancy interface {
+	// Dedicated reports whether the shard ctx is routed to holds the
+	// records of tenant and no other
+	Dedicated(ctx context.Context, tenant string) bool
+}
+
+// WithShardTenancy limits retention policies to tenants with a shard of
+// their own. Without it the repository is taken to hold one tenant's
+// records, as in the demo mode.
+func WithShardTenancy(t ShardTenancy) Option {
+	return func(s *Service) { s.tenancy = t }
+}
+
+// dedicated reports whether tenant is the only tenant on the shard of ctx
+func (s *Service) dedicated(ctx context.Context, tenant string) bool {
+	return s.tenancy == nil || s.tenancy.Dedicated(ctx, tenant)
+}
+
+type retentionPeriod struct {
+	entity RetentionEntity
+	months *int
+}
+
+// periods lists the policy's period of each entity, appointments first so
+// their events and feedback go with them
+func (p *RetentionPolicy) periods() []retentionPeriod {
+	return []retentionPeriod{
+		{RetainAppointments, p.AppointmentMonths},
+		{RetainEvents, p.EventMonths},
+		{RetainFeedback, p.FeedbackMonths},
+	}
+}
+
+// GetRetentionPolicy returns the retention policy of the shard ctx is
+// routed to
+func (s *Service) GetRetentionPolicy(ctx context.Context) (*RetentionPolicy, error) {
+	p, err := s.repo.GetRetentionPolicy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get retention policy: %w", err)
+	}
+	return p, nil
+}
+
+// PutRetentionPolicy sets the retention policy of the shard ctx is routed
+// to, owned by p.TenantID. Periods are whole months from 1 to 1200; a nil
+// period keeps those records forever. It returns ErrRetentionPolicyShared
+// when other tenants keep their records on the shard too, and
+// ErrRetentionPolicyOwned when another tenant set the policy of the shard.
+func (s *Service) PutRetentionPolicy(ctx context.Context, p RetentionPolicy) (*RetentionPolicy, error) {
+	if !s.dedicated(ctx, p.TenantID) {
+		return nil, fmt.Errorf("put retention policy: %w", ErrRetentionPolicyShared)
+	}
+	set := false
+	for _, period := range p.periods() {
+		if period.months == nil {
+			continue
+		}
+		if *period.months < 1 || *period.months > maxRetentionMonths {
+			return nil, fmt.Errorf("%w: %s must be kept from 1 to %d months", ErrInvalidRetentionPolicy, period.entity, maxRetentionMonths)
+		}
+		set = true
+	}
+	if !set {
+		return nil, fmt.Errorf("%w: no retention period is set, delete the policy to keep everything", ErrInvalidRetentionPolicy)
+	}
+
+	var saved *RetentionPolicy
+	err := s.repo.WithTx(ctx, func(tx Repository) error {
+		if err := s.checkRetentionOwner(ctx, tx, p.TenantID); err != nil && !errors.Is(err, ErrRetentionPolicyNotFound) {
+			return err
+		}
+		var err error
+		saved, err = tx.PutRetentionPolicy(ctx, p)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("put retention policy: %w", err)
+	}
+	return saved, nil
+}
+
+// DeleteRetentionPolicy removes the retention policy of the shard ctx is
+// routed to, so its records are kept forever
+func (s *Service) DeleteRetentionPolicy(ctx context.Context, tenantID string) error {
+	err := s.repo.WithTx(ctx, func(tx Repository) error {
+		if err := s.checkRetentionOwner(ctx, tx, tenantID); err != nil {
+			return err
+		}
+		return tx.DeleteRetentionPolicy(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("delete retention policy: %w", err)
+	}
+	return nil
+}
+
+// checkRetentionOwner returns ErrRetentionPolicyOwned when the shard's
+// policy was set by a tenant other than tenantID that still has the shard
+// to itself. A policy left by a tenant that has since moved, or been
+// joined by others, is not enforced and can be replaced.
+func (s *Service) checkRetentionOwner(ctx context.Context, repo Repository, tenantID string) error {
+	current, err := repo.GetRetentionPolicy(ctx)
+	if err != nil {
+		return err
+	}
+	if current.TenantID != tenantID && s.dedicated(ctx, current.TenantID) {
+		return ErrRetentionPolicyOwned
+	}
+	return nil
+}
+
+// ListRetentionRuns returns the newest retention runs of the shard ctx is
+// routed to, up to limit
+func (s *Service) ListRetentionRuns(ctx context.Context, limit int) ([]RetentionRun, error) {
+	runs, err := s.repo.ListRetentionRuns(ctx, min(max(limit, 1), MaxRetentionRuns))
+	if err != nil {
+		return nil, fmt.Errorf("list retention runs: %w", err)
+	}
+	return runs, nil
+}
+
+// ApplyRetention counts the records of every entity the shard's policy
+// keeps for a limited time that are past their period and, unless dryRun
+// is set, deletes them. Expired appointments go with everything recorded
+// for them, attachment content included. Each entity's run is recorded in
+// the audit trail and returned; there are none without a policy. A policy
+// whose tenant no longer has the shard to itself deletes nothing and
+// returns ErrRetentionPolicyShared. Once stop is closed the rest is left
+// for the next run. stop may be nil.
+func (s *Service) ApplyRetention(ctx context.Context, stop <-chan struct{}, dryRun bool) ([]RetentionRun, error) {
+	policy, err := s.repo.GetRetentionPolicy(ctx)
+	if errors.Is(err, ErrRetentionPolicyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get retention policy: %w", err)
+	}
+	if !s.dedicated(ctx, policy.TenantID) {
+		return nil, fmt.Errorf("policy of tenant %q: %w", policy.TenantID, ErrRetentionPolicyShared)
+	}
+
+	var runs []RetentionRun
+	var errs []error
+	for _, period := range policy.periods() {
+		if period.months == nil {
+			continue
+		}
+		select {
+		case <-stop:
+			return runs, errors.Join(errs...)
+		default:
+		}
+
+		now := s.clock.Now()
+		run := RetentionRun{
+			ID:           uuid.New(),
+			Entity:       period.entity,
+			TenantID:     policy.TenantID,
+			RetainMonths: *period.months,
+			Cutoff:       now.AddDate(0, -*period.months, 0),
+			DryRun:       dryRun,
+			StartedAt:    now,
+		}
+		run.Matched, err = s.repo.CountExpiredRecords(ctx, run.Entity, run.Cutoff)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !dryRun && run.Matched > 0 {
+			run.Deleted, err = s.deleteExpired(ctx, stop, run.Entity, run.Cutoff)
+			retentionDeleted.Add(float64(run.Deleted), string(run.Entity))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("delete expired %s: %w", run.Entity, err))
+			}
+		}
+		run.FinishedAt = s.clock.Now()
+
+		// The audit record outlives a cancelled context, so what was
+		// deleted is always accounted for
+		if err := s.repo.InsertRetentionRun(context.WithoutCancel(ctx), run); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, errors.Join(errs...)
+}
+
+// deleteExpired deletes the records of entity past cutoff in batches of
+// cfg.RetentionBatchSize, each in its own transaction, and returns how many
+// it deleted
+func (s *Service) deleteExpired(ctx context.Context, stop <-chan struct{}, entity RetentionEntity, cutoff time.Time) (int, error) {
+	batch := s.cfg.RetentionBatchSize
+	if batch <= 0 {
+		batch = defaultRetentionBatch
+	}
+
+	deleted := 0
+	for {
+		select {
+		case <-stop:
+			return deleted, nil
+		default:
+		}
+
+		var n int
+		var err error
+		switch entity {
+		case RetainAppointments:
+			n, err = s.deleteExpiredAppointments(ctx, cutoff, batch)
+		case RetainEvents:
+			n, err = s.repo.DeleteEventsBefore(ctx, cutoff, batch)
+		case RetainFeedback:
+			n, err = s.repo.DeleteFeedbackBefore(ctx, cutoff, batch)
+		}
+		deleted += n
+		if err != nil || n < batch {
+			return deleted, err
+		}
+	}
+}
+
+// deleteExpiredAppointments deletes up to limit appointments past cutoff.
+// Attachment content is deleted first: a failure after it leaves rows
+// pointing at missing content, which the next run deletes, rather than
+// content nothing points at.
+func (s *Service) deleteExpiredAppointments(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	ids, err := s.repo.ListExpiredAppointments(ctx, cutoff, limit)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	keys, err := s.repo.ListAttachmentBlobKeys(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) > 0 && s.blobs == nil {
+		return 0, fmt.Errorf("%w: expired appointments have attachments to delete", ErrAttachmentsDisabled)
+	}
+	for _, key := range keys {
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			return 0, fmt.Errorf("delete attachment content %s: %w", key, err)
+		}
+	}
+
+	var deleted int
+	err = s.repo.WithTx(ctx, func(tx Repository) error {
+		var err error
+		deleted, err = tx.DeleteAppointments(ctx, ids)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
@@ -0,0 +1,1551 @@
+package appointment
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// rowScanner is satisfied by pgx.Row, pgx.Rows, *sql.Row and *sql.Rows so the
+// scan helpers can be shared by every Repository backend.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func isNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows) || errors.Is(err, sql.ErrNoRows)
+}
+
+// slotCapacityConstraint bounds appointment_slots.confirmed_count by capacity
+const slotCapacityConstraint = "chk_slot_capacity"
+
+// isCapacityViolation reports whether err is a confirm that would take a
+// slot past its capacity
+func isCapacityViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName == slotCapacityConstraint
+	}
+	// SQLite only names the constraint in the message
+	return strings.Contains(err.Error(), "CHECK constraint failed: "+slotCapacityConstraint)
+}
+
+const (
+	// slotOverlapConstraint keeps a clinician's slots, other than deleted
+	// ones, from overlapping
+	slotOverlapConstraint = "appointment_slots_no_overlap"
+	// slotTimeIndex keeps two slots of a clinician, deleted ones included,
+	// from having the same times
+	slotTimeIndex = "uniq_slot_practitioner_time"
+)
+
+// isSlotOverlap reports whether err is a slot insert or update overlapping
+// another slot of the clinician, or taking the times of a deleted one
+func isSlotOverlap(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName == slotOverlapConstraint || pgErr.ConstraintName == slotTimeIndex
+	}
+	// SQLite enforces the first with triggers raising the constraint's name,
+	// and names the columns of the second
+	return strings.Contains(err.Error(), slotOverlapConstraint) ||
+		strings.Contains(err.Error(), "UNIQUE constraint failed: appointment_slots.practitioner_id")
+}
+
+// patientEmailConstraint keeps patients.email unique
+const patientEmailConstraint = "patients_email_key"
+
+// isPatientEmailViolation reports whether err is a patient insert or
+// update giving them an email another patient has
+func isPatientEmailViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName == patientEmailConstraint
+	}
+	// SQLite names the column rather than the constraint
+	return strings.Contains(err.Error(), "UNIQUE constraint failed: patients.email")
+}
+
+// patientColumns are the columns scanPatient reads, in order
+const patientColumns = `id, name, email, created_at, updated_at, deactivated_at, deactivated_by, deactivation_reason, phone`
+
+func scanPatient(row rowScanner) (*Patient, error) {
+	var p Patient
+	var email *string
+
+	err := row.Scan(
+		&p.ID,
+		&p.Name,
+		&email,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+		&p.DeactivatedAt,
+		&p.DeactivatedBy,
+		&p.DeactivationReason,
+		&p.Phone,
+	)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, ErrPatientNotFound
+		}
+		return nil, err
+	}
+
+	p.Email = email
+	return &p, nil
+}
+
+// clinicianClinicConstraint ties clinicians.clinic_id to a clinic
+const clinicianClinicConstraint = "clinicians_clinic_id_fkey"
+
+// isClinicianClinicViolation reports whether err is a clinician insert
+// naming a clinic that does not exist
+func isClinicianClinicViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName == clinicianClinicConstraint
+	}
+	// SQLite names no constraint, and clinic_id is the clinicians table's
+	// only foreign key
+	return strings.Contains(err.Error(), "FOREIGN KEY constraint failed")
+}
+
+// clinicianColumns are the columns scanClinician reads, in order
+const clinicianColumns = `id, name, specialty, clinic_id, created_at, updated_at, deactivated_at`
+
+func scanClinician(row rowScanner) (*Clinician, error) {
+	var c Clinician
+	var specialty *string
+
+	err := row.Scan(
+		&c.ID,
+		&c.Name,
+		&specialty,
+		&c.ClinicID,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+		&c.DeactivatedAt,
+	)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, ErrClinicianNotFound
+		}
+		return nil, err
+	}
+
+	c.Specialty = specialty
+	return &c, nil
+}
+
+func scanSlot(row rowScanner) (*AppointmentSlot, error) {
+	var s AppointmentSlot
+
+	err := row.Scan(
+		&s.ID,
+		&s.PractitionerID,
+		&s.StartTime,
+		&s.EndTime,
+		&s.Status,
+		&s.Capacity,
+		&s.SlotType,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, ErrSlotNotFound
+		}
+		if isSlotOverlap(err) {
+			return nil, ErrSlotOverlap
+		}
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// orphanedAppointmentsQuery selects the rows scanned by
+// scanOrphanedAppointment. The statuses are literals so that one query text
+// serves both backends; param renders the n-th placeholder.
+func orphanedAppointmentsQuery(param func(n int) string) string {
+	return `
+		SELECT a.id, a.slot_id, a.patient_id, a.status, a.created_at, a.updated_at, a.expires_at, s.status
+		FROM appointments a
+		JOIN appointment_slots s ON s.id = a.slot_id
+		WHERE (a.status = 'pending' AND a.expires_at < ` + param(1) + `)
+		   OR (a.status IN ('pending', 'pending_approval', 'confirmed') AND s.status = 'deleted')
+		   OR (a.status = 'confirmed' AND s.status = 'blocked')
+		ORDER BY a.updated_at DESC, a.id
+		LIMIT ` + param(2)
+}
+
+// overduePendingQuery counts pending appointments with a hold that passed
+// before each of n cutoffs, param(1) to param(n)
+func overduePendingQuery(param func(n int) string, n int) string {
+	counts := make([]string, n)
+	for i := range counts {
+		counts[i] = "COALESCE(SUM(CASE WHEN expires_at < " + param(i+1) + " THEN 1 ELSE 0 END), 0)"
+	}
+	return `
+		SELECT ` + strings.Join(counts, ",\n\t\t       ") + `
+		FROM appointments
+		WHERE status = 'pending'
+		  AND expires_at IS NOT NULL`
+}
+
+// patientTimelineQuery selects the newest events of a patient's appointments;
+// param(1) is the patient and param(2) the limit
+func patientTimelineQuery(param func(n int) string) string {
+	return `
+		SELECT e.id, e.event_type, e.payload, e.created_at,
+		       a.id, a.status, s.id, s.start_time, s.end_time
+		FROM appointments a
+		JOIN event_logs e ON e.appointment_id = a.id
+		JOIN appointment_slots s ON s.id = a.slot_id
+		WHERE a.patient_id = ` + param(1) + `
+		ORDER BY e.created_at DESC, e.id DESC
+		LIMIT ` + param(2)
+}
+
+func scanTimelineEntry(row rowScanner) (*TimelineEntry, error) {
+	var t TimelineEntry
+	err := row.Scan(
+		&t.EventID,
+		&t.EventType,
+		&t.Payload,
+		&t.CreatedAt,
+		&t.AppointmentID,
+		&t.AppointmentStatus,
+		&t.SlotID,
+		&t.SlotStart,
+		&t.SlotEnd,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// appointmentSlotsQuery selects every slot an appointment spans, earliest
+// first; param(1) and param(2) are both the appointment
+func appointmentSlotsQuery(param func(n int) string) string {
+	return `
+		SELECT id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+		FROM appointment_slots
+		WHERE id IN (
+		    SELECT slot_id FROM appointments WHERE id = ` + param(1) + `
+		    UNION
+		    SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = ` + param(2) + `
+		)
+		ORDER BY start_time, id`
+}
+
+// blockingAppointmentQuery selects whether a confirmed or checked-in and
+// whether a held appointment spans slot param(1) through (4), all the same
+// slot. Each probe is an EXISTS on idx_appointments_slot_held or the extra
+// slots index, so no appointment row is read.
+func blockingAppointmentQuery(param func(n int) string) string {
+	probe := func(n int, statuses string) string {
+		return `EXISTS (
+		           SELECT 1 FROM appointments
+		           WHERE slot_id = ` + param(n) + ` AND status IN (` + statuses + `)
+		       ) OR EXISTS (
+		           SELECT 1
+		           FROM appointment_extra_slots x
+		           JOIN appointments a ON a.id = x.appointment_id
+		           WHERE x.slot_id = ` + param(n+1) + ` AND a.status IN (` + statuses + `)
+		       )`
+	}
+	return `
+		SELECT ` + probe(1, `'confirmed', 'checked_in'`) + `,
+		       ` + probe(3, `'pending', 'pending_approval'`)
+}
+
+// takenPlacesQuery counts the places taken in slot param(1), also param(2):
+// its confirmed and checked-in appointments and the pending holds with no
+// deadline or one not before param(3), as their first slot or a later one.
+// A booking awaiting approval takes no place until it is approved.
+func takenPlacesQuery(param func(n int) string) string {
+	return `
+		SELECT count(*)
+		FROM appointments
+		WHERE (slot_id = ` + param(1) + `
+		       OR id IN (SELECT appointment_id FROM appointment_extra_slots WHERE slot_id = ` + param(2) + `))
+		  AND (status IN ('confirmed', 'checked_in') OR (status = 'pending' AND (expires_at IS NULL OR expires_at >= ` + param(3) + `)))`
+}
+
+// patientConflictsQuery selects the active appointments of patient
+// param(1) with a slot, first or later, overlapping [param(2), param(3))
+func patientConflictsQuery(param func(n int) string) string {
+	return `
+		SELECT a.id, a.slot_id, a.patient_id, a.status, a.created_at, a.updated_at, a.expires_at
+		FROM appointments a
+		WHERE a.patient_id = ` + param(1) + `
+		  AND a.status IN ('pending', 'pending_approval', 'confirmed', 'checked_in')
+		  AND EXISTS (
+		      SELECT 1
+		      FROM appointment_slots s
+		      WHERE (s.id = a.slot_id
+		             OR s.id IN (SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = a.id))
+		        AND s.end_time > ` + param(2) + `
+		        AND s.start_time < ` + param(3) + `
+		  )
+		ORDER BY a.created_at, a.id`
+}
+
+// pendingByPhoneQuery selects the pending appointments of the patients
+// with phone param(1) whose hold runs past param(2), soonest slot first
+func pendingByPhoneQuery(param func(n int) string) string {
+	return `
+		SELECT a.id, a.slot_id, a.patient_id, a.status, a.created_at, a.updated_at, a.expires_at
+		FROM appointments a
+		INNER JOIN patients p ON a.patient_id = p.id
+		INNER JOIN appointment_slots s ON a.slot_id = s.id
+		WHERE p.phone = ` + param(1) + `
+		  AND a.status = 'pending'
+		  AND a.expires_at > ` + param(2) + `
+		ORDER BY s.start_time, a.created_at, a.id`
+}
+
+// nextByPhoneQuery selects the active appointment of the patients with
+// phone param(1) whose slot starts soonest after param(2), in a slot of a
+// clinician of clinic param(3) when clinic is set
+func nextByPhoneQuery(clinic bool, param func(n int) string) string {
+	query := `
+		SELECT a.id, a.slot_id, a.patient_id, a.status, a.created_at, a.updated_at, a.expires_at
+		FROM appointments a
+		INNER JOIN patients p ON a.patient_id = p.id
+		INNER JOIN appointment_slots s ON a.slot_id = s.id
+		INNER JOIN clinicians c ON s.practitioner_id = c.id
+		WHERE p.phone = ` + param(1) + `
+		  AND a.status IN ('pending', 'pending_approval', 'confirmed')
+		  AND s.start_time > ` + param(2)
+	if clinic {
+		query += `
+		  AND c.clinic_id = ` + param(3)
+	}
+	return query + `
+		ORDER BY s.start_time, a.created_at, a.id
+		LIMIT 1`
+}
+
+// syncEventsQuery selects the ID and appointment of events after param(1)
+// and created before param(2) of appointments whose first slot belongs to a
+// clinician of clinic param(3), and of clinician param(4) when clinician
+// is set. The event's primary key gives the order.
+func syncEventsQuery(clinician bool, param func(n int) string) string {
+	query := `
+		SELECT e.id, e.appointment_id
+		FROM event_logs e
+		JOIN appointments a ON a.id = e.appointment_id
+		JOIN appointment_slots s ON s.id = a.slot_id
+		JOIN clinicians c ON c.id = s.practitioner_id
+		WHERE e.id > ` + param(1) + `
+		  AND e.created_at < ` + param(2) + `
+		  AND c.clinic_id = ` + param(3)
+	if clinician {
+		query += `
+		  AND s.practitioner_id = ` + param(4)
+	}
+	return query + `
+		ORDER BY e.id`
+}
+
+// clinicianSlotsOverlappingQuery selects the slots of clinician param(1)
+// that are not deleted and overlap [param(2), param(3))
+func clinicianSlotsOverlappingQuery(param func(n int) string) string {
+	return `
+		SELECT id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+		FROM appointment_slots
+		WHERE practitioner_id = ` + param(1) + `
+		  AND status <> 'deleted'
+		  AND end_time > ` + param(2) + `
+		  AND start_time < ` + param(3) + `
+		ORDER BY start_time, id`
+}
+
+// activeSlotAppointmentsQuery selects the active appointments spanning
+// slot param(1) through (2), both the same slot, oldest booking first
+func activeSlotAppointmentsQuery(param func(n int) string) string {
+	return `
+		SELECT a.id, a.slot_id, a.patient_id, a.status, a.created_at, a.updated_at, a.expires_at
+		FROM appointments a
+		WHERE a.status IN ('pending', 'pending_approval', 'confirmed', 'checked_in')
+		  AND (a.slot_id = ` + param(1) + `
+		       OR a.id IN (SELECT appointment_id FROM appointment_extra_slots WHERE slot_id = ` + param(2) + `))
+		ORDER BY a.created_at, a.id`
+}
+
+// availableResourcesQuery selects the clinic's staff of a kind speaking a
+// language, ” for chaperones, that no active appointment holds over the
+// range. param(1) is the clinic, (2) the kind, (3) the language and (4) and
+// (5) the start and end of the range.
+func availableResourcesQuery(param func(n int) string) string {
+	return `
+		SELECT r.id, r.clinic_id, r.kind, r.language, r.name, r.created_at
+		FROM staff_resources r
+		WHERE r.clinic_id = ` + param(1) + `
+		  AND r.kind = ` + param(2) + `
+		  AND COALESCE(r.language, '') = ` + param(3) + `
+		  AND NOT EXISTS (
+		      SELECT 1
+		      FROM appointment_resources x
+		      JOIN appointments a ON a.id = x.appointment_id
+		      WHERE x.resource_id = r.id
+		        AND a.status IN ('pending', 'pending_approval', 'confirmed', 'checked_in')
+		        AND x.end_time > ` + param(4) + `
+		        AND x.start_time < ` + param(5) + `
+		  )
+		ORDER BY r.name, r.id`
+}
+
+// appointmentResourcesQuery selects the staff reserved for appointment
+// param(1)
+func appointmentResourcesQuery(param func(n int) string) string {
+	return `
+		SELECT r.id, r.clinic_id, r.kind, r.language, r.name, r.created_at
+		FROM appointment_resources x
+		JOIN staff_resources r ON r.id = x.resource_id
+		WHERE x.appointment_id = ` + param(1) + `
+		ORDER BY r.kind, r.name, r.id`
+}
+
+func scanStaffResource(row rowScanner) (*StaffResource, error) {
+	var r StaffResource
+	err := row.Scan(&r.ID, &r.ClinicID, &r.Kind, &r.Language, &r.Name, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// attachmentColumns are the columns scanAttachment reads
+const attachmentColumns = `id, appointment_id, kind, filename, content_type, size_bytes, blob_key, scan_status, created_at`
+
+func scanAttachment(row rowScanner) (*Attachment, error) {
+	var a Attachment
+	err := row.Scan(&a.ID, &a.AppointmentID, &a.Kind, &a.Filename, &a.ContentType, &a.Size, &a.BlobKey, &a.ScanStatus, &a.CreatedAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// noteColumns are the columns scanNote reads
+const noteColumns = `id, appointment_id, author, body, created_at`
+
+func scanNote(row rowScanner) (*AppointmentNote, error) {
+	var n AppointmentNote
+	if err := row.Scan(&n.ID, &n.AppointmentID, &n.Author, &n.Body, &n.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// deviceColumns are the columns scanDevice reads
+const deviceColumns = `id, patient_id, provider, token, created_at, updated_at`
+
+func scanDevice(row rowScanner) (*Device, error) {
+	var d Device
+	err := row.Scan(&d.ID, &d.PatientID, &d.Provider, &d.Token, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// smsReplyColumns are the columns scanSMSReply reads
+const smsReplyColumns = `message_id, from_number, body, action, appointment_id, outcome, received_at`
+
+func scanSMSReply(row rowScanner) (*SMSReply, error) {
+	var m SMSReply
+	err := row.Scan(&m.MessageID, &m.From, &m.Body, &m.Action, &m.AppointmentID, &m.Outcome, &m.ReceivedAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, ErrSMSReplyNotFound
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// apiKeyColumns are the columns scanAPIKey reads
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, clinic_id, created_at, revoked_at`
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var k APIKey
+	var scopes string
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.KeyHash, &scopes, &k.ClinicID, &k.CreatedAt, &k.RevokedAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	for _, scope := range strings.Fields(scopes) {
+		k.Scopes = append(k.Scopes, APIScope(scope))
+	}
+	return &k, nil
+}
+
+// joinScopes is how scopes are stored, space-separated
+func joinScopes(scopes []APIScope) string {
+	s := make([]string, len(scopes))
+	for i, scope := range scopes {
+		s[i] = string(scope)
+	}
+	return strings.Join(s, " ")
+}
+
+// collectAPIKeys reads rows of apiKeyColumns
+func collectAPIKeys(rows detailRows) ([]APIKey, error) {
+	var result []APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// collectDevices reads rows of deviceColumns
+func collectDevices(rows detailRows) ([]Device, error) {
+	var result []Device
+	for rows.Next() {
+		d, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// intakeQuestionJSON is how intake questions are stored
+type intakeQuestionJSON struct {
+	ID       string             `json:"id"`
+	Label    string             `json:"label"`
+	Type     IntakeQuestionType `json:"type"`
+	Required bool               `json:"required,omitempty"`
+	Options  []string           `json:"options,omitempty"`
+}
+
+func encodeIntakeQuestions(questions []IntakeQuestion) (string, error) {
+	stored := make([]intakeQuestionJSON, len(questions))
+	for i, q := range questions {
+		stored[i] = intakeQuestionJSON(q)
+	}
+	data, err := json.Marshal(stored)
+	return string(data), err
+}
+
+// availabilityPeriodJSON is how an AvailabilityPeriod is stored
+type availabilityPeriodJSON struct {
+	Weekday time.Weekday `json:"weekday"`
+	Start   int          `json:"start"`
+	End     int          `json:"end"`
+}
+
+func encodeAvailabilityPeriods(periods []AvailabilityPeriod) (string, error) {
+	stored := make([]availabilityPeriodJSON, len(periods))
+	for i, p := range periods {
+		stored[i] = availabilityPeriodJSON(p)
+	}
+	data, err := json.Marshal(stored)
+	return string(data), err
+}
+
+// availabilityTemplateColumns are the columns scanAvailabilityTemplate reads
+const availabilityTemplateColumns = `clinician_id, time_zone, slot_minutes, capacity, slot_type, periods, updated_at`
+
+func scanAvailabilityTemplate(row rowScanner) (*AvailabilityTemplate, error) {
+	var t AvailabilityTemplate
+	var minutes int
+	var periods []byte
+	if err := row.Scan(&t.ClinicianID, &t.TimeZone, &minutes, &t.Capacity, &t.SlotType, &periods, &t.UpdatedAt); err != nil {
+		if isNoRows(err) {
+			return nil, ErrAvailabilityTemplateNotFound
+		}
+		return nil, err
+	}
+	t.SlotDuration = time.Duration(minutes) * time.Minute
+
+	var stored []availabilityPeriodJSON
+	if err := json.Unmarshal(periods, &stored); err != nil {
+		return nil, fmt.Errorf("decode availability template of %s: %w", t.ClinicianID, err)
+	}
+	t.Periods = make([]AvailabilityPeriod, len(stored))
+	for i, p := range stored {
+		t.Periods[i] = AvailabilityPeriod(p)
+	}
+	return &t, nil
+}
+
+// intakeTemplateSelect reads a template row for scanIntakeTemplate
+const intakeTemplateSelect = `
+		SELECT slot_type, version, questions, updated_at
+		FROM intake_templates`
+
+func scanIntakeTemplate(row rowScanner) (*IntakeTemplate, error) {
+	var t IntakeTemplate
+	var questions []byte
+	if err := row.Scan(&t.SlotType, &t.Version, &questions, &t.UpdatedAt); err != nil {
+		if isNoRows(err) {
+			return nil, ErrIntakeTemplateNotFound
+		}
+		return nil, err
+	}
+	var stored []intakeQuestionJSON
+	if err := json.Unmarshal(questions, &stored); err != nil {
+		return nil, fmt.Errorf("decode intake template %s: %w", t.SlotType, err)
+	}
+	t.Questions = make([]IntakeQuestion, len(stored))
+	for i, q := range stored {
+		t.Questions[i] = IntakeQuestion(q)
+	}
+	return &t, nil
+}
+
+// intakeResponseColumns are the columns scanIntakeResponse reads
+const intakeResponseColumns = `appointment_id, template_version, answers, complete, created_at, updated_at`
+
+// scanIntakeResponse reads intakeResponseColumns; no row scans as nil
+func scanIntakeResponse(row rowScanner) (*IntakeResponse, error) {
+	var r IntakeResponse
+	var answers []byte
+	if err := row.Scan(&r.AppointmentID, &r.TemplateVersion, &answers, &r.Complete, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(answers, &r.Answers); err != nil {
+		return nil, fmt.Errorf("decode intake answers of %s: %w", r.AppointmentID, err)
+	}
+	return &r, nil
+}
+
+// intakeRemindersQuery selects confirmed appointments starting in
+// [param(1), param(2)) with an incomplete intake form and no param(3) event
+func intakeRemindersQuery(param func(n int) string) string {
+	return `
+		SELECT a.id, s.slot_type, s.start_time
+		FROM appointments a
+		INNER JOIN appointment_slots s ON s.id = a.slot_id
+		INNER JOIN intake_templates t ON t.slot_type = s.slot_type
+		LEFT JOIN intake_responses r ON r.appointment_id = a.id
+		WHERE a.status = 'confirmed'
+		  AND s.start_time >= ` + param(1) + `
+		  AND s.start_time < ` + param(2) + `
+		  AND (r.appointment_id IS NULL OR NOT r.complete)
+		  AND NOT EXISTS (
+		      SELECT 1
+		      FROM event_logs e
+		      WHERE e.appointment_id = a.id
+		        AND e.event_type = ` + param(3) + `
+		  )
+		ORDER BY s.start_time, a.id
+		LIMIT ` + param(4)
+}
+
+const feedbackColumns = `appointment_id, clinician_id, rating, comment, created_at`
+
+func scanFeedback(row rowScanner) (*Feedback, error) {
+	var f Feedback
+	if err := row.Scan(&f.AppointmentID, &f.ClinicianID, &f.Rating, &f.Comment, &f.CreatedAt); err != nil {
+		if isNoRows(err) {
+			return nil, ErrFeedbackNotFound
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+// clinicianRatingsQuery aggregates feedback given since param(1) by
+// clinician, keeping clinicians with at least param(2) ratings
+func clinicianRatingsQuery(param func(n int) string) string {
+	return `
+		SELECT c.id, c.name, c.specialty, COUNT(*), CAST(AVG(f.rating) AS DOUBLE PRECISION),
+		       SUM(CASE WHEN f.rating = 1 THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN f.rating = 2 THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN f.rating = 3 THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN f.rating = 4 THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN f.rating = 5 THEN 1 ELSE 0 END)
+		FROM appointment_feedback f
+		INNER JOIN clinicians c ON c.id = f.clinician_id
+		WHERE f.created_at >= ` + param(1) + `
+		GROUP BY c.id, c.name, c.specialty
+		HAVING COUNT(*) >= ` + param(2) + `
+		ORDER BY AVG(f.rating) DESC, COUNT(*) DESC, c.name`
+}
+
+func scanClinicianRating(row rowScanner) (*ClinicianRating, error) {
+	var cr ClinicianRating
+	r := &cr.Ratings
+	if err := row.Scan(&cr.ClinicianID, &cr.Name, &cr.Specialty, &cr.Count, &cr.Average, &r[0], &r[1], &r[2], &r[3], &r[4]); err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+// feedbackRequestsQuery selects confirmed, checked-in and completed
+// appointments ending in [param(1), param(2)) without feedback and with no
+// param(4) event, with param(5) the limit. A multi-slot appointment is
+// selected once its last slot has ended too; param(3) is the end of the
+// range again.
+func feedbackRequestsQuery(param func(n int) string) string {
+	return `
+		SELECT a.id, s.practitioner_id, s.end_time
+		FROM appointments a
+		INNER JOIN appointment_slots s ON s.id = a.slot_id
+		WHERE a.status IN ('confirmed', 'checked_in', 'completed')
+		  AND s.end_time >= ` + param(1) + `
+		  AND s.end_time < ` + param(2) + `
+		  AND NOT EXISTS (
+		      SELECT 1
+		      FROM appointment_extra_slots x
+		      INNER JOIN appointment_slots xs ON xs.id = x.slot_id
+		      WHERE x.appointment_id = a.id
+		        AND xs.end_time >= ` + param(3) + `
+		  )
+		  AND NOT EXISTS (
+		      SELECT 1
+		      FROM appointment_feedback f
+		      WHERE f.appointment_id = a.id
+		  )
+		  AND NOT EXISTS (
+		      SELECT 1
+		      FROM event_logs e
+		      WHERE e.appointment_id = a.id
+		        AND e.event_type = ` + param(4) + `
+		  )
+		ORDER BY s.end_time, a.id
+		LIMIT ` + param(5)
+}
+
+const retentionPolicyColumns = `tenant_id, appointment_months, event_months, feedback_months, updated_at`
+
+func scanRetentionPolicy(row rowScanner) (*RetentionPolicy, error) {
+	var p RetentionPolicy
+	if err := row.Scan(&p.TenantID, &p.AppointmentMonths, &p.EventMonths, &p.FeedbackMonths, &p.UpdatedAt); err != nil {
+		if isNoRows(err) {
+			return nil, ErrRetentionPolicyNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+const retentionRunColumns = `id, entity, tenant_id, retain_months, cutoff, dry_run, matched, deleted, started_at, finished_at`
+
+func scanRetentionRun(row rowScanner) (*RetentionRun, error) {
+	var run RetentionRun
+	if err := row.Scan(&run.ID, &run.Entity, &run.TenantID, &run.RetainMonths, &run.Cutoff,
+		&run.DryRun, &run.Matched, &run.Deleted, &run.StartedAt, &run.FinishedAt); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// expiredAppointmentsWhere matches appointments whose every slot ended
+// before param(1), which is repeated as param(2) for the later slots
+func expiredAppointmentsWhere(param func(n int) string) string {
+	return `
+		FROM appointments a
+		INNER JOIN appointment_slots s ON s.id = a.slot_id
+		WHERE s.end_time < ` + param(1) + `
+		  AND NOT EXISTS (
+		      SELECT 1
+		      FROM appointment_extra_slots x
+		      INNER JOIN appointment_slots xs ON xs.id = x.slot_id
+		      WHERE x.appointment_id = a.id
+		        AND xs.end_time >= ` + param(2) + `
+		  )`
+}
+
+// expiredRecordsCountQuery counts the records of entity past the cutoff in
+// param(1), and param(2) for appointments
+func expiredRecordsCountQuery(entity RetentionEntity, param func(n int) string) string {
+	switch entity {
+	case RetainAppointments:
+		return `SELECT COUNT(*)` + expiredAppointmentsWhere(param)
+	case RetainEvents:
+		return `SELECT COUNT(*) FROM event_logs WHERE created_at < ` + param(1)
+	default:
+		return `SELECT COUNT(*) FROM appointment_feedback WHERE created_at < ` + param(1)
+	}
+}
+
+// appointmentDeleteStatements delete the appointments listed by doomed, an
+// expression yielding one id column, with every row that refers to them.
+// The confirmed count trigger only reaches the later slots of an
+// appointment through appointment_extra_slots, so those are given back
+// before the rows go. Every statement binds the same arguments; the last
+// deletes the appointments, so its row count is the number deleted.
+func appointmentDeleteStatements(doomed string) []string {
+	with := `WITH doomed(id) AS (` + doomed + `) `
+	stmts := []string{with + `
+		UPDATE appointment_slots
+		SET confirmed_count = confirmed_count - (
+		    SELECT COUNT(*)
+		    FROM appointment_extra_slots x
+		    INNER JOIN appointments a ON a.id = x.appointment_id
+		    WHERE x.slot_id = appointment_slots.id
+		      AND a.status IN ('confirmed', 'checked_in')
+		      AND a.id IN (SELECT id FROM doomed)
+		)
+		WHERE id IN (
+		    SELECT x.slot_id
+		    FROM appointment_extra_slots x
+		    INNER JOIN appointments a ON a.id = x.appointment_id
+		    WHERE a.status IN ('confirmed', 'checked_in')
+		      AND a.id IN (SELECT id FROM doomed)
+		)`}
+	for _, table := range []string{
+		"appointment_extra_slots",
+		"event_logs",
+		"intake_responses",
+		"appointment_feedback",
+		"appointment_attachments",
+		"appointment_notes",
+		"appointment_resources",
+		"appointment_series_occurrences",
+		"booking_intents",
+		"sms_replies",
+	} {
+		stmts = append(stmts, with+`DELETE FROM `+table+` WHERE appointment_id IN (SELECT id FROM doomed)`)
+	}
+	return append(stmts,
+		with+`DELETE FROM appointment_series
+		WHERE NOT EXISTS (
+		    SELECT 1
+		    FROM appointment_series_occurrences o
+		    WHERE o.series_id = appointment_series.id
+		)`,
+		with+`DELETE FROM appointments WHERE id IN (SELECT id FROM doomed)`,
+	)
+}
+
+// heldSlotsTable is appointment_slots with a column held counting the
+// holds of each slot live at now, as takenPlacesQuery counts them
+func heldSlotsTable(now string) string {
+	return `(
+		    SELECT sl.*, (
+		        SELECT count(*)
+		        FROM appointments h
+		        WHERE (h.slot_id = sl.id
+		               OR h.id IN (SELECT appointment_id FROM appointment_extra_slots WHERE slot_id = sl.id))
+		          AND h.status = 'pending'
+		          AND (h.expires_at IS NULL OR h.expires_at >= ` + now + `)
+		    ) AS held
+		    FROM appointment_slots sl
+		)`
+}
+
+// slotsQuery selects the slots matching q, soonest first, after the slot
+// at after when it is set, with the self-pay price of its type at its
+// clinic, and returns the arguments it binds. Holds live at now take
+// places as confirmed appointments do.
+func slotsQuery(q SlotQuery, after *pageKey, now time.Time, param func(n int) string) (string, []any) {
+	args := []any{now, q.From.UTC(), q.To.UTC(), string(q.Status)}
+	where := `s.start_time >= ` + param(2) + `
+		  AND s.start_time < ` + param(3) + `
+		  AND s.status = ` + param(4)
+	if q.Status == SlotOpen {
+		where += `
+		  AND s.confirmed_count + s.held < s.capacity
+		  AND c.deactivated_at IS NULL`
+	}
+	if after != nil {
+		args = append(args, after.At.UTC(), after.ID)
+		where += `
+		  AND (s.start_time, s.id) > (` + param(len(args)-1) + `, ` + param(len(args)) + `)`
+	}
+	if q.ClinicID != nil {
+		args = append(args, *q.ClinicID)
+		where += `
+		  AND c.clinic_id = ` + param(len(args))
+	}
+	if q.ClinicianID != nil {
+		args = append(args, *q.ClinicianID)
+		where += `
+		  AND s.practitioner_id = ` + param(len(args))
+	}
+	if q.Specialty != "" {
+		args = append(args, q.Specialty)
+		where += `
+		  AND c.specialty = ` + param(len(args))
+	}
+	args = append(args, q.Limit)
+	return `
+		SELECT s.id, s.practitioner_id, s.start_time, s.end_time, s.status, s.capacity, s.slot_type, s.created_at, s.updated_at,
+		       c.name, c.specialty, s.capacity - s.confirmed_count - s.held, p.amount_minor, p.currency
+		FROM ` + heldSlotsTable(param(1)) + ` s
+		INNER JOIN clinicians c ON c.id = s.practitioner_id
+		LEFT JOIN slot_type_prices p ON p.clinic_id = c.clinic_id AND p.slot_type = s.slot_type
+		WHERE ` + where + `
+		ORDER BY s.start_time, s.id
+		LIMIT ` + param(len(args)), args
+}
+
+func scanOpenSlot(row rowScanner) (*OpenSlot, error) {
+	var o OpenSlot
+	var priceAmount *int64
+	var priceCurrency *string
+	if err := row.Scan(&o.ID, &o.PractitionerID, &o.StartTime, &o.EndTime, &o.Status, &o.Capacity,
+		&o.SlotType, &o.CreatedAt, &o.UpdatedAt, &o.ClinicianName, &o.Specialty, &o.Remaining,
+		&priceAmount, &priceCurrency); err != nil {
+		return nil, err
+	}
+	if priceAmount != nil && priceCurrency != nil {
+		o.Price = &Price{AmountMinor: *priceAmount, Currency: *priceCurrency}
+	}
+	return &o, nil
+}
+
+const piiAccessColumns = `id, actor, actor_kind, reason, patient_id, endpoint, request_id, accessed_at`
+
+func scanPIIAccess(row rowScanner) (*PIIAccess, error) {
+	var a PIIAccess
+	if err := row.Scan(&a.ID, &a.Actor, &a.ActorKind, &a.Reason, &a.PatientID,
+		&a.Endpoint, &a.RequestID, &a.AccessedAt); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// piiAccessQuery selects the access records matching f, newest first, and
+// returns the arguments it binds
+func piiAccessQuery(f PIIAccessFilter, param func(n int) string) (string, []any) {
+	args := []any{f.From.UTC(), f.To.UTC()}
+	where := `accessed_at >= ` + param(1) + ` AND accessed_at < ` + param(2)
+	if f.PatientID != nil {
+		args = append(args, *f.PatientID)
+		where += ` AND patient_id = ` + param(len(args))
+	}
+	if f.Actor != "" {
+		args = append(args, f.Actor)
+		where += ` AND actor = ` + param(len(args))
+	}
+	args = append(args, f.Limit)
+	return `
+		SELECT ` + piiAccessColumns + `
+		FROM pii_access_log
+		WHERE ` + where + `
+		ORDER BY accessed_at DESC, id
+		LIMIT ` + param(len(args)), args
+}
+
+// bookingWindowSelect reads a policy row for scanBookingWindow
+const bookingWindowSelect = `
+		SELECT specialty, min_lead_seconds, max_lead_seconds, updated_at
+		FROM specialty_booking_policies`
+
+func scanBookingWindow(row rowScanner) (*BookingWindow, error) {
+	var w BookingWindow
+	var minLead int64
+	var maxLead *int64
+	if err := row.Scan(&w.Specialty, &minLead, &maxLead, &w.UpdatedAt); err != nil {
+		if isNoRows(err) {
+			return nil, ErrBookingWindowNotFound
+		}
+		return nil, err
+	}
+	w.MinLead = time.Duration(minLead) * time.Second
+	if maxLead != nil {
+		d := time.Duration(*maxLead) * time.Second
+		w.MaxLead = &d
+	}
+	return &w, nil
+}
+
+// leadSeconds stores a lead time in whole seconds; nil stays NULL
+func leadSeconds(d *time.Duration) *int64 {
+	if d == nil {
+		return nil
+	}
+	secs := int64(*d / time.Second)
+	return &secs
+}
+
+// slotBookingWindowColumns selects the policy of the specialty of a slot's
+// clinician, NULLs when there is none; callers add the WHERE on s.id
+const slotBookingWindowColumns = `
+		SELECT p.specialty, p.min_lead_seconds, p.max_lead_seconds, p.updated_at
+		FROM appointment_slots s
+		INNER JOIN clinicians c ON s.practitioner_id = c.id
+		LEFT JOIN specialty_booking_policies p ON p.specialty = c.specialty`
+
+// scanSlotBookingWindow reads slotBookingWindowColumns; a slot without a
+// window scans as nil
+func scanSlotBookingWindow(row rowScanner) (*BookingWindow, error) {
+	var specialty *string
+	var minLead, maxLead *int64
+	var updatedAt *time.Time
+	if err := row.Scan(&specialty, &minLead, &maxLead, &updatedAt); err != nil {
+		if isNoRows(err) {
+			return nil, ErrSlotNotFound
+		}
+		return nil, err
+	}
+	if specialty == nil {
+		return nil, nil
+	}
+	w := &BookingWindow{Specialty: *specialty, MinLead: time.Duration(*minLead) * time.Second, UpdatedAt: *updatedAt}
+	if maxLead != nil {
+		d := time.Duration(*maxLead) * time.Second
+		w.MaxLead = &d
+	}
+	return w, nil
+}
+
+// seriesSelect reads a series row for scanSeries
+const seriesSelect = `
+		SELECT id, patient_id, clinician_id, interval_days, time_zone, created_at
+		FROM appointment_series`
+
+func scanSeries(row rowScanner) (*Series, error) {
+	var s Series
+	err := row.Scan(&s.ID, &s.PatientID, &s.ClinicianID, &s.IntervalDays, &s.TimeZone, &s.CreatedAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, ErrSeriesNotFound
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// seriesOccurrencesQuery selects the occurrences of series param(1) with
+// their appointments and slots, in order
+func seriesOccurrencesQuery(param func(n int) string) string {
+	return `
+		SELECT o.occurrence, o.exception,
+		       a.id, a.slot_id, a.patient_id, a.status, a.created_at, a.updated_at, a.expires_at,
+		       s.id, s.practitioner_id, s.start_time, s.end_time, s.status, s.capacity, s.slot_type, s.created_at, s.updated_at
+		FROM appointment_series_occurrences o
+		JOIN appointments a ON a.id = o.appointment_id
+		JOIN appointment_slots s ON s.id = a.slot_id
+		WHERE o.series_id = ` + param(1) + `
+		ORDER BY o.occurrence`
+}
+
+func scanSeriesOccurrence(row rowScanner) (*SeriesOccurrence, error) {
+	var o SeriesOccurrence
+	a, sl := &o.Appointment, &o.Slot
+	err := row.Scan(
+		&o.Number,
+		&o.Exception,
+		&a.ID,
+		&a.SlotID,
+		&a.PatientID,
+		&a.Status,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+		&a.ExpiresAt,
+		&sl.ID,
+		&sl.PractitionerID,
+		&sl.StartTime,
+		&sl.EndTime,
+		&sl.Status,
+		&sl.Capacity,
+		&sl.SlotType,
+		&sl.CreatedAt,
+		&sl.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// scanOrphanedAppointment scans a row of orphanedAppointmentsQuery. A stale
+// hold on a deleted slot is classified by the slot.
+func scanOrphanedAppointment(row rowScanner) (*OrphanedAppointment, error) {
+	var o OrphanedAppointment
+	err := row.Scan(
+		&o.ID,
+		&o.SlotID,
+		&o.PatientID,
+		&o.Status,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+		&o.ExpiresAt,
+		&o.SlotStatus,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case o.SlotStatus == SlotDeleted:
+		o.Kind = OrphanDeletedSlot
+	case o.Status == StatusConfirmed:
+		o.Kind = OrphanBlockedSlot
+	default:
+		o.Kind = OrphanStalePending
+	}
+	return &o, nil
+}
+
+func scanAppointment(row rowScanner) (*Appointment, error) {
+	var a Appointment
+	var expiresAt *time.Time
+
+	err := row.Scan(
+		&a.ID,
+		&a.SlotID,
+		&a.PatientID,
+		&a.Status,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+		&expiresAt,
+	)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, ErrAppointmentNotFound
+		}
+		if isCapacityViolation(err) {
+			return nil, ErrSlotAlreadyBooked
+		}
+		return nil, err
+	}
+
+	a.ExpiresAt = expiresAt
+	return &a, nil
+}
+
+func scanBookingIntent(row rowScanner) (*BookingIntent, error) {
+	var i BookingIntent
+
+	err := row.Scan(
+		&i.ID,
+		&i.SlotID,
+		&i.PatientID,
+		&i.LockToken,
+		&i.State,
+		&i.AppointmentID,
+		&i.CreatedAt,
+		&i.ResolvedAt,
+	)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, ErrIntentNotFound
+		}
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+// detailSelect builds the query for AppointmentDetail rows with the columns
+// and joins fields needs; callers append the WHERE clause. The slot's price
+// is looked up through the clinician's clinic, so a slot always brings the
+// clinician join along. Column order must match scanAppointmentDetail.
+func detailSelect(fields DetailFields) string {
+	return detailSelectJoining(fields, fields.Slot || fields.Clinician, fields.Patient)
+}
+
+// detailSelectJoining is detailSelect that also joins the slot and
+// clinician when slot is set, and the patient when patient is set, for
+// WHERE clauses that filter on them whatever fields selects. extra columns
+// are selected after the ones scanAppointmentDetail reads.
+func detailSelectJoining(fields DetailFields, slot, patient bool, extra ...string) string {
+	cols := []string{"a.id, a.slot_id, a.patient_id, a.status, a.created_at, a.updated_at, a.expires_at, a.checked_in_at, a.reason"}
+	if fields.Slot {
+		cols = append(cols, "s.id, s.practitioner_id, s.start_time, s.end_time, s.status, s.capacity, s.slot_type, s.created_at, s.updated_at")
+	}
+	if fields.Patient {
+		cols = append(cols, "p.id, p.name, p.email, p.created_at, p.updated_at, p.deactivated_at, p.deactivated_by, p.deactivation_reason, p.phone")
+	}
+	if fields.Clinician {
+		cols = append(cols, "c.id, c.name, c.specialty, c.clinic_id, c.created_at, c.updated_at")
+	}
+	if fields.Slot {
+		cols = append(cols, "sp.amount_minor, sp.currency")
+	}
+	cols = append(cols, extra...)
+
+	var b strings.Builder
+	b.WriteString("\n\t\tSELECT\n\t\t\t")
+	b.WriteString(strings.Join(cols, ",\n\t\t\t"))
+	b.WriteString("\n\t\tFROM appointments a")
+	if slot {
+		b.WriteString("\n\t\tINNER JOIN appointment_slots s ON a.slot_id = s.id")
+	}
+	if patient {
+		b.WriteString("\n\t\tINNER JOIN patients p ON a.patient_id = p.id")
+	}
+	if slot {
+		b.WriteString("\n\t\tINNER JOIN clinicians c ON s.practitioner_id = c.id")
+	}
+	if fields.Slot {
+		b.WriteString("\n\t\tLEFT JOIN slot_type_prices sp ON sp.clinic_id = c.clinic_id AND sp.slot_type = s.slot_type")
+	}
+	return b.String()
+}
+
+// appointmentSearchQuery builds the query for one page of q, fetching one
+// row more than q.Limit, and returns the arguments it binds. The slot and
+// clinician are joined when a filter needs them, the patient for a name
+// search; fields only decides which columns come back.
+func appointmentSearchQuery(q AppointmentSearch, after *pageKey, fields DetailFields, param func(n int) string) (string, []any) {
+	var args []any
+	var where []string
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, strings.ReplaceAll(cond, "?", param(len(args))))
+	}
+
+	if q.PatientID != nil {
+		add("a.patient_id = ?", *q.PatientID)
+	}
+	if q.SlotID != nil {
+		add("a.slot_id = ?", *q.SlotID)
+	}
+	if q.PatientName != "" {
+		add(`lower(p.name) LIKE ? ESCAPE '\'`, "%"+escapeLike(strings.ToLower(q.PatientName))+"%")
+	}
+	if q.ClinicianID != nil {
+		add("s.practitioner_id = ?", *q.ClinicianID)
+	}
+	if q.Specialty != "" {
+		add("c.specialty = ?", q.Specialty)
+	}
+	if len(q.Statuses) > 0 {
+		marks := make([]string, len(q.Statuses))
+		for i, st := range q.Statuses {
+			args = append(args, string(st))
+			marks[i] = param(len(args))
+		}
+		where = append(where, "a.status IN ("+strings.Join(marks, ", ")+")")
+	}
+	if q.From != nil {
+		add("s.start_time >= ?", q.From.UTC())
+	}
+	if q.To != nil {
+		add("s.start_time < ?", q.To.UTC())
+	}
+	hideDeactivated := !q.IncludeDeactivated && q.PatientID == nil
+	if hideDeactivated {
+		where = append(where, "p.deactivated_at IS NULL")
+	}
+
+	slot := q.ClinicianID != nil || q.Specialty != "" || q.From != nil || q.To != nil
+	return detailListQuery(fields, slot, q.PatientName != "" || hideDeactivated, where, args, q.Sort, after, q.Limit+1, 0, param)
+}
+
+// detailListQuery selects the AppointmentDetail rows matching every
+// condition in where, which bind args in order, sorted by sort. With after
+// set the rows start right after it, otherwise offset rows are skipped;
+// limit 0 reads every row. The slot and clinician are joined when slot is
+// set or the sort needs them, the patient when patient is set. A limited
+// query sorted by start time selects the slot's start last, for
+// collectPage.
+func detailListQuery(fields DetailFields, slot, patient bool, where []string, args []any, sort ListSort, after *pageKey, limit, offset int, param func(n int) string) (string, []any) {
+	// The sort columns and the key values a page token holds for them
+	cols := []string{"a.created_at", "a.id"}
+	key := func(k *pageKey) []any { return []any{k.At.UTC(), k.ID} }
+	switch sort.Field {
+	case SortStartTime:
+		cols = []string{"s.start_time", "a.id"}
+		slot = true
+	case SortStatus:
+		// Postgres would order its enum by declaration; as text both
+		// backends agree
+		cols = []string{"CAST(a.status AS TEXT)", "a.created_at", "a.id"}
+		key = func(k *pageKey) []any { return []any{k.Status, k.At.UTC(), k.ID} }
+	}
+	dir, cmp := " DESC", "<"
+	if sort.Asc {
+		dir, cmp = " ASC", ">"
+	}
+
+	if after != nil {
+		vals := key(after)
+		marks := make([]string, len(vals))
+		for i, v := range vals {
+			args = append(args, v)
+			marks[i] = param(len(args))
+		}
+		where = append(where, "("+strings.Join(cols, ", ")+") "+cmp+" ("+strings.Join(marks, ", ")+")")
+	}
+
+	var extra []string
+	if limit > 0 && sort.Field == SortStartTime {
+		extra = append(extra, "s.start_time")
+	}
+	var b strings.Builder
+	b.WriteString(detailSelectJoining(fields, slot || fields.Slot || fields.Clinician, patient || fields.Patient, extra...))
+	b.WriteString("\n\t\tWHERE " + strings.Join(where, "\n\t\t  AND "))
+	b.WriteString("\n\t\tORDER BY " + strings.Join(cols, dir+", ") + dir)
+	if limit > 0 {
+		args = append(args, limit)
+		b.WriteString("\n\t\tLIMIT " + param(len(args)))
+		if after == nil && offset > 0 {
+			args = append(args, offset)
+			b.WriteString(" OFFSET " + param(len(args)))
+		}
+	}
+	return b.String(), args
+}
+
+// detailRows is what collectPage reads of pgx.Rows and *sql.Rows
+type detailRows interface {
+	rowScanner
+	Next() bool
+	Err() error
+}
+
+// collectPage reads the rows of a detailListQuery that fetched limit+1 and
+// builds the page, with a NextToken when the extra row came back
+func collectPage(rows detailRows, fields DetailFields, sort ListSort, limit int) (*AppointmentPage, error) {
+	var result []AppointmentDetail
+	var starts []time.Time
+	for rows.Next() {
+		var start time.Time
+		var extra []any
+		if sort.Field == SortStartTime {
+			extra = append(extra, &start)
+		}
+		detail, err := scanAppointmentDetail(rows, fields, extra...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *detail)
+		starts = append(starts, start)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &AppointmentPage{Appointments: result}
+	if len(result) > limit {
+		page.Appointments = result[:limit]
+		last := page.Appointments[limit-1]
+		key := pageKey{At: last.CreatedAt, Status: string(last.Status), ID: last.ID}
+		if sort.Field == SortStartTime {
+			key.At = starts[limit-1]
+		}
+		page.NextToken = encodeSortedPageKey(sort, key)
+	}
+	return page, nil
+}
+
+// patientSearchQuery builds the query for one page of q, fetching one row
+// more than q.Limit, and returns the arguments it binds. Patients are
+// ordered by lower(name) and then id; after is the last patient of the
+// previous page. Its name is lowered by the database, as Go and SQLite
+// lower non-ASCII letters differently.
+func patientSearchQuery(q PatientSearch, after *patientPageKey, param func(n int) string) (string, []any) {
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return param(len(args))
+	}
+
+	pattern := "%" + escapeLike(strings.ToLower(q.Query)) + "%"
+	where := []string{
+		`(lower(name) LIKE ` + arg(pattern) + ` ESCAPE '\' OR lower(email) LIKE ` + arg(pattern) + ` ESCAPE '\')`,
+	}
+	if !q.IncludeDeactivated {
+		where = append(where, "deactivated_at IS NULL")
+	}
+	if after != nil {
+		where = append(where, "(lower(name) > lower("+arg(after.Name)+") OR (lower(name) = lower("+arg(after.Name)+") AND id > "+arg(after.ID)+"))")
+	}
+	return `
+		SELECT ` + patientColumns + `
+		FROM patients
+		WHERE ` + strings.Join(where, "\n\t\t  AND ") + `
+		ORDER BY lower(name), id
+		LIMIT ` + arg(q.Limit+1), args
+}
+
+// collectPatientPage reads the rows of patientSearchQuery into a page of
+// limit patients
+func collectPatientPage(rows detailRows, limit int) (*PatientPage, error) {
+	var result []Patient
+	for rows.Next() {
+		p, err := scanPatient(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &PatientPage{Patients: result}
+	if len(result) > limit {
+		page.Patients = result[:limit]
+		last := page.Patients[limit-1]
+		page.NextToken = encodePatientPageKey(patientPageKey{Name: last.Name, ID: last.ID})
+	}
+	return page, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s for a pattern with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// slotsByDayQuery counts a clinician's slots per day for n days. The days
+// are bound as a VALUES list of (index, start, end) so each backend computes
+// nothing zone-dependent, followed by the bookable range, the time holds
+// are live at and the clinician, in the order they appear. param renders the placeholder for the nth
+// parameter of the given SQL type.
+func slotsByDayQuery(n int, param func(n int, sqlType string) string) string {
+	rows := make([]string, n)
+	for i := range rows {
+		rows[i] = fmt.Sprintf("(%s, %s, %s)",
+			param(3*i+1, "int"), param(3*i+2, "timestamptz"), param(3*i+3, "timestamptz"))
+	}
+	return `
+		WITH days (idx, day_start, day_end) AS (
+			VALUES ` + strings.Join(rows, ",\n\t\t\t       ") + `
+		)
+		SELECT
+			d.idx,
+			count(s.id),
+			COALESCE(sum(CASE WHEN s.status = 'open' AND s.confirmed_count + s.held < s.capacity
+			                   AND s.start_time >= ` + param(3*n+1, "timestamptz") + `
+			                   AND s.start_time < ` + param(3*n+2, "timestamptz") + `
+			                  THEN 1 ELSE 0 END), 0),
+			COALESCE(sum(CASE WHEN s.confirmed_count + s.held >= s.capacity THEN 1 ELSE 0 END), 0)
+		FROM days d
+		LEFT JOIN ` + heldSlotsTable(param(3*n+3, "timestamptz")) + ` s
+			ON s.practitioner_id = ` + param(3*n+4, "uuid") + `
+			AND s.status <> 'deleted'
+			AND s.start_time >= d.day_start
+			AND s.start_time < d.day_end
+		GROUP BY d.idx
+		ORDER BY d.idx`
+}
+
+// slotsByDayArgs binds days, bookable, now and clinicianID for
+// slotsByDayQuery
+func slotsByDayArgs(clinicianID uuid.UUID, days []DayRange, bookable DayRange, now time.Time, toDB func(time.Time) time.Time) []any {
+	args := make([]any, 0, 3*len(days)+4)
+	for i, d := range days {
+		args = append(args, i, toDB(d.Start), toDB(d.End))
+	}
+	return append(args, toDB(bookable.Start), toDB(bookable.End), toDB(now), clinicianID)
+}
+
+// scanSlotsByDay reads the rows of slotsByDayQuery for n days
+func scanSlotsByDay(rows interface {
+	Next() bool
+	rowScanner
+	Err() error
+}, n int) ([]DayAvailability, error) {
+	result := make([]DayAvailability, n)
+	for rows.Next() {
+		var idx int
+		var d DayAvailability
+		if err := rows.Scan(&idx, &d.Slots, &d.Open, &d.Booked); err != nil {
+			return nil, fmt.Errorf("scan slots by day: %w", err)
+		}
+		if idx < 0 || idx >= n {
+			return nil, fmt.Errorf("scan slots by day: unexpected day %d", idx)
+		}
+		result[idx] = d
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// scanAppointmentDetail scans a row selected by detailSelect(fields), and
+// any extra columns after it into extra. Related entities left out of
+// fields stay nil.
+func scanAppointmentDetail(row rowScanner, fields DetailFields, extra ...any) (*AppointmentDetail, error) {
+	var a Appointment
+	var checkedInAt *time.Time
+	var reason *string
+	var slot AppointmentSlot
+	var patient Patient
+	var clinician Clinician
+
+	// Price fields, NULL when no price is configured
+	var priceAmount *int64
+	var priceCurrency *string
+
+	dest := []any{
+		&a.ID, &a.SlotID, &a.PatientID, &a.Status, &a.CreatedAt, &a.UpdatedAt, &a.ExpiresAt, &checkedInAt, &reason,
+	}
+	if fields.Slot {
+		dest = append(dest,
+			&slot.ID, &slot.PractitionerID, &slot.StartTime, &slot.EndTime, &slot.Status,
+			&slot.Capacity, &slot.SlotType, &slot.CreatedAt, &slot.UpdatedAt,
+		)
+	}
+	if fields.Patient {
+		dest = append(dest, &patient.ID, &patient.Name, &patient.Email, &patient.CreatedAt, &patient.UpdatedAt,
+			&patient.DeactivatedAt, &patient.DeactivatedBy, &patient.DeactivationReason, &patient.Phone)
+	}
+	if fields.Clinician {
+		dest = append(dest,
+			&clinician.ID, &clinician.Name, &clinician.Specialty, &clinician.ClinicID,
+			&clinician.CreatedAt, &clinician.UpdatedAt,
+		)
+	}
+	if fields.Slot {
+		dest = append(dest, &priceAmount, &priceCurrency)
+	}
+	dest = append(dest, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		if isNoRows(err) {
+			return nil, ErrAppointmentNotFound
+		}
+		return nil, err
+	}
+
+	// Validate that IDs match
+	if (fields.Slot && a.SlotID != slot.ID) ||
+		(fields.Patient && a.PatientID != patient.ID) ||
+		(fields.Slot && fields.Clinician && slot.PractitionerID != clinician.ID) {
+		return nil, fmt.Errorf("data integrity error: appointment/slot/patient/clinician IDs do not match")
+	}
+
+	detail := &AppointmentDetail{Appointment: a, CheckedInAt: checkedInAt, Reason: reason}
+	if fields.Slot {
+		detail.Slot = &slot
+		if priceAmount != nil && priceCurrency != nil {
+			detail.Price = &Price{AmountMinor: *priceAmount, Currency: *priceCurrency}
+		}
+	}
+	if fields.Patient {
+		detail.Patient = &patient
+	}
+	if fields.Clinician {
+		detail.Clinician = &clinician
+	}
+
+	return detail, nil
+}
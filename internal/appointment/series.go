@@ -0,0 +1,547 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// Series limits
+const (
+	MaxSeriesOccurrences  = 52
+	MaxSeriesIntervalDays = 365
+)
+
+// SeriesRequest describes a series to book: Occurrences appointments for
+// the patient, the first in FirstSlotID and each later one in the same
+// clinician's slot IntervalDays after the previous, at the same wall-clock
+// time in Location
+type SeriesRequest struct {
+	PatientID    uuid.UUID
+	FirstSlotID  uuid.UUID
+	IntervalDays int
+	Occurrences  int
+	Location     *time.Location // UTC when nil
+}
+
+// CreateSeries books every occurrence of a series or none of them. Each
+// occurrence is a pending hold like one made by CreateAppointment, confirmed
+// with ConfirmSeries or one by one. The holds are created in one transaction
+// under the locks of all their slots; the capacity CHECK still has the final
+// say when they are confirmed.
+func (s *Service) CreateSeries(ctx context.Context, req SeriesRequest) (*Series, error) {
+	if req.Occurrences < 2 || req.Occurrences > MaxSeriesOccurrences {
+		return nil, fmt.Errorf("%w: occurrences must be between 2 and %d", ErrInvalidSeries, MaxSeriesOccurrences)
+	}
+	if req.IntervalDays < 1 || req.IntervalDays > MaxSeriesIntervalDays {
+		return nil, fmt.Errorf("%w: interval_days must be between 1 and %d", ErrInvalidSeries, MaxSeriesIntervalDays)
+	}
+	loc := req.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	err := s.runStage(ctx, StagePatientLookup, func(ctx context.Context) error {
+		return s.checkBookablePatient(ctx, req.PatientID)
+	})
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) || errors.Is(err, ErrPatientDeactivated) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load patient: %w", err)
+	}
+
+	var first *AppointmentSlot
+	err = s.runStage(ctx, StageSlotLookup, func(ctx context.Context) error {
+		first, err = s.repo.GetSlotByID(ctx, req.FirstSlotID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load slot: %w", err)
+	}
+	if first.Status != SlotOpen {
+		return nil, ErrSlotNotOpen
+	}
+
+	starts := make([]time.Time, req.Occurrences)
+	for i := range starts {
+		starts[i] = first.StartTime.In(loc).AddDate(0, 0, i*req.IntervalDays)
+	}
+	slots, err := s.seriesSlots(ctx, first.PractitionerID, starts)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBookableSlots(ctx, slots...); err != nil {
+		return nil, err
+	}
+
+	var created *Series
+	expiresAt := s.clock.Now().Add(s.cfg.AppointmentTTL)
+
+	err = s.runStage(ctx, StageLockSection, func(ctx context.Context) error {
+		return s.withSlotLocks(ctx, slots, func(ctx context.Context) error {
+			return s.repo.WithTx(ctx, func(tx Repository) error {
+				if err := s.checkSeriesCapacity(ctx, tx, slots, 1); err != nil {
+					return err
+				}
+				series, err := tx.CreateSeries(ctx, Series{
+					ID:           uuid.New(),
+					PatientID:    req.PatientID,
+					ClinicianID:  first.PractitionerID,
+					IntervalDays: req.IntervalDays,
+					TimeZone:     loc.String(),
+				})
+				if err != nil {
+					return err
+				}
+				for i, slot := range slots {
+					appt, err := tx.CreatePendingAppointment(ctx, slot.ID, req.PatientID, expiresAt)
+					if err != nil {
+						return fmt.Errorf("create occurrence %d: %w", i+1, err)
+					}
+					if err := tx.AddSeriesOccurrence(ctx, series.ID, i+1, appt.ID); err != nil {
+						return err
+					}
+					series.Occurrences = append(series.Occurrences, SeriesOccurrence{
+						Number: i + 1, Appointment: *appt, Slot: *slot,
+					})
+				}
+				created = series
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, lockError(err)
+	}
+
+	for _, occ := range created.Occurrences {
+		s.logEvent(ctx, occ.Appointment.ID, EventAppointmentCreated, map[string]any{
+			"slot_id":    occ.Slot.ID.String(),
+			"patient_id": req.PatientID.String(),
+			"expires_at": expiresAt,
+			"series_id":  created.ID.String(),
+			"occurrence": occ.Number,
+		})
+	}
+	return created, nil
+}
+
+// seriesSlots finds the clinician's open slot starting at each of starts
+func (s *Service) seriesSlots(ctx context.Context, clinicianID uuid.UUID, starts []time.Time) ([]*AppointmentSlot, error) {
+	var found []AppointmentSlot
+	err := s.runStage(ctx, StageSlotLookup, func(ctx context.Context) (err error) {
+		found, err = s.repo.ListClinicianSlotsAt(ctx, clinicianID, starts)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find series slots: %w", err)
+	}
+
+	slots := make([]*AppointmentSlot, len(starts))
+	for i, start := range starts {
+		for j := range found {
+			if found[j].Status == SlotOpen && found[j].StartTime.Equal(start) {
+				slots[i] = &found[j]
+				break
+			}
+		}
+		if slots[i] == nil {
+			return nil, fmt.Errorf("%w: occurrence %d at %s", ErrSeriesSlotUnavailable, i+1, start.Format(time.RFC3339))
+		}
+	}
+	return slots, nil
+}
+
+// checkSeriesCapacity fails when a slot's places are already taken by
+// confirmed appointments and live holds. Occurrences are numbered from first.
+// Like checkSlotRoom it is counted through the transaction that books them.
+func (s *Service) checkSeriesCapacity(ctx context.Context, tx Repository, slots []*AppointmentSlot, first int) error {
+	for i, slot := range slots {
+		if err := s.checkSlotRoom(ctx, tx, slot); err != nil {
+			return fmt.Errorf("occurrence %d: %w", first+i, err)
+		}
+	}
+	return nil
+}
+
+// withSlotLocks holds the locks of every slot while fn runs. Unlike
+// withSlotLock it takes them exclusively whatever the capacity.
+func (s *Service) withSlotLocks(ctx context.Context, slots []*AppointmentSlot, fn func(ctx context.Context) error) error {
+	keys := make([]string, 0, len(slots))
+	for _, slot := range slots {
+		key := redisclient.SlotKey(slot.ID)
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return s.locker.WithLock(ctx, keys, fn)
+}
+
+// GetSeries returns a series with the current appointment of each occurrence
+func (s *Service) GetSeries(ctx context.Context, id uuid.UUID) (*Series, error) {
+	series, err := s.repo.GetSeries(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get series: %w", err)
+	}
+	return series, nil
+}
+
+// ConfirmSeries confirms every pending occurrence of a series in one
+// transaction, or moves them to pending_approval when the clinic triages
+// bookings. If any hold has passed or any slot is full, none is confirmed.
+func (s *Service) ConfirmSeries(ctx context.Context, id uuid.UUID) (*Series, error) {
+	series, err := s.GetSeries(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []SeriesOccurrence
+	for _, occ := range series.Occurrences {
+		if occ.Appointment.Status == StatusPending {
+			pending = append(pending, occ)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, fmt.Errorf("%w: series has no pending occurrences", ErrInvalidStatusTransition)
+	}
+
+	// Every occurrence is with the series clinician, so one clinic decides
+	approval, err := s.repo.SlotRequiresApproval(ctx, pending[0].Slot.ID)
+	if err != nil {
+		return nil, fmt.Errorf("load clinic: %w", err)
+	}
+
+	now := s.clock.Now()
+	deadline := now.Add(s.cfg.ApprovalWindow)
+	err = s.runStage(ctx, StageStatusUpdate, func(ctx context.Context) error {
+		return s.repo.WithTx(ctx, func(tx Repository) error {
+			for _, occ := range pending {
+				var err error
+				if approval {
+					_, err = tx.RequestApproval(ctx, occ.Appointment.ID, now, deadline)
+				} else {
+					_, err = tx.ResolvePendingAppointment(ctx, occ.Appointment.ID, StatusConfirmed, now)
+				}
+				if errors.Is(err, ErrAppointmentNotFound) {
+					// the hold passed or the occurrence changed since it was read
+					err = ErrAppointmentExpiredState
+				}
+				if err != nil {
+					return fmt.Errorf("occurrence %d: %w", occ.Number, err)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("confirm series: %w", err)
+	}
+
+	for _, occ := range pending {
+		if approval {
+			s.logEvent(ctx, occ.Appointment.ID, EventAppointmentApprovalRequested, map[string]any{
+				"approval_deadline": deadline,
+				"series_id":         series.ID.String(),
+				"occurrence":        occ.Number,
+			})
+		} else {
+			s.logEvent(ctx, occ.Appointment.ID, EventAppointmentConfirmed, map[string]any{
+				"series_id":  series.ID.String(),
+				"occurrence": occ.Number,
+			})
+		}
+	}
+	return s.GetSeries(ctx, id)
+}
+
+// CancelSeries cancels every active occurrence of a series whose slot has
+// not started yet, except those numbered in except. An occurrence resolved
+// in the meantime is skipped.
+func (s *Service) CancelSeries(ctx context.Context, id uuid.UUID, reason string, except []int) (*Series, error) {
+	series, err := s.GetSeries(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOccurrenceNumbers(series, except); err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	for _, occ := range series.Occurrences {
+		if slices.Contains(except, occ.Number) || !occ.Slot.StartTime.After(now) || !occ.Appointment.Status.Active() {
+			continue
+		}
+		_, err := s.CancelAppointment(ctx, occ.Appointment.ID, reason, map[string]any{
+			"series_id":  series.ID.String(),
+			"occurrence": occ.Number,
+		})
+		if err != nil && !errors.Is(err, ErrAppointmentNotActive) {
+			return nil, fmt.Errorf("cancel occurrence %d: %w", occ.Number, err)
+		}
+	}
+	return s.GetSeries(ctx, id)
+}
+
+// CancelOccurrence cancels one occurrence and marks it an exception
+func (s *Service) CancelOccurrence(ctx context.Context, id uuid.UUID, number int, reason string) (*Series, error) {
+	series, err := s.GetSeries(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	occ, err := findOccurrence(series, number)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.CancelAppointment(ctx, occ.Appointment.ID, reason, map[string]any{
+		"series_id":  series.ID.String(),
+		"occurrence": occ.Number,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.MoveSeriesOccurrence(ctx, series.ID, number, occ.Appointment.ID, true); err != nil {
+		return nil, fmt.Errorf("mark occurrence: %w", err)
+	}
+	return s.GetSeries(ctx, id)
+}
+
+// RescheduleSeries moves every active occurrence whose slot has not started
+// yet by shift, keeping the wall-clock time in the series zone for whole
+// days. Exceptions and the occurrences numbered in except stay where they
+// are. All occurrences move or none does.
+func (s *Service) RescheduleSeries(ctx context.Context, id uuid.UUID, shift time.Duration, except []int) (*Series, error) {
+	if shift == 0 {
+		return nil, fmt.Errorf("%w: shift must not be zero", ErrInvalidSeries)
+	}
+	series, err := s.GetSeries(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOccurrenceNumbers(series, except); err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(series.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("series time zone: %w", err)
+	}
+
+	now := s.clock.Now()
+	var moving []SeriesOccurrence
+	var starts []time.Time
+	for _, occ := range series.Occurrences {
+		if occ.Exception || slices.Contains(except, occ.Number) ||
+			!occ.Slot.StartTime.After(now) || !occ.Appointment.Status.Active() {
+			continue
+		}
+		moving = append(moving, occ)
+		starts = append(starts, shiftWallClock(occ.Slot.StartTime, shift, loc))
+	}
+	if len(moving) == 0 {
+		return nil, fmt.Errorf("%w: no occurrence to reschedule", ErrInvalidSeries)
+	}
+
+	slots, err := s.seriesSlots(ctx, series.ClinicianID, starts)
+	if err != nil {
+		return nil, err
+	}
+	moves := make([]occurrenceMove, len(moving))
+	for i := range moving {
+		moves[i] = occurrenceMove{occ: moving[i], to: slots[i]}
+	}
+	if err := s.moveOccurrences(ctx, series, moves, false); err != nil {
+		return nil, err
+	}
+	return s.GetSeries(ctx, id)
+}
+
+// RescheduleOccurrence moves one active occurrence to another open slot of
+// the series clinician and marks it an exception, so later series
+// reschedules leave it alone
+func (s *Service) RescheduleOccurrence(ctx context.Context, id uuid.UUID, number int, slotID uuid.UUID) (*Series, error) {
+	series, err := s.GetSeries(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	occ, err := findOccurrence(series, number)
+	if err != nil {
+		return nil, err
+	}
+	if !occ.Appointment.Status.Active() {
+		return nil, ErrAppointmentNotActive
+	}
+
+	slot, err := s.repo.GetSlotByID(ctx, slotID)
+	if err != nil {
+		return nil, fmt.Errorf("load slot: %w", err)
+	}
+	if slot.Status != SlotOpen {
+		return nil, ErrSlotNotOpen
+	}
+	if slot.PractitionerID != series.ClinicianID {
+		return nil, fmt.Errorf("%w: slot belongs to another clinician", ErrSeriesSlotUnavailable)
+	}
+
+	if err := s.moveOccurrences(ctx, series, []occurrenceMove{{occ: *occ, to: slot}}, true); err != nil {
+		return nil, err
+	}
+	return s.GetSeries(ctx, id)
+}
+
+// occurrenceMove is an occurrence and the slot it moves to
+type occurrenceMove struct {
+	occ SeriesOccurrence
+	to  *AppointmentSlot
+}
+
+// moveOccurrences books each occurrence into its new slot and cancels its
+// old appointment, all in one transaction under the locks of every slot
+// involved. The new appointment takes over the old one's status: a
+// confirmed occurrence is confirmed again, subject to the new slot's
+// capacity, and a hold or a booking awaiting approval keeps its deadline.
+func (s *Service) moveOccurrences(ctx context.Context, series *Series, moves []occurrenceMove, exception bool) error {
+	slots := make([]*AppointmentSlot, 0, 2*len(moves))
+	targets := make([]*AppointmentSlot, len(moves))
+	for i, m := range moves {
+		slots = append(slots, &moves[i].occ.Slot, m.to)
+		targets[i] = m.to
+	}
+
+	if err := s.checkBookableSlots(ctx, targets...); err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+	moved := make([]*Appointment, len(moves))
+	err := s.runStage(ctx, StageLockSection, func(ctx context.Context) error {
+		return s.withSlotLocks(ctx, slots, func(ctx context.Context) error {
+			return s.repo.WithTx(ctx, func(tx Repository) error {
+				for i, m := range moves {
+					if err := s.checkSeriesCapacity(ctx, tx, targets[i:i+1], m.occ.Number); err != nil {
+						return err
+					}
+				}
+				for i, m := range moves {
+					appt, _, err := s.moveAppointment(ctx, tx, m.occ.Appointment, m.to.ID, now)
+					if err != nil {
+						return fmt.Errorf("occurrence %d: %w", m.occ.Number, err)
+					}
+					if err := tx.MoveSeriesOccurrence(ctx, series.ID, m.occ.Number, appt.ID, exception); err != nil {
+						return err
+					}
+					moved[i] = appt
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		if mapped := lockError(err); mapped != err {
+			return mapped
+		}
+		return fmt.Errorf("reschedule series: %w", err)
+	}
+
+	for i, m := range moves {
+		old, appt := m.occ.Appointment, moved[i]
+		s.logEvent(ctx, old.ID, EventAppointmentCancelled, map[string]any{
+			"reason":          "rescheduled",
+			"previous_status": string(old.Status),
+			"series_id":       series.ID.String(),
+			"occurrence":      m.occ.Number,
+			"rescheduled_to":  appt.ID.String(),
+		})
+		s.logEvent(ctx, appt.ID, EventAppointmentCreated, map[string]any{
+			"slot_id":          m.to.ID.String(),
+			"patient_id":       appt.PatientID.String(),
+			"series_id":        series.ID.String(),
+			"occurrence":       m.occ.Number,
+			"rescheduled_from": old.ID.String(),
+		})
+		switch appt.Status {
+		case StatusConfirmed:
+			s.logEvent(ctx, appt.ID, EventAppointmentConfirmed, map[string]any{
+				"series_id":  series.ID.String(),
+				"occurrence": m.occ.Number,
+			})
+		case StatusPendingApproval:
+			s.logEvent(ctx, appt.ID, EventAppointmentApprovalRequested, map[string]any{
+				"approval_deadline": appt.ExpiresAt,
+				"series_id":         series.ID.String(),
+				"occurrence":        m.occ.Number,
+			})
+		}
+	}
+	return nil
+}
+
+// moveAppointment creates the replacement of old in slotID with old's
+// status and cancels old, inside tx. It returns the replacement and old as
+// cancelled.
+func (s *Service) moveAppointment(ctx context.Context, tx Repository, old Appointment, slotID uuid.UUID, now time.Time) (*Appointment, *Appointment, error) {
+	hold := now.Add(s.cfg.AppointmentTTL)
+	if old.Status != StatusConfirmed {
+		if old.ExpiresAt == nil || old.ExpiresAt.Before(now) {
+			return nil, nil, ErrAppointmentExpiredState
+		}
+		if old.Status == StatusPending {
+			hold = *old.ExpiresAt
+		}
+	}
+
+	appt, err := tx.CreatePendingAppointment(ctx, slotID, old.PatientID, hold)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create appointment: %w", err)
+	}
+	switch old.Status {
+	case StatusConfirmed:
+		appt, err = tx.ResolvePendingAppointment(ctx, appt.ID, StatusConfirmed, now)
+	case StatusPendingApproval:
+		appt, err = tx.RequestApproval(ctx, appt.ID, now, *old.ExpiresAt)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cancelled, err := tx.UpdateAppointmentStatus(ctx, old.ID, old.Status, StatusCancelled)
+	if err != nil {
+		if errors.Is(err, ErrAppointmentNotFound) {
+			// status changed since it was read
+			return nil, nil, ErrAppointmentNotActive
+		}
+		return nil, nil, fmt.Errorf("cancel appointment: %w", err)
+	}
+	return appt, cancelled, nil
+}
+
+// shiftWallClock moves t by d, counting whole days of d in loc so the time
+// of day stays the same across a DST change
+func shiftWallClock(t time.Time, d time.Duration, loc *time.Location) time.Time {
+	days := int(d / (24 * time.Hour))
+	return t.In(loc).AddDate(0, 0, days).Add(d - time.Duration(days)*24*time.Hour)
+}
+
+func findOccurrence(series *Series, number int) (*SeriesOccurrence, error) {
+	for i := range series.Occurrences {
+		if series.Occurrences[i].Number == number {
+			return &series.Occurrences[i], nil
+		}
+	}
+	return nil, ErrOccurrenceNotFound
+}
+
+func checkOccurrenceNumbers(series *Series, numbers []int) error {
+	for _, n := range numbers {
+		if _, err := findOccurrence(series, n); err != nil {
+			return fmt.Errorf("%w: %d", err, n)
+		}
+	}
+	return nil
+}
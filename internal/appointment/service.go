@@ -6,11 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/hackgods/distributed-appointment-scheduling/internal/blob"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/clock"
 	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/pagination"
 	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
 )
 
@@ -18,156 +23,372 @@ const (
 	EventAppointmentCreated   = "APPOINTMENT_CREATED"
 	EventAppointmentConfirmed = "APPOINTMENT_CONFIRMED"
 	EventAppointmentExpired   = "APPOINTMENT_EXPIRED"
-)
+	EventAppointmentCancelled = "APPOINTMENT_CANCELLED"
+
+	// EventAppointmentApprovalRequested is logged instead of CONFIRMED when
+	// the clinic triages bookings. Approval logs CONFIRMED.
+	EventAppointmentApprovalRequested = "APPOINTMENT_APPROVAL_REQUESTED"
+	EventAppointmentRejected          = "APPOINTMENT_REJECTED"
 
-var (
-	ErrSlotAlreadyBooked       = errors.New("slot already has a confirmed appointment")
-	ErrSlotBeingBooked         = errors.New("slot is currently being booked, please retry")
-	ErrAppointmentExpiredState = errors.New("appointment is already expired")
-	ErrInvalidStatusTransition = errors.New("invalid status transition")
-	ErrSlotNotOpen             = errors.New("slot is not open")
+	// EventAttachmentAdded is logged when a file is attached to an
+	// appointment. It does not change its status.
+	EventAttachmentAdded = "APPOINTMENT_ATTACHMENT_ADDED"
+
+	// EventLockForceReleased audits an operator breaking a lock. It has no
+	// appointment and is not published.
+	EventLockForceReleased = "LOCK_FORCE_RELEASED"
 )
 
 type Service struct {
-	repo   Repository
-	locker redisclient.Locker
-	cfg    config.Config
+	repo       Repository
+	locker     redisclient.Locker
+	cfg        config.Config
+	publishers []EventPublisher
+	budgets    map[string]time.Duration
+	clock      clock.Clock
+	blobs      blob.Store
+	scanner    Scanner
+	queue      redisclient.BookingQueue
+	tenancy    ShardTenancy
+	patients   *lookupCache[Patient]
+	clinicians *lookupCache[Clinician]
+	reads      readCoalescer
+	pages      *pagination.Codec
+}
+
+// EventPublisher hands appointment events to downstream consumers such as
+// webhook delivery. Publish should only enqueue; it runs on the request path.
+type EventPublisher interface {
+	Publish(ctx context.Context, eventType string, appointmentID uuid.UUID, data map[string]any) error
+}
+
+// Option configures optional Service dependencies
+type Option func(*Service)
+
+// WithEventPublisher publishes every logged event to p. It can be given
+// more than once; publishers are called in the order given.
+func WithEventPublisher(p EventPublisher) Option {
+	return func(s *Service) { s.publishers = append(s.publishers, p) }
+}
+
+// WithClock makes hold expiry follow c instead of the wall clock
+func WithClock(c clock.Clock) Option {
+	return func(s *Service) { s.clock = c }
 }
 
-func NewService(repo Repository, locker redisclient.Locker, cfg config.Config) *Service {
-	return &Service{
-		repo:   repo,
-		locker: locker,
-		cfg:    cfg,
+func NewService(repo Repository, locker redisclient.Locker, cfg config.Config, opts ...Option) *Service {
+	s := &Service{
+		repo:    repo,
+		locker:  locker,
+		cfg:     cfg,
+		budgets: budgets(cfg.StageBudgets),
+		clock:   clock.Real(),
+		pages:   pagination.New(cfg.PageTokenSecret),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.patients = newLookupCache[Patient]("patient", cfg.LookupCacheTTL, s.clock)
+	s.clinicians = newLookupCache[Clinician]("clinician", cfg.LookupCacheTTL, s.clock)
+	return s
 }
 
 // CreateAppointment tries to reserve a slot for a patient.
 // It uses a distributed lock so that concurrent requests for the same slot
-// cannot both create a pending appointment.
+// cannot both create a pending appointment. With a booking queue, attempts
+// over the clinic's rate return a *QueuedError instead.
 func (s *Service) CreateAppointment(ctx context.Context, slotID, patientID uuid.UUID) (*Appointment, error) {
-	// Validate patient exists
-	if _, err := s.repo.GetPatientByID(ctx, patientID); err != nil {
-		if errors.Is(err, ErrPatientNotFound) {
+	// Validate patient exists and may book
+	err := s.runStage(ctx, StagePatientLookup, func(ctx context.Context) error {
+		return s.checkBookablePatient(ctx, patientID)
+	})
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) || errors.Is(err, ErrPatientDeactivated) {
 			return nil, err
 		}
 		return nil, fmt.Errorf("load patient: %w", err)
 	}
 
 	// Validate slot exists and is open
-	slot, err := s.repo.GetSlotByID(ctx, slotID)
+	var slot *AppointmentSlot
+	err = s.runStage(ctx, StageSlotLookup, func(ctx context.Context) error {
+		slot, err = s.repo.GetSlotByID(ctx, slotID)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("load slot: %w", err)
 	}
 	if slot.Status != SlotOpen {
 		return nil, ErrSlotNotOpen
 	}
+	if err := s.checkBookableSlots(ctx, slot); err != nil {
+		return nil, err
+	}
+	if err := s.admitBooking(ctx, slot); err != nil {
+		return nil, err
+	}
+
+	// Journal the attempt before taking the lock so a crash between here and
+	// the commit can be reconciled on the next startup.
+	intent := BookingIntent{
+		ID:        uuid.New(),
+		SlotID:    slotID,
+		PatientID: patientID,
+		LockToken: uuid.NewString(),
+		State:     IntentPending,
+	}
+	err = s.runStage(ctx, StageJournal, func(ctx context.Context) error {
+		return s.repo.CreateBookingIntent(ctx, intent)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("journal booking intent: %w", err)
+	}
 
 	var created *Appointment
+	expiresAt := s.clock.Now().Add(s.cfg.AppointmentTTL)
+
+	err = s.runStage(ctx, StageLockSection, func(ctx context.Context) error {
+		lockCtx := redisclient.WithLockToken(ctx, intent.LockToken)
+		return s.withSlotLock(lockCtx, slot, func(lockCtx context.Context) error {
+			return s.repo.WithTx(lockCtx, func(tx Repository) error {
+				// Re-check the places taken inside the critical section and
+				// the transaction; the capacity CHECK on the slot has the
+				// final say at confirm time
+				if err := s.checkSlotRoom(lockCtx, tx, slot); err != nil {
+					return err
+				}
+				appt, err := tx.CreatePendingAppointment(lockCtx, slotID, patientID, expiresAt)
+				if err != nil {
+					return fmt.Errorf("create pending appointment: %w", err)
+				}
+				if err := tx.ResolveBookingIntent(lockCtx, intent.ID, IntentCommitted, &appt.ID); err != nil {
+					return fmt.Errorf("commit booking intent: %w", err)
+				}
+				created = appt
+				return nil
+			})
+		})
+	})
 
-	err = s.locker.WithSlotLock(ctx, slotID, func(lockCtx context.Context) error {
-		// Inside the critical section re-check for confirmed appointment for this slot
-		existing, err := s.repo.GetConfirmedAppointmentForSlot(lockCtx, slotID)
-		if err != nil && !errors.Is(err, ErrAppointmentNotFound) {
-			return fmt.Errorf("check confirmed appointment: %w", err)
-		}
-		if existing != nil {
-			return ErrSlotAlreadyBooked
-		}
+	if err != nil {
+		s.abortIntent(ctx, intent.ID)
+		return nil, lockError(err)
+	}
+	journalIntents.Inc(string(IntentCommitted))
+
+	// Written after the lock is released so it does not lengthen the
+	// critical section
+	s.logEvent(ctx, created.ID, EventAppointmentCreated, map[string]any{
+		"slot_id":    slotID.String(),
+		"patient_id": patientID.String(),
+		"expires_at": expiresAt,
+	})
 
-		expiresAt := time.Now().Add(s.cfg.AppointmentTTL)
-		appt, err := s.repo.CreatePendingAppointment(lockCtx, slotID, patientID, expiresAt)
-		if err != nil {
-			return fmt.Errorf("create pending appointment: %w", err)
-		}
+	return created, nil
+}
 
-		created = appt
+// withSlotLock serializes bookings for a slot. A slot with capacity above
+// one admits up to capacity bookings at once when the locker supports it.
+func (s *Service) withSlotLock(ctx context.Context, slot *AppointmentSlot, fn func(ctx context.Context) error) error {
+	if sem, ok := s.locker.(redisclient.Semaphore); ok && slot.Capacity > 1 {
+		return sem.WithPermit(ctx, redisclient.SlotKey(slot.ID), slot.Capacity, fn)
+	}
+	return redisclient.WithSlotLock(ctx, s.locker, slot.ID, fn)
+}
 
-		payload := map[string]any{
-			"slot_id":    slotID.String(),
-			"patient_id": patientID.String(),
-			"expires_at": expiresAt,
+// ConfirmAppointment moves a pending appointment to confirmed, or to
+// pending_approval when its clinic triages bookings. When expected is given
+// the appointment must currently have one of those statuses, checked against
+// the read and again if the update loses a race, otherwise
+// ErrPreconditionFailed is returned.
+func (s *Service) ConfirmAppointment(ctx context.Context, id uuid.UUID, expected ...AppointmentStatus) (*Appointment, error) {
+	var appt *Appointment
+	var approval bool
+	err := s.runStage(ctx, StageAppointment, func(ctx context.Context) (err error) {
+		appt, err = s.repo.GetAppointmentByID(ctx, id)
+		if err != nil || appt.Status != StatusPending {
+			return err
 		}
-		s.logEvent(lockCtx, appt.ID, EventAppointmentCreated, payload)
-
-		return nil
+		approval, err = s.repo.SlotRequiresApproval(ctx, appt.SlotID)
+		return err
 	})
-
 	if err != nil {
-		if errors.Is(err, redisclient.ErrLockNotAcquired) {
-			return nil, ErrSlotBeingBooked
+		return nil, fmt.Errorf("load appointment: %w", err)
+	}
+
+	if len(expected) > 0 && !slices.Contains(expected, appt.Status) {
+		return nil, ErrPreconditionFailed
+	}
+
+	switch appt.Status {
+	case StatusPending:
+	case StatusExpired:
+		return nil, ErrAppointmentExpiredState
+	default:
+		return nil, ErrInvalidStatusTransition
+	}
+
+	// The deadline is checked by the update itself, so the confirm and the
+	// expiry worker cannot both win: a hold is confirmable up to and
+	// including expires_at and expirable only after it.
+	now := s.clock.Now()
+	deadline := now.Add(s.cfg.ApprovalWindow)
+	var updated *Appointment
+	err = s.runStage(ctx, StageStatusUpdate, func(ctx context.Context) (err error) {
+		if approval {
+			updated, err = s.repo.RequestApproval(ctx, appt.ID, now, deadline)
+		} else {
+			updated, err = s.repo.ResolvePendingAppointment(ctx, appt.ID, StatusConfirmed, now)
 		}
-		if errors.Is(err, ErrSlotAlreadyBooked) {
-			return nil, err
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrAppointmentNotFound) {
+			return nil, s.confirmLost(ctx, appt.ID, now, expected)
 		}
-		return nil, err
+		return nil, fmt.Errorf("confirm appointment: %w", err)
 	}
 
-	return created, nil
+	if approval {
+		s.logEvent(ctx, updated.ID, EventAppointmentApprovalRequested, map[string]any{
+			"approval_deadline": deadline,
+		})
+		return updated, nil
+	}
+	s.logEvent(ctx, updated.ID, EventAppointmentConfirmed, map[string]any{})
+
+	return updated, nil
 }
 
-// ConfirmAppointment moves a pending appointment to confirmed
-func (s *Service) ConfirmAppointment(ctx context.Context, id uuid.UUID) (*Appointment, error) {
+// confirmLost explains a confirm whose update matched nothing by re-reading
+// the appointment. A hold still pending has passed its deadline, so it is
+// expired here; the EXPIRED event is only logged by whoever expires it.
+func (s *Service) confirmLost(ctx context.Context, id uuid.UUID, now time.Time, expected []AppointmentStatus) error {
 	appt, err := s.repo.GetAppointmentByID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("load appointment: %w", err)
+		return fmt.Errorf("reload appointment: %w", err)
 	}
 
-	now := time.Now()
+	if appt.Status == StatusPending {
+		_, err := s.repo.ResolvePendingAppointment(ctx, id, StatusExpired, now)
+		switch {
+		case err == nil:
+			s.logEvent(ctx, id, EventAppointmentExpired, map[string]any{
+				"reason": "confirm_after_expiry",
+			})
+			return ErrAppointmentExpiredState
+		case errors.Is(err, ErrAppointmentNotFound):
+			// resolved by someone else in between
+			return s.confirmLost(ctx, id, now, expected)
+		default:
+			return fmt.Errorf("expire appointment: %w", err)
+		}
+	}
 
+	if len(expected) > 0 && !slices.Contains(expected, appt.Status) {
+		return ErrPreconditionFailed
+	}
 	if appt.Status == StatusExpired {
-		return nil, ErrAppointmentExpiredState
+		return ErrAppointmentExpiredState
 	}
+	return ErrInvalidStatusTransition
+}
 
-	if appt.ExpiresAt != nil && appt.ExpiresAt.Before(now) {
-		// Try to mark it as expired if still pending
-		_, updErr := s.repo.UpdateAppointmentStatus(ctx, appt.ID, StatusPending, StatusExpired)
-		if updErr != nil && !errors.Is(updErr, ErrAppointmentNotFound) {
-			log.Printf("failed to mark appointment %s as expired during confirm: %v", appt.ID, updErr)
-		}
-		s.logEvent(ctx, appt.ID, EventAppointmentExpired, map[string]any{
-			"reason": "confirm_after_expiry",
-		})
-		return nil, ErrAppointmentExpiredState
+// CancelAppointment cancels a pending, awaiting approval or confirmed
+// appointment. reason and details are recorded on the APPOINTMENT_CANCELLED
+// event.
+func (s *Service) CancelAppointment(ctx context.Context, id uuid.UUID, reason string, details map[string]any) (*Appointment, error) {
+	appt, err := s.repo.GetAppointmentByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("load appointment: %w", err)
 	}
-
-	if appt.Status != StatusPending {
-		return nil, ErrInvalidStatusTransition
+	if !appt.Status.Active() {
+		return nil, ErrAppointmentNotActive
 	}
 
-	updated, err := s.repo.UpdateAppointmentStatus(ctx, appt.ID, StatusPending, StatusConfirmed)
+	updated, err := s.repo.UpdateAppointmentStatus(ctx, appt.ID, appt.Status, StatusCancelled)
 	if err != nil {
-		return nil, fmt.Errorf("confirm appointment: %w", err)
+		if errors.Is(err, ErrAppointmentNotFound) {
+			// status changed since it was read
+			return nil, ErrAppointmentNotActive
+		}
+		return nil, fmt.Errorf("cancel appointment: %w", err)
 	}
 
-	s.logEvent(ctx, updated.ID, EventAppointmentConfirmed, map[string]any{})
+	payload := map[string]any{
+		"reason":          reason,
+		"previous_status": string(appt.Status),
+	}
+	for k, v := range details {
+		payload[k] = v
+	}
+	s.logEvent(ctx, updated.ID, EventAppointmentCancelled, payload)
 
 	return updated, nil
 }
 
-// ExpirePendingAppointments is intended to be called by the worker periodically
-func (s *Service) ExpirePendingAppointments(ctx context.Context) error {
-	now := time.Now()
+// ExpiryResult reports how far one expiry run got
+type ExpiryResult struct {
+	Expired   int
+	Remaining int  // candidates left unprocessed because the run was stopped
+	Stopped   bool // stop was closed before every batch was processed
+}
+
+// ExpirePendingAppointments is intended to be called by the worker periodically.
+// Candidates are processed in batches of cfg.ExpiryBatchSize; once stop is
+// closed the current batch is finished and the rest is left for the next run.
+// Each expiry commits on its own, so stopping never loses finished work.
+// stop may be nil.
+func (s *Service) ExpirePendingAppointments(ctx context.Context, stop <-chan struct{}) (ExpiryResult, error) {
+	var res ExpiryResult
+
+	now := s.clock.Now()
 	expiredCandidates, err := s.repo.FindExpiredPending(ctx, now)
 	if err != nil {
-		return fmt.Errorf("find expired pending appointments: %w", err)
+		return res, fmt.Errorf("find expired pending appointments: %w", err)
+	}
+
+	batchSize := s.cfg.ExpiryBatchSize
+	if batchSize <= 0 {
+		batchSize = len(expiredCandidates)
 	}
 
-	for _, appt := range expiredCandidates {
-		_, err := s.repo.UpdateAppointmentStatus(ctx, appt.ID, StatusPending, StatusExpired)
-		if err != nil && !errors.Is(err, ErrAppointmentNotFound) {
-			log.Printf("failed to expire appointment %s: %v", appt.ID, err)
-			continue
+	for start := 0; start < len(expiredCandidates); start += batchSize {
+		select {
+		case <-stop:
+			res.Stopped = true
+			res.Remaining = len(expiredCandidates) - start
+			return res, nil
+		default:
+		}
+
+		end := min(start+batchSize, len(expiredCandidates))
+		for _, appt := range expiredCandidates[start:end] {
+			_, err := s.repo.ResolvePendingAppointment(ctx, appt.ID, StatusExpired, now)
+			if err != nil {
+				// not found means a confirm or cancel got there first
+				if !errors.Is(err, ErrAppointmentNotFound) {
+					log.Printf("failed to expire appointment %s: %v", appt.ID, err)
+				}
+				continue
+			}
+			res.Expired++
+			s.logEvent(ctx, appt.ID, EventAppointmentExpired, map[string]any{
+				"reason": "worker",
+			})
 		}
-		s.logEvent(ctx, appt.ID, EventAppointmentExpired, map[string]any{
-			"reason": "worker",
-		})
 	}
 
-	return nil
+	return res, nil
 }
 
+// logEvent records an event after the change it describes has committed.
+// It runs detached from the caller's cancellation, bounded by the event write
+// budget, so a client disconnecting right after a commit does not lose the
+// event or its notifications.
 func (s *Service) logEvent(ctx context.Context, appointmentID uuid.UUID, eventType string, payload map[string]any) {
+	s.reads.invalidate()
+
 	data, err := json.Marshal(payload)
 	if err != nil {
 		log.Printf("failed to marshal event payload for %s: %v", eventType, err)
@@ -180,47 +401,340 @@ func (s *Service) logEvent(ctx context.Context, appointmentID uuid.UUID, eventTy
 		EventType:     eventType,
 		AppointmentID: &apptID,
 		Payload:       data,
-		CreatedAt:     time.Now(),
+		CreatedAt:     s.clock.Now(),
 	}
 
-	if err := s.repo.InsertEvent(ctx, ev); err != nil {
-		log.Printf("failed to insert event log %s for appointment %s: %v", eventType, appointmentID, err)
-	}
+	_ = s.runStage(context.WithoutCancel(ctx), StageEventWrite, func(ctx context.Context) error {
+		if err := s.repo.InsertEvent(ctx, ev); err != nil {
+			log.Printf("failed to insert event log %s for appointment %s: %v", eventType, appointmentID, err)
+		}
+		s.countFunnel(ctx, appointmentID, eventType)
+
+		for _, p := range s.publishers {
+			if err := p.Publish(ctx, eventType, appointmentID, payload); err != nil {
+				log.Printf("failed to publish event %s for appointment %s: %v", eventType, appointmentID, err)
+			}
+		}
+		return ctx.Err()
+	})
 }
 
-// GetAppointment retrieves a fully hydrated appointment by ID
-func (s *Service) GetAppointment(ctx context.Context, id uuid.UUID) (*AppointmentDetail, error) {
-	detail, err := s.repo.GetAppointmentDetail(ctx, id)
+// getAppointment reads an appointment by ID with the related entities in
+// fields. With the slot it also reads the span of a multi-slot appointment,
+// the staff reserved for it and the state of its intake form.
+func (s *Service) getAppointment(ctx context.Context, id uuid.UUID, fields DetailFields) (*AppointmentDetail, error) {
+	detail, err := s.repo.GetAppointmentDetail(ctx, id, fields)
 	if err != nil {
 		return nil, fmt.Errorf("get appointment: %w", err)
 	}
+	if fields.Slot {
+		slots, err := s.repo.ListAppointmentSlots(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("get appointment slots: %w", err)
+		}
+		if len(slots) > 1 {
+			detail.Span = slots
+		}
+		if detail.Resources, err = s.repo.ListAppointmentResources(ctx, id); err != nil {
+			return nil, fmt.Errorf("get appointment resources: %w", err)
+		}
+		if detail.Intake, err = s.appointmentIntakeStatus(ctx, id, detail.Slot); err != nil {
+			return nil, err
+		}
+	}
+	// Notes are about the patient, so they come with the patient's data and
+	// are recorded as access to it
+	if fields.Patient {
+		if detail.Notes, err = s.repo.ListAppointmentNotes(ctx, id); err != nil {
+			return nil, fmt.Errorf("get appointment notes: %w", err)
+		}
+	}
 	return detail, nil
 }
 
-// ListAppointmentsByPatient retrieves appointments for a specific patient
-func (s *Service) ListAppointmentsByPatient(ctx context.Context, patientID uuid.UUID, limit, offset int) ([]AppointmentDetail, error) {
-	if limit <= 0 {
-		limit = 20 // default
+// MaxBatchGet bounds the ids accepted by GetAppointments
+const MaxBatchGet = 100
+
+// GetAppointments retrieves several appointments with one query.
+// Details come back in the order of ids, duplicates collapsed; ids with no
+// appointment are returned in notFound instead of failing the batch.
+func (s *Service) GetAppointments(ctx context.Context, ids []uuid.UUID, fields DetailFields) (details []AppointmentDetail, notFound []uuid.UUID, err error) {
+	unique := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
 	}
-	if limit > 100 {
-		limit = 100 // max
+
+	rows, err := s.repo.GetAppointmentDetails(ctx, unique, fields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get appointments: %w", err)
+	}
+
+	byID := make(map[uuid.UUID]AppointmentDetail, len(rows))
+	for _, d := range rows {
+		byID[d.ID] = d
+	}
+	details = make([]AppointmentDetail, 0, len(rows))
+	for _, id := range unique {
+		if d, ok := byID[id]; ok {
+			details = append(details, d)
+		} else {
+			notFound = append(notFound, id)
+		}
+	}
+	return details, notFound, nil
+}
+
+// Now is the service's current time. Hold countdowns shown to clients must
+// use it so they agree with expiry.
+func (s *Service) Now() time.Time {
+	return s.clock.Now()
+}
+
+// GetAppointmentHold reads just the appointment row, without the joins of
+// GetAppointment. It backs the cheap hold countdown endpoint.
+func (s *Service) GetAppointmentHold(ctx context.Context, id uuid.UUID) (*Appointment, error) {
+	appt, err := s.repo.GetAppointmentByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get appointment: %w", err)
+	}
+	return appt, nil
+}
+
+func (s *Service) getAvailabilityVersion(ctx context.Context, clinicianID uuid.UUID) (int64, error) {
+	if _, err := s.lookupClinician(ctx, clinicianID); err != nil {
+		return 0, fmt.Errorf("get clinician: %w", err)
+	}
+
+	version, err := s.repo.GetAvailabilityVersion(ctx, clinicianID)
+	if err != nil {
+		return 0, fmt.Errorf("get availability version: %w", err)
+	}
+	return version, nil
+}
+
+func (s *Service) getAvailabilityCalendar(ctx context.Context, clinicianID uuid.UUID, month time.Time, loc *time.Location) ([]CalendarDay, error) {
+	clinician, err := s.repo.GetClinicianByID(ctx, clinicianID)
+	if err != nil {
+		return nil, fmt.Errorf("get clinician: %w", err)
+	}
+	window, err := s.clinicianBookingWindow(ctx, clinician)
+	if err != nil {
+		return nil, fmt.Errorf("get booking window: %w", err)
+	}
+
+	year, mon, _ := month.Date()
+	first := time.Date(year, mon, 1, 0, 0, 0, 0, loc)
+	var days []DayRange
+	for d := first; d.Month() == mon; d = d.AddDate(0, 0, 1) {
+		days = append(days, DayRange{Start: d, End: d.AddDate(0, 0, 1)})
+	}
+
+	bookable := window.Bookable(s.clock.Now())
+	if clinician.Deactivated() {
+		// None of their slots can be booked
+		bookable = DayRange{}
+	}
+	counts, err := s.repo.CountSlotsByDay(ctx, clinicianID, days, bookable, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("get availability calendar: %w", err)
+	}
+
+	calendar := make([]CalendarDay, len(days))
+	for i, d := range days {
+		calendar[i] = CalendarDay{Date: d.Start, DayAvailability: counts[i]}
 	}
-	if offset < 0 {
-		offset = 0
+	return calendar, nil
+}
+
+// ListAppointmentsByPatient retrieves one page of appointments for a specific patient
+func (s *Service) ListAppointmentsByPatient(ctx context.Context, patientID uuid.UUID, page PageRequest, fields DetailFields) (*AppointmentPage, error) {
+	if page.Limit <= 0 {
+		page.Limit = 20 // default
+	}
+	if page.Limit > 100 {
+		page.Limit = 100 // max
+	}
+	if page.Offset < 0 {
+		page.Offset = 0
+	}
+	scope := patientListScope(patientID, page.Sort)
+	cursor, err := s.openPageToken(scope, page.Token)
+	if err != nil {
+		return nil, err
 	}
+	page.Token = cursor
 
-	appointments, err := s.repo.ListAppointmentsByPatient(ctx, patientID, limit, offset)
+	result, err := s.repo.ListAppointmentsByPatient(ctx, patientID, page, fields)
 	if err != nil {
 		return nil, fmt.Errorf("list appointments by patient: %w", err)
 	}
-	return appointments, nil
+	result.NextToken = s.pages.Encode(scope, result.NextToken)
+	return result, nil
+}
+
+// PatientTimeline is a patient's appointment history, oldest event first.
+// Truncated is set when older events were left out.
+type PatientTimeline struct {
+	Entries   []TimelineEntry
+	Truncated bool
+}
+
+// TimelineStage names the step of an appointment's life an event marks:
+// "booked" for APPOINTMENT_CREATED, and the rest of the type otherwise, so
+// APPOINTMENT_CONFIRMED is "confirmed"
+func TimelineStage(eventType string) string {
+	if eventType == EventAppointmentCreated {
+		return "booked"
+	}
+	return strings.ToLower(strings.TrimPrefix(eventType, "APPOINTMENT_"))
+}
+
+// GetPatientTimeline merges the events of every appointment of the patient
+// into one chronological history, keeping the newest limit events
+func (s *Service) GetPatientTimeline(ctx context.Context, patientID uuid.UUID, limit int) (*PatientTimeline, error) {
+	if limit <= 0 {
+		limit = 100 // default
+	}
+	if limit > 500 {
+		limit = 500 // max
+	}
+
+	if _, err := s.lookupPatient(ctx, patientID); err != nil {
+		return nil, fmt.Errorf("load patient: %w", err)
+	}
+
+	// One extra row tells whether older events were cut off
+	entries, err := s.repo.ListPatientTimeline(ctx, patientID, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("patient timeline: %w", err)
+	}
+	timeline := &PatientTimeline{Entries: entries}
+	if len(entries) > limit {
+		timeline.Entries = entries[:limit]
+		timeline.Truncated = true
+	}
+	slices.Reverse(timeline.Entries)
+	return timeline, nil
+}
+
+// QuoteSlot returns the self-pay price for a slot based on its clinic's price table
+func (s *Service) QuoteSlot(ctx context.Context, slotID uuid.UUID) (*SlotQuote, error) {
+	if _, err := s.repo.GetSlotByID(ctx, slotID); err != nil {
+		return nil, fmt.Errorf("load slot: %w", err)
+	}
+
+	quote, err := s.repo.GetSlotQuote(ctx, slotID)
+	if err != nil {
+		return nil, fmt.Errorf("quote slot: %w", err)
+	}
+	return quote, nil
 }
 
 // ListAppointmentsBySlot retrieves all appointments for a specific slot
-func (s *Service) ListAppointmentsBySlot(ctx context.Context, slotID uuid.UUID) ([]AppointmentDetail, error) {
-	appointments, err := s.repo.ListAppointmentsBySlot(ctx, slotID)
+func (s *Service) ListAppointmentsBySlot(ctx context.Context, slotID uuid.UUID, sort ListSort, fields DetailFields) ([]AppointmentDetail, error) {
+	appointments, err := s.repo.ListAppointmentsBySlot(ctx, slotID, sort, fields)
 	if err != nil {
 		return nil, fmt.Errorf("list appointments by slot: %w", err)
 	}
 	return appointments, nil
 }
+
+// MaxSearchNameLength bounds AppointmentSearch.PatientName
+const MaxSearchNameLength = 100
+
+// SearchAppointments returns one page of the appointments matching every
+// filter set in q. At least one filter other than the page is required, so
+// a search never walks the whole table.
+func (s *Service) SearchAppointments(ctx context.Context, q AppointmentSearch, fields DetailFields) (*AppointmentPage, error) {
+	q.PatientName = strings.TrimSpace(q.PatientName)
+	if len(q.PatientName) > MaxSearchNameLength {
+		return nil, fmt.Errorf("%w: patient name is longer than %d bytes", ErrInvalidSearch, MaxSearchNameLength)
+	}
+	for _, st := range q.Statuses {
+		if !st.Valid() {
+			return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidSearch, st)
+		}
+	}
+	if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+		return nil, ErrInvalidTimeRange
+	}
+	if q.PatientID == nil && q.SlotID == nil && q.ClinicianID == nil && q.PatientName == "" &&
+		q.Specialty == "" && len(q.Statuses) == 0 && q.From == nil && q.To == nil {
+		return nil, fmt.Errorf("%w: at least one filter is required", ErrInvalidSearch)
+	}
+	if q.Limit <= 0 {
+		q.Limit = 20 // default
+	}
+	if q.Limit > 100 {
+		q.Limit = 100 // max
+	}
+	scope := q.pageScope()
+	cursor, err := s.openPageToken(scope, q.Token)
+	if err != nil {
+		return nil, err
+	}
+	q.Token = cursor
+
+	page, err := s.repo.SearchAppointments(ctx, q, fields)
+	if err != nil {
+		return nil, fmt.Errorf("search appointments: %w", err)
+	}
+	page.NextToken = s.pages.Encode(scope, page.NextToken)
+	return page, nil
+}
+
+// ExpiryEventReport lists appointments whose APPOINTMENT_EXPIRED events
+// since the given time disagree with their status. Events are only logged
+// after the expiring transition commits, so anything here predates that or
+// was written outside the service.
+func (s *Service) ExpiryEventReport(ctx context.Context, since time.Time, limit int) ([]ExpiryEventMismatch, error) {
+	if limit <= 0 {
+		limit = 100 // default
+	}
+	if limit > 1000 {
+		limit = 1000 // max
+	}
+
+	mismatches, err := s.repo.ListExpiryEventMismatches(ctx, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("expiry event report: %w", err)
+	}
+	return mismatches, nil
+}
+
+// StreamClinicAppointments passes each appointment of the clinic with a slot
+// starting in [from, to) to fn without collecting them, for exports too large
+// to hold in memory
+func (s *Service) StreamClinicAppointments(ctx context.Context, clinicID uuid.UUID, from, to time.Time, fields DetailFields, fn func(*AppointmentDetail) error) error {
+	if !from.Before(to) {
+		return ErrInvalidTimeRange
+	}
+	return s.repo.StreamAppointmentsByClinic(ctx, clinicID, from, to, fields, fn)
+}
+
+// RecordLockForceRelease writes the audit event for an operator breaking the
+// lock on key. details describes who and why.
+func (s *Service) RecordLockForceRelease(ctx context.Context, key string, details map[string]any) error {
+	payload := map[string]any{"key": key}
+	for k, v := range details {
+		payload[k] = v
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal lock release event: %w", err)
+	}
+
+	ev := EventLog{
+		EventType: EventLockForceReleased,
+		Payload:   data,
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.repo.InsertEvent(ctx, ev); err != nil {
+		return fmt.Errorf("record lock release: %w", err)
+	}
+	return nil
+}
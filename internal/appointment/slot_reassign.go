@@ -0,0 +1,127 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventAppointmentClinicianChanged is logged for each active appointment of
+// a slot moved to a covering clinician, so the patient can be told
+const EventAppointmentClinicianChanged = "APPOINTMENT_CLINICIAN_CHANGED"
+
+// SlotReassignment is the outcome of ReassignSlot: the slot as reassigned,
+// the clinician it was taken from and the active appointments that moved
+// with it
+type SlotReassignment struct {
+	Slot                *AppointmentSlot
+	PreviousClinicianID uuid.UUID
+	Appointments        []Appointment
+}
+
+// ReassignSlot moves a slot that has not started, with its appointments, to
+// a covering clinician, e.g. a locum standing in for the week. The
+// clinician must be active and share the specialty and clinic of the slot's
+// clinician, so booking windows, approval and staff reservations hold as
+// they are, and must have no slot overlapping it. A slot that is part of an
+// appointment spanning several slots cannot be moved on its own. The checks
+// and the move run under the slot's lock, so no booking lands in between;
+// each active appointment of the slot then gets an
+// APPOINTMENT_CLINICIAN_CHANGED event.
+func (s *Service) ReassignSlot(ctx context.Context, slotID, clinicianID uuid.UUID) (*SlotReassignment, error) {
+	slot, err := s.repo.GetSlotByID(ctx, slotID)
+	if err != nil {
+		return nil, fmt.Errorf("load slot: %w", err)
+	}
+	if slot.PractitionerID == clinicianID {
+		return nil, fmt.Errorf("%w: the slot already belongs to the clinician", ErrInvalidReassignment)
+	}
+	if slot.Status == SlotDeleted {
+		return nil, ErrSlotNotOpen
+	}
+	if !slot.StartTime.After(s.clock.Now()) {
+		return nil, fmt.Errorf("%w: the slot has already started", ErrInvalidReassignment)
+	}
+
+	from, err := s.repo.GetClinicianByID(ctx, slot.PractitionerID)
+	if err != nil {
+		return nil, fmt.Errorf("load clinician: %w", err)
+	}
+	to, err := s.repo.GetClinicianByID(ctx, clinicianID)
+	if err != nil {
+		return nil, fmt.Errorf("load covering clinician: %w", err)
+	}
+	if err := checkCover(from, to); err != nil {
+		return nil, err
+	}
+
+	var moved *AppointmentSlot
+	var appts []Appointment
+	err = s.runStage(ctx, StageLockSection, func(ctx context.Context) error {
+		return s.withSlotLocks(ctx, []*AppointmentSlot{slot}, func(ctx context.Context) error {
+			busy, err := s.repo.ListClinicianSlotsOverlapping(ctx, to.ID, slot.StartTime, slot.EndTime)
+			if err != nil {
+				return fmt.Errorf("list clinician slots: %w", err)
+			}
+			if len(busy) > 0 {
+				return fmt.Errorf("%w: slot %s from %s", ErrClinicianUnavailable, busy[0].ID, busy[0].StartTime.Format(time.RFC3339))
+			}
+
+			appts, err = s.repo.ListActiveSlotAppointments(ctx, slot.ID)
+			if err != nil {
+				return fmt.Errorf("list slot appointments: %w", err)
+			}
+			for _, a := range appts {
+				spanned, err := s.repo.ListAppointmentSlots(ctx, a.ID)
+				if err != nil {
+					return fmt.Errorf("list appointment slots: %w", err)
+				}
+				if len(spanned) > 1 {
+					return fmt.Errorf("%w: appointment %s", ErrSlotInMultiSlotAppointment, a.ID)
+				}
+			}
+
+			moved, err = s.repo.ReassignSlot(ctx, slot.ID, from.ID, to.ID)
+			if errors.Is(err, ErrSlotOverlap) {
+				// another of to's slots was added or moved since the check
+				return ErrClinicianUnavailable
+			}
+			return err
+		})
+	})
+	if err != nil {
+		if mapped := lockError(err); mapped != err {
+			return nil, mapped
+		}
+		return nil, fmt.Errorf("reassign slot: %w", err)
+	}
+
+	for _, a := range appts {
+		s.logEvent(ctx, a.ID, EventAppointmentClinicianChanged, map[string]any{
+			"slot_id":               slot.ID.String(),
+			"patient_id":            a.PatientID.String(),
+			"previous_clinician_id": from.ID.String(),
+			"clinician_id":          to.ID.String(),
+			"clinician_name":        to.Name,
+		})
+	}
+	return &SlotReassignment{Slot: moved, PreviousClinicianID: from.ID, Appointments: appts}, nil
+}
+
+// checkCover returns ErrClinicianIncompatible unless to has the specialty
+// and clinic of from, where from has one
+func checkCover(from, to *Clinician) error {
+	if to.Deactivated() {
+		return fmt.Errorf("%w: the covering clinician is deactivated", ErrClinicianDeactivated)
+	}
+	if from.Specialty != nil && (to.Specialty == nil || *to.Specialty != *from.Specialty) {
+		return fmt.Errorf("%w: the slot needs a clinician of specialty %q", ErrClinicianIncompatible, *from.Specialty)
+	}
+	if from.ClinicID != nil && (to.ClinicID == nil || *to.ClinicID != *from.ClinicID) {
+		return fmt.Errorf("%w: the clinician works at another clinic", ErrClinicianIncompatible)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package appointment
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// minSlotLength is the shortest slot a split makes, as for generated
+	// slots
+	minSlotLength = 5 * time.Minute
+	// maxMergedSlots bounds the slots one MergeSlots call folds together
+	maxMergedSlots = 100
+)
+
+// SlotMerge is the outcome of MergeSlots: the slot that now spans the
+// others, and the IDs of those deleted into it
+type SlotMerge struct {
+	Slot   *AppointmentSlot
+	Merged []uuid.UUID
+}
+
+// SplitSlot cuts a slot that has not started into back-to-back slots of
+// length, e.g. after a clinic moves from hour-long to half-hour
+// appointments. length must divide the slot into two or more slots of
+// whole minutes. The slot keeps its ID as the first of them; each later one
+// takes the ID of a deleted slot of the clinician with its times, as a
+// merge leaves behind, or a new one. All keep the slot's status, capacity
+// and type. A slot with active appointments, which would be left on part
+// of the time they were booked for, returns ErrSlotHasAppointments. The
+// checks and the split run under the slot's lock and its row lock in one
+// transaction.
+func (s *Service) SplitSlot(ctx context.Context, id uuid.UUID, length time.Duration) ([]AppointmentSlot, error) {
+	if length < minSlotLength || length%time.Minute != 0 {
+		return nil, fmt.Errorf("%w: slots are split into whole minutes, at least %d", ErrInvalidSlotChange, int(minSlotLength/time.Minute))
+	}
+	slot, err := s.reshapeableSlot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	span := slot.EndTime.Sub(slot.StartTime)
+	if span <= length || span%length != 0 {
+		return nil, fmt.Errorf("%w: a %d-minute slot cannot be split into %d-minute slots",
+			ErrInvalidSlotChange, int(span/time.Minute), int(length/time.Minute))
+	}
+
+	var parts []AppointmentSlot
+	err = s.runStage(ctx, StageLockSection, func(ctx context.Context) error {
+		return s.withSlotLocks(ctx, []*AppointmentSlot{slot}, func(ctx context.Context) error {
+			return s.repo.WithTx(ctx, func(tx Repository) error {
+				// Read again under the lock, in case a split or merge
+				// finished since. Locking the row makes bookings that
+				// take no slot lock, such as group slots', wait until
+				// the split is done.
+				current, err := tx.LockSlot(ctx, id)
+				if err != nil {
+					return err
+				}
+				if !current.StartTime.Equal(slot.StartTime) || !current.EndTime.Equal(slot.EndTime) || current.Status == SlotDeleted {
+					return fmt.Errorf("%w: the slot changed while it was being split", ErrInvalidSlotChange)
+				}
+				if err := checkNoActiveAppointments(ctx, tx, current.ID); err != nil {
+					return err
+				}
+
+				starts := make([]time.Time, 0, span/length)
+				for t := current.StartTime.Add(length); t.Before(current.EndTime); t = t.Add(length) {
+					starts = append(starts, t)
+				}
+				existing, err := tx.ListClinicianSlotsAt(ctx, current.PractitionerID, starts)
+				if err != nil {
+					return fmt.Errorf("list clinician slots: %w", err)
+				}
+
+				first := *current
+				first.EndTime = first.StartTime.Add(length)
+				shrunk, err := tx.UpdateSlot(ctx, first)
+				if err != nil {
+					return fmt.Errorf("shorten slot: %w", err)
+				}
+				parts = append(parts, *shrunk)
+
+				for _, start := range starts {
+					part := *current
+					part.ID = uuid.New()
+					part.StartTime, part.EndTime = start, start.Add(length)
+					if i := slices.IndexFunc(existing, func(e AppointmentSlot) bool {
+						return e.Status == SlotDeleted && e.StartTime.Equal(part.StartTime) && e.EndTime.Equal(part.EndTime)
+					}); i >= 0 {
+						part.ID = existing[i].ID
+						revived, err := tx.UpdateSlot(ctx, part)
+						if err != nil {
+							return fmt.Errorf("restore slot: %w", err)
+						}
+						parts = append(parts, *revived)
+						continue
+					}
+					created, err := tx.CreateSlots(ctx, []AppointmentSlot{part})
+					if err != nil {
+						return err
+					}
+					parts = append(parts, created...)
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		if mapped := lockError(err); mapped != err {
+			return nil, mapped
+		}
+		return nil, fmt.Errorf("split slot: %w", err)
+	}
+	return parts, nil
+}
+
+// MergeSlots joins back-to-back slots of one clinician into one, e.g. to
+// undo a split or fit a longer appointment type. The slots must not have
+// started and must share their status, capacity and type. The earliest
+// keeps its ID and is stretched over the others, which are deleted. Slots
+// with active appointments return ErrSlotHasAppointments. The checks and
+// the merge run under the slots' locks and row locks in one transaction.
+func (s *Service) MergeSlots(ctx context.Context, ids []uuid.UUID) (*SlotMerge, error) {
+	if len(ids) < 2 || len(ids) > maxMergedSlots {
+		return nil, fmt.Errorf("%w: 2 to %d slots are merged at a time", ErrInvalidSlotChange, maxMergedSlots)
+	}
+	slots := make([]*AppointmentSlot, 0, len(ids))
+	for _, id := range ids {
+		if slices.ContainsFunc(slots, func(s *AppointmentSlot) bool { return s.ID == id }) {
+			return nil, fmt.Errorf("%w: slot %s is listed twice", ErrInvalidSlotChange, id)
+		}
+		slot, err := s.reshapeableSlot(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	slices.SortFunc(slots, func(a, b *AppointmentSlot) int { return a.StartTime.Compare(b.StartTime) })
+	first := slots[0]
+	for i, slot := range slots[1:] {
+		switch {
+		case slot.PractitionerID != first.PractitionerID:
+			return nil, fmt.Errorf("%w: the slots belong to different clinicians", ErrInvalidSlotChange)
+		case slot.Status != first.Status || slot.Capacity != first.Capacity || !equalSlotType(slot.SlotType, first.SlotType):
+			return nil, fmt.Errorf("%w: slot %s differs from slot %s in status, capacity or type", ErrInvalidSlotChange, slot.ID, first.ID)
+		case !slot.StartTime.Equal(slots[i].EndTime):
+			return nil, fmt.Errorf("%w: slot %s does not start when slot %s ends", ErrInvalidSlotChange, slot.ID, slots[i].ID)
+		}
+	}
+
+	merge := &SlotMerge{}
+	err := s.runStage(ctx, StageLockSection, func(ctx context.Context) error {
+		return s.withSlotLocks(ctx, slots, func(ctx context.Context) error {
+			return s.repo.WithTx(ctx, func(tx Repository) error {
+				// Lock the rows in start order, as bookings of several
+				// slots do
+				for _, slot := range slots {
+					current, err := tx.LockSlot(ctx, slot.ID)
+					if err != nil {
+						return err
+					}
+					if !current.StartTime.Equal(slot.StartTime) || !current.EndTime.Equal(slot.EndTime) || current.Status != slot.Status {
+						return fmt.Errorf("%w: slot %s changed while it was being merged", ErrInvalidSlotChange, slot.ID)
+					}
+					if err := checkNoActiveAppointments(ctx, tx, slot.ID); err != nil {
+						return err
+					}
+				}
+
+				// Delete before stretching, so the slots never overlap
+				for _, slot := range slots[1:] {
+					deleted := *slot
+					deleted.Status = SlotDeleted
+					if _, err := tx.UpdateSlot(ctx, deleted); err != nil {
+						return fmt.Errorf("delete slot: %w", err)
+					}
+					merge.Merged = append(merge.Merged, slot.ID)
+				}
+				stretched := *first
+				stretched.EndTime = slots[len(slots)-1].EndTime
+				var err error
+				merge.Slot, err = tx.UpdateSlot(ctx, stretched)
+				if err != nil {
+					return fmt.Errorf("stretch slot: %w", err)
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		if mapped := lockError(err); mapped != err {
+			return nil, mapped
+		}
+		return nil, fmt.Errorf("merge slots: %w", err)
+	}
+	return merge, nil
+}
+
+// reshapeableSlot loads a slot a split or merge may change: not deleted and
+// not started
+func (s *Service) reshapeableSlot(ctx context.Context, id uuid.UUID) (*AppointmentSlot, error) {
+	slot, err := s.repo.GetSlotByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("load slot: %w", err)
+	}
+	if slot.Status == SlotDeleted {
+		return nil, fmt.Errorf("%w: slot %s is deleted", ErrSlotNotOpen, id)
+	}
+	if !slot.StartTime.After(s.clock.Now()) {
+		return nil, fmt.Errorf("%w: slot %s has already started", ErrInvalidSlotChange, id)
+	}
+	return slot, nil
+}
+
+// checkNoActiveAppointments returns ErrSlotHasAppointments when an active
+// appointment spans the slot
+func checkNoActiveAppointments(ctx context.Context, tx Repository, slotID uuid.UUID) error {
+	appts, err := tx.ListActiveSlotAppointments(ctx, slotID)
+	if err != nil {
+		return fmt.Errorf("list slot appointments: %w", err)
+	}
+	if len(appts) > 0 {
+		return fmt.Errorf("%w: appointment %s is %s on slot %s", ErrSlotHasAppointments, appts[0].ID, appts[0].Status, slotID)
+	}
+	return nil
+}
+
+func equalSlotType(a, b *string) bool {
+	return (a == nil) == (b == nil) && (a == nil || *a == *b)
+}
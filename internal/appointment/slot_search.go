@@ -0,0 +1,76 @@
+package appointment
+
+import (
+	"context"
+	"fmt"
+)
+
+// SearchSlots returns one page of the slots matching q. Status defaults to
+// open, and open slots are the ones that can still be booked: below
+// capacity, not started and admitted by the booking window of their
+// clinician's specialty. Slots the window leaves out are dropped after
+// paging, so a page may come back short, or empty with a NextToken.
+// Blocked and deleted slots are listed as they are, past ones included.
+func (s *Service) SearchSlots(ctx context.Context, q SlotQuery) (*SlotSearchResult, error) {
+	if q.Status == "" {
+		q.Status = SlotOpen
+	}
+	if !q.Status.Valid() {
+		return nil, fmt.Errorf("%w: unknown slot status %q", ErrInvalidSlotSearch, q.Status)
+	}
+	if !q.From.Before(q.To) {
+		return nil, ErrInvalidTimeRange
+	}
+	q.Limit = min(max(q.Limit, 1), MaxOpenSlots)
+	// Scoped before From moves up to now, so later pages match the first
+	scope := q.pageScope()
+	cursor, err := s.openPageToken(scope, q.Token)
+	if err != nil {
+		return nil, err
+	}
+	q.Token = cursor
+	now := s.clock.Now()
+	res := &SlotSearchResult{Slots: []OpenSlot{}}
+	if q.Status == SlotOpen && q.From.Before(now) {
+		q.From = now
+		if !q.From.Before(q.To) {
+			return res, nil
+		}
+	}
+
+	// Fetch one extra slot to know whether there is a next page
+	limit := q.Limit
+	q.Limit++
+	slots, err := s.repo.SearchSlots(ctx, q, now)
+	if err != nil {
+		return nil, fmt.Errorf("search slots: %w", err)
+	}
+	if len(slots) > limit {
+		slots = slots[:limit]
+		last := slots[limit-1]
+		res.NextToken = s.pages.Encode(scope, encodePageKey(last.StartTime, last.ID))
+	}
+	if q.Status != SlotOpen {
+		res.Slots = append(res.Slots, slots...)
+		return res, nil
+	}
+
+	windows, err := s.repo.ListBookingWindows(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list booking windows: %w", err)
+	}
+	bySpecialty := make(map[string]*BookingWindow, len(windows))
+	for i := range windows {
+		bySpecialty[windows[i].Specialty] = &windows[i]
+	}
+	for _, o := range slots {
+		var window *BookingWindow
+		if o.Specialty != nil {
+			window = bySpecialty[*o.Specialty]
+		}
+		if window.Allows(o.StartTime, now) {
+			res.Slots = append(res.Slots, o)
+		}
+	}
+	return res, nil
+}
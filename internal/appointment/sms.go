@@ -0,0 +1,192 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// MaxSMSReplyLength bounds the body of an SMS reply that is stored. Replies
+// that matter are one character; a long one is kept only in part.
+const MaxSMSReplyLength = 1600
+
+// SetPatientPhone sets the number the patient's SMS replies come from. It
+// is normalized to E.164, so "+1 (415) 555-0123" is stored as +14155550123.
+func (s *Service) SetPatientPhone(ctx context.Context, id uuid.UUID, phone string) (*Patient, error) {
+	normalized, ok := normalizePhone(phone)
+	if !ok {
+		return nil, ErrInvalidPhone
+	}
+	return s.setPatientPhone(ctx, id, &normalized)
+}
+
+// ClearPatientPhone removes the patient's number; replies from it no
+// longer reach their appointments
+func (s *Service) ClearPatientPhone(ctx context.Context, id uuid.UUID) (*Patient, error) {
+	return s.setPatientPhone(ctx, id, nil)
+}
+
+func (s *Service) setPatientPhone(ctx context.Context, id uuid.UUID, phone *string) (*Patient, error) {
+	p, err := s.repo.SetPatientPhone(ctx, id, phone)
+	if err != nil {
+		if errors.Is(err, ErrPatientNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("set patient phone: %w", err)
+	}
+	s.patients.forget(ctx, id)
+	return p, nil
+}
+
+// HandleSMSReply acts on an SMS a patient sent from from: "1" confirms and
+// "2" cancels the pending appointment of a patient with that number whose
+// slot comes soonest. The reply is recorded by the carrier's messageID, and
+// a message delivered again returns the recorded reply without acting
+// twice. Replies from one number are handled one at a time, so a duplicate
+// arriving while the first is handled waits for its outcome.
+//
+// An appointment that cannot be confirmed or cancelled, e.g. because its
+// slot filled up, is recorded as SMSFailed. Errors that may pass, such as a
+// slot being booked at the same moment, are returned without recording the
+// reply, so a redelivery tries again.
+func (s *Service) HandleSMSReply(ctx context.Context, messageID, from, body string) (*SMSReply, error) {
+	messageID = strings.TrimSpace(messageID)
+	if messageID == "" {
+		return nil, fmt.Errorf("%w: no message ID", ErrInvalidSMSReply)
+	}
+	phone, ok := normalizePhone(from)
+	if !ok {
+		return nil, fmt.Errorf("%w: sender %q is not an E.164 number", ErrInvalidSMSReply, from)
+	}
+	if len(body) > MaxSMSReplyLength {
+		body = body[:MaxSMSReplyLength]
+	}
+	// Text columns take neither invalid UTF-8, as a cut can leave, nor NUL
+	body = strings.ReplaceAll(strings.ToValidUTF8(body, ""), "\x00", "")
+
+	var reply *SMSReply
+	err := s.locker.WithLock(ctx, []string{redisclient.PhoneKey(phone)}, func(ctx context.Context) error {
+		recorded, err := s.repo.GetSMSReply(ctx, messageID)
+		if err == nil {
+			reply = recorded
+			return nil
+		}
+		if !errors.Is(err, ErrSMSReplyNotFound) {
+			return fmt.Errorf("load sms reply: %w", err)
+		}
+
+		reply, err = s.actOnSMSReply(ctx, messageID, phone, body)
+		if err != nil {
+			return err
+		}
+		_, err = s.repo.InsertSMSReply(ctx, *reply)
+		return err
+	})
+	if err != nil {
+		if mapped := lockError(err); mapped != err {
+			return nil, mapped
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+// actOnSMSReply performs what the reply asks for and returns it as it is to
+// be recorded
+func (s *Service) actOnSMSReply(ctx context.Context, messageID, phone, body string) (*SMSReply, error) {
+	reply := &SMSReply{
+		MessageID:  messageID,
+		From:       phone,
+		Body:       body,
+		ReceivedAt: s.clock.Now(),
+	}
+
+	action, ok := parseSMSAction(body)
+	if !ok {
+		reply.Outcome = SMSNotUnderstood
+		return reply, nil
+	}
+	reply.Action = &action
+
+	pending, err := s.repo.ListPendingAppointmentsByPhone(ctx, phone, reply.ReceivedAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		reply.Outcome = SMSNoAppointment
+		return reply, nil
+	}
+	id := pending[0].ID
+	reply.AppointmentID = &id
+
+	var appt *Appointment
+	switch action {
+	case SMSConfirm:
+		appt, err = s.ConfirmAppointment(ctx, id)
+	case SMSCancel:
+		appt, err = s.CancelAppointment(ctx, id, "patient_request", map[string]any{
+			"via":        "sms",
+			"message_id": messageID,
+		})
+	}
+	if err != nil {
+		var domainErr *Error
+		if !errors.As(err, &domainErr) || domainErr.Retryable || domainErr.HTTPStatus >= 500 {
+			return nil, err
+		}
+		reply.Outcome = SMSFailed
+		return reply, nil
+	}
+
+	switch appt.Status {
+	case StatusPendingApproval:
+		reply.Outcome = SMSAwaitingApproval
+	case StatusCancelled:
+		reply.Outcome = SMSCancelled
+	default:
+		reply.Outcome = SMSConfirmed
+	}
+	return reply, nil
+}
+
+// parseSMSAction reads the action from the body of a reply: "1" to confirm
+// and "2" to cancel, as the booking messages ask
+func parseSMSAction(body string) (SMSAction, bool) {
+	switch strings.TrimSpace(body) {
+	case "1":
+		return SMSConfirm, true
+	case "2":
+		return SMSCancel, true
+	}
+	return "", false
+}
+
+// normalizePhone returns phone in E.164, dropping the spaces, dashes, dots
+// and brackets people write numbers with. It must start with + and the
+// country code.
+func normalizePhone(phone string) (string, bool) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		switch {
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", false
+		}
+	}
+
+	n := b.String()
+	// + then 8 to 15 digits, the first of them a country code, never 0
+	if !strings.HasPrefix(n, "+") || len(n) < 9 || len(n) > 16 || n[1] == '0' {
+		return "", false
+	}
+	return n, true
+}
@@ -0,0 +1,2201 @@
+package appointment
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sqlQuerier is satisfied by both *sql.DB and *sql.Tx
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SqliteRepository is the reference Repository for edge and demo deployments.
+// Open the database with db.OpenSQLite, which also applies the schema.
+type SqliteRepository struct {
+	db *sql.DB
+	q  sqlQuerier
+}
+
+func NewSqliteRepository(db *sql.DB) *SqliteRepository {
+	return &SqliteRepository{db: db, q: db}
+}
+
+// Timestamps are stored as UTC text so they compare correctly as strings
+func utcNow() time.Time {
+	return time.Now().UTC()
+}
+
+// Interface methods
+
+func (r *SqliteRepository) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	if _, inTx := r.q.(*sql.Tx); inTx {
+		return fn(r)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := fn(&SqliteRepository{db: r.db, q: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+func (r *SqliteRepository) GetPatientByID(ctx context.Context, id uuid.UUID) (*Patient, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT `+patientColumns+`
+		FROM patients
+		WHERE id = ?
+	`, id)
+	return scanPatient(row)
+}
+
+func (r *SqliteRepository) CreatePatient(ctx context.Context, p Patient) (*Patient, error) {
+	now := utcNow()
+	created, err := scanPatient(r.q.QueryRowContext(ctx, `
+		INSERT INTO patients (id, name, email, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING `+patientColumns+`
+	`, p.ID, p.Name, p.Email, now, now))
+	if err != nil && isPatientEmailViolation(err) {
+		return nil, ErrPatientEmailTaken
+	}
+	return created, err
+}
+
+func (r *SqliteRepository) UpdatePatient(ctx context.Context, id uuid.UUID, u PatientUpdate) (*Patient, error) {
+	updated, err := scanPatient(r.q.QueryRowContext(ctx, `
+		UPDATE patients
+		SET name = COALESCE(?, name), email = COALESCE(?, email), updated_at = ?
+		WHERE id = ?
+		RETURNING `+patientColumns+`
+	`, u.Name, u.Email, utcNow(), id))
+	if err != nil && isPatientEmailViolation(err) {
+		return nil, ErrPatientEmailTaken
+	}
+	return updated, err
+}
+
+func (r *SqliteRepository) SearchPatients(ctx context.Context, q PatientSearch) (*PatientPage, error) {
+	var after *patientPageKey
+	if q.Token != "" {
+		key, err := decodePatientPageToken(q.Token)
+		if err != nil {
+			return nil, err
+		}
+		after = key
+	}
+
+	query, args := patientSearchQuery(q, after, func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search patients: %w", err)
+	}
+	defer rows.Close()
+
+	return collectPatientPage(rows, q.Limit)
+}
+
+func (r *SqliteRepository) DeactivatePatient(ctx context.Context, id uuid.UUID, actor, reason string, at time.Time) (*Patient, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE patients
+		SET deactivated_at = ?, deactivated_by = ?, deactivation_reason = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING `+patientColumns+`
+	`, at.UTC(), actor, reason, utcNow(), id)
+	return scanPatient(row)
+}
+
+func (r *SqliteRepository) ReactivatePatient(ctx context.Context, id uuid.UUID) (*Patient, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE patients
+		SET deactivated_at = NULL, deactivated_by = NULL, deactivation_reason = NULL, updated_at = ?
+		WHERE id = ?
+		RETURNING `+patientColumns+`
+	`, utcNow(), id)
+	return scanPatient(row)
+}
+
+func (r *SqliteRepository) SetPatientPhone(ctx context.Context, id uuid.UUID, phone *string) (*Patient, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE patients
+		SET phone = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING `+patientColumns+`
+	`, phone, utcNow(), id)
+	return scanPatient(row)
+}
+
+func (r *SqliteRepository) ListPendingAppointmentsByPhone(ctx context.Context, phone string, now time.Time) ([]Appointment, error) {
+	query := pendingByPhoneQuery(func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, phone, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list pending appointments by phone: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Appointment
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *SqliteRepository) GetSMSReply(ctx context.Context, messageID string) (*SMSReply, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT `+smsReplyColumns+`
+		FROM sms_replies
+		WHERE message_id = ?
+	`, messageID)
+	return scanSMSReply(row)
+}
+
+func (r *SqliteRepository) InsertSMSReply(ctx context.Context, m SMSReply) (bool, error) {
+	res, err := r.q.ExecContext(ctx, `
+		INSERT INTO sms_replies (message_id, from_number, body, action, appointment_id, outcome, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (message_id) DO NOTHING
+	`, m.MessageID, m.From, m.Body, m.Action, m.AppointmentID, m.Outcome, m.ReceivedAt.UTC())
+	if err != nil {
+		return false, fmt.Errorf("insert sms reply: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *SqliteRepository) GetNextAppointmentByPhone(ctx context.Context, phone string, clinicID *uuid.UUID, now time.Time) (*Appointment, error) {
+	args := []any{phone, now.UTC()}
+	if clinicID != nil {
+		args = append(args, *clinicID)
+	}
+	query := nextByPhoneQuery(clinicID != nil, func(int) string { return "?" })
+	return scanAppointment(r.q.QueryRowContext(ctx, query, args...))
+}
+
+func (r *SqliteRepository) CreateAPIKey(ctx context.Context, k APIKey) (*APIKey, error) {
+	row := r.q.QueryRowContext(ctx, `
+		INSERT INTO api_keys (id, name, prefix, key_hash, scopes, clinic_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING `+apiKeyColumns+`
+	`, k.ID, k.Name, k.Prefix, k.KeyHash, joinScopes(k.Scopes), k.ClinicID, utcNow())
+	return scanAPIKey(row)
+}
+
+func (r *SqliteRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE key_hash = ?
+	`, keyHash)
+	return scanAPIKey(row)
+}
+
+func (r *SqliteRepository) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	defer rows.Close()
+	return collectAPIKeys(rows)
+}
+
+func (r *SqliteRepository) RevokeAPIKey(ctx context.Context, id uuid.UUID, at time.Time) (*APIKey, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, ?)
+		WHERE id = ?
+		RETURNING `+apiKeyColumns+`
+	`, at.UTC(), id)
+	return scanAPIKey(row)
+}
+
+func (r *SqliteRepository) RegisterDevice(ctx context.Context, d Device) (*Device, error) {
+	now := utcNow()
+	row := r.q.QueryRowContext(ctx, `
+		INSERT INTO patient_devices (id, patient_id, provider, token, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (token) DO UPDATE
+		SET patient_id = excluded.patient_id, provider = excluded.provider, updated_at = excluded.updated_at
+		RETURNING `+deviceColumns+`
+	`, d.ID, d.PatientID, d.Provider, d.Token, now, now)
+	return scanDevice(row)
+}
+
+func (r *SqliteRepository) ListPatientDevices(ctx context.Context, patientID uuid.UUID) ([]Device, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+deviceColumns+`
+		FROM patient_devices
+		WHERE patient_id = ?
+		ORDER BY created_at, id
+	`, patientID)
+	if err != nil {
+		return nil, fmt.Errorf("list patient devices: %w", err)
+	}
+	defer rows.Close()
+	return collectDevices(rows)
+}
+
+func (r *SqliteRepository) ListAppointmentDevices(ctx context.Context, appointmentID uuid.UUID) ([]Device, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT d.id, d.patient_id, d.provider, d.token, d.created_at, d.updated_at
+		FROM appointments a
+		JOIN patient_devices d ON d.patient_id = a.patient_id
+		WHERE a.id = ?
+		ORDER BY d.created_at, d.id
+	`, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("list appointment devices: %w", err)
+	}
+	defer rows.Close()
+	return collectDevices(rows)
+}
+
+func (r *SqliteRepository) DeletePatientDevice(ctx context.Context, patientID, deviceID uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE FROM patient_devices
+		WHERE id = ? AND patient_id = ?
+	`, deviceID, patientID)
+	if err != nil {
+		return fmt.Errorf("delete patient device: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (r *SqliteRepository) DeleteDeviceByToken(ctx context.Context, token string) error {
+	_, err := r.q.ExecContext(ctx, `
+		DELETE FROM patient_devices
+		WHERE token = ?
+	`, token)
+	if err != nil {
+		return fmt.Errorf("delete device by token: %w", err)
+	}
+	return nil
+}
+
+func (r *SqliteRepository) GetClinicianByID(ctx context.Context, id uuid.UUID) (*Clinician, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT `+clinicianColumns+`
+		FROM clinicians
+		WHERE id = ?
+	`, id)
+	return scanClinician(row)
+}
+
+func (r *SqliteRepository) CreateClinician(ctx context.Context, c Clinician) (*Clinician, error) {
+	now := utcNow()
+	created, err := scanClinician(r.q.QueryRowContext(ctx, `
+		INSERT INTO clinicians (id, name, specialty, clinic_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING `+clinicianColumns+`
+	`, c.ID, c.Name, c.Specialty, c.ClinicID, now, now))
+	if err != nil && isClinicianClinicViolation(err) {
+		return nil, ErrClinicNotFound
+	}
+	return created, err
+}
+
+func (r *SqliteRepository) UpdateClinician(ctx context.Context, id uuid.UUID, u ClinicianUpdate) (*Clinician, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE clinicians
+		SET name = COALESCE(?, name), specialty = COALESCE(?, specialty), updated_at = ?
+		WHERE id = ?
+		RETURNING `+clinicianColumns+`
+	`, u.Name, u.Specialty, utcNow(), id)
+	return scanClinician(row)
+}
+
+func (r *SqliteRepository) DeactivateClinician(ctx context.Context, id uuid.UUID, at time.Time) (*Clinician, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE clinicians
+		SET deactivated_at = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING `+clinicianColumns+`
+	`, at.UTC(), utcNow(), id)
+	return scanClinician(row)
+}
+
+func (r *SqliteRepository) GetAvailabilityVersion(ctx context.Context, clinicianID uuid.UUID) (int64, error) {
+	var version int64
+	err := r.q.QueryRowContext(ctx, `
+		SELECT COALESCE((SELECT version FROM clinician_availability_versions WHERE clinician_id = ?), 0)
+	`, clinicianID).Scan(&version)
+	return version, err
+}
+
+func (r *SqliteRepository) CountSlotsByDay(ctx context.Context, clinicianID uuid.UUID, days []DayRange, bookable DayRange, now time.Time) ([]DayAvailability, error) {
+	if len(days) == 0 {
+		return nil, nil
+	}
+	query := slotsByDayQuery(len(days), func(int, string) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, slotsByDayArgs(clinicianID, days, bookable, now, time.Time.UTC)...)
+	if err != nil {
+		return nil, fmt.Errorf("count slots by day: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSlotsByDay(rows, len(days))
+}
+
+func (r *SqliteRepository) GetSlotByID(ctx context.Context, id uuid.UUID) (*AppointmentSlot, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+		FROM appointment_slots
+		WHERE id = ?
+	`, id)
+	return scanSlot(row)
+}
+
+// LockSlot needs no row lock: SQLite runs one write transaction at a time
+func (r *SqliteRepository) LockSlot(ctx context.Context, id uuid.UUID) (*AppointmentSlot, error) {
+	return r.GetSlotByID(ctx, id)
+}
+
+func (r *SqliteRepository) GetSlotQuote(ctx context.Context, slotID uuid.UUID) (*SlotQuote, error) {
+	var q SlotQuote
+
+	err := r.q.QueryRowContext(ctx, `
+		SELECT s.id, c.clinic_id, s.slot_type, p.amount_minor, p.currency
+		FROM appointment_slots s
+		INNER JOIN clinicians c ON s.practitioner_id = c.id
+		INNER JOIN slot_type_prices p ON p.clinic_id = c.clinic_id AND p.slot_type = s.slot_type
+		WHERE s.id = ?
+	`, slotID).Scan(&q.SlotID, &q.ClinicID, &q.SlotType, &q.Price.AmountMinor, &q.Price.Currency)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, ErrPriceNotFound
+		}
+		return nil, err
+	}
+
+	return &q, nil
+}
+
+func (r *SqliteRepository) SlotRequiresApproval(ctx context.Context, slotID uuid.UUID) (bool, error) {
+	var required bool
+	err := r.q.QueryRowContext(ctx, `
+		SELECT COALESCE(k.requires_approval, 0)
+		FROM appointment_slots s
+		INNER JOIN clinicians c ON s.practitioner_id = c.id
+		LEFT JOIN clinics k ON k.id = c.clinic_id
+		WHERE s.id = ?
+	`, slotID).Scan(&required)
+	if err != nil {
+		if isNoRows(err) {
+			return false, ErrSlotNotFound
+		}
+		return false, err
+	}
+	return required, nil
+}
+
+func (r *SqliteRepository) GetAppointmentByID(ctx context.Context, id uuid.UUID) (*Appointment, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT id, slot_id, patient_id, status, created_at, updated_at, expires_at
+		FROM appointments
+		WHERE id = ?
+	`, id)
+	return scanAppointment(row)
+}
+
+func (r *SqliteRepository) GetSlotBookingWindow(ctx context.Context, slotID uuid.UUID) (*BookingWindow, error) {
+	return scanSlotBookingWindow(r.q.QueryRowContext(ctx, slotBookingWindowColumns+`
+		WHERE s.id = ?
+	`, slotID))
+}
+
+func (r *SqliteRepository) GetBookingWindow(ctx context.Context, specialty string) (*BookingWindow, error) {
+	return scanBookingWindow(r.q.QueryRowContext(ctx, bookingWindowSelect+`
+		WHERE specialty = ?
+	`, specialty))
+}
+
+func (r *SqliteRepository) ListBookingWindows(ctx context.Context) ([]BookingWindow, error) {
+	rows, err := r.q.QueryContext(ctx, bookingWindowSelect+`
+		ORDER BY specialty
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list booking windows: %w", err)
+	}
+	defer rows.Close()
+
+	var result []BookingWindow
+	for rows.Next() {
+		w, err := scanBookingWindow(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) PutBookingWindow(ctx context.Context, w BookingWindow) (*BookingWindow, error) {
+	row := r.q.QueryRowContext(ctx, `
+		INSERT INTO specialty_booking_policies (specialty, min_lead_seconds, max_lead_seconds, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (specialty)
+		DO UPDATE SET min_lead_seconds = excluded.min_lead_seconds,
+		              max_lead_seconds = excluded.max_lead_seconds,
+		              updated_at = excluded.updated_at
+		RETURNING specialty, min_lead_seconds, max_lead_seconds, updated_at
+	`, w.Specialty, leadSeconds(&w.MinLead), leadSeconds(w.MaxLead), utcNow())
+	saved, err := scanBookingWindow(row)
+	if err != nil {
+		return nil, fmt.Errorf("put booking window: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *SqliteRepository) DeleteBookingWindow(ctx context.Context, specialty string) error {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE FROM specialty_booking_policies
+		WHERE specialty = ?
+	`, specialty)
+	if err != nil {
+		return fmt.Errorf("delete booking window: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrBookingWindowNotFound
+	}
+	return nil
+}
+
+func (r *SqliteRepository) HasBlockingAppointment(ctx context.Context, slotID uuid.UUID) (SlotBlockers, error) {
+	var b SlotBlockers
+	query := blockingAppointmentQuery(func(int) string { return "?" })
+	err := r.q.QueryRowContext(ctx, query, slotID, slotID, slotID, slotID).Scan(&b.Confirmed, &b.Held)
+	return b, err
+}
+
+func (r *SqliteRepository) CountConfirmedAppointmentsForSlot(ctx context.Context, slotID uuid.UUID) (int, error) {
+	var n int
+	err := r.q.QueryRowContext(ctx, `
+		SELECT count(*)
+		FROM appointments
+		WHERE status IN ('confirmed', 'checked_in')
+		  AND (slot_id = ?
+		       OR id IN (SELECT appointment_id FROM appointment_extra_slots WHERE slot_id = ?))
+	`, slotID, slotID).Scan(&n)
+	return n, err
+}
+
+// CountTakenPlaces needs no row lock: SQLite runs one write transaction
+// at a time
+func (r *SqliteRepository) CountTakenPlaces(ctx context.Context, slotID uuid.UUID, now time.Time) (int, error) {
+	var n int
+	query := takenPlacesQuery(func(int) string { return "?" })
+	err := r.q.QueryRowContext(ctx, query, slotID, slotID, now.UTC()).Scan(&n)
+	return n, err
+}
+
+func (r *SqliteRepository) ListPatientConflicts(ctx context.Context, patientID uuid.UUID, start, end time.Time) ([]Appointment, error) {
+	query := patientConflictsQuery(func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, patientID, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list patient conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Appointment
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *SqliteRepository) ListSyncEvents(ctx context.Context, scope SyncScope, after int64, before time.Time, limit int) ([]SyncEvent, error) {
+	param := func(int) string { return "?" }
+	query := syncEventsQuery(scope.ClinicianID != nil, param)
+	args := []any{after, before.UTC(), scope.ClinicID}
+	if scope.ClinicianID != nil {
+		args = append(args, *scope.ClinicianID)
+	}
+	args = append(args, limit)
+	query += " LIMIT " + param(len(args))
+
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list sync events: %w", err)
+	}
+	defer rows.Close()
+
+	var result []SyncEvent
+	for rows.Next() {
+		var e SyncEvent
+		if err := rows.Scan(&e.ID, &e.AppointmentID); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *SqliteRepository) EventSequenceBounds(ctx context.Context, before time.Time) (oldest, latest int64, err error) {
+	err = r.q.QueryRowContext(ctx, `
+		SELECT COALESCE((SELECT min(id) FROM event_logs), 0),
+		       COALESCE((SELECT max(id) FROM event_logs WHERE created_at < ?), 0)
+	`, before.UTC()).Scan(&oldest, &latest)
+	return oldest, latest, err
+}
+
+func (r *SqliteRepository) ListClinicianSlotsOverlapping(ctx context.Context, clinicianID uuid.UUID, start, end time.Time) ([]AppointmentSlot, error) {
+	query := clinicianSlotsOverlappingQuery(func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, clinicianID, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list overlapping slots: %w", err)
+	}
+	defer rows.Close()
+
+	var result []AppointmentSlot
+	for rows.Next() {
+		s, err := scanSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *SqliteRepository) ListActiveSlotAppointments(ctx context.Context, slotID uuid.UUID) ([]Appointment, error) {
+	query := activeSlotAppointmentsQuery(func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, slotID, slotID)
+	if err != nil {
+		return nil, fmt.Errorf("list slot appointments: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Appointment
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *SqliteRepository) ReassignSlot(ctx context.Context, slotID, from, to uuid.UUID) (*AppointmentSlot, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE appointment_slots
+		SET practitioner_id = ?, updated_at = ?
+		WHERE id = ? AND practitioner_id = ?
+		RETURNING id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+	`, to, utcNow(), slotID, from)
+	return scanSlot(row)
+}
+
+func (r *SqliteRepository) UpdateSlot(ctx context.Context, s AppointmentSlot) (*AppointmentSlot, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE appointment_slots
+		SET start_time = ?, end_time = ?, status = ?, capacity = ?, slot_type = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+	`, s.StartTime.UTC(), s.EndTime.UTC(), s.Status, s.Capacity, s.SlotType, utcNow(), s.ID)
+	return scanSlot(row)
+}
+
+func (r *SqliteRepository) CreatePendingAppointment(ctx context.Context, slotID, patientID uuid.UUID, expiresAt time.Time) (*Appointment, error) {
+	id := uuid.New()
+	now := utcNow()
+
+	row := r.q.QueryRowContext(ctx, `
+		INSERT INTO appointments (id, slot_id, patient_id, status, created_at, updated_at, expires_at)
+		VALUES (?, ?, ?, 'pending', ?, ?, ?)
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, id, slotID, patientID, now, now, expiresAt.UTC())
+
+	return scanAppointment(row)
+}
+
+func (r *SqliteRepository) UpdateAppointmentStatus(ctx context.Context, id uuid.UUID, from, to AppointmentStatus) (*Appointment, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE appointments
+		SET status = ?,
+		    updated_at = ?
+		WHERE id = ?
+		  AND status = ?
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, to, utcNow(), id, from)
+
+	return scanAppointment(row)
+}
+
+func (r *SqliteRepository) CheckInAppointment(ctx context.Context, id uuid.UUID, at time.Time) (*Appointment, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE appointments
+		SET status = 'checked_in',
+		    checked_in_at = ?,
+		    updated_at = ?
+		WHERE id = ?
+		  AND status = 'confirmed'
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, at.UTC(), utcNow(), id)
+
+	return scanAppointment(row)
+}
+
+func (r *SqliteRepository) TransferHold(ctx context.Context, id, from, to uuid.UUID, now time.Time) (*Appointment, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE appointments
+		SET slot_id = ?,
+		    updated_at = ?
+		WHERE id = ?
+		  AND slot_id = ?
+		  AND status = 'pending'
+		  AND (expires_at IS NULL OR expires_at >= ?)
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, to, utcNow(), id, from, now.UTC())
+
+	return scanAppointment(row)
+}
+
+func (r *SqliteRepository) ResolvePendingAppointment(ctx context.Context, id uuid.UUID, to AppointmentStatus, now time.Time) (*Appointment, error) {
+	var deadline string
+	switch to {
+	case StatusConfirmed:
+		deadline = "(expires_at IS NULL OR expires_at >= ?)"
+	case StatusExpired:
+		deadline = "(expires_at IS NOT NULL AND expires_at < ?)"
+	default:
+		return nil, fmt.Errorf("resolve pending appointment to %s: %w", to, ErrInvalidStatusTransition)
+	}
+
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE appointments
+		SET status = ?,
+		    updated_at = ?
+		WHERE id = ?
+		  AND status = 'pending'
+		  AND `+deadline+`
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, to, utcNow(), id, now.UTC())
+
+	return scanAppointment(row)
+}
+
+func (r *SqliteRepository) RequestApproval(ctx context.Context, id uuid.UUID, now, deadline time.Time) (*Appointment, error) {
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE appointments
+		SET status = 'pending_approval',
+		    expires_at = ?,
+		    updated_at = ?
+		WHERE id = ?
+		  AND status = 'pending'
+		  AND (expires_at IS NULL OR expires_at >= ?)
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, deadline.UTC(), utcNow(), id, now.UTC())
+
+	return scanAppointment(row)
+}
+
+func (r *SqliteRepository) ResolveApproval(ctx context.Context, id uuid.UUID, to AppointmentStatus, now time.Time) (*Appointment, error) {
+	args := []any{to, utcNow(), id}
+	var deadline string
+	switch to {
+	case StatusConfirmed:
+		deadline = "AND (expires_at IS NULL OR expires_at >= ?)"
+		args = append(args, now.UTC())
+	case StatusRejected:
+	default:
+		return nil, fmt.Errorf("resolve approval to %s: %w", to, ErrInvalidStatusTransition)
+	}
+
+	row := r.q.QueryRowContext(ctx, `
+		UPDATE appointments
+		SET status = ?,
+		    updated_at = ?
+		WHERE id = ?
+		  AND status = 'pending_approval'
+		  `+deadline+`
+		RETURNING id, slot_id, patient_id, status, created_at, updated_at, expires_at
+	`, args...)
+
+	return scanAppointment(row)
+}
+
+func (r *SqliteRepository) FindExpiredPending(ctx context.Context, now time.Time) ([]Appointment, error) {
+	return r.findPastDeadline(ctx, StatusPending, now)
+}
+
+func (r *SqliteRepository) FindOverdueApprovals(ctx context.Context, now time.Time) ([]Appointment, error) {
+	return r.findPastDeadline(ctx, StatusPendingApproval, now)
+}
+
+// findPastDeadline lists appointments in status whose expires_at passed before now
+func (r *SqliteRepository) findPastDeadline(ctx context.Context, status AppointmentStatus, now time.Time) ([]Appointment, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT id, slot_id, patient_id, status, created_at, updated_at, expires_at
+		FROM appointments
+		WHERE status = ?
+		  AND expires_at IS NOT NULL
+		  AND expires_at < ?
+	`, status, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Appointment
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) CountOverduePending(ctx context.Context, cutoffs []time.Time) ([]int, *time.Time, error) {
+	args := make([]any, len(cutoffs))
+	for i, c := range cutoffs {
+		args[i] = c.UTC()
+	}
+	counts := make([]int, len(cutoffs))
+	dest := make([]any, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	query := overduePendingQuery(func(int) string { return "?" }, len(cutoffs))
+	if err := r.q.QueryRowContext(ctx, query, args...).Scan(dest...); err != nil {
+		return nil, nil, fmt.Errorf("count overdue pending: %w", err)
+	}
+
+	var oldest time.Time
+	err := r.q.QueryRowContext(ctx, `
+		SELECT expires_at
+		FROM appointments
+		WHERE status = 'pending'
+		  AND expires_at IS NOT NULL
+		  AND expires_at < ?
+		ORDER BY expires_at
+		LIMIT 1
+	`, args[0]).Scan(&oldest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return counts, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("find oldest overdue pending: %w", err)
+	}
+	return counts, &oldest, nil
+}
+
+func (r *SqliteRepository) InsertEvent(ctx context.Context, ev EventLog) error {
+	createdAt := ev.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO event_logs (event_type, appointment_id, payload, created_at)
+		VALUES (?, ?, ?, ?)
+	`, ev.EventType, ev.AppointmentID, ev.Payload, createdAt.UTC())
+	if err != nil {
+		return fmt.Errorf("insert event log: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SqliteRepository) ListExpiryEventMismatches(ctx context.Context, since time.Time, limit int) ([]ExpiryEventMismatch, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT a.id, a.status, count(*), a.updated_at
+		FROM event_logs e
+		JOIN appointments a ON a.id = e.appointment_id
+		WHERE e.event_type = ?
+		  AND e.created_at >= ?
+		GROUP BY a.id, a.status, a.updated_at
+		HAVING a.status <> 'expired' OR count(*) > 1
+		ORDER BY a.updated_at DESC
+		LIMIT ?
+	`, EventAppointmentExpired, since.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("list expiry event mismatches: %w", err)
+	}
+	defer rows.Close()
+
+	var result []ExpiryEventMismatch
+	for rows.Next() {
+		var m ExpiryEventMismatch
+		if err := rows.Scan(&m.AppointmentID, &m.Status, &m.ExpiredEvents, &m.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan expiry event mismatch: %w", err)
+		}
+		result = append(result, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) ListOrphanedAppointments(ctx context.Context, staleBefore time.Time, limit int) ([]OrphanedAppointment, error) {
+	query := orphanedAppointmentsQuery(func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, staleBefore.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("list orphaned appointments: %w", err)
+	}
+	defer rows.Close()
+
+	var result []OrphanedAppointment
+	for rows.Next() {
+		o, err := scanOrphanedAppointment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan orphaned appointment: %w", err)
+		}
+		result = append(result, *o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) ListPatientTimeline(ctx context.Context, patientID uuid.UUID, limit int) ([]TimelineEntry, error) {
+	query := patientTimelineQuery(func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, patientID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list patient timeline: %w", err)
+	}
+	defer rows.Close()
+
+	var result []TimelineEntry
+	for rows.Next() {
+		t, err := scanTimelineEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan timeline entry: %w", err)
+		}
+		result = append(result, *t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) ListClinicianSlotsAt(ctx context.Context, clinicianID uuid.UUID, starts []time.Time) ([]AppointmentSlot, error) {
+	if len(starts) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, 0, len(starts)+1)
+	args = append(args, clinicianID)
+	for _, t := range starts {
+		args = append(args, t.UTC())
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(starts)), ", ")
+
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+		FROM appointment_slots
+		WHERE practitioner_id = ?
+		  AND start_time IN (`+placeholders+`)
+		ORDER BY start_time, id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list clinician slots: %w", err)
+	}
+	defer rows.Close()
+
+	var result []AppointmentSlot
+	for rows.Next() {
+		s, err := scanSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) CreateSeries(ctx context.Context, s Series) (*Series, error) {
+	row := r.q.QueryRowContext(ctx, `
+		INSERT INTO appointment_series (id, patient_id, clinician_id, interval_days, time_zone, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id, patient_id, clinician_id, interval_days, time_zone, created_at
+	`, s.ID, s.PatientID, s.ClinicianID, s.IntervalDays, s.TimeZone, utcNow())
+	created, err := scanSeries(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert series: %w", err)
+	}
+	return created, nil
+}
+
+func (r *SqliteRepository) AddSeriesOccurrence(ctx context.Context, seriesID uuid.UUID, number int, appointmentID uuid.UUID) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO appointment_series_occurrences (series_id, occurrence, appointment_id)
+		VALUES (?, ?, ?)
+	`, seriesID, number, appointmentID)
+	if err != nil {
+		return fmt.Errorf("insert series occurrence: %w", err)
+	}
+	return nil
+}
+
+func (r *SqliteRepository) MoveSeriesOccurrence(ctx context.Context, seriesID uuid.UUID, number int, appointmentID uuid.UUID, exception bool) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE appointment_series_occurrences
+		SET appointment_id = ?,
+		    exception = exception OR ?
+		WHERE series_id = ?
+		  AND occurrence = ?
+	`, appointmentID, exception, seriesID, number)
+	if err != nil {
+		return fmt.Errorf("update series occurrence: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrOccurrenceNotFound
+	}
+	return nil
+}
+
+func (r *SqliteRepository) MoveAppointmentOccurrence(ctx context.Context, from, to uuid.UUID) (bool, error) {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE appointment_series_occurrences
+		SET appointment_id = ?,
+		    exception = 1
+		WHERE appointment_id = ?
+	`, to, from)
+	if err != nil {
+		return false, fmt.Errorf("update series occurrence: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *SqliteRepository) GetSeries(ctx context.Context, id uuid.UUID) (*Series, error) {
+	s, err := scanSeries(r.q.QueryRowContext(ctx, seriesSelect+`
+		WHERE id = ?
+	`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	query := seriesOccurrencesQuery(func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("list series occurrences: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		o, err := scanSeriesOccurrence(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan series occurrence: %w", err)
+		}
+		s.Occurrences = append(s.Occurrences, *o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (r *SqliteRepository) AddExtraSlots(ctx context.Context, appointmentID uuid.UUID, slotIDs []uuid.UUID) error {
+	for i, slotID := range slotIDs {
+		_, err := r.q.ExecContext(ctx, `
+			INSERT INTO appointment_extra_slots (appointment_id, position, slot_id)
+			VALUES (?, ?, ?)
+		`, appointmentID, i+2, slotID)
+		if err != nil {
+			return fmt.Errorf("insert extra slot: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *SqliteRepository) ListAppointmentSlots(ctx context.Context, appointmentID uuid.UUID) ([]AppointmentSlot, error) {
+	query := appointmentSlotsQuery(func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, appointmentID, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("list appointment slots: %w", err)
+	}
+	defer rows.Close()
+
+	var result []AppointmentSlot
+	for rows.Next() {
+		s, err := scanSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, ErrAppointmentNotFound
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) ListAvailableResources(ctx context.Context, clinicID uuid.UUID, req ResourceRequirement, start, end time.Time) ([]StaffResource, error) {
+	rows, err := r.q.QueryContext(ctx, availableResourcesQuery(func(int) string { return "?" }), clinicID, req.Kind, req.Language, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list available resources: %w", err)
+	}
+	defer rows.Close()
+
+	var result []StaffResource
+	for rows.Next() {
+		res, err := scanStaffResource(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) ReserveResource(ctx context.Context, appointmentID, resourceID uuid.UUID, start, end time.Time) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO appointment_resources (appointment_id, resource_id, start_time, end_time)
+		VALUES (?, ?, ?, ?)
+	`, appointmentID, resourceID, start.UTC(), end.UTC())
+	if err != nil {
+		return fmt.Errorf("reserve resource: %w", err)
+	}
+	return nil
+}
+
+func (r *SqliteRepository) ListAppointmentResources(ctx context.Context, appointmentID uuid.UUID) ([]StaffResource, error) {
+	rows, err := r.q.QueryContext(ctx, appointmentResourcesQuery(func(int) string { return "?" }), appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("list appointment resources: %w", err)
+	}
+	defer rows.Close()
+
+	var result []StaffResource
+	for rows.Next() {
+		res, err := scanStaffResource(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) InsertAttachment(ctx context.Context, a Attachment) (*Attachment, error) {
+	row := r.q.QueryRowContext(ctx, `
+		INSERT INTO appointment_attachments (`+attachmentColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING `+attachmentColumns,
+		a.ID, a.AppointmentID, a.Kind, a.Filename, a.ContentType, a.Size, a.BlobKey, a.ScanStatus, utcNow())
+	saved, err := scanAttachment(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert attachment: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *SqliteRepository) GetAttachment(ctx context.Context, id uuid.UUID) (*Attachment, error) {
+	return scanAttachment(r.q.QueryRowContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM appointment_attachments
+		WHERE id = ?
+	`, id))
+}
+
+func (r *SqliteRepository) ListAttachments(ctx context.Context, appointmentID uuid.UUID) ([]Attachment, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM appointment_attachments
+		WHERE appointment_id = ?
+		ORDER BY created_at, id
+	`, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("list attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) SetAppointmentReason(ctx context.Context, id uuid.UUID, reason string) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE appointments
+		SET reason = ?,
+		    updated_at = ?
+		WHERE id = ?
+	`, reason, utcNow(), id)
+	if err != nil {
+		return fmt.Errorf("set appointment reason: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAppointmentNotFound
+	}
+	return nil
+}
+
+func (r *SqliteRepository) InsertAppointmentNote(ctx context.Context, n AppointmentNote) (*AppointmentNote, error) {
+	row := r.q.QueryRowContext(ctx, `
+		INSERT INTO appointment_notes (`+noteColumns+`)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING `+noteColumns,
+		n.ID, n.AppointmentID, n.Author, n.Body, utcNow())
+	saved, err := scanNote(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert appointment note: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *SqliteRepository) ListAppointmentNotes(ctx context.Context, appointmentID uuid.UUID) ([]AppointmentNote, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+noteColumns+`
+		FROM appointment_notes
+		WHERE appointment_id = ?
+		ORDER BY created_at, id
+	`, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("list appointment notes: %w", err)
+	}
+	defer rows.Close()
+
+	var result []AppointmentNote
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *SqliteRepository) GetIntakeTemplate(ctx context.Context, slotType string) (*IntakeTemplate, error) {
+	return scanIntakeTemplate(r.q.QueryRowContext(ctx, intakeTemplateSelect+`
+		WHERE slot_type = ?
+	`, slotType))
+}
+
+func (r *SqliteRepository) ListIntakeTemplates(ctx context.Context) ([]IntakeTemplate, error) {
+	rows, err := r.q.QueryContext(ctx, intakeTemplateSelect+`
+		ORDER BY slot_type
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list intake templates: %w", err)
+	}
+	defer rows.Close()
+
+	var result []IntakeTemplate
+	for rows.Next() {
+		t, err := scanIntakeTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) PutIntakeTemplate(ctx context.Context, t IntakeTemplate) (*IntakeTemplate, error) {
+	questions, err := encodeIntakeQuestions(t.Questions)
+	if err != nil {
+		return nil, fmt.Errorf("encode intake questions: %w", err)
+	}
+	row := r.q.QueryRowContext(ctx, `
+		INSERT INTO intake_templates (slot_type, version, questions, updated_at)
+		VALUES (?, 1, ?, ?)
+		ON CONFLICT (slot_type)
+		DO UPDATE SET version = intake_templates.version + 1,
+		              questions = excluded.questions,
+		              updated_at = excluded.updated_at
+		RETURNING slot_type, version, questions, updated_at
+	`, t.SlotType, questions, utcNow())
+	saved, err := scanIntakeTemplate(row)
+	if err != nil {
+		return nil, fmt.Errorf("put intake template: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *SqliteRepository) DeleteIntakeTemplate(ctx context.Context, slotType string) error {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE FROM intake_templates
+		WHERE slot_type = ?
+	`, slotType)
+	if err != nil {
+		return fmt.Errorf("delete intake template: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrIntakeTemplateNotFound
+	}
+	return nil
+}
+
+func (r *SqliteRepository) GetAvailabilityTemplate(ctx context.Context, clinicianID uuid.UUID) (*AvailabilityTemplate, error) {
+	row := r.q.QueryRowContext(ctx, `
+		SELECT `+availabilityTemplateColumns+`
+		FROM availability_templates
+		WHERE clinician_id = ?
+	`, clinicianID)
+	return scanAvailabilityTemplate(row)
+}
+
+func (r *SqliteRepository) PutAvailabilityTemplate(ctx context.Context, t AvailabilityTemplate) (*AvailabilityTemplate, error) {
+	periods, err := encodeAvailabilityPeriods(t.Periods)
+	if err != nil {
+		return nil, fmt.Errorf("encode availability periods: %w", err)
+	}
+	row := r.q.QueryRowContext(ctx, `
+		INSERT INTO availability_templates (clinician_id, time_zone, slot_minutes, capacity, slot_type, periods, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (clinician_id)
+		DO UPDATE SET time_zone = excluded.time_zone,
+		              slot_minutes = excluded.slot_minutes,
+		              capacity = excluded.capacity,
+		              slot_type = excluded.slot_type,
+		              periods = excluded.periods,
+		              updated_at = excluded.updated_at
+		RETURNING `+availabilityTemplateColumns+`
+	`, t.ClinicianID, t.TimeZone, int(t.SlotDuration/time.Minute), t.Capacity, t.SlotType, periods, utcNow())
+	saved, err := scanAvailabilityTemplate(row)
+	if err != nil {
+		return nil, fmt.Errorf("put availability template: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *SqliteRepository) DeleteAvailabilityTemplate(ctx context.Context, clinicianID uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE FROM availability_templates
+		WHERE clinician_id = ?
+	`, clinicianID)
+	if err != nil {
+		return fmt.Errorf("delete availability template: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAvailabilityTemplateNotFound
+	}
+	return nil
+}
+
+func (r *SqliteRepository) CreateSlots(ctx context.Context, slots []AppointmentSlot) ([]AppointmentSlot, error) {
+	now := utcNow()
+	created := make([]AppointmentSlot, 0, len(slots))
+	for _, s := range slots {
+		saved, err := scanSlot(r.q.QueryRowContext(ctx, `
+			INSERT INTO appointment_slots (id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at
+		`, s.ID, s.PractitionerID, s.StartTime.UTC(), s.EndTime.UTC(), s.Status, s.Capacity, s.SlotType, now, now))
+		if err != nil {
+			return nil, fmt.Errorf("create slot: %w", err)
+		}
+		created = append(created, *saved)
+	}
+	return created, nil
+}
+
+func (r *SqliteRepository) GetIntakeResponse(ctx context.Context, appointmentID uuid.UUID) (*IntakeResponse, error) {
+	return scanIntakeResponse(r.q.QueryRowContext(ctx, `
+		SELECT `+intakeResponseColumns+`
+		FROM intake_responses
+		WHERE appointment_id = ?
+	`, appointmentID))
+}
+
+func (r *SqliteRepository) PutIntakeResponse(ctx context.Context, resp IntakeResponse) (*IntakeResponse, error) {
+	now := utcNow()
+	answers, err := json.Marshal(resp.Answers)
+	if err != nil {
+		return nil, fmt.Errorf("encode intake answers: %w", err)
+	}
+	row := r.q.QueryRowContext(ctx, `
+		INSERT INTO intake_responses (appointment_id, template_version, answers, complete, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (appointment_id)
+		DO UPDATE SET template_version = excluded.template_version,
+		              answers = excluded.answers,
+		              complete = excluded.complete,
+		              updated_at = excluded.updated_at
+		RETURNING `+intakeResponseColumns,
+		resp.AppointmentID, resp.TemplateVersion, string(answers), resp.Complete, now, now)
+	saved, err := scanIntakeResponse(row)
+	if err != nil {
+		return nil, fmt.Errorf("put intake response: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *SqliteRepository) ListIntakeReminders(ctx context.Context, from, to time.Time, eventType string, limit int) ([]IntakeReminder, error) {
+	rows, err := r.q.QueryContext(ctx, intakeRemindersQuery(func(int) string { return "?" }), from.UTC(), to.UTC(), eventType, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list intake reminders: %w", err)
+	}
+	defer rows.Close()
+
+	var result []IntakeReminder
+	for rows.Next() {
+		var rem IntakeReminder
+		if err := rows.Scan(&rem.AppointmentID, &rem.SlotType, &rem.SlotStart); err != nil {
+			return nil, err
+		}
+		result = append(result, rem)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) InsertFeedback(ctx context.Context, f Feedback) (*Feedback, error) {
+	now := utcNow()
+	saved, err := scanFeedback(r.q.QueryRowContext(ctx, `
+		INSERT INTO appointment_feedback (appointment_id, clinician_id, rating, comment, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (appointment_id) DO NOTHING
+		RETURNING `+feedbackColumns,
+		f.AppointmentID, f.ClinicianID, f.Rating, f.Comment, now))
+	if errors.Is(err, ErrFeedbackNotFound) {
+		return nil, ErrFeedbackAlreadyGiven
+	}
+	if err != nil {
+		return nil, fmt.Errorf("insert feedback: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *SqliteRepository) GetFeedback(ctx context.Context, appointmentID uuid.UUID) (*Feedback, error) {
+	return scanFeedback(r.q.QueryRowContext(ctx, `
+		SELECT `+feedbackColumns+`
+		FROM appointment_feedback
+		WHERE appointment_id = ?
+	`, appointmentID))
+}
+
+func (r *SqliteRepository) ListClinicianRatings(ctx context.Context, since time.Time, minCount int) ([]ClinicianRating, error) {
+	rows, err := r.q.QueryContext(ctx, clinicianRatingsQuery(func(int) string { return "?" }), since.UTC(), minCount)
+	if err != nil {
+		return nil, fmt.Errorf("list clinician ratings: %w", err)
+	}
+	defer rows.Close()
+
+	var result []ClinicianRating
+	for rows.Next() {
+		cr, err := scanClinicianRating(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *cr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) ListClinicianFeedback(ctx context.Context, clinicianID uuid.UUID, limit int) ([]Feedback, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+feedbackColumns+`
+		FROM appointment_feedback
+		WHERE clinician_id = ?
+		ORDER BY created_at DESC, appointment_id
+		LIMIT ?
+	`, clinicianID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list clinician feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Feedback
+	for rows.Next() {
+		f, err := scanFeedback(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) ListFeedbackRequests(ctx context.Context, from, to time.Time, eventType string, limit int) ([]FeedbackRequest, error) {
+	rows, err := r.q.QueryContext(ctx, feedbackRequestsQuery(func(int) string { return "?" }), from.UTC(), to.UTC(), to.UTC(), eventType, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list feedback requests: %w", err)
+	}
+	defer rows.Close()
+
+	var result []FeedbackRequest
+	for rows.Next() {
+		var req FeedbackRequest
+		if err := rows.Scan(&req.AppointmentID, &req.ClinicianID, &req.SlotEnd); err != nil {
+			return nil, err
+		}
+		result = append(result, req)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) GetRetentionPolicy(ctx context.Context) (*RetentionPolicy, error) {
+	return scanRetentionPolicy(r.q.QueryRowContext(ctx, `
+		SELECT `+retentionPolicyColumns+`
+		FROM retention_policy
+		WHERE id = 1
+	`))
+}
+
+func (r *SqliteRepository) PutRetentionPolicy(ctx context.Context, p RetentionPolicy) (*RetentionPolicy, error) {
+	saved, err := scanRetentionPolicy(r.q.QueryRowContext(ctx, `
+		INSERT INTO retention_policy (id, tenant_id, appointment_months, event_months, feedback_months, updated_at)
+		VALUES (1, ?, ?, ?, ?, ?)
+		ON CONFLICT (id)
+		DO UPDATE SET tenant_id = excluded.tenant_id,
+		              appointment_months = excluded.appointment_months,
+		              event_months = excluded.event_months,
+		              feedback_months = excluded.feedback_months,
+		              updated_at = excluded.updated_at
+		RETURNING `+retentionPolicyColumns,
+		p.TenantID, p.AppointmentMonths, p.EventMonths, p.FeedbackMonths, utcNow()))
+	if err != nil {
+		return nil, fmt.Errorf("put retention policy: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *SqliteRepository) DeleteRetentionPolicy(ctx context.Context) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM retention_policy WHERE id = 1`)
+	if err != nil {
+		return fmt.Errorf("delete retention policy: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRetentionPolicyNotFound
+	}
+	return nil
+}
+
+func (r *SqliteRepository) CountExpiredRecords(ctx context.Context, entity RetentionEntity, before time.Time) (int, error) {
+	query := expiredRecordsCountQuery(entity, func(int) string { return "?" })
+	args := []any{before.UTC()}
+	if entity == RetainAppointments {
+		args = append(args, before.UTC())
+	}
+	var n int
+	if err := r.q.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count expired %s: %w", entity, err)
+	}
+	return n, nil
+}
+
+func (r *SqliteRepository) ListExpiredAppointments(ctx context.Context, before time.Time, limit int) ([]uuid.UUID, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT a.id`+expiredAppointmentsWhere(func(int) string { return "?" })+`
+		ORDER BY s.end_time, a.id
+		LIMIT ?
+	`, before.UTC(), before.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("list expired appointments: %w", err)
+	}
+	defer rows.Close()
+
+	var result []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		result = append(result, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) ListAttachmentBlobKeys(ctx context.Context, appointmentIDs []uuid.UUID) ([]string, error) {
+	if len(appointmentIDs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, len(appointmentIDs))
+	for i, id := range appointmentIDs {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(appointmentIDs)), ", ")
+
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT blob_key
+		FROM appointment_attachments
+		WHERE appointment_id IN (`+placeholders+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list attachment blob keys: %w", err)
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		result = append(result, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) DeleteAppointments(ctx context.Context, ids []uuid.UUID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	values := strings.TrimSuffix(strings.Repeat("(?), ", len(ids)), ", ")
+
+	var deleted int64
+	for _, stmt := range appointmentDeleteStatements(`VALUES ` + values) {
+		res, err := r.q.ExecContext(ctx, stmt, args...)
+		if err != nil {
+			return 0, fmt.Errorf("delete appointments: %w", err)
+		}
+		if deleted, err = res.RowsAffected(); err != nil {
+			return 0, err
+		}
+	}
+	return int(deleted), nil
+}
+
+func (r *SqliteRepository) DeleteEventsBefore(ctx context.Context, before time.Time, limit int) (int, error) {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE FROM event_logs
+		WHERE id IN (
+		    SELECT id
+		    FROM event_logs
+		    WHERE created_at < ?
+		    ORDER BY created_at
+		    LIMIT ?
+		)
+	`, before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("delete events: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func (r *SqliteRepository) DeleteFeedbackBefore(ctx context.Context, before time.Time, limit int) (int, error) {
+	res, err := r.q.ExecContext(ctx, `
+		DELETE FROM appointment_feedback
+		WHERE appointment_id IN (
+		    SELECT appointment_id
+		    FROM appointment_feedback
+		    WHERE created_at < ?
+		    ORDER BY created_at
+		    LIMIT ?
+		)
+	`, before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("delete feedback: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func (r *SqliteRepository) InsertRetentionRun(ctx context.Context, run RetentionRun) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO retention_runs (`+retentionRunColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, run.ID, run.Entity, run.TenantID, run.RetainMonths, run.Cutoff.UTC(), run.DryRun,
+		run.Matched, run.Deleted, run.StartedAt.UTC(), run.FinishedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("insert retention run: %w", err)
+	}
+	return nil
+}
+
+func (r *SqliteRepository) ListRetentionRuns(ctx context.Context, limit int) ([]RetentionRun, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+retentionRunColumns+`
+		FROM retention_runs
+		ORDER BY started_at DESC, id
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list retention runs: %w", err)
+	}
+	defer rows.Close()
+
+	var result []RetentionRun
+	for rows.Next() {
+		run, err := scanRetentionRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) SearchSlots(ctx context.Context, q SlotQuery, now time.Time) ([]OpenSlot, error) {
+	var after *pageKey
+	if q.Token != "" {
+		key, err := decodePageToken(q.Token)
+		if err != nil {
+			return nil, err
+		}
+		after = key
+	}
+
+	query, args := slotsQuery(q, after, now.UTC(), func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search slots: %w", err)
+	}
+	defer rows.Close()
+
+	var result []OpenSlot
+	for rows.Next() {
+		o, err := scanOpenSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// UpsertPatientByEmail updates nothing on a match; the no-op update only
+// makes RETURNING give back the existing row
+func (r *SqliteRepository) UpsertPatientByEmail(ctx context.Context, p Patient) (*Patient, error) {
+	now := utcNow()
+	row := r.q.QueryRowContext(ctx, `
+		INSERT INTO patients (id, name, email, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (email) DO UPDATE SET email = excluded.email
+		RETURNING `+patientColumns+`
+	`, p.ID, p.Name, p.Email, now, now)
+	return scanPatient(row)
+}
+
+func (r *SqliteRepository) InsertPIIAccess(ctx context.Context, records []PIIAccess) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(records)*8)
+	for _, a := range records {
+		args = append(args, a.ID, a.Actor, a.ActorKind, a.Reason, a.PatientID, a.Endpoint, a.RequestID, a.AccessedAt.UTC())
+	}
+	values := strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?, ?, ?, ?, ?), ", len(records)), ", ")
+
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO pii_access_log (`+piiAccessColumns+`)
+		VALUES `+values, args...)
+	if err != nil {
+		return fmt.Errorf("insert pii access: %w", err)
+	}
+	return nil
+}
+
+func (r *SqliteRepository) ListPIIAccess(ctx context.Context, filter PIIAccessFilter) ([]PIIAccess, error) {
+	query, args := piiAccessQuery(filter, func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list pii access: %w", err)
+	}
+	defer rows.Close()
+
+	var result []PIIAccess
+	for rows.Next() {
+		a, err := scanPIIAccess(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) CreateBookingIntent(ctx context.Context, intent BookingIntent) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO booking_intents (id, slot_id, patient_id, lock_token, state, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, intent.ID, intent.SlotID, intent.PatientID, intent.LockToken, intent.State, utcNow())
+	if err != nil {
+		return fmt.Errorf("insert booking intent: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SqliteRepository) ResolveBookingIntent(ctx context.Context, id uuid.UUID, state BookingIntentState, appointmentID *uuid.UUID) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE booking_intents
+		SET state = ?,
+		    appointment_id = ?,
+		    resolved_at = ?
+		WHERE id = ?
+	`, state, appointmentID, utcNow(), id)
+	if err != nil {
+		return fmt.Errorf("resolve booking intent: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("resolve booking intent: %w", err)
+	}
+	if n == 0 {
+		return ErrIntentNotFound
+	}
+
+	return nil
+}
+
+func (r *SqliteRepository) ListPendingBookingIntents(ctx context.Context, createdBefore time.Time) ([]BookingIntent, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT id, slot_id, patient_id, lock_token, state, appointment_id, created_at, resolved_at
+		FROM booking_intents
+		WHERE state = 'pending'
+		  AND created_at < ?
+		ORDER BY created_at
+	`, createdBefore.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []BookingIntent
+	for rows.Next() {
+		i, err := scanBookingIntent(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *i)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) GetAppointmentDetail(ctx context.Context, id uuid.UUID, fields DetailFields) (*AppointmentDetail, error) {
+	row := r.q.QueryRowContext(ctx, detailSelect(fields)+`
+		WHERE a.id = ?
+	`, id)
+	return scanAppointmentDetail(row, fields)
+}
+
+// GetAppointmentDetails binds one placeholder per id since SQLite has no
+// array parameters
+func (r *SqliteRepository) GetAppointmentDetails(ctx context.Context, ids []uuid.UUID, fields DetailFields) ([]AppointmentDetail, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	rows, err := r.q.QueryContext(ctx, detailSelect(fields)+`
+		WHERE a.id IN (`+placeholders+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectDetails(rows, fields)
+}
+
+func (r *SqliteRepository) ListAppointmentsByPatient(ctx context.Context, patientID uuid.UUID, page PageRequest, fields DetailFields) (*AppointmentPage, error) {
+	var after *pageKey
+	if page.Token != "" {
+		key, err := decodeSortedPageToken(page.Sort, page.Token)
+		if err != nil {
+			return nil, err
+		}
+		after = key
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	query, args := detailListQuery(fields, false, false, []string{"a.patient_id = ?"}, []any{patientID},
+		page.Sort, after, page.Limit+1, page.Offset, func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return collectPage(rows, fields, page.Sort, page.Limit)
+}
+
+func (r *SqliteRepository) ListAppointmentsBySlot(ctx context.Context, slotID uuid.UUID, sort ListSort, fields DetailFields) ([]AppointmentDetail, error) {
+	query, args := detailListQuery(fields, false, false, []string{"a.slot_id = ?"}, []any{slotID},
+		sort, nil, 0, 0, func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectDetails(rows, fields)
+}
+
+func (r *SqliteRepository) SearchAppointments(ctx context.Context, q AppointmentSearch, fields DetailFields) (*AppointmentPage, error) {
+	var after *pageKey
+	if q.Token != "" {
+		key, err := decodeSortedPageToken(q.Sort, q.Token)
+		if err != nil {
+			return nil, err
+		}
+		after = key
+	}
+
+	query, args := appointmentSearchQuery(q, after, fields, func(int) string { return "?" })
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return collectPage(rows, fields, q.Sort, q.Limit)
+}
+
+func collectDetails(rows *sql.Rows, fields DetailFields) ([]AppointmentDetail, error) {
+	defer rows.Close()
+
+	var result []AppointmentDetail
+	for rows.Next() {
+		detail, err := scanAppointmentDetail(rows, fields)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *detail)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqliteRepository) StreamAppointmentsByClinic(ctx context.Context, clinicID uuid.UUID, from, to time.Time, fields DetailFields, fn func(*AppointmentDetail) error) error {
+	// Filter through a subquery so the joins stay the ones fields asks for
+	rows, err := r.q.QueryContext(ctx, detailSelect(fields)+`
+		WHERE a.slot_id IN (
+			SELECT cs.id
+			FROM appointment_slots cs
+			INNER JOIN clinicians cc ON cc.id = cs.practitioner_id
+			WHERE cc.clinic_id = ?
+			  AND cs.start_time >= ?
+			  AND cs.start_time < ?
+		)
+		ORDER BY a.created_at, a.id
+	`, clinicID, from.UTC(), to.UTC())
+	if err != nil {
+		return fmt.Errorf("stream clinic appointments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		detail, err := scanAppointmentDetail(rows, fields)
+		if err != nil {
+			return err
+		}
+		if err := fn(detail); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *SqliteRepository) ListClinicianAppointments(ctx context.Context, clinicianID uuid.UUID, from, to time.Time, fields DetailFields) ([]AppointmentDetail, error) {
+	rows, err := r.q.QueryContext(ctx, detailSelectJoining(fields, true, fields.Patient)+`
+		WHERE s.practitioner_id = ?
+		  AND s.start_time >= ?
+		  AND s.start_time < ?
+		  AND a.status NOT IN ('cancelled', 'expired', 'rejected')
+		ORDER BY s.start_time, a.created_at, a.id
+	`, clinicianID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list clinician appointments: %w", err)
+	}
+	defer rows.Close()
+
+	var result []AppointmentDetail
+	for rows.Next() {
+		detail, err := scanAppointmentDetail(rows, fields)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *detail)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Seeder methods
+
+func (r *SqliteRepository) InsertClinic(ctx context.Context, c Clinic) error {
+	now := utcNow()
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO clinics (id, name, requires_approval, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, c.ID, c.Name, c.RequiresApproval, now, now)
+	if err != nil {
+		return fmt.Errorf("insert clinic: %w", err)
+	}
+	return nil
+}
+
+func (r *SqliteRepository) InsertClinician(ctx context.Context, c Clinician) error {
+	now := utcNow()
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO clinicians (id, name, specialty, clinic_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, c.ID, c.Name, c.Specialty, c.ClinicID, now, now)
+	if err != nil {
+		return fmt.Errorf("insert clinician: %w", err)
+	}
+	return nil
+}
+
+func (r *SqliteRepository) InsertPatient(ctx context.Context, p Patient) error {
+	now := utcNow()
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO patients (id, name, email, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, p.ID, p.Name, p.Email, now, now)
+	if err != nil {
+		return fmt.Errorf("insert patient: %w", err)
+	}
+	return nil
+}
+
+func (r *SqliteRepository) InsertSlot(ctx context.Context, s AppointmentSlot) error {
+	now := utcNow()
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO appointment_slots (id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.ID, s.PractitionerID, s.StartTime.UTC(), s.EndTime.UTC(), s.Status, s.Capacity, s.SlotType, now, now)
+	if err != nil {
+		if isSlotOverlap(err) {
+			return ErrSlotOverlap
+		}
+		return fmt.Errorf("insert slot: %w", err)
+	}
+	return nil
+}
+
+func (r *SqliteRepository) SetSlotTypePrice(ctx context.Context, clinicID uuid.UUID, slotType string, price Price) error {
+	now := utcNow()
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO slot_type_prices (clinic_id, slot_type, amount_minor, currency, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (clinic_id, slot_type)
+		DO UPDATE SET amount_minor = excluded.amount_minor,
+		              currency = excluded.currency,
+		              updated_at = excluded.updated_at
+	`, clinicID, slotType, price.AmountMinor, price.Currency, now, now)
+	if err != nil {
+		return fmt.Errorf("set slot type price: %w", err)
+	}
+	return nil
+}
+
+func (r *SqliteRepository) InsertStaffResource(ctx context.Context, res StaffResource) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO staff_resources (id, clinic_id, kind, language, name, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, res.ID, res.ClinicID, res.Kind, res.Language, res.Name, utcNow())
+	if err != nil {
+		return fmt.Errorf("insert staff resource: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package appointment
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/pagination"
+)
+
+// Sync limits. Events younger than SyncSettle are not returned yet: event
+// sequence numbers are taken before the insert commits, so a younger event
+// could still be joined by one with a lower number. Events are stamped by
+// the server logging them, whose clocks must agree well within it.
+const (
+	MaxSyncChanges = MaxBatchGet
+	SyncSettle     = 5 * time.Second
+)
+
+// SyncScope selects the appointments a client syncs: those of the clinic's
+// clinicians, or of one of them when ClinicianID is set
+type SyncScope struct {
+	ClinicID    uuid.UUID
+	ClinicianID *uuid.UUID
+}
+
+// SyncEvent is one entry of the event stream a sync reads
+type SyncEvent struct {
+	ID            int64
+	AppointmentID uuid.UUID
+}
+
+// SyncPage is the outcome of Sync: the current state of each appointment
+// that changed since the cursor, least recently changed first, and the
+// cursor to sync from next. Removed are appointments that changed but no
+// longer exist. HasMore is set when further changes are waiting.
+type SyncPage struct {
+	Appointments []AppointmentDetail
+	Removed      []uuid.UUID
+	Cursor       string
+	HasMore      bool
+}
+
+// Sync returns the appointments in scope that changed since cursor, read
+// from the event log in sequence order, so offline clients can catch up
+// incrementally. An empty cursor syncs from the first retained event. A
+// cursor from before the oldest retained event fails with
+// ErrSyncCursorExpired, as changes may have been lost to retention; the
+// client should then sync from scratch.
+func (s *Service) Sync(ctx context.Context, scope SyncScope, cursor string, limit int, fields DetailFields) (*SyncPage, error) {
+	since, err := s.decodeSyncCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > MaxSyncChanges {
+		limit = MaxSyncChanges
+	}
+
+	before := s.clock.Now().Add(-SyncSettle)
+	oldest, latest, err := s.repo.EventSequenceBounds(ctx, before)
+	if err != nil {
+		return nil, fmt.Errorf("event sequence bounds: %w", err)
+	}
+	if since > 0 && oldest > since+1 {
+		return nil, ErrSyncCursorExpired
+	}
+
+	events, err := s.repo.ListSyncEvents(ctx, scope, since, before, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("list sync events: %w", err)
+	}
+
+	page := &SyncPage{}
+	next := max(since, latest)
+	if len(events) > limit {
+		events = events[:limit]
+		page.HasMore = true
+		next = events[limit-1].ID
+	}
+	page.Cursor = s.encodeSyncCursor(next)
+
+	// Each appointment once, at its latest change
+	last := make(map[uuid.UUID]int, len(events))
+	for i, e := range events {
+		last[e.AppointmentID] = i
+	}
+	ids := make([]uuid.UUID, 0, len(last))
+	for i, e := range events {
+		if last[e.AppointmentID] == i {
+			ids = append(ids, e.AppointmentID)
+		}
+	}
+	if len(ids) == 0 {
+		return page, nil
+	}
+	page.Appointments, page.Removed, err = s.GetAppointments(ctx, ids, fields)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+const syncCursorPrefix = "seq:"
+
+// syncCursorScope seals sync cursors like page tokens. A cursor is a
+// position in the one event log every scope reads, so a client may narrow
+// or widen its scope and keep syncing from it.
+var syncCursorScope = pagination.NewScope("sync")
+
+func (s *Service) encodeSyncCursor(seq int64) string {
+	return s.pages.Encode(syncCursorScope, syncCursorPrefix+strconv.FormatInt(seq, 10))
+}
+
+func (s *Service) decodeSyncCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := s.pages.Decode(syncCursorScope, cursor)
+	if err != nil {
+		return 0, ErrInvalidSyncCursor
+	}
+	n, ok := strings.CutPrefix(raw, syncCursorPrefix)
+	if !ok {
+		return 0, ErrInvalidSyncCursor
+	}
+	seq, err := strconv.ParseInt(n, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidSyncCursor
+	}
+	return seq, nil
+}
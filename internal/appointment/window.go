@@ -0,0 +1,92 @@
+package appointment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ListBookingWindows returns every configured booking window by specialty
+func (s *Service) ListBookingWindows(ctx context.Context) ([]BookingWindow, error) {
+	windows, err := s.repo.ListBookingWindows(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list booking windows: %w", err)
+	}
+	return windows, nil
+}
+
+// PutBookingWindow creates or replaces the booking window of w.Specialty.
+// Lead times are kept in whole seconds.
+func (s *Service) PutBookingWindow(ctx context.Context, w BookingWindow) (*BookingWindow, error) {
+	w.Specialty = strings.TrimSpace(w.Specialty)
+	if w.Specialty == "" {
+		return nil, fmt.Errorf("%w: specialty is required", ErrInvalidBookingWindow)
+	}
+	if w.MinLead < 0 {
+		return nil, fmt.Errorf("%w: min_lead must not be negative", ErrInvalidBookingWindow)
+	}
+	if w.MaxLead != nil && *w.MaxLead <= w.MinLead {
+		return nil, fmt.Errorf("%w: max_lead must be after min_lead", ErrInvalidBookingWindow)
+	}
+
+	saved, err := s.repo.PutBookingWindow(ctx, w)
+	if err != nil {
+		return nil, fmt.Errorf("put booking window: %w", err)
+	}
+	return saved, nil
+}
+
+// DeleteBookingWindow makes the specialty bookable any time again
+func (s *Service) DeleteBookingWindow(ctx context.Context, specialty string) error {
+	if err := s.repo.DeleteBookingWindow(ctx, specialty); err != nil {
+		return fmt.Errorf("delete booking window: %w", err)
+	}
+	return nil
+}
+
+// checkBookingWindow fails with ErrOutsideBookingWindow when any of slots,
+// all of one clinician, starts outside the window of their specialty
+func (s *Service) checkBookingWindow(ctx context.Context, slots ...*AppointmentSlot) error {
+	var window *BookingWindow
+	err := s.runStage(ctx, StageSlotLookup, func(ctx context.Context) (err error) {
+		window, err = s.repo.GetSlotBookingWindow(ctx, slots[0].ID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("load booking window: %w", err)
+	}
+	if window == nil {
+		return nil
+	}
+
+	now := s.clock.Now()
+	for _, slot := range slots {
+		if !window.Allows(slot.StartTime, now) {
+			return fmt.Errorf("%w: %s", ErrOutsideBookingWindow, describeWindow(window, slot.StartTime))
+		}
+	}
+	return nil
+}
+
+// clinicianBookingWindow returns the window of the clinician's specialty,
+// nil when there is none
+func (s *Service) clinicianBookingWindow(ctx context.Context, clinician *Clinician) (*BookingWindow, error) {
+	if clinician.Specialty == nil {
+		return nil, nil
+	}
+	window, err := s.repo.GetBookingWindow(ctx, *clinician.Specialty)
+	if errors.Is(err, ErrBookingWindowNotFound) {
+		return nil, nil
+	}
+	return window, err
+}
+
+func describeWindow(w *BookingWindow, start time.Time) string {
+	desc := fmt.Sprintf("%s slots must start at least %s after booking", w.Specialty, w.MinLead)
+	if w.MaxLead != nil {
+		desc += fmt.Sprintf(" and at most %s after", *w.MaxLead)
+	}
+	return desc + "; this one starts at " + start.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,103 @@
+// Package blob stores files such as exports, archives and attachments in
+// S3-compatible object storage, or in a local directory for development.
+// Both backends implement Store, so callers do not know which one they use.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// Store keeps objects by key. Keys are slash-separated paths, see ValidKey.
+type Store interface {
+	// Put stores size bytes from r under key, replacing any object there.
+	// size must be exact; S3 needs the length before the body.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object for reading. The caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// List calls fn for each object whose key starts with prefix, in key
+	// order. It stops at the first error from fn and returns it.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+	// Ping checks the store can be reached, for readiness
+	Ping(ctx context.Context) error
+}
+
+// maxKeyLen is the longest key S3 accepts
+const maxKeyLen = 1024
+
+// ValidKey reports whether key can name an object: 1 to 1024 bytes of UTF-8
+// without control characters or backslashes, made of non-empty segments
+// separated by "/", none of them "." or "..". The rules keep keys portable
+// between the backends and stop a key escaping the directory of an FSStore.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > maxKeyLen || !utf8.ValidString(key) || strings.ContainsRune(key, '\\') {
+		return false
+	}
+	for _, r := range key {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func checkKey(key string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
+
+// Config selects and configures a backend
+type Config struct {
+	Backend string // "fs" or "s3"; Open returns nil when empty
+
+	Dir string // FSStore root
+
+	Endpoint  string // S3 endpoint URL, e.g. https://s3.eu-west-1.amazonaws.com or http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // address the bucket in the path rather than the host name, as MinIO needs
+}
+
+// Open builds the store cfg describes. It returns nil, nil when no backend
+// is configured.
+func Open(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case "fs":
+		return NewFSStore(cfg.Dir)
+	case "s3":
+		return NewS3Store(cfg)
+	default:
+		return nil, fmt.Errorf("unknown blob backend %q, want fs or s3", cfg.Backend)
+	}
+}
@@ -0,0 +1,54 @@
+// Package bulkcancel cancels every active appointment of a clinic (or one of
+// its clinicians) on a given day, e.g. for an unplanned closure. Runs are
+// queued on the job queue and processed by a worker in rate-limited batches.
+package bulkcancel
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type State string
+
+const (
+	StateQueued  State = "queued"
+	StateRunning State = "running"
+	StateDone    State = "done"
+)
+
+// Run is one bulk cancellation and its progress
+type Run struct {
+	ID          uuid.UUID
+	ClinicID    uuid.UUID
+	ClinicianID *uuid.UUID // nil cancels the whole clinic
+	Day         string     // YYYY-MM-DD as requested
+	WindowStart time.Time  // the day's bounds in the requested time zone
+	WindowEnd   time.Time
+	Reason      string
+	State       State
+	Total       int // active appointments found when the run (re)started, plus those already cancelled
+	Cancelled   int
+	Failed      int
+	LastError   *string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FinishedAt  *time.Time
+}
+
+// Target is an active appointment inside a run's window
+type Target struct {
+	AppointmentID uuid.UUID
+	PatientID     uuid.UUID
+	SlotID        uuid.UUID
+	ClinicianID   uuid.UUID
+	StartTime     time.Time
+}
+
+// Suggestion is an open slot offered for rebooking
+type Suggestion struct {
+	SlotID      uuid.UUID `json:"slot_id"`
+	ClinicianID uuid.UUID `json:"clinician_id"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+}
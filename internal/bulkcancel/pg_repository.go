@@ -0,0 +1,211 @@
+package bulkcancel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// dbtx is satisfied by *pgxpool.Pool and by *shard.Set, which sends each
+// call to the shard of the tenant in its context
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type PgRepository struct {
+	pool dbtx
+}
+
+func NewPgRepository(pool dbtx) *PgRepository {
+	return &PgRepository{pool: pool}
+}
+
+const runColumns = `id, clinic_id, clinician_id, day::text, window_start, window_end, reason, state,
+	total, cancelled, failed, last_error, created_at, updated_at, finished_at`
+
+func scanRun(row pgx.Row) (*Run, error) {
+	var r Run
+
+	err := row.Scan(
+		&r.ID,
+		&r.ClinicID,
+		&r.ClinicianID,
+		&r.Day,
+		&r.WindowStart,
+		&r.WindowEnd,
+		&r.Reason,
+		&r.State,
+		&r.Total,
+		&r.Cancelled,
+		&r.Failed,
+		&r.LastError,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+		&r.FinishedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRunNotFound
+		}
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (r *PgRepository) CheckScope(ctx context.Context, clinicID uuid.UUID, clinicianID *uuid.UUID) error {
+	var exists bool
+	if err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM clinics WHERE id = $1)`, clinicID).Scan(&exists); err != nil {
+		return fmt.Errorf("check clinic: %w", err)
+	}
+	if !exists {
+		return ErrClinicNotFound
+	}
+
+	if clinicianID == nil {
+		return nil
+	}
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM clinicians WHERE id = $1 AND clinic_id = $2)
+	`, *clinicianID, clinicID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check clinician: %w", err)
+	}
+	if !exists {
+		return ErrClinicianNotInClinic
+	}
+	return nil
+}
+
+func (r *PgRepository) CreateRun(ctx context.Context, run Run) (*Run, error) {
+	row := r.pool.QueryRow(ctx, `
+		INSERT INTO bulk_cancellations (id, clinic_id, clinician_id, day, window_start, window_end, reason, state)
+		VALUES ($1, $2, $3, $4::date, $5, $6, $7, $8)
+		RETURNING `+runColumns,
+		run.ID, run.ClinicID, run.ClinicianID, run.Day, run.WindowStart, run.WindowEnd, run.Reason, run.State)
+
+	created, err := scanRun(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert bulk cancellation: %w", err)
+	}
+	return created, nil
+}
+
+func (r *PgRepository) GetRun(ctx context.Context, id uuid.UUID) (*Run, error) {
+	row := r.pool.QueryRow(ctx, `SELECT `+runColumns+` FROM bulk_cancellations WHERE id = $1`, id)
+	return scanRun(row)
+}
+
+func (r *PgRepository) UpdateProgress(ctx context.Context, run Run) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE bulk_cancellations
+		SET state = $2,
+		    total = $3,
+		    cancelled = $4,
+		    failed = $5,
+		    last_error = $6,
+		    finished_at = $7,
+		    updated_at = now()
+		WHERE id = $1
+	`, run.ID, run.State, run.Total, run.Cancelled, run.Failed, run.LastError, run.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("update bulk cancellation: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRunNotFound
+	}
+	return nil
+}
+
+// targetFilter selects active appointments in a run's scope; $1 clinic,
+// $2 optional clinician, $3 and $4 the window
+const targetFilter = `
+	FROM appointments a
+	JOIN appointment_slots s ON s.id = a.slot_id
+	JOIN clinicians c ON c.id = s.practitioner_id
+	WHERE c.clinic_id = $1
+	  AND ($2::uuid IS NULL OR s.practitioner_id = $2)
+	  AND s.start_time >= $3
+	  AND s.start_time < $4
+	  AND a.status IN ('pending', 'pending_approval', 'confirmed')`
+
+func (r *PgRepository) CountTargets(ctx context.Context, run Run) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx, `SELECT count(*)`+targetFilter,
+		run.ClinicID, run.ClinicianID, run.WindowStart, run.WindowEnd).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count bulk cancellation targets: %w", err)
+	}
+	return n, nil
+}
+
+func (r *PgRepository) ListTargets(ctx context.Context, run Run, afterID uuid.UUID, limit int) ([]Target, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT a.id, a.patient_id, a.slot_id, s.practitioner_id, s.start_time`+targetFilter+`
+		  AND a.id > $5
+		ORDER BY a.id
+		LIMIT $6
+	`, run.ClinicID, run.ClinicianID, run.WindowStart, run.WindowEnd, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list bulk cancellation targets: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Target
+	for rows.Next() {
+		var t Target
+		if err := rows.Scan(&t.AppointmentID, &t.PatientID, &t.SlotID, &t.ClinicianID, &t.StartTime); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) ListOpenSlots(ctx context.Context, clinicID, clinicianID uuid.UUID, from time.Time, limit int) ([]Suggestion, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT s.id, s.practitioner_id, s.start_time, s.end_time
+		FROM appointment_slots s
+		JOIN clinicians c ON c.id = s.practitioner_id
+		WHERE c.clinic_id = $1
+		  AND s.status = 'open'
+		  AND s.start_time >= $3
+		  AND NOT EXISTS (
+			SELECT 1
+			FROM appointments a
+			WHERE a.slot_id = s.id
+			  AND a.status IN ('pending', 'pending_approval', 'confirmed', 'checked_in')
+		  )
+		ORDER BY (s.practitioner_id = $2) DESC, s.start_time
+		LIMIT $4
+	`, clinicID, clinicianID, from, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list open slots: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Suggestion
+	for rows.Next() {
+		var sg Suggestion
+		if err := rows.Scan(&sg.SlotID, &sg.ClinicianID, &sg.StartTime, &sg.EndTime); err != nil {
+			return nil, err
+		}
+		result = append(result, sg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package bulkcancel
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+var (
+	ErrRunNotFound = &appointment.Error{
+		Code: "bulk_cancellation_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "bulk cancellation not found",
+	}
+	ErrClinicNotFound = &appointment.Error{
+		Code: "clinic_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "clinic not found",
+	}
+	ErrClinicianNotInClinic = &appointment.Error{
+		Code: "clinician_not_in_clinic", HTTPStatus: http.StatusBadRequest,
+		Message: "clinician does not belong to the clinic",
+	}
+)
+
+type Repository interface {
+	// CheckScope returns ErrClinicNotFound or ErrClinicianNotInClinic when the
+	// clinic, or the clinician within it, does not exist
+	CheckScope(ctx context.Context, clinicID uuid.UUID, clinicianID *uuid.UUID) error
+
+	CreateRun(ctx context.Context, run Run) (*Run, error)
+	GetRun(ctx context.Context, id uuid.UUID) (*Run, error)
+	UpdateProgress(ctx context.Context, run Run) error
+
+	// CountTargets and ListTargets see pending, awaiting approval and confirmed
+	// appointments whose slot starts inside the run's window. ListTargets pages
+	// by appointment id.
+	CountTargets(ctx context.Context, run Run) (int, error)
+	ListTargets(ctx context.Context, run Run, afterID uuid.UUID, limit int) ([]Target, error)
+
+	// ListOpenSlots returns free slots of the clinic starting at or after
+	// from, the given clinician's first
+	ListOpenSlots(ctx context.Context, clinicID, clinicianID uuid.UUID, from time.Time, limit int) ([]Suggestion, error)
+}
@@ -0,0 +1,215 @@
+package bulkcancel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/jobs"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+// Queue is the jobs queue runs are processed from
+const Queue = "bulk_cancel"
+
+// ReasonClinicClosure is recorded on every cancellation event of a run
+const ReasonClinicClosure = "clinic_closure"
+
+// suggestionsPerAppointment is how many open slots each cancelled patient is offered
+const suggestionsPerAppointment = 3
+
+var ErrInvalidDay = &appointment.Error{
+	Code: "invalid_date", HTTPStatus: http.StatusBadRequest,
+	Message: "date must be formatted YYYY-MM-DD",
+}
+
+var bulkCancelled = metrics.NewCounter(
+	"bulk_cancel_appointments_total",
+	"Appointments handled by bulk cancellation runs, by outcome (cancelled, failed).",
+	"outcome",
+)
+
+type runJob struct {
+	RunID uuid.UUID `json:"run_id"`
+	// Tenant routes the run to the shard it was started on
+	Tenant string `json:"tenant,omitempty"`
+}
+
+type Service struct {
+	repo      Repository
+	appts     *appointment.Service
+	queue     *jobs.Queue
+	batchSize int
+	pause     time.Duration
+}
+
+// NewService creates the bulk cancellation service. Runs cancel batchSize
+// appointments at a time and wait pause between batches, so a large closure
+// does not flood the database or notification receivers.
+func NewService(repo Repository, appts *appointment.Service, queue *jobs.Queue, batchSize int, pause time.Duration) *Service {
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+	return &Service{
+		repo:      repo,
+		appts:     appts,
+		queue:     queue,
+		batchSize: batchSize,
+		pause:     pause,
+	}
+}
+
+// Start validates the request, records a queued run and enqueues it. day is
+// interpreted in loc. The returned run's ID is used to poll progress.
+func (s *Service) Start(ctx context.Context, clinicID uuid.UUID, clinicianID *uuid.UUID, day string, loc *time.Location) (*Run, error) {
+	start, err := time.ParseInLocation(time.DateOnly, day, loc)
+	if err != nil {
+		return nil, ErrInvalidDay
+	}
+
+	if err := s.repo.CheckScope(ctx, clinicID, clinicianID); err != nil {
+		return nil, err
+	}
+
+	run, err := s.repo.CreateRun(ctx, Run{
+		ID:          uuid.New(),
+		ClinicID:    clinicID,
+		ClinicianID: clinicianID,
+		Day:         day,
+		WindowStart: start,
+		WindowEnd:   start.AddDate(0, 0, 1),
+		Reason:      ReasonClinicClosure,
+		State:       StateQueued,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.queue.Enqueue(ctx, Queue, runJob{RunID: run.ID, Tenant: shard.Tenant(ctx)}); err != nil {
+		return nil, fmt.Errorf("enqueue bulk cancellation: %w", err)
+	}
+	return run, nil
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*Run, error) {
+	run, err := s.repo.GetRun(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get bulk cancellation: %w", err)
+	}
+	return run, nil
+}
+
+// Handle processes one queued run. It is safe to retry: a restarted run only
+// sees appointments that are still active and keeps its cancelled count.
+func (s *Service) Handle(ctx context.Context, job jobs.Job) error {
+	var rj runJob
+	if err := job.Decode(&rj); err != nil {
+		return fmt.Errorf("decode bulk cancellation job: %w", err)
+	}
+	if rj.Tenant != "" {
+		ctx = shard.WithTenant(ctx, rj.Tenant)
+	}
+
+	run, err := s.repo.GetRun(ctx, rj.RunID)
+	if err != nil {
+		if errors.Is(err, ErrRunNotFound) {
+			return nil
+		}
+		return err
+	}
+	if run.State == StateDone {
+		return nil
+	}
+
+	remaining, err := s.repo.CountTargets(ctx, *run)
+	if err != nil {
+		return err
+	}
+	run.State = StateRunning
+	run.Total = run.Cancelled + remaining
+	run.Failed = 0
+	if err := s.repo.UpdateProgress(ctx, *run); err != nil {
+		return err
+	}
+
+	// One suggestion list per clinician; every patient of that clinician is
+	// offered the same open slots
+	suggestions := make(map[uuid.UUID][]Suggestion)
+
+	var after uuid.UUID
+	for {
+		batch, err := s.repo.ListTargets(ctx, *run, after, s.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, t := range batch {
+			after = t.AppointmentID
+
+			offer, ok := suggestions[t.ClinicianID]
+			if !ok {
+				offer, err = s.repo.ListOpenSlots(ctx, run.ClinicID, t.ClinicianID, run.WindowEnd, suggestionsPerAppointment)
+				if err != nil {
+					return err
+				}
+				suggestions[t.ClinicianID] = offer
+			}
+
+			_, err := s.appts.CancelAppointment(ctx, t.AppointmentID, run.Reason, map[string]any{
+				"bulk_cancellation_id": run.ID.String(),
+				"suggested_slots":      offer,
+			})
+			switch {
+			case err == nil:
+				run.Cancelled++
+				bulkCancelled.Inc("cancelled")
+			case errors.Is(err, appointment.ErrAppointmentNotActive):
+				// cancelled or expired since it was listed
+				run.Total--
+			default:
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				msg := err.Error()
+				run.Failed++
+				run.LastError = &msg
+				bulkCancelled.Inc("failed")
+				log.Printf("bulk cancellation %s: appointment %s: %v", run.ID, t.AppointmentID, err)
+			}
+		}
+
+		if err := s.repo.UpdateProgress(ctx, *run); err != nil {
+			return err
+		}
+		if len(batch) < s.batchSize {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.pause):
+		}
+	}
+
+	now := s.appts.Now()
+	run.State = StateDone
+	run.FinishedAt = &now
+	if err := s.repo.UpdateProgress(ctx, *run); err != nil {
+		return err
+	}
+
+	log.Printf("bulk cancellation %s done: cancelled=%d failed=%d total=%d",
+		run.ID, run.Cancelled, run.Failed, run.Total)
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package clamav scans uploads with a ClamAV daemon. Clamd satisfies
+// appointment.Scanner.
+package clamav
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is the largest INSTREAM chunk sent; clamd's StreamMaxLength
+// bounds the total
+const chunkSize = 64 << 10
+
+// defaultTimeout bounds a scan whose context has no deadline
+const defaultTimeout = 30 * time.Second
+
+// Clamd talks to clamd over TCP, or over a Unix socket when the address is
+// an absolute path, with one connection per scan
+type Clamd struct {
+	network string
+	addr    string
+}
+
+func New(addr string) *Clamd {
+	if strings.HasPrefix(addr, "/") {
+		return &Clamd{network: "unix", addr: addr}
+	}
+	return &Clamd{network: "tcp", addr: addr}
+}
+
+// Scan streams content to clamd with the INSTREAM command and returns the
+// signature it matched, empty when it found none
+func (c *Clamd) Scan(ctx context.Context, _ string, content []byte) (string, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.addr)
+	if err != nil {
+		return "", fmt.Errorf("connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	var size [4]byte
+	for len(content) > 0 {
+		n := min(len(content), chunkSize)
+		binary.BigEndian.PutUint32(size[:], uint32(n))
+		w.Write(size[:])
+		w.Write(content[:n])
+		content = content[n:]
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	w.Write(size[:])
+	if err := w.Flush(); err != nil {
+		return "", fmt.Errorf("send to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("read clamd reply: %w", err)
+	}
+	return parseReply(strings.TrimSuffix(reply, "\x00"))
+}
+
+// parseReply reads "stream: OK", "stream: <signature> FOUND" or
+// "<message> ERROR"
+func parseReply(reply string) (string, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd: %s", reply)
+	}
+}
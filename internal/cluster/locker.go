@@ -0,0 +1,109 @@
+package cluster
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// ownershipLocker serializes bookings in-process for slots this instance owns
+// and uses the fallback (Redis) lock for everything else.
+type ownershipLocker struct {
+	topology *Topology
+	fallback redisclient.Locker
+
+	mu    sync.Mutex
+	slots map[uuid.UUID]*slotQueue
+}
+
+// slotQueue is a per-slot mutex that can be waited on with a context
+type slotQueue struct {
+	ch   chan struct{}
+	refs int
+}
+
+// NewOwnershipLocker wraps fallback with the owned-slot fast path
+func NewOwnershipLocker(topology *Topology, fallback redisclient.Locker) redisclient.Locker {
+	return &ownershipLocker{
+		topology: topology,
+		fallback: fallback,
+		slots:    make(map[uuid.UUID]*slotQueue),
+	}
+}
+
+// WithLock only takes the fast path for a single owned slot. Anything else
+// goes to the fallback, which does not exclude in-process holders, so a
+// multi-key lock first takes the in-process lock of every owned slot it
+// covers, in key order. A lock taken on another instance, e.g. by a worker
+// the slot is not routed to, is not excluded by the fast path; the room
+// checks it guards count places under the slot's row lock inside the
+// booking transaction, which keeps such writers apart.
+func (l *ownershipLocker) WithLock(ctx context.Context, keys []string, fn func(ctx context.Context) error) error {
+	var owned []uuid.UUID
+	for _, key := range slices.Compact(slices.Sorted(slices.Values(keys))) {
+		if slotID, ok := redisclient.ParseSlotKey(key); ok && l.topology.OwnsStable(slotID) {
+			owned = append(owned, slotID)
+		}
+	}
+	if len(keys) == 1 && len(owned) == 1 {
+		return l.withLocal(ctx, owned, fn)
+	}
+	return l.withLocal(ctx, owned, func(ctx context.Context) error {
+		return l.fallback.WithLock(ctx, keys, fn)
+	})
+}
+
+// withLocal runs fn holding the in-process locks of slotIDs, taken in order
+func (l *ownershipLocker) withLocal(ctx context.Context, slotIDs []uuid.UUID, fn func(ctx context.Context) error) error {
+	if len(slotIDs) == 0 {
+		return fn(ctx)
+	}
+	slotID := slotIDs[0]
+	q := l.acquireRef(slotID)
+	defer l.releaseRef(slotID, q)
+
+	select {
+	case q.ch <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-q.ch }()
+
+	return l.withLocal(ctx, slotIDs[1:], fn)
+}
+
+// WithPermit always uses the fallback; slots with capacity above one are
+// not serialized in-process
+func (l *ownershipLocker) WithPermit(ctx context.Context, key string, limit int, fn func(ctx context.Context) error) error {
+	if sem, ok := l.fallback.(redisclient.Semaphore); ok {
+		return sem.WithPermit(ctx, key, limit, fn)
+	}
+	return l.fallback.WithLock(ctx, []string{key}, fn)
+}
+
+func (l *ownershipLocker) acquireRef(slotID uuid.UUID) *slotQueue {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	q, ok := l.slots[slotID]
+	if !ok {
+		q = &slotQueue{ch: make(chan struct{}, 1)}
+		l.slots[slotID] = q
+	}
+	q.refs++
+	return q
+}
+
+func (l *ownershipLocker) releaseRef(slotID uuid.UUID, q *slotQueue) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	q.refs--
+	if q.refs == 0 {
+		delete(l.slots, slotID)
+	}
+}
@@ -0,0 +1,47 @@
+// Package cluster assigns slot ownership to api-server instances with a
+// consistent hash ring built from the Redis instance registry.
+package cluster
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas is the number of virtual nodes per member. More replicas
+// spread slots more evenly at the cost of a larger ring.
+const DefaultReplicas = 128
+
+// Ring maps keys to members by consistent hashing, so adding or removing one
+// member only moves the keys that member owned or takes over.
+type Ring struct {
+	hashes []uint32
+	owners map[uint32]string
+}
+
+func NewRing(members []string, replicas int) *Ring {
+	r := &Ring{owners: make(map[uint32]string, len(members)*replicas)}
+	for _, m := range members {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(m + "#" + strconv.Itoa(i)))
+			r.hashes = append(r.hashes, h)
+			r.owners[h] = m
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// Owner returns the member responsible for key, false when the ring is empty
+func (r *Ring) Owner(key string) (string, bool) {
+	if len(r.hashes) == 0 {
+		return "", false
+	}
+
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]], true
+}
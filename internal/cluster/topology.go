@@ -0,0 +1,139 @@
+package cluster
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+)
+
+// ListFunc returns the live instances, normally InstanceRegistry.List
+type ListFunc func(ctx context.Context) ([]redisclient.Instance, error)
+
+// Topology is this instance's view of the cluster. It is rebuilt from the
+// registry on every refresh and considered stable once the member set has not
+// changed for the settle period. Until then, and whenever the registry cannot
+// be read, ownership is not trusted and callers fall back to the Redis lock.
+type Topology struct {
+	list   ListFunc
+	selfID string
+	settle time.Duration
+
+	mu        sync.RWMutex
+	ring      *Ring
+	instances map[string]redisclient.Instance
+	members   string
+	changedAt time.Time
+	healthy   bool
+}
+
+func NewTopology(list ListFunc, selfID string, settle time.Duration) *Topology {
+	return &Topology{
+		list:      list,
+		selfID:    selfID,
+		settle:    settle,
+		ring:      NewRing(nil, DefaultReplicas),
+		instances: make(map[string]redisclient.Instance),
+		changedAt: time.Now(),
+	}
+}
+
+// Refresh reloads the member list from the registry
+func (t *Topology) Refresh(ctx context.Context) error {
+	instances, err := t.list(ctx)
+	if err != nil {
+		t.mu.Lock()
+		t.healthy = false
+		t.mu.Unlock()
+		return err
+	}
+
+	ids := make([]string, 0, len(instances))
+	byID := make(map[string]redisclient.Instance, len(instances))
+	for _, inst := range instances {
+		ids = append(ids, inst.ID)
+		byID[inst.ID] = inst
+	}
+	sort.Strings(ids)
+	members := strings.Join(ids, ",")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.healthy = true
+	t.instances = byID
+	if members != t.members {
+		t.members = members
+		t.ring = NewRing(ids, DefaultReplicas)
+		t.changedAt = time.Now()
+		log.Printf("slot ownership topology changed: %d instances", len(ids))
+	}
+	return nil
+}
+
+// Run refreshes the topology every interval until ctx is done
+func (t *Topology) Run(ctx context.Context, interval time.Duration) {
+	refresh := func() {
+		refreshCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		if err := t.Refresh(refreshCtx); err != nil {
+			log.Printf("slot ownership refresh error: %v", err)
+		}
+	}
+
+	refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
+
+// Owner returns the instance that owns slotID in the current view
+func (t *Topology) Owner(slotID uuid.UUID) (redisclient.Instance, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	id, ok := t.ring.Owner(slotID.String())
+	if !ok {
+		return redisclient.Instance{}, false
+	}
+	inst, ok := t.instances[id]
+	return inst, ok
+}
+
+// OwnsStable reports whether this instance owns slotID and the topology has
+// been unchanged long enough for every other instance to agree.
+func (t *Topology) OwnsStable(slotID uuid.UUID) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.healthy || time.Since(t.changedAt) < t.settle {
+		return false
+	}
+	id, ok := t.ring.Owner(slotID.String())
+	return ok && id == t.selfID
+}
+
+// Route returns the address to forward a booking for slotID to, or local=true
+// when this instance should handle it itself.
+func (t *Topology) Route(slotID uuid.UUID) (addr string, local bool) {
+	owner, ok := t.Owner(slotID)
+	if !ok || owner.ID == t.selfID || owner.Addr == "" {
+		return "", true
+	}
+	return owner.Addr, false
+}
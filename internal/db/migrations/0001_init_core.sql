@@ -1,4 +1,6 @@
 -- Core tables: patients and clinicians, plus enum types
+--
+-- phase: expand
 
 DO $$
 BEGIN
@@ -1,4 +1,6 @@
 -- Slot and appointment tables with constraints
+--
+-- phase: expand
 
 CREATE TABLE IF NOT EXISTS appointment_slots (
     id               uuid PRIMARY KEY,
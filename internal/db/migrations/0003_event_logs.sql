@@ -1,4 +1,6 @@
 -- Event log for audit trail
+--
+-- phase: expand
 
 CREATE TABLE IF NOT EXISTS event_logs (
     id             bigserial PRIMARY KEY,
@@ -1,4 +1,6 @@
 -- Indexes for read-side queries
+--
+-- phase: expand
 
 -- Index for listing appointments by patient
 CREATE INDEX IF NOT EXISTS idx_appointments_patient_id_created_at
@@ -0,0 +1,34 @@
+-- Clinics, slot types and per-clinic self-pay prices
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS clinics (
+    id          uuid PRIMARY KEY,
+    name        text NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+ALTER TABLE clinicians
+    ADD COLUMN IF NOT EXISTS clinic_id uuid REFERENCES clinics(id);
+
+ALTER TABLE appointment_slots
+    ADD COLUMN IF NOT EXISTS slot_type text;
+
+-- Self-pay price for a slot type at a clinic. Amounts are stored in the
+-- currency's minor unit (cents for USD/EUR, yen for JPY).
+CREATE TABLE IF NOT EXISTS slot_type_prices (
+    clinic_id     uuid NOT NULL REFERENCES clinics(id),
+    slot_type     text NOT NULL,
+    amount_minor  bigint NOT NULL,
+    currency      char(3) NOT NULL,
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    updated_at    timestamptz NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (clinic_id, slot_type),
+    CONSTRAINT chk_price_non_negative CHECK (amount_minor >= 0),
+    CONSTRAINT chk_price_currency_upper CHECK (currency = upper(currency))
+);
+
+CREATE INDEX IF NOT EXISTS idx_clinicians_clinic_id
+    ON clinicians (clinic_id);
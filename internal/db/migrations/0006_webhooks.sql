@@ -0,0 +1,30 @@
+-- Webhook subscriptions and delivery attempt history
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id           uuid PRIMARY KEY,
+    url          text NOT NULL,
+    event_types  text[] NOT NULL,
+    secret       text NOT NULL,
+    active       boolean NOT NULL DEFAULT true,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    updated_at   timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT chk_webhook_event_types_not_empty CHECK (cardinality(event_types) > 0)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id               bigserial PRIMARY KEY,
+    subscription_id  uuid NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_type       text NOT NULL,
+    payload          jsonb,
+    response_status  integer,
+    error            text,
+    duration_ms      integer NOT NULL DEFAULT 0,
+    created_at       timestamptz NOT NULL DEFAULT now()
+);
+
+-- For delivery history per subscription, newest first
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_created_at
+    ON webhook_deliveries (subscription_id, created_at DESC);
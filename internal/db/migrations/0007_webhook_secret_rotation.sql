@@ -0,0 +1,7 @@
+-- Keep the previous webhook secret valid for a grace period after rotation
+--
+-- phase: expand
+
+ALTER TABLE webhook_subscriptions
+    ADD COLUMN IF NOT EXISTS previous_secret text,
+    ADD COLUMN IF NOT EXISTS previous_secret_expires_at timestamptz;
@@ -0,0 +1,21 @@
+-- Write-ahead journal of booking attempts, reconciled on api-server startup
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS booking_intents (
+    id              uuid PRIMARY KEY,
+    slot_id         uuid NOT NULL REFERENCES appointment_slots(id),
+    patient_id      uuid NOT NULL REFERENCES patients(id),
+    lock_token      text NOT NULL,
+    state           text NOT NULL DEFAULT 'pending',
+    appointment_id  uuid REFERENCES appointments(id),
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    resolved_at     timestamptz,
+
+    CONSTRAINT chk_booking_intent_state CHECK (state IN ('pending', 'committed', 'aborted'))
+);
+
+-- Reconciliation only scans unresolved intents
+CREATE INDEX IF NOT EXISTS idx_booking_intents_pending
+    ON booking_intents (created_at)
+    WHERE state = 'pending';
@@ -0,0 +1,10 @@
+-- Per-job schedule overrides read by worker binaries at startup
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS worker_jobs (
+    name        text PRIMARY KEY,
+    schedule    text,                      -- cron expression or "@every <duration>", NULL keeps the built-in schedule
+    enabled     boolean NOT NULL DEFAULT true,
+    updated_at  timestamptz NOT NULL DEFAULT now()
+);
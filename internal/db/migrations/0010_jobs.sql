@@ -0,0 +1,28 @@
+-- At-least-once job queue used by internal/jobs
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id            uuid PRIMARY KEY,
+    queue         text NOT NULL,
+    payload       jsonb NOT NULL DEFAULT '{}'::jsonb,
+    state         text NOT NULL DEFAULT 'queued',
+    attempts      integer NOT NULL DEFAULT 0,
+    max_attempts  integer NOT NULL DEFAULT 5,
+    run_at        timestamptz NOT NULL DEFAULT now(),
+    locked_until  timestamptz,
+    last_error    text,
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    updated_at    timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT chk_job_state CHECK (state IN ('queued', 'running', 'done', 'dead'))
+);
+
+-- Claim scans only jobs that can still run
+CREATE INDEX IF NOT EXISTS idx_jobs_claim
+    ON jobs (queue, run_at)
+    WHERE state IN ('queued', 'running');
+
+CREATE INDEX IF NOT EXISTS idx_jobs_dead
+    ON jobs (queue, updated_at DESC)
+    WHERE state = 'dead';
@@ -0,0 +1,25 @@
+-- Progress of bulk cancellations, see internal/bulkcancel
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS bulk_cancellations (
+    id            uuid PRIMARY KEY,
+    clinic_id     uuid NOT NULL REFERENCES clinics(id),
+    clinician_id  uuid REFERENCES clinicians(id),
+    day           date NOT NULL,
+    window_start  timestamptz NOT NULL,
+    window_end    timestamptz NOT NULL,
+    reason        text NOT NULL,
+    state         text NOT NULL DEFAULT 'queued',
+    total         integer NOT NULL DEFAULT 0,
+    cancelled     integer NOT NULL DEFAULT 0,
+    failed        integer NOT NULL DEFAULT 0,
+    last_error    text,
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    updated_at    timestamptz NOT NULL DEFAULT now(),
+    finished_at   timestamptz,
+
+    CONSTRAINT chk_bulk_cancellation_state CHECK (state IN ('queued', 'running', 'done')),
+    CONSTRAINT chk_bulk_cancellation_window CHECK (window_end > window_start)
+);
+
@@ -0,0 +1,60 @@
+-- Per-clinician availability version, bumped by triggers on every slot or
+-- appointment change so clients can validate cached availability cheaply
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS clinician_availability_versions (
+    clinician_id  uuid PRIMARY KEY REFERENCES clinicians(id),
+    version       bigint NOT NULL DEFAULT 0,
+    updated_at    timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE OR REPLACE FUNCTION bump_availability_version(p_clinician_id uuid) RETURNS void AS $$
+BEGIN
+    INSERT INTO clinician_availability_versions (clinician_id, version, updated_at)
+    VALUES (p_clinician_id, 1, now())
+    ON CONFLICT (clinician_id) DO UPDATE
+        SET version = clinician_availability_versions.version + 1,
+            updated_at = now();
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION slots_bump_availability() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM bump_availability_version(OLD.practitioner_id);
+    END IF;
+    IF TG_OP = 'INSERT' OR (TG_OP = 'UPDATE' AND NEW.practitioner_id <> OLD.practitioner_id) THEN
+        PERFORM bump_availability_version(NEW.practitioner_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION appointments_bump_availability() RETURNS trigger AS $$
+DECLARE
+    v_slot_id uuid;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        v_slot_id := OLD.slot_id;
+    ELSE
+        v_slot_id := NEW.slot_id;
+    END IF;
+
+    PERFORM bump_availability_version(s.practitioner_id)
+    FROM appointment_slots s
+    WHERE s.id = v_slot_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_slots_bump_availability ON appointment_slots;
+CREATE TRIGGER trg_slots_bump_availability
+    AFTER INSERT OR UPDATE OR DELETE ON appointment_slots
+    FOR EACH ROW EXECUTE FUNCTION slots_bump_availability();
+
+-- Only status changes affect availability
+DROP TRIGGER IF EXISTS trg_appointments_bump_availability ON appointments;
+CREATE TRIGGER trg_appointments_bump_availability
+    AFTER INSERT OR DELETE OR UPDATE OF status ON appointments
+    FOR EACH ROW EXECUTE FUNCTION appointments_bump_availability();
@@ -0,0 +1,37 @@
+-- Slot capacity: a slot takes up to capacity confirmed appointments. The
+-- count is kept on the slot row by a trigger and bounded by a CHECK, which
+-- replaces the one-confirmed-per-slot unique index.
+--
+-- phase: contract
+
+ALTER TABLE appointment_slots
+    ADD COLUMN IF NOT EXISTS confirmed_count integer NOT NULL DEFAULT 0;
+
+UPDATE appointment_slots s
+SET confirmed_count = (
+    SELECT count(*) FROM appointments a
+    WHERE a.slot_id = s.id AND a.status = 'confirmed'
+);
+
+ALTER TABLE appointment_slots DROP CONSTRAINT IF EXISTS chk_slot_capacity;
+ALTER TABLE appointment_slots
+    ADD CONSTRAINT chk_slot_capacity CHECK (confirmed_count >= 0 AND confirmed_count <= capacity);
+
+CREATE OR REPLACE FUNCTION appointments_count_confirmed() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.status = 'confirmed' THEN
+        UPDATE appointment_slots SET confirmed_count = confirmed_count - 1 WHERE id = OLD.slot_id;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.status = 'confirmed' THEN
+        UPDATE appointment_slots SET confirmed_count = confirmed_count + 1 WHERE id = NEW.slot_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_appointments_count_confirmed ON appointments;
+CREATE TRIGGER trg_appointments_count_confirmed
+    AFTER INSERT OR DELETE OR UPDATE OF status, slot_id ON appointments
+    FOR EACH ROW EXECUTE FUNCTION appointments_count_confirmed();
+
+DROP INDEX IF EXISTS uniq_confirmed_appointment_per_slot;
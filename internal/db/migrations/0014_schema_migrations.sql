@@ -0,0 +1,28 @@
+-- Applied migrations and their blue/green phase, read by the startup schema
+-- check in internal/db. Every migration from here on ends by recording itself.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version     integer PRIMARY KEY,
+    phase       text NOT NULL CHECK (phase IN ('expand', 'contract')),
+    applied_at  timestamptz NOT NULL DEFAULT now()
+);
+
+-- Migrations are applied in order, so everything before this one is in place
+INSERT INTO schema_migrations (version, phase) VALUES
+    (1, 'expand'),
+    (2, 'expand'),
+    (3, 'expand'),
+    (4, 'expand'),
+    (5, 'expand'),
+    (6, 'expand'),
+    (7, 'expand'),
+    (8, 'expand'),
+    (9, 'expand'),
+    (10, 'expand'),
+    (11, 'expand'),
+    (12, 'expand'),
+    (13, 'contract'),
+    (14, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,14 @@
+-- Tenants whose booking data lives in a database other than the default,
+-- see internal/shard. Read from the default database only; shard names refer
+-- to SHARD_DSNS entries.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS tenant_shards (
+    tenant_id   text PRIMARY KEY,
+    shard       text NOT NULL,
+    updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+INSERT INTO schema_migrations (version, phase) VALUES (15, 'expand')
+ON CONFLICT (version) DO NOTHING;
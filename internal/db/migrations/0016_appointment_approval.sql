@@ -0,0 +1,17 @@
+-- Provisional bookings: appointments at clinics that triage bookings wait in
+-- pending_approval until a clinician approves or rejects them. expires_at
+-- holds the approval deadline while they wait.
+--
+-- The new statuses are not used here: a value added to an enum cannot be used
+-- in the transaction that adds it.
+--
+-- phase: expand
+
+ALTER TYPE appointment_status ADD VALUE IF NOT EXISTS 'pending_approval';
+ALTER TYPE appointment_status ADD VALUE IF NOT EXISTS 'rejected';
+
+ALTER TABLE clinics
+    ADD COLUMN IF NOT EXISTS requires_approval boolean NOT NULL DEFAULT false;
+
+INSERT INTO schema_migrations (version, phase) VALUES (16, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,30 @@
+-- Recurring appointments: a series links one appointment per occurrence,
+-- numbered from 1. appointment_id follows the occurrence when it is
+-- rescheduled; exception marks an occurrence changed on its own, which
+-- series-wide reschedules leave alone. Occurrences fall on the same
+-- wall-clock time in time_zone.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS appointment_series (
+    id              uuid PRIMARY KEY,
+    patient_id      uuid NOT NULL REFERENCES patients(id),
+    clinician_id    uuid NOT NULL REFERENCES clinicians(id),
+    interval_days   integer NOT NULL,
+    time_zone       text NOT NULL DEFAULT 'UTC',
+    created_at      timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT chk_series_interval CHECK (interval_days > 0)
+);
+
+CREATE TABLE IF NOT EXISTS appointment_series_occurrences (
+    series_id       uuid NOT NULL REFERENCES appointment_series(id),
+    occurrence      integer NOT NULL,
+    appointment_id  uuid NOT NULL UNIQUE REFERENCES appointments(id),
+    exception       boolean NOT NULL DEFAULT false,
+
+    PRIMARY KEY (series_id, occurrence)
+);
+
+INSERT INTO schema_migrations (version, phase) VALUES (17, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,41 @@
+-- Multi-slot appointments: a procedure may span consecutive slots of one
+-- clinician. appointments.slot_id stays the first slot; the later ones are
+-- listed here by position from 2. The confirmed count trigger counts a
+-- confirmed appointment against every slot it spans, so the capacity CHECK
+-- admits or refuses the whole span in one statement.
+--
+-- Binaries that predate this never add rows here, and the trigger behaves
+-- as before for appointments without any.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS appointment_extra_slots (
+    appointment_id  uuid NOT NULL REFERENCES appointments(id),
+    position        integer NOT NULL,
+    slot_id         uuid NOT NULL REFERENCES appointment_slots(id),
+
+    PRIMARY KEY (appointment_id, position),
+    CONSTRAINT chk_extra_slot_position CHECK (position > 1)
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_extra_slots_slot
+    ON appointment_extra_slots(slot_id);
+
+CREATE OR REPLACE FUNCTION appointments_count_confirmed() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.status = 'confirmed' THEN
+        UPDATE appointment_slots SET confirmed_count = confirmed_count - 1
+        WHERE id = OLD.slot_id
+           OR id IN (SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = OLD.id);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.status = 'confirmed' THEN
+        UPDATE appointment_slots SET confirmed_count = confirmed_count + 1
+        WHERE id = NEW.slot_id
+           OR id IN (SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = NEW.id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+INSERT INTO schema_migrations (version, phase) VALUES (18, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,39 @@
+-- Staff resources: interpreters and chaperones of a clinic that an
+-- appointment can require besides its clinician. Each requirement is met by
+-- reserving one of them for the appointment's time range. A reservation
+-- holds the resource while its appointment is pending, awaiting approval or
+-- confirmed, so expiry and cancellation free it without touching this
+-- table.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS staff_resources (
+    id          uuid PRIMARY KEY,
+    clinic_id   uuid NOT NULL REFERENCES clinics(id),
+    kind        text NOT NULL,
+    language    text,
+    name        text NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT chk_staff_resource_kind CHECK (kind IN ('interpreter', 'chaperone')),
+    CONSTRAINT chk_staff_resource_language CHECK ((kind = 'interpreter') = (language IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_staff_resources_clinic_kind
+    ON staff_resources(clinic_id, kind);
+
+CREATE TABLE IF NOT EXISTS appointment_resources (
+    appointment_id  uuid NOT NULL REFERENCES appointments(id),
+    resource_id     uuid NOT NULL REFERENCES staff_resources(id),
+    start_time      timestamptz NOT NULL,
+    end_time        timestamptz NOT NULL,
+
+    PRIMARY KEY (appointment_id, resource_id),
+    CONSTRAINT chk_appointment_resource_range CHECK (start_time < end_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_resources_resource
+    ON appointment_resources(resource_id, start_time);
+
+INSERT INTO schema_migrations (version, phase) VALUES (19, 'expand')
+ON CONFLICT (version) DO NOTHING;
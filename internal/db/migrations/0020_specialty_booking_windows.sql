@@ -0,0 +1,19 @@
+-- Per-specialty booking windows: slots of clinicians with the specialty can
+-- only be booked from min_lead_seconds before they start and, when
+-- max_lead_seconds is set, no earlier than that. Specialties without a row
+-- are bookable any time.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS specialty_booking_policies (
+    specialty         text PRIMARY KEY,
+    min_lead_seconds  bigint NOT NULL DEFAULT 0,
+    max_lead_seconds  bigint,
+    updated_at        timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT chk_booking_policy_min_lead CHECK (min_lead_seconds >= 0),
+    CONSTRAINT chk_booking_policy_max_lead CHECK (max_lead_seconds IS NULL OR max_lead_seconds > min_lead_seconds)
+);
+
+INSERT INTO schema_migrations (version, phase) VALUES (20, 'expand')
+ON CONFLICT (version) DO NOTHING;
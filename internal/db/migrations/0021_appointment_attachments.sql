@@ -0,0 +1,28 @@
+-- Appointment attachments: referral letters and intake forms uploaded for an
+-- appointment. The content lives in blob storage under blob_key; a row is
+-- only written once the upload is stored and passed the virus scan, or
+-- unscanned when no scanner is configured.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS appointment_attachments (
+    id              uuid PRIMARY KEY,
+    appointment_id  uuid NOT NULL REFERENCES appointments(id),
+    kind            text NOT NULL,
+    filename        text NOT NULL,
+    content_type    text NOT NULL,
+    size_bytes      bigint NOT NULL,
+    blob_key        text NOT NULL UNIQUE,
+    scan_status     text NOT NULL,
+    created_at      timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT chk_attachment_kind CHECK (kind IN ('referral_letter', 'intake_form', 'other')),
+    CONSTRAINT chk_attachment_size CHECK (size_bytes > 0),
+    CONSTRAINT chk_attachment_scan_status CHECK (scan_status IN ('clean', 'unscanned'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_attachments_appointment
+    ON appointment_attachments(appointment_id, created_at);
+
+INSERT INTO schema_migrations (version, phase) VALUES (21, 'expand')
+ON CONFLICT (version) DO NOTHING;
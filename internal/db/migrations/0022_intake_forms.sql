@@ -0,0 +1,26 @@
+-- Intake forms: a questionnaire template per slot type and each
+-- appointment's answers to it. version counts changes to a template, so
+-- answers record which version they were given against. Reminders for
+-- incomplete forms are logged as APPOINTMENT_INTAKE_REMINDER events, which
+-- also keep an appointment from being reminded twice.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS intake_templates (
+    slot_type   text PRIMARY KEY,
+    version     integer NOT NULL DEFAULT 1,
+    questions   jsonb NOT NULL,
+    updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS intake_responses (
+    appointment_id    uuid PRIMARY KEY REFERENCES appointments(id),
+    template_version  integer NOT NULL,
+    answers           jsonb NOT NULL,
+    complete          boolean NOT NULL DEFAULT false,
+    created_at        timestamptz NOT NULL DEFAULT now(),
+    updated_at        timestamptz NOT NULL DEFAULT now()
+);
+
+INSERT INTO schema_migrations (version, phase) VALUES (22, 'expand')
+ON CONFLICT (version) DO NOTHING;
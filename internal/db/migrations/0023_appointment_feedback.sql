@@ -0,0 +1,21 @@
+-- Post-visit feedback: one rating from 1 to 5, with an optional comment, per
+-- appointment. clinician_id is copied from the slot so ratings aggregate per
+-- clinician without joining through appointments. Requests for feedback are
+-- logged as APPOINTMENT_FEEDBACK_REQUESTED events, which also keep an
+-- appointment from being asked twice.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS appointment_feedback (
+    appointment_id  uuid PRIMARY KEY REFERENCES appointments(id),
+    clinician_id    uuid NOT NULL REFERENCES clinicians(id),
+    rating          smallint NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    comment         text,
+    created_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_feedback_clinician
+    ON appointment_feedback (clinician_id, created_at);
+
+INSERT INTO schema_migrations (version, phase) VALUES (23, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,51 @@
+-- Data retention: how many months appointments, events and feedback are
+-- kept on this database. Rows carry no tenant, so there is one policy per
+-- shard, owned by the tenant that set it, and it is only set and applied
+-- while that tenant has the shard to itself.
+-- NULL keeps that kind of record forever. Every run of the retention worker
+-- over an entity is recorded in retention_runs, dry runs included, as the
+-- audit trail of what was deleted and when.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS retention_policy (
+    id                 smallint PRIMARY KEY DEFAULT 1,
+    tenant_id          text NOT NULL DEFAULT '',
+    appointment_months integer,
+    event_months       integer,
+    feedback_months    integer,
+    updated_at         timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT chk_retention_policy_singleton CHECK (id = 1),
+    CONSTRAINT chk_retention_policy_months CHECK (
+        (appointment_months IS NULL OR appointment_months > 0)
+        AND (event_months IS NULL OR event_months > 0)
+        AND (feedback_months IS NULL OR feedback_months > 0))
+);
+
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id             uuid PRIMARY KEY,
+    entity         text NOT NULL,
+    tenant_id      text NOT NULL DEFAULT '',
+    retain_months  integer NOT NULL,
+    cutoff         timestamptz NOT NULL,
+    dry_run        boolean NOT NULL,
+    matched        integer NOT NULL DEFAULT 0,
+    deleted        integer NOT NULL DEFAULT 0,
+    started_at     timestamptz NOT NULL,
+    finished_at    timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT chk_retention_run_entity CHECK (entity IN ('appointments', 'events', 'feedback'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_runs_started
+    ON retention_runs (started_at);
+
+CREATE INDEX IF NOT EXISTS idx_event_logs_created_at
+    ON event_logs (created_at);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_feedback_created
+    ON appointment_feedback (created_at);
+
+INSERT INTO schema_migrations (version, phase) VALUES (24, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,32 @@
+-- Break-glass audit: one row per patient whose personal data an API
+-- response returned to a staff member or admin, naming who read it, through
+-- which endpoint and why when a reason was given. Rows are kept on the
+-- shard holding the patient and are never updated. patient_id has no
+-- foreign key so the trail outlives the records it describes.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS pii_access_log (
+    id           uuid PRIMARY KEY,
+    actor        text NOT NULL,
+    actor_kind   text NOT NULL,
+    reason       text,
+    patient_id   uuid NOT NULL,
+    endpoint     text NOT NULL,
+    request_id   text NOT NULL DEFAULT '',
+    accessed_at  timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT chk_pii_access_actor_kind CHECK (actor_kind IN ('staff', 'admin'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_pii_access_log_patient
+    ON pii_access_log (patient_id, accessed_at);
+
+CREATE INDEX IF NOT EXISTS idx_pii_access_log_actor
+    ON pii_access_log (actor, accessed_at);
+
+CREATE INDEX IF NOT EXISTS idx_pii_access_log_accessed
+    ON pii_access_log (accessed_at);
+
+INSERT INTO schema_migrations (version, phase) VALUES (25, 'expand')
+ON CONFLICT (version) DO NOTHING;
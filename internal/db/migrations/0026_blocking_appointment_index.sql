@@ -0,0 +1,15 @@
+-- Covering index for the checks made under the slot lock: whether an
+-- active appointment has a slot as its first one, and in which status. The
+-- partial predicate leaves out the cancelled, expired and rejected rows
+-- that make up most of the table, and both columns are in the index so the
+-- EXISTS probes never visit the heap. Later slots of an appointment are
+-- found through idx_appointment_extra_slots_slot.
+--
+-- phase: expand
+
+CREATE INDEX IF NOT EXISTS idx_appointments_slot_active
+    ON appointments (slot_id, status)
+    WHERE status IN ('pending', 'pending_approval', 'confirmed');
+
+INSERT INTO schema_migrations (version, phase) VALUES (26, 'expand')
+ON CONFLICT (version) DO NOTHING;
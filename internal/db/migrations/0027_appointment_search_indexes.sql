@@ -0,0 +1,25 @@
+-- Indexes for GET /appointments/search, which combines filters on the
+-- patient's name, the clinician, the specialty, the status and the slot's
+-- start time. Name matching is a case-insensitive substring match, so the
+-- patients get a trigram index on lower(name) that LIKE '%...%' can use.
+-- The others let a search on status, specialty or date range alone avoid
+-- scanning the whole table.
+--
+-- phase: expand
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_patients_name_trgm
+    ON patients USING gin (lower(name) gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_clinicians_specialty
+    ON clinicians (specialty);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_slots_start_time
+    ON appointment_slots (start_time);
+
+CREATE INDEX IF NOT EXISTS idx_appointments_status_created_at
+    ON appointments (status, created_at DESC);
+
+INSERT INTO schema_migrations (version, phase) VALUES (27, 'expand')
+ON CONFLICT (version) DO NOTHING;
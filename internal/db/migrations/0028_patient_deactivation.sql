@@ -0,0 +1,14 @@
+-- Deactivated patient accounts. A deactivated patient keeps their records
+-- but cannot book, and is left out of appointment searches by default.
+-- The columns hold who deactivated the account and why; reactivating
+-- clears them. Unlike erasure nothing is deleted or anonymised.
+--
+-- phase: expand
+
+ALTER TABLE patients
+    ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS deactivated_by TEXT,
+    ADD COLUMN IF NOT EXISTS deactivation_reason TEXT;
+
+INSERT INTO schema_migrations (version, phase) VALUES (28, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,26 @@
+-- Mobile devices registered for push notifications, one row per device
+-- token. A token belongs to one patient at a time: registering it again,
+-- as happens when another patient signs in on the same phone, moves it.
+-- provider names the push service the token was issued by. Rows live on
+-- the shard holding the patient and go when the provider reports the
+-- token unregistered.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS patient_devices (
+    id          uuid PRIMARY KEY,
+    patient_id  uuid NOT NULL REFERENCES patients(id),
+    provider    text NOT NULL,
+    token       text NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT uq_patient_devices_token UNIQUE (token),
+    CONSTRAINT chk_patient_devices_provider CHECK (provider IN ('fcm', 'apns'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_patient_devices_patient
+    ON patient_devices (patient_id);
+
+INSERT INTO schema_migrations (version, phase) VALUES (29, 'expand')
+ON CONFLICT (version) DO NOTHING;
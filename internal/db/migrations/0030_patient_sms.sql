@@ -0,0 +1,30 @@
+-- SMS replies. patients.phone is the number, in E.164, that a patient's
+-- replies come from; it is not unique, since family members often share
+-- one. sms_replies records each inbound message by the carrier's message
+-- ID, so a message the carrier delivers twice is acted on once and
+-- answered the same both times. action is what the reply asked for, if it
+-- was understood, and outcome what came of it.
+--
+-- phase: expand
+
+ALTER TABLE patients
+    ADD COLUMN IF NOT EXISTS phone TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_patients_phone
+    ON patients (phone)
+    WHERE phone IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS sms_replies (
+    message_id      text PRIMARY KEY,
+    from_number     text NOT NULL,
+    body            text NOT NULL,
+    action          text,
+    appointment_id  uuid REFERENCES appointments(id),
+    outcome         text NOT NULL,
+    received_at     timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT chk_sms_replies_action CHECK (action IN ('confirm', 'cancel'))
+);
+
+INSERT INTO schema_migrations (version, phase) VALUES (30, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,32 @@
+-- API keys for integrations, such as IVR phone trees, that call a few
+-- endpoints without staff credentials. Only the SHA-256 of a key is stored;
+-- prefix is its start, shown so keys can be told apart. scopes lists what
+-- the key may do, space-separated, and clinic_id, when set, limits it to
+-- that clinic's appointments. clinic_id has no foreign key, like other
+-- scopes, so a key for a clinic that goes away sees nothing. Revoked keys
+-- are kept.
+--
+-- pii_access_log also records what an integration was shown, under the
+-- key's prefix with actor_kind api_key.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id          uuid PRIMARY KEY,
+    name        text NOT NULL,
+    prefix      text NOT NULL,
+    key_hash    text NOT NULL,
+    scopes      text NOT NULL,
+    clinic_id   uuid,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    revoked_at  timestamptz,
+
+    CONSTRAINT uq_api_keys_key_hash UNIQUE (key_hash)
+);
+
+ALTER TABLE pii_access_log
+    DROP CONSTRAINT IF EXISTS chk_pii_access_actor_kind,
+    ADD CONSTRAINT chk_pii_access_actor_kind CHECK (actor_kind IN ('staff', 'admin', 'api_key'));
+
+INSERT INTO schema_migrations (version, phase) VALUES (31, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,23 @@
+-- Weekly availability of a clinician, which POST
+-- /clinicians/{id}/slots:generate expands into slots over a date range.
+-- periods is a JSON array of {"weekday", "start", "end"}: weekday 0 is
+-- Sunday, start and end are minutes after midnight in time_zone, an IANA
+-- zone name. Each period is cut into slots of slot_minutes.
+--
+-- phase: expand
+
+CREATE TABLE IF NOT EXISTS availability_templates (
+    clinician_id  uuid PRIMARY KEY REFERENCES clinicians(id),
+    time_zone     text NOT NULL,
+    slot_minutes  integer NOT NULL,
+    capacity      integer NOT NULL DEFAULT 1,
+    slot_type     text,
+    periods       jsonb NOT NULL,
+    updated_at    timestamptz NOT NULL DEFAULT now(),
+
+    CONSTRAINT chk_availability_templates_slot_minutes CHECK (slot_minutes > 0),
+    CONSTRAINT chk_availability_templates_capacity CHECK (capacity > 0)
+);
+
+INSERT INTO schema_migrations (version, phase) VALUES (32, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,14 @@
+-- Attendance: once its last slot has ended, a confirmed appointment is
+-- marked completed or no_show. Both leave confirmed, so the confirmed count
+-- trigger gives the place back; the slot is over by then.
+--
+-- The new statuses are not used here: a value added to an enum cannot be used
+-- in the transaction that adds it.
+--
+-- phase: expand
+
+ALTER TYPE appointment_status ADD VALUE IF NOT EXISTS 'completed';
+ALTER TYPE appointment_status ADD VALUE IF NOT EXISTS 'no_show';
+
+INSERT INTO schema_migrations (version, phase) VALUES (33, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,31 @@
+-- Check-in: a confirmed appointment moves to checked_in when the patient
+-- arrives, within a window around the slot's start, and records when.
+-- A checked-in appointment keeps its place, so the confirmed count trigger
+-- counts it like a confirmed one. The status is compared as text: a value
+-- added to an enum cannot be used in the transaction that adds it.
+--
+-- phase: expand
+
+ALTER TYPE appointment_status ADD VALUE IF NOT EXISTS 'checked_in';
+
+ALTER TABLE appointments
+    ADD COLUMN IF NOT EXISTS checked_in_at timestamptz;
+
+CREATE OR REPLACE FUNCTION appointments_count_confirmed() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.status::text IN ('confirmed', 'checked_in') THEN
+        UPDATE appointment_slots SET confirmed_count = confirmed_count - 1
+        WHERE id = OLD.slot_id
+           OR id IN (SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = OLD.id);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.status::text IN ('confirmed', 'checked_in') THEN
+        UPDATE appointment_slots SET confirmed_count = confirmed_count + 1
+        WHERE id = NEW.slot_id
+           OR id IN (SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = NEW.id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+INSERT INTO schema_migrations (version, phase) VALUES (34, 'expand')
+ON CONFLICT (version) DO NOTHING;
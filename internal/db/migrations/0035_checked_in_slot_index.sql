@@ -0,0 +1,15 @@
+-- idx_appointments_slot_active again, with checked-in appointments among
+-- the active ones. Its predicate names checked_in, so it follows the
+-- migration adding that status. The old index is dropped once the new one
+-- exists: every query it served also matches the wider predicate.
+--
+-- phase: expand
+
+CREATE INDEX IF NOT EXISTS idx_appointments_slot_held
+    ON appointments (slot_id, status)
+    WHERE status IN ('pending', 'pending_approval', 'confirmed', 'checked_in');
+
+DROP INDEX IF EXISTS idx_appointments_slot_active;
+
+INSERT INTO schema_migrations (version, phase) VALUES (35, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,11 @@
+-- Deactivated clinicians, e.g. after leaving the practice. Their slots
+-- cannot be booked and drop out of open slot searches; appointments already
+-- made are kept, for staff to move to a covering clinician.
+--
+-- phase: expand
+
+ALTER TABLE clinicians
+    ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
+
+INSERT INTO schema_migrations (version, phase) VALUES (36, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,29 @@
+-- No two slots of a clinician may overlap, other than deleted ones: an
+-- exclusion constraint over each slot's time range, per clinician.
+-- Overlapping slots double the clinician's availability and let two
+-- patients book the same half hour. Slots that merely touch, one ending as
+-- the next starts, do not overlap.
+--
+-- Older binaries may still write overlapping slots, so this is applied once
+-- they are gone. It fails while overlapping slots exist; list them with
+--
+--   SELECT a.id, b.id FROM appointment_slots a
+--   JOIN appointment_slots b ON b.practitioner_id = a.practitioner_id AND b.id > a.id
+--   WHERE a.status <> 'deleted' AND b.status <> 'deleted'
+--     AND a.start_time < b.end_time AND b.start_time < a.end_time;
+--
+-- and delete or move one of each pair first.
+--
+-- phase: contract
+
+-- btree_gist lets the GiST index compare practitioner_id with =
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE appointment_slots DROP CONSTRAINT IF EXISTS appointment_slots_no_overlap;
+ALTER TABLE appointment_slots
+    ADD CONSTRAINT appointment_slots_no_overlap
+    EXCLUDE USING gist (practitioner_id WITH =, tstzrange(start_time, end_time) WITH &&)
+    WHERE (status <> 'deleted');
+
+INSERT INTO schema_migrations (version, phase) VALUES (37, 'contract')
+ON CONFLICT (version) DO NOTHING;
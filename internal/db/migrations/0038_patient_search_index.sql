@@ -0,0 +1,12 @@
+-- Index for GET /patients?query=, which matches a case-insensitive
+-- substring of the patient's name or email. The name already has a trigram
+-- index from 0027; this adds one on the email so the OR of both can use a
+-- bitmap scan.
+--
+-- phase: expand
+
+CREATE INDEX IF NOT EXISTS idx_patients_email_trgm
+    ON patients USING gin (lower(email) gin_trgm_ops);
+
+INSERT INTO schema_migrations (version, phase) VALUES (38, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,24 @@
+-- A hold moved to another slot keeps its status, so the status trigger from
+-- 0012 does not see it. Bump the availability of the clinicians of both
+-- slots when an appointment's slot changes.
+--
+-- phase: expand
+
+CREATE OR REPLACE FUNCTION appointments_slot_bump_availability() RETURNS trigger AS $$
+BEGIN
+    PERFORM bump_availability_version(s.practitioner_id)
+    FROM appointment_slots s
+    WHERE s.id IN (OLD.slot_id, NEW.slot_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_appointments_slot_bump_availability ON appointments;
+CREATE TRIGGER trg_appointments_slot_bump_availability
+    AFTER UPDATE OF slot_id ON appointments
+    FOR EACH ROW
+    WHEN (NEW.slot_id IS DISTINCT FROM OLD.slot_id)
+    EXECUTE FUNCTION appointments_slot_bump_availability();
+
+INSERT INTO schema_migrations (version, phase) VALUES (39, 'expand')
+ON CONFLICT (version) DO NOTHING;
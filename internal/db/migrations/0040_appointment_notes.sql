@@ -0,0 +1,22 @@
+-- The reason for a visit, given when booking, and notes staff add to an
+-- appointment afterwards. Notes are kept in the order they were written and
+-- are never edited.
+--
+-- phase: expand
+
+ALTER TABLE appointments
+    ADD COLUMN IF NOT EXISTS reason TEXT;
+
+CREATE TABLE IF NOT EXISTS appointment_notes (
+    id              uuid PRIMARY KEY,
+    appointment_id  uuid NOT NULL REFERENCES appointments(id),
+    author          text NOT NULL,
+    body            text NOT NULL,
+    created_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_notes_appointment
+    ON appointment_notes(appointment_id, created_at);
+
+INSERT INTO schema_migrations (version, phase) VALUES (40, 'expand')
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+)
+
+var (
+	poolAcquireWait = metrics.NewHistogram(
+		"db_pool_acquire_wait_seconds",
+		"Time taken to acquire a Postgres connection from the pool, by pool.",
+		[]float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		"pool",
+	)
+	poolConns = metrics.NewGauge(
+		"db_pool_connections",
+		"Postgres pool connections by pool and state (acquired, idle, total, max).",
+		"pool", "state",
+	)
+	poolMeanWait = metrics.NewGauge(
+		"db_pool_empty_acquire_wait_mean_seconds",
+		"Mean wait of the acquisitions that found no free connection over the last sample, by pool.",
+		"pool",
+	)
+	poolWaitAlerts = metrics.NewCounter(
+		"db_pool_wait_alerts_total",
+		"Times the mean acquisition wait of a pool rose above the alert threshold.",
+		"pool",
+	)
+)
+
+// acquireTracer times every Acquire of one pool. It only implements the
+// query hooks because pgx requires them of a tracer.
+type acquireTracer struct {
+	pool string
+}
+
+type acquireStartKey struct{}
+
+func (t acquireTracer) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	return context.WithValue(ctx, acquireStartKey{}, time.Now())
+}
+
+func (t acquireTracer) TraceAcquireEnd(ctx context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	start, ok := ctx.Value(acquireStartKey{}).(time.Time)
+	if !ok || data.Err != nil {
+		return
+	}
+	poolAcquireWait.Observe(time.Since(start).Seconds(), t.pool)
+}
+
+func (acquireTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return ctx
+}
+
+func (acquireTracer) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+// PoolSampleInterval is how often a PoolMonitor reads pool statistics
+const PoolSampleInterval = 10 * time.Second
+
+// PoolMonitor exports the statistics of named pools as gauges and warns when
+// the mean wait of acquisitions that found every connection busy rises above
+// a threshold. A pgxpool cannot be resized while open, so the warning names
+// the pool to give a larger pool_max_conns rather than resizing it.
+type PoolMonitor struct {
+	pools     map[string]*pgxpool.Pool
+	alertWait time.Duration
+
+	// Only touched by sample
+	last     map[string]poolWaits
+	alerting map[string]bool
+}
+
+type poolWaits struct {
+	count  int64
+	waited time.Duration
+}
+
+// NewPoolMonitor watches pools by name. alertWait of 0 exports the
+// statistics without alerting.
+func NewPoolMonitor(pools map[string]*pgxpool.Pool, alertWait time.Duration) *PoolMonitor {
+	m := &PoolMonitor{
+		pools:     pools,
+		alertWait: alertWait,
+		last:      make(map[string]poolWaits, len(pools)),
+		alerting:  make(map[string]bool, len(pools)),
+	}
+	for name, pool := range pools {
+		st := pool.Stat()
+		m.last[name] = poolWaits{st.EmptyAcquireCount(), st.EmptyAcquireWaitTime()}
+	}
+	return m
+}
+
+// Run samples the pools every interval until ctx is done
+func (m *PoolMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.sample()
+		}
+	}
+}
+
+func (m *PoolMonitor) sample() {
+	names := make([]string, 0, len(m.pools))
+	for name := range m.pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		st := m.pools[name].Stat()
+		poolConns.Set(float64(st.AcquiredConns()), name, "acquired")
+		poolConns.Set(float64(st.IdleConns()), name, "idle")
+		poolConns.Set(float64(st.TotalConns()), name, "total")
+		poolConns.Set(float64(st.MaxConns()), name, "max")
+
+		now := poolWaits{st.EmptyAcquireCount(), st.EmptyAcquireWaitTime()}
+		prev := m.last[name]
+		m.last[name] = now
+		var mean time.Duration
+		if n := now.count - prev.count; n > 0 {
+			mean = (now.waited - prev.waited) / time.Duration(n)
+		}
+		poolMeanWait.Set(mean.Seconds(), name)
+
+		if m.alertWait <= 0 {
+			continue
+		}
+		over := mean > m.alertWait
+		switch {
+		case over && !m.alerting[name]:
+			poolWaitAlerts.Inc(name)
+			log.Printf("postgres pool %s: acquisitions waited %s on average for a free connection, above %s; all %d connections were busy, consider raising pool_max_conns",
+				name, mean, m.alertWait, st.MaxConns())
+		case !over && m.alerting[name]:
+			log.Printf("postgres pool %s: acquisition waits back under %s", name, m.alertWait)
+		}
+		m.alerting[name] = over
+	}
+}
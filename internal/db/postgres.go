@@ -3,22 +3,59 @@ package db
 import (
 	"context"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func ConnectPostgres(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+// ConnectPostgres opens a pool for dsn. name labels the pool's metrics, e.g.
+// the shard it serves. tagRequests tags each connection with the request it
+// is acquired for, see requestTagger. prepare lists hot queries to prepare
+// on every new connection. The pool's MinConns connections are open before
+// it returns, so the first requests after a start do not pay for dialing.
+func ConnectPostgres(ctx context.Context, name, dsn string, tagRequests bool, prepare ...string) (*pgxpool.Pool, error) {
 	cfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("parse postgres dsn: %w", err)
 	}
 
-	cfg.MaxConns = 10
-	cfg.MinConns = 1
+	// pool_max_conns in the DSN wins, so shards can be sized individually
+	if !strings.Contains(dsn, "pool_max_conns") {
+		cfg.MaxConns = 10
+	}
+	if !strings.Contains(dsn, "pool_min_conns") {
+		cfg.MinConns = 1
+	}
 	cfg.HealthCheckPeriod = 30 * time.Second
 	cfg.MaxConnLifetime = time.Hour
 	cfg.MaxConnIdleTime = 15 * time.Minute
+	cfg.ConnConfig.Tracer = acquireTracer{pool: name}
+	appName := cfg.ConnConfig.RuntimeParams[appNameKey]
+	if appName == "" {
+		appName = defaultAppName()
+	}
+	appName = appName[:min(len(appName), maxAppName)]
+	cfg.ConnConfig.RuntimeParams[appNameKey] = appName
+	if tagRequests {
+		cfg.PrepareConn = requestTagger{base: appName}.prepare
+	}
+
+	// Statements are cached per connection unless the DSN picks another
+	// mode, e.g. default_query_exec_mode=exec behind PgBouncer in
+	// transaction mode, where a prepared statement may not be on the next
+	// transaction's server connection
+	if !strings.Contains(dsn, "default_query_exec_mode") {
+		cfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	}
+	if cfg.ConnConfig.DefaultQueryExecMode == pgx.QueryExecModeCacheStatement && len(prepare) > 0 {
+		cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			prepareStatements(ctx, name, conn, prepare)
+			return nil
+		}
+	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
@@ -33,6 +70,43 @@ func ConnectPostgres(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
 		pool.Close()
 		return nil, fmt.Errorf("ping postgres: %w", err)
 	}
+	if err := warm(pingCtx, pool); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("warm postgres pool: %w", err)
+	}
 
 	return pool, nil
 }
+
+// prepareStatements prepares each of sqls on conn under its own text, which
+// pgx then uses for any query with that text. A failure is only logged: the
+// query still runs and gets cached on first use, and failing here would
+// refuse every connection, e.g. while a migration is yet to add a table.
+func prepareStatements(ctx context.Context, pool string, conn *pgx.Conn, sqls []string) {
+	for _, sql := range sqls {
+		if _, err := conn.Prepare(ctx, sql, sql); err != nil {
+			log.Printf("postgres pool %s: prepare statement: %v", pool, err)
+			return
+		}
+	}
+}
+
+// warm opens the pool's MinConns connections by holding that many at once.
+// pgxpool opens them in the background, so without this the first requests
+// after a start would dial and prepare them.
+func warm(ctx context.Context, pool *pgxpool.Pool) error {
+	conns := make([]*pgxpool.Conn, 0, pool.Config().MinConns)
+	defer func() {
+		for _, c := range conns {
+			c.Release()
+		}
+	}()
+	for range pool.Config().MinConns {
+		c, err := pool.Acquire(ctx)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, c)
+	}
+	return nil
+}
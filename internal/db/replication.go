@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReplicationStatus reports whether the server is a streaming standby and, if
+// so, how far replay is behind. lag is nil on a primary, and also on a standby
+// that has not replayed anything yet.
+func ReplicationStatus(ctx context.Context, pool *pgxpool.Pool) (inRecovery bool, lag *time.Duration, err error) {
+	var lagSeconds *float64
+
+	err = pool.QueryRow(ctx, `
+		SELECT pg_is_in_recovery(),
+		       CASE WHEN pg_is_in_recovery()
+		            THEN EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())::float8
+		       END
+	`).Scan(&inRecovery, &lagSeconds)
+	if err != nil {
+		return false, nil, fmt.Errorf("query replication status: %w", err)
+	}
+
+	if lagSeconds != nil {
+		d := time.Duration(*lagSeconds * float64(time.Second))
+		lag = &d
+	}
+	return inRecovery, lag, nil
+}
+
+// PromotePostgres promotes a standby to primary and waits up to a minute for
+// it to accept writes. It requires superuser or the pg_promote grant.
+func PromotePostgres(ctx context.Context, pool *pgxpool.Pool) error {
+	var ok bool
+	if err := pool.QueryRow(ctx, `SELECT pg_promote(true, 60)`).Scan(&ok); err != nil {
+		return fmt.Errorf("promote postgres: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("promote postgres: standby did not finish promotion within 60s")
+	}
+	return nil
+}
+
+// WALPosition returns the position in the WAL the primary has written up to,
+// which is past the commit of every transaction that has returned. It fails
+// on a standby.
+func WALPosition(ctx context.Context, pool *pgxpool.Pool) (string, error) {
+	var lsn string
+	if err := pool.QueryRow(ctx, `SELECT pg_current_wal_lsn()::text`).Scan(&lsn); err != nil {
+		return "", fmt.Errorf("query wal position: %w", err)
+	}
+	return lsn, nil
+}
+
+// ReplayedTo reports whether the server has the WAL up to lsn: always on a
+// primary, and once replay has passed it on a standby
+func ReplayedTo(ctx context.Context, pool *pgxpool.Pool, lsn string) (bool, error) {
+	var ok bool
+	err := pool.QueryRow(ctx, `
+		SELECT NOT pg_is_in_recovery() OR COALESCE(pg_last_wal_replay_lsn() >= $1::pg_lsn, false)
+	`, lsn).Scan(&ok)
+	if err != nil {
+		return false, fmt.Errorf("query wal replay: %w", err)
+	}
+	return ok, nil
+}
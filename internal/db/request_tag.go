@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/requestid"
+)
+
+const (
+	appNameKey = "application_name"
+	// maxAppName is the longest application_name Postgres keeps; it
+	// truncates longer ones
+	maxAppName = 63
+	// tagTimeout bounds tagging a connection, which does not follow the
+	// request's cancellation
+	tagTimeout = time.Second
+)
+
+// defaultAppName is the application_name of connections whose DSN does not
+// set one: the binary's name, e.g. api-server
+func defaultAppName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// requestTagger tags each connection with the ID of the request it is
+// acquired for, by appending it to application_name, so pg_stat_activity and
+// log_line_prefix's %a show which HTTP request a statement runs for. Setting
+// it costs a round trip whenever the connection changes hands between
+// requests, which is nearly every acquire, so it is only turned on with
+// DB_REQUEST_TAGS while tracing a problem. Queries outside a request put the
+// bare name back.
+type requestTagger struct {
+	base string
+}
+
+func (t requestTagger) prepare(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	want := t.base
+	if id := requestid.Tag(requestid.From(ctx)); id != "" {
+		suffix := " req=" + id
+		want = t.base[:min(len(t.base), maxAppName-len(suffix))] + suffix
+	}
+
+	// Postgres reports every change of application_name back, so the
+	// connection always knows its current one
+	if conn.PgConn().ParameterStatus(appNameKey) == want {
+		return true, nil
+	}
+	// A request cancelled mid-tag would otherwise cost a healthy connection
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tagTimeout)
+	defer cancel()
+	if _, err := conn.Exec(ctx, `SELECT set_config('application_name', $1, false)`, want); err != nil {
+		// The session is in an unknown state; drop the connection
+		return false, fmt.Errorf("tag connection with request id: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,212 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var pgMigrations embed.FS
+
+// Phase is the role a migration plays in a blue/green deploy
+type Phase string
+
+const (
+	// PhaseExpand only adds to the schema. Binaries that predate it keep
+	// working, so it is applied before the binaries that need it roll out.
+	PhaseExpand Phase = "expand"
+	// PhaseContract removes or tightens something older binaries may still
+	// rely on, so it is applied once none of them run. The binaries that ship
+	// it must work with and without it.
+	PhaseContract Phase = "contract"
+)
+
+// schemaMigrationsVersion is the migration that creates schema_migrations.
+// It and every later migration must record itself there.
+const schemaMigrationsVersion = 14
+
+// ErrSchemaIncompatible means this binary must not run against the database
+var ErrSchemaIncompatible = errors.New("database schema incompatible with this binary")
+
+// Migration is a Postgres migration, declared by its file name and a
+// "-- phase: expand" or "-- phase: contract" line in its header comment
+type Migration struct {
+	Version int
+	Name    string
+	Phase   Phase
+}
+
+// Migrations lists the Postgres migrations this binary ships, in order
+func Migrations() ([]Migration, error) {
+	names, err := fs.Glob(pgMigrations, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		base := strings.TrimPrefix(name, "migrations/")
+		version, err := strconv.Atoi(strings.SplitN(base, "_", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("bad migration name %s", name)
+		}
+
+		body, err := pgMigrations.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		phase, err := migrationPhase(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", base, err)
+		}
+		if version >= schemaMigrationsVersion && !strings.Contains(string(body), "INSERT INTO schema_migrations") {
+			return nil, fmt.Errorf("migration %s does not record itself in schema_migrations", base)
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: base, Phase: phase})
+	}
+	return migrations, nil
+}
+
+// migrationPhase reads the phase line from the leading comment of a migration
+func migrationPhase(body string) (Phase, error) {
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		v, ok := strings.CutPrefix(strings.TrimSpace(strings.TrimPrefix(line, "--")), "phase:")
+		if !ok {
+			continue
+		}
+		switch p := Phase(strings.TrimSpace(v)); p {
+		case PhaseExpand, PhaseContract:
+			return p, nil
+		default:
+			return "", fmt.Errorf("unknown phase %q", p)
+		}
+	}
+	return "", errors.New("no phase in header comment")
+}
+
+// SchemaStatus compares the migrations this binary ships with those applied
+type SchemaStatus struct {
+	Binary   int // newest migration this binary ships
+	Database int // newest migration applied
+
+	Missing []Migration // expand migrations this binary ships that are not applied
+	Pending []Migration // contract migrations this binary ships that are not applied yet
+	Newer   []Migration // applied migrations this binary predates, with no Name
+}
+
+// CheckSchema compares the applied migrations with the ones this binary
+// ships. It returns ErrSchemaIncompatible when an expand migration the
+// binary needs is missing, or when the database is ahead of the binary by a
+// contract migration or by more than window migrations. Pending contract
+// migrations are fine: they are applied after the rollout.
+func CheckSchema(ctx context.Context, pool *pgxpool.Pool, window int) (SchemaStatus, error) {
+	var st SchemaStatus
+
+	shipped, err := Migrations()
+	if err != nil {
+		return st, err
+	}
+	if len(shipped) > 0 {
+		st.Binary = shipped[len(shipped)-1].Version
+	}
+
+	applied, err := appliedMigrations(ctx, pool)
+	if err != nil {
+		return st, err
+	}
+	for _, m := range applied {
+		st.Database = max(st.Database, m.Version)
+		if m.Version > st.Binary {
+			st.Newer = append(st.Newer, m)
+		}
+	}
+
+	for _, m := range shipped {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if m.Phase == PhaseContract {
+			st.Pending = append(st.Pending, m)
+		} else {
+			st.Missing = append(st.Missing, m)
+		}
+	}
+	sort.Slice(st.Newer, func(i, j int) bool { return st.Newer[i].Version < st.Newer[j].Version })
+
+	var problems []string
+	if len(st.Missing) > 0 {
+		problems = append(problems, "expand migrations not applied: "+migrationList(st.Missing))
+	}
+	var contracts []Migration
+	for _, m := range st.Newer {
+		if m.Phase == PhaseContract {
+			contracts = append(contracts, m)
+		}
+	}
+	if len(contracts) > 0 {
+		problems = append(problems, "database has contract migrations newer than this binary: "+migrationList(contracts))
+	}
+	if len(st.Newer) > window {
+		problems = append(problems, fmt.Sprintf("database is %d migrations ahead of this binary, more than the window of %d",
+			len(st.Newer), window))
+	}
+	if len(problems) > 0 {
+		return st, fmt.Errorf("%w (database %d, binary %d): %s",
+			ErrSchemaIncompatible, st.Database, st.Binary, strings.Join(problems, "; "))
+	}
+	return st, nil
+}
+
+func appliedMigrations(ctx context.Context, pool *pgxpool.Pool) (map[int]Migration, error) {
+	rows, err := pool.Query(ctx, `SELECT version, phase FROM schema_migrations`)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "42P01" { // undefined_table
+			return nil, fmt.Errorf("%w: schema_migrations does not exist, apply migration %04d",
+				ErrSchemaIncompatible, schemaMigrationsVersion)
+		}
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]Migration)
+	for rows.Next() {
+		var m Migration
+		if err := rows.Scan(&m.Version, &m.Phase); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[m.Version] = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func migrationList(ms []Migration) string {
+	parts := make([]string, len(ms))
+	for i, m := range ms {
+		parts[i] = fmt.Sprintf("%04d", m.Version)
+		if m.Name != "" {
+			parts[i] = m.Name
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+//go:embed sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// OpenSQLite opens (or creates) a SQLite database and applies the embedded
+// schema migrations. path may be a file path or ":memory:".
+func OpenSQLite(ctx context.Context, path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	// SQLite serializes writers anyway, and a single connection keeps an
+	// in-memory database shared by every caller.
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("ping sqlite: %w", err)
+	}
+
+	if err := migrateSQLite(ctx, sqlDB); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+
+	return sqlDB, nil
+}
+
+// migrateSQLite applies every embedded migration newer than PRAGMA user_version.
+// Foreign keys are not enforced while migrating, so a migration can rebuild a
+// table others reference, the only way SQLite changes a CHECK constraint; each
+// is checked with PRAGMA foreign_key_check before it commits instead.
+func migrateSQLite(ctx context.Context, sqlDB *sql.DB) error {
+	var current int
+	if err := sqlDB.QueryRowContext(ctx, "PRAGMA user_version").Scan(&current); err != nil {
+		return fmt.Errorf("read sqlite schema version: %w", err)
+	}
+
+	names, err := fs.Glob(sqliteMigrations, "sqlite/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	// PRAGMA foreign_keys is per connection and ignored inside a transaction
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return fmt.Errorf("disable sqlite foreign keys: %w", err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), "PRAGMA foreign_keys = ON")
+	}()
+
+	for _, name := range names {
+		base := strings.TrimPrefix(name, "sqlite/")
+		version, err := strconv.Atoi(strings.SplitN(base, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("bad sqlite migration name %s", name)
+		}
+		if version <= current {
+			continue
+		}
+
+		body, err := sqliteMigrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, string(body)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("apply sqlite migration %s: %w", base, err)
+		}
+		if err := foreignKeyCheck(ctx, tx); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("apply sqlite migration %s: %w", base, err)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("record sqlite migration %s: %w", base, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// foreignKeyCheck fails if any row references a missing one
+func foreignKeyCheck(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return fmt.Errorf("foreign key check: %w", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return fmt.Errorf("foreign key check: %w", err)
+		}
+		return fmt.Errorf("foreign key violation: row %d of %s references a missing %s", rowid.Int64, table, parent)
+	}
+	return rows.Err()
+}
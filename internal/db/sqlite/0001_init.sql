@@ -0,0 +1,91 @@
+-- SQLite schema for the reference/demo backend. Mirrors the Postgres
+-- migrations 0001-0005 for the tables the appointment repository uses.
+-- UUIDs are stored as text, timestamps as UTC text, enums as CHECKed text.
+
+CREATE TABLE IF NOT EXISTS clinics (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS patients (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    email       TEXT UNIQUE,
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS clinicians (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    specialty   TEXT,
+    clinic_id   TEXT REFERENCES clinics(id),
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS appointment_slots (
+    id               TEXT PRIMARY KEY,
+    practitioner_id  TEXT NOT NULL REFERENCES clinicians(id),
+    start_time       DATETIME NOT NULL,
+    end_time         DATETIME NOT NULL,
+    status           TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'blocked', 'deleted')),
+    capacity         INTEGER NOT NULL DEFAULT 1,
+    slot_type        TEXT,
+    created_at       DATETIME NOT NULL,
+    updated_at       DATETIME NOT NULL,
+
+    CHECK (end_time > start_time)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_slot_practitioner_time
+    ON appointment_slots (practitioner_id, start_time, end_time);
+
+CREATE TABLE IF NOT EXISTS appointments (
+    id           TEXT PRIMARY KEY,
+    slot_id      TEXT NOT NULL REFERENCES appointment_slots(id),
+    patient_id   TEXT NOT NULL REFERENCES patients(id),
+    status       TEXT NOT NULL CHECK (status IN ('pending', 'confirmed', 'cancelled', 'expired')),
+    created_at   DATETIME NOT NULL,
+    updated_at   DATETIME NOT NULL,
+    expires_at   DATETIME,
+
+    CHECK (expires_at IS NULL OR expires_at > created_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointments_status_expires_at
+    ON appointments (status, expires_at);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_confirmed_appointment_per_slot
+    ON appointments (slot_id)
+    WHERE status = 'confirmed';
+
+CREATE INDEX IF NOT EXISTS idx_appointments_patient_id_created_at
+    ON appointments (patient_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_appointments_slot_id_created_at
+    ON appointments (slot_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS event_logs (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_type     TEXT NOT NULL,
+    appointment_id TEXT REFERENCES appointments(id),
+    payload        TEXT,
+    created_at     DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_logs_appointment_id
+    ON event_logs (appointment_id);
+
+CREATE TABLE IF NOT EXISTS slot_type_prices (
+    clinic_id     TEXT NOT NULL REFERENCES clinics(id),
+    slot_type     TEXT NOT NULL,
+    amount_minor  INTEGER NOT NULL CHECK (amount_minor >= 0),
+    currency      TEXT NOT NULL CHECK (length(currency) = 3 AND currency = upper(currency)),
+    created_at    DATETIME NOT NULL,
+    updated_at    DATETIME NOT NULL,
+
+    PRIMARY KEY (clinic_id, slot_type)
+);
@@ -0,0 +1,16 @@
+-- Mirrors Postgres migration 0008
+
+CREATE TABLE IF NOT EXISTS booking_intents (
+    id              TEXT PRIMARY KEY,
+    slot_id         TEXT NOT NULL REFERENCES appointment_slots(id),
+    patient_id      TEXT NOT NULL REFERENCES patients(id),
+    lock_token      TEXT NOT NULL,
+    state           TEXT NOT NULL DEFAULT 'pending' CHECK (state IN ('pending', 'committed', 'aborted')),
+    appointment_id  TEXT REFERENCES appointments(id),
+    created_at      DATETIME NOT NULL,
+    resolved_at     DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_intents_pending
+    ON booking_intents (created_at)
+    WHERE state = 'pending';
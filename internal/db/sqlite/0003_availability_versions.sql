@@ -0,0 +1,58 @@
+-- Mirrors Postgres migration 0012
+
+CREATE TABLE IF NOT EXISTS clinician_availability_versions (
+    clinician_id  TEXT PRIMARY KEY REFERENCES clinicians(id),
+    version       INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TRIGGER IF NOT EXISTS trg_slots_insert_bump_availability
+AFTER INSERT ON appointment_slots
+BEGIN
+    INSERT INTO clinician_availability_versions (clinician_id, version)
+    VALUES (NEW.practitioner_id, 1)
+    ON CONFLICT (clinician_id) DO UPDATE SET version = version + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_slots_update_bump_availability
+AFTER UPDATE ON appointment_slots
+BEGIN
+    INSERT INTO clinician_availability_versions (clinician_id, version)
+    VALUES (OLD.practitioner_id, 1)
+    ON CONFLICT (clinician_id) DO UPDATE SET version = version + 1;
+    INSERT INTO clinician_availability_versions (clinician_id, version)
+    SELECT NEW.practitioner_id, 1
+    WHERE NEW.practitioner_id <> OLD.practitioner_id
+    ON CONFLICT (clinician_id) DO UPDATE SET version = version + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_slots_delete_bump_availability
+AFTER DELETE ON appointment_slots
+BEGIN
+    INSERT INTO clinician_availability_versions (clinician_id, version)
+    VALUES (OLD.practitioner_id, 1)
+    ON CONFLICT (clinician_id) DO UPDATE SET version = version + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_insert_bump_availability
+AFTER INSERT ON appointments
+BEGIN
+    INSERT INTO clinician_availability_versions (clinician_id, version)
+    SELECT practitioner_id, 1 FROM appointment_slots WHERE id = NEW.slot_id
+    ON CONFLICT (clinician_id) DO UPDATE SET version = version + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_status_bump_availability
+AFTER UPDATE OF status ON appointments
+BEGIN
+    INSERT INTO clinician_availability_versions (clinician_id, version)
+    SELECT practitioner_id, 1 FROM appointment_slots WHERE id = NEW.slot_id
+    ON CONFLICT (clinician_id) DO UPDATE SET version = version + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_delete_bump_availability
+AFTER DELETE ON appointments
+BEGIN
+    INSERT INTO clinician_availability_versions (clinician_id, version)
+    SELECT practitioner_id, 1 FROM appointment_slots WHERE id = OLD.slot_id
+    ON CONFLICT (clinician_id) DO UPDATE SET version = version + 1;
+END;
@@ -0,0 +1,36 @@
+-- Mirrors Postgres migration 0013
+
+ALTER TABLE appointment_slots
+    ADD COLUMN confirmed_count INTEGER NOT NULL DEFAULT 0
+    CONSTRAINT chk_slot_capacity CHECK (confirmed_count >= 0 AND confirmed_count <= capacity);
+
+UPDATE appointment_slots
+SET confirmed_count = (
+    SELECT count(*) FROM appointments a
+    WHERE a.slot_id = appointment_slots.id AND a.status = 'confirmed'
+);
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_insert_count_confirmed
+AFTER INSERT ON appointments
+WHEN NEW.status = 'confirmed'
+BEGIN
+    UPDATE appointment_slots SET confirmed_count = confirmed_count + 1 WHERE id = NEW.slot_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_update_count_confirmed
+AFTER UPDATE OF status, slot_id ON appointments
+BEGIN
+    UPDATE appointment_slots SET confirmed_count = confirmed_count - 1
+    WHERE id = OLD.slot_id AND OLD.status = 'confirmed';
+    UPDATE appointment_slots SET confirmed_count = confirmed_count + 1
+    WHERE id = NEW.slot_id AND NEW.status = 'confirmed';
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_delete_count_confirmed
+AFTER DELETE ON appointments
+WHEN OLD.status = 'confirmed'
+BEGIN
+    UPDATE appointment_slots SET confirmed_count = confirmed_count - 1 WHERE id = OLD.slot_id;
+END;
+
+DROP INDEX IF EXISTS uniq_confirmed_appointment_per_slot;
@@ -0,0 +1,19 @@
+-- Mirrors Postgres migration 0017
+
+CREATE TABLE IF NOT EXISTS appointment_series (
+    id              TEXT PRIMARY KEY,
+    patient_id      TEXT NOT NULL REFERENCES patients(id),
+    clinician_id    TEXT NOT NULL REFERENCES clinicians(id),
+    interval_days   INTEGER NOT NULL CHECK (interval_days > 0),
+    time_zone       TEXT NOT NULL DEFAULT 'UTC',
+    created_at      DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS appointment_series_occurrences (
+    series_id       TEXT NOT NULL REFERENCES appointment_series(id),
+    occurrence      INTEGER NOT NULL,
+    appointment_id  TEXT NOT NULL UNIQUE REFERENCES appointments(id),
+    exception       INTEGER NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (series_id, occurrence)
+);
@@ -0,0 +1,36 @@
+-- Mirrors Postgres migration 0018
+
+CREATE TABLE IF NOT EXISTS appointment_extra_slots (
+    appointment_id  TEXT NOT NULL REFERENCES appointments(id),
+    position        INTEGER NOT NULL CHECK (position > 1),
+    slot_id         TEXT NOT NULL REFERENCES appointment_slots(id),
+
+    PRIMARY KEY (appointment_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_extra_slots_slot
+    ON appointment_extra_slots(slot_id);
+
+DROP TRIGGER IF EXISTS trg_appointments_update_count_confirmed;
+CREATE TRIGGER trg_appointments_update_count_confirmed
+AFTER UPDATE OF status, slot_id ON appointments
+BEGIN
+    UPDATE appointment_slots SET confirmed_count = confirmed_count - 1
+    WHERE OLD.status = 'confirmed'
+      AND (id = OLD.slot_id
+           OR id IN (SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = OLD.id));
+    UPDATE appointment_slots SET confirmed_count = confirmed_count + 1
+    WHERE NEW.status = 'confirmed'
+      AND (id = NEW.slot_id
+           OR id IN (SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = NEW.id));
+END;
+
+DROP TRIGGER IF EXISTS trg_appointments_delete_count_confirmed;
+CREATE TRIGGER trg_appointments_delete_count_confirmed
+AFTER DELETE ON appointments
+WHEN OLD.status = 'confirmed'
+BEGIN
+    UPDATE appointment_slots SET confirmed_count = confirmed_count - 1
+    WHERE id = OLD.slot_id
+       OR id IN (SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = OLD.id);
+END;
@@ -0,0 +1,27 @@
+-- Mirrors Postgres migration 0019
+
+CREATE TABLE IF NOT EXISTS staff_resources (
+    id          TEXT PRIMARY KEY,
+    clinic_id   TEXT NOT NULL REFERENCES clinics(id),
+    kind        TEXT NOT NULL CHECK (kind IN ('interpreter', 'chaperone')),
+    language    TEXT,
+    name        TEXT NOT NULL,
+    created_at  DATETIME NOT NULL,
+
+    CHECK ((kind = 'interpreter') = (language IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_staff_resources_clinic_kind
+    ON staff_resources(clinic_id, kind);
+
+CREATE TABLE IF NOT EXISTS appointment_resources (
+    appointment_id  TEXT NOT NULL REFERENCES appointments(id),
+    resource_id     TEXT NOT NULL REFERENCES staff_resources(id),
+    start_time      DATETIME NOT NULL,
+    end_time        DATETIME NOT NULL CHECK (start_time < end_time),
+
+    PRIMARY KEY (appointment_id, resource_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_resources_resource
+    ON appointment_resources(resource_id, start_time);
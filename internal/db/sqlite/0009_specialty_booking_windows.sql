@@ -0,0 +1,10 @@
+-- Mirrors Postgres migration 0020
+
+CREATE TABLE IF NOT EXISTS specialty_booking_policies (
+    specialty         TEXT PRIMARY KEY,
+    min_lead_seconds  INTEGER NOT NULL DEFAULT 0 CHECK (min_lead_seconds >= 0),
+    max_lead_seconds  INTEGER,
+    updated_at        DATETIME NOT NULL,
+
+    CHECK (max_lead_seconds IS NULL OR max_lead_seconds > min_lead_seconds)
+);
@@ -0,0 +1,16 @@
+-- Mirrors Postgres migration 0021
+
+CREATE TABLE IF NOT EXISTS appointment_attachments (
+    id              TEXT PRIMARY KEY,
+    appointment_id  TEXT NOT NULL REFERENCES appointments(id),
+    kind            TEXT NOT NULL CHECK (kind IN ('referral_letter', 'intake_form', 'other')),
+    filename        TEXT NOT NULL,
+    content_type    TEXT NOT NULL,
+    size_bytes      INTEGER NOT NULL CHECK (size_bytes > 0),
+    blob_key        TEXT NOT NULL UNIQUE,
+    scan_status     TEXT NOT NULL CHECK (scan_status IN ('clean', 'unscanned')),
+    created_at      DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_attachments_appointment
+    ON appointment_attachments(appointment_id, created_at);
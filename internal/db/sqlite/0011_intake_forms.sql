@@ -0,0 +1,17 @@
+-- Mirrors Postgres migration 0022
+
+CREATE TABLE IF NOT EXISTS intake_templates (
+    slot_type   TEXT PRIMARY KEY,
+    version     INTEGER NOT NULL DEFAULT 1,
+    questions   TEXT NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS intake_responses (
+    appointment_id    TEXT PRIMARY KEY REFERENCES appointments(id),
+    template_version  INTEGER NOT NULL,
+    answers           TEXT NOT NULL,
+    complete          INTEGER NOT NULL DEFAULT 0,
+    created_at        DATETIME NOT NULL,
+    updated_at        DATETIME NOT NULL
+);
@@ -0,0 +1,12 @@
+-- Mirrors Postgres migration 0023
+
+CREATE TABLE IF NOT EXISTS appointment_feedback (
+    appointment_id  TEXT PRIMARY KEY REFERENCES appointments(id),
+    clinician_id    TEXT NOT NULL REFERENCES clinicians(id),
+    rating          INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    comment         TEXT,
+    created_at      DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_feedback_clinician
+    ON appointment_feedback (clinician_id, created_at);
@@ -0,0 +1,32 @@
+-- Mirrors Postgres migration 0024
+
+CREATE TABLE IF NOT EXISTS retention_policy (
+    id                 INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    tenant_id          TEXT NOT NULL DEFAULT '',
+    appointment_months INTEGER CHECK (appointment_months IS NULL OR appointment_months > 0),
+    event_months       INTEGER CHECK (event_months IS NULL OR event_months > 0),
+    feedback_months    INTEGER CHECK (feedback_months IS NULL OR feedback_months > 0),
+    updated_at         DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id             TEXT PRIMARY KEY,
+    entity         TEXT NOT NULL CHECK (entity IN ('appointments', 'events', 'feedback')),
+    tenant_id      TEXT NOT NULL DEFAULT '',
+    retain_months  INTEGER NOT NULL,
+    cutoff         DATETIME NOT NULL,
+    dry_run        INTEGER NOT NULL,
+    matched        INTEGER NOT NULL DEFAULT 0,
+    deleted        INTEGER NOT NULL DEFAULT 0,
+    started_at     DATETIME NOT NULL,
+    finished_at    DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_runs_started
+    ON retention_runs (started_at);
+
+CREATE INDEX IF NOT EXISTS idx_event_logs_created_at
+    ON event_logs (created_at);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_feedback_created
+    ON appointment_feedback (created_at);
@@ -0,0 +1,21 @@
+-- Mirrors Postgres migration 0025
+
+CREATE TABLE IF NOT EXISTS pii_access_log (
+    id           TEXT PRIMARY KEY,
+    actor        TEXT NOT NULL,
+    actor_kind   TEXT NOT NULL CHECK (actor_kind IN ('staff', 'admin')),
+    reason       TEXT,
+    patient_id   TEXT NOT NULL,
+    endpoint     TEXT NOT NULL,
+    request_id   TEXT NOT NULL DEFAULT '',
+    accessed_at  DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_pii_access_log_patient
+    ON pii_access_log (patient_id, accessed_at);
+
+CREATE INDEX IF NOT EXISTS idx_pii_access_log_actor
+    ON pii_access_log (actor, accessed_at);
+
+CREATE INDEX IF NOT EXISTS idx_pii_access_log_accessed
+    ON pii_access_log (accessed_at);
@@ -0,0 +1,5 @@
+-- Mirrors Postgres migration 0026. SQLite only picks a partial index when
+-- the query repeats its WHERE clause, so this one covers every row.
+
+CREATE INDEX IF NOT EXISTS idx_appointments_slot_active
+    ON appointments (slot_id, status);
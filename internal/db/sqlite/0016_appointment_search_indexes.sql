@@ -0,0 +1,11 @@
+-- Mirrors Postgres migration 0027. SQLite has no trigram index, so name
+-- searches scan the patients; the other indexes are the same.
+
+CREATE INDEX IF NOT EXISTS idx_clinicians_specialty
+    ON clinicians (specialty);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_slots_start_time
+    ON appointment_slots (start_time);
+
+CREATE INDEX IF NOT EXISTS idx_appointments_status_created_at
+    ON appointments (status, created_at DESC);
@@ -0,0 +1,5 @@
+-- Mirrors Postgres migration 0028
+
+ALTER TABLE patients ADD COLUMN deactivated_at DATETIME;
+ALTER TABLE patients ADD COLUMN deactivated_by TEXT;
+ALTER TABLE patients ADD COLUMN deactivation_reason TEXT;
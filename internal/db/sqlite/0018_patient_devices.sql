@@ -0,0 +1,13 @@
+-- Mirrors Postgres migration 0029
+
+CREATE TABLE IF NOT EXISTS patient_devices (
+    id          TEXT PRIMARY KEY,
+    patient_id  TEXT NOT NULL REFERENCES patients(id),
+    provider    TEXT NOT NULL CHECK (provider IN ('fcm', 'apns')),
+    token       TEXT NOT NULL UNIQUE,
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_patient_devices_patient
+    ON patient_devices (patient_id);
@@ -0,0 +1,17 @@
+-- Mirrors Postgres migration 0030
+
+ALTER TABLE patients ADD COLUMN phone TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_patients_phone
+    ON patients (phone)
+    WHERE phone IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS sms_replies (
+    message_id      TEXT PRIMARY KEY,
+    from_number     TEXT NOT NULL,
+    body            TEXT NOT NULL,
+    action          TEXT CHECK (action IN ('confirm', 'cancel')),
+    appointment_id  TEXT REFERENCES appointments(id),
+    outcome         TEXT NOT NULL,
+    received_at     DATETIME NOT NULL
+);
@@ -0,0 +1,40 @@
+-- Mirrors Postgres migration 0031. SQLite cannot change a CHECK
+-- constraint, so pii_access_log is rebuilt to allow api_key actors.
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    prefix      TEXT NOT NULL,
+    key_hash    TEXT NOT NULL UNIQUE,
+    scopes      TEXT NOT NULL,
+    clinic_id   TEXT,
+    created_at  DATETIME NOT NULL,
+    revoked_at  DATETIME
+);
+
+CREATE TABLE pii_access_log_new (
+    id           TEXT PRIMARY KEY,
+    actor        TEXT NOT NULL,
+    actor_kind   TEXT NOT NULL CHECK (actor_kind IN ('staff', 'admin', 'api_key')),
+    reason       TEXT,
+    patient_id   TEXT NOT NULL,
+    endpoint     TEXT NOT NULL,
+    request_id   TEXT NOT NULL DEFAULT '',
+    accessed_at  DATETIME NOT NULL
+);
+
+INSERT INTO pii_access_log_new
+SELECT id, actor, actor_kind, reason, patient_id, endpoint, request_id, accessed_at
+FROM pii_access_log;
+
+DROP TABLE pii_access_log;
+ALTER TABLE pii_access_log_new RENAME TO pii_access_log;
+
+CREATE INDEX IF NOT EXISTS idx_pii_access_log_patient
+    ON pii_access_log (patient_id, accessed_at);
+
+CREATE INDEX IF NOT EXISTS idx_pii_access_log_actor
+    ON pii_access_log (actor, accessed_at);
+
+CREATE INDEX IF NOT EXISTS idx_pii_access_log_accessed
+    ON pii_access_log (accessed_at);
@@ -0,0 +1,11 @@
+-- Mirrors Postgres migration 0032
+
+CREATE TABLE IF NOT EXISTS availability_templates (
+    clinician_id  TEXT PRIMARY KEY REFERENCES clinicians(id),
+    time_zone     TEXT NOT NULL,
+    slot_minutes  INTEGER NOT NULL CHECK (slot_minutes > 0),
+    capacity      INTEGER NOT NULL DEFAULT 1 CHECK (capacity > 0),
+    slot_type     TEXT,
+    periods       TEXT NOT NULL,
+    updated_at    DATETIME NOT NULL
+);
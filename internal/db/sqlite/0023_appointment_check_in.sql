@@ -0,0 +1,91 @@
+-- Mirrors Postgres migrations 0034 and 0035. SQLite cannot change a CHECK
+-- constraint, so appointments is rebuilt with the new status and column,
+-- along with its indexes and triggers. The confirmed count triggers count
+-- checked-in appointments like confirmed ones.
+
+CREATE TABLE appointments_new (
+    id           TEXT PRIMARY KEY,
+    slot_id      TEXT NOT NULL REFERENCES appointment_slots(id),
+    patient_id   TEXT NOT NULL REFERENCES patients(id),
+    status       TEXT NOT NULL CHECK (status IN ('pending', 'confirmed', 'cancelled', 'expired', 'pending_approval', 'rejected', 'completed', 'no_show', 'checked_in')),
+    created_at   DATETIME NOT NULL,
+    updated_at   DATETIME NOT NULL,
+    expires_at   DATETIME,
+    checked_in_at DATETIME,
+
+    CHECK (expires_at IS NULL OR expires_at > created_at)
+);
+
+INSERT INTO appointments_new (id, slot_id, patient_id, status, created_at, updated_at, expires_at)
+SELECT id, slot_id, patient_id, status, created_at, updated_at, expires_at FROM appointments;
+
+DROP TABLE appointments;
+ALTER TABLE appointments_new RENAME TO appointments;
+
+CREATE INDEX IF NOT EXISTS idx_appointments_status_expires_at
+    ON appointments (status, expires_at);
+
+CREATE INDEX IF NOT EXISTS idx_appointments_patient_id_created_at
+    ON appointments (patient_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_appointments_slot_id_created_at
+    ON appointments (slot_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_appointments_slot_held
+    ON appointments (slot_id, status);
+
+CREATE INDEX IF NOT EXISTS idx_appointments_status_created_at
+    ON appointments (status, created_at DESC);
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_insert_bump_availability
+AFTER INSERT ON appointments
+BEGIN
+    INSERT INTO clinician_availability_versions (clinician_id, version)
+    SELECT practitioner_id, 1 FROM appointment_slots WHERE id = NEW.slot_id
+    ON CONFLICT (clinician_id) DO UPDATE SET version = version + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_status_bump_availability
+AFTER UPDATE OF status ON appointments
+BEGIN
+    INSERT INTO clinician_availability_versions (clinician_id, version)
+    SELECT practitioner_id, 1 FROM appointment_slots WHERE id = NEW.slot_id
+    ON CONFLICT (clinician_id) DO UPDATE SET version = version + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_delete_bump_availability
+AFTER DELETE ON appointments
+BEGIN
+    INSERT INTO clinician_availability_versions (clinician_id, version)
+    SELECT practitioner_id, 1 FROM appointment_slots WHERE id = OLD.slot_id
+    ON CONFLICT (clinician_id) DO UPDATE SET version = version + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_insert_count_confirmed
+AFTER INSERT ON appointments
+WHEN NEW.status IN ('confirmed', 'checked_in')
+BEGIN
+    UPDATE appointment_slots SET confirmed_count = confirmed_count + 1 WHERE id = NEW.slot_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_update_count_confirmed
+AFTER UPDATE OF status, slot_id ON appointments
+BEGIN
+    UPDATE appointment_slots SET confirmed_count = confirmed_count - 1
+    WHERE OLD.status IN ('confirmed', 'checked_in')
+      AND (id = OLD.slot_id
+           OR id IN (SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = OLD.id));
+    UPDATE appointment_slots SET confirmed_count = confirmed_count + 1
+    WHERE NEW.status IN ('confirmed', 'checked_in')
+      AND (id = NEW.slot_id
+           OR id IN (SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = NEW.id));
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_delete_count_confirmed
+AFTER DELETE ON appointments
+WHEN OLD.status IN ('confirmed', 'checked_in')
+BEGIN
+    UPDATE appointment_slots SET confirmed_count = confirmed_count - 1
+    WHERE id = OLD.slot_id
+       OR id IN (SELECT slot_id FROM appointment_extra_slots WHERE appointment_id = OLD.id);
+END;
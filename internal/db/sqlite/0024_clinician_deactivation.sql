@@ -0,0 +1,3 @@
+-- Mirrors Postgres migration 0036
+
+ALTER TABLE clinicians ADD COLUMN deactivated_at DATETIME;
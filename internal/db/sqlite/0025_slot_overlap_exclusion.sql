@@ -0,0 +1,32 @@
+-- Mirrors Postgres migration 0037. SQLite has no exclusion constraints, so
+-- triggers refuse a slot overlapping another of its clinician, naming the
+-- Postgres constraint so both backends fail alike.
+
+CREATE TRIGGER IF NOT EXISTS trg_slots_insert_no_overlap
+BEFORE INSERT ON appointment_slots
+WHEN NEW.status <> 'deleted'
+BEGIN
+    SELECT RAISE(ABORT, 'appointment_slots_no_overlap')
+    WHERE EXISTS (
+        SELECT 1 FROM appointment_slots
+        WHERE practitioner_id = NEW.practitioner_id
+          AND status <> 'deleted'
+          AND start_time < NEW.end_time
+          AND end_time > NEW.start_time
+    );
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_slots_update_no_overlap
+BEFORE UPDATE OF practitioner_id, start_time, end_time, status ON appointment_slots
+WHEN NEW.status <> 'deleted'
+BEGIN
+    SELECT RAISE(ABORT, 'appointment_slots_no_overlap')
+    WHERE EXISTS (
+        SELECT 1 FROM appointment_slots
+        WHERE practitioner_id = NEW.practitioner_id
+          AND id <> NEW.id
+          AND status <> 'deleted'
+          AND start_time < NEW.end_time
+          AND end_time > NEW.start_time
+    );
+END;
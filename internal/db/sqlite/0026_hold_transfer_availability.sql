@@ -0,0 +1,10 @@
+-- Mirrors Postgres migration 0039
+
+CREATE TRIGGER IF NOT EXISTS trg_appointments_slot_bump_availability
+AFTER UPDATE OF slot_id ON appointments
+WHEN NEW.slot_id <> OLD.slot_id
+BEGIN
+    INSERT INTO clinician_availability_versions (clinician_id, version)
+    SELECT DISTINCT practitioner_id, 1 FROM appointment_slots WHERE id IN (OLD.slot_id, NEW.slot_id)
+    ON CONFLICT (clinician_id) DO UPDATE SET version = version + 1;
+END;
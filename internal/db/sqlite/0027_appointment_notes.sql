@@ -0,0 +1,14 @@
+-- Mirrors Postgres migration 0040
+
+ALTER TABLE appointments ADD COLUMN reason TEXT;
+
+CREATE TABLE IF NOT EXISTS appointment_notes (
+    id              TEXT PRIMARY KEY,
+    appointment_id  TEXT NOT NULL REFERENCES appointments(id),
+    author          TEXT NOT NULL,
+    body            TEXT NOT NULL,
+    created_at      DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_notes_appointment
+    ON appointment_notes(appointment_id, created_at);
@@ -0,0 +1,129 @@
+// Package demo seeds the small dataset used by the api-server demo mode
+package demo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+const (
+	clinicianCount = 3
+	patientCount   = 10
+	slotDays       = 3
+	slotLength     = 30 * time.Minute
+)
+
+var demoSlotTypes = []struct {
+	name  string
+	price int64
+}{
+	{"consultation", 8500},
+	{"follow_up", 4500},
+}
+
+// demoStaff are the clinic's interpreters and chaperones
+var demoStaff = []struct {
+	kind     appointment.ResourceKind
+	language string
+}{
+	{appointment.ResourceInterpreter, "es"},
+	{appointment.ResourceInterpreter, "fr"},
+	{appointment.ResourceChaperone, ""},
+}
+
+// Dataset holds the IDs created by Seed so they can be printed for evaluators
+type Dataset struct {
+	ClinicID     uuid.UUID
+	ClinicianIDs []uuid.UUID
+	PatientIDs   []uuid.UUID
+	SlotIDs      []uuid.UUID
+}
+
+// Seed creates one clinic with a few clinicians, an interpreter for each of
+// Spanish and French, a chaperone, patients, and open slots every half hour
+// from 09:00 to 12:00 UTC on each of the next few days.
+func Seed(ctx context.Context, s appointment.Seeder) (*Dataset, error) {
+	faker := gofakeit.New(42)
+	ds := &Dataset{ClinicID: uuid.New()}
+
+	if err := s.InsertClinic(ctx, appointment.Clinic{ID: ds.ClinicID, Name: "Demo Clinic"}); err != nil {
+		return nil, err
+	}
+
+	for _, st := range demoSlotTypes {
+		if err := s.SetSlotTypePrice(ctx, ds.ClinicID, st.name, appointment.Price{AmountMinor: st.price, Currency: "USD"}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, st := range demoStaff {
+		res := appointment.StaffResource{ID: uuid.New(), ClinicID: ds.ClinicID, Kind: st.kind, Name: faker.Name()}
+		if st.language != "" {
+			res.Language = &st.language
+		}
+		if err := s.InsertStaffResource(ctx, res); err != nil {
+			return nil, err
+		}
+	}
+
+	specialties := []string{"General Practice", "Dermatology", "Cardiology"}
+	for i := 0; i < clinicianCount; i++ {
+		spec := specialties[i%len(specialties)]
+		c := appointment.Clinician{
+			ID:        uuid.New(),
+			Name:      "Dr. " + faker.Name(),
+			Specialty: &spec,
+			ClinicID:  &ds.ClinicID,
+		}
+		if err := s.InsertClinician(ctx, c); err != nil {
+			return nil, err
+		}
+		ds.ClinicianIDs = append(ds.ClinicianIDs, c.ID)
+	}
+
+	for i := 0; i < patientCount; i++ {
+		email := fmt.Sprintf("demo.patient%d@example.com", i+1)
+		p := appointment.Patient{
+			ID:    uuid.New(),
+			Name:  faker.Name(),
+			Email: &email,
+		}
+		if err := s.InsertPatient(ctx, p); err != nil {
+			return nil, err
+		}
+		ds.PatientIDs = append(ds.PatientIDs, p.ID)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for day := 1; day <= slotDays; day++ {
+		dayStart := today.AddDate(0, 0, day).Add(9 * time.Hour)
+
+		for _, clinicianID := range ds.ClinicianIDs {
+			for i := 0; i < 6; i++ {
+				start := dayStart.Add(time.Duration(i) * slotLength)
+				slotType := demoSlotTypes[i%len(demoSlotTypes)].name
+				slot := appointment.AppointmentSlot{
+					ID:             uuid.New(),
+					PractitionerID: clinicianID,
+					StartTime:      start,
+					EndTime:        start.Add(slotLength),
+					Status:         appointment.SlotOpen,
+					Capacity:       1,
+					SlotType:       &slotType,
+				}
+				if err := s.InsertSlot(ctx, slot); err != nil {
+					return nil, err
+				}
+				ds.SlotIDs = append(ds.SlotIDs, slot.ID)
+			}
+		}
+	}
+
+	return ds, nil
+}
@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+)
+
+// Handler processes one job. Returning an error schedules a retry.
+// Handlers must be idempotent: a job can run more than once.
+type Handler func(ctx context.Context, job Job) error
+
+// Consumer drains one queue from a worker runtime
+type Consumer struct {
+	Queue      *Queue
+	Name       string // queue name
+	Handler    Handler
+	Batch      int           // jobs claimed per round
+	Visibility time.Duration // how long a claimed job is hidden from other workers
+	Backoff    func(attempt int) time.Duration
+}
+
+// recordTimeout bounds recording a job's outcome, which outlives the
+// context the job ran under
+const recordTimeout = 5 * time.Second
+
+var (
+	jobsProcessed = metrics.NewCounter(
+		"jobs_processed_total",
+		"Jobs handled by queue and outcome (done, retry, dead).",
+		"queue", "outcome",
+	)
+)
+
+// ExponentialBackoff doubles from base up to max
+func ExponentialBackoff(base, max time.Duration) func(int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		return min(d, max)
+	}
+}
+
+// Drain claims and handles batches until the queue is empty or stop is
+// closed. Its signature matches worker.Job.Run; register it on a worker
+// runtime with Interval as the polling interval.
+func (c Consumer) Drain(ctx context.Context, stop <-chan struct{}) error {
+	c = c.withDefaults()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		claimed, err := c.Queue.Claim(ctx, c.Name, c.Batch, c.Visibility)
+		if err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		for _, job := range claimed {
+			if err := c.process(ctx, job); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c Consumer) withDefaults() Consumer {
+	if c.Batch <= 0 {
+		c.Batch = 10
+	}
+	if c.Visibility <= 0 {
+		c.Visibility = time.Minute
+	}
+	if c.Backoff == nil {
+		c.Backoff = ExponentialBackoff(5*time.Second, 10*time.Minute)
+	}
+	return c
+}
+
+// process runs the handler and records the outcome. Only queue errors are
+// returned; handler errors become retries. The outcome is recorded even
+// when ctx ended, often the reason the handler failed, so a shutdown does
+// not leave the attempt to its visibility timeout. An attempt another
+// worker has since claimed again is left to that worker.
+func (c Consumer) process(ctx context.Context, job Job) error {
+	handlerErr := c.Handler(ctx, job)
+
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if handlerErr == nil {
+		if err := c.Queue.Complete(recordCtx, job); err != nil {
+			if errors.Is(err, ErrJobLost) {
+				log.Printf("job %s on %s finished after attempt %d was claimed again", job.ID, c.Name, job.Attempts)
+				return nil
+			}
+			return fmt.Errorf("complete job %s: %w", job.ID, err)
+		}
+		jobsProcessed.Inc(c.Name, "done")
+		return nil
+	}
+
+	state, err := c.Queue.Fail(recordCtx, job, handlerErr, c.Backoff(job.Attempts))
+	if errors.Is(err, ErrJobLost) {
+		log.Printf("job %s on %s failed after attempt %d was claimed again: %v", job.ID, c.Name, job.Attempts, handlerErr)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("record failure of job %s: %w", job.ID, err)
+	}
+	if state == StateDead {
+		jobsProcessed.Inc(c.Name, "dead")
+		log.Printf("job %s on %s is dead after %d attempts: %v", job.ID, c.Name, job.Attempts, handlerErr)
+		return nil
+	}
+	jobsProcessed.Inc(c.Name, "retry")
+	log.Printf("job %s on %s failed (attempt %d/%d), retrying: %v", job.ID, c.Name, job.Attempts, job.MaxAttempts, handlerErr)
+	return nil
+}
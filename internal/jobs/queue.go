@@ -0,0 +1,301 @@
+// Package jobs is an at-least-once job queue stored in Postgres. Workers
+// claim jobs with SELECT ... FOR UPDATE SKIP LOCKED; a claimed job becomes
+// visible again if it is not completed within its visibility timeout, and
+// moves to the dead state after MaxAttempts failures.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type State string
+
+const (
+	StateQueued  State = "queued"
+	StateRunning State = "running"
+	StateDone    State = "done"
+	StateDead    State = "dead"
+)
+
+const DefaultMaxAttempts = 5
+
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobLost is returned by Complete and Fail when the job is no longer
+// running the attempt they were given, e.g. because its visibility timeout
+// passed and another worker claimed it again
+var ErrJobLost = errors.New("job claimed by another attempt")
+
+type Job struct {
+	ID          uuid.UUID
+	Queue       string
+	Payload     json.RawMessage
+	State       State
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	LockedUntil *time.Time
+	LastError   *string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Decode unmarshals the payload into v
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// dbtx is satisfied by *pgxpool.Pool and pgx.Tx
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type Queue struct {
+	db dbtx
+}
+
+func NewQueue(pool *pgxpool.Pool) *Queue {
+	return &Queue{db: pool}
+}
+
+// WithTx returns a queue that enqueues inside tx, so a job is only visible
+// if the surrounding business change commits.
+func (q *Queue) WithTx(tx pgx.Tx) *Queue {
+	return &Queue{db: tx}
+}
+
+// EnqueueOption adjusts a job before it is inserted
+type EnqueueOption func(*Job)
+
+// RunAt delays the first attempt
+func RunAt(t time.Time) EnqueueOption {
+	return func(j *Job) { j.RunAt = t }
+}
+
+// MaxAttempts overrides DefaultMaxAttempts
+func MaxAttempts(n int) EnqueueOption {
+	return func(j *Job) { j.MaxAttempts = n }
+}
+
+const jobColumns = `id, queue, payload, state, attempts, max_attempts, run_at, locked_until, last_error, created_at, updated_at`
+
+func scanJob(row pgx.Row) (*Job, error) {
+	var j Job
+
+	err := row.Scan(
+		&j.ID,
+		&j.Queue,
+		&j.Payload,
+		&j.State,
+		&j.Attempts,
+		&j.MaxAttempts,
+		&j.RunAt,
+		&j.LockedUntil,
+		&j.LastError,
+		&j.CreatedAt,
+		&j.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	return &j, nil
+}
+
+// Enqueue adds a job with a JSON payload to the named queue
+func (q *Queue) Enqueue(ctx context.Context, queue string, payload any, opts ...EnqueueOption) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal job payload: %w", err)
+	}
+
+	j := Job{
+		ID:          uuid.New(),
+		Queue:       queue,
+		Payload:     data,
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	for _, opt := range opts {
+		opt(&j)
+	}
+
+	row := q.db.QueryRow(ctx, `
+		INSERT INTO jobs (id, queue, payload, state, attempts, max_attempts, run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, 'queued', 0, $4, $5, now(), now())
+		RETURNING `+jobColumns,
+		j.ID, j.Queue, j.Payload, j.MaxAttempts, j.RunAt)
+
+	created, err := scanJob(row)
+	if err != nil {
+		return nil, fmt.Errorf("enqueue job: %w", err)
+	}
+	return created, nil
+}
+
+// Claim locks up to limit runnable jobs for visibility and counts the
+// attempt. Runnable means queued and due, or running with an expired lock
+// (the previous worker died or timed out). A running job whose lock expired
+// on its last attempt moves to dead instead, so a job that keeps killing or
+// outlasting its worker is not retried forever.
+func (q *Queue) Claim(ctx context.Context, queue string, limit int, visibility time.Duration) ([]Job, error) {
+	if _, err := q.db.Exec(ctx, `
+		UPDATE jobs
+		SET state = 'dead',
+		    locked_until = NULL,
+		    last_error = COALESCE(last_error, 'visibility timeout passed on the last attempt'),
+		    updated_at = now()
+		WHERE queue = $1
+		  AND state = 'running'
+		  AND locked_until < now()
+		  AND attempts >= max_attempts
+	`, queue); err != nil {
+		return nil, fmt.Errorf("dead-letter expired jobs: %w", err)
+	}
+
+	rows, err := q.db.Query(ctx, `
+		UPDATE jobs
+		SET state = 'running',
+		    attempts = attempts + 1,
+		    locked_until = now() + $3 * interval '1 millisecond',
+		    updated_at = now()
+		WHERE id IN (
+			SELECT id
+			FROM jobs
+			WHERE queue = $1
+			  AND ((state = 'queued' AND run_at <= now())
+			    OR (state = 'running' AND locked_until < now() AND attempts < max_attempts))
+			ORDER BY run_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns,
+		queue, limit, visibility.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var claimed []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, *j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return claimed, nil
+}
+
+// Complete marks a claimed job done. It returns ErrJobLost when job is no
+// longer running the attempt it was claimed for.
+func (q *Queue) Complete(ctx context.Context, job Job) error {
+	tag, err := q.db.Exec(ctx, `
+		UPDATE jobs
+		SET state = 'done', locked_until = NULL, last_error = NULL, updated_at = now()
+		WHERE id = $1
+		  AND state = 'running'
+		  AND attempts = $2
+	`, job.ID, job.Attempts)
+	if err != nil {
+		return fmt.Errorf("complete job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrJobLost
+	}
+	return nil
+}
+
+// Fail records a failed attempt. The job is retried after retryIn, or moves
+// to dead once it has used all its attempts. It returns the resulting state,
+// or ErrJobLost when job is no longer running the attempt it was claimed
+// for.
+func (q *Queue) Fail(ctx context.Context, job Job, cause error, retryIn time.Duration) (State, error) {
+	next := StateQueued
+	if job.Attempts >= job.MaxAttempts {
+		next = StateDead
+	}
+
+	tag, err := q.db.Exec(ctx, `
+		UPDATE jobs
+		SET state = $2,
+		    run_at = now() + $3 * interval '1 millisecond',
+		    locked_until = NULL,
+		    last_error = $4,
+		    updated_at = now()
+		WHERE id = $1
+		  AND state = 'running'
+		  AND attempts = $5
+	`, job.ID, next, retryIn.Milliseconds(), cause.Error(), job.Attempts)
+	if err != nil {
+		return "", fmt.Errorf("fail job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return "", ErrJobLost
+	}
+	return next, nil
+}
+
+// Retry puts a dead job back on its queue with a fresh attempt budget
+func (q *Queue) Retry(ctx context.Context, id uuid.UUID) (*Job, error) {
+	row := q.db.QueryRow(ctx, `
+		UPDATE jobs
+		SET state = 'queued', attempts = 0, run_at = now(), updated_at = now()
+		WHERE id = $1
+		  AND state = 'dead'
+		RETURNING `+jobColumns, id)
+	return scanJob(row)
+}
+
+func (q *Queue) Get(ctx context.Context, id uuid.UUID) (*Job, error) {
+	row := q.db.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id)
+	return scanJob(row)
+}
+
+// ListDead returns dead jobs on a queue, most recent first
+func (q *Queue) ListDead(ctx context.Context, queue string, limit int) ([]Job, error) {
+	rows, err := q.db.Query(ctx, `
+		SELECT `+jobColumns+`
+		FROM jobs
+		WHERE queue = $1
+		  AND state = 'dead'
+		ORDER BY updated_at DESC
+		LIMIT $2
+	`, queue, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list dead jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
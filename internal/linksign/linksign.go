@@ -0,0 +1,57 @@
+// Package linksign signs expiring links, so a request following one can be
+// trusted without a session or an Authorization header, e.g. a download
+// link opened in a browser.
+package linksign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalid = errors.New("invalid link signature")
+	ErrExpired = errors.New("link has expired")
+)
+
+// Signer signs with HMAC-SHA256 under one secret. Links signed for one
+// purpose must not verify for another, so callers start fields with a
+// purpose name.
+type Signer struct {
+	key []byte
+}
+
+func New(secret string) *Signer {
+	return &Signer{key: []byte(secret)}
+}
+
+// Sign returns the hex signature of fields and expires
+func (s *Signer) Sign(expires time.Time, fields ...string) string {
+	return hex.EncodeToString(s.mac(expires, fields))
+}
+
+// Verify checks signature against fields and expires, and that expires is
+// not past at now
+func (s *Signer) Verify(signature string, expires, now time.Time, fields ...string) error {
+	got, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, s.mac(expires, fields)) {
+		return ErrInvalid
+	}
+	if !now.Before(expires) {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (s *Signer) mac(expires time.Time, fields []string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	for _, f := range fields {
+		// Length-prefix each field so no two field lists sign the same bytes
+		h.Write([]byte(strconv.Itoa(len(f)) + ":" + f))
+	}
+	h.Write([]byte(strconv.FormatInt(expires.Unix(), 10)))
+	return h.Sum(nil)
+}
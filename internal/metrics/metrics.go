@@ -0,0 +1,318 @@
+// Package metrics is a small Prometheus-compatible metrics registry. It only
+// implements what the services need and renders the text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds registered metrics and renders them for scraping
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default is the registry served by Handler
+var Default = NewRegistry()
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, dup := r.collectors[c.name()]; dup {
+		panic("metrics: duplicate metric " + c.name())
+	}
+	r.collectors[c.name()] = c
+}
+
+// Write renders every metric in the text exposition format, sorted by name
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for n := range r.collectors {
+		names = append(names, n)
+	}
+	cs := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, n := range names {
+		cs = append(cs, r.collectors[n])
+	}
+	r.mu.Unlock()
+
+	for _, c := range cs {
+		c.write(w)
+	}
+}
+
+// Handler serves the Default registry
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Default.Write(w)
+	})
+}
+
+// vec stores one float value per label combination
+type vec struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	v := &vec{
+		metricName: name,
+		help:       help,
+		kind:       kind,
+		labels:     labels,
+		values:     make(map[string]float64),
+	}
+	// Unlabelled metrics are exported as 0 before the first update
+	if len(labels) == 0 {
+		v.values[""] = 0
+	}
+	return v
+}
+
+func (v *vec) name() string { return v.metricName }
+
+func (v *vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.metricName, len(v.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (v *vec) add(delta float64, labelValues []string) {
+	k := v.key(labelValues)
+	v.mu.Lock()
+	v.values[k] += delta
+	v.mu.Unlock()
+}
+
+func (v *vec) set(value float64, labelValues []string) {
+	k := v.key(labelValues)
+	v.mu.Lock()
+	v.values[k] = value
+	v.mu.Unlock()
+}
+
+func (v *vec) get(labelValues []string) float64 {
+	k := v.key(labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.values[k]
+}
+
+func (v *vec) write(w io.Writer) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	snapshot := make([]float64, len(keys))
+	for i, k := range keys {
+		snapshot[i] = v.values[k]
+	}
+	v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", v.metricName, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.metricName, v.kind)
+	for i, k := range keys {
+		var values []string
+		if len(v.labels) > 0 {
+			values = strings.Split(k, "\xff")
+		}
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, formatLabels(v.labels, values), formatFloat(snapshot[i]))
+	}
+}
+
+// Counter is a monotonically increasing value, optionally split by labels
+type Counter struct{ v *vec }
+
+// NewCounter registers a counter in the Default registry
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{v: newVec(name, help, "counter", labels)}
+	Default.register(c.v)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) { c.v.add(1, labelValues) }
+
+// Add increases the counter by delta, which must not be negative
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.v.add(delta, labelValues)
+}
+
+// Value returns the current count, mainly for stats endpoints
+func (c *Counter) Value(labelValues ...string) float64 { return c.v.get(labelValues) }
+
+// Gauge is a value that can go up and down, optionally split by labels
+type Gauge struct{ v *vec }
+
+// NewGauge registers a gauge in the Default registry
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{v: newVec(name, help, "gauge", labels)}
+	Default.register(g.v)
+	return g
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) { g.v.set(value, labelValues) }
+func (g *Gauge) Add(delta float64, labelValues ...string) { g.v.add(delta, labelValues) }
+func (g *Gauge) Inc(labelValues ...string)                { g.v.add(1, labelValues) }
+func (g *Gauge) Dec(labelValues ...string)                { g.v.add(-1, labelValues) }
+func (g *Gauge) Value(labelValues ...string) float64      { return g.v.get(labelValues) }
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(n)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Histogram counts observations into cumulative buckets, optionally split
+// by labels
+type Histogram struct {
+	metricName string
+	help       string
+	labels     []string
+	buckets    []float64 // upper bounds, ascending, without +Inf
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers a histogram in the Default registry. buckets are
+// the upper bounds in ascending order; +Inf is added.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: histogram buckets must be sorted: " + name)
+	}
+	h := &Histogram{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		buckets:    buckets,
+		series:     make(map[string]*histogramSeries),
+	}
+	Default.register(h)
+	return h
+}
+
+func (h *Histogram) name() string { return h.metricName }
+
+// Observe records one value
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", h.metricName, len(h.labels), len(labelValues)))
+	}
+	k := strings.Join(labelValues, "\xff")
+	i := sort.SearchFloat64s(h.buckets, value)
+
+	h.mu.Lock()
+	s, ok := h.series[k]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[k] = s
+	}
+	s.counts[i]++
+	s.sum += value
+	s.count++
+	h.mu.Unlock()
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	snapshot := make([]histogramSeries, len(keys))
+	for i, k := range keys {
+		s := h.series[k]
+		snapshot[i] = histogramSeries{counts: append([]uint64(nil), s.counts...), sum: s.sum, count: s.count}
+	}
+	h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.metricName, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.metricName)
+	for i, k := range keys {
+		var values []string
+		if len(h.labels) > 0 {
+			values = strings.Split(k, "\xff")
+		}
+		bucketLabels := append(append([]string(nil), h.labels...), "le")
+		var cumulative uint64
+		for j, c := range snapshot[i].counts {
+			cumulative += c
+			le := "+Inf"
+			if j < len(h.buckets) {
+				le = formatFloat(h.buckets[j])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(bucketLabels, append(append([]string(nil), values...), le)), cumulative)
+		}
+		labels := formatLabels(h.labels, values)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, labels, formatFloat(snapshot[i].sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, labels, snapshot[i].count)
+	}
+}
@@ -0,0 +1,131 @@
+package outbound
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Destination configures mutual TLS for calls to a single host
+type Destination struct {
+	Host     string // host or host:port as it appears in the request URL
+	CertFile string
+	KeyFile  string
+	CAFile   string // optional, system roots are used when empty
+}
+
+// ParseDestinations parses OUTBOUND_MTLS_DESTINATIONS, a semicolon separated list of
+//
+//	host=cert.pem,key.pem[,ca.pem]
+func ParseDestinations(raw string) ([]Destination, error) {
+	var dests []Destination
+
+	for _, entry := range strings.Split(raw, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		host, files, ok := strings.Cut(entry, "=")
+		if !ok || host == "" {
+			return nil, fmt.Errorf("invalid mTLS destination %q: expected host=cert,key[,ca]", entry)
+		}
+
+		paths := strings.Split(files, ",")
+		if len(paths) < 2 || len(paths) > 3 {
+			return nil, fmt.Errorf("invalid mTLS destination %q: expected host=cert,key[,ca]", entry)
+		}
+
+		d := Destination{
+			Host:     strings.ToLower(strings.TrimSpace(host)),
+			CertFile: strings.TrimSpace(paths[0]),
+			KeyFile:  strings.TrimSpace(paths[1]),
+		}
+		if len(paths) == 3 {
+			d.CAFile = strings.TrimSpace(paths[2])
+		}
+		dests = append(dests, d)
+	}
+
+	return dests, nil
+}
+
+// ClientPool hands out an HTTP client per destination. Hosts with an mTLS
+// destination get a client presenting that certificate, everything else
+// shares a plain client.
+type ClientPool struct {
+	fallback *http.Client
+	byHost   map[string]*http.Client
+}
+
+func NewClientPool(dests []Destination, timeout time.Duration) (*ClientPool, error) {
+	pool := &ClientPool{
+		fallback: &http.Client{Timeout: timeout},
+		byHost:   make(map[string]*http.Client),
+	}
+
+	for _, d := range dests {
+		tlsCfg, err := loadTLSConfig(d)
+		if err != nil {
+			return nil, fmt.Errorf("mTLS destination %s: %w", d.Host, err)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsCfg
+
+		pool.byHost[d.Host] = &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+		}
+	}
+
+	return pool, nil
+}
+
+// For returns the client to use for a request to rawURL
+func (p *ClientPool) For(rawURL string) *http.Client {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return p.fallback
+	}
+
+	host := strings.ToLower(u.Host)
+	if c, ok := p.byHost[host]; ok {
+		return c
+	}
+	if c, ok := p.byHost[strings.ToLower(u.Hostname())]; ok {
+		return c
+	}
+	return p.fallback
+}
+
+func loadTLSConfig(d Destination) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(d.CertFile, d.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load client certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if d.CAFile != "" {
+		pem, err := os.ReadFile(d.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", d.CAFile)
+		}
+		cfg.RootCAs = roots
+	}
+
+	return cfg, nil
+}
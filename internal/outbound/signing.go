@@ -0,0 +1,95 @@
+package outbound
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the timestamped HMAC signatures on outgoing requests
+const SignatureHeader = "X-Signature"
+
+var (
+	ErrMissingSignature = errors.New("signature header missing or malformed")
+	ErrSignatureExpired = errors.New("signature timestamp outside tolerance")
+	ErrSignatureInvalid = errors.New("no signature matched")
+)
+
+// SigningKey is one HMAC secret. During rotation the previous key stays in the
+// set until ExpiresAt so receivers can switch over without dropping requests.
+type SigningKey struct {
+	Secret    string
+	ExpiresAt *time.Time
+}
+
+// Sign produces a header value of the form
+//
+//	t=<unix seconds>,v1=<hex hmac>[,v1=<hex hmac>...]
+//
+// with one v1 entry per unexpired key. The signed message is "<t>.<body>".
+func Sign(keys []SigningKey, now time.Time, body []byte) string {
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	parts := []string{"t=" + ts}
+	for _, k := range keys {
+		if k.Secret == "" || (k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)) {
+			continue
+		}
+		parts = append(parts, "v1="+computeMAC(k.Secret, ts, body))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// Verify checks a header produced by Sign against a single secret. It is what
+// receivers are expected to implement and is used by our own integration checks.
+func Verify(header, secret string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts string
+	var sigs []string
+
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+
+	if ts == "" || len(sigs) == 0 {
+		return ErrMissingSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp", ErrMissingSignature)
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	expected := computeMAC(secret, ts, body)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrSignatureInvalid
+}
+
+func computeMAC(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,184 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/outbound"
+)
+
+const (
+	apnsEndpoint        = "https://api.push.apple.com"
+	apnsSandboxEndpoint = "https://api.sandbox.push.apple.com"
+	// apnsTokenRefresh is how long a provider token is reused. Apple
+	// rejects tokens older than an hour and throttles fresh ones issued
+	// more often than every 20 minutes.
+	apnsTokenRefresh = 40 * time.Minute
+)
+
+// APNs sends through the Apple Push Notification service, authenticating
+// with a signed provider token. APNs only speaks HTTP/2, which the outbound
+// clients negotiate over TLS.
+type APNs struct {
+	keyID    string
+	teamID   string
+	topic    string
+	key      *ecdsa.PrivateKey
+	endpoint string
+	clients  *outbound.ClientPool
+
+	mu       sync.Mutex
+	jwt      string
+	issuedAt time.Time
+}
+
+// APNsOptions configures an APNs provider
+type APNsOptions struct {
+	KeyFile string // auth key, .p8, as downloaded from the Apple developer account
+	KeyID   string
+	TeamID  string
+	Topic   string // bundle ID of the app
+	Sandbox bool   // send to the development environment
+}
+
+func NewAPNs(opts APNsOptions, clients *outbound.ClientPool) (*APNs, error) {
+	if opts.KeyID == "" || opts.TeamID == "" || opts.Topic == "" {
+		return nil, errors.New("key ID, team ID and topic are required")
+	}
+
+	data, err := os.ReadFile(opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, errors.New("auth key is not a P-256 key")
+	}
+
+	endpoint := apnsEndpoint
+	if opts.Sandbox {
+		endpoint = apnsSandboxEndpoint
+	}
+	return &APNs{
+		keyID:    opts.KeyID,
+		teamID:   opts.TeamID,
+		topic:    opts.Topic,
+		key:      ecKey,
+		endpoint: endpoint,
+		clients:  clients,
+	}, nil
+}
+
+func (a *APNs) Send(ctx context.Context, token string, msg Message) error {
+	jwt, err := a.providerToken()
+	if err != nil {
+		return fmt.Errorf("apns provider token: %w", err)
+	}
+
+	// Custom data sits next to aps at the top level of the payload
+	payload := map[string]any{
+		"aps": map[string]any{
+			"alert": map[string]string{"title": msg.Title, "body": msg.Body},
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		if k != "aps" {
+			payload[k] = v
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	sendURL := a.endpoint + "/3/device/" + token
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+jwt)
+	req.Header.Set("apns-topic", a.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+
+	resp, err := a.clients.For(sendURL).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	errBody := readError(resp)
+	var e struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.Unmarshal(errBody, &e)
+
+	// BadDeviceToken and DeviceTokenNotForTopic are left to retry and die:
+	// a wrong APNS_SANDBOX or APNS_TOPIC gives them for every token
+	switch {
+	case resp.StatusCode == http.StatusGone || e.Reason == "Unregistered":
+		return ErrUnregistered
+	case e.Reason == "ExpiredProviderToken":
+		a.resetToken()
+	}
+	return responseError(resp.StatusCode, errBody)
+}
+
+// providerToken returns the current provider token, signing a new one when
+// it is due
+func (a *APNs) providerToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if a.jwt != "" && now.Sub(a.issuedAt) < apnsTokenRefresh {
+		return a.jwt, nil
+	}
+
+	jwt, err := signJWT(
+		map[string]any{"alg": "ES256", "kid": a.keyID},
+		map[string]any{"iss": a.teamID, "iat": now.Unix()},
+		func(digest []byte) ([]byte, error) {
+			r, s, err := ecdsa.Sign(rand.Reader, a.key, digest)
+			if err != nil {
+				return nil, err
+			}
+			// JWS wants r and s as fixed 32-byte halves, not ASN.1
+			sig := make([]byte, 64)
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+			return sig, nil
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	a.jwt, a.issuedAt = jwt, now
+	return a.jwt, nil
+}
+
+func (a *APNs) resetToken() {
+	a.mu.Lock()
+	a.jwt = ""
+	a.mu.Unlock()
+}
@@ -0,0 +1,122 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/jobs"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+// Queue is the jobs queue notifications are enqueued on
+const Queue = "push_delivery"
+
+// Devices is what the dispatcher needs of the appointment repository
+type Devices interface {
+	ListAppointmentDevices(ctx context.Context, appointmentID uuid.UUID) ([]appointment.Device, error)
+	DeleteDeviceByToken(ctx context.Context, token string) error
+}
+
+// deliveryJob is the payload of one queued notification to one device
+type deliveryJob struct {
+	Provider appointment.PushProvider `json:"provider"`
+	Token    string                   `json:"token"`
+	Message  Message                  `json:"message"`
+	// Tenant and Shard route the removal of a stale token to the shard
+	// holding the device
+	Tenant string `json:"tenant,omitempty"`
+	Shard  string `json:"shard,omitempty"`
+}
+
+// Dispatcher notifies the patient of an appointment on each of their
+// devices through the job queue, so a slow or failing provider never
+// blocks a booking and failed sends are retried.
+type Dispatcher struct {
+	devices   Devices
+	queue     *jobs.Queue
+	providers map[appointment.PushProvider]Provider
+}
+
+func NewDispatcher(devices Devices, queue *jobs.Queue, providers map[appointment.PushProvider]Provider) *Dispatcher {
+	return &Dispatcher{
+		devices:   devices,
+		queue:     queue,
+		providers: providers,
+	}
+}
+
+// Publish enqueues one notification per device of the appointment's
+// patient whose provider is configured. It satisfies
+// appointment.EventPublisher.
+func (d *Dispatcher) Publish(ctx context.Context, eventType string, appointmentID uuid.UUID, data map[string]any) error {
+	msg, ok := messageFor(eventType, appointmentID, data)
+	if !ok {
+		return nil
+	}
+
+	devices, err := d.devices.ListAppointmentDevices(ctx, appointmentID)
+	if err != nil {
+		return fmt.Errorf("list devices: %w", err)
+	}
+
+	var shardName string
+	if name := shard.Name(ctx); name != shard.Default {
+		shardName = name
+	}
+	for _, dev := range devices {
+		if _, ok := d.providers[dev.Provider]; !ok {
+			continue
+		}
+
+		_, err := d.queue.Enqueue(ctx, Queue, deliveryJob{
+			Provider: dev.Provider,
+			Token:    dev.Token,
+			Message:  msg,
+			Tenant:   shard.Tenant(ctx),
+			Shard:    shardName,
+		})
+		if err != nil {
+			return fmt.Errorf("enqueue push notification: %w", err)
+		}
+	}
+	return nil
+}
+
+// Handle sends one queued notification. A failed send returns an error so
+// the queue retries it; a token the provider no longer knows is removed and
+// completes the job.
+func (d *Dispatcher) Handle(ctx context.Context, job jobs.Job) error {
+	var dj deliveryJob
+	if err := job.Decode(&dj); err != nil {
+		return fmt.Errorf("decode push notification: %w", err)
+	}
+
+	provider, ok := d.providers[dj.Provider]
+	if !ok {
+		// Configured when it was queued but not on this worker; retrying
+		// will not help
+		log.Printf("push provider %s is not configured, dropping notification", dj.Provider)
+		return nil
+	}
+
+	err := provider.Send(ctx, dj.Token, dj.Message)
+	if !errors.Is(err, ErrUnregistered) {
+		return err
+	}
+
+	if dj.Tenant != "" {
+		ctx = shard.WithTenant(ctx, dj.Tenant)
+	}
+	if dj.Shard != "" {
+		ctx = shard.WithShard(ctx, dj.Shard)
+	}
+	if err := d.devices.DeleteDeviceByToken(ctx, dj.Token); err != nil {
+		return fmt.Errorf("delete unregistered device: %w", err)
+	}
+	return nil
+}
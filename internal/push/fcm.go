@@ -0,0 +1,244 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/outbound"
+)
+
+const (
+	fcmEndpoint = "https://fcm.googleapis.com"
+	fcmScope    = "https://www.googleapis.com/auth/firebase.messaging"
+	// googleTokenURI is used when the service account key names none
+	googleTokenURI = "https://oauth2.googleapis.com/token"
+)
+
+// FCM sends through the Firebase Cloud Messaging HTTP v1 API, authorised
+// as a service account. Access tokens are fetched with a signed assertion
+// and reused until shortly before they expire.
+type FCM struct {
+	projectID   string
+	clientEmail string
+	keyID       string
+	key         *rsa.PrivateKey
+	tokenURI    string
+	endpoint    string
+	clients     *outbound.ClientPool
+
+	mu          sync.Mutex
+	accessToken string
+	expires     time.Time
+}
+
+// serviceAccount is the part of a Google service account key FCM needs
+type serviceAccount struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// NewFCM loads the service account key at path, as downloaded from the
+// Firebase console
+func NewFCM(path string, clients *outbound.ClientPool) (*FCM, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sa serviceAccount
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("parse service account key: %w", err)
+	}
+	if sa.Type != "service_account" || sa.ProjectID == "" || sa.ClientEmail == "" {
+		return nil, errors.New("not a service account key")
+	}
+
+	key, err := parsePrivateKey([]byte(sa.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("service account key is not an RSA key")
+	}
+
+	tokenURI := sa.TokenURI
+	if tokenURI == "" {
+		tokenURI = googleTokenURI
+	}
+	return &FCM{
+		projectID:   sa.ProjectID,
+		clientEmail: sa.ClientEmail,
+		keyID:       sa.PrivateKeyID,
+		key:         rsaKey,
+		tokenURI:    tokenURI,
+		endpoint:    fcmEndpoint,
+		clients:     clients,
+	}, nil
+}
+
+// fcmMessage is the body of a messages:send request
+type fcmMessage struct {
+	Message struct {
+		Token        string            `json:"token"`
+		Notification fcmNotification   `json:"notification"`
+		Data         map[string]string `json:"data,omitempty"`
+	} `json:"message"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// fcmError is the error body of the v1 API
+type fcmError struct {
+	Error struct {
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (f *FCM) Send(ctx context.Context, token string, msg Message) error {
+	accessToken, err := f.token(ctx)
+	if err != nil {
+		return fmt.Errorf("fcm access token: %w", err)
+	}
+
+	var m fcmMessage
+	m.Message.Token = token
+	m.Message.Notification = fcmNotification{Title: msg.Title, Body: msg.Body}
+	m.Message.Data = msg.Data
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	sendURL := f.endpoint + "/v1/projects/" + url.PathEscape(f.projectID) + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := f.clients.For(sendURL).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	errBody := readError(resp)
+	if resp.StatusCode == http.StatusUnauthorized {
+		// Revoked or rotated; the retry fetches a new one
+		f.resetToken()
+	}
+	// Not a bare 404: a wrong project is one too, and must not cost every
+	// patient their devices
+	if fcmUnregistered(errBody) {
+		return ErrUnregistered
+	}
+	return responseError(resp.StatusCode, errBody)
+}
+
+// fcmUnregistered reports whether an error body says the token is gone
+func fcmUnregistered(body []byte) bool {
+	var e fcmError
+	if json.Unmarshal(body, &e) != nil {
+		return false
+	}
+	for _, d := range e.Error.Details {
+		if d.ErrorCode == "UNREGISTERED" {
+			return true
+		}
+	}
+	return false
+}
+
+// token returns an access token valid for at least another minute,
+// fetching a new one when needed
+func (f *FCM) token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if f.accessToken != "" && now.Add(time.Minute).Before(f.expires) {
+		return f.accessToken, nil
+	}
+
+	assertion, err := signJWT(
+		map[string]any{"alg": "RS256", "typ": "JWT", "kid": f.keyID},
+		map[string]any{
+			"iss":   f.clientEmail,
+			"scope": fcmScope,
+			"aud":   f.tokenURI,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		},
+		func(digest []byte) ([]byte, error) {
+			return rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest)
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.clients.For(f.tokenURI).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError(resp.StatusCode, readError(resp))
+	}
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("decode token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", errors.New("token response has no access token")
+	}
+
+	f.accessToken = tok.AccessToken
+	f.expires = now.Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return f.accessToken, nil
+}
+
+func (f *FCM) resetToken() {
+	f.mu.Lock()
+	f.accessToken = ""
+	f.mu.Unlock()
+}
@@ -0,0 +1,72 @@
+package push
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// signJWT builds a compact JWT, handing sign the SHA-256 digest of the
+// signing input
+func signJWT(header, claims map[string]any, sign func(digest []byte) ([]byte, error)) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	input := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := sign(digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign token: %w", err)
+	}
+	return input + "." + enc.EncodeToString(sig), nil
+}
+
+// parsePrivateKey reads the PKCS #8 private key both providers issue their
+// keys in, PEM encoded
+func parsePrivateKey(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	return key, nil
+}
+
+// maxErrorBody bounds how much of a provider's error response is read
+const maxErrorBody = 64 << 10
+
+// readError reads the body of a failed provider response
+func readError(resp *http.Response) []byte {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return body
+}
+
+// responseError describes a failed provider response, with the start of
+// its body
+func responseError(status int, body []byte) error {
+	msg := strings.TrimSpace(string(body))
+	if len(msg) > 512 {
+		msg = msg[:512]
+	}
+	if msg == "" {
+		return fmt.Errorf("provider responded with status %d", status)
+	}
+	return fmt.Errorf("provider responded with status %d: %s", status, msg)
+}
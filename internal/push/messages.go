@@ -0,0 +1,52 @@
+package push
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// messageFor builds the notification for an event, or reports false when
+// the patient is not notified of it; holds being taken or running out only
+// matter to the app while it is open. The text is kept free of the
+// appointment's details, which can show on a locked screen; the app loads
+// them by the appointment_id in Data.
+func messageFor(eventType string, appointmentID uuid.UUID, data map[string]any) (Message, bool) {
+	var title, body string
+	switch eventType {
+	case appointment.EventAppointmentConfirmed:
+		title, body = "Appointment confirmed", "Your appointment is confirmed."
+	case appointment.EventAppointmentCancelled:
+		// A rescheduled occurrence is confirmed again under its new
+		// appointment, which is what the patient hears about
+		if data["reason"] == "rescheduled" {
+			return Message{}, false
+		}
+		title, body = "Appointment cancelled", "Your appointment has been cancelled."
+	case appointment.EventAppointmentRejected:
+		title, body = "Booking not accepted", "The clinic could not accept your booking."
+	case appointment.EventAppointmentClinicianChanged:
+		title, body = "Clinician changed", "Your appointment is now with a different clinician."
+	case appointment.EventAppointmentRescheduled:
+		// Logged on both appointments; the patient hears of the new one
+		if _, ok := data["rescheduled_from"]; !ok {
+			return Message{}, false
+		}
+		title, body = "Appointment moved", "Your appointment has been moved to a new time."
+	case appointment.EventIntakeReminder:
+		title, body = "Intake form", "Please complete your intake form before your appointment."
+	case appointment.EventFeedbackRequested:
+		title, body = "How did it go?", "Tell us about your appointment."
+	default:
+		return Message{}, false
+	}
+
+	return Message{
+		Title: title,
+		Body:  body,
+		Data: map[string]string{
+			"event_type":     eventType,
+			"appointment_id": appointmentID.String(),
+		},
+	}, true
+}
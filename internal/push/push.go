@@ -0,0 +1,63 @@
+// Package push sends appointment notifications to patients' mobile devices
+// through Firebase Cloud Messaging and the Apple Push Notification service.
+// Like webhooks, notifications are queued when an event is logged and sent
+// by the expiry-worker, so a slow provider never holds up a booking.
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/outbound"
+)
+
+// ErrUnregistered is returned by a Provider for a token it no longer
+// delivers to, because the app was uninstalled or the token was rotated
+var ErrUnregistered = errors.New("device token is no longer registered")
+
+// Message is one notification. Data is handed to the app alongside the
+// alert; providers only carry strings there.
+type Message struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// Provider sends a message to one device token
+type Provider interface {
+	Send(ctx context.Context, token string, msg Message) error
+}
+
+// Open builds the providers cfg enables, keyed by the provider their
+// device tokens name. A provider is on when its credentials are set; the
+// map is empty when none is.
+func Open(cfg config.Config, clients *outbound.ClientPool) (map[appointment.PushProvider]Provider, error) {
+	providers := make(map[appointment.PushProvider]Provider)
+
+	if cfg.FCMCredentialsFile != "" {
+		fcm, err := NewFCM(cfg.FCMCredentialsFile, clients)
+		if err != nil {
+			return nil, fmt.Errorf("fcm: %w", err)
+		}
+		providers[appointment.PushFCM] = fcm
+	}
+
+	if cfg.APNsKeyFile != "" {
+		apns, err := NewAPNs(APNsOptions{
+			KeyFile: cfg.APNsKeyFile,
+			KeyID:   cfg.APNsKeyID,
+			TeamID:  cfg.APNsTeamID,
+			Topic:   cfg.APNsTopic,
+			Sandbox: cfg.APNsSandbox,
+		}, clients)
+		if err != nil {
+			return nil, fmt.Errorf("apns: %w", err)
+		}
+		providers[appointment.PushAPNs] = apns
+	}
+
+	return providers, nil
+}
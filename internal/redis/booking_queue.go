@@ -0,0 +1,186 @@
+package redisclient
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// BookingQueue admits booking attempts per scope, e.g. a clinic, at a steady
+// rate and queues the rest in arrival order. Each attempt takes a ticket;
+// the queue moves on by rate tickets a second, and up to burst are admitted
+// at once while nobody is waiting.
+type BookingQueue interface {
+	// Admit takes a ticket for scope, or looks up ticket when one is given,
+	// and reports whether its turn has come. A ticket that is unknown,
+	// e.g. because it was abandoned for too long, is replaced by a new one
+	// at the back of the queue. An admitted ticket is used up.
+	Admit(ctx context.Context, scope, ticket string, rate float64, burst int) (Admission, error)
+}
+
+// Admission is the outcome of BookingQueue.Admit. A queued attempt is
+// retried with Ticket after about Wait.
+type Admission struct {
+	Admitted bool
+	Ticket   string
+	Position int // 1 for the next ticket admitted, 0 when admitted
+	Wait     time.Duration
+}
+
+// bookingQueueTTL is how long a queue is kept after its last attempt, and
+// how long a ticket the queue has passed stays valid
+const bookingQueueTTL = 10 * time.Minute
+
+// The queue is a hash of the next ticket number, how many tickets it
+// admits, fractional as it grows with time, and when that was last moved,
+// plus a sorted set of the tickets waiting or admitted but not yet used,
+// scored by number. ARGV: ticket or "", new ticket, rate/s, burst, ttl ms.
+// Returns admitted, the ticket and its position.
+var admitScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local rate = tonumber(ARGV[3])
+local burst = tonumber(ARGV[4])
+local ttl = tonumber(ARGV[5])
+local state = redis.call("HMGET", KEYS[1], "next", "serving", "at")
+local nxt = tonumber(state[1]) or 0
+local serving = tonumber(state[2]) or burst
+local at = tonumber(state[3]) or now
+serving = math.min(serving + (now - at) * rate / 1000, nxt + burst)
+
+local ticket = ARGV[1]
+local n = false
+if ticket ~= "" then
+  n = redis.call("ZSCORE", KEYS[2], ticket)
+end
+if n then
+  n = tonumber(n)
+else
+  ticket = ARGV[2]
+  n = nxt
+  nxt = nxt + 1
+  redis.call("ZADD", KEYS[2], n, ticket)
+end
+local admitted = n + 1 <= serving
+if admitted then
+  redis.call("ZREM", KEYS[2], ticket)
+end
+-- Tickets passed more than a TTL ago were abandoned
+redis.call("ZREMRANGEBYSCORE", KEYS[2], "-inf", "(" .. math.floor(serving - rate * ttl / 1000))
+
+redis.call("HSET", KEYS[1], "next", nxt, "serving", serving, "at", now)
+redis.call("PEXPIRE", KEYS[1], ttl)
+redis.call("PEXPIRE", KEYS[2], ttl)
+if admitted then
+  return {1, ticket, 0}
+end
+return {0, ticket, math.ceil(n + 1 - serving)}
+`)
+
+type redisBookingQueue struct {
+	client *redis.Client
+}
+
+// NewRedisBookingQueue queues in Redis, so every instance shares one queue
+// per scope. Use the RoleRateLimit client.
+func NewRedisBookingQueue(client *redis.Client) BookingQueue {
+	return &redisBookingQueue{client: client}
+}
+
+func (q *redisBookingQueue) Admit(ctx context.Context, scope, ticket string, rate float64, burst int) (Admission, error) {
+	key := "bookingqueue:" + scope
+	res, err := admitScript.Run(ctx, q.client, []string{key, key + ":tickets"},
+		ticket, uuid.NewString(), rate, burst, bookingQueueTTL.Milliseconds()).Slice()
+	if err != nil {
+		return Admission{}, fmt.Errorf("admit booking: %w", err)
+	}
+	if len(res) != 3 {
+		return Admission{}, fmt.Errorf("admit booking: unexpected reply %v", res)
+	}
+	admitted, _ := res[0].(int64)
+	ticket, _ = res[1].(string)
+	position, _ := res[2].(int64)
+	return newAdmission(admitted == 1, ticket, int(position), rate), nil
+}
+
+func newAdmission(admitted bool, ticket string, position int, rate float64) Admission {
+	if admitted {
+		return Admission{Admitted: true, Ticket: ticket}
+	}
+	wait := time.Duration(float64(position) / rate * float64(time.Second))
+	return Admission{Ticket: ticket, Position: position, Wait: wait}
+}
+
+// memoryBookingQueue is a process-local BookingQueue for single-instance
+// deployments such as the demo mode
+type memoryBookingQueue struct {
+	mu        sync.Mutex
+	queues    map[string]*memoryQueue
+	nextSweep time.Time
+}
+
+type memoryQueue struct {
+	next    int
+	serving float64
+	rate    float64
+	at      time.Time
+	tickets map[string]int
+}
+
+// NewInMemoryBookingQueue creates a booking queue kept in process memory
+func NewInMemoryBookingQueue() BookingQueue {
+	return &memoryBookingQueue{queues: make(map[string]*memoryQueue)}
+}
+
+func (q *memoryBookingQueue) Admit(_ context.Context, scope, ticket string, rate float64, burst int) (Admission, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	if !now.Before(q.nextSweep) {
+		q.sweep(now)
+		q.nextSweep = now.Add(time.Minute)
+	}
+	mq, ok := q.queues[scope]
+	if !ok {
+		mq = &memoryQueue{serving: float64(burst), at: now, tickets: make(map[string]int)}
+		q.queues[scope] = mq
+	}
+	mq.serving = math.Min(mq.serving+now.Sub(mq.at).Seconds()*rate, float64(mq.next+burst))
+	mq.rate, mq.at = rate, now
+
+	n, ok := mq.tickets[ticket]
+	if !ok {
+		ticket = uuid.NewString()
+		n = mq.next
+		mq.next++
+		mq.tickets[ticket] = n
+	}
+	admitted := float64(n+1) <= mq.serving
+	if admitted {
+		delete(mq.tickets, ticket)
+	}
+	return newAdmission(admitted, ticket, int(math.Ceil(float64(n+1)-mq.serving)), rate), nil
+}
+
+// sweep drops idle queues, and the tickets of the others passed more than a
+// TTL ago, as the keys and ZREMRANGEBYSCORE do in Redis
+func (q *memoryBookingQueue) sweep(now time.Time) {
+	for scope, mq := range q.queues {
+		if now.Sub(mq.at) >= bookingQueueTTL {
+			delete(q.queues, scope)
+			continue
+		}
+		abandoned := math.Floor(mq.serving - mq.rate*bookingQueueTTL.Seconds())
+		for t, n := range mq.tickets {
+			if float64(n) < abandoned {
+				delete(mq.tickets, t)
+			}
+		}
+	}
+}
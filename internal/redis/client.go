@@ -2,31 +2,224 @@ package redisclient
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
 )
 
-func NewRedisClient(addr, username, password string) (*redis.Client, error) {
-	rdb := redis.NewClient(&redis.Options{
-		Addr:         addr,
-		Username:     username,
-		Password:     password,
-		DB:           0,
-		ReadTimeout:  2 * time.Second,
-		WriteTimeout: 2 * time.Second,
-		PoolSize:     10,
-		MinIdleConns: 1,
-	})
+// Role names a logical Redis client. Each role gets its own connection pool,
+// so a burst of one kind of traffic cannot take the connections another
+// needs, e.g. heartbeats starving lock acquisition.
+type Role string
+
+const (
+	RoleLocking   Role = "locking"   // slot and resource locks, lock admin and contention stats
+	RoleRegistry  Role = "registry"  // instance heartbeats and cluster membership
+	RoleCache     Role = "cache"     // cached reads
+	RoleRateLimit Role = "ratelimit" // rate limiting counters
+)
+
+// defaultPoolSizes are the pool sizes of roles not set in Options.PoolSizes.
+// Each fair-lock waiter holds a locking connection while it blocks.
+var defaultPoolSizes = map[Role]int{
+	RoleLocking:   10,
+	RoleRegistry:  2,
+	RoleCache:     10,
+	RoleRateLimit: 5,
+}
+
+// MonitorInterval is how often Clients.Monitor pings each client
+const MonitorInterval = 5 * time.Second
+
+var (
+	clientUp = metrics.NewGauge(
+		"redis_client_up",
+		"1 when the last ping of a Redis client succeeded, by client role.",
+		"client",
+	)
+	poolConns = metrics.NewGauge(
+		"redis_pool_connections",
+		"Redis pool connections by client role and state (total, idle, stale).",
+		"client", "state",
+	)
+	poolTimeouts = metrics.NewCounter(
+		"redis_pool_timeouts_total",
+		"Commands that gave up waiting for a free pooled connection, by client role.",
+		"client",
+	)
+)
+
+// Options are the connection settings shared by every client and the pool
+// size of each role, keyed by role name
+type Options struct {
+	Addr      string
+	Username  string
+	Password  string
+	PoolSizes map[string]int
+}
+
+// Clients holds one client per role, all to the same server
+type Clients struct {
+	clients map[Role]*redis.Client
+
+	// Only touched by Monitor
+	up       map[Role]bool
+	timeouts map[Role]uint32
+
+	closeOnce sync.Once
+	closeErr  error
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Connect opens a client for each of roles and pings them, retrying with
+// backoff until ctx is done, so a Redis that is still starting does not fail
+// the process
+func Connect(ctx context.Context, opts Options, roles ...Role) (*Clients, error) {
+	for name := range opts.PoolSizes {
+		if _, ok := defaultPoolSizes[Role(name)]; !ok {
+			return nil, fmt.Errorf("unknown redis client role %q", name)
+		}
+	}
 
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		_ = rdb.Close()
-		return nil, fmt.Errorf("ping redis: %w", err)
+	c := &Clients{
+		clients:  make(map[Role]*redis.Client, len(roles)),
+		up:       make(map[Role]bool, len(roles)),
+		timeouts: make(map[Role]uint32, len(roles)),
+	}
+	for _, role := range roles {
+		size, ok := opts.PoolSizes[string(role)]
+		if !ok {
+			size = defaultPoolSizes[role]
+		}
+		client := redis.NewClient(&redis.Options{
+			Addr:         opts.Addr,
+			Username:     opts.Username,
+			Password:     opts.Password,
+			DB:           0,
+			ReadTimeout:  2 * time.Second,
+			WriteTimeout: 2 * time.Second,
+			PoolSize:     size,
+			MinIdleConns: 1,
+		})
+		client.AddHook(requestHook{role: role})
+		c.clients[role] = client
 	}
 
-	return rdb, nil
+	backoff := 100 * time.Millisecond
+	for {
+		err := c.ping(ctx)
+		if err == nil {
+			return c, nil
+		}
+		select {
+		case <-ctx.Done():
+			_ = c.Close()
+			return nil, fmt.Errorf("ping redis: %w", err)
+		case <-time.After(backoff):
+		}
+		log.Printf("redis not reachable yet, retrying: %v", err)
+		backoff = min(2*backoff, 2*time.Second)
+	}
+}
+
+func (c *Clients) ping(ctx context.Context) error {
+	for _, role := range c.roles() {
+		if err := c.clients[role].Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("%s client: %w", role, err)
+		}
+		c.up[role] = true
+		clientUp.Set(1, string(role))
+	}
+	return nil
+}
+
+// Client returns the client of role. Asking for a role that was not passed
+// to Connect is a programming error and panics.
+func (c *Clients) Client(role Role) *redis.Client {
+	client, ok := c.clients[role]
+	if !ok {
+		panic(fmt.Sprintf("redisclient: no %s client connected", role))
+	}
+	return client
+}
+
+// Monitor pings every client each interval until ctx is done, exporting
+// whether it is up and its pool statistics and logging when it goes down or
+// comes back. The clients reconnect on their own: a broken pooled
+// connection is dropped and the next command dials a new one.
+func (c *Clients) Monitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.check(ctx, interval)
+		}
+	}
+}
+
+func (c *Clients) check(ctx context.Context, timeout time.Duration) {
+	for _, role := range c.roles() {
+		client := c.clients[role]
+
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := client.Ping(pingCtx).Err()
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case err != nil && c.up[role]:
+			log.Printf("redis %s client down: %v", role, err)
+		case err == nil && !c.up[role]:
+			log.Printf("redis %s client reconnected", role)
+		}
+		c.up[role] = err == nil
+		if err == nil {
+			clientUp.Set(1, string(role))
+		} else {
+			clientUp.Set(0, string(role))
+		}
+
+		st := client.PoolStats()
+		poolConns.Set(float64(st.TotalConns), string(role), "total")
+		poolConns.Set(float64(st.IdleConns), string(role), "idle")
+		poolConns.Set(float64(st.StaleConns), string(role), "stale")
+		if st.Timeouts > c.timeouts[role] {
+			poolTimeouts.Add(float64(st.Timeouts-c.timeouts[role]), string(role))
+		}
+		c.timeouts[role] = st.Timeouts
+	}
+}
+
+func (c *Clients) roles() []Role {
+	roles := make([]Role, 0, len(c.clients))
+	for role := range c.clients {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+	return roles
+}
+
+// Close closes every client. Later calls return the first call's result.
+func (c *Clients) Close() error {
+	c.closeOnce.Do(func() {
+		var errs []error
+		for _, role := range c.roles() {
+			if err := c.clients[role].Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close %s client: %w", role, err))
+			}
+		}
+		c.closeErr = errors.Join(errs...)
+	})
+	return c.closeErr
 }
@@ -0,0 +1,213 @@
+package redisclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fairPollInterval bounds each BLPOP so a waiter notices a holder that died
+// without releasing and promotes the next waiter itself
+const fairPollInterval = 100 * time.Millisecond
+
+// A fair lock keeps the same lock key as the plain locker plus, per slot:
+//
+//	lock:slot:<id>:queue        list of "<token>|<deadline ms>", oldest first
+//	lock:slot:<id>:grant:<tok>  list the releaser pushes to when tok is next
+//
+// Release hands the lock key straight to the oldest live waiter, so a newly
+// arriving request cannot overtake anyone already queued. Entries whose
+// deadline has passed are skipped, which keeps a waiter that gave up or died
+// from being handed the lock.
+//
+// promote is shared by the scripts below. It expects KEYS[1] lock, KEYS[2]
+// queue, ARGV[2] ttl ms and ARGV[3] grant key prefix, and returns the token
+// it granted or false.
+const fairPromoteLua = `
+local function now_ms()
+  local t = redis.call("TIME")
+  return tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+end
+
+local function promote()
+  local now = now_ms()
+  while true do
+    local entry = redis.call("LPOP", KEYS[2])
+    if not entry then
+      return false
+    end
+    local sep = string.find(entry, "|", 1, true)
+    local token = string.sub(entry, 1, sep - 1)
+    local deadline = tonumber(string.sub(entry, sep + 1))
+    if deadline > now then
+      redis.call("SET", KEYS[1], token, "PX", ARGV[2])
+      local grant = ARGV[3] .. token
+      redis.call("RPUSH", grant, "1")
+      redis.call("PEXPIRE", grant, ARGV[2])
+      return token
+    end
+  end
+end
+`
+
+// fairAcquireScript takes the lock when it is free and nobody is queued,
+// otherwise queues the caller. ARGV[1] token, ARGV[4] wait ms. Returns
+// {1} when acquired or {0, entry}.
+var fairAcquireScript = redis.NewScript(fairPromoteLua + `
+if redis.call("EXISTS", KEYS[1]) == 0 and not promote() then
+  redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+  return {1}
+end
+local entry = ARGV[1] .. "|" .. tostring(now_ms() + tonumber(ARGV[4]))
+redis.call("RPUSH", KEYS[2], entry)
+redis.call("PEXPIRE", KEYS[2], tonumber(ARGV[2]) + tonumber(ARGV[4]))
+return {0, entry}
+`)
+
+// fairPokeScript promotes the next waiter if the holder's key has expired
+var fairPokeScript = redis.NewScript(fairPromoteLua + `
+if redis.call("EXISTS", KEYS[1]) == 0 then
+  promote()
+end
+return 0
+`)
+
+// fairReleaseScript frees the lock held with ARGV[1] and hands it on
+var fairReleaseScript = redis.NewScript(fairPromoteLua + `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+  return 0
+end
+redis.call("DEL", KEYS[1])
+promote()
+return 1
+`)
+
+// fairWithdrawScript removes a waiter that ran out of time. ARGV[1] token,
+// ARGV[4] queue entry. Returns 1 when the lock was granted in the meantime,
+// in which case the caller keeps it.
+var fairWithdrawScript = redis.NewScript(fairPromoteLua + `
+redis.call("LREM", KEYS[2], 1, ARGV[4])
+redis.call("DEL", ARGV[3] .. ARGV[1])
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return 1
+end
+return 0
+`)
+
+// NewFairSlotLocker creates a locker that grants a held slot lock to waiters
+// in arrival order, waiting up to wait for its turn. With wait zero it
+// behaves like NewRedisSlotLocker without waiting.
+func NewFairSlotLocker(client *redis.Client, ttl, wait time.Duration) Locker {
+	return &redisSlotLocker{
+		client: client,
+		ttl:    ttl,
+		wait:   wait,
+		fair:   true,
+		held:   newHeldLocks(),
+	}
+}
+
+func (l *redisSlotLocker) acquireFair(ctx context.Context, key, token string) (bool, error) {
+	keys := []string{key, key + ":queue"}
+	ttlMs := l.ttl.Milliseconds()
+	grantPrefix := key + ":grant:"
+	grantKey := grantPrefix + token
+
+	callCtx, cancel := context.WithTimeout(ctx, acquireTimeout)
+	res, err := fairAcquireScript.Run(callCtx, l.client, keys, token, ttlMs, grantPrefix, l.wait.Milliseconds()).Slice()
+	if err != nil {
+		err = lockCallError("acquire slot lock", ctx, callCtx, err)
+	}
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res[0].(int64); n == 1 {
+		return true, nil
+	}
+	entry, _ := res[1].(string)
+
+	deadline := time.Now().Add(l.wait)
+	var waitErr error
+	for waitErr == nil {
+		remaining := time.Until(deadline)
+		if remaining <= 0 || ctx.Err() != nil {
+			break
+		}
+
+		// BLPOP holds the reply for up to the poll interval, so its call
+		// timeout starts after that
+		block := min(remaining, fairPollInterval)
+		granted, err := l.fairCall(ctx, block, func(ctx context.Context) error {
+			return l.client.BLPop(ctx, block, grantKey).Err()
+		})
+		if err != nil {
+			waitErr = err
+			break
+		}
+		if granted {
+			lockWaits.Inc(waitOutcome(true))
+			return true, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		lockRetries.Inc()
+
+		_, waitErr = l.fairCall(ctx, 0, func(ctx context.Context) error {
+			return fairPokeScript.Run(ctx, l.client, keys, token, ttlMs, grantPrefix).Err()
+		})
+	}
+
+	// Leave the queue even when ctx is done or Redis failed; an entry left
+	// behind could still be granted and hold the lock until its TTL
+	withdrawCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+	granted, err := fairWithdrawScript.Run(withdrawCtx, l.client, keys, token, ttlMs, grantPrefix, entry).Int()
+	if err != nil {
+		return false, fmt.Errorf("leave slot lock queue: %w", err)
+	}
+	if granted == 1 {
+		if ctx.Err() == nil && waitErr == nil {
+			lockWaits.Inc(waitOutcome(true))
+			return true, nil
+		}
+		if err := l.releaseFair(withdrawCtx, key, token); err != nil {
+			return false, err
+		}
+	}
+
+	lockWaits.Inc(waitOutcome(false))
+	if waitErr != nil {
+		return false, waitErr
+	}
+	return false, ctx.Err()
+}
+
+// fairCall runs one Redis call of a fair lock waiter with acquireTimeout on
+// top of block, and reports whether it returned a value. redis.Nil and a
+// done ctx are not errors; the caller sees ctx itself.
+func (l *redisSlotLocker) fairCall(ctx context.Context, block time.Duration, call func(ctx context.Context) error) (bool, error) {
+	callCtx, cancel := context.WithTimeout(ctx, block+acquireTimeout)
+	defer cancel()
+	err := call(callCtx)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, redis.Nil) || ctx.Err() != nil:
+		return false, nil
+	}
+	return false, lockCallError("wait for slot lock", ctx, callCtx, err)
+}
+
+func (l *redisSlotLocker) releaseFair(ctx context.Context, key, token string) error {
+	keys := []string{key, key + ":queue"}
+	err := fairReleaseScript.Run(ctx, l.client, keys, token, l.ttl.Milliseconds(), key+":grant:").Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("release slot lock: %w", err)
+	}
+	return nil
+}
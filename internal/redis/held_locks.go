@@ -0,0 +1,75 @@
+package redisclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// HeldLockReleaser is implemented by lockers that keep track of the locks
+// this process holds. Shutdown calls ReleaseHeld once the HTTP server has
+// stopped, so locks of requests that outlived the shutdown timeout are
+// freed now rather than when their TTL runs out.
+type HeldLockReleaser interface {
+	// ReleaseHeld releases every lock and permit still held and returns how
+	// many there were. Each is released with its own token, so a lock that
+	// has meanwhile expired and gone to someone else is left alone.
+	ReleaseHeld(ctx context.Context) (int, error)
+}
+
+// heldLock is one lock key, or one permit of a semaphore, and its token
+type heldLock struct {
+	key    string
+	token  string
+	permit bool
+}
+
+// heldLocks is the set of locks a redisSlotLocker currently holds
+type heldLocks struct {
+	mu    sync.Mutex
+	locks map[heldLock]struct{}
+}
+
+func newHeldLocks() *heldLocks {
+	return &heldLocks{locks: make(map[heldLock]struct{})}
+}
+
+func (h *heldLocks) add(l heldLock) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.locks[l] = struct{}{}
+}
+
+func (h *heldLocks) remove(l heldLock) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.locks, l)
+}
+
+func (h *heldLocks) list() []heldLock {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]heldLock, 0, len(h.locks))
+	for l := range h.locks {
+		out = append(out, l)
+	}
+	return out
+}
+
+func (l *redisSlotLocker) ReleaseHeld(ctx context.Context) (int, error) {
+	held := l.held.list()
+	var errs []error
+	for _, h := range held {
+		var err error
+		if h.permit {
+			err = l.client.ZRem(ctx, h.key, h.token).Err()
+		} else {
+			err = l.release(ctx, h.key, h.token)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		l.held.remove(h)
+	}
+	return len(held), errors.Join(errs...)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,48 +13,206 @@ import (
 
 var (
 	ErrLockNotAcquired = errors.New("slot lock not acquired")
+	// ErrLockTimeout is returned when a lock call ran out of its own time
+	// while the caller's context was still live, i.e. Redis is slow
+	ErrLockTimeout = errors.New("slot lock call timed out")
 )
 
-// Locker is used by the appointment service to guard critical sections per slot
+// Lock calls get their own short timeouts. Each acquire call gets
+// acquireTimeout on top of the caller's context, so a stalled Redis fails
+// the attempt instead of using up the request's whole budget. Release and
+// bookkeeping after the critical section get releaseTimeout and ignore the
+// caller's cancellation.
+const (
+	acquireTimeout = 250 * time.Millisecond
+	releaseTimeout = time.Second
+)
+
+// Retry delays while waiting for a held slot lock. Each delay is drawn
+// uniformly from [0, backoff) so waiters on the same slot spread out.
+const (
+	lockRetryBase = 5 * time.Millisecond
+	lockRetryMax  = 50 * time.Millisecond
+)
+
+// Locker guards critical sections over one or more named resources, see
+// SlotKey, RoomKey, ClinicianKey and ResourceKey
 type Locker interface {
-	WithSlotLock(ctx context.Context, slotID uuid.UUID, fn func(ctx context.Context) error) error
+	// WithLock holds every key while fn runs. Keys are taken in sorted order
+	// so two callers locking overlapping sets cannot deadlock, and all keys
+	// are released if any of them cannot be acquired.
+	WithLock(ctx context.Context, keys []string, fn func(ctx context.Context) error) error
+}
+
+// WithSlotLock locks a single slot
+func WithSlotLock(ctx context.Context, l Locker, slotID uuid.UUID, fn func(ctx context.Context) error) error {
+	return l.WithLock(ctx, []string{SlotKey(slotID)}, fn)
 }
 
 type redisSlotLocker struct {
 	client *redis.Client
 	ttl    time.Duration
+	wait   time.Duration
+	fair   bool // queue waiters in arrival order, see fair_lock.go
+	held   *heldLocks
 }
 
-// NewRedisSlotLocker creates a locker that uses a per slot Redis key
-func NewRedisSlotLocker(client *redis.Client, ttl time.Duration) Locker {
+// NewRedisSlotLocker creates a locker that uses one Redis key per resource.
+// When wait is positive a held lock is retried for up to wait before
+// ErrLockNotAcquired is returned; zero fails on the first miss.
+func NewRedisSlotLocker(client *redis.Client, ttl, wait time.Duration) Locker {
 	return &redisSlotLocker{
 		client: client,
 		ttl:    ttl,
+		wait:   wait,
+		held:   newHeldLocks(),
 	}
 }
 
-func (l *redisSlotLocker) WithSlotLock(ctx context.Context, slotID uuid.UUID, fn func(ctx context.Context) error) error {
-	key := fmt.Sprintf("lock:slot:%s", slotID.String())
-	token := uuid.NewString()
-
-	ok, err := l.client.SetNX(ctx, key, token, l.ttl).Result()
-	if err != nil {
-		return fmt.Errorf("acquire slot lock: %w", err)
+func (l *redisSlotLocker) WithLock(ctx context.Context, keys []string, fn func(ctx context.Context) error) error {
+	token := lockTokenFrom(ctx)
+	if token == "" {
+		token = uuid.NewString()
 	}
-	if !ok {
-		return ErrLockNotAcquired
+
+	var held []string
+	defer func() {
+		// Release even when ctx has been cancelled or timed out, otherwise
+		// the resources stay locked for the rest of the TTL
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+		defer cancel()
+		for i := len(held) - 1; i >= 0; i-- {
+			key := lockKey(held[i])
+			_ = l.release(releaseCtx, key, token)
+			l.held.remove(heldLock{key: key, token: token})
+		}
+	}()
+
+	// The first key's TTL runs out first, so it bounds fn
+	var expires time.Time
+	for _, key := range lockOrder(keys) {
+		lockAttempts.Inc()
+		ok, err := l.acquire(ctx, lockKey(key), token)
+		if err != nil || ok {
+			// On an error the key may have been set even though the reply
+			// was lost to a timeout or cancellation. Release checks the
+			// token, so it frees the key only if it is ours.
+			l.held.add(heldLock{key: lockKey(key), token: token})
+			held = append(held, key)
+		}
+		if err != nil || !ok {
+			return l.acquireFailed(ctx, key, err)
+		}
+
+		if len(held) == 1 {
+			expires = time.Now().Add(l.ttl)
+		}
 	}
 
+	acquired := time.Now()
 	defer func() {
-		_ = l.release(ctx, key, token)
+		hold := time.Since(acquired).Seconds()
+		lockHolds.Add(float64(len(held)))
+		lockHoldSeconds.Add(hold * float64(len(held)))
 	}()
 
-	ctxWithTimeout, cancel := context.WithTimeout(ctx, l.ttl)
+	if len(held) == 0 {
+		return fn(ctx)
+	}
+	ctxWithTimeout, cancel := context.WithDeadline(ctx, expires)
 	defer cancel()
 
 	return fn(ctxWithTimeout)
 }
 
+// acquireFailed records a failed acquisition of key and returns the error
+// WithLock reports for it; err is nil when the key was held by someone else
+func (l *redisSlotLocker) acquireFailed(ctx context.Context, key string, err error) error {
+	if err != nil {
+		if ctx.Err() != nil {
+			lockFailures.Inc("cancelled")
+		} else {
+			lockFailures.Inc("error")
+		}
+		return err
+	}
+	lockFailures.Inc("contended")
+	recordContention(ctx, l.client, key)
+	return ErrLockNotAcquired
+}
+
+// acquire tries SETNX until it succeeds or the wait budget runs out
+func (l *redisSlotLocker) acquire(ctx context.Context, key, token string) (bool, error) {
+	if l.fair && l.wait > 0 {
+		return l.acquireFair(ctx, key, token)
+	}
+	return l.retry(ctx, func(ctx context.Context) (bool, error) {
+		callCtx, cancel := context.WithTimeout(ctx, acquireTimeout)
+		defer cancel()
+		ok, err := l.client.SetNX(callCtx, key, token, l.ttl).Result()
+		if err != nil {
+			return false, lockCallError("acquire slot lock", ctx, callCtx, err)
+		}
+		return ok, nil
+	})
+}
+
+// lockCallError wraps err from a lock call made with callCtx, derived from
+// ctx. When the call's own timeout is what ran out, it is ErrLockTimeout.
+func lockCallError(op string, ctx, callCtx context.Context, err error) error {
+	if callCtx.Err() != nil && ctx.Err() == nil {
+		return fmt.Errorf("%s: %w: %v", op, ErrLockTimeout, err)
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// retry calls try until it succeeds or the wait budget runs out, sleeping a
+// jittered, growing delay in between. A cancelled ctx ends the wait early
+// with ctx's error, so a stage or request deadline is reported as such
+// rather than as contention.
+func (l *redisSlotLocker) retry(ctx context.Context, try func(ctx context.Context) (bool, error)) (bool, error) {
+	deadline := time.Now().Add(l.wait)
+	backoff := lockRetryBase
+
+	for retries := 0; ; retries++ {
+		ok, err := try(ctx)
+		if err != nil {
+			return false, err
+		}
+		if ok || l.wait <= 0 {
+			if retries > 0 {
+				lockWaits.Inc(waitOutcome(ok))
+			}
+			return ok, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			lockWaits.Inc(waitOutcome(false))
+			return false, nil
+		}
+		delay := min(time.Duration(rand.Int64N(int64(backoff))), remaining)
+		backoff = min(2*backoff, lockRetryMax)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			lockWaits.Inc(waitOutcome(false))
+			return false, ctx.Err()
+		case <-timer.C:
+		}
+		lockRetries.Inc()
+	}
+}
+
+func waitOutcome(acquired bool) string {
+	if acquired {
+		return "acquired"
+	}
+	return "gave_up"
+}
+
 var unlockScript = redis.NewScript(`
 local val = redis.call("GET", KEYS[1])
 if val == ARGV[1] then
@@ -64,9 +223,56 @@ end
 `)
 
 func (l *redisSlotLocker) release(ctx context.Context, key, token string) error {
+	if l.fair {
+		return l.releaseFair(ctx, key, token)
+	}
 	_, err := unlockScript.Run(ctx, l.client, []string{key}, token).Result()
 	if err != nil && !errors.Is(err, redis.Nil) {
 		return fmt.Errorf("release slot lock: %w", err)
 	}
 	return nil
 }
+
+func slotLockKey(slotID uuid.UUID) string {
+	return lockKey(SlotKey(slotID))
+}
+
+func lockKey(key string) string {
+	return "lock:" + key
+}
+
+type lockTokenKey struct{}
+
+// WithLockToken makes WithSlotLock take the lock with token instead of a
+// random one, so the caller can journal the token before acquiring.
+func WithLockToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, lockTokenKey{}, token)
+}
+
+func lockTokenFrom(ctx context.Context) string {
+	token, _ := ctx.Value(lockTokenKey{}).(string)
+	return token
+}
+
+// LockReleaser frees a slot lock left behind by a crashed holder
+type LockReleaser interface {
+	// ReleaseSlotLock deletes the lock only if it is still held with token
+	// and reports whether it did.
+	ReleaseSlotLock(ctx context.Context, slotID uuid.UUID, token string) (bool, error)
+}
+
+type redisLockReleaser struct {
+	client *redis.Client
+}
+
+func NewLockReleaser(client *redis.Client) LockReleaser {
+	return &redisLockReleaser{client: client}
+}
+
+func (r *redisLockReleaser) ReleaseSlotLock(ctx context.Context, slotID uuid.UUID, token string) (bool, error) {
+	n, err := unlockScript.Run(ctx, r.client, []string{slotLockKey(slotID)}, token).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, fmt.Errorf("release slot lock: %w", err)
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,64 @@
+package redisclient
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Lock keys name a resource as "<namespace>:<id>". The Redis key is the
+// resource name prefixed with "lock:", so a slot is "lock:slot:<id>".
+const (
+	slotNamespace      = "slot:"
+	roomNamespace      = "room:"
+	clinicianNamespace = "clinician:"
+	resourceNamespace  = "resource:"
+	phoneNamespace     = "phone:"
+)
+
+// SlotKey names the lock for one slot
+func SlotKey(id uuid.UUID) string {
+	return slotNamespace + id.String()
+}
+
+// RoomKey names the lock for one room
+func RoomKey(id uuid.UUID) string {
+	return roomNamespace + id.String()
+}
+
+// ClinicianKey names the lock for one clinician's calendar
+func ClinicianKey(id uuid.UUID) string {
+	return clinicianNamespace + id.String()
+}
+
+// ResourceKey names the lock for one staff resource, e.g. an interpreter
+func ResourceKey(id uuid.UUID) string {
+	return resourceNamespace + id.String()
+}
+
+// PhoneKey names the lock for the SMS replies from one phone number
+func PhoneKey(phone string) string {
+	return phoneNamespace + phone
+}
+
+// ParseSlotKey returns the slot id of a key made by SlotKey
+func ParseSlotKey(key string) (uuid.UUID, bool) {
+	rest, ok := strings.CutPrefix(key, slotNamespace)
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(rest)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// lockOrder sorts and deduplicates keys. Every locker takes keys in this
+// order, which rules out two callers each holding a key the other waits for.
+func lockOrder(keys []string) []string {
+	ordered := slices.Clone(keys)
+	slices.Sort(ordered)
+	return slices.Compact(ordered)
+}
@@ -0,0 +1,185 @@
+package redisclient
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+)
+
+// contentionKey is a sorted set of slot id -> failed acquisitions shared by
+// every instance. It expires after a day without contention.
+const (
+	contentionKey = "lock:contention"
+	contentionTTL = 24 * time.Hour
+
+	// contentionKeep bounds the sorted set; trimming drops the least
+	// contended slots
+	contentionKeep = 1000
+
+	// ContentionWindow is how far back RecentConflicts counts. Each slot
+	// has a counter per window, contention:<slot id>:<window number>, kept
+	// out of lock: so the lock listing does not take them for locks.
+	ContentionWindow = 5 * time.Minute
+)
+
+var (
+	lockAttempts = metrics.NewCounter(
+		"slot_lock_attempts_total",
+		"Lock key acquisition attempts.",
+	)
+	lockFailures = metrics.NewCounter(
+		"slot_lock_failures_total",
+		"Slot lock acquisitions that failed, by reason (contended, error, cancelled).",
+		"reason",
+	)
+	lockRetries = metrics.NewCounter(
+		"slot_lock_retries_total",
+		"SETNX retries made while waiting for a held slot lock.",
+	)
+	lockWaits = metrics.NewCounter(
+		"slot_lock_waits_total",
+		"Acquisitions that found the lock held and waited, by outcome (acquired, gave_up).",
+		"outcome",
+	)
+	lockHolds = metrics.NewCounter(
+		"slot_lock_holds_total",
+		"Lock keys acquired and released.",
+	)
+	lockHoldSeconds = metrics.NewCounter(
+		"slot_lock_hold_seconds_total",
+		"Total time lock keys were held.",
+	)
+)
+
+// LockStats is this process's view of slot lock activity since start
+type LockStats struct {
+	Attempts         int64
+	Contended        int64
+	Errors           int64
+	Holds            int64
+	HoldSecondsTotal float64
+}
+
+// CurrentLockStats reads the lock counters of this process
+func CurrentLockStats() LockStats {
+	return LockStats{
+		Attempts:         int64(lockAttempts.Value()),
+		Contended:        int64(lockFailures.Value("contended")),
+		Errors:           int64(lockFailures.Value("error")),
+		Holds:            int64(lockHolds.Value()),
+		HoldSecondsTotal: lockHoldSeconds.Value(),
+	}
+}
+
+// SlotContention is the number of failed acquisitions recorded for one slot
+type SlotContention struct {
+	SlotID    uuid.UUID
+	Conflicts int64
+}
+
+// ContentionReport reads the cluster-wide contended slots ranking
+type ContentionReport struct {
+	client *redis.Client
+}
+
+func NewContentionReport(client *redis.Client) *ContentionReport {
+	return &ContentionReport{client: client}
+}
+
+// TopContended returns up to k slots with the most failed acquisitions
+func (c *ContentionReport) TopContended(ctx context.Context, k int) ([]SlotContention, error) {
+	entries, err := c.client.ZRevRangeWithScores(ctx, contentionKey, 0, int64(k)-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read lock contention: %w", err)
+	}
+
+	out := make([]SlotContention, 0, len(entries))
+	for _, e := range entries {
+		member, _ := e.Member.(string)
+		id, err := uuid.Parse(member)
+		if err != nil {
+			continue
+		}
+		out = append(out, SlotContention{SlotID: id, Conflicts: int64(e.Score)})
+	}
+	return out, nil
+}
+
+// recordContention bumps a slot key in the shared ranking; other resources
+// are not ranked. It is best effort: the caller is already failing with
+// ErrLockNotAcquired.
+func recordContention(ctx context.Context, client *redis.Client, key string) {
+	slotID, ok := ParseSlotKey(key)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+
+	window, _ := contentionWindowAt(time.Now())
+	recent := recentContentionKey(slotID, window)
+
+	pipe := client.Pipeline()
+	pipe.ZIncrBy(ctx, contentionKey, 1, slotID.String())
+	pipe.ZRemRangeByRank(ctx, contentionKey, 0, -contentionKeep-1)
+	pipe.Expire(ctx, contentionKey, contentionTTL)
+	pipe.Incr(ctx, recent)
+	pipe.Expire(ctx, recent, 2*ContentionWindow)
+	_, _ = pipe.Exec(ctx)
+}
+
+// RecentConflicts estimates the failed acquisitions of each slot over the
+// last ContentionWindow. Slots without any are left out of the map. The
+// count is the current window's plus the previous window's scaled by how
+// much of it is still within reach, so it fades out rather than dropping
+// to zero when a window turns over.
+func (c *ContentionReport) RecentConflicts(ctx context.Context, slotIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	out := make(map[uuid.UUID]int64)
+	if len(slotIDs) == 0 {
+		return out, nil
+	}
+	window, elapsed := contentionWindowAt(time.Now())
+	keys := make([]string, 0, 2*len(slotIDs))
+	for _, id := range slotIDs {
+		keys = append(keys, recentContentionKey(id, window), recentContentionKey(id, window-1))
+	}
+	vals, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read recent lock contention: %w", err)
+	}
+
+	previousWeight := 1 - elapsed.Seconds()/ContentionWindow.Seconds()
+	for i, id := range slotIDs {
+		current, previous := countValue(vals[2*i]), countValue(vals[2*i+1])
+		if n := current + int64(math.Round(float64(previous)*previousWeight)); n > 0 {
+			out[id] = n
+		}
+	}
+	return out, nil
+}
+
+// contentionWindowAt numbers the window t falls in and says how far into
+// it t is
+func contentionWindowAt(t time.Time) (int64, time.Duration) {
+	n := t.UnixNano() / int64(ContentionWindow)
+	return n, time.Duration(t.UnixNano() - n*int64(ContentionWindow))
+}
+
+func recentContentionKey(slotID uuid.UUID, window int64) string {
+	return fmt.Sprintf("contention:%s:%d", slotID, window)
+}
+
+// countValue reads a counter from MGET, nil when it does not exist
+func countValue(v any) int64 {
+	s, _ := v.(string)
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
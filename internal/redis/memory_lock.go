@@ -0,0 +1,74 @@
+package redisclient
+
+import (
+	"context"
+	"sync"
+)
+
+// memorySlotLocker is a process-local Locker for single-instance deployments
+// such as the demo mode. It gives no protection across processes.
+type memorySlotLocker struct {
+	mu      sync.Mutex
+	held    map[string]struct{}
+	permits map[string]int
+}
+
+// NewInMemorySlotLocker creates a locker that keeps locks in process memory
+func NewInMemorySlotLocker() Locker {
+	return &memorySlotLocker{
+		held:    make(map[string]struct{}),
+		permits: make(map[string]int),
+	}
+}
+
+// WithLock takes all keys at once under the mutex, so ordering only matters
+// for consistency with the Redis locker
+func (l *memorySlotLocker) WithLock(ctx context.Context, keys []string, fn func(ctx context.Context) error) error {
+	keys = lockOrder(keys)
+
+	l.mu.Lock()
+	for _, key := range keys {
+		if _, busy := l.held[key]; busy {
+			l.mu.Unlock()
+			return ErrLockNotAcquired
+		}
+	}
+	for _, key := range keys {
+		l.held[key] = struct{}{}
+	}
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		for _, key := range keys {
+			delete(l.held, key)
+		}
+		l.mu.Unlock()
+	}()
+
+	return fn(ctx)
+}
+
+func (l *memorySlotLocker) WithPermit(ctx context.Context, key string, limit int, fn func(ctx context.Context) error) error {
+	if limit <= 1 {
+		return l.WithLock(ctx, []string{key}, fn)
+	}
+
+	l.mu.Lock()
+	if l.permits[key] >= limit {
+		l.mu.Unlock()
+		return ErrLockNotAcquired
+	}
+	l.permits[key]++
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		if l.permits[key]--; l.permits[key] == 0 {
+			delete(l.permits, key)
+		}
+		l.mu.Unlock()
+	}()
+
+	return fn(ctx)
+}
@@ -0,0 +1,94 @@
+package redisclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimiter counts requests per key in fixed windows
+type RateLimiter interface {
+	// Allow counts one request for key and reports whether it is within
+	// limit for the current window. When it is not, retryAfter is how long
+	// until the window ends.
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// The counter expires with its window, so the first request of a window
+// starts it. ARGV: window ms. Returns the count and the ms left.
+var rateLimitScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+  redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {n, redis.call("PTTL", KEYS[1])}
+`)
+
+type redisRateLimiter struct {
+	client *redis.Client
+}
+
+// NewRedisRateLimiter counts in Redis, so every instance shares the limits.
+// Use the RoleRateLimit client.
+func NewRedisRateLimiter(client *redis.Client) RateLimiter {
+	return &redisRateLimiter{client: client}
+}
+
+func (l *redisRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	res, err := rateLimitScript.Run(ctx, l.client, []string{"ratelimit:" + key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("count request: %w", err)
+	}
+	if res[0] <= int64(limit) {
+		return true, 0, nil
+	}
+	return false, time.Duration(max(res[1], 0)) * time.Millisecond, nil
+}
+
+// memoryRateLimiter is a process-local RateLimiter for single-instance
+// deployments such as the demo mode
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	nextSweep time.Time
+}
+
+type rateWindow struct {
+	count int
+	ends  time.Time
+}
+
+// NewInMemoryRateLimiter creates a rate limiter that counts in process memory
+func NewInMemoryRateLimiter() RateLimiter {
+	return &memoryRateLimiter{windows: make(map[string]*rateWindow)}
+}
+
+func (l *memoryRateLimiter) Allow(_ context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if !now.Before(l.nextSweep) {
+		// Drop ended windows once a window, so idle keys do not pile up
+		for k, w := range l.windows {
+			if !now.Before(w.ends) {
+				delete(l.windows, k)
+			}
+		}
+		l.nextSweep = now.Add(window)
+	}
+
+	w, ok := l.windows[key]
+	if !ok || !now.Before(w.ends) {
+		w = &rateWindow{ends: now.Add(window)}
+		l.windows[key] = w
+	}
+	w.count++
+	if w.count <= limit {
+		return true, 0, nil
+	}
+	return false, w.ends.Sub(now), nil
+}
@@ -0,0 +1,96 @@
+package redisclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const instanceKeyPrefix = "instance:"
+
+// Instance is what one api-server publishes about itself on every heartbeat
+type Instance struct {
+	ID          string    `json:"id"`
+	Hostname    string    `json:"hostname"`
+	Addr        string    `json:"addr"` // base URL other instances use to reach this one
+	Version     string    `json:"version"`
+	StartedAt   time.Time `json:"started_at"`
+	LastSeen    time.Time `json:"last_seen"`
+	RequestRate float64   `json:"request_rate"` // requests per second since the previous heartbeat
+}
+
+// InstanceRegistry keeps one Redis key per running instance. Keys expire after
+// ttl, so an instance that stops heartbeating drops out without cleanup.
+type InstanceRegistry struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewInstanceRegistry(client *redis.Client, ttl time.Duration) *InstanceRegistry {
+	return &InstanceRegistry{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// Heartbeat writes the instance record and refreshes its TTL
+func (r *InstanceRegistry) Heartbeat(ctx context.Context, inst Instance) error {
+	payload, err := json.Marshal(inst)
+	if err != nil {
+		return fmt.Errorf("marshal instance: %w", err)
+	}
+
+	if err := r.client.Set(ctx, instanceKeyPrefix+inst.ID, payload, r.ttl).Err(); err != nil {
+		return fmt.Errorf("write instance heartbeat: %w", err)
+	}
+	return nil
+}
+
+// Deregister removes the instance immediately, used on graceful shutdown
+func (r *InstanceRegistry) Deregister(ctx context.Context, id string) error {
+	return r.client.Del(ctx, instanceKeyPrefix+id).Err()
+}
+
+// List returns all live instances ordered by start time
+func (r *InstanceRegistry) List(ctx context.Context) ([]Instance, error) {
+	var keys []string
+	iter := r.client.Scan(ctx, 0, instanceKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("scan instances: %w", err)
+	}
+	if len(keys) == 0 {
+		return []Instance{}, nil
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read instances: %w", err)
+	}
+
+	instances := make([]Instance, 0, len(values))
+	for _, v := range values {
+		// a key can expire between SCAN and MGET
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		var inst Instance
+		if err := json.Unmarshal([]byte(s), &inst); err != nil {
+			continue
+		}
+		instances = append(instances, inst)
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].StartedAt.Before(instances[j].StartedAt)
+	})
+	return instances, nil
+}
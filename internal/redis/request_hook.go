@@ -0,0 +1,71 @@
+package redisclient
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/requestid"
+)
+
+// slowCommand is how long a command or pipeline may take before it is logged
+// with the request it ran for. Redis commands cannot carry a comment the way
+// SQL can, so the log is where the request ID is attached.
+const slowCommand = 50 * time.Millisecond
+
+var slowCommands = metrics.NewCounter(
+	"redis_slow_commands_total",
+	"Redis commands and pipelines slower than 50ms, by client role.",
+	"client",
+)
+
+// blockingCommands wait on the server by design, e.g. a fair lock waiter's
+// BLPOP, and are never slow
+var blockingCommands = map[string]bool{
+	"blpop": true, "brpop": true, "blmove": true, "bzpopmin": true, "bzpopmax": true,
+}
+
+// requestHook logs slow commands with the ID of the request in their context
+type requestHook struct {
+	role Role
+}
+
+func (h requestHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h requestHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		if elapsed := time.Since(start); elapsed > slowCommand && !blockingCommands[cmd.Name()] {
+			h.logSlow(ctx, cmd.Name(), elapsed)
+		}
+		return err
+	}
+}
+
+func (h requestHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		if elapsed := time.Since(start); elapsed > slowCommand {
+			names := make([]string, len(cmds))
+			for i, cmd := range cmds {
+				names[i] = cmd.Name()
+			}
+			h.logSlow(ctx, "pipeline("+strings.Join(names, ",")+")", elapsed)
+		}
+		return err
+	}
+}
+
+func (h requestHook) logSlow(ctx context.Context, command string, elapsed time.Duration) {
+	slowCommands.Inc(string(h.role))
+	log.Printf("slow redis command client=%s command=%s duration=%s request_id=%s",
+		h.role, command, elapsed, requestid.Tag(requestid.From(ctx)))
+}
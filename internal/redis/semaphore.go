@@ -0,0 +1,90 @@
+package redisclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Semaphore is implemented by lockers that can let up to limit holders into
+// a critical section for the same key, e.g. a slot with capacity above one
+type Semaphore interface {
+	WithPermit(ctx context.Context, key string, limit int, fn func(ctx context.Context) error) error
+}
+
+// Permits are members of a sorted set scored by their expiry in ms, so a
+// holder that dies only occupies its permit until the lock TTL runs out.
+// ARGV: token, ttl ms, limit.
+var acquirePermitScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[3]) then
+  return 0
+end
+redis.call("ZADD", KEYS[1], now + tonumber(ARGV[2]), ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+
+// WithPermit runs fn while holding one of limit permits for key. A limit of
+// one or less is the same as WithLock on key alone.
+func (l *redisSlotLocker) WithPermit(ctx context.Context, key string, limit int, fn func(ctx context.Context) error) error {
+	if limit <= 1 {
+		return l.WithLock(ctx, []string{key}, fn)
+	}
+
+	token := lockTokenFrom(ctx)
+	if token == "" {
+		token = uuid.NewString()
+	}
+	permits := permitsKey(key)
+
+	lockAttempts.Inc()
+	ok, err := l.retry(ctx, func(ctx context.Context) (bool, error) {
+		callCtx, cancel := context.WithTimeout(ctx, acquireTimeout)
+		defer cancel()
+		ok, err := acquirePermitScript.Run(callCtx, l.client, []string{permits}, token, l.ttl.Milliseconds(), limit).Bool()
+		if err != nil {
+			return false, lockCallError("acquire slot permit", ctx, callCtx, err)
+		}
+		return ok, nil
+	})
+	if err != nil || ok {
+		l.held.add(heldLock{key: permits, token: token, permit: true})
+	}
+	if err != nil {
+		// As in WithLock, the permit may have been taken without the reply
+		// arriving
+		l.releasePermit(ctx, permits, token)
+	}
+	if err != nil || !ok {
+		return l.acquireFailed(ctx, key, err)
+	}
+
+	acquired := time.Now()
+	defer func() {
+		lockHolds.Inc()
+		lockHoldSeconds.Add(time.Since(acquired).Seconds())
+		l.releasePermit(ctx, permits, token)
+	}()
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, l.ttl)
+	defer cancel()
+
+	return fn(ctxWithTimeout)
+}
+
+// releasePermit gives token's permit back even when ctx is done
+func (l *redisSlotLocker) releasePermit(ctx context.Context, permits, token string) {
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+	_ = l.client.ZRem(releaseCtx, permits, token).Err()
+	l.held.remove(heldLock{key: permits, token: token, permit: true})
+}
+
+func permitsKey(key string) string {
+	return lockKey(key) + ":permits"
+}
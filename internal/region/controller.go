@@ -0,0 +1,137 @@
+// Package region supports running the api-server in a passive disaster
+// recovery region: it tracks read-only mode, exposes replication lag and
+// drives promotion to active.
+package region
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+)
+
+const (
+	RoleActive  = "active"
+	RolePassive = "passive"
+)
+
+var (
+	ErrNotPassive      = errors.New("region is already active")
+	ErrDatabaseStandby = errors.New("database is still a standby, promote it first")
+)
+
+// Status is a point-in-time view of this instance's region role
+type Status struct {
+	Role           string
+	ReadOnly       bool
+	InRecovery     bool
+	ReplicationLag *time.Duration
+	MaxLag         time.Duration
+	PromotedAt     *time.Time
+}
+
+// Controller holds the read-only flag. It starts from config (READ_ONLY) and
+// is cleared by Promote; the flag is per process, so promotion has to reach
+// every instance (see cmd/region-ctl).
+type Controller struct {
+	pool   *pgxpool.Pool
+	maxLag time.Duration
+
+	readOnly atomic.Bool
+
+	mu         sync.Mutex
+	promotedAt *time.Time
+}
+
+func NewController(pool *pgxpool.Pool, readOnly bool, maxLag time.Duration) *Controller {
+	c := &Controller{pool: pool, maxLag: maxLag}
+	c.readOnly.Store(readOnly)
+	return c
+}
+
+// ReadOnly reports whether writes must be rejected
+func (c *Controller) ReadOnly() bool {
+	return c.readOnly.Load()
+}
+
+func (c *Controller) Status(ctx context.Context) (Status, error) {
+	inRecovery, lag, err := db.ReplicationStatus(ctx, c.pool)
+	if err != nil {
+		return Status{}, err
+	}
+
+	st := Status{
+		Role:           RoleActive,
+		ReadOnly:       c.ReadOnly(),
+		InRecovery:     inRecovery,
+		ReplicationLag: lag,
+		MaxLag:         c.maxLag,
+	}
+	if st.ReadOnly {
+		st.Role = RolePassive
+	}
+
+	c.mu.Lock()
+	st.PromotedAt = c.promotedAt
+	c.mu.Unlock()
+
+	return st, nil
+}
+
+// Check fails when a standby database has fallen further behind than the
+// configured maximum, or has no replay position yet. A primary always passes.
+func (c *Controller) Check(ctx context.Context) error {
+	inRecovery, lag, err := db.ReplicationStatus(ctx, c.pool)
+	if err != nil {
+		return err
+	}
+	if !inRecovery {
+		return nil
+	}
+	if lag == nil {
+		return errors.New("standby has not replayed any transactions")
+	}
+	if *lag > c.maxLag {
+		return fmt.Errorf("replication lag %s exceeds %s", lag.Round(time.Millisecond), c.maxLag)
+	}
+	return nil
+}
+
+// Promote makes this instance accept writes. With promoteDB it first promotes
+// the Postgres standby it is connected to; run that on exactly one instance
+// and plain Promote on the rest.
+func (c *Controller) Promote(ctx context.Context, promoteDB bool) (Status, error) {
+	if !c.ReadOnly() {
+		return Status{}, ErrNotPassive
+	}
+
+	inRecovery, _, err := db.ReplicationStatus(ctx, c.pool)
+	if err != nil {
+		return Status{}, err
+	}
+	if inRecovery {
+		if !promoteDB {
+			return Status{}, ErrDatabaseStandby
+		}
+		if err := db.PromotePostgres(ctx, c.pool); err != nil {
+			return Status{}, err
+		}
+		log.Println("postgres standby promoted to primary")
+	}
+
+	now := time.Now().UTC()
+	c.mu.Lock()
+	c.promotedAt = &now
+	c.mu.Unlock()
+	c.readOnly.Store(false)
+	log.Println("region promoted to active, writes enabled")
+
+	return c.Status(ctx)
+}
@@ -0,0 +1,34 @@
+// Package requestid carries the ID of the HTTP request being served in its
+// context, so the storage layers below the API can tag their work with it
+// and a slow statement or command can be traced back to its request.
+package requestid
+
+import "context"
+
+type key struct{}
+
+// With returns ctx carrying id
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// From returns the request ID in ctx, or "" outside a request
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(key{}).(string)
+	return id
+}
+
+// Tag returns id cut down to at most 36 letters, digits, '-', '_', '.' or
+// ':', the form of a UUID. IDs come from a client header, so they are
+// cleaned before being put anywhere an operator reads them back.
+func Tag(id string) string {
+	b := make([]byte, 0, min(len(id), 36))
+	for i := 0; i < len(id) && len(b) < 36; i++ {
+		switch c := id[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == ':':
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,73 @@
+package shard
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+)
+
+var (
+	ErrInvalidConsistencyToken = errors.New("invalid consistency token")
+	ErrStaleRead               = errors.New("database has not caught up with the consistency token")
+)
+
+var lsnPattern = regexp.MustCompile(`^[0-9A-F]{1,8}/[0-9A-F]{1,8}$`)
+
+// awaitPoll is how often AwaitToken checks a standby's replay
+const awaitPoll = 20 * time.Millisecond
+
+// ConsistencyToken returns a read-after-write token for the shard of ctx: its
+// name and its WAL position, which is past every write committed so far.
+// Reads that pass it to AwaitToken see those writes.
+func (s *Set) ConsistencyToken(ctx context.Context) (string, error) {
+	name := s.Resolve(ctx)
+	lsn, err := db.WALPosition(ctx, s.pools[name])
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(name + ":" + lsn)), nil
+}
+
+// AwaitToken waits up to wait for the shard of ctx to have the writes token
+// was issued after. A primary always has them; a standby, as in a passive
+// region, has them once its replay reaches the token, and AwaitToken fails
+// with ErrStaleRead if that takes longer than wait. A token of another
+// shard, left over from a tenant that has since moved, is ignored.
+func (s *Set) AwaitToken(ctx context.Context, token string, wait time.Duration) error {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ErrInvalidConsistencyToken
+	}
+	name, lsn, ok := strings.Cut(string(raw), ":")
+	if !ok || !lsnPattern.MatchString(lsn) {
+		return ErrInvalidConsistencyToken
+	}
+	if name != s.Resolve(ctx) {
+		return nil
+	}
+
+	pool := s.pools[name]
+	deadline := time.Now().Add(wait)
+	for {
+		ok, err := db.ReplayedTo(ctx, pool, lsn)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().Add(awaitPoll).After(deadline) {
+			return ErrStaleRead
+		}
+		select {
+		case <-time.After(awaitPoll):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
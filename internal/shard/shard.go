@@ -0,0 +1,227 @@
+// Package shard keeps tenants' booking data in separate Postgres databases.
+// A Set holds one pool per shard and routes each query to the shard of the
+// tenant in its context, so repositories built on a Set need no changes to
+// serve every shard.
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+)
+
+// Default is the shard of POSTGRES_DSN. It holds every tenant without an
+// assignment along with the shared tables: webhooks, jobs and the shard map.
+const Default = "default"
+
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidTenant reports whether id can name a tenant
+func ValidTenant(id string) bool {
+	return tenantPattern.MatchString(id)
+}
+
+type tenantKey struct{}
+type shardKey struct{}
+
+// WithTenant routes queries made with ctx to the tenant's shard
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant set by WithTenant, if any
+func Tenant(ctx context.Context) string {
+	t, _ := ctx.Value(tenantKey{}).(string)
+	return t
+}
+
+// WithShard routes queries made with ctx to the named shard whatever the
+// tenant, for work that is not done on behalf of one such as worker runs
+func WithShard(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, shardKey{}, name)
+}
+
+// Name returns the shard set by WithShard, else Default. It is for labelling
+// work done per shard; Set.Resolve also follows the tenant.
+func Name(ctx context.Context) string {
+	if name, ok := ctx.Value(shardKey{}).(string); ok {
+		return name
+	}
+	return Default
+}
+
+// Set is the pools of every shard and the tenants assigned to them. It is
+// safe for concurrent use once built.
+type Set struct {
+	pools   map[string]*pgxpool.Pool
+	tenants map[string]string
+}
+
+// Connect opens a pool for each of dsns, keyed by shard name, next to def,
+// the default shard. tenants assigns tenants to shards; rows in the
+// tenant_shards table of the default shard take precedence over it.
+// tagRequests and prepare are passed to db.ConnectPostgres for every shard.
+func Connect(ctx context.Context, def *pgxpool.Pool, dsns, tenants map[string]string, tagRequests bool, prepare ...string) (*Set, error) {
+	s := &Set{
+		pools:   map[string]*pgxpool.Pool{Default: def},
+		tenants: make(map[string]string, len(tenants)),
+	}
+	for name, dsn := range dsns {
+		if name == Default {
+			s.Close()
+			return nil, fmt.Errorf("shard %q is POSTGRES_DSN and cannot be redefined", Default)
+		}
+		pool, err := db.ConnectPostgres(ctx, name, dsn, tagRequests, prepare...)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("connect shard %s: %w", name, err)
+		}
+		s.pools[name] = pool
+	}
+
+	for tenant, name := range tenants {
+		s.tenants[tenant] = name
+	}
+	if err := s.loadAssignments(ctx); err != nil {
+		s.Close()
+		return nil, err
+	}
+	for tenant, name := range s.tenants {
+		if _, ok := s.pools[name]; !ok {
+			s.Close()
+			return nil, fmt.Errorf("tenant %s is assigned to unknown shard %q", tenant, name)
+		}
+	}
+	return s, nil
+}
+
+// Single is a Set with only the default shard
+func Single(def *pgxpool.Pool) *Set {
+	return &Set{pools: map[string]*pgxpool.Pool{Default: def}, tenants: map[string]string{}}
+}
+
+func (s *Set) loadAssignments(ctx context.Context) error {
+	rows, err := s.pools[Default].Query(ctx, `SELECT tenant_id, shard FROM tenant_shards`)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "42P01" { // undefined_table
+			return nil
+		}
+		return fmt.Errorf("load tenant shards: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tenant, name string
+		if err := rows.Scan(&tenant, &name); err != nil {
+			return fmt.Errorf("scan tenant shard: %w", err)
+		}
+		s.tenants[tenant] = name
+	}
+	return rows.Err()
+}
+
+// Names lists the shards, the default first
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.pools))
+	for name := range s.pools {
+		if name != Default {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{Default}, names...)
+}
+
+// Sharded reports whether the set has shards beyond the default
+func (s *Set) Sharded() bool {
+	return len(s.pools) > 1
+}
+
+// Named returns the pool of a shard listed by Names
+func (s *Set) Named(name string) *pgxpool.Pool {
+	return s.pools[name]
+}
+
+// Pools returns the pool of every shard by name
+func (s *Set) Pools() map[string]*pgxpool.Pool {
+	pools := make(map[string]*pgxpool.Pool, len(s.pools))
+	for name, pool := range s.pools {
+		pools[name] = pool
+	}
+	return pools
+}
+
+// Resolve names the shard queries made with ctx go to: the one set by
+// WithShard, else the tenant's, else the default
+func (s *Set) Resolve(ctx context.Context) string {
+	if name, ok := ctx.Value(shardKey{}).(string); ok {
+		if _, known := s.pools[name]; known {
+			return name
+		}
+	}
+	if name, ok := s.tenants[Tenant(ctx)]; ok {
+		return name
+	}
+	return Default
+}
+
+// Dedicated reports whether the shard ctx is routed to holds the records of
+// tenant and no other. The default shard holds every tenant without an
+// assignment, so it only counts as dedicated to requests naming no tenant,
+// those of the deployment itself.
+func (s *Set) Dedicated(ctx context.Context, tenant string) bool {
+	name := s.Resolve(ctx)
+	if name == Default {
+		return tenant == ""
+	}
+	if s.tenants[tenant] != name {
+		return false
+	}
+	for other, assigned := range s.tenants {
+		if assigned == name && other != tenant {
+			return false
+		}
+	}
+	return true
+}
+
+// Pool returns the pool queries made with ctx go to
+func (s *Set) Pool(ctx context.Context) *pgxpool.Pool {
+	return s.pools[s.Resolve(ctx)]
+}
+
+// Exec, Query, QueryRow and Begin mirror *pgxpool.Pool on the shard of ctx
+
+func (s *Set) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return s.Pool(ctx).Exec(ctx, sql, args...)
+}
+
+func (s *Set) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return s.Pool(ctx).Query(ctx, sql, args...)
+}
+
+func (s *Set) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return s.Pool(ctx).QueryRow(ctx, sql, args...)
+}
+
+func (s *Set) Begin(ctx context.Context) (pgx.Tx, error) {
+	return s.Pool(ctx).Begin(ctx)
+}
+
+// Close closes the shard pools. The default pool belongs to the caller.
+func (s *Set) Close() {
+	for name, pool := range s.pools {
+		if name != Default {
+			pool.Close()
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Package sms checks the webhooks a carrier sends inbound SMS to. Twilio
+// signs each request with the account's auth token over the URL it posted
+// to and the form parameters, so a request that verifies came from the
+// carrier for that URL, tenant query parameter included.
+package sms
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"sort"
+)
+
+var ErrInvalidSignature = errors.New("invalid request signature")
+
+// VerifyTwilio checks signature, the X-Twilio-Signature header, against
+// fullURL, the URL exactly as configured with Twilio, and the POST form
+// params
+func VerifyTwilio(authToken, fullURL string, params url.Values, signature string) error {
+	got, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, twilioMAC(authToken, fullURL, params)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// twilioMAC is HMAC-SHA1 over the URL followed by each parameter's name and
+// value, sorted by name
+func twilioMAC(authToken, fullURL string, params url.Values) []byte {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := hmac.New(sha1.New, []byte(authToken))
+	h.Write([]byte(fullURL))
+	for _, k := range keys {
+		for _, v := range params[k] {
+			h.Write([]byte(k + v))
+		}
+	}
+	return h.Sum(nil)
+}
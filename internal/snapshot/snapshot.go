@@ -0,0 +1,362 @@
+// Package snapshot copies the slot inventory of a database - clinics,
+// clinicians, patients and slots - to a portable file and back, e.g. to
+// refresh staging from anonymized prod-shaped data. The file is NDJSON like
+// the clinic appointment export: a header line, then one row per line in an
+// order that satisfies the foreign keys, so both directions stream in
+// constant memory. Patients can be anonymized on the way out, or in an
+// existing file, so the data never leaves production with its PII.
+package snapshot
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/anonymize"
+)
+
+// FormatVersion is written in the header and checked on restore
+const FormatVersion = 1
+
+// maxLine bounds one NDJSON row read back on restore
+const maxLine = 1 << 20
+
+// Header is the first line of a snapshot
+type Header struct {
+	Version int       `json:"version"`
+	TakenAt time.Time `json:"taken_at"`
+}
+
+type Clinic struct {
+	ID               uuid.UUID `json:"id"`
+	Name             string    `json:"name"`
+	RequiresApproval bool      `json:"requires_approval"`
+}
+
+type Clinician struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	Specialty     *string    `json:"specialty,omitempty"`
+	ClinicID      *uuid.UUID `json:"clinic_id,omitempty"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+}
+
+type Patient struct {
+	ID                 uuid.UUID  `json:"id"`
+	Name               string     `json:"name"`
+	Email              *string    `json:"email,omitempty"`
+	Phone              *string    `json:"phone,omitempty"`
+	DeactivatedAt      *time.Time `json:"deactivated_at,omitempty"`
+	DeactivatedBy      *string    `json:"deactivated_by,omitempty"`
+	DeactivationReason *string    `json:"deactivation_reason,omitempty"`
+}
+
+// Slot leaves out confirmed_count: appointments are not part of a snapshot,
+// so a restored slot counts only the appointments already in the target
+type Slot struct {
+	ID             uuid.UUID `json:"id"`
+	PractitionerID uuid.UUID `json:"practitioner_id"`
+	StartTime      time.Time `json:"start_time"`
+	EndTime        time.Time `json:"end_time"`
+	Status         string    `json:"status"`
+	Capacity       int       `json:"capacity"`
+	SlotType       *string   `json:"slot_type,omitempty"`
+}
+
+// line is one row of the file; exactly one field is set
+type line struct {
+	Header    *Header    `json:"header,omitempty"`
+	Clinic    *Clinic    `json:"clinic,omitempty"`
+	Clinician *Clinician `json:"clinician,omitempty"`
+	Patient   *Patient   `json:"patient,omitempty"`
+	Slot      *Slot      `json:"slot,omitempty"`
+}
+
+// Counts is how many rows of each kind a snapshot or restore handled
+type Counts struct {
+	Clinics    int
+	Clinicians int
+	Patients   int
+	Slots      int
+}
+
+func (c Counts) String() string {
+	return fmt.Sprintf("%d clinics, %d clinicians, %d patients, %d slots", c.Clinics, c.Clinicians, c.Patients, c.Slots)
+}
+
+// ErrFormat means the input is not a snapshot this binary can restore
+var ErrFormat = errors.New("not a valid snapshot")
+
+// anonymizePatient replaces the patient's personal data with fakes from a.
+// Clinicians and clinics are staff and businesses, and are kept.
+func anonymizePatient(p *Patient, a *anonymize.Anonymizer) {
+	p.Name = a.Name(p.Name)
+	if p.Email != nil {
+		email := a.Email(*p.Email)
+		p.Email = &email
+	}
+	if p.Phone != nil {
+		phone := a.Phone(*p.Phone)
+		p.Phone = &phone
+	}
+	if p.DeactivatedBy != nil {
+		by := anonymize.Redacted
+		p.DeactivatedBy = &by
+	}
+	if p.DeactivationReason != nil {
+		reason := anonymize.Redacted
+		p.DeactivationReason = &reason
+	}
+}
+
+// Save writes the inventory of pool to w. It reads in one repeatable read
+// transaction, so the rows are consistent with each other while writers
+// keep running. With a non-nil anon every patient is anonymized before it
+// is written.
+func Save(ctx context.Context, pool *pgxpool.Pool, w io.Writer, anon *anonymize.Anonymizer) (Counts, error) {
+	var counts Counts
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return counts, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := enc.Encode(line{Header: &Header{Version: FormatVersion, TakenAt: time.Now().UTC()}}); err != nil {
+		return counts, fmt.Errorf("write header: %w", err)
+	}
+
+	err = dump(ctx, tx, enc, &counts.Clinics, `
+		SELECT id, name, requires_approval FROM clinics ORDER BY id
+	`, func(rows pgx.Rows) (line, error) {
+		var c Clinic
+		err := rows.Scan(&c.ID, &c.Name, &c.RequiresApproval)
+		return line{Clinic: &c}, err
+	})
+	if err != nil {
+		return counts, fmt.Errorf("save clinics: %w", err)
+	}
+
+	err = dump(ctx, tx, enc, &counts.Clinicians, `
+		SELECT id, name, specialty, clinic_id, deactivated_at FROM clinicians ORDER BY id
+	`, func(rows pgx.Rows) (line, error) {
+		var c Clinician
+		err := rows.Scan(&c.ID, &c.Name, &c.Specialty, &c.ClinicID, &c.DeactivatedAt)
+		return line{Clinician: &c}, err
+	})
+	if err != nil {
+		return counts, fmt.Errorf("save clinicians: %w", err)
+	}
+
+	err = dump(ctx, tx, enc, &counts.Patients, `
+		SELECT id, name, email, phone, deactivated_at, deactivated_by, deactivation_reason
+		FROM patients ORDER BY id
+	`, func(rows pgx.Rows) (line, error) {
+		var p Patient
+		err := rows.Scan(&p.ID, &p.Name, &p.Email, &p.Phone, &p.DeactivatedAt, &p.DeactivatedBy, &p.DeactivationReason)
+		if err == nil && anon != nil {
+			anonymizePatient(&p, anon)
+		}
+		return line{Patient: &p}, err
+	})
+	if err != nil {
+		return counts, fmt.Errorf("save patients: %w", err)
+	}
+
+	err = dump(ctx, tx, enc, &counts.Slots, `
+		SELECT id, practitioner_id, start_time, end_time, status::text, capacity, slot_type
+		FROM appointment_slots ORDER BY start_time, id
+	`, func(rows pgx.Rows) (line, error) {
+		var s Slot
+		err := rows.Scan(&s.ID, &s.PractitionerID, &s.StartTime, &s.EndTime, &s.Status, &s.Capacity, &s.SlotType)
+		return line{Slot: &s}, err
+	})
+	if err != nil {
+		return counts, fmt.Errorf("save slots: %w", err)
+	}
+
+	return counts, bw.Flush()
+}
+
+func dump(ctx context.Context, tx pgx.Tx, enc *json.Encoder, n *int, query string, scan func(pgx.Rows) (line, error)) error {
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l, err := scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(l); err != nil {
+			return err
+		}
+		*n++
+	}
+	return rows.Err()
+}
+
+// Restore upserts the snapshot read from r into pool by id, in a single
+// transaction: either every row is written or none is. Rows already in the
+// target that the snapshot does not mention are left alone, as are
+// appointments and everything else outside the inventory.
+func Restore(ctx context.Context, pool *pgxpool.Pool, r io.Reader) (Counts, error) {
+	var counts Counts
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return counts, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = readLines(r, nil, func(l line) error {
+		return restoreLine(ctx, tx, l, &counts)
+	})
+	if err != nil {
+		return counts, err
+	}
+	return counts, tx.Commit(ctx)
+}
+
+// Anonymize copies the snapshot read from r to w with every patient
+// anonymized, for files saved without it
+func Anonymize(r io.Reader, w io.Writer, anon *anonymize.Anonymizer) (Counts, error) {
+	var counts Counts
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	err := readLines(r, func(h *Header) error {
+		return enc.Encode(line{Header: h})
+	}, func(l line) error {
+		switch {
+		case l.Clinic != nil:
+			counts.Clinics++
+		case l.Clinician != nil:
+			counts.Clinicians++
+		case l.Patient != nil:
+			anonymizePatient(l.Patient, anon)
+			counts.Patients++
+		case l.Slot != nil:
+			counts.Slots++
+		default:
+			return fmt.Errorf("%w: row of unknown kind", ErrFormat)
+		}
+		return enc.Encode(l)
+	})
+	if err != nil {
+		return counts, err
+	}
+	return counts, bw.Flush()
+}
+
+// readLines checks the header read from r and passes it to header, if set,
+// then passes each row to row
+func readLines(r io.Reader, header func(*Header) error, row func(line) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLine)
+
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: empty input", ErrFormat)
+	}
+	var first line
+	if err := json.Unmarshal(sc.Bytes(), &first); err != nil || first.Header == nil {
+		return fmt.Errorf("%w: missing header", ErrFormat)
+	}
+	if first.Header.Version != FormatVersion {
+		return fmt.Errorf("%w: format version %d, want %d", ErrFormat, first.Header.Version, FormatVersion)
+	}
+	if header != nil {
+		if err := header(first.Header); err != nil {
+			return err
+		}
+	}
+
+	for n := 2; sc.Scan(); n++ {
+		var l line
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrFormat, n, err)
+		}
+		if err := row(l); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return sc.Err()
+}
+
+func restoreLine(ctx context.Context, tx pgx.Tx, l line, counts *Counts) error {
+	var err error
+	switch {
+	case l.Clinic != nil:
+		c := l.Clinic
+		_, err = tx.Exec(ctx, `
+			INSERT INTO clinics (id, name, requires_approval, created_at, updated_at)
+			VALUES ($1, $2, $3, now(), now())
+			ON CONFLICT (id) DO UPDATE
+				SET name = EXCLUDED.name,
+				    requires_approval = EXCLUDED.requires_approval,
+				    updated_at = now()
+		`, c.ID, c.Name, c.RequiresApproval)
+		counts.Clinics++
+	case l.Clinician != nil:
+		c := l.Clinician
+		_, err = tx.Exec(ctx, `
+			INSERT INTO clinicians (id, name, specialty, clinic_id, deactivated_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, now(), now())
+			ON CONFLICT (id) DO UPDATE
+				SET name = EXCLUDED.name,
+				    specialty = EXCLUDED.specialty,
+				    clinic_id = EXCLUDED.clinic_id,
+				    deactivated_at = EXCLUDED.deactivated_at,
+				    updated_at = now()
+		`, c.ID, c.Name, c.Specialty, c.ClinicID, c.DeactivatedAt)
+		counts.Clinicians++
+	case l.Patient != nil:
+		p := l.Patient
+		_, err = tx.Exec(ctx, `
+			INSERT INTO patients (id, name, email, phone, deactivated_at, deactivated_by, deactivation_reason, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now())
+			ON CONFLICT (id) DO UPDATE
+				SET name = EXCLUDED.name,
+				    email = EXCLUDED.email,
+				    phone = EXCLUDED.phone,
+				    deactivated_at = EXCLUDED.deactivated_at,
+				    deactivated_by = EXCLUDED.deactivated_by,
+				    deactivation_reason = EXCLUDED.deactivation_reason,
+				    updated_at = now()
+		`, p.ID, p.Name, p.Email, p.Phone, p.DeactivatedAt, p.DeactivatedBy, p.DeactivationReason)
+		counts.Patients++
+	case l.Slot != nil:
+		s := l.Slot
+		_, err = tx.Exec(ctx, `
+			INSERT INTO appointment_slots (id, practitioner_id, start_time, end_time, status, capacity, slot_type, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5::slot_status, $6, $7, now(), now())
+			ON CONFLICT (id) DO UPDATE
+				SET practitioner_id = EXCLUDED.practitioner_id,
+				    start_time = EXCLUDED.start_time,
+				    end_time = EXCLUDED.end_time,
+				    status = EXCLUDED.status,
+				    capacity = EXCLUDED.capacity,
+				    slot_type = EXCLUDED.slot_type,
+				    updated_at = now()
+		`, s.ID, s.PractitionerID, s.StartTime, s.EndTime, s.Status, s.Capacity, s.SlotType)
+		counts.Slots++
+	default:
+		return fmt.Errorf("%w: row of unknown kind", ErrFormat)
+	}
+	return err
+}
@@ -0,0 +1,146 @@
+package verify
+
+import (
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+// confirmedSpans pairs each confirmed or checked-in appointment with every
+// slot it spans
+const confirmedSpans = `(
+			    SELECT id, slot_id FROM appointments WHERE status IN ('confirmed', 'checked_in')
+			    UNION ALL
+			    SELECT x.appointment_id, x.slot_id
+			    FROM appointment_extra_slots x
+			    JOIN appointments c ON c.id = x.appointment_id AND c.status IN ('confirmed', 'checked_in')
+			)`
+
+// Checks are the invariants Run verifies, in the order they run
+var Checks = []Check{
+	{
+		Name:        "slot_capacity",
+		Description: "no slot has more confirmed appointments than its capacity",
+		query: static(`
+			SELECT CAST(s.id AS TEXT),
+			       'confirmed ' || CAST(count(*) AS TEXT) || ' of capacity ' || CAST(s.capacity AS TEXT)
+			FROM appointment_slots s
+			JOIN ` + confirmedSpans + ` a ON a.slot_id = s.id
+			GROUP BY s.id, s.capacity
+			HAVING count(*) > s.capacity`),
+	},
+	{
+		Name:        "slot_confirmed_count",
+		Description: "each slot's confirmed_count matches its confirmed appointments",
+		query: static(`
+			SELECT CAST(s.id AS TEXT),
+			       'confirmed_count ' || CAST(s.confirmed_count AS TEXT) || ', confirmed ' || CAST(count(a.id) AS TEXT)
+			FROM appointment_slots s
+			LEFT JOIN ` + confirmedSpans + ` a ON a.slot_id = s.id
+			GROUP BY s.id, s.confirmed_count
+			HAVING count(a.id) <> s.confirmed_count`),
+	},
+	{
+		Name:        "appointment_status",
+		Description: "every appointment has a known status",
+		query: static(`
+			SELECT CAST(id AS TEXT), 'status ' || CAST(status AS TEXT)
+			FROM appointments
+			WHERE CAST(status AS TEXT) NOT IN ('pending', 'confirmed', 'cancelled', 'expired', 'pending_approval', 'rejected', 'checked_in', 'completed', 'no_show')`),
+	},
+	{
+		Name:        "slot_status",
+		Description: "every slot has a known status",
+		query: static(`
+			SELECT CAST(id AS TEXT), 'status ' || CAST(status AS TEXT)
+			FROM appointment_slots
+			WHERE CAST(status AS TEXT) NOT IN ('open', 'blocked', 'deleted')`),
+	},
+	{
+		Name:        "event_created",
+		Description: "every appointment has an " + appointment.EventAppointmentCreated + " event",
+		settled:     true,
+		query: func(cutoff string) string {
+			return `
+			SELECT CAST(a.id AS TEXT), 'no ` + appointment.EventAppointmentCreated + ` event'
+			FROM appointments a
+			WHERE a.updated_at < ` + cutoff + `
+			  AND NOT EXISTS (
+			      SELECT 1 FROM event_logs e
+			      WHERE e.appointment_id = a.id AND e.event_type = '` + appointment.EventAppointmentCreated + `')`
+		},
+	},
+	{
+		Name:        "event_status",
+		Description: "every appointment past pending has the event for its status",
+		settled:     true,
+		query: func(cutoff string) string {
+			return `
+			SELECT CAST(a.id AS TEXT), CAST(a.status AS TEXT) || ' without its event'
+			FROM appointments a
+			WHERE a.status <> 'pending'
+			  AND a.updated_at < ` + cutoff + `
+			  AND NOT EXISTS (
+			      SELECT 1 FROM event_logs e
+			      WHERE e.appointment_id = a.id
+			        AND e.event_type = CASE CAST(a.status AS TEXT)
+			            WHEN 'confirmed' THEN '` + appointment.EventAppointmentConfirmed + `'
+			            WHEN 'cancelled' THEN '` + appointment.EventAppointmentCancelled + `'
+			            WHEN 'expired' THEN '` + appointment.EventAppointmentExpired + `'
+			            WHEN 'pending_approval' THEN '` + appointment.EventAppointmentApprovalRequested + `'
+			            WHEN 'rejected' THEN '` + appointment.EventAppointmentRejected + `'
+			            WHEN 'checked_in' THEN '` + appointment.EventAppointmentCheckedIn + `'
+			            WHEN 'completed' THEN '` + appointment.EventAppointmentCompleted + `'
+			            WHEN 'no_show' THEN '` + appointment.EventAppointmentNoShow + `'
+			        END)`
+		},
+	},
+	{
+		Name:        "fk_appointment_slot",
+		Description: "every appointment references an existing slot",
+		query: static(`
+			SELECT CAST(a.id AS TEXT), 'slot ' || CAST(a.slot_id AS TEXT) || ' missing'
+			FROM appointments a
+			LEFT JOIN appointment_slots s ON s.id = a.slot_id
+			WHERE s.id IS NULL`),
+	},
+	{
+		Name:        "fk_appointment_patient",
+		Description: "every appointment references an existing patient",
+		query: static(`
+			SELECT CAST(a.id AS TEXT), 'patient ' || CAST(a.patient_id AS TEXT) || ' missing'
+			FROM appointments a
+			LEFT JOIN patients p ON p.id = a.patient_id
+			WHERE p.id IS NULL`),
+	},
+	{
+		Name:        "fk_slot_clinician",
+		Description: "every slot references an existing clinician",
+		query: static(`
+			SELECT CAST(s.id AS TEXT), 'clinician ' || CAST(s.practitioner_id AS TEXT) || ' missing'
+			FROM appointment_slots s
+			LEFT JOIN clinicians c ON c.id = s.practitioner_id
+			WHERE c.id IS NULL`),
+	},
+	{
+		Name:        "fk_clinician_clinic",
+		Description: "every clinician with a clinic references an existing one",
+		query: static(`
+			SELECT CAST(c.id AS TEXT), 'clinic ' || CAST(c.clinic_id AS TEXT) || ' missing'
+			FROM clinicians c
+			LEFT JOIN clinics k ON k.id = c.clinic_id
+			WHERE c.clinic_id IS NOT NULL AND k.id IS NULL`),
+	},
+	{
+		Name:        "fk_event_appointment",
+		Description: "every event with an appointment references an existing one",
+		query: static(`
+			SELECT CAST(e.id AS TEXT), 'appointment ' || CAST(e.appointment_id AS TEXT) || ' missing'
+			FROM event_logs e
+			LEFT JOIN appointments a ON a.id = e.appointment_id
+			WHERE e.appointment_id IS NOT NULL AND a.id IS NULL`),
+	},
+}
+
+// static is the query of a check that takes no parameters
+func static(query string) func(string) string {
+	return func(string) string { return query }
+}
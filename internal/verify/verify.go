@@ -0,0 +1,137 @@
+// Package verify checks the data invariants the booking flow is meant to
+// uphold against a live database. The checks are plain SQL that runs
+// unchanged on Postgres and SQLite and only reads, so they are safe to point
+// at production.
+package verify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Check is one invariant. Its query selects a row per violation: the id of
+// the offending row and a description, both as text.
+type Check struct {
+	Name        string
+	Description string
+
+	// settled checks take the settle cutoff as their only parameter and
+	// ignore appointments updated after it
+	settled bool
+	query   func(param string) string
+}
+
+// Violation is one row breaking a check
+type Violation struct {
+	Subject string
+	Detail  string
+}
+
+// Result is the outcome of one check. Violations holds up to the sample
+// size given to Run; Count is the full number.
+type Result struct {
+	Check      Check
+	Count      int
+	Violations []Violation
+	Err        error
+}
+
+// Failed reports whether the check found violations or could not run
+func (r Result) Failed() bool {
+	return r.Err != nil || r.Count > 0
+}
+
+// Verifier runs the checks against one database
+type Verifier struct {
+	param string
+	query func(ctx context.Context, query string, args ...any) (rowIterator, error)
+}
+
+type rowIterator interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close()
+}
+
+// NewPostgres creates a verifier for a Postgres database
+func NewPostgres(pool *pgxpool.Pool) *Verifier {
+	return &Verifier{
+		param: "$1",
+		query: func(ctx context.Context, query string, args ...any) (rowIterator, error) {
+			return pool.Query(ctx, query, args...)
+		},
+	}
+}
+
+// NewSQLite creates a verifier for a SQLite database
+func NewSQLite(db *sql.DB) *Verifier {
+	return &Verifier{
+		param: "?",
+		query: func(ctx context.Context, query string, args ...any) (rowIterator, error) {
+			for i, a := range args {
+				if t, ok := a.(time.Time); ok {
+					args[i] = t.UTC()
+				}
+			}
+			rows, err := db.QueryContext(ctx, query, args...)
+			if err != nil {
+				return nil, err
+			}
+			return sqlRows{rows}, nil
+		},
+	}
+}
+
+// sqlRows adapts *sql.Rows, whose Close returns an error, to rowIterator
+type sqlRows struct{ *sql.Rows }
+
+func (r sqlRows) Close() { _ = r.Rows.Close() }
+
+// Run runs every check. Appointments updated after settledBefore are left
+// out of the checks on their events, which are written after the change
+// commits. Each result keeps up to samples violations.
+func (v *Verifier) Run(ctx context.Context, settledBefore time.Time, samples int) []Result {
+	results := make([]Result, 0, len(Checks))
+	for _, c := range Checks {
+		res := Result{Check: c}
+		res.Count, res.Violations, res.Err = v.run(ctx, c, settledBefore, samples)
+		results = append(results, res)
+	}
+	return results
+}
+
+func (v *Verifier) run(ctx context.Context, c Check, settledBefore time.Time, samples int) (int, []Violation, error) {
+	var args []any
+	if c.settled {
+		args = append(args, settledBefore)
+	}
+
+	rows, err := v.query(ctx, c.query(v.param), args...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("run %s: %w", c.Name, err)
+	}
+	defer rows.Close()
+
+	count := 0
+	var violations []Violation
+	for rows.Next() {
+		count++
+		if len(violations) >= samples {
+			continue
+		}
+		var vi Violation
+		if err := rows.Scan(&vi.Subject, &vi.Detail); err != nil {
+			return 0, nil, fmt.Errorf("scan %s: %w", c.Name, err)
+		}
+		violations = append(violations, vi)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("run %s: %w", c.Name, err)
+	}
+	return count, violations, nil
+}
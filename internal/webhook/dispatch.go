@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/jobs"
+)
+
+// DeliveryQueue is the jobs queue webhook deliveries are enqueued on
+const DeliveryQueue = "webhook_delivery"
+
+// deliveryJob is the payload of one queued delivery
+type deliveryJob struct {
+	SubscriptionID uuid.UUID      `json:"subscription_id"`
+	EventID        string         `json:"event_id"`
+	EventType      string         `json:"event_type"`
+	Data           map[string]any `json:"data"`
+}
+
+// Dispatcher fans appointment events out to matching subscriptions through
+// the job queue, so a slow or failing receiver never blocks a booking and
+// failed deliveries are retried.
+type Dispatcher struct {
+	svc   *Service
+	queue *jobs.Queue
+}
+
+func NewDispatcher(svc *Service, queue *jobs.Queue) *Dispatcher {
+	return &Dispatcher{
+		svc:   svc,
+		queue: queue,
+	}
+}
+
+// Publish enqueues one delivery per active subscription that wants eventType.
+// It satisfies appointment.EventPublisher.
+func (d *Dispatcher) Publish(ctx context.Context, eventType string, appointmentID uuid.UUID, data map[string]any) error {
+	subs, err := d.svc.repo.ListSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("list webhook subscriptions: %w", err)
+	}
+
+	payload := make(map[string]any, len(data)+1)
+	for k, v := range data {
+		payload[k] = v
+	}
+	payload["appointment_id"] = appointmentID.String()
+
+	eventID := uuid.NewString()
+	for _, sub := range subs {
+		if !sub.Active || !sub.Matches(eventType) {
+			continue
+		}
+
+		_, err := d.queue.Enqueue(ctx, DeliveryQueue, deliveryJob{
+			SubscriptionID: sub.ID,
+			EventID:        eventID,
+			EventType:      eventType,
+			Data:           payload,
+		})
+		if err != nil {
+			return fmt.Errorf("enqueue webhook delivery: %w", err)
+		}
+	}
+	return nil
+}
+
+// Handle delivers one queued event. A failed or non-2xx delivery returns an
+// error so the queue retries it; a deleted subscription completes the job.
+func (d *Dispatcher) Handle(ctx context.Context, job jobs.Job) error {
+	var dj deliveryJob
+	if err := job.Decode(&dj); err != nil {
+		return fmt.Errorf("decode webhook delivery: %w", err)
+	}
+
+	sub, err := d.svc.repo.GetSubscription(ctx, dj.SubscriptionID)
+	if err != nil {
+		if errors.Is(err, ErrSubscriptionNotFound) {
+			return nil
+		}
+		return fmt.Errorf("get webhook subscription: %w", err)
+	}
+	if !sub.Active {
+		return nil
+	}
+
+	delivery, err := d.svc.deliver(ctx, sub, dj.EventID, dj.EventType, dj.Data)
+	if err != nil {
+		return err
+	}
+	if !delivery.Succeeded() {
+		if delivery.Error != nil {
+			return errors.New(*delivery.Error)
+		}
+		return fmt.Errorf("receiver responded with status %d", *delivery.ResponseStatus)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/outbound"
+)
+
+// EventTest is only ever sent by the test-delivery endpoint
+const EventTest = "WEBHOOK_TEST"
+
+// EventTypes are the event types a subscription may filter on
+var EventTypes = []string{
+	appointment.EventAppointmentCreated,
+	appointment.EventAppointmentConfirmed,
+	appointment.EventAppointmentExpired,
+	appointment.EventAppointmentCancelled,
+	appointment.EventAppointmentApprovalRequested,
+	appointment.EventAppointmentRejected,
+	appointment.EventAttachmentAdded,
+	appointment.EventNoteAdded,
+	appointment.EventIntakeCompleted,
+	appointment.EventIntakeReminder,
+	appointment.EventFeedbackRequested,
+	appointment.EventFeedbackReceived,
+	appointment.EventAppointmentClinicianChanged,
+	appointment.EventAppointmentRescheduled,
+	appointment.EventHoldTransferred,
+	appointment.EventAppointmentCheckedIn,
+	appointment.EventAppointmentCompleted,
+	appointment.EventAppointmentNoShow,
+}
+
+type Subscription struct {
+	ID         uuid.UUID
+	URL        string
+	EventTypes []string
+	Secret     string
+	Active     bool
+
+	// Set after a rotation; the old secret keeps signing until it expires
+	PreviousSecret          *string
+	PreviousSecretExpiresAt *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SigningKeys returns the secrets outgoing deliveries are signed with
+func (s *Subscription) SigningKeys() []outbound.SigningKey {
+	keys := []outbound.SigningKey{{Secret: s.Secret}}
+	if s.PreviousSecret != nil {
+		keys = append(keys, outbound.SigningKey{
+			Secret:    *s.PreviousSecret,
+			ExpiresAt: s.PreviousSecretExpiresAt,
+		})
+	}
+	return keys
+}
+
+// Matches reports whether the subscription wants events of the given type
+func (s *Subscription) Matches(eventType string) bool {
+	if eventType == EventTest {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery records a single attempt to POST an event to a subscription
+type Delivery struct {
+	ID             int64
+	SubscriptionID uuid.UUID
+	EventType      string
+	Payload        []byte
+	ResponseStatus *int
+	Error          *string
+	Duration       time.Duration
+	CreatedAt      time.Time
+}
+
+// Succeeded reports whether the receiver acknowledged with a 2xx status
+func (d *Delivery) Succeeded() bool {
+	return d.Error == nil && d.ResponseStatus != nil && *d.ResponseStatus >= 200 && *d.ResponseStatus < 300
+}
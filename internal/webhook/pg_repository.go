@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PgRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPgRepository(pool *pgxpool.Pool) *PgRepository {
+	return &PgRepository{pool: pool}
+}
+
+func scanSubscription(row pgx.Row) (*Subscription, error) {
+	var s Subscription
+
+	err := row.Scan(
+		&s.ID,
+		&s.URL,
+		&s.EventTypes,
+		&s.Secret,
+		&s.Active,
+		&s.PreviousSecret,
+		&s.PreviousSecretExpiresAt,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSubscriptionNotFound
+		}
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func scanDelivery(row pgx.Row) (*Delivery, error) {
+	var d Delivery
+	var durationMs int
+
+	err := row.Scan(
+		&d.ID,
+		&d.SubscriptionID,
+		&d.EventType,
+		&d.Payload,
+		&d.ResponseStatus,
+		&d.Error,
+		&durationMs,
+		&d.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Duration = time.Duration(durationMs) * time.Millisecond
+	return &d, nil
+}
+
+func (r *PgRepository) CreateSubscription(ctx context.Context, sub Subscription) (*Subscription, error) {
+	row := r.pool.QueryRow(ctx, `
+		INSERT INTO webhook_subscriptions (id, url, event_types, secret, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, true, now(), now())
+		RETURNING id, url, event_types, secret, active, previous_secret, previous_secret_expires_at, created_at, updated_at
+	`, sub.ID, sub.URL, sub.EventTypes, sub.Secret)
+	return scanSubscription(row)
+}
+
+func (r *PgRepository) GetSubscription(ctx context.Context, id uuid.UUID) (*Subscription, error) {
+	row := r.pool.QueryRow(ctx, `
+		SELECT id, url, event_types, secret, active, previous_secret, previous_secret_expires_at, created_at, updated_at
+		FROM webhook_subscriptions
+		WHERE id = $1
+	`, id)
+	return scanSubscription(row)
+}
+
+func (r *PgRepository) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, url, event_types, secret, active, previous_secret, previous_secret_expires_at, created_at, updated_at
+		FROM webhook_subscriptions
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Subscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PgRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM webhook_subscriptions
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) RotateSecret(ctx context.Context, id uuid.UUID, secret string, previousExpiresAt time.Time) (*Subscription, error) {
+	row := r.pool.QueryRow(ctx, `
+		UPDATE webhook_subscriptions
+		SET previous_secret = secret,
+		    previous_secret_expires_at = $3,
+		    secret = $2,
+		    updated_at = now()
+		WHERE id = $1
+		RETURNING id, url, event_types, secret, active, previous_secret, previous_secret_expires_at, created_at, updated_at
+	`, id, secret, previousExpiresAt)
+	return scanSubscription(row)
+}
+
+func (r *PgRepository) InsertDelivery(ctx context.Context, d Delivery) (*Delivery, error) {
+	row := r.pool.QueryRow(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_type, payload, response_status, error, duration_ms, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, now())
+		RETURNING id, subscription_id, event_type, payload, response_status, error, duration_ms, created_at
+	`, d.SubscriptionID, d.EventType, d.Payload, d.ResponseStatus, d.Error, int(d.Duration.Milliseconds()))
+
+	delivery, err := scanDelivery(row)
+	if err != nil {
+		return nil, fmt.Errorf("insert webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+func (r *PgRepository) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]Delivery, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, subscription_id, event_type, payload, response_status, error, duration_ms, created_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Delivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
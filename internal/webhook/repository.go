@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+)
+
+var (
+	ErrSubscriptionNotFound = &appointment.Error{
+		Code: "webhook_not_found", HTTPStatus: http.StatusNotFound,
+		Message: "webhook subscription not found",
+	}
+)
+
+// Repository contains all DB interactions needed by the webhook service.
+type Repository interface {
+	CreateSubscription(ctx context.Context, sub Subscription) (*Subscription, error)
+	GetSubscription(ctx context.Context, id uuid.UUID) (*Subscription, error)
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+
+	// RotateSecret installs a new secret and keeps the current one as the
+	// previous secret until previousExpiresAt.
+	RotateSecret(ctx context.Context, id uuid.UUID, secret string, previousExpiresAt time.Time) (*Subscription, error)
+
+	// Delivery history
+	InsertDelivery(ctx context.Context, d Delivery) (*Delivery, error)
+	ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]Delivery, error)
+}
@@ -0,0 +1,240 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/appointment"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/outbound"
+)
+
+var (
+	ErrInvalidURL = &appointment.Error{
+		Code: "invalid_webhook_url", HTTPStatus: http.StatusBadRequest,
+		Message: "webhook url must be an absolute http or https URL",
+	}
+	ErrInvalidEventType = &appointment.Error{
+		Code: "invalid_event_types", HTTPStatus: http.StatusBadRequest,
+		Message: "unknown webhook event type",
+	}
+	ErrNoEventTypes = &appointment.Error{
+		Code: "invalid_event_types", HTTPStatus: http.StatusBadRequest,
+		Message: "at least one event type is required",
+	}
+)
+
+const deliveryHistoryLimit = 50
+
+type Service struct {
+	repo        Repository
+	clients     *outbound.ClientPool
+	secretGrace time.Duration
+}
+
+// NewService creates the webhook service. After a secret rotation the old
+// secret keeps being used for signatures for secretGrace.
+func NewService(repo Repository, clients *outbound.ClientPool, secretGrace time.Duration) *Service {
+	return &Service{
+		repo:        repo,
+		clients:     clients,
+		secretGrace: secretGrace,
+	}
+}
+
+// CreateSubscription validates and stores a new subscription with a freshly generated secret
+func (s *Service) CreateSubscription(ctx context.Context, rawURL string, eventTypes []string) (*Subscription, error) {
+	if err := validateURL(rawURL); err != nil {
+		return nil, err
+	}
+	if err := validateEventTypes(eventTypes); err != nil {
+		return nil, err
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generate secret: %w", err)
+	}
+
+	sub, err := s.repo.CreateSubscription(ctx, Subscription{
+		ID:         uuid.New(),
+		URL:        rawURL,
+		EventTypes: eventTypes,
+		Secret:     secret,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create webhook subscription: %w", err)
+	}
+	return sub, nil
+}
+
+func (s *Service) GetSubscription(ctx context.Context, id uuid.UUID) (*Subscription, error) {
+	sub, err := s.repo.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get webhook subscription: %w", err)
+	}
+	return sub, nil
+}
+
+func (s *Service) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	subs, err := s.repo.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+func (s *Service) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.DeleteSubscription(ctx, id); err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// RotateSecret replaces the signing secret. The new secret is only returned here;
+// deliveries are signed with both secrets until the grace period ends.
+func (s *Service) RotateSecret(ctx context.Context, id uuid.UUID) (*Subscription, error) {
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generate secret: %w", err)
+	}
+
+	sub, err := s.repo.RotateSecret(ctx, id, secret, time.Now().Add(s.secretGrace))
+	if err != nil {
+		return nil, fmt.Errorf("rotate webhook secret: %w", err)
+	}
+	return sub, nil
+}
+
+// SendTest delivers a WEBHOOK_TEST event to the subscription and records the attempt
+func (s *Service) SendTest(ctx context.Context, id uuid.UUID) (*Delivery, error) {
+	sub, err := s.repo.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get webhook subscription: %w", err)
+	}
+
+	return s.Deliver(ctx, sub, EventTest, map[string]any{
+		"subscription_id": sub.ID.String(),
+		"message":         "test delivery",
+	})
+}
+
+// Deliver POSTs a single event to a subscription and records the attempt.
+// A non-2xx response is recorded but is not returned as an error.
+func (s *Service) Deliver(ctx context.Context, sub *Subscription, eventType string, data map[string]any) (*Delivery, error) {
+	return s.deliver(ctx, sub, uuid.NewString(), eventType, data)
+}
+
+// deliver sends the event under a caller-chosen id, so retries of the same
+// event carry the same id and receivers can deduplicate.
+func (s *Service) deliver(ctx context.Context, sub *Subscription, eventID, eventType string, data map[string]any) (*Delivery, error) {
+	body, err := json.Marshal(map[string]any{
+		"id":         eventID,
+		"event_type": eventType,
+		"created_at": time.Now().UTC(),
+		"data":       data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal webhook payload: %w", err)
+	}
+
+	attempt := Delivery{
+		SubscriptionID: sub.ID,
+		EventType:      eventType,
+		Payload:        body,
+	}
+
+	start := time.Now()
+	status, sendErr := s.post(ctx, sub, eventType, body)
+	attempt.Duration = time.Since(start)
+
+	if sendErr != nil {
+		msg := sendErr.Error()
+		attempt.Error = &msg
+	} else {
+		attempt.ResponseStatus = &status
+	}
+
+	recorded, err := s.repo.InsertDelivery(ctx, attempt)
+	if err != nil {
+		return nil, err
+	}
+	return recorded, nil
+}
+
+func (s *Service) post(ctx context.Context, sub *Subscription, eventType string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", eventType)
+	req.Header.Set(outbound.SignatureHeader, outbound.Sign(sub.SigningKeys(), time.Now(), body))
+
+	resp, err := s.clients.For(sub.URL).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// ListDeliveries returns the most recent delivery attempts for a subscription
+func (s *Service) ListDeliveries(ctx context.Context, id uuid.UUID) ([]Delivery, error) {
+	if _, err := s.repo.GetSubscription(ctx, id); err != nil {
+		return nil, fmt.Errorf("get webhook subscription: %w", err)
+	}
+
+	deliveries, err := s.repo.ListDeliveries(ctx, id, deliveryHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return ErrInvalidURL
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+func validateEventTypes(eventTypes []string) error {
+	if len(eventTypes) == 0 {
+		return ErrNoEventTypes
+	}
+	for _, t := range eventTypes {
+		known := false
+		for _, k := range EventTypes {
+			if t == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%w: %s", ErrInvalidEventType, t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// jobOverride replaces a job's built-in schedule
+type jobOverride struct {
+	schedule string
+	enabled  bool
+	source   string
+}
+
+// applyOverrides replaces job schedules from, in increasing precedence, the
+// JOB_SCHEDULE_<NAME> environment variable and the worker_jobs table. NAME is
+// the job name upper-cased with dashes as underscores. A disabled row removes
+// the job for this process.
+func (rt *Runtime) applyOverrides(ctx context.Context) error {
+	overrides := make(map[string]jobOverride)
+
+	for _, job := range rt.jobs {
+		if v := os.Getenv(envScheduleKey(job.Name)); v != "" {
+			overrides[job.Name] = jobOverride{schedule: v, enabled: true, source: envScheduleKey(job.Name)}
+		}
+	}
+
+	rows, err := loadJobRows(ctx, rt)
+	if err != nil {
+		return err
+	}
+	for name, o := range rows {
+		overrides[name] = o
+	}
+
+	jobs := rt.jobs[:0]
+	for _, job := range rt.jobs {
+		o, ok := overrides[job.Name]
+		if !ok {
+			jobs = append(jobs, job)
+			continue
+		}
+		if !o.enabled {
+			log.Printf("job %s disabled by %s", job.Name, o.source)
+			continue
+		}
+		if o.schedule != "" {
+			sched, err := ParseSchedule(o.schedule, time.UTC)
+			if err != nil {
+				return fmt.Errorf("job %s (%s): %w", job.Name, o.source, err)
+			}
+			job.Schedule = sched
+		}
+		jobs = append(jobs, job)
+	}
+	rt.jobs = jobs
+	return nil
+}
+
+func envScheduleKey(name string) string {
+	return "JOB_SCHEDULE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// loadJobRows reads the worker_jobs table. A missing table is not an error so
+// workers keep starting before migration 0009 is applied.
+func loadJobRows(ctx context.Context, rt *Runtime) (map[string]jobOverride, error) {
+	rows, err := rt.Postgres.Query(ctx, `
+		SELECT name, COALESCE(schedule, ''), enabled
+		FROM worker_jobs
+	`)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("load worker_jobs: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]jobOverride)
+	for rows.Next() {
+		var name string
+		var o jobOverride
+		if err := rows.Scan(&name, &o.schedule, &o.enabled); err != nil {
+			return nil, fmt.Errorf("load worker_jobs: %w", err)
+		}
+		o.source = "worker_jobs"
+		result[name] = o
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("load worker_jobs: %w", err)
+	}
+	return result, nil
+}
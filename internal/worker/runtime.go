@@ -0,0 +1,313 @@
+// Package worker is the shared runtime for background worker binaries. It
+// loads config, connects Postgres and Redis, runs registered jobs on their
+// own schedules and serves health and metrics endpoints until SIGTERM.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/hackgods/distributed-appointment-scheduling/internal/api"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/config"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/db"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/metrics"
+	redisclient "github.com/hackgods/distributed-appointment-scheduling/internal/redis"
+	"github.com/hackgods/distributed-appointment-scheduling/internal/shard"
+)
+
+// DefaultJobTimeout bounds a single run when Job.Timeout is zero
+const DefaultJobTimeout = 20 * time.Second
+
+// Job is one unit of periodic work
+type Job struct {
+	Name string
+
+	// Schedule decides when the job runs; when nil it runs every Interval.
+	// Either can be overridden at startup, see loadOverrides.
+	Schedule Schedule
+	Interval time.Duration
+	Timeout  time.Duration
+
+	// Run performs one pass. stop is closed when shutdown starts: jobs that
+	// work in batches should finish the current batch and return. ctx stays
+	// valid for up to Config.ShutdownTimeout after that.
+	Run func(ctx context.Context, stop <-chan struct{}) error
+}
+
+// Runtime holds the shared dependencies handed to jobs
+type Runtime struct {
+	Name     string
+	Config   config.Config
+	Postgres *pgxpool.Pool
+	Redis    *redis.Client
+
+	// Shards routes booking data by tenant; Postgres is its default shard.
+	// Jobs over booking data run once per shard, see shard.WithShard.
+	Shards *shard.Set
+
+	jobs []Job
+
+	mu      sync.Mutex
+	lastErr map[string]error
+}
+
+var (
+	jobRuns = metrics.NewCounter(
+		"worker_job_runs_total",
+		"Job runs by job and result.",
+		"job", "result",
+	)
+	jobDuration = metrics.NewGauge(
+		"worker_job_last_duration_seconds",
+		"Duration of the most recent run of each job.",
+		"job",
+	)
+	jobLastSuccess = metrics.NewGauge(
+		"worker_job_last_success_timestamp_seconds",
+		"Unix time of the last successful run of each job.",
+		"job",
+	)
+)
+
+// Register adds a job. It must be called before the runtime starts.
+func (rt *Runtime) Register(job Job) {
+	if job.Timeout == 0 {
+		job.Timeout = DefaultJobTimeout
+	}
+	if job.Schedule == nil {
+		job.Schedule = Every(job.Interval)
+	}
+	rt.jobs = append(rt.jobs, job)
+}
+
+// Main runs a worker binary: setup registers jobs against the connected
+// runtime, then jobs run until SIGINT/SIGTERM. Startup errors are fatal.
+func Main(name string, setup func(rt *Runtime) error) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	log.Printf("%s starting up", name)
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config load error: %v", err)
+	}
+
+	rootCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Connect Postgres
+	pgCtx, cancelPg := context.WithTimeout(rootCtx, 10*time.Second)
+	pgPool, err := db.ConnectPostgres(pgCtx, shard.Default, cfg.PostgresDSN, cfg.DBRequestTags)
+	cancelPg()
+	if err != nil {
+		log.Fatalf("postgres connection error: %v", err)
+	}
+	defer pgPool.Close()
+	log.Println("connected to Postgres")
+	shards, err := shard.Connect(rootCtx, pgPool, cfg.ShardDSNs, cfg.TenantShards, cfg.DBRequestTags)
+	if err != nil {
+		log.Fatalf("shard connection error: %v", err)
+	}
+	defer shards.Close()
+	if shards.Sharded() {
+		log.Printf("connected to shards %s", strings.Join(shards.Names(), ", "))
+	}
+	go db.NewPoolMonitor(shards.Pools(), cfg.PoolWaitAlert).Run(rootCtx, db.PoolSampleInterval)
+	if cfg.SchemaCheck {
+		for _, name := range shards.Names() {
+			st, err := db.CheckSchema(rootCtx, shards.Named(name), cfg.SchemaCompatWindow)
+			if err != nil {
+				log.Fatalf("schema check failed on shard %s: %v", name, err)
+			}
+			log.Printf("shard %s schema at migration %d, binary ships %d, %d contract migration(s) pending",
+				name, st.Database, st.Binary, len(st.Pending))
+		}
+	}
+
+	redisCtx, cancelRedis := context.WithTimeout(rootCtx, 10*time.Second)
+	redisClients, err := redisclient.Connect(redisCtx, redisclient.Options{
+		Addr:      cfg.RedisAddr,
+		Username:  cfg.RedisUsername,
+		Password:  cfg.RedisPassword,
+		PoolSizes: cfg.RedisPoolSizes,
+	}, redisclient.RoleLocking)
+	cancelRedis()
+	if err != nil {
+		log.Fatalf("redis connection error: %v", err)
+	}
+	defer func() {
+		if err := redisClients.Close(); err != nil {
+			log.Printf("error closing redis: %v", err)
+		}
+	}()
+	log.Println("connected to Redis")
+	go redisClients.Monitor(rootCtx, redisclient.MonitorInterval)
+	rdb := redisClients.Client(redisclient.RoleLocking)
+
+	rt := &Runtime{
+		Name:     name,
+		Config:   cfg,
+		Postgres: pgPool,
+		Redis:    rdb,
+		Shards:   shards,
+		lastErr:  make(map[string]error),
+	}
+	if err := setup(rt); err != nil {
+		log.Fatalf("%s setup error: %v", name, err)
+	}
+	if err := rt.applyOverrides(rootCtx); err != nil {
+		log.Fatalf("%s job schedule error: %v", name, err)
+	}
+	if len(rt.jobs) == 0 {
+		log.Fatalf("%s has no jobs enabled", name)
+	}
+
+	log.Printf("running %s in env=%s jobs=%d health_port=%s", name, cfg.Env, len(rt.jobs), cfg.WorkerHealthPort)
+
+	server := &http.Server{
+		Addr:              ":" + cfg.WorkerHealthPort,
+		Handler:           rt.router(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("health server error: %v", err)
+		}
+	}()
+
+	rt.run(rootCtx)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = server.Shutdown(shutdownCtx)
+
+	log.Printf("shutting down %s", name)
+}
+
+// run starts every job and blocks until shutdown has drained them
+func (rt *Runtime) run(rootCtx context.Context) {
+	// Runs in flight when shutdown starts get ShutdownTimeout to finish
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(rootCtx))
+	defer cancelWork()
+	go func() {
+		<-rootCtx.Done()
+		time.AfterFunc(rt.Config.ShutdownTimeout, cancelWork)
+	}()
+
+	var wg sync.WaitGroup
+	for _, job := range rt.jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			rt.loop(rootCtx, workCtx, job)
+		}(job)
+	}
+
+	<-rootCtx.Done()
+	log.Println("shutdown signal received, waiting for running jobs")
+	wg.Wait()
+}
+
+func (rt *Runtime) loop(rootCtx, workCtx context.Context, job Job) {
+	log.Printf("job %s scheduled %s", job.Name, describe(job.Schedule))
+
+	// Interval jobs run once at startup, cron jobs wait for their first slot
+	if _, ok := job.Schedule.(everySchedule); ok {
+		rt.runOnce(rootCtx, workCtx, job)
+	}
+
+	for {
+		next := job.Schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("job %s schedule never fires again, stopping it", job.Name)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-rootCtx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			rt.runOnce(rootCtx, workCtx, job)
+		}
+	}
+}
+
+func describe(s Schedule) string {
+	if str, ok := s.(fmt.Stringer); ok {
+		return str.String()
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+func (rt *Runtime) runOnce(rootCtx, workCtx context.Context, job Job) {
+	ctx, cancel := context.WithTimeout(workCtx, job.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := job.Run(ctx, rootCtx.Done())
+	elapsed := time.Since(start)
+
+	jobDuration.Set(elapsed.Seconds(), job.Name)
+
+	rt.mu.Lock()
+	rt.lastErr[job.Name] = err
+	rt.mu.Unlock()
+
+	if err != nil {
+		jobRuns.Inc(job.Name, "error")
+		log.Printf("job %s error after %s: %v", job.Name, elapsed, err)
+		return
+	}
+	jobRuns.Inc(job.Name, "ok")
+	jobLastSuccess.Set(float64(time.Now().Unix()), job.Name)
+}
+
+// router serves liveness, readiness (Postgres, Redis and the last result of
+// each job) and metrics
+func (rt *Runtime) router() http.Handler {
+	checks := []api.DependencyCheck{
+		api.PostgresCheck(rt.Postgres),
+		api.RedisCheck(rt.Redis),
+	}
+	for _, name := range rt.Shards.Names()[1:] {
+		check := api.PostgresCheck(rt.Shards.Named(name))
+		check.Name = "postgres:" + name
+		checks = append(checks, check)
+	}
+	for _, job := range rt.jobs {
+		name := job.Name
+		checks = append(checks, api.DependencyCheck{
+			Name: "job:" + name,
+			Check: func(context.Context) error {
+				rt.mu.Lock()
+				defer rt.mu.Unlock()
+				if err := rt.lastErr[name]; err != nil {
+					return fmt.Errorf("last run failed: %w", err)
+				}
+				return nil
+			},
+		})
+	}
+
+	health := api.NewHealthHandler(checks, nil, rt.Config.Env, os.Getenv("APP_VERSION"))
+
+	r := chi.NewRouter()
+	r.Get("/health/live", health.Liveness)
+	r.Get("/health/ready", health.Readiness)
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
+	return r
+}
@@ -0,0 +1,245 @@
+package worker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a job runs next
+type Schedule interface {
+	// Next returns the first run time strictly after t
+	Next(t time.Time) time.Time
+}
+
+type everySchedule struct {
+	interval time.Duration
+}
+
+// Every runs a job at a fixed interval
+func Every(d time.Duration) Schedule {
+	return everySchedule{interval: d}
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+func (s everySchedule) String() string {
+	return "@every " + s.interval.String()
+}
+
+// cronSchedule holds one bitmask per field; bit n is set when value n matches
+type cronSchedule struct {
+	expr   string
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	loc    *time.Location
+
+	// when both day fields are restricted a day matches if either does
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseSchedule accepts "@every <duration>", a descriptor such as "@daily",
+// or a five-field cron expression (minute hour day-of-month month
+// day-of-week). Cron times are evaluated in loc unless the expression starts
+// with "CRON_TZ=<zone> ", e.g. "CRON_TZ=America/New_York 0 2 * * *".
+func ParseSchedule(expr string, loc *time.Location) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	orig := expr
+
+	if rest, ok := strings.CutPrefix(expr, "CRON_TZ="); ok {
+		zone, spec, found := strings.Cut(rest, " ")
+		if !found {
+			return nil, fmt.Errorf("schedule %q: missing expression after CRON_TZ", orig)
+		}
+		l, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", orig, err)
+		}
+		loc = l
+		expr = strings.TrimSpace(spec)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	if d, ok := strings.CutPrefix(expr, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("schedule %q: invalid @every duration", orig)
+		}
+		return Every(interval), nil
+	}
+	if spec, ok := cronDescriptors[expr]; ok {
+		expr = spec
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("schedule %q: expected %d cron fields, got %d", orig, len(cronFields), len(parts))
+	}
+
+	masks := make([]uint64, len(parts))
+	for i, part := range parts {
+		m, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", orig, err)
+		}
+		masks[i] = m
+	}
+
+	// 7 is accepted as Sunday
+	if masks[4]&(1<<7) != 0 {
+		masks[4] |= 1
+	}
+
+	return &cronSchedule{
+		expr:    orig,
+		minute:  masks[0],
+		hour:    masks[1],
+		dom:     masks[2],
+		month:   masks[3],
+		dow:     masks[4],
+		loc:     loc,
+		domStar: parts[2] == "*" || parts[2] == "?",
+		dowStar: parts[4] == "*" || parts[4] == "?",
+	}, nil
+}
+
+func parseCronField(field string, f cronField) (uint64, error) {
+	upper := f.max
+	if f.name == "day of week" {
+		upper = 7
+	}
+
+	var mask uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepPart, f.name)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, upper
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			if f.name == "day of week" {
+				hi = f.max
+			}
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid %s %q", f.name, a)
+			}
+			if hi, err = strconv.Atoi(b); err != nil {
+				return 0, fmt.Errorf("invalid %s %q", f.name, b)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid %s %q", f.name, rangePart)
+			}
+			lo = n
+			hi = n
+			if hasStep {
+				hi = upper
+			}
+		}
+
+		if lo < f.min || hi > upper || lo > hi {
+			return 0, fmt.Errorf("%s %q out of range %d-%d", f.name, item, f.min, f.max)
+		}
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+func (s *cronSchedule) String() string {
+	return s.expr
+}
+
+// Next walks forward field by field, jumping a whole month, day or hour when
+// that field does not match. Working in the schedule's location keeps
+// "02:00 local" correct across DST changes.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+
+	// Give up after five years, which only happens for impossible dates like Feb 30
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc))
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc))
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc))
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// forward guards against time.Date resolving a wall time inside a DST gap to
+// an instant at or before t; a local hour skipped by DST simply never matches.
+func forward(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Truncate(time.Minute).Add(time.Hour - time.Duration(t.Minute())*time.Minute)
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}